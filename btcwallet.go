@@ -171,14 +171,30 @@ func rpcClientConnectLoop(legacyRPCServer *legacyrpc.Server, loader *wallet.Load
 				continue
 			}
 			defer spvdb.Close()
+			// Peers specified through --connect are connected to
+			// exclusively, without querying DNS seeds.
+			trustedPeers := chain.TrustedPeersConfig{
+				Peers: cfg.AddPeers,
+			}
+			if len(cfg.ConnectPeers) > 0 {
+				trustedPeers = chain.TrustedPeersConfig{
+					Peers:     cfg.ConnectPeers,
+					Exclusive: true,
+				}
+			}
+			neutrinoCfg := neutrino.Config{
+				DataDir:     netDir,
+				Database:    spvdb,
+				ChainParams: *activeNet.Params,
+			}
+			err = trustedPeers.Apply(&neutrinoCfg)
+			if err != nil {
+				log.Errorf("Invalid Neutrino peers: %s", err)
+				continue
+			}
 			chainService, err = neutrino.NewChainService(
-				neutrino.Config{
-					DataDir:      netDir,
-					Database:     spvdb,
-					ChainParams:  *activeNet.Params,
-					ConnectPeers: cfg.ConnectPeers,
-					AddPeers:     cfg.AddPeers,
-				})
+				neutrinoCfg,
+			)
 			if err != nil {
 				log.Errorf("Couldn't create Neutrino ChainService: %s", err)
 				continue
@@ -1,8 +1,10 @@
 package chain
 
 import (
+	"bytes"
 	"container/list"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sync"
@@ -15,6 +17,7 @@ import (
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
 	"github.com/btcsuite/btcwallet/wtxmgr"
 )
 
@@ -47,6 +50,10 @@ type BitcoindClient struct {
 	// the RPC and ZMQ connections to a bitcoind node.
 	chainConn *BitcoindConn
 
+	// broadcasters holds the additional endpoints transactions are
+	// published to.
+	broadcasters broadcastSet
+
 	// bestBlock keeps track of the tip of the current best chain.
 	bestBlockMtx sync.RWMutex
 	bestBlock    waddrmgr.BlockStamp
@@ -56,14 +63,40 @@ type BitcoindClient struct {
 	// they are relevant to the client.
 	rescanUpdate chan interface{}
 
-	// watchedAddresses, watchedOutPoints, and watchedTxs are the set of
-	// items we should match transactions against while processing a chain
-	// rescan to determine if they are relevant to the client.
+	// watchedAddresses, watchedScripts, watchedOutPoints, and watchedTxs
+	// are the set of items we should match transactions against while
+	// processing a chain rescan to determine if they are relevant to the
+	// client. The raw output scripts within watchedScripts are keyed by
+	// their string conversion.
 	watchMtx         sync.RWMutex
 	watchedAddresses map[string]struct{}
+	watchedScripts   map[string]struct{}
 	watchedOutPoints map[wire.OutPoint]struct{}
 	watchedTxs       map[chainhash.Hash]struct{}
 
+	// watchedOutPointScripts holds the output scripts of the watched
+	// outpoints whose outputs are known, which allows matching the BIP158
+	// filters of blocks against the watch list.
+	watchedOutPointScripts map[wire.OutPoint][]byte
+
+	// watchListVersion is incremented whenever the watch list is updated
+	// through the rescanUpdate channel, allowing rescans to notice that
+	// their block filter query must be rebuilt.
+	watchListVersion uint64
+
+	// deferredUpdates holds the updates received through the
+	// rescanUpdate channel while rescanning which must only be handled
+	// once the rescan completes.
+	//
+	// NOTE: This must only be accessed within the rescanHandler
+	// goroutine.
+	deferredUpdates []interface{}
+
+	// blockFilterCatchUp indicates whether the blocks whose BIP158 filter
+	// doesn't match the watch list are connected from their header alone
+	// while catching up, as set through SetBlockFilterCatchUp.
+	blockFilterCatchUp bool
+
 	// mempool keeps track of all relevant transactions that have yet to be
 	// confirmed. This is used to shortcut the filtering process of a
 	// transaction when a new confirmed transaction notification is
@@ -81,6 +114,40 @@ type BitcoindClient struct {
 	// NOTE: This requires the watchMtx to be held.
 	expiredMempool map[int32]map[chainhash.Hash]struct{}
 
+	// scanConfirmed holds the hashes of the transactions confirmed while
+	// bitcoind's existing mempool is being scanned, if it is, such that
+	// those the scan retrieved before they confirmed aren't notified as
+	// unconfirmed after their confirmation.
+	//
+	// NOTE: This requires the watchMtx to be held.
+	scanConfirmed map[chainhash.Hash]struct{}
+
+	// mempoolStore is the database the hashes of the transactions within
+	// the client's mempool are persisted in, if set through
+	// SetMempoolStore.
+	mempoolStore walletdb.DB
+
+	// unpersistedMempool holds the hashes of the transactions added to
+	// the client's mempool that are yet to be persisted within its
+	// mempool store. They're persisted in batches once the watchMtx is
+	// released.
+	//
+	// NOTE: This requires the watchMtx to be held.
+	unpersistedMempool []chainhash.Hash
+
+	// mempoolPollInterval is the interval at which WaitForMempoolEntry
+	// polls bitcoind's mempool.
+	mempoolPollInterval time.Duration
+
+	// existingMempoolWatched and existingMempoolLoaded are set atomically
+	// once the transactions within bitcoind's mempool have been scanned
+	// after the watch list is first updated and after the first rescan
+	// completes, respectively, if LoadExistingMempool is set. The scans are
+	// serialized through existingMempoolMtx.
+	existingMempoolWatched uint32
+	existingMempoolLoaded  uint32
+	existingMempoolMtx     sync.Mutex
+
 	// notificationQueue is a concurrent unbounded queue that handles
 	// dispatching notifications to the subscriber of this client.
 	//
@@ -99,6 +166,17 @@ type BitcoindClient struct {
 	// retrieved from the backing bitcoind connection.
 	zmqBlockNtfns chan *wire.MsgBlock
 
+	// lifecycleRegistrations is a channel through which the output
+	// lifecycle watches of WatchOutputLifecycle are registered with the
+	// notification handler.
+	lifecycleRegistrations chan *outputLifecycleWatch
+
+	// lifecycleWatches is the set of registered output lifecycle watches,
+	// keyed by their ID assigned through lifecycleWatchCounter.
+	lifecycleMtx          sync.Mutex
+	lifecycleWatches      map[uint64]*outputLifecycleWatch
+	lifecycleWatchCounter uint64
+
 	quit chan struct{}
 	wg   sync.WaitGroup
 }
@@ -152,7 +230,7 @@ func (c *BitcoindClient) GetBlockVerbose(
 
 // GetBlockHash returns a block hash from the height.
 func (c *BitcoindClient) GetBlockHash(height int64) (*chainhash.Hash, error) {
-	return c.chainConn.client.GetBlockHash(height)
+	return c.chainConn.GetBlockHash(int32(height))
 }
 
 // GetBlockHeader returns a block header from the hash.
@@ -162,6 +240,23 @@ func (c *BitcoindClient) GetBlockHeader(
 	return c.chainConn.client.GetBlockHeader(hash)
 }
 
+// BlockHeightForTimestamp returns the height of the first block with a
+// timestamp at or after the given one, or the height of the tip if the
+// timestamp is after it. The block is located through a binary search over the
+// block headers served by bitcoind.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *BitcoindClient) BlockHeightForTimestamp(ts time.Time) (int32, error) {
+	_, tipHeight, err := c.GetBestBlock()
+	if err != nil {
+		return 0, err
+	}
+
+	return c.chainConn.timestamps.resolve(
+		ts, tipHeight, fetchHeaderByHeight(c),
+	)
+}
+
 // GetBlockHeaderVerbose returns a block header from the hash.
 func (c *BitcoindClient) GetBlockHeaderVerbose(
 	hash *chainhash.Hash) (*btcjson.GetBlockHeaderVerboseResult, error) {
@@ -190,6 +285,52 @@ func (c *BitcoindClient) GetRawTransactionVerbose(
 	return c.chainConn.client.GetRawTransactionVerbose(hash)
 }
 
+// GetMerkleProof returns a merkle proof of the inclusion of the transaction
+// within its block, generated through bitcoind's gettxoutproof, or
+// ErrTxNotConfirmed if it's within the mempool. Locating the block of a
+// confirmed transaction requires bitcoind's transaction index.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *BitcoindClient) GetMerkleProof(txHash chainhash.Hash) (*MerkleProof,
+	error) {
+
+	tx, err := c.GetRawTransactionVerbose(&txHash)
+	if err != nil {
+		return nil, err
+	}
+	if tx.BlockHash == "" {
+		return nil, ErrTxNotConfirmed
+	}
+
+	params := []json.RawMessage{
+		[]byte(fmt.Sprintf("[%q]", txHash.String())),
+		[]byte(fmt.Sprintf("%q", tx.BlockHash)),
+	}
+	resp, err := c.chainConn.client.RawRequest("gettxoutproof", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var proofHex string
+	if err := json.Unmarshal(resp, &proofHex); err != nil {
+		return nil, err
+	}
+	proofBytes, err := hex.DecodeString(proofHex)
+	if err != nil {
+		return nil, err
+	}
+	var merkleBlock wire.MsgMerkleBlock
+	err = merkleBlock.BtcDecode(
+		bytes.NewReader(proofBytes), wire.ProtocolVersion,
+		wire.BaseEncoding,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return merkleProofFromMerkleBlock(&merkleBlock, txHash)
+}
+
 // GetTxOut returns a txout from the outpoint info provided.
 func (c *BitcoindClient) GetTxOut(txHash *chainhash.Hash, index uint32,
 	mempool bool) (*btcjson.GetTxOutResult, error) {
@@ -197,11 +338,137 @@ func (c *BitcoindClient) GetTxOut(txHash *chainhash.Hash, index uint32,
 	return c.chainConn.client.GetTxOut(txHash, index, mempool)
 }
 
-// SendRawTransaction sends a raw transaction via bitcoind.
+// GetMempoolAncestors returns the mempool entries of all unconfirmed ancestors
+// of the mempool transaction with the given hash, keyed by their hash.
+func (c *BitcoindClient) GetMempoolAncestors(txHash *chainhash.Hash) (
+	map[chainhash.Hash]*btcjson.GetMempoolEntryResult, error) {
+
+	params := []json.RawMessage{
+		[]byte(fmt.Sprintf("%q", txHash.String())),
+		[]byte("true"),
+	}
+	resp, err := c.chainConn.client.RawRequest(
+		"getmempoolancestors", params,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries map[string]*btcjson.GetMempoolEntryResult
+	if err := json.Unmarshal(resp, &entries); err != nil {
+		return nil, err
+	}
+
+	ancestors := make(
+		map[chainhash.Hash]*btcjson.GetMempoolEntryResult, len(entries),
+	)
+	for txid, entry := range entries {
+		hash, err := chainhash.NewHashFromStr(txid)
+		if err != nil {
+			return nil, err
+		}
+		ancestors[*hash] = entry
+	}
+
+	return ancestors, nil
+}
+
+// GetMempoolSpender returns the transaction within bitcoind's mempool spending
+// the given outpoint, or nil if there is none. This requires bitcoind's
+// gettxspendingprevout command, available since version 24.0.
+func (c *BitcoindClient) GetMempoolSpender(op wire.OutPoint) (*wire.MsgTx,
+	error) {
+
+	prevOut, err := json.Marshal([]map[string]interface{}{{
+		"txid": op.Hash.String(),
+		"vout": op.Index,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.chainConn.client.RawRequest(
+		"gettxspendingprevout", []json.RawMessage{prevOut},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []struct {
+		SpendingTxID string `json:"spendingtxid"`
+	}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 || results[0].SpendingTxID == "" {
+		return nil, nil
+	}
+
+	spenderHash, err := chainhash.NewHashFromStr(results[0].SpendingTxID)
+	if err != nil {
+		return nil, err
+	}
+	spender, _, err := c.chainConn.GetRawTransaction(spenderHash)
+	return spender, err
+}
+
+// GetRawMempool returns the hashes of all transactions within bitcoind's
+// mempool.
+func (c *BitcoindClient) GetRawMempool() ([]*chainhash.Hash, error) {
+	return c.chainConn.client.GetRawMempool()
+}
+
+// GetIncrementalRelayFee returns the fee rate, in sat/kvB, by which bitcoind
+// requires a replacement to increase the fee paid for the transactions it
+// replaces, as reported by getmempoolinfo.
+func (c *BitcoindClient) GetIncrementalRelayFee() (btcutil.Amount, error) {
+	resp, err := c.chainConn.client.RawRequest("getmempoolinfo", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var info struct {
+		IncrementalRelayFee float64 `json:"incrementalrelayfee"`
+	}
+	if err := json.Unmarshal(resp, &info); err != nil {
+		return 0, err
+	}
+
+	return btcutil.NewAmount(info.IncrementalRelayFee)
+}
+
+// EstimateSmartFee returns the fee rate, in BTC/kvB, estimated by bitcoind for
+// a transaction to confirm within confTarget blocks.
+func (c *BitcoindClient) EstimateSmartFee(confTarget int64,
+	mode *btcjson.EstimateSmartFeeMode) (*btcjson.EstimateSmartFeeResult,
+	error) {
+
+	return c.chainConn.client.EstimateSmartFee(confTarget, mode)
+}
+
+// SendRawTransaction sends a raw transaction via bitcoind. The transaction is
+// also published to the client's broadcasters, if any. Once published, the
+// transaction is cached such that WaitForMempoolEntry can report why it was
+// rejected from the mempool, if it is.
 func (c *BitcoindClient) SendRawTransaction(tx *wire.MsgTx,
 	allowHighFees bool) (*chainhash.Hash, error) {
 
-	return c.chainConn.client.SendRawTransaction(tx, allowHighFees)
+	txHash, err := c.broadcasters.broadcast(
+		tx, allowHighFees, c.chainConn.client.SendRawTransaction,
+	)
+	if err != nil {
+		return nil, err
+	}
+	c.chainConn.rawTxCache.add(tx, nil)
+
+	return txHash, nil
+}
+
+// SetBroadcasters sets additional endpoints transactions are published to
+// through SendRawTransaction, alongside bitcoind. A transaction is then
+// considered published if any of them accepts it. The bitcoind node remains
+// the source of all notifications.
+func (c *BitcoindClient) SetBroadcasters(broadcasters ...Broadcaster) {
+	c.broadcasters.set(broadcasters)
 }
 
 // Notifications returns a channel to retrieve notifications from.
@@ -241,6 +508,22 @@ func (c *BitcoindClient) NotifySpent(outPoints []*wire.OutPoint) error {
 	return nil
 }
 
+// NotifyScript allows the chain backend to notify the caller whenever a
+// transaction pays to the given raw output script, which is useful for scripts
+// that can't be expressed as an address, such as bare multisig scripts. The
+// outputs paying to the script are watched for spends as well.
+func (c *BitcoindClient) NotifyScript(script []byte) error {
+	_ = c.NotifyBlocks()
+
+	select {
+	case c.rescanUpdate <- [][]byte{script}:
+	case <-c.quit:
+		return ErrBitcoindClientShuttingDown
+	}
+
+	return nil
+}
+
 // NotifyTx allows the chain backend to notify the caller whenever any of the
 // given transactions confirm within the chain.
 func (c *BitcoindClient) NotifyTx(txids []chainhash.Hash) error {
@@ -350,13 +633,9 @@ func (c *BitcoindClient) LoadTxFilter(reset bool, filters ...interface{}) error
 		}
 	}
 
-	for _, filter := range filters {
-		if err := updateFilter(filter); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	// The filters are applied at once, such that the existing mempool is
+	// only scanned once all of them are applied.
+	return updateFilter(watchListBatch(filters))
 }
 
 // RescanBlocks rescans any blocks passed, returning only the blocks that
@@ -475,6 +754,10 @@ func (c *BitcoindClient) Start() error {
 	}
 	c.bestBlockMtx.Unlock()
 
+	if err := c.loadMempool(); err != nil {
+		return fmt.Errorf("unable to load persisted mempool: %v", err)
+	}
+
 	c.wg.Add(1)
 	go c.rescanHandler()
 
@@ -515,74 +798,153 @@ func (c *BitcoindClient) rescanHandler() {
 	defer c.wg.Done()
 
 	for {
-		select {
-		case update := <-c.rescanUpdate:
-			switch update := update.(type) {
-
-			// We're clearing the filters.
-			case struct{}:
-				c.watchMtx.Lock()
-				c.watchedOutPoints = make(map[wire.OutPoint]struct{})
-				c.watchedAddresses = make(map[string]struct{})
-				c.watchedTxs = make(map[chainhash.Hash]struct{})
-				c.watchMtx.Unlock()
-
-			// We're adding the addresses to our filter.
-			case []btcutil.Address:
-				c.watchMtx.Lock()
-				for _, addr := range update {
-					c.watchedAddresses[addr.String()] = struct{}{}
-				}
-				c.watchMtx.Unlock()
+		// The updates deferred while rescanning are handled first, in
+		// the order they were received.
+		var update interface{}
+		if len(c.deferredUpdates) > 0 {
+			update = c.deferredUpdates[0]
+			c.deferredUpdates = c.deferredUpdates[1:]
+		} else {
+			select {
+			case update = <-c.rescanUpdate:
+			case <-c.quit:
+				return
+			}
+		}
 
-			// We're adding the outpoints to our filter.
-			case []wire.OutPoint:
-				c.watchMtx.Lock()
-				for _, op := range update {
-					c.watchedOutPoints[op] = struct{}{}
-				}
-				c.watchMtx.Unlock()
-			case []*wire.OutPoint:
-				c.watchMtx.Lock()
-				for _, op := range update {
-					c.watchedOutPoints[*op] = struct{}{}
-				}
-				c.watchMtx.Unlock()
-
-			// We're adding the outpoints that map to the scripts
-			// that we should scan for to our filter.
-			case map[wire.OutPoint]btcutil.Address:
-				c.watchMtx.Lock()
-				for op := range update {
-					c.watchedOutPoints[op] = struct{}{}
-				}
-				c.watchMtx.Unlock()
+		// We're starting a rescan from the hash.
+		hash, ok := update.(chainhash.Hash)
+		if !ok {
+			c.applyWatchUpdate(update)
+			continue
+		}
+		if err := c.rescan(hash); err != nil {
+			log.Errorf("Unable to complete chain rescan: %v", err)
+			continue
+		}
 
-			// We're adding the transactions to our filter.
-			case []chainhash.Hash:
-				c.watchMtx.Lock()
-				for _, txid := range update {
-					c.watchedTxs[txid] = struct{}{}
-				}
-				c.watchMtx.Unlock()
-			case []*chainhash.Hash:
-				c.watchMtx.Lock()
-				for _, txid := range update {
-					c.watchedTxs[*txid] = struct{}{}
-				}
-				c.watchMtx.Unlock()
+		// Now that the watch list is loaded, the transactions already
+		// within the mempool can be matched against it.
+		c.loadExistingMempool(&c.existingMempoolLoaded)
+	}
+}
 
-			// We're starting a rescan from the hash.
-			case chainhash.Hash:
-				if err := c.rescan(update); err != nil {
-					log.Errorf("Unable to complete chain "+
-						"rescan: %v", err)
-				}
+// watchListBatch is an update of the watch list made of several updates, which
+// are applied at once.
+type watchListBatch []interface{}
+
+// applyWatchUpdate applies an update of the watch list sent through the
+// rescanUpdate channel, other than a rescan request.
+func (c *BitcoindClient) applyWatchUpdate(update interface{}) {
+	c.watchMtx.Lock()
+	if !c.updateWatchList(update) {
+		c.watchMtx.Unlock()
+		return
+	}
+
+	// Any rescan in progress rebuilds its block filter query once it
+	// notices the watch list has changed.
+	c.watchListVersion++
+	c.watchMtx.Unlock()
+
+	// The watch list may be loaded without ever rescanning, so the
+	// transactions already within the mempool are also matched against it
+	// once it's first updated. The update is applied as a whole before,
+	// such that the scan matches all of it.
+	if _, ok := update.(struct{}); !ok {
+		c.loadExistingMempool(&c.existingMempoolWatched)
+	}
+}
+
+// updateWatchList applies an update of the watch list, returning whether it
+// was of a known type.
+//
+// NOTE: This must be called with the watchMtx held.
+func (c *BitcoindClient) updateWatchList(update interface{}) bool {
+	switch update := update.(type) {
+	case watchListBatch:
+		for _, u := range update {
+			if !c.updateWatchList(u) {
+				return false
+			}
+		}
+
+	// We're clearing the filters.
+	case struct{}:
+		c.watchedOutPoints = make(map[wire.OutPoint]struct{})
+		c.watchedOutPointScripts = make(map[wire.OutPoint][]byte)
+		c.watchedAddresses = make(map[string]struct{})
+		c.watchedScripts = make(map[string]struct{})
+		c.watchedTxs = make(map[chainhash.Hash]struct{})
+
+	// We're adding the addresses to our filter.
+	case []btcutil.Address:
+		for _, addr := range update {
+			c.watchedAddresses[addr.String()] = struct{}{}
+		}
+
+	// We're adding the raw output scripts to our filter.
+	case [][]byte:
+		for _, script := range update {
+			c.watchedScripts[string(script)] = struct{}{}
+		}
+
+	// We're adding the outpoints to our filter.
+	case []wire.OutPoint:
+		for _, op := range update {
+			c.watchedOutPoints[op] = struct{}{}
+		}
+	case []*wire.OutPoint:
+		for _, op := range update {
+			c.watchedOutPoints[*op] = struct{}{}
+		}
+
+	// We're adding the outpoints that map to the scripts that we should
+	// scan for to our filter.
+	case map[wire.OutPoint]btcutil.Address:
+		for op, addr := range update {
+			c.watchedOutPoints[op] = struct{}{}
+			c.watchOutPointScript(op, addr)
+		}
+
+	// We're adding the transactions to our filter.
+	case []chainhash.Hash:
+		for _, txid := range update {
+			c.watchedTxs[txid] = struct{}{}
+		}
+	case []*chainhash.Hash:
+		for _, txid := range update {
+			c.watchedTxs[*txid] = struct{}{}
+		}
+
+	default:
+		log.Warnf("Received unexpected filter type %T", update)
+		return false
+	}
+
+	return true
+}
+
+// applyRescanWatchUpdates applies the watch list updates received while
+// rescanning, such that the blocks yet to be rescanned are matched against
+// them. Any reset of the watch list or further rescan request, along with the
+// updates following it, is deferred until the rescan completes.
+//
+// NOTE: This must only be called within the rescanHandler goroutine.
+func (c *BitcoindClient) applyRescanWatchUpdates() {
+	for len(c.deferredUpdates) == 0 {
+		select {
+		case update := <-c.rescanUpdate:
+			switch update.(type) {
+			case chainhash.Hash, struct{}:
+				c.deferredUpdates = append(
+					c.deferredUpdates, update,
+				)
 			default:
-				log.Warnf("Received unexpected filter type %T",
-					update)
+				c.applyWatchUpdate(update)
 			}
-		case <-c.quit:
+
+		default:
 			return
 		}
 	}
@@ -602,6 +964,7 @@ func (c *BitcoindClient) ntfnHandler() {
 				log.Errorf("Unable to filter transaction %v: %v",
 					tx.TxHash(), err)
 			}
+			c.persistMempoolTxs()
 		case newBlock := <-c.zmqBlockNtfns:
 			// If the new block's previous hash matches the best
 			// hash known to us, then the new block is the next
@@ -614,6 +977,9 @@ func (c *BitcoindClient) ntfnHandler() {
 			if newBlock.Header.PrevBlock == bestBlock.Hash {
 				newBlockHeight := bestBlock.Height + 1
 				_ = c.filterBlock(newBlock, newBlockHeight, true)
+				c.matchOutputLifecycles(
+					newBlock, newBlockHeight,
+				)
 
 				// With the block successfully filtered, we'll
 				// make it our new best block.
@@ -633,6 +999,10 @@ func (c *BitcoindClient) ntfnHandler() {
 				log.Errorf("Unable to process chain reorg: %v",
 					err)
 			}
+
+		case watch := <-c.lifecycleRegistrations:
+			watch.registered <- c.registerOutputLifecycle(watch)
+
 		case <-c.quit:
 			return
 		}
@@ -707,6 +1077,13 @@ func (c *BitcoindClient) onFilteredBlockConnected(height int32,
 func (c *BitcoindClient) onBlockDisconnected(hash *chainhash.Hash, height int32,
 	timestamp time.Time) {
 
+	// The transactions confirmed in the block are no longer confirmed, and
+	// the block is no longer part of the main chain, so we'll make sure
+	// they aren't served from the caches as such.
+	c.chainConn.rawTxCache.blockDisconnected(hash)
+	c.chainConn.blockHashes.blockDisconnected(height)
+	c.chainConn.timestamps.blockDisconnected(height)
+
 	if c.shouldNotifyBlocks() {
 		select {
 		case c.notificationQueue.ChanIn() <- BlockDisconnected{
@@ -801,6 +1178,12 @@ func (c *BitcoindClient) reorg(currentBlock waddrmgr.BlockStamp,
 		return nil
 	}
 
+	// Keep track of the tip before the reorg, along with the number of
+	// blocks we disconnect from it, so we can report the reorg once it's
+	// been processed.
+	oldTip := currentBlock
+	var disconnected int32
+
 	// We'll now keep track of all the blocks known to the *chain*, starting
 	// from the best block known to us until the best block in the chain.
 	// This will let us fast-forward despite any future reorgs.
@@ -808,7 +1191,7 @@ func (c *BitcoindClient) reorg(currentBlock waddrmgr.BlockStamp,
 	blocksToNotify.PushFront(reorgBlock)
 	previousBlock := reorgBlock.Header.PrevBlock
 	for i := bestHeight - 1; i >= currentBlock.Height; i-- {
-		block, err := c.GetBlock(&previousBlock)
+		block, err := c.chainConn.getRawBlock(&previousBlock)
 		if err != nil {
 			return fmt.Errorf("unable to get block %v: %v",
 				previousBlock, err)
@@ -841,6 +1224,7 @@ func (c *BitcoindClient) reorg(currentBlock waddrmgr.BlockStamp,
 			&currentBlock.Hash, currentBlock.Height,
 			currentBlock.Timestamp,
 		)
+		disconnected++
 
 		// Our current block should now reflect the previous one to
 		// continue the common ancestor search.
@@ -857,7 +1241,7 @@ func (c *BitcoindClient) reorg(currentBlock waddrmgr.BlockStamp,
 
 		// Store the correct block in our list in order to notify it
 		// once we've found our common ancestor.
-		block, err := c.GetBlock(&previousBlock)
+		block, err := c.chainConn.getRawBlock(&previousBlock)
 		if err != nil {
 			return fmt.Errorf("unable to get block %v: %v",
 				previousBlock, err)
@@ -875,6 +1259,7 @@ func (c *BitcoindClient) reorg(currentBlock waddrmgr.BlockStamp,
 	c.onBlockDisconnected(
 		&currentBlock.Hash, currentBlock.Height, currentHeader.Timestamp,
 	)
+	disconnected++
 
 	currentBlock.Height--
 
@@ -890,6 +1275,7 @@ func (c *BitcoindClient) reorg(currentBlock waddrmgr.BlockStamp,
 		}
 
 		_ = c.filterBlock(nextBlock, nextHeight, true)
+		c.matchOutputLifecycles(nextBlock, nextHeight)
 
 		currentBlock.Height = nextHeight
 		currentBlock.Hash = nextHash
@@ -902,6 +1288,13 @@ func (c *BitcoindClient) reorg(currentBlock waddrmgr.BlockStamp,
 	c.bestBlock = currentBlock
 	c.bestBlockMtx.Unlock()
 
+	c.chainConn.reorged()
+	c.chainConn.emit(&ReorgEvent{
+		OldTip:       oldTip,
+		NewTip:       currentBlock,
+		Disconnected: disconnected,
+	})
+
 	return nil
 }
 
@@ -923,7 +1316,7 @@ func (c *BitcoindClient) FilterBlocks(
 	for i, block := range req.Blocks {
 		// TODO(conner): add prefetching, since we already know we'll be
 		// fetching *every* block
-		rawBlock, err := c.GetBlock(&block.Hash)
+		rawBlock, err := c.chainConn.getRawBlock(&block.Hash)
 		if err != nil {
 			return nil, err
 		}
@@ -990,9 +1383,33 @@ func (c *BitcoindClient) rescan(start chainhash.Hash) error {
 	}
 	headers.PushBack(previousHeader)
 
+	// If enabled, the blocks after the birthday whose filter doesn't match
+	// the watch list are connected from their header alone. The query is
+	// rebuilt whenever the watch list is updated, while the outpoints
+	// found while filtering blocks pay to the scripts within it.
+	var (
+		filterQuery   [][]byte
+		filterVersion uint64
+		filterBuilt   bool
+		filterFailed  bool
+		useFilters    bool
+	)
+
 	// Cycle through all of the blocks known to bitcoind, being mindful of
 	// reorgs.
 	for i := previousHeader.Height + 1; i <= bestBlock.Height; i++ {
+		// The items added to the watch list while rescanning, such as
+		// the addresses of the look-ahead window, must be matched
+		// against the blocks that follow.
+		c.applyRescanWatchUpdates()
+		rebuildQuery := !filterBuilt ||
+			c.currentWatchListVersion() != filterVersion
+		if c.blockFilterCatchUp && !filterFailed && rebuildQuery {
+			filterQuery, filterVersion, useFilters =
+				c.blockFilterQuery()
+			filterBuilt = true
+		}
+
 		hash, err := c.GetBlockHash(int64(i))
 		if err != nil {
 			return err
@@ -1023,8 +1440,31 @@ func (c *BitcoindClient) rescan(start chainhash.Hash) error {
 			}
 		}
 
-		if afterBirthday {
-			block, err = c.GetBlock(hash)
+		fetchBlock := afterBirthday
+		if afterBirthday && useFilters {
+			matched, err := c.matchBlockFilter(hash, filterQuery)
+			switch {
+			case err != nil:
+				log.Warnf("Unable to match filter of block "+
+					"%v, fetching blocks in full: %v",
+					hash, err)
+				useFilters = false
+				filterFailed = true
+
+			case !matched:
+				header, err := c.GetBlockHeader(hash)
+				if err != nil {
+					return err
+				}
+				block = &wire.MsgBlock{
+					Header: *header,
+				}
+				fetchBlock = false
+			}
+		}
+
+		if fetchBlock {
+			block, err = c.chainConn.getRawBlock(hash)
 			if err != nil {
 				return err
 			}
@@ -1047,7 +1487,7 @@ func (c *BitcoindClient) rescan(start chainhash.Hash) error {
 			if err != nil {
 				return err
 			}
-			block, err = c.GetBlock(hash)
+			block, err = c.chainConn.getRawBlock(hash)
 			if err != nil {
 				return err
 			}
@@ -1137,7 +1577,8 @@ func (c *BitcoindClient) rescan(start chainhash.Hash) error {
 func (c *BitcoindClient) shouldFilterBlock(blockTimestamp time.Time) bool {
 	c.watchMtx.RLock()
 	hasEmptyFilter := len(c.watchedAddresses) == 0 &&
-		len(c.watchedOutPoints) == 0 && len(c.watchedTxs) == 0
+		len(c.watchedScripts) == 0 && len(c.watchedOutPoints) == 0 &&
+		len(c.watchedTxs) == 0
 	c.watchMtx.RUnlock()
 
 	return !(blockTimestamp.Before(c.birthday) || hasEmptyFilter)
@@ -1200,7 +1641,8 @@ func (c *BitcoindClient) filterBlock(block *wire.MsgBlock, height int32,
 	// over 288 blocks ago.
 	c.watchMtx.Lock()
 	c.expiredMempool[height] = confirmedTxs
-	if oldBlock, ok := c.expiredMempool[height-288]; ok {
+	oldBlock, evicted := c.expiredMempool[height-288]
+	if evicted {
 		for txHash := range oldBlock {
 			delete(c.mempool, txHash)
 		}
@@ -1208,6 +1650,18 @@ func (c *BitcoindClient) filterBlock(block *wire.MsgBlock, height int32,
 	}
 	c.watchMtx.Unlock()
 
+	if evicted {
+		c.forgetMempoolTxs(oldBlock)
+		c.chainConn.mempoolEvicted(oldBlock)
+	}
+
+	if evicted && len(oldBlock) > 0 {
+		c.chainConn.emit(&MempoolEvictEvent{
+			Height:     height - 288,
+			NumEvicted: len(oldBlock),
+		})
+	}
+
 	if notify {
 		c.onFilteredBlockConnected(height, &block.Header, relevantTxs)
 		c.onBlockConnected(&blockHash, height, block.Header.Timestamp)
@@ -1237,15 +1691,32 @@ func (c *BitcoindClient) filterTx(tx *wire.MsgTx,
 		rec.Received = time.Unix(blockDetails.Time, 0)
 	}
 
+	// The record's hash is used throughout, rather than hashing the
+	// transaction again for each of its outputs.
+	txHash := rec.Hash
+
 	// We'll begin the filtering process by holding the lock to ensure we
 	// match exactly against what's currently in the filters.
 	c.watchMtx.Lock()
 	defer c.watchMtx.Unlock()
 
+	// Transactions retrieved from bitcoind's mempool by a scan that have
+	// confirmed since have been notified as confirmed already.
+	if c.scanConfirmed != nil {
+		if blockDetails != nil {
+			c.scanConfirmed[txHash] = struct{}{}
+		} else if _, ok := c.scanConfirmed[txHash]; ok {
+			return false, rec, nil
+		}
+	}
+
 	// If we've already seen this transaction and it's now been confirmed,
 	// then we'll shortcut the filter process by immediately sending a
 	// notification to the caller that the filter matches.
-	if _, ok := c.mempool[tx.TxHash()]; ok {
+	if _, ok := c.mempool[txHash]; ok {
+		if blockDetails != nil {
+			c.unwatchSpentOutPoints(tx)
+		}
 		if notify && blockDetails != nil {
 			c.onRelevantTx(rec, blockDetails)
 		}
@@ -1289,9 +1760,20 @@ func (c *BitcoindClient) filterTx(tx *wire.MsgTx,
 	}
 
 	// We'll also cycle through its outputs to determine if it pays to
-	// any of the currently watched addresses. If an output matches, we'll
-	// add it to our watch list.
+	// any of the currently watched scripts or addresses. If an output
+	// matches, we'll add it to our watch list.
 	for i, txOut := range tx.TxOut {
+		op := wire.OutPoint{
+			Hash:  txHash,
+			Index: uint32(i),
+		}
+		if _, ok := c.watchedScripts[string(txOut.PkScript)]; ok {
+			isRelevant = true
+			c.watchedOutPoints[op] = struct{}{}
+			c.watchedOutPointScripts[op] = txOut.PkScript
+			continue
+		}
+
 		_, addrs, _, err := txscript.ExtractPkScriptAddrs(
 			txOut.PkScript, c.chainConn.cfg.ChainParams,
 		)
@@ -1303,11 +1785,8 @@ func (c *BitcoindClient) filterTx(tx *wire.MsgTx,
 		for _, addr := range addrs {
 			if _, ok := c.watchedAddresses[addr.String()]; ok {
 				isRelevant = true
-				op := wire.OutPoint{
-					Hash:  tx.TxHash(),
-					Index: uint32(i),
-				}
 				c.watchedOutPoints[op] = struct{}{}
+				c.watchedOutPointScripts[op] = txOut.PkScript
 			}
 		}
 	}
@@ -1315,7 +1794,7 @@ func (c *BitcoindClient) filterTx(tx *wire.MsgTx,
 	// If the transaction didn't pay to any of our watched addresses, we'll
 	// check if we're currently watching for the hash of this transaction.
 	if !isRelevant {
-		if _, ok := c.watchedTxs[tx.TxHash()]; ok {
+		if _, ok := c.watchedTxs[txHash]; ok {
 			isRelevant = true
 		}
 	}
@@ -1330,7 +1809,14 @@ func (c *BitcoindClient) filterTx(tx *wire.MsgTx,
 	// our mempool so that it can also be notified as part of
 	// FilteredBlockConnected once it confirms.
 	if blockDetails == nil {
-		c.mempool[tx.TxHash()] = struct{}{}
+		c.mempool[txHash] = struct{}{}
+		if c.mempoolStore != nil {
+			c.unpersistedMempool = append(
+				c.unpersistedMempool, txHash,
+			)
+		}
+	} else {
+		c.unwatchSpentOutPoints(tx)
 	}
 
 	c.onRelevantTx(rec, blockDetails)
@@ -2,6 +2,10 @@ package chain
 
 import (
 	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -83,6 +87,79 @@ type BitcoindConfig struct {
 	//
 	// NOTE: This only applies for pruned bitcoind nodes.
 	PrunedModeMaxPeers int
+
+	// EventSink is an optional sink that will receive structured events
+	// for significant occurrences within the connection and its rescan
+	// clients. If nil, events are written to the package logger.
+	EventSink EventSink
+
+	// RawTxCacheSize is the maximum number of raw transactions cached by
+	// GetRawTransaction. If zero, a default size is used.
+	RawTxCacheSize int
+
+	// BlockHashCacheSize is the maximum number of block hashes cached by
+	// GetBlockHash. If zero, a default size is used.
+	BlockHashCacheSize int
+
+	// BlockHashCacheSafeDepth is the depth below the best known height
+	// from which block hashes cached by GetBlockHash are considered safe
+	// from reorgs, and are no longer invalidated as new blocks are
+	// connected. If zero, a default depth is used.
+	BlockHashCacheSafeDepth int32
+
+	// HealthCheck configures the periodic health check of the bitcoind
+	// node, performed through getblockcount.
+	HealthCheck HealthCheckConfig
+
+	// LoadExistingMempool indicates whether the rescan clients scan the
+	// transactions already within bitcoind's mempool once their watch list
+	// is first updated and once their first rescan completes, notifying
+	// those relevant to their watch list. Otherwise, only the transactions
+	// entering the mempool afterwards are notified.
+	LoadExistingMempool bool
+}
+
+// BitcoindConnStats describes the current state of a BitcoindConn.
+type BitcoindConnStats struct {
+	// RawTxCacheHits is the number of GetRawTransaction calls served from
+	// the raw transaction cache.
+	RawTxCacheHits uint64
+
+	// RawTxCacheMisses is the number of GetRawTransaction calls that had
+	// to be served by bitcoind.
+	RawTxCacheMisses uint64
+
+	// RawTxCacheSize is the number of raw transactions currently cached.
+	RawTxCacheSize int
+
+	// BlockHashCacheHits is the number of GetBlockHash calls served from
+	// the block hash cache.
+	BlockHashCacheHits uint64
+
+	// BlockHashCacheMisses is the number of GetBlockHash calls that had
+	// to be served by bitcoind.
+	BlockHashCacheMisses uint64
+
+	// BlockHashCacheSize is the number of block hashes currently cached.
+	BlockHashCacheSize int
+
+	// Healthy is whether the bitcoind node is currently considered
+	// healthy by its periodic health check.
+	Healthy bool
+
+	// HealthCheckFailures is the number of consecutive failed health
+	// checks of the bitcoind node.
+	HealthCheckFailures int
+}
+
+// RawTxCacheHitRatio returns the ratio of GetRawTransaction calls served from
+// the raw transaction cache, or zero if there haven't been any.
+func (s BitcoindConnStats) RawTxCacheHitRatio() float64 {
+	total := s.RawTxCacheHits + s.RawTxCacheMisses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.RawTxCacheHits) / float64(total)
 }
 
 // BitcoindConn represents a persistent client connection to a bitcoind node
@@ -114,6 +191,20 @@ type BitcoindConn struct {
 	// events.
 	zmqTxConn *gozmq.Conn
 
+	// rawTxCache caches the raw transactions fetched through
+	// GetRawTransaction.
+	rawTxCache *rawTxCache
+
+	// blockHashes caches the block hashes fetched through GetBlockHash.
+	blockHashes *blockHashCache
+
+	// health periodically health checks the bitcoind node.
+	health *healthMonitor
+
+	// timestamps caches the heights resolved by its clients'
+	// BlockHeightForTimestamp.
+	timestamps timestampResolver
+
 	// rescanClients is the set of active bitcoind rescan clients to which
 	// ZMQ event notfications will be sent to.
 	rescanClientsMtx sync.Mutex
@@ -207,15 +298,24 @@ func NewBitcoindConn(cfg *BitcoindConfig) (*BitcoindConn, error) {
 		}
 	}
 
-	return &BitcoindConn{
+	conn := &BitcoindConn{
 		cfg:                   *cfg,
 		client:                client,
 		prunedBlockDispatcher: prunedBlockDispatcher,
 		zmqBlockConn:          zmqBlockConn,
 		zmqTxConn:             zmqTxConn,
-		rescanClients:         make(map[uint64]*BitcoindClient),
-		quit:                  make(chan struct{}),
-	}, nil
+		rawTxCache:            newRawTxCache(cfg.RawTxCacheSize),
+		blockHashes: newBlockHashCache(
+			cfg.BlockHashCacheSize, cfg.BlockHashCacheSafeDepth,
+		),
+		rescanClients: make(map[uint64]*BitcoindClient),
+		quit:          make(chan struct{}),
+	}
+	conn.health = newHealthMonitor(
+		"bitcoind", cfg.HealthCheck, conn.checkHealth, conn.emit,
+	)
+
+	return conn, nil
 }
 
 // Start attempts to establish a RPC and ZMQ connection to a bitcoind node. If
@@ -241,6 +341,8 @@ func (c *BitcoindConn) Start() error {
 	go c.blockEventHandler()
 	go c.txEventHandler()
 
+	c.health.Start()
+
 	return nil
 }
 
@@ -255,6 +357,8 @@ func (c *BitcoindConn) Stop() {
 		client.Stop()
 	}
 
+	c.health.Stop()
+
 	close(c.quit)
 	c.client.Shutdown()
 	c.zmqBlockConn.Close()
@@ -319,8 +423,9 @@ func (c *BitcoindConn) blockEventHandler() {
 			// error to prevent spamming the logs.
 			netErr, ok := err.(net.Error)
 			if ok && netErr.Timeout() {
-				log.Trace("Re-establishing timed out ZMQ " +
-					"block connection")
+				c.emit(&ReconnectEvent{
+					Subscription: rawBlockZMQCommand,
+				})
 				continue
 			}
 
@@ -343,7 +448,15 @@ func (c *BitcoindConn) blockEventHandler() {
 				continue
 			}
 
+			// Any unconfirmed transactions cached may have been
+			// confirmed or conflicted by the new block, which may
+			// also have reorged out the shallow block hashes
+			// cached.
+			c.rawTxCache.blockConnected()
+			c.blockHashes.blockConnected()
+
 			c.rescanClientsMtx.Lock()
+			numClients := len(c.rescanClients)
 			for _, client := range c.rescanClients {
 				select {
 				case client.zmqBlockNtfns <- block:
@@ -354,6 +467,11 @@ func (c *BitcoindConn) blockEventHandler() {
 				}
 			}
 			c.rescanClientsMtx.Unlock()
+
+			c.emit(&BlockDispatchedEvent{
+				Hash:       block.BlockHash(),
+				NumClients: numClients,
+			})
 		default:
 			// It's possible that the message wasn't fully read if
 			// bitcoind shuts down, which will produce an unreadable
@@ -421,8 +539,9 @@ func (c *BitcoindConn) txEventHandler() {
 			// error to prevent spamming the logs.
 			netErr, ok := err.(net.Error)
 			if ok && netErr.Timeout() {
-				log.Trace("Re-establishing timed out ZMQ " +
-					"transaction connection")
+				c.emit(&ReconnectEvent{
+					Subscription: rawTxZMQCommand,
+				})
 				continue
 			}
 
@@ -471,6 +590,17 @@ func (c *BitcoindConn) txEventHandler() {
 	}
 }
 
+// emit hands the event off to the configured EventSink, falling back to the
+// package logger if one wasn't provided.
+func (c *BitcoindConn) emit(event Event) {
+	if c.cfg.EventSink == nil {
+		logEventSink{}.OnEvent(event)
+		return
+	}
+
+	c.cfg.EventSink.OnEvent(event)
+}
+
 // getCurrentNet returns the network on which the bitcoind node is running.
 func getCurrentNet(client *rpcclient.Client) (wire.BitcoinNet, error) {
 	hash, err := client.GetBlockHash(0)
@@ -503,17 +633,24 @@ func (c *BitcoindConn) NewBitcoindClient() *BitcoindClient {
 
 		chainConn: c,
 
-		rescanUpdate:     make(chan interface{}),
-		watchedAddresses: make(map[string]struct{}),
-		watchedOutPoints: make(map[wire.OutPoint]struct{}),
-		watchedTxs:       make(map[chainhash.Hash]struct{}),
+		rescanUpdate:           make(chan interface{}),
+		watchedAddresses:       make(map[string]struct{}),
+		watchedScripts:         make(map[string]struct{}),
+		watchedOutPoints:       make(map[wire.OutPoint]struct{}),
+		watchedOutPointScripts: make(map[wire.OutPoint][]byte),
+		watchedTxs:             make(map[chainhash.Hash]struct{}),
 
 		notificationQueue: NewConcurrentQueue(20),
 		zmqTxNtfns:        make(chan *wire.MsgTx),
 		zmqBlockNtfns:     make(chan *wire.MsgBlock),
 
+		lifecycleRegistrations: make(chan *outputLifecycleWatch),
+		lifecycleWatches:       make(map[uint64]*outputLifecycleWatch),
+
 		mempool:        make(map[chainhash.Hash]struct{}),
 		expiredMempool: make(map[int32]map[chainhash.Hash]struct{}),
+
+		mempoolPollInterval: defaultMempoolPollInterval,
 	}
 }
 
@@ -552,7 +689,73 @@ func isBlockPrunedErr(err error) bool {
 // GetBlock returns a raw block from the server given its hash. If the server
 // has already pruned the block, it will be retrieved from one of its peers.
 func (c *BitcoindConn) GetBlock(hash *chainhash.Hash) (*wire.MsgBlock, error) {
-	block, err := c.client.GetBlock(hash)
+	return c.getBlock(hash, c.client.GetBlock)
+}
+
+// getRawBlock returns a block from the server given its hash like GetBlock,
+// but decodes the serialized block returned by `getblock <hash> 0` as it's
+// streamed out of the response, rather than first unmarshaling it into a hex
+// string and decoding that into a byte slice. It should be preferred when
+// fetching many blocks in a row, such as when catching up after a reorg or
+// rescanning, as it avoids two intermediate copies of each block.
+func (c *BitcoindConn) getRawBlock(hash *chainhash.Hash) (*wire.MsgBlock,
+	error) {
+
+	return c.getBlock(hash, c.fetchRawBlock)
+}
+
+// fetchRawBlock requests the serialized block with the given hash from the
+// server through `getblock <hash> 0`, decoding it from the response.
+func (c *BitcoindConn) fetchRawBlock(hash *chainhash.Hash) (*wire.MsgBlock,
+	error) {
+
+	hashParam, err := json.Marshal(hash.String())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.RawRequest("getblock", []json.RawMessage{
+		hashParam, json.RawMessage("0"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeRawBlock(resp)
+}
+
+// decodeRawBlock decodes the block serialized as a JSON hex string within the
+// response of `getblock <hash> 0`. As hex characters are never escaped, the
+// serialized block is decoded in place from the response.
+func decodeRawBlock(resp json.RawMessage) (*wire.MsgBlock, error) {
+	resp = bytes.TrimSpace(resp)
+	if len(resp) < 2 || resp[0] != '"' || resp[len(resp)-1] != '"' {
+		return nil, errors.New("getblock response is not a hex string")
+	}
+
+	r := hex.NewDecoder(bytes.NewReader(resp[1 : len(resp)-1]))
+	var block wire.MsgBlock
+	if err := block.Deserialize(r); err != nil {
+		return nil, fmt.Errorf("unable to decode block: %v", err)
+	}
+
+	// The response shouldn't contain anything past the block.
+	if n, _ := r.Read(make([]byte, 1)); n != 0 {
+		return nil, errors.New("getblock response has trailing data")
+	}
+
+	return &block, nil
+}
+
+// blockFetcher fetches the block with the given hash from the server.
+type blockFetcher func(*chainhash.Hash) (*wire.MsgBlock, error)
+
+// getBlock returns the block with the given hash fetched from the server with
+// the given function. If the server has already pruned the block, it will be
+// retrieved from one of its peers.
+func (c *BitcoindConn) getBlock(hash *chainhash.Hash,
+	fetch blockFetcher) (*wire.MsgBlock, error) {
+
+	block, err := fetch(hash)
 	// Got the block from the backend successfully, return it.
 	if err == nil {
 		return block, nil
@@ -590,6 +793,109 @@ func (c *BitcoindConn) GetBlock(hash *chainhash.Hash) (*wire.MsgBlock, error) {
 	}
 }
 
+// GetRawTransaction returns the transaction with the given hash, along with
+// the hash of the block it was confirmed in, which is nil if it's unconfirmed.
+// Transactions are cached, with confirmed transactions remaining cached until
+// their block is disconnected or they're evicted from a client's mempool, and
+// unconfirmed ones until the next block is connected or a reorg is processed.
+func (c *BitcoindConn) GetRawTransaction(txHash *chainhash.Hash) (*wire.MsgTx,
+	*chainhash.Hash, error) {
+
+	if tx, blockHash, ok := c.rawTxCache.get(txHash); ok {
+		return tx, blockHash, nil
+	}
+
+	result, err := c.client.GetRawTransactionVerbose(txHash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txBytes, err := hex.DecodeString(result.Hex)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx := &wire.MsgTx{}
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, nil, err
+	}
+
+	var blockHash *chainhash.Hash
+	if result.BlockHash != "" {
+		blockHash, err = chainhash.NewHashFromStr(result.BlockHash)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	c.rawTxCache.add(tx, blockHash)
+
+	return tx, blockHash, nil
+}
+
+// reorged invalidates the cached transactions and block hashes made stale by a
+// chain reorganization processed by one of the rescan clients. The blocks
+// disconnected by it are invalidated as they're disconnected, while the cached
+// unconfirmed transactions may have been confirmed or conflicted by any of the
+// blocks of the new chain.
+func (c *BitcoindConn) reorged() {
+	c.rawTxCache.blockConnected()
+	c.blockHashes.blockConnected()
+}
+
+// mempoolEvicted invalidates the cached transactions evicted from the mempool
+// of one of the rescan clients.
+func (c *BitcoindConn) mempoolEvicted(txHashes map[chainhash.Hash]struct{}) {
+	c.rawTxCache.remove(txHashes)
+}
+
+// GetBlockHash returns the hash of the main chain block at the given height.
+// Hashes are cached, with those deep enough to be safe from reorgs remaining
+// cached until evicted, and shallower ones until the next block is connected.
+func (c *BitcoindConn) GetBlockHash(height int32) (*chainhash.Hash, error) {
+	if hash, ok := c.blockHashes.get(height); ok {
+		return hash, nil
+	}
+
+	hash, err := c.client.GetBlockHash(int64(height))
+	if err != nil {
+		return nil, err
+	}
+	c.blockHashes.add(height, hash)
+
+	return hash, nil
+}
+
+// Stats returns the current state of the connection.
+func (c *BitcoindConn) Stats() BitcoindConnStats {
+	hits, misses, size := c.rawTxCache.stats()
+	hashHits, hashMisses, hashSize := c.blockHashes.stats()
+	healthy, failures, _ := c.health.status()
+	return BitcoindConnStats{
+		RawTxCacheHits:       hits,
+		RawTxCacheMisses:     misses,
+		RawTxCacheSize:       size,
+		BlockHashCacheHits:   hashHits,
+		BlockHashCacheMisses: hashMisses,
+		BlockHashCacheSize:   hashSize,
+		Healthy:              healthy,
+		HealthCheckFailures:  failures,
+	}
+}
+
+// IsHealthy returns whether the bitcoind node is currently considered healthy
+// by its periodic health check. If it isn't, the error of the latest failed
+// health check is returned as well.
+func (c *BitcoindConn) IsHealthy() (bool, error) {
+	return c.health.isHealthy()
+}
+
+// checkHealth checks whether the bitcoind node is serving data by querying its
+// block count.
+func (c *BitcoindConn) checkHealth(_ context.Context) error {
+	_, err := c.client.GetBlockCount()
+	return err
+}
+
 // isASCII is a helper method that checks whether all bytes in `data` would be
 // printable ASCII characters if interpreted as a string.
 func isASCII(s string) bool {
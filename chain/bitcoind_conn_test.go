@@ -0,0 +1,231 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// newRawBlockTestBlock creates a block with the given number of transactions,
+// each spending a few inputs to a few outputs.
+func newRawBlockTestBlock(height, numTxs int) *wire.MsgBlock {
+	prevHash := chainhash.Hash{byte(height), byte(height >> 8)}
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   0x20000000,
+			PrevBlock: prevHash,
+			Timestamp: time.Unix(1600000000+int64(height)*600, 0),
+			Bits:      0x1d00ffff,
+			Nonce:     uint32(height),
+		},
+	}
+	for i := 0; i < numTxs; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		for j := 0; j < 2; j++ {
+			prevOut := wire.OutPoint{
+				Hash:  chainhash.Hash{byte(height), byte(i)},
+				Index: uint32(j),
+			}
+			tx.AddTxIn(wire.NewTxIn(
+				&prevOut, bytes.Repeat([]byte{0x01}, 72), nil,
+			))
+		}
+		for j := 0; j < 2; j++ {
+			pkScript := bytes.Repeat([]byte{0x02}, 22)
+			tx.AddTxOut(wire.NewTxOut(int64(1000*(j+1)), pkScript))
+		}
+		block.Transactions = append(block.Transactions, tx)
+	}
+
+	return block
+}
+
+// rawBlockResponse returns the response of `getblock <hash> 0` for the block.
+func rawBlockResponse(t testing.TB, block *wire.MsgBlock) json.RawMessage {
+	var b bytes.Buffer
+	require.NoError(t, block.Serialize(&b))
+	resp, err := json.Marshal(hex.EncodeToString(b.Bytes()))
+	require.NoError(t, err)
+	return resp
+}
+
+// verboseBlockResponse returns the response of `getblock <hash> 2` for the
+// block.
+func verboseBlockResponse(t testing.TB, block *wire.MsgBlock) json.RawMessage {
+	header := &block.Header
+	result := btcjson.GetBlockVerboseResult{
+		Hash:         block.BlockHash().String(),
+		Version:      header.Version,
+		VersionHex:   fmt.Sprintf("%08x", header.Version),
+		MerkleRoot:   header.MerkleRoot.String(),
+		Time:         header.Timestamp.Unix(),
+		Nonce:        header.Nonce,
+		Bits:         strconv.FormatInt(int64(header.Bits), 16),
+		PreviousHash: header.PrevBlock.String(),
+	}
+	for _, tx := range block.Transactions {
+		var b bytes.Buffer
+		require.NoError(t, tx.Serialize(&b))
+		rawTx := btcjson.TxRawResult{
+			Hex:      hex.EncodeToString(b.Bytes()),
+			Txid:     tx.TxHash().String(),
+			Hash:     tx.WitnessHash().String(),
+			Size:     int32(tx.SerializeSize()),
+			Version:  uint32(tx.Version),
+			LockTime: tx.LockTime,
+		}
+		for _, txIn := range tx.TxIn {
+			sigScript := hex.EncodeToString(txIn.SignatureScript)
+			rawTx.Vin = append(rawTx.Vin, btcjson.Vin{
+				Txid:      txIn.PreviousOutPoint.Hash.String(),
+				Vout:      txIn.PreviousOutPoint.Index,
+				ScriptSig: &btcjson.ScriptSig{Hex: sigScript},
+				Sequence:  txIn.Sequence,
+			})
+		}
+		for i, txOut := range tx.TxOut {
+			pkScript := hex.EncodeToString(txOut.PkScript)
+			rawTx.Vout = append(rawTx.Vout, btcjson.Vout{
+				Value: float64(txOut.Value) / 1e8,
+				N:     uint32(i),
+				ScriptPubKey: btcjson.ScriptPubKeyResult{
+					Hex:  pkScript,
+					Type: "nonstandard",
+				},
+			})
+		}
+		result.RawTx = append(result.RawTx, rawTx)
+	}
+
+	resp, err := json.Marshal(result)
+	require.NoError(t, err)
+	return resp
+}
+
+// decodeVerboseBlock reconstructs a block from the response of
+// `getblock <hash> 2`.
+func decodeVerboseBlock(resp json.RawMessage) (*wire.MsgBlock, error) {
+	var result btcjson.GetBlockVerboseResult
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, err
+	}
+
+	prevHash, err := chainhash.NewHashFromStr(result.PreviousHash)
+	if err != nil {
+		return nil, err
+	}
+	merkleRoot, err := chainhash.NewHashFromStr(result.MerkleRoot)
+	if err != nil {
+		return nil, err
+	}
+	bits, err := strconv.ParseUint(result.Bits, 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    result.Version,
+			PrevBlock:  *prevHash,
+			MerkleRoot: *merkleRoot,
+			Timestamp:  time.Unix(result.Time, 0),
+			Bits:       uint32(bits),
+			Nonce:      result.Nonce,
+		},
+	}
+	for _, rawTx := range result.RawTx {
+		serializedTx, err := hex.DecodeString(rawTx.Hex)
+		if err != nil {
+			return nil, err
+		}
+		var tx wire.MsgTx
+		err = tx.Deserialize(bytes.NewReader(serializedTx))
+		if err != nil {
+			return nil, err
+		}
+		block.Transactions = append(block.Transactions, &tx)
+	}
+
+	return block, nil
+}
+
+// TestDecodeRawBlock ensures that blocks are decoded from the response of
+// `getblock <hash> 0`, and that malformed responses are rejected.
+func TestDecodeRawBlock(t *testing.T) {
+	t.Parallel()
+
+	block := newRawBlockTestBlock(1, 10)
+	resp := rawBlockResponse(t, block)
+
+	decoded, err := decodeRawBlock(resp)
+	require.NoError(t, err)
+	require.Equal(t, block.BlockHash(), decoded.BlockHash())
+	require.Len(t, decoded.Transactions, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		require.Equal(t, tx.TxHash(), decoded.Transactions[i].TxHash())
+	}
+
+	// The verbose decoding used by the benchmark should yield the same
+	// block.
+	decoded, err = decodeVerboseBlock(verboseBlockResponse(t, block))
+	require.NoError(t, err)
+	require.Equal(t, block, decoded)
+
+	malformed := []json.RawMessage{
+		json.RawMessage(`null`),
+		json.RawMessage(`{"hash":"00"}`),
+		resp[:len(resp)/2],
+		append(resp[:len(resp)-1:len(resp)-1], '0', '0', '"'),
+		json.RawMessage(`"zz"`),
+	}
+	for _, resp := range malformed {
+		_, err := decodeRawBlock(resp)
+		require.Error(t, err, "response %s", resp)
+	}
+}
+
+// BenchmarkDecodeBlock compares decoding 100 blocks from verbose JSON
+// responses against decoding them from raw hex responses.
+func BenchmarkDecodeBlock(b *testing.B) {
+	const numBlocks = 100
+
+	var verboseResps, rawResps []json.RawMessage
+	for i := 0; i < numBlocks; i++ {
+		block := newRawBlockTestBlock(i, 500)
+		verboseResps = append(
+			verboseResps, verboseBlockResponse(b, block),
+		)
+		rawResps = append(rawResps, rawBlockResponse(b, block))
+	}
+
+	benchmarks := []struct {
+		name   string
+		resps  []json.RawMessage
+		decode func(json.RawMessage) (*wire.MsgBlock, error)
+	}{
+		{"verbose json", verboseResps, decodeVerboseBlock},
+		{"raw hex", rawResps, decodeRawBlock},
+	}
+	for _, bm := range benchmarks {
+		bm := bm
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, resp := range bm.resps {
+					_, err := bm.decode(resp)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
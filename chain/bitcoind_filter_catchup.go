@@ -0,0 +1,148 @@
+package chain
+
+import (
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/gcs"
+	"github.com/btcsuite/btcutil/gcs/builder"
+)
+
+// SetBlockFilterCatchUp sets whether the client matches the BIP158 filters of
+// the blocks it catches up with, after its birthday, against its watch list
+// before fetching them. The blocks whose filter doesn't match are connected
+// from their header alone, with only the blocks whose filter matches being
+// fetched in full, which speeds up catching up after downtime. This requires
+// the bitcoind node to run with -blockfilterindex; otherwise, every block is
+// fetched in full as before.
+//
+// The filters can only be matched as long as the outputs of every outpoint
+// watched are known, and no transaction is watched by its hash, with the
+// client fetching every block in full otherwise.
+//
+// NOTE: This must be called before the client is started.
+func (c *BitcoindClient) SetBlockFilterCatchUp(enabled bool) {
+	c.blockFilterCatchUp = enabled
+}
+
+// blockFilterQuery returns the output scripts the BIP158 filters of blocks are
+// matched against to determine whether they're relevant to the client's watch
+// list, along with the version of the watch list they were derived from, or
+// false if the relevance of blocks can't be determined from their filter.
+func (c *BitcoindClient) blockFilterQuery() ([][]byte, uint64, bool) {
+	c.watchMtx.RLock()
+	defer c.watchMtx.RUnlock()
+
+	version := c.watchListVersion
+
+	// Transactions watched by their hash can't be matched against a
+	// filter, and neither can the outpoints whose output is unknown.
+	if len(c.watchedTxs) > 0 {
+		return nil, version, false
+	}
+	for op := range c.watchedOutPoints {
+		if _, ok := c.watchedOutPointScripts[op]; !ok {
+			return nil, version, false
+		}
+	}
+
+	params := c.chainConn.cfg.ChainParams
+	scripts := make(map[string]struct{}, len(c.watchedAddresses)+
+		len(c.watchedScripts)+len(c.watchedOutPointScripts))
+	for encoded := range c.watchedAddresses {
+		addr, err := btcutil.DecodeAddress(encoded, params)
+		if err != nil {
+			return nil, version, false
+		}
+		script, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, version, false
+		}
+		scripts[string(script)] = struct{}{}
+	}
+	for script := range c.watchedScripts {
+		scripts[script] = struct{}{}
+	}
+	for _, script := range c.watchedOutPointScripts {
+		scripts[string(script)] = struct{}{}
+	}
+
+	query := make([][]byte, 0, len(scripts))
+	for script := range scripts {
+		query = append(query, []byte(script))
+	}
+	return query, version, true
+}
+
+// currentWatchListVersion returns the version of the client's watch list.
+func (c *BitcoindClient) currentWatchListVersion() uint64 {
+	c.watchMtx.RLock()
+	defer c.watchMtx.RUnlock()
+
+	return c.watchListVersion
+}
+
+// matchBlockFilter returns whether the BIP158 filter of the block with the
+// given hash likely matches any of the scripts of the query.
+func (c *BitcoindClient) matchBlockFilter(hash *chainhash.Hash,
+	query [][]byte) (bool, error) {
+
+	filterType := btcjson.FilterTypeBasic
+	result, err := c.chainConn.client.GetBlockFilter(*hash, &filterType)
+	if err != nil {
+		return false, err
+	}
+	rawFilter, err := hex.DecodeString(result.Filter)
+	if err != nil {
+		return false, err
+	}
+
+	// Empty filters can't match any script.
+	if len(rawFilter) < 4 {
+		return false, nil
+	}
+	filter, err := gcs.FromNBytes(
+		builder.DefaultP, builder.DefaultM, rawFilter,
+	)
+	if err != nil {
+		return false, err
+	}
+	if filter.N() == 0 {
+		return false, nil
+	}
+
+	return filter.MatchAny(builder.DeriveKey(hash), query)
+}
+
+// watchOutPointScript records the output script paying to the address as the
+// one of the watched outpoint, if it can be determined.
+//
+// NOTE: This requires the watchMtx to be held for writes.
+func (c *BitcoindClient) watchOutPointScript(op wire.OutPoint,
+	addr btcutil.Address) {
+
+	if addr == nil {
+		return
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return
+	}
+	c.watchedOutPointScripts[op] = script
+}
+
+// unwatchSpentOutPoints stops watching the outpoints spent by the confirmed
+// transaction, along with their output scripts, such that the filters of the
+// blocks that follow are no longer matched against them.
+//
+// NOTE: This requires the watchMtx to be held for writes.
+func (c *BitcoindClient) unwatchSpentOutPoints(tx *wire.MsgTx) {
+	for _, txIn := range tx.TxIn {
+		delete(c.watchedOutPoints, txIn.PreviousOutPoint)
+		delete(c.watchedOutPointScripts, txIn.PreviousOutPoint)
+	}
+}
@@ -0,0 +1,260 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/gcs/builder"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBlockFilterCatchUp ensures that a client catching up with the blocks
+// whose BIP158 filter is matched against its watch list only fetches the
+// blocks whose filter matches in full, connecting the others from their header
+// alone, while still finding the relevant transactions within the former.
+func TestBlockFilterCatchUp(t *testing.T) {
+	t.Parallel()
+
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), &chaincfg.RegressionNetParams,
+	)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	newTx := func(prevOut wire.OutPoint, pkScript []byte) *wire.MsgTx {
+		tx := wire.NewMsgTx(2)
+		tx.AddTxIn(&wire.TxIn{PreviousOutPoint: prevOut})
+		tx.AddTxOut(wire.NewTxOut(1000, pkScript))
+		return tx
+	}
+	unrelatedTx := func(i byte) *wire.MsgTx {
+		prevOut := wire.OutPoint{Hash: chainhash.Hash{i}}
+		return newTx(prevOut, []byte{0x51})
+	}
+
+	// The node's chain pays to the watched address at height 2 and spends
+	// the output paying to it at height 4, with the other blocks only
+	// containing unrelated transactions.
+	paymentTx := newTx(wire.OutPoint{Hash: chainhash.Hash{0xff}}, pkScript)
+	spendTx := newTx(wire.OutPoint{Hash: paymentTx.TxHash()}, []byte{0x51})
+	prevOutScripts := map[chainhash.Hash][][]byte{}
+
+	node := &fakeChainNode{}
+	genesis := node.addBlock()
+	node.addBlock(unrelatedTx(1))
+	paymentBlock := node.addBlock(unrelatedTx(2), paymentTx)
+	node.addBlock(unrelatedTx(3))
+	spendBlock := node.addBlock(spendTx)
+	node.addBlock(unrelatedTx(4))
+	prevOutScripts[spendBlock.BlockHash()] = [][]byte{pkScript}
+
+	// newClient returns a client backed by the node, whose filters are only
+	// served if requested, along with the hashes of the blocks it fetched
+	// in full. The hook, if set, is called whenever the hash of a block is
+	// requested while rescanning.
+	var onGetBlockHash func(client *BitcoindClient, height int64)
+	newClient := func(serveFilters bool) (*BitcoindClient,
+		func() []chainhash.Hash) {
+
+		var client *BitcoindClient
+
+		var (
+			fetchedMtx sync.Mutex
+			fetched    []chainhash.Hash
+		)
+		handler := func(method string,
+			params []json.RawMessage) (interface{},
+			*btcjson.RPCError) {
+
+			var hash string
+			if len(params) > 0 {
+				_ = json.Unmarshal(params[0], &hash)
+			}
+
+			switch {
+			case method == "getblockhash" && onGetBlockHash != nil:
+				var height int64
+				_ = json.Unmarshal(params[0], &height)
+				onGetBlockHash(client, height)
+
+			case method == "getblock":
+				blockHash, _ := chainhash.NewHashFromStr(hash)
+				fetchedMtx.Lock()
+				fetched = append(fetched, *blockHash)
+				fetchedMtx.Unlock()
+
+			case method == "getblockheader" && len(params) > 1 &&
+				string(params[1]) == "false":
+
+				node.mtx.Lock()
+				block, _ := node.block(hash)
+				node.mtx.Unlock()
+
+				var buf bytes.Buffer
+				_ = block.Header.Serialize(&buf)
+				return hex.EncodeToString(buf.Bytes()), nil
+
+			case method == "getblockfilter" && serveFilters:
+				node.mtx.Lock()
+				block, _ := node.block(hash)
+				node.mtx.Unlock()
+
+				spent := prevOutScripts[block.BlockHash()]
+				filter, err := builder.BuildBasicFilter(
+					block, spent,
+				)
+				require.NoError(t, err)
+				rawFilter, err := filter.NBytes()
+				require.NoError(t, err)
+				return &btcjson.GetBlockFilterResult{
+					Filter: hex.EncodeToString(rawFilter),
+				}, nil
+			}
+
+			return node.handle(method, params)
+		}
+
+		conn := &BitcoindConn{
+			cfg: BitcoindConfig{
+				ChainParams: &chaincfg.RegressionNetParams,
+			},
+			client:        newTestRPCClient(t, handler),
+			rawTxCache:    newRawTxCache(0),
+			blockHashes:   newBlockHashCache(0, 0),
+			rescanClients: make(map[uint64]*BitcoindClient),
+		}
+		client = conn.NewBitcoindClient()
+		client.SetBlockFilterCatchUp(true)
+		atomic.StoreUint32(&client.notifyBlocks, 1)
+		client.notificationQueue.Start()
+		t.Cleanup(client.notificationQueue.Stop)
+
+		client.watchMtx.Lock()
+		client.watchedAddresses[addr.String()] = struct{}{}
+		client.watchMtx.Unlock()
+
+		return client, func() []chainhash.Hash {
+			fetchedMtx.Lock()
+			defer fetchedMtx.Unlock()
+			return fetched
+		}
+	}
+
+	// catchUp catches up the client from the genesis block, returning the
+	// relevant transactions notified within the connected blocks.
+	catchUp := func(client *BitcoindClient) []chainhash.Hash {
+		t.Helper()
+
+		require.NoError(t, client.rescan(genesis.BlockHash()))
+
+		var (
+			relevant  []chainhash.Hash
+			connected int32
+		)
+		for connected < 5 {
+			switch ntfn := (<-client.Notifications()).(type) {
+			case FilteredBlockConnected:
+				for _, rec := range ntfn.RelevantTxs {
+					relevant = append(relevant, rec.Hash)
+				}
+
+			case BlockConnected:
+				connected++
+				require.Equal(t, connected, ntfn.Height)
+			}
+		}
+		return relevant
+	}
+
+	// Only the blocks with the payment and the spend are fetched in full,
+	// with every block being connected nonetheless.
+	client, fetched := newClient(true)
+	relevant := catchUp(client)
+	require.Equal(
+		t, []chainhash.Hash{paymentTx.TxHash(), spendTx.TxHash()},
+		relevant,
+	)
+	require.Equal(
+		t, []chainhash.Hash{
+			paymentBlock.BlockHash(), spendBlock.BlockHash(),
+		}, fetched(),
+	)
+
+	// The spent output is no longer watched, nor matched against the
+	// filters of the blocks that follow.
+	client.watchMtx.RLock()
+	require.Empty(t, client.watchedOutPoints)
+	require.Empty(t, client.watchedOutPointScripts)
+	client.watchMtx.RUnlock()
+
+	// Without filters served by the node, every block is fetched in full.
+	client, fetched = newClient(false)
+	relevant = catchUp(client)
+	require.Equal(
+		t, []chainhash.Hash{paymentTx.TxHash(), spendTx.TxHash()},
+		relevant,
+	)
+	require.Len(t, fetched(), 5)
+
+	// The same goes for a client watching a transaction by its hash, as
+	// it can't be matched against the filters.
+	client, fetched = newClient(true)
+	client.watchMtx.Lock()
+	client.watchedTxs[chainhash.Hash{0x01}] = struct{}{}
+	client.watchMtx.Unlock()
+	catchUp(client)
+	require.Len(t, fetched(), 5)
+
+	// A client only watching an unrelated address catches up from the
+	// headers alone, unless the watched address is added while it's
+	// rescanning, in which case the blocks that follow are matched against
+	// it.
+	otherAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		bytes.Repeat([]byte{0x01}, 20), &chaincfg.RegressionNetParams,
+	)
+	require.NoError(t, err)
+
+	client, fetched = newClient(true)
+	client.watchMtx.Lock()
+	client.watchedAddresses = map[string]struct{}{
+		otherAddr.String(): {},
+	}
+	client.watchMtx.Unlock()
+	require.Empty(t, catchUp(client))
+	require.Empty(t, fetched())
+
+	client, fetched = newClient(true)
+	client.watchMtx.Lock()
+	client.watchedAddresses = map[string]struct{}{
+		otherAddr.String(): {},
+	}
+	client.watchMtx.Unlock()
+	client.rescanUpdate = make(chan interface{}, 1)
+	onGetBlockHash = func(client *BitcoindClient, height int64) {
+		if height == 1 {
+			client.rescanUpdate <- []btcutil.Address{addr}
+		}
+	}
+	relevant = catchUp(client)
+	onGetBlockHash = nil
+	require.Equal(
+		t, []chainhash.Hash{paymentTx.TxHash(), spendTx.TxHash()},
+		relevant,
+	)
+	require.Equal(
+		t, []chainhash.Hash{
+			paymentBlock.BlockHash(), spendBlock.BlockHash(),
+		}, fetched(),
+	)
+}
@@ -0,0 +1,325 @@
+package chain
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// OutputLifecycleEventType is the type of an event in the lifecycle of a
+// watched output.
+type OutputLifecycleEventType uint8
+
+const (
+	// OutputCreated indicates that a transaction creating the watched
+	// output confirmed.
+	OutputCreated OutputLifecycleEventType = iota
+
+	// OutputSpent indicates that a transaction spending the watched output
+	// confirmed.
+	OutputSpent
+)
+
+// String returns a human-readable name of the event type.
+func (t OutputLifecycleEventType) String() string {
+	switch t {
+	case OutputCreated:
+		return "created"
+	case OutputSpent:
+		return "spent"
+	default:
+		return fmt.Sprintf("unknown event type %d", uint8(t))
+	}
+}
+
+// OutputLifecycleEvent is an event in the lifecycle of an output watched
+// through WatchOutputLifecycle.
+type OutputLifecycleEvent struct {
+	// Type is the type of the event.
+	Type OutputLifecycleEventType
+
+	// OutPoint is the watched output.
+	OutPoint wire.OutPoint
+
+	// Tx is the transaction creating the output for OutputCreated events,
+	// or the transaction spending it for OutputSpent events.
+	Tx *wire.MsgTx
+
+	// BlockHash is the hash of the block Tx confirmed in.
+	BlockHash chainhash.Hash
+
+	// Height is the height of the block Tx confirmed in. The height of the
+	// OutputCreated event is the height hint to resume the watch from
+	// after a restart.
+	Height int32
+}
+
+// OutputLifecycleWatch is a watch for the creation and spend of an output,
+// created through WatchOutputLifecycle.
+type OutputLifecycleWatch struct {
+	// Events delivers the OutputCreated event of the watched output
+	// followed by its OutputSpent event. It's closed once the spend has
+	// been delivered, or the watch is cancelled.
+	Events <-chan *OutputLifecycleEvent
+
+	// Cancel stops the watch.
+	Cancel func()
+}
+
+// outputLifecycleWatch tracks an output watched through WatchOutputLifecycle.
+type outputLifecycleWatch struct {
+	id       uint64
+	pkScript []byte
+
+	// nextHeight is the height of the next block to scan before the watch
+	// can be registered with the notification handler.
+	nextHeight int32
+
+	// registered is sent whether the watch was registered by the
+	// notification handler, which is only the case once the blocks up to
+	// the best height have been scanned.
+	registered chan bool
+
+	mtx sync.Mutex
+
+	// outPoint is the watched output, which is only known once a
+	// transaction paying to the watched script has been found.
+	outPoint *wire.OutPoint
+
+	// events is buffered for both events of the lifecycle, such that they
+	// never block the client.
+	events chan *OutputLifecycleEvent
+
+	done bool
+}
+
+// WatchOutputLifecycle watches for the first output paying to the given script
+// confirmed at or above startHeight, notifying both its creation and its later
+// spend, along with the spending transaction, through the returned watch. The
+// blocks from startHeight up to the current tip are scanned before returning,
+// such that events which occurred while the caller was offline are delivered
+// as well.
+//
+// The start height thus acts as a height hint: to resume a watch across
+// restarts, callers should persist the height of its OutputCreated event, or
+// the original start height if none was received yet, and pass it as
+// startHeight, which delivers the events since then again. Events are only
+// delivered for confirmed transactions, and aren't retracted if their blocks
+// are reorged out of the chain.
+//
+// NOTE: As watches are matched against the blocks connected by the
+// notification handler, this enables block notifications as NotifyBlocks
+// does if they weren't already, such that the caller will receive
+// BlockConnected and BlockDisconnected notifications from then on.
+func (c *BitcoindClient) WatchOutputLifecycle(pkScript []byte,
+	startHeight int32) (*OutputLifecycleWatch, error) {
+
+	// The watch is matched against the blocks connected by the
+	// notification handler, so we'll make sure it's running. This also
+	// enables the block notifications of the caller, as documented above.
+	if err := c.NotifyBlocks(); err != nil {
+		return nil, err
+	}
+
+	c.lifecycleMtx.Lock()
+	c.lifecycleWatchCounter++
+	id := c.lifecycleWatchCounter
+	c.lifecycleMtx.Unlock()
+
+	watch := &outputLifecycleWatch{
+		id:         id,
+		pkScript:   pkScript,
+		nextHeight: startHeight,
+		registered: make(chan bool, 1),
+		events:     make(chan *OutputLifecycleEvent, 2),
+	}
+
+	// The blocks up to the best height are scanned here rather than by
+	// the notification handler, such that the scan doesn't hold up the
+	// notifications of other blocks. The handler only registers the watch
+	// once no block was connected since, so we'll scan again otherwise.
+	for {
+		c.bestBlockMtx.RLock()
+		bestHeight := c.bestBlock.Height
+		c.bestBlockMtx.RUnlock()
+
+		if err := c.scanOutputLifecycle(watch, bestHeight); err != nil {
+			return nil, err
+		}
+
+		select {
+		case c.lifecycleRegistrations <- watch:
+		case <-c.quit:
+			return nil, ErrBitcoindClientShuttingDown
+		}
+
+		var registered bool
+		select {
+		case registered = <-watch.registered:
+		case <-c.quit:
+			return nil, ErrBitcoindClientShuttingDown
+		}
+		if registered {
+			break
+		}
+	}
+
+	return &OutputLifecycleWatch{
+		Events: watch.events,
+		Cancel: func() {
+			c.lifecycleMtx.Lock()
+			delete(c.lifecycleWatches, watch.id)
+			c.lifecycleMtx.Unlock()
+
+			watch.finish()
+		},
+	}, nil
+}
+
+// scanOutputLifecycle scans the blocks from the watch's next height up to the
+// given height for the events of the watched output.
+func (c *BitcoindClient) scanOutputLifecycle(watch *outputLifecycleWatch,
+	height int32) error {
+
+	for ; watch.nextHeight <= height; watch.nextHeight++ {
+		if watch.finished() {
+			return nil
+		}
+
+		hash, err := c.GetBlockHash(int64(watch.nextHeight))
+		if err != nil {
+			return err
+		}
+		block, err := c.chainConn.getRawBlock(hash)
+		if err != nil {
+			return err
+		}
+		watch.matchBlock(block, watch.nextHeight)
+	}
+
+	return nil
+}
+
+// registerOutputLifecycle registers the watch to be matched against every
+// block connected, returning whether it was registered. It isn't if blocks
+// were connected since its scan, which must then resume from its next height.
+// A finished watch is considered registered, as its events have all been
+// delivered.
+//
+// NOTE: This must be called from the notification handler, such that no block
+// is connected before the watch is registered.
+func (c *BitcoindClient) registerOutputLifecycle(
+	watch *outputLifecycleWatch) bool {
+
+	c.bestBlockMtx.RLock()
+	bestHeight := c.bestBlock.Height
+	c.bestBlockMtx.RUnlock()
+
+	if watch.finished() {
+		return true
+	}
+	if watch.nextHeight <= bestHeight {
+		return false
+	}
+
+	c.lifecycleMtx.Lock()
+	c.lifecycleWatches[watch.id] = watch
+	c.lifecycleMtx.Unlock()
+
+	return true
+}
+
+// matchOutputLifecycles matches the connected block against the lifecycle
+// watches, removing those whose output has been spent.
+func (c *BitcoindClient) matchOutputLifecycles(block *wire.MsgBlock,
+	height int32) {
+
+	c.lifecycleMtx.Lock()
+	defer c.lifecycleMtx.Unlock()
+
+	for id, watch := range c.lifecycleWatches {
+		watch.matchBlock(block, height)
+		if watch.finished() {
+			delete(c.lifecycleWatches, id)
+		}
+	}
+}
+
+// matchBlock delivers the events of the watched output found within the
+// block.
+func (w *outputLifecycleWatch) matchBlock(block *wire.MsgBlock, height int32) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.done {
+		return
+	}
+
+	blockHash := block.BlockHash()
+	for _, tx := range block.Transactions {
+		// A transaction can't spend its own outputs, so the inputs
+		// only need to be checked once the output has been created by
+		// a previous transaction.
+		if w.outPoint != nil {
+			for _, txIn := range tx.TxIn {
+				if txIn.PreviousOutPoint != *w.outPoint {
+					continue
+				}
+
+				w.events <- &OutputLifecycleEvent{
+					Type:      OutputSpent,
+					OutPoint:  *w.outPoint,
+					Tx:        tx,
+					BlockHash: blockHash,
+					Height:    height,
+				}
+				w.done = true
+				close(w.events)
+				return
+			}
+			continue
+		}
+
+		for i, txOut := range tx.TxOut {
+			if !bytes.Equal(txOut.PkScript, w.pkScript) {
+				continue
+			}
+
+			w.outPoint = &wire.OutPoint{
+				Hash:  tx.TxHash(),
+				Index: uint32(i),
+			}
+			w.events <- &OutputLifecycleEvent{
+				Type:      OutputCreated,
+				OutPoint:  *w.outPoint,
+				Tx:        tx,
+				BlockHash: blockHash,
+				Height:    height,
+			}
+			break
+		}
+	}
+}
+
+// finished returns whether the watch's output has been spent, or the watch
+// cancelled.
+func (w *outputLifecycleWatch) finished() bool {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return w.done
+}
+
+// finish stops delivering the events of the watch.
+func (w *outputLifecycleWatch) finish() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if !w.done {
+		w.done = true
+		close(w.events)
+	}
+}
@@ -0,0 +1,232 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChainNode is a fake bitcoind node serving the blocks of its main chain.
+type fakeChainNode struct {
+	mtx    sync.Mutex
+	blocks []*wire.MsgBlock
+}
+
+// addBlock extends the chain with a block containing the given transactions,
+// returning it.
+func (n *fakeChainNode) addBlock(txs ...*wire.MsgTx) *wire.MsgBlock {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	var prevBlock chainhash.Hash
+	if len(n.blocks) > 0 {
+		prevBlock = n.blocks[len(n.blocks)-1].BlockHash()
+	}
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			PrevBlock: prevBlock,
+			Timestamp: time.Unix(int64(1e9+len(n.blocks)), 0),
+		},
+		Transactions: txs,
+	}
+	n.blocks = append(n.blocks, block)
+
+	return block
+}
+
+// block returns the block with the given hash along with its height.
+func (n *fakeChainNode) block(hash string) (*wire.MsgBlock, int) {
+	for height, block := range n.blocks {
+		if block.BlockHash().String() == hash {
+			return block, height
+		}
+	}
+	return nil, 0
+}
+
+func (n *fakeChainNode) handle(method string,
+	params []json.RawMessage) (interface{}, *btcjson.RPCError) {
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	notFound := &btcjson.RPCError{
+		Code:    btcjson.ErrRPCBlockNotFound,
+		Message: "Block not found",
+	}
+
+	switch method {
+	case "getinfo":
+		return map[string]interface{}{"version": 1}, nil
+
+	case "getblockchaininfo":
+		tip := n.blocks[len(n.blocks)-1]
+		return map[string]interface{}{
+			"blocks":         len(n.blocks) - 1,
+			"bestblockhash":  tip.BlockHash().String(),
+			"softforks":      []interface{}{},
+			"bip9_softforks": map[string]interface{}{},
+		}, nil
+
+	case "getblockhash":
+		var height int
+		_ = json.Unmarshal(params[0], &height)
+		if height < 0 || height >= len(n.blocks) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCOutOfRange,
+				Message: "Block height out of range",
+			}
+		}
+		return n.blocks[height].BlockHash().String(), nil
+
+	case "getblockheader":
+		var hash string
+		_ = json.Unmarshal(params[0], &hash)
+		block, height := n.block(hash)
+		if block == nil {
+			return nil, notFound
+		}
+		return &btcjson.GetBlockHeaderVerboseResult{
+			Hash:   hash,
+			Height: int32(height),
+			Time:   block.Header.Timestamp.Unix(),
+		}, nil
+
+	case "getblock":
+		var hash string
+		_ = json.Unmarshal(params[0], &hash)
+		block, _ := n.block(hash)
+		if block == nil {
+			return nil, notFound
+		}
+		var buf bytes.Buffer
+		_ = block.Serialize(&buf)
+		return hex.EncodeToString(buf.Bytes()), nil
+
+	default:
+		return nil, btcjson.ErrRPCMethodNotFound
+	}
+}
+
+// newLifecycleTestClient creates a BitcoindClient backed by the given fake
+// node.
+func newLifecycleTestClient(t *testing.T,
+	node *fakeChainNode) *BitcoindClient {
+
+	t.Helper()
+
+	conn := &BitcoindConn{
+		cfg: BitcoindConfig{
+			ChainParams: &chaincfg.RegressionNetParams,
+		},
+		client:        newTestRPCClient(t, node.handle),
+		rawTxCache:    newRawTxCache(0),
+		blockHashes:   newBlockHashCache(0, 0),
+		rescanClients: make(map[uint64]*BitcoindClient),
+	}
+	client := conn.NewBitcoindClient()
+	client.notificationQueue.Start()
+	t.Cleanup(func() {
+		close(client.quit)
+		client.WaitForShutdown()
+		client.notificationQueue.Stop()
+	})
+
+	return client
+}
+
+// TestWatchOutputLifecycle ensures that watching the lifecycle of an output
+// notifies its creation followed by its spend, including those which
+// occurred before the watch when resumed from a height hint.
+func TestWatchOutputLifecycle(t *testing.T) {
+	t.Parallel()
+
+	pkScript := []byte{0x00, 0x14, 0x01, 0x02, 0x03}
+
+	// The node's chain starts with a block paying to the script.
+	node := &fakeChainNode{}
+	node.addBlock()
+	fundingTx := wire.NewMsgTx(2)
+	fundingTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{0x01}},
+	})
+	fundingTx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	fundingTx.AddTxOut(wire.NewTxOut(100000, pkScript))
+	fundingBlock := node.addBlock(fundingTx)
+	node.addBlock()
+
+	outPoint := wire.OutPoint{Hash: fundingTx.TxHash(), Index: 1}
+
+	// nextEvent returns the next event of the watch, ensuring it matches
+	// the given transaction confirmed in the given block.
+	nextEvent := func(watch *OutputLifecycleWatch,
+		eventType OutputLifecycleEventType, tx *wire.MsgTx,
+		block *wire.MsgBlock, height int32) *OutputLifecycleEvent {
+
+		t.Helper()
+
+		select {
+		case event, ok := <-watch.Events:
+			require.True(t, ok)
+			require.Equal(t, eventType, event.Type)
+			require.Equal(t, outPoint, event.OutPoint)
+			require.Equal(t, tx.TxHash(), event.Tx.TxHash())
+			require.Equal(t, block.BlockHash(), event.BlockHash)
+			require.Equal(t, height, event.Height)
+			return event
+
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %v event", eventType)
+			return nil
+		}
+	}
+
+	// Watching from the start of the chain should notify the creation of
+	// the output that has already confirmed, enabling the client's block
+	// notifications along the way.
+	client := newLifecycleTestClient(t, node)
+	require.False(t, client.shouldNotifyBlocks())
+	watch, err := client.WatchOutputLifecycle(pkScript, 0)
+	require.NoError(t, err)
+	require.True(t, client.shouldNotifyBlocks())
+	created := nextEvent(watch, OutputCreated, fundingTx, fundingBlock, 1)
+
+	// Once a block spending the output is connected, its spend should be
+	// notified along with the spending transaction, ending the watch.
+	spendingTx := wire.NewMsgTx(2)
+	spendingTx.AddTxIn(&wire.TxIn{PreviousOutPoint: outPoint})
+	spendingTx.AddTxOut(wire.NewTxOut(90000, []byte{0x51}))
+	spendingBlock := node.addBlock(spendingTx)
+	client.zmqBlockNtfns <- spendingBlock
+
+	nextEvent(watch, OutputSpent, spendingTx, spendingBlock, 3)
+	_, ok := <-watch.Events
+	require.False(t, ok)
+
+	// After a restart, resuming the watch from the height hint of the
+	// creation event should notify both events again, in order.
+	client = newLifecycleTestClient(t, node)
+	watch, err = client.WatchOutputLifecycle(pkScript, created.Height)
+	require.NoError(t, err)
+	nextEvent(watch, OutputCreated, fundingTx, fundingBlock, 1)
+	nextEvent(watch, OutputSpent, spendingTx, spendingBlock, 3)
+	_, ok = <-watch.Events
+	require.False(t, ok)
+
+	// Resuming past the creation of the output misses it, so neither
+	// event is notified until the watch is cancelled.
+	watch, err = client.WatchOutputLifecycle(pkScript, created.Height+1)
+	require.NoError(t, err)
+	watch.Cancel()
+	_, ok = <-watch.Events
+	require.False(t, ok)
+}
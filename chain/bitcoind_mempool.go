@@ -0,0 +1,275 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// defaultMempoolPollInterval is the default interval at which
+	// WaitForMempoolEntry polls bitcoind's mempool.
+	defaultMempoolPollInterval = time.Second
+
+	// rejectAlreadyInMempool and rejectAlreadyKnown are the reasons
+	// bitcoind's testmempoolaccept rejects a transaction that has already
+	// been accepted to the mempool or confirmed, respectively.
+	rejectAlreadyInMempool = "txn-already-in-mempool"
+	rejectAlreadyKnown     = "txn-already-known"
+)
+
+// MempoolRejectError is returned by WaitForMempoolEntry when a transaction is
+// rejected from bitcoind's mempool.
+type MempoolRejectError struct {
+	// TxHash is the hash of the rejected transaction.
+	TxHash chainhash.Hash
+
+	// Reason is the rejection reason reported by bitcoind, such as
+	// "min relay fee not met".
+	Reason string
+}
+
+// Error returns the rejection reason of the transaction.
+//
+// NOTE: This is part of the error interface.
+func (e *MempoolRejectError) Error() string {
+	return fmt.Sprintf("transaction %v rejected from mempool: %v", e.TxHash,
+		e.Reason)
+}
+
+// WaitForMempoolEntry polls bitcoind's mempool until the transaction with the
+// given hash enters it, or the context expires, in which case the context's
+// error is returned. This allows confirming that a transaction published
+// through SendRawTransaction propagated, even if one of the client's
+// broadcasters accepted it while bitcoind didn't. A transaction confirmed
+// while waiting is considered to have entered the mempool.
+//
+// If the transaction was published through the client, it's tested for
+// mempool acceptance whenever it's missing from the mempool, and a
+// MempoolRejectError is returned if it's rejected. Otherwise, the rejection
+// reason is unknown, and the wait only ends once the context expires, or the
+// transaction is found to be confirmed through the client's transaction cache
+// or bitcoind's transaction index, if enabled.
+func (c *BitcoindClient) WaitForMempoolEntry(ctx context.Context,
+	txHash chainhash.Hash) error {
+
+	// The transaction is looked up once, as unconfirmed transactions are
+	// dropped from the cache when a block is connected.
+	tx, _, _ := c.chainConn.rawTxCache.get(&txHash)
+
+	ticker := time.NewTicker(c.mempoolPollInterval)
+	defer ticker.Stop()
+
+	for {
+		_, err := c.chainConn.client.GetMempoolEntry(txHash.String())
+		switch {
+		case err == nil:
+			return nil
+
+		case !isTxNotFoundErr(err):
+			return err
+
+		// Without the transaction, bitcoind can't tell whether it
+		// confirmed through testmempoolaccept, so we'll look up its
+		// block instead.
+		case tx == nil:
+			_, blockHash, err := c.chainConn.GetRawTransaction(
+				&txHash,
+			)
+			switch {
+			case err == nil && blockHash != nil:
+				return nil
+
+			case err != nil && !isTxNotFoundErr(err):
+				return err
+			}
+
+		default:
+			reason, err := c.testMempoolAccept(tx)
+			if err != nil {
+				return err
+			}
+			switch reason {
+			// The transaction would be accepted, so it may not
+			// have reached bitcoind yet.
+			case "":
+
+			case rejectAlreadyInMempool, rejectAlreadyKnown:
+				return nil
+
+			default:
+				return &MempoolRejectError{
+					TxHash: txHash,
+					Reason: reason,
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.quit:
+			return ErrBitcoindClientShuttingDown
+		}
+	}
+}
+
+// loadExistingMempool starts scanning the transactions within bitcoind's
+// mempool, notifying those relevant to the client's watch list, if
+// LoadExistingMempool is set and the scan guarded by the given flag hasn't been
+// performed yet. The mempool is scanned once the watch list is first updated,
+// and again once the first rescan completes, as the transactions entering the
+// mempool afterwards are notified as they arrive. Those already within the
+// client's mempool, such as those loaded from its mempool store or notified by
+// a previous scan, aren't notified again.
+//
+// The scan is performed in its own goroutine, as it retrieves each of the
+// mempool's transactions.
+func (c *BitcoindClient) loadExistingMempool(scanned *uint32) {
+	if !c.chainConn.cfg.LoadExistingMempool ||
+		!atomic.CompareAndSwapUint32(scanned, 0, 1) {
+
+		return
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		if err := c.scanExistingMempool(); err != nil {
+			log.Errorf("Unable to retrieve mempool: %v", err)
+			atomic.StoreUint32(scanned, 0)
+		}
+	}()
+}
+
+// scanExistingMempool notifies the transactions within bitcoind's mempool that
+// are relevant to the client's watch list and aren't within the client's
+// mempool yet.
+func (c *BitcoindClient) scanExistingMempool() error {
+	c.existingMempoolMtx.Lock()
+	defer c.existingMempoolMtx.Unlock()
+
+	// The transactions confirming while the scan retrieves them are
+	// tracked from before the mempool is listed, such that filterTx skips
+	// them atomically with its check of the client's mempool.
+	c.watchMtx.Lock()
+	c.scanConfirmed = make(map[chainhash.Hash]struct{})
+	c.watchMtx.Unlock()
+	defer func() {
+		c.watchMtx.Lock()
+		c.scanConfirmed = nil
+		c.watchMtx.Unlock()
+	}()
+
+	hashes, err := c.GetRawMempool()
+	if err != nil {
+		return err
+	}
+
+	// The relevant transactions found are persisted at once after the
+	// scan, rather than one by one.
+	defer c.persistMempoolTxs()
+
+	var numRelevant int
+	for _, hash := range hashes {
+		// The client's mempool is checked again by filterTx, this only
+		// avoids retrieving the transactions already within it.
+		c.watchMtx.RLock()
+		_, ok := c.mempool[*hash]
+		c.watchMtx.RUnlock()
+		if ok {
+			continue
+		}
+
+		// The transaction may have left the mempool since it was
+		// listed, in which case it's skipped.
+		tx, err := c.chainConn.client.GetRawTransaction(hash)
+		switch {
+		case isTxNotFoundErr(err):
+			continue
+
+		case err != nil:
+			log.Errorf("Unable to retrieve mempool transaction "+
+				"%v: %v", hash, err)
+			continue
+		}
+
+		relevant, _, err := c.filterTx(tx.MsgTx(), nil, true)
+		if err != nil {
+			log.Errorf("Unable to filter mempool transaction "+
+				"%v: %v", hash, err)
+			continue
+		}
+		if relevant {
+			numRelevant++
+		}
+
+		select {
+		case <-c.quit:
+			return nil
+		default:
+		}
+	}
+
+	log.Debugf("Loaded %d relevant transactions out of %d within the "+
+		"mempool", numRelevant, len(hashes))
+
+	return nil
+}
+
+// testMempoolAccept tests the transaction for acceptance to bitcoind's mempool
+// through testmempoolaccept, returning the rejection reason if it would be
+// rejected, or an empty string otherwise.
+func (c *BitcoindClient) testMempoolAccept(tx *wire.MsgTx) (string, error) {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return "", err
+	}
+	rawTxs, err := json.Marshal([]string{hex.EncodeToString(buf.Bytes())})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.chainConn.client.RawRequest(
+		"testmempoolaccept", []json.RawMessage{rawTxs},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	var results []struct {
+		Allowed      bool   `json:"allowed"`
+		RejectReason string `json:"reject-reason"`
+	}
+	if err := json.Unmarshal(resp, &results); err != nil {
+		return "", err
+	}
+	if len(results) != 1 {
+		return "", fmt.Errorf("expected 1 testmempoolaccept result, "+
+			"got %d", len(results))
+	}
+	if results[0].Allowed {
+		return "", nil
+	}
+
+	return results[0].RejectReason, nil
+}
+
+// isTxNotFoundErr determines if the error returned by the getmempoolentry RPC
+// corresponds to the transaction not being in the mempool.
+func isTxNotFoundErr(err error) bool {
+	var rpcErr *btcjson.RPCError
+	return errors.As(err, &rpcErr) &&
+		rpcErr.Code == btcjson.ErrRPCInvalidAddressOrKey
+}
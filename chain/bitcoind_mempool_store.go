@@ -0,0 +1,147 @@
+package chain
+
+import (
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// mempoolBucketKey is the top-level bucket of the database set through
+// SetMempoolStore, holding the hashes of the relevant unconfirmed transactions
+// tracked by the client's mempool.
+var mempoolBucketKey = []byte("bitcoindmempool")
+
+// SetMempoolStore sets the database in which the client persists the hashes of
+// the relevant unconfirmed transactions it has notified. Once started again
+// with the same database, the client doesn't notify the transactions still
+// within bitcoind's mempool as new, only notifying those which arrived since.
+// Persisted transactions no longer within bitcoind's mempool are pruned as the
+// client starts.
+//
+// NOTE: This must be called before the client is started.
+func (c *BitcoindClient) SetMempoolStore(db walletdb.DB) {
+	c.mempoolStore = db
+}
+
+// loadMempool adds the transactions persisted within the client's mempool
+// store that are still within bitcoind's mempool to the client's mempool,
+// pruning the others from the store.
+func (c *BitcoindClient) loadMempool() error {
+	if c.mempoolStore == nil {
+		return nil
+	}
+
+	hashes, err := c.GetRawMempool()
+	if err != nil {
+		return err
+	}
+	inMempool := make(map[chainhash.Hash]struct{}, len(hashes))
+	for _, hash := range hashes {
+		inMempool[*hash] = struct{}{}
+	}
+
+	var known []chainhash.Hash
+	var stale [][]byte
+	load := func(tx walletdb.ReadWriteTx) error {
+		bucket, err := tx.CreateTopLevelBucket(mempoolBucketKey)
+		if err != nil {
+			return err
+		}
+
+		err = bucket.ForEach(func(k, _ []byte) error {
+			var hash chainhash.Hash
+			if len(k) == chainhash.HashSize {
+				copy(hash[:], k)
+				if _, ok := inMempool[hash]; ok {
+					known = append(known, hash)
+					return nil
+				}
+			}
+
+			stale = append(stale, append([]byte(nil), k...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range stale {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walletdb.Update(c.mempoolStore, load); err != nil {
+		return err
+	}
+
+	c.watchMtx.Lock()
+	for _, hash := range known {
+		c.mempool[hash] = struct{}{}
+	}
+	c.watchMtx.Unlock()
+
+	log.Debugf("Loaded %d persisted mempool transactions, pruned %d no "+
+		"longer within the mempool", len(known), len(stale))
+
+	return nil
+}
+
+// persistMempoolTxs persists the hashes of the transactions added to the
+// client's mempool since it was last called within its mempool store, if any,
+// at once.
+//
+// NOTE: This must be called without the watchMtx held, such that the client's
+// filters aren't held up by the database.
+func (c *BitcoindClient) persistMempoolTxs() {
+	c.watchMtx.Lock()
+	hashes := c.unpersistedMempool
+	c.unpersistedMempool = nil
+	c.watchMtx.Unlock()
+
+	if c.mempoolStore == nil || len(hashes) == 0 {
+		return
+	}
+
+	put := func(tx walletdb.ReadWriteTx) error {
+		bucket, err := tx.CreateTopLevelBucket(mempoolBucketKey)
+		if err != nil {
+			return err
+		}
+		for _, hash := range hashes {
+			if err := bucket.Put(hash[:], []byte{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walletdb.Update(c.mempoolStore, put); err != nil {
+		log.Warnf("Unable to persist %d mempool transactions: %v",
+			len(hashes), err)
+	}
+}
+
+// forgetMempoolTxs removes the hashes of the transactions evicted from the
+// client's mempool from its mempool store, if any.
+func (c *BitcoindClient) forgetMempoolTxs(hashes map[chainhash.Hash]struct{}) {
+	if c.mempoolStore == nil || len(hashes) == 0 {
+		return
+	}
+
+	remove := func(tx walletdb.ReadWriteTx) error {
+		bucket := tx.ReadWriteBucket(mempoolBucketKey)
+		if bucket == nil {
+			return nil
+		}
+		for hash := range hashes {
+			if err := bucket.Delete(hash[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walletdb.Update(c.mempoolStore, remove); err != nil {
+		log.Warnf("Unable to remove evicted mempool transactions: %v",
+			err)
+	}
+}
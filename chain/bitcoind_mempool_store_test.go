@@ -0,0 +1,190 @@
+package chain
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMempoolStore ensures that a client started again with the same mempool
+// store doesn't notify the relevant transactions it has already notified as
+// new, as long as they're still within bitcoind's mempool, while pruning those
+// which aren't.
+func TestMempoolStore(t *testing.T) {
+	t.Parallel()
+
+	db, err := walletdb.Create(
+		"bdb", filepath.Join(t.TempDir(), "mempool.db"), true,
+		10*time.Second,
+	)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// The fake node serves the chain of a lifecycle test node, along with
+	// a mempool.
+	node := &fakeChainNode{}
+	node.addBlock()
+
+	var (
+		mempoolMtx sync.Mutex
+		mempool    []string
+	)
+	handler := func(method string,
+		params []json.RawMessage) (interface{}, *btcjson.RPCError) {
+
+		if method != "getrawmempool" {
+			return node.handle(method, params)
+		}
+
+		mempoolMtx.Lock()
+		defer mempoolMtx.Unlock()
+		return mempool, nil
+	}
+	setMempool := func(txs ...*wire.MsgTx) {
+		mempoolMtx.Lock()
+		defer mempoolMtx.Unlock()
+
+		mempool = mempool[:0]
+		for _, tx := range txs {
+			mempool = append(mempool, tx.TxHash().String())
+		}
+	}
+
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), &chaincfg.RegressionNetParams,
+	)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	// newClient starts a client persisting its mempool within the store,
+	// watching the address.
+	newClient := func() *BitcoindClient {
+		t.Helper()
+
+		conn := &BitcoindConn{
+			cfg: BitcoindConfig{
+				ChainParams: &chaincfg.RegressionNetParams,
+			},
+			client:        newTestRPCClient(t, handler),
+			rawTxCache:    newRawTxCache(0),
+			blockHashes:   newBlockHashCache(0, 0),
+			rescanClients: make(map[uint64]*BitcoindClient),
+		}
+		client := conn.NewBitcoindClient()
+		client.SetMempoolStore(db)
+		require.NoError(t, client.Start())
+
+		client.watchMtx.Lock()
+		client.watchedAddresses[addr.String()] = struct{}{}
+		client.watchMtx.Unlock()
+
+		return client
+	}
+	newTx := func(i byte) *wire.MsgTx {
+		tx := wire.NewMsgTx(2)
+		prevOut := wire.OutPoint{Hash: chainhash.Hash{i}}
+		tx.AddTxIn(&wire.TxIn{PreviousOutPoint: prevOut})
+		tx.AddTxOut(wire.NewTxOut(1000, pkScript))
+		return tx
+	}
+
+	// relevantTxs returns the hashes of the transactions notified by the
+	// client, until none are notified for a while.
+	relevantTxs := func(client *BitcoindClient) []chainhash.Hash {
+		t.Helper()
+
+		var hashes []chainhash.Hash
+		for {
+			select {
+			case ntfn := <-client.Notifications():
+				relevant, ok := ntfn.(RelevantTx)
+				if ok {
+					hashes = append(
+						hashes, relevant.TxRecord.Hash,
+					)
+				}
+
+			case <-time.After(200 * time.Millisecond):
+				return hashes
+			}
+		}
+	}
+
+	// The first client notifies both transactions entering the mempool.
+	tx1, tx2, tx3 := newTx(1), newTx(2), newTx(3)
+	client := newClient()
+	for _, tx := range []*wire.MsgTx{tx1, tx2} {
+		relevant, _, err := client.filterTx(tx, nil, true)
+		require.NoError(t, err)
+		require.True(t, relevant)
+	}
+
+	// The transactions are only persisted once the filters are released,
+	// at once.
+	client.watchMtx.Lock()
+	require.Len(t, client.unpersistedMempool, 2)
+	client.watchMtx.Unlock()
+	client.persistMempoolTxs()
+	require.Equal(
+		t, []chainhash.Hash{tx1.TxHash(), tx2.TxHash()},
+		relevantTxs(client),
+	)
+	client.Stop()
+	client.WaitForShutdown()
+
+	// By the time the client is started again, the first transaction has
+	// left the mempool, while a new one has entered it.
+	setMempool(tx2, tx3)
+	client = newClient()
+	defer func() {
+		client.Stop()
+		client.WaitForShutdown()
+	}()
+
+	client.watchMtx.Lock()
+	_, known1 := client.mempool[tx1.TxHash()]
+	_, known2 := client.mempool[tx2.TxHash()]
+	client.watchMtx.Unlock()
+	require.False(t, known1)
+	require.True(t, known2)
+
+	// Only the new transaction is notified, without notifying the one
+	// already notified by the previous client again.
+	for _, tx := range []*wire.MsgTx{tx2, tx3} {
+		relevant, _, err := client.filterTx(tx, nil, true)
+		require.NoError(t, err)
+		require.True(t, relevant)
+	}
+	client.persistMempoolTxs()
+	require.Equal(t, []chainhash.Hash{tx3.TxHash()}, relevantTxs(client))
+
+	// The transaction no longer within the mempool was pruned from the
+	// store.
+	var persisted []chainhash.Hash
+	view := func(tx walletdb.ReadTx) error {
+		bucket := tx.ReadBucket(mempoolBucketKey)
+		return bucket.ForEach(func(k, _ []byte) error {
+			var hash chainhash.Hash
+			copy(hash[:], k)
+			persisted = append(persisted, hash)
+			return nil
+		})
+	}
+	require.NoError(t, walletdb.View(db, view))
+	require.ElementsMatch(
+		t, []chainhash.Hash{tx2.TxHash(), tx3.TxHash()}, persisted,
+	)
+}
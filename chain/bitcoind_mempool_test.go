@@ -0,0 +1,393 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/require"
+)
+
+// rpcHandler handles a JSON-RPC request of a fake bitcoind node, returning
+// its result or error.
+type rpcHandler func(method string,
+	params []json.RawMessage) (interface{}, *btcjson.RPCError)
+
+// newTestRPCClient creates an RPC client connected to a fake bitcoind node
+// serving requests through the given handler.
+func newTestRPCClient(t *testing.T, handler rpcHandler) *rpcclient.Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				Method string            `json:"method"`
+				Params []json.RawMessage `json:"params"`
+				ID     uint64            `json:"id"`
+			}
+			err := json.NewDecoder(r.Body).Decode(&req)
+			if err != nil {
+				http.Error(
+					w, err.Error(), http.StatusBadRequest,
+				)
+				return
+			}
+
+			result, rpcErr := handler(req.Method, req.Params)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"result": result,
+				"error":  rpcErr,
+				"id":     req.ID,
+			})
+		},
+	))
+	t.Cleanup(server.Close)
+
+	client, err := rpcclient.New(&rpcclient.ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		HTTPPostMode: true,
+		DisableTLS:   true,
+	}, nil)
+	require.NoError(t, err)
+	t.Cleanup(client.Shutdown)
+
+	return client
+}
+
+// fakeMempoolNode is a fake bitcoind node whose mempool only accepts a
+// published transaction once it has been polled for a number of times,
+// mimicking its propagation delay.
+type fakeMempoolNode struct {
+	mtx sync.Mutex
+
+	// acceptAfter is the number of getmempoolentry calls after which a
+	// published transaction enters the mempool.
+	acceptAfter int
+
+	// rejectReason, if set, is the reason bitcoind's testmempoolaccept
+	// rejects published transactions for, which then never enter the
+	// mempool.
+	rejectReason string
+
+	// confirmedTx, if set, is a transaction confirmed within the node's
+	// chain without being published through the client.
+	confirmedTx *wire.MsgTx
+
+	published map[string]struct{}
+	polls     int
+}
+
+func (n *fakeMempoolNode) handle(method string,
+	params []json.RawMessage) (interface{}, *btcjson.RPCError) {
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	var txid string
+	switch method {
+	case "getinfo":
+		return map[string]interface{}{"version": 1}, nil
+
+	case "sendrawtransaction":
+		var txHex string
+		_ = json.Unmarshal(params[0], &txHex)
+		txBytes, _ := hex.DecodeString(txHex)
+
+		tx := &wire.MsgTx{}
+		_ = tx.Deserialize(bytes.NewReader(txBytes))
+		txid = tx.TxHash().String()
+		n.published[txid] = struct{}{}
+
+		return txid, nil
+
+	case "getmempoolentry":
+		_ = json.Unmarshal(params[0], &txid)
+		n.polls++
+
+		_, ok := n.published[txid]
+		if !ok || n.rejectReason != "" || n.polls <= n.acceptAfter {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Transaction not in mempool",
+			}
+		}
+		return &btcjson.GetMempoolEntryResult{VSize: 100}, nil
+
+	case "testmempoolaccept":
+		result := map[string]interface{}{"allowed": true}
+		if n.rejectReason != "" {
+			result = map[string]interface{}{
+				"allowed":       false,
+				"reject-reason": n.rejectReason,
+			}
+		}
+		return []interface{}{result}, nil
+
+	case "getrawtransaction":
+		_ = json.Unmarshal(params[0], &txid)
+		if n.confirmedTx == nil ||
+			n.confirmedTx.TxHash().String() != txid {
+
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "No such mempool or blockchain " +
+					"transaction",
+			}
+		}
+
+		var buf bytes.Buffer
+		_ = n.confirmedTx.Serialize(&buf)
+		return &btcjson.TxRawResult{
+			Hex:       hex.EncodeToString(buf.Bytes()),
+			Txid:      txid,
+			BlockHash: chainhash.Hash{1}.String(),
+		}, nil
+
+	default:
+		return nil, btcjson.ErrRPCMethodNotFound
+	}
+}
+
+func (n *fakeMempoolNode) numPolls() int {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	return n.polls
+}
+
+// newMempoolTestClient creates a BitcoindClient backed by the given fake node.
+func newMempoolTestClient(t *testing.T,
+	node *fakeMempoolNode) *BitcoindClient {
+
+	t.Helper()
+
+	node.published = make(map[string]struct{})
+	conn := &BitcoindConn{
+		client:     newTestRPCClient(t, node.handle),
+		rawTxCache: newRawTxCache(0),
+	}
+	client := conn.NewBitcoindClient()
+	client.mempoolPollInterval = 10 * time.Millisecond
+
+	return client
+}
+
+// TestWaitForMempoolEntry ensures that waiting on the mempool entry of a
+// published transaction returns once it enters bitcoind's mempool, or with its
+// rejection reason if it's rejected.
+func TestWaitForMempoolEntry(t *testing.T) {
+	t.Parallel()
+
+	tx := &wire.MsgTx{Version: 2}
+	tx.AddTxIn(&wire.TxIn{})
+	tx.AddTxOut(&wire.TxOut{Value: 1000, PkScript: []byte{0x51}})
+
+	// The wait returns once the transaction propagated to the mempool.
+	node := &fakeMempoolNode{acceptAfter: 3}
+	client := newMempoolTestClient(t, node)
+
+	txHash, err := client.SendRawTransaction(tx, false)
+	require.NoError(t, err)
+	require.Equal(t, tx.TxHash(), *txHash)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, client.WaitForMempoolEntry(ctx, *txHash))
+	require.Equal(t, node.acceptAfter+1, node.numPolls())
+
+	// A transaction rejected from the mempool is reported along with its
+	// rejection reason.
+	node = &fakeMempoolNode{rejectReason: "min relay fee not met"}
+	client = newMempoolTestClient(t, node)
+
+	txHash, err = client.SendRawTransaction(tx, false)
+	require.NoError(t, err)
+
+	err = client.WaitForMempoolEntry(ctx, *txHash)
+	var rejectErr *MempoolRejectError
+	require.True(t, errors.As(err, &rejectErr), err)
+	require.Equal(t, tx.TxHash(), rejectErr.TxHash)
+	require.Equal(t, node.rejectReason, rejectErr.Reason)
+
+	// Without knowing the transaction, the wait lasts until the context
+	// expires.
+	timeoutCtx, timeoutCancel := context.WithTimeout(
+		context.Background(), 50*time.Millisecond,
+	)
+	defer timeoutCancel()
+	err = client.WaitForMempoolEntry(timeoutCtx, chainhash.Hash{1})
+	require.True(t, errors.Is(err, context.DeadlineExceeded), err)
+
+	// Unless the transaction is found to be confirmed.
+	node = &fakeMempoolNode{confirmedTx: tx}
+	client = newMempoolTestClient(t, node)
+	require.NoError(t, client.WaitForMempoolEntry(ctx, tx.TxHash()))
+}
+
+// TestLoadExistingMempool ensures that a client loading bitcoind's existing
+// mempool notifies the transactions relevant to its watch list which were
+// within the mempool before it started, once its watch list is loaded through
+// a rescan or otherwise, while skipping the irrelevant ones and those which
+// left the mempool since.
+func TestLoadExistingMempool(t *testing.T) {
+	t.Parallel()
+
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), &chaincfg.RegressionNetParams,
+	)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	newTx := func(i byte, pkScript []byte) *wire.MsgTx {
+		tx := wire.NewMsgTx(2)
+		prevOut := wire.OutPoint{Hash: chainhash.Hash{i}}
+		tx.AddTxIn(&wire.TxIn{PreviousOutPoint: prevOut})
+		tx.AddTxOut(wire.NewTxOut(1000, pkScript))
+		return tx
+	}
+	relevantTx := newTx(1, pkScript)
+	unrelatedTx := newTx(2, []byte{0x51})
+	evictedTx := newTx(3, pkScript)
+
+	// The fake node serves the chain of a lifecycle test node, along with
+	// a mempool whose last transaction can no longer be retrieved.
+	node := &fakeChainNode{}
+	node.addBlock()
+	handler := func(method string,
+		params []json.RawMessage) (interface{}, *btcjson.RPCError) {
+
+		switch method {
+		case "getrawmempool":
+			return []string{
+				relevantTx.TxHash().String(),
+				unrelatedTx.TxHash().String(),
+				evictedTx.TxHash().String(),
+			}, nil
+
+		case "getrawtransaction":
+			var hash string
+			_ = json.Unmarshal(params[0], &hash)
+			known := []*wire.MsgTx{relevantTx, unrelatedTx}
+			for _, tx := range known {
+				if tx.TxHash().String() != hash {
+					continue
+				}
+				var buf bytes.Buffer
+				_ = tx.Serialize(&buf)
+				return hex.EncodeToString(buf.Bytes()), nil
+			}
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "No such mempool or blockchain " +
+					"transaction",
+			}
+
+		default:
+			return node.handle(method, params)
+		}
+	}
+
+	conn := &BitcoindConn{
+		cfg: BitcoindConfig{
+			ChainParams:         &chaincfg.RegressionNetParams,
+			LoadExistingMempool: true,
+		},
+		client:        newTestRPCClient(t, handler),
+		rawTxCache:    newRawTxCache(0),
+		blockHashes:   newBlockHashCache(0, 0),
+		rescanClients: make(map[uint64]*BitcoindClient),
+	}
+	client := conn.NewBitcoindClient()
+	require.NoError(t, client.Start())
+	defer func() {
+		client.Stop()
+		client.WaitForShutdown()
+	}()
+
+	// relevantTxs returns the hashes of the unconfirmed transactions
+	// notified by the client, until none are notified for a while.
+	relevantTxs := func(client *BitcoindClient) []chainhash.Hash {
+		t.Helper()
+
+		var hashes []chainhash.Hash
+		for {
+			select {
+			case ntfn := <-client.Notifications():
+				relevant, ok := ntfn.(RelevantTx)
+				if ok && relevant.Block == nil {
+					hashes = append(
+						hashes, relevant.TxRecord.Hash,
+					)
+				}
+
+			case <-time.After(200 * time.Millisecond):
+				return hashes
+			}
+		}
+	}
+
+	// Only the relevant transaction is notified once the rescan loading
+	// the watch list completes, and only the first time.
+	genesis := node.blocks[0].BlockHash()
+	for i := 0; i < 2; i++ {
+		err := client.Rescan(&genesis, []btcutil.Address{addr}, nil)
+		require.NoError(t, err)
+
+		var expected []chainhash.Hash
+		if i == 0 {
+			expected = []chainhash.Hash{relevantTx.TxHash()}
+		}
+		require.Equal(t, expected, relevantTxs(client))
+	}
+
+	// A client loading its watch list without rescanning also notifies
+	// the relevant transaction.
+	other := conn.NewBitcoindClient()
+	require.NoError(t, other.Start())
+	defer func() {
+		other.Stop()
+		other.WaitForShutdown()
+	}()
+
+	require.NoError(t, other.NotifyReceived([]btcutil.Address{addr}))
+	require.Equal(
+		t, []chainhash.Hash{relevantTx.TxHash()}, relevantTxs(other),
+	)
+
+	// A relevant transaction confirmed while a scan is in progress isn't
+	// notified as unconfirmed once the scan filters it.
+	confirmedTx := newTx(4, pkScript)
+	other.watchMtx.Lock()
+	other.scanConfirmed = make(map[chainhash.Hash]struct{})
+	other.watchMtx.Unlock()
+
+	relevant, _, err := other.filterTx(confirmedTx, &btcjson.BlockDetails{
+		Height: 1,
+		Hash:   node.blocks[0].BlockHash().String(),
+	}, true)
+	require.NoError(t, err)
+	require.True(t, relevant)
+
+	relevant, _, err = other.filterTx(confirmedTx, nil, true)
+	require.NoError(t, err)
+	require.False(t, relevant)
+	require.Empty(t, relevantTxs(other))
+}
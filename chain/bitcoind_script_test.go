@@ -0,0 +1,83 @@
+package chain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNotifyScript ensures that a client watching a raw output script that
+// can't be expressed as an address, such as a bare multisig script, considers
+// the transactions paying to it relevant, whether they're unconfirmed or
+// confirmed, along with those spending the outputs paying to it.
+func TestNotifyScript(t *testing.T) {
+	t.Parallel()
+
+	node := &fakeChainNode{}
+	node.addBlock()
+	client := newLifecycleTestClient(t, node)
+	client.wg.Add(1)
+	go client.rescanHandler()
+
+	// Build a bare 1-of-2 multisig script.
+	var pubKeys []*btcutil.AddressPubKey
+	for i := 0; i < 2; i++ {
+		privKey, err := btcec.NewPrivateKey(btcec.S256())
+		require.NoError(t, err)
+		pubKey, err := btcutil.NewAddressPubKey(
+			privKey.PubKey().SerializeCompressed(),
+			&chaincfg.RegressionNetParams,
+		)
+		require.NoError(t, err)
+		pubKeys = append(pubKeys, pubKey)
+	}
+	script, err := txscript.MultiSigScript(pubKeys, 1)
+	require.NoError(t, err)
+
+	require.NoError(t, client.NotifyScript(script))
+	require.Eventually(t, func() bool {
+		client.watchMtx.RLock()
+		defer client.watchMtx.RUnlock()
+
+		_, ok := client.watchedScripts[string(script)]
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	newTx := func(prevOut wire.OutPoint, pkScript []byte) *wire.MsgTx {
+		tx := wire.NewMsgTx(2)
+		tx.AddTxIn(&wire.TxIn{PreviousOutPoint: prevOut})
+		tx.AddTxOut(wire.NewTxOut(1000, pkScript))
+		return tx
+	}
+
+	// A transaction paying to another script isn't relevant.
+	unrelatedTx := newTx(
+		wire.OutPoint{Hash: chainhash.Hash{0x01}}, []byte{0x51},
+	)
+	relevant, _, err := client.filterTx(unrelatedTx, nil, false)
+	require.NoError(t, err)
+	require.False(t, relevant)
+
+	// The unconfirmed payment to the multisig script is relevant.
+	paymentTx := newTx(wire.OutPoint{Hash: chainhash.Hash{0x02}}, script)
+	relevant, _, err = client.filterTx(paymentTx, nil, false)
+	require.NoError(t, err)
+	require.True(t, relevant)
+
+	// A payment confirmed without having been seen unconfirmed is found
+	// within its block, along with the transaction spending its output.
+	minedTx := newTx(wire.OutPoint{Hash: chainhash.Hash{0x03}}, script)
+	spendTx := newTx(wire.OutPoint{Hash: minedTx.TxHash()}, []byte{0x51})
+	block := node.addBlock(unrelatedTx, minedTx, spendTx)
+	relevantTxs := client.filterBlock(block, 1, false)
+	require.Len(t, relevantTxs, 2)
+	require.Equal(t, minedTx.TxHash(), relevantTxs[0].Hash)
+	require.Equal(t, spendTx.TxHash(), relevantTxs[1].Hash)
+}
@@ -0,0 +1,156 @@
+package chain
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+const (
+	// defaultBlockHashCacheSize is the default number of block hashes
+	// cached by a BitcoindConn.
+	defaultBlockHashCacheSize = 10000
+
+	// defaultBlockHashCacheSafeDepth is the default depth below the best
+	// known height from which cached block hashes are considered safe
+	// from reorgs.
+	defaultBlockHashCacheSafeDepth = 6
+)
+
+// blockHashCacheEntry is a block hash cached along with its height.
+type blockHashCacheEntry struct {
+	height int32
+	hash   chainhash.Hash
+}
+
+// blockHashCache is a bounded LRU cache of the hashes of the main chain blocks
+// keyed by their height.
+//
+// Entries at least safeDepth blocks below the best known height are treated
+// as immutable. Shallower entries only remain valid until the next block is
+// connected, as it may reorg them out of the main chain. All entries at or
+// above the height of a disconnected block are invalidated, regardless of
+// their depth.
+type blockHashCache struct {
+	mtx       sync.Mutex
+	capacity  int
+	safeDepth int32
+	entries   map[int32]*list.Element
+	lru       *list.List
+
+	// bestHeight is the best height known to the cache, which is the
+	// highest height cached since the last disconnected block.
+	bestHeight int32
+
+	hits   uint64
+	misses uint64
+}
+
+// newBlockHashCache creates a block hash cache holding up to capacity hashes,
+// with hashes at least safeDepth blocks deep treated as immutable.
+func newBlockHashCache(capacity int, safeDepth int32) *blockHashCache {
+	if capacity <= 0 {
+		capacity = defaultBlockHashCacheSize
+	}
+	if safeDepth <= 0 {
+		safeDepth = defaultBlockHashCacheSafeDepth
+	}
+
+	return &blockHashCache{
+		capacity:  capacity,
+		safeDepth: safeDepth,
+		entries:   make(map[int32]*list.Element),
+		lru:       list.New(),
+	}
+}
+
+// get returns the cached hash of the block at the given height. The lookup is
+// recorded as either a hit or a miss.
+func (c *blockHashCache) get(height int32) (*chainhash.Hash, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	elem, ok := c.entries[height]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	c.hits++
+	c.lru.MoveToFront(elem)
+	hash := elem.Value.(*blockHashCacheEntry).hash
+	return &hash, true
+}
+
+// add caches the hash of the block at the given height, evicting the least
+// recently used hash if the cache is full.
+func (c *blockHashCache) add(height int32, hash *chainhash.Hash) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if elem, ok := c.entries[height]; ok {
+		c.removeElement(elem)
+	}
+
+	c.entries[height] = c.lru.PushFront(&blockHashCacheEntry{
+		height: height,
+		hash:   *hash,
+	})
+	if height > c.bestHeight {
+		c.bestHeight = height
+	}
+
+	for c.lru.Len() > c.capacity {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// blockConnected invalidates the cached hashes shallower than the safe depth,
+// which the new block may have reorged out.
+func (c *blockHashCache) blockConnected() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.removeFrom(c.bestHeight - c.safeDepth + 1)
+}
+
+// blockDisconnected invalidates the cached hashes at or above the height of a
+// disconnected block.
+func (c *blockHashCache) blockDisconnected(height int32) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.removeFrom(height)
+	if c.bestHeight >= height {
+		c.bestHeight = height - 1
+	}
+}
+
+// stats returns the number of cache hits and misses, along with the number of
+// cached hashes.
+func (c *blockHashCache) stats() (uint64, uint64, int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.hits, c.misses, c.lru.Len()
+}
+
+// removeFrom removes the cached hashes at or above the given height.
+//
+// NOTE: This must be called with the cache's mutex held.
+func (c *blockHashCache) removeFrom(height int32) {
+	for h, elem := range c.entries {
+		if h >= height {
+			c.removeElement(elem)
+		}
+	}
+}
+
+// removeElement removes the cached hash of the given element.
+//
+// NOTE: This must be called with the cache's mutex held.
+func (c *blockHashCache) removeElement(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*blockHashCacheEntry)
+	delete(c.entries, entry.height)
+}
@@ -0,0 +1,123 @@
+package chain
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBlockHashNode is a fake bitcoind node serving the hashes of its main
+// chain blocks through getblockhash.
+type fakeBlockHashNode struct {
+	mtx     sync.Mutex
+	chain   []chainhash.Hash
+	lookups map[int64]int
+}
+
+func (n *fakeBlockHashNode) handle(method string,
+	params []json.RawMessage) (interface{}, *btcjson.RPCError) {
+
+	if method != "getblockhash" {
+		return nil, btcjson.ErrRPCMethodNotFound
+	}
+
+	var height int64
+	_ = json.Unmarshal(params[0], &height)
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.lookups[height]++
+	if height < 0 || height >= int64(len(n.chain)) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCOutOfRange,
+			Message: "Block height out of range",
+		}
+	}
+	return n.chain[height].String(), nil
+}
+
+// reorg replaces the blocks of the chain from the given height on.
+func (n *fakeBlockHashNode) reorg(height int, fork byte) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	for h := height; h < len(n.chain); h++ {
+		n.chain[h] = chainhash.Hash{fork, byte(h)}
+	}
+}
+
+func (n *fakeBlockHashNode) numLookups(height int64) int {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	return n.lookups[height]
+}
+
+// TestBitcoindConnGetBlockHash ensures that block hashes are served from the
+// cache once looked up, that shallow hashes are invalidated once a block is
+// connected while deep ones aren't, and that hashes at or above a
+// disconnected block are invalidated.
+func TestBitcoindConnGetBlockHash(t *testing.T) {
+	t.Parallel()
+
+	node := &fakeBlockHashNode{lookups: make(map[int64]int)}
+	for h := 0; h < 20; h++ {
+		node.chain = append(node.chain, chainhash.Hash{0x01, byte(h)})
+	}
+
+	const safeDepth = 6
+	conn := &BitcoindConn{
+		client:      newTestRPCClient(t, node.handle),
+		blockHashes: newBlockHashCache(0, safeDepth),
+	}
+
+	// assertHash looks up the hash at the given height, ensuring it's the
+	// node's and that it's been looked up from the node the expected
+	// number of times.
+	assertHash := func(height int32, lookups int) {
+		t.Helper()
+
+		hash, err := conn.GetBlockHash(height)
+		require.NoError(t, err)
+		require.Equal(t, node.chain[height], *hash)
+		require.Equal(t, lookups, node.numLookups(int64(height)))
+	}
+
+	// Repeated lookups of the same heights are served from the cache.
+	const (
+		deep    = 5
+		shallow = 17
+		tip     = 19
+	)
+	for i := 0; i < 3; i++ {
+		assertHash(deep, 1)
+		assertHash(shallow, 1)
+		assertHash(tip, 1)
+	}
+	hits, misses, size := conn.blockHashes.stats()
+	require.EqualValues(t, 6, hits)
+	require.EqualValues(t, 3, misses)
+	require.Equal(t, 3, size)
+
+	// A shallow reorg replaces the shallow blocks, which are invalidated
+	// once the new block is connected, while deep ones remain cached.
+	node.reorg(shallow, 0x02)
+	conn.blockHashes.blockConnected()
+	assertHash(deep, 1)
+	assertHash(shallow, 2)
+	assertHash(tip, 2)
+
+	// Disconnecting a block invalidates the hashes at or above its height,
+	// regardless of their depth.
+	node.reorg(deep, 0x03)
+	conn.blockHashes.blockDisconnected(deep)
+	assertHash(deep, 2)
+	assertHash(shallow, 3)
+	assertHash(deep-1, 1)
+	assertHash(deep-1, 1)
+}
@@ -0,0 +1,130 @@
+package chain
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Broadcaster is an endpoint transactions can be published to, such as an
+// rpcclient.Client connected to an additional node. Broadcasters are only used
+// to publish transactions, with the chain client's primary backend remaining
+// the source of notifications.
+type Broadcaster interface {
+	// SendRawTransaction publishes the transaction, returning its hash if
+	// it's accepted.
+	SendRawTransaction(*wire.MsgTx, bool) (*chainhash.Hash, error)
+}
+
+// BroadcastError is returned when a transaction is rejected by the primary
+// backend of a chain client and all of its broadcasters.
+type BroadcastError struct {
+	// Errs contains the rejection reason of each endpoint, starting with
+	// the primary backend's followed by those of the broadcasters in the
+	// order they were set.
+	Errs []error
+}
+
+// Error returns the rejection reasons of all endpoints.
+//
+// NOTE: This is part of the error interface.
+func (e *BroadcastError) Error() string {
+	reasons := make([]string, 0, len(e.Errs))
+	for idx, err := range e.Errs {
+		reasons = append(reasons, fmt.Sprintf("endpoint %d: %v", idx,
+			err))
+	}
+
+	return "transaction rejected by all endpoints: " +
+		strings.Join(reasons, "; ")
+}
+
+// Unwrap returns the rejection reason of the primary backend, such that its
+// errors can still be inspected through errors.Is and errors.As.
+func (e *BroadcastError) Unwrap() error {
+	if len(e.Errs) == 0 {
+		return nil
+	}
+	return e.Errs[0]
+}
+
+// broadcastFunc publishes a transaction through a chain client's primary
+// backend.
+type broadcastFunc func(*wire.MsgTx, bool) (*chainhash.Hash, error)
+
+// broadcastSet holds the broadcast-only endpoints of a chain client.
+type broadcastSet struct {
+	mtx          sync.RWMutex
+	broadcasters []Broadcaster
+}
+
+// set replaces the broadcasters of the set.
+func (b *broadcastSet) set(broadcasters []Broadcaster) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.broadcasters = append([]Broadcaster(nil), broadcasters...)
+}
+
+// broadcast publishes the transaction through the primary backend and all of
+// the broadcasters concurrently, succeeding if any of them accepts it. If all
+// of them reject it, a BroadcastError aggregating their rejection reasons is
+// returned. Without any broadcaster, the transaction is only published through
+// the primary backend, whose error is returned as is.
+func (b *broadcastSet) broadcast(tx *wire.MsgTx, allowHighFees bool,
+	primary broadcastFunc) (*chainhash.Hash, error) {
+
+	b.mtx.RLock()
+	broadcasters := b.broadcasters
+	b.mtx.RUnlock()
+
+	if len(broadcasters) == 0 {
+		return primary(tx, allowHighFees)
+	}
+
+	endpoints := make([]broadcastFunc, 0, len(broadcasters)+1)
+	endpoints = append(endpoints, primary)
+	for _, broadcaster := range broadcasters {
+		endpoints = append(endpoints, broadcaster.SendRawTransaction)
+	}
+
+	var (
+		wg     sync.WaitGroup
+		hashes = make([]*chainhash.Hash, len(endpoints))
+		errs   = make([]error, len(endpoints))
+	)
+	for idx, send := range endpoints {
+		wg.Add(1)
+		go func(idx int, send broadcastFunc) {
+			defer wg.Done()
+
+			hashes[idx], errs[idx] = send(tx, allowHighFees)
+		}(idx, send)
+	}
+	wg.Wait()
+
+	// The hash reported by the primary backend is preferred if it
+	// accepted the transaction.
+	accepted := -1
+	for idx, err := range errs {
+		if err == nil {
+			accepted = idx
+			break
+		}
+	}
+	if accepted == -1 {
+		return nil, &BroadcastError{Errs: errs}
+	}
+
+	for idx, err := range errs {
+		if err != nil {
+			log.Debugf("Endpoint %d rejected transaction %v: %v",
+				idx, tx.TxHash(), err)
+		}
+	}
+
+	return hashes[accepted], nil
+}
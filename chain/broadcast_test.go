@@ -0,0 +1,91 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// stubBroadcaster is a Broadcaster accepting or rejecting all transactions.
+type stubBroadcaster struct {
+	err  error
+	sent chan *wire.MsgTx
+}
+
+func newStubBroadcaster(err error) *stubBroadcaster {
+	return &stubBroadcaster{err: err, sent: make(chan *wire.MsgTx, 1)}
+}
+
+func (b *stubBroadcaster) SendRawTransaction(tx *wire.MsgTx,
+	_ bool) (*chainhash.Hash, error) {
+
+	b.sent <- tx
+	if b.err != nil {
+		return nil, b.err
+	}
+	hash := tx.TxHash()
+	return &hash, nil
+}
+
+// TestBroadcastSet ensures that transactions are published to the primary
+// backend and all broadcasters, succeeding if any of them accepts it, and
+// aggregating the rejection reasons otherwise.
+func TestBroadcastSet(t *testing.T) {
+	t.Parallel()
+
+	tx := &wire.MsgTx{
+		Version: 2,
+		TxIn:    []*wire.TxIn{{}},
+		TxOut:   []*wire.TxOut{wire.NewTxOut(1000, nil)},
+	}
+	txHash := tx.TxHash()
+
+	primaryErr := &btcjson.RPCError{
+		Code:    btcjson.ErrRPCTxAlreadyInChain,
+		Message: "transaction already in block chain",
+	}
+	primary := newStubBroadcaster(primaryErr)
+
+	// Without broadcasters, the primary backend's error is returned as
+	// is.
+	var broadcasters broadcastSet
+	_, err := broadcasters.broadcast(tx, false, primary.SendRawTransaction)
+	require.Equal(t, primaryErr, err)
+	require.Equal(t, tx, <-primary.sent)
+
+	// With one broadcaster rejecting the transaction and the other
+	// accepting it, it's published successfully.
+	rejecting := newStubBroadcaster(errors.New("min relay fee not met"))
+	accepting := newStubBroadcaster(nil)
+	broadcasters.set([]Broadcaster{rejecting, accepting})
+
+	hash, err := broadcasters.broadcast(
+		tx, false, primary.SendRawTransaction,
+	)
+	require.NoError(t, err)
+	require.Equal(t, &txHash, hash)
+	for _, b := range []*stubBroadcaster{primary, rejecting, accepting} {
+		require.Equal(t, tx, <-b.sent)
+	}
+
+	// If all endpoints reject the transaction, their reasons are
+	// aggregated, with the primary backend's error still being
+	// inspectable.
+	accepting.err = errors.New("mempool full")
+	_, err = broadcasters.broadcast(tx, false, primary.SendRawTransaction)
+	var broadcastErr *BroadcastError
+	require.True(t, errors.As(err, &broadcastErr))
+	require.Equal(t, []error{
+		primaryErr, rejecting.err, accepting.err,
+	}, broadcastErr.Errs)
+	require.Contains(t, err.Error(), "min relay fee not met")
+	require.Contains(t, err.Error(), "mempool full")
+
+	var rpcErr *btcjson.RPCError
+	require.True(t, errors.As(err, &rpcErr))
+	require.Equal(t, btcjson.ErrRPCTxAlreadyInChain, rpcErr.Code)
+}
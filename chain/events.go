@@ -0,0 +1,149 @@
+package chain
+
+import (
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+)
+
+// Event is a structured notification of a significant occurrence within one
+// of the chain backends. Each concrete event type carries the details of the
+// occurrence as typed fields so that embedders can consume them without
+// parsing log lines.
+type Event interface {
+	// isEvent is an unexported marker method to ensure only the events
+	// defined within this package satisfy the interface.
+	isEvent()
+}
+
+// BlockDispatchedEvent is emitted once a block received from the backend has
+// been handed off to all of the active rescan clients.
+type BlockDispatchedEvent struct {
+	// Hash is the hash of the dispatched block.
+	Hash chainhash.Hash
+
+	// NumClients is the number of rescan clients the block was dispatched
+	// to.
+	NumClients int
+}
+
+// ReorgEvent is emitted once a chain reorganization has been processed and the
+// client has been brought up to date with the new best chain.
+type ReorgEvent struct {
+	// OldTip is the best block known to the client before the reorg.
+	OldTip waddrmgr.BlockStamp
+
+	// NewTip is the best block known to the client after the reorg.
+	NewTip waddrmgr.BlockStamp
+
+	// Disconnected is the number of blocks that were disconnected from the
+	// old chain.
+	Disconnected int32
+}
+
+// ReconnectEvent is emitted whenever a subscription to the backend has timed
+// out and is in the process of being re-established.
+type ReconnectEvent struct {
+	// Subscription is the name of the subscription being re-established,
+	// e.g. rawblock or rawtx.
+	Subscription string
+}
+
+// MempoolEvictEvent is emitted whenever confirmed transactions are evicted from
+// a client's local view of the mempool after they've reached a sufficient
+// depth.
+type MempoolEvictEvent struct {
+	// Height is the height of the block whose confirmed transactions were
+	// evicted.
+	Height int32
+
+	// NumEvicted is the number of transactions that were evicted.
+	NumEvicted int
+}
+
+// HealthEvent is emitted whenever a backend transitions between being healthy
+// and unhealthy, as determined by its periodic health checks.
+type HealthEvent struct {
+	// Backend is the name of the backend, e.g. bitcoind or neutrino.
+	Backend string
+
+	// Healthy is whether the backend is now healthy.
+	Healthy bool
+
+	// ConsecutiveFailures is the number of consecutive failed health
+	// checks, which is zero once the backend is healthy again.
+	ConsecutiveFailures int
+
+	// Err is the error of the latest failed health check while the backend
+	// is unhealthy.
+	Err error
+}
+
+// A compile-time check to ensure the event types satisfy the Event interface.
+var (
+	_ Event = (*BlockDispatchedEvent)(nil)
+	_ Event = (*ReorgEvent)(nil)
+	_ Event = (*ReconnectEvent)(nil)
+	_ Event = (*MempoolEvictEvent)(nil)
+	_ Event = (*HealthEvent)(nil)
+)
+
+func (*BlockDispatchedEvent) isEvent() {}
+func (*ReorgEvent) isEvent()           {}
+func (*ReconnectEvent) isEvent()       {}
+func (*MempoolEvictEvent) isEvent()    {}
+func (*HealthEvent) isEvent()          {}
+
+// EventSink is an interface for receiving the structured events emitted by the
+// chain backends.
+//
+// NOTE: Implementations must be safe for concurrent use and should not block,
+// as events are emitted from within the backends' notification handlers.
+type EventSink interface {
+	// OnEvent is invoked for every event emitted by the backend.
+	OnEvent(Event)
+}
+
+// logEventSink is the default EventSink, which writes every event to the
+// package logger.
+type logEventSink struct{}
+
+// A compile-time check to ensure logEventSink satisfies the EventSink
+// interface.
+var _ EventSink = logEventSink{}
+
+// OnEvent writes the event to the package logger.
+//
+// NOTE: This is part of the EventSink interface.
+func (logEventSink) OnEvent(event Event) {
+	switch e := event.(type) {
+	case *BlockDispatchedEvent:
+		log.Tracef("Dispatched block %v to %d rescan client(s)", e.Hash,
+			e.NumClients)
+
+	case *ReorgEvent:
+		log.Infof("Processed chain reorg: old_tip=(%v, %v), "+
+			"new_tip=(%v, %v), disconnected=%v", e.OldTip.Height,
+			e.OldTip.Hash, e.NewTip.Height, e.NewTip.Hash,
+			e.Disconnected)
+
+	case *ReconnectEvent:
+		log.Tracef("Re-establishing timed out ZMQ %v connection",
+			e.Subscription)
+
+	case *MempoolEvictEvent:
+		log.Debugf("Evicted %d confirmed transaction(s) from mempool "+
+			"at height %v", e.NumEvicted, e.Height)
+
+	case *HealthEvent:
+		if e.Healthy {
+			log.Infof("Chain backend %v is healthy again", e.Backend)
+		} else {
+			log.Warnf("Chain backend %v is unhealthy after %d "+
+				"failed health check(s): %v", e.Backend,
+				e.ConsecutiveFailures, e.Err)
+		}
+
+	default:
+		log.Warnf("Received unknown chain event %T", event)
+	}
+}
@@ -0,0 +1,48 @@
+package chain
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingEventSink is an EventSink that records every event it receives.
+type recordingEventSink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// OnEvent records the event.
+func (s *recordingEventSink) OnEvent(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+}
+
+// TestBitcoindConnEmit ensures events are handed off to the configured
+// EventSink, and that a connection without one falls back to the default
+// sink.
+func TestBitcoindConnEmit(t *testing.T) {
+	t.Parallel()
+
+	sink := &recordingEventSink{}
+	conn := &BitcoindConn{cfg: BitcoindConfig{EventSink: sink}}
+
+	dispatched := &BlockDispatchedEvent{
+		Hash:       chainhash.Hash{0x01},
+		NumClients: 2,
+	}
+	evicted := &MempoolEvictEvent{Height: 100, NumEvicted: 3}
+	conn.emit(dispatched)
+	conn.emit(evicted)
+
+	require.Equal(t, []Event{dispatched, evicted}, sink.events)
+
+	// A connection without a sink should not panic when emitting events.
+	conn = &BitcoindConn{}
+	conn.emit(&ReconnectEvent{Subscription: rawBlockZMQCommand})
+	conn.emit(&ReorgEvent{})
+}
@@ -0,0 +1,264 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultHealthCheckInterval is the default interval at which the
+	// chain backends are health checked.
+	defaultHealthCheckInterval = 30 * time.Second
+
+	// defaultHealthCheckTimeout is the default duration after which a
+	// health check is considered failed if the backend hasn't responded.
+	defaultHealthCheckTimeout = 10 * time.Second
+
+	// defaultHealthCheckMaxFailures is the default number of consecutive
+	// failed health checks after which a backend is considered unhealthy.
+	defaultHealthCheckMaxFailures = 3
+)
+
+// HealthCheckConfig contains the parameters with which a chain backend is
+// periodically health checked, to detect backends that accept connections but
+// have silently stopped serving data. Zero values are replaced by defaults.
+type HealthCheckConfig struct {
+	// Disable disables health checking the backend, which is then always
+	// reported as healthy.
+	Disable bool
+
+	// Interval is the interval at which the backend is health checked.
+	Interval time.Duration
+
+	// Timeout is the duration after which a health check is considered
+	// failed if the backend hasn't responded.
+	Timeout time.Duration
+
+	// MaxFailures is the number of consecutive failed health checks after
+	// which the backend is considered unhealthy. A single successful check
+	// is enough for it to be considered healthy again.
+	MaxFailures int
+
+	// EventSink is an optional sink that will receive the HealthEvents of
+	// the backend, in place of the one the backend emits its other events
+	// to, if any. If nil, they're emitted along with the backend's other
+	// events, or written to the package logger if it has none.
+	EventSink EventSink
+}
+
+// withDefaults returns a copy of the config with its zero values replaced by
+// defaults.
+func (c HealthCheckConfig) withDefaults() HealthCheckConfig {
+	if c.Interval <= 0 {
+		c.Interval = defaultHealthCheckInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultHealthCheckTimeout
+	}
+	if c.MaxFailures <= 0 {
+		c.MaxFailures = defaultHealthCheckMaxFailures
+	}
+	return c
+}
+
+// healthCheck checks whether a chain backend is serving data. It should return
+// once the context is done. The healthMonitor doesn't wait for it to, but
+// doesn't start another check until it does either.
+type healthCheck func(ctx context.Context) error
+
+// healthMonitor periodically health checks a chain backend, tracking whether
+// it's healthy and emitting a HealthEvent whenever that changes. A nil monitor
+// never checks its backend, which is always reported as healthy.
+type healthMonitor struct {
+	cfg     HealthCheckConfig
+	backend string
+	check   healthCheck
+	emit    func(Event)
+	wg      sync.WaitGroup
+
+	// pending delivers the result of the ongoing check, if any. It's only
+	// accessed by the check handler.
+	pending chan error
+
+	// mtx guards the fields below.
+	mtx      sync.Mutex
+	failures int
+	healthy  bool
+	lastErr  error
+	quit     chan struct{}
+}
+
+// newHealthMonitor creates a monitor for the named backend, which is initially
+// considered healthy. Its events are emitted through emit, unless the config
+// sets an EventSink.
+func newHealthMonitor(backend string, cfg HealthCheckConfig,
+	check healthCheck, emit func(Event)) *healthMonitor {
+
+	if cfg.EventSink != nil {
+		emit = cfg.EventSink.OnEvent
+	}
+
+	return &healthMonitor{
+		cfg:     cfg.withDefaults(),
+		backend: backend,
+		check:   check,
+		emit:    emit,
+		healthy: true,
+	}
+}
+
+// Start starts health checking the backend, unless disabled. Calling Start on
+// a running monitor has no effect.
+func (m *healthMonitor) Start() {
+	if m == nil || m.cfg.Disable {
+		return
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.quit != nil {
+		return
+	}
+	m.quit = make(chan struct{})
+
+	m.wg.Add(1)
+	go m.checkHandler(m.quit)
+}
+
+// Stop stops health checking the backend, waiting for the ongoing check, if
+// any, to be abandoned.
+func (m *healthMonitor) Stop() {
+	if m == nil {
+		return
+	}
+
+	m.mtx.Lock()
+	quit := m.quit
+	m.quit = nil
+	m.mtx.Unlock()
+
+	if quit == nil {
+		return
+	}
+	close(quit)
+	m.wg.Wait()
+}
+
+// checkHandler health checks the backend at every interval until quit is
+// closed.
+//
+// NOTE: This must be run as a goroutine.
+func (m *healthMonitor) checkHandler(quit chan struct{}) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-quit:
+			return
+		}
+
+		err := m.runCheck(quit)
+		select {
+		case <-quit:
+			return
+		default:
+		}
+		m.recordResult(err)
+	}
+}
+
+// runCheck runs a single health check bounded by the configured timeout. The
+// check is abandoned, rather than waited on, if the backend doesn't respond in
+// time, such that a hung backend can't stall the monitor. An abandoned check
+// that hasn't returned yet is awaited by the following runs in place of a new
+// check, such that a hung backend doesn't accumulate a goroutine per interval.
+func (m *healthMonitor) runCheck(quit chan struct{}) error {
+	if m.pending == nil {
+		pending := make(chan error, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(
+				context.Background(), m.cfg.Timeout,
+			)
+			defer cancel()
+
+			pending <- m.check(ctx)
+		}()
+		m.pending = pending
+	}
+
+	timeout := time.NewTimer(m.cfg.Timeout)
+	defer timeout.Stop()
+
+	select {
+	case err := <-m.pending:
+		m.pending = nil
+		return err
+
+	case <-timeout.C:
+		return fmt.Errorf("%v backend did not respond within %v: %w",
+			m.backend, m.cfg.Timeout, context.DeadlineExceeded)
+
+	case <-quit:
+		return nil
+	}
+}
+
+// recordResult records the result of a health check, emitting a HealthEvent if
+// the backend's health changed as a result.
+func (m *healthMonitor) recordResult(err error) {
+	m.mtx.Lock()
+	wasHealthy := m.healthy
+	if err != nil {
+		m.failures++
+		m.lastErr = err
+		if m.failures >= m.cfg.MaxFailures {
+			m.healthy = false
+		}
+	} else {
+		m.failures = 0
+		m.lastErr = nil
+		m.healthy = true
+	}
+	event := &HealthEvent{
+		Backend:             m.backend,
+		Healthy:             m.healthy,
+		ConsecutiveFailures: m.failures,
+		Err:                 m.lastErr,
+	}
+	m.mtx.Unlock()
+
+	if event.Healthy != wasHealthy {
+		m.emit(event)
+	}
+}
+
+// status returns whether the backend is currently healthy, the number of
+// consecutive failed health checks, and the error of the latest one if it
+// failed.
+func (m *healthMonitor) status() (bool, int, error) {
+	if m == nil {
+		return true, 0, nil
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.healthy, m.failures, m.lastErr
+}
+
+// isHealthy returns whether the backend is currently healthy. If it isn't, the
+// error of the latest failed health check is returned as well.
+func (m *healthMonitor) isHealthy() (bool, error) {
+	healthy, _, err := m.status()
+	if healthy {
+		return true, nil
+	}
+	return false, err
+}
@@ -0,0 +1,127 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHealthMonitorHungBackend ensures that a backend which stops responding
+// to health checks is considered unhealthy after the configured number of
+// consecutive failures, without starting another check while the hung one
+// hasn't returned, and healthy again once it responds.
+func TestHealthMonitorHungBackend(t *testing.T) {
+	t.Parallel()
+
+	// The stubbed backend hangs until told to respond again, without
+	// honoring the check's context.
+	var checks int32
+	unblock := make(chan struct{})
+	check := func(_ context.Context) error {
+		atomic.AddInt32(&checks, 1)
+		<-unblock
+		return nil
+	}
+
+	sink := &recordingEventSink{}
+	conn := &BitcoindConn{
+		cfg:         BitcoindConfig{EventSink: sink},
+		rawTxCache:  newRawTxCache(0),
+		blockHashes: newBlockHashCache(0, 0),
+	}
+	conn.health = newHealthMonitor("bitcoind", HealthCheckConfig{
+		Interval:    10 * time.Millisecond,
+		Timeout:     10 * time.Millisecond,
+		MaxFailures: 3,
+	}, check, conn.emit)
+
+	healthy, err := conn.IsHealthy()
+	require.True(t, healthy)
+	require.NoError(t, err)
+
+	conn.health.Start()
+	defer conn.health.Stop()
+
+	require.Eventually(t, func() bool {
+		healthy, _ := conn.IsHealthy()
+		return !healthy
+	}, 5*time.Second, 5*time.Millisecond)
+
+	healthy, err = conn.IsHealthy()
+	require.False(t, healthy)
+	require.True(t, errors.Is(err, context.DeadlineExceeded), err)
+
+	stats := conn.Stats()
+	require.False(t, stats.Healthy)
+	require.GreaterOrEqual(t, stats.HealthCheckFailures, 3)
+
+	// The unhealthy transition should have been emitted once.
+	sink.mu.Lock()
+	require.Len(t, sink.events, 1)
+	event, ok := sink.events[0].(*HealthEvent)
+	sink.mu.Unlock()
+	require.True(t, ok)
+	require.Equal(t, "bitcoind", event.Backend)
+	require.False(t, event.Healthy)
+	require.Equal(t, 3, event.ConsecutiveFailures)
+	require.True(t, errors.Is(event.Err, context.DeadlineExceeded))
+
+	// The hung check is awaited rather than checked again.
+	require.EqualValues(t, 1, atomic.LoadInt32(&checks))
+
+	// Once the backend responds again, a single successful check should be
+	// enough for it to be considered healthy.
+	close(unblock)
+	require.Eventually(t, func() bool {
+		healthy, _ := conn.IsHealthy()
+		return healthy
+	}, 5*time.Second, 5*time.Millisecond)
+	require.Zero(t, conn.Stats().HealthCheckFailures)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	require.Len(t, sink.events, 2)
+	require.Equal(t, &HealthEvent{Backend: "bitcoind", Healthy: true},
+		sink.events[1])
+}
+
+// TestHealthCheckEventSink ensures that the HealthEvents of a backend without
+// an EventSink of its own are emitted through the health check's.
+func TestHealthCheckEventSink(t *testing.T) {
+	t.Parallel()
+
+	// The client never connects, so every health check fails.
+	client, err := NewRPCClient(
+		&chaincfg.RegressionNetParams, "127.0.0.1:0", "", "", nil,
+		true, 0,
+	)
+	require.NoError(t, err)
+
+	sink := &recordingEventSink{}
+	client.SetHealthCheckConfig(HealthCheckConfig{
+		Interval:    10 * time.Millisecond,
+		Timeout:     time.Second,
+		MaxFailures: 1,
+		EventSink:   sink,
+	})
+	client.health.Start()
+	defer client.health.Stop()
+
+	require.Eventually(t, func() bool {
+		sink.mu.Lock()
+		defer sink.mu.Unlock()
+		return len(sink.events) > 0
+	}, 5*time.Second, 5*time.Millisecond)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	event, ok := sink.events[0].(*HealthEvent)
+	require.True(t, ok)
+	require.Equal(t, "btcd", event.Backend)
+	require.False(t, event.Healthy)
+}
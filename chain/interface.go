@@ -36,10 +36,12 @@ type Interface interface {
 	GetBlock(*chainhash.Hash) (*wire.MsgBlock, error)
 	GetBlockHash(int64) (*chainhash.Hash, error)
 	GetBlockHeader(*chainhash.Hash) (*wire.BlockHeader, error)
+	BlockHeightForTimestamp(time.Time) (int32, error)
 	IsCurrent() bool
 	FilterBlocks(*FilterBlocksRequest) (*FilterBlocksResponse, error)
 	BlockStamp() (*waddrmgr.BlockStamp, error)
 	SendRawTransaction(*wire.MsgTx, bool) (*chainhash.Hash, error)
+	GetMerkleProof(chainhash.Hash) (*MerkleProof, error)
 	Rescan(*chainhash.Hash, []btcutil.Address, map[wire.OutPoint]btcutil.Address) error
 	NotifyReceived([]btcutil.Address) error
 	NotifyBlocks() error
@@ -68,6 +70,14 @@ type (
 		RelevantTxs []*wtxmgr.TxRecord
 	}
 
+	// FilteredBlocksConnected is a batch of consecutive
+	// FilteredBlockConnected notifications, in the order in which the
+	// blocks were connected, delivered at once to reduce the number of
+	// notifications during rescans.
+	FilteredBlocksConnected struct {
+		Blocks []FilteredBlockConnected
+	}
+
 	// FilterBlocksRequest specifies a range of blocks and the set of
 	// internal and external addresses of interest, indexed by corresponding
 	// scoped-index of the child address. A global set of watched outpoints
@@ -0,0 +1,285 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ErrTxNotConfirmed is returned when a merkle proof is requested for a
+// transaction that has yet to be confirmed within a block of the main chain.
+var ErrTxNotConfirmed = errors.New("transaction not confirmed")
+
+// MerkleProof is an SPV proof of the inclusion of a transaction within a
+// block.
+type MerkleProof struct {
+	// BlockHash is the hash of the block the transaction is included in.
+	BlockHash chainhash.Hash
+
+	// Branch is the merkle branch of the transaction, i.e. the hashes of
+	// the siblings of each node on the path from the transaction to the
+	// merkle root, ordered from the transaction up.
+	Branch []chainhash.Hash
+
+	// TxIndex is the index of the transaction within the block, whose
+	// bits determine on which side each hash of the branch is hashed.
+	TxIndex uint32
+}
+
+// MerkleRoot returns the merkle root the proof commits to for the given
+// transaction.
+func (p *MerkleProof) MerkleRoot(txHash chainhash.Hash) chainhash.Hash {
+	hash := txHash
+	index := p.TxIndex
+	for i := range p.Branch {
+		sibling := &p.Branch[i]
+		if index&1 == 0 {
+			hash = *blockchain.HashMerkleBranches(&hash, sibling)
+		} else {
+			hash = *blockchain.HashMerkleBranches(sibling, &hash)
+		}
+		index >>= 1
+	}
+
+	return hash
+}
+
+// Verify checks that the proof proves the inclusion of the given transaction
+// within the block with the given header.
+func (p *MerkleProof) Verify(txHash chainhash.Hash,
+	header *wire.BlockHeader) error {
+
+	if header.BlockHash() != p.BlockHash {
+		return fmt.Errorf("proof is for block %v, not %v", p.BlockHash,
+			header.BlockHash())
+	}
+	if p.MerkleRoot(txHash) != header.MerkleRoot {
+		return fmt.Errorf("proof of transaction %v doesn't commit to "+
+			"merkle root %v", txHash, header.MerkleRoot)
+	}
+
+	return nil
+}
+
+// newMerkleProof computes the merkle proof of the transaction with the given
+// hash within the block.
+func newMerkleProof(block *wire.MsgBlock,
+	txHash chainhash.Hash) (*MerkleProof, error) {
+
+	level := make([]chainhash.Hash, 0, len(block.Transactions))
+	index := -1
+	for i, tx := range block.Transactions {
+		hash := tx.TxHash()
+		if hash == txHash {
+			index = i
+		}
+		level = append(level, hash)
+	}
+	if index == -1 {
+		return nil, fmt.Errorf("transaction %v not found in block %v",
+			txHash, block.BlockHash())
+	}
+
+	proof := &MerkleProof{
+		BlockHash: block.BlockHash(),
+		TxIndex:   uint32(index),
+	}
+
+	// Walk the tree up to its root, recording the sibling of the node on
+	// the transaction's path at each level. A node without a sibling is
+	// hashed with itself.
+	pos := index
+	for len(level) > 1 {
+		sibling := pos ^ 1
+		if sibling >= len(level) {
+			sibling = pos
+		}
+		proof.Branch = append(proof.Branch, level[sibling])
+
+		next := make([]chainhash.Hash, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			right := i + 1
+			if right >= len(level) {
+				right = i
+			}
+			next = append(next, *blockchain.HashMerkleBranches(
+				&level[i], &level[right],
+			))
+		}
+		level = next
+		pos /= 2
+	}
+
+	return proof, nil
+}
+
+// MerkleMatch is a transaction matched by a merkle block, along with its merkle
+// proof.
+type MerkleMatch struct {
+	// TxHash is the hash of the matched transaction.
+	TxHash chainhash.Hash
+
+	// Proof is the merkle proof of the transaction within the block.
+	Proof MerkleProof
+}
+
+// ExtractMerkleMatches traverses the partial merkle tree of the merkle block,
+// such as one returned by bitcoind's gettxoutproof, returning the merkle root
+// it commits to along with the transactions it matches. Malformed partial
+// merkle trees, including those mutated through identical sibling hashes, are
+// rejected.
+//
+// NOTE: The caller must check the root against the merkle root of the block's
+// header.
+func ExtractMerkleMatches(msg *wire.MsgMerkleBlock) (chainhash.Hash,
+	[]MerkleMatch, error) {
+
+	numTxs := msg.Transactions
+	if numTxs == 0 {
+		return chainhash.Hash{}, nil, errors.New("merkle block has " +
+			"no transactions")
+	}
+	if uint32(len(msg.Hashes)) > numTxs {
+		return chainhash.Hash{}, nil, errors.New("merkle block has " +
+			"more hashes than transactions")
+	}
+	if len(msg.Flags)*8 < len(msg.Hashes) {
+		return chainhash.Hash{}, nil, errors.New("merkle block has " +
+			"too few flag bits")
+	}
+
+	// treeWidth returns the number of nodes at the given height of the
+	// tree, where height 0 holds the transactions themselves.
+	treeWidth := func(height uint32) uint32 {
+		return (numTxs + (1 << height) - 1) >> height
+	}
+
+	blockHash := msg.Header.BlockHash()
+	var (
+		bitsUsed, hashesUsed int
+		matches              []MerkleMatch
+		traverse             func(height, pos uint32) (chainhash.Hash,
+			[]int, error)
+	)
+
+	// traverse returns the hash of the node at the given height and
+	// position, along with the indexes of the matches beneath it. As
+	// nodes are visited depth first, the branch of each match is recorded
+	// from the transaction up.
+	traverse = func(height, pos uint32) (chainhash.Hash, []int, error) {
+		if bitsUsed >= len(msg.Flags)*8 {
+			return chainhash.Hash{}, nil, errors.New("merkle " +
+				"block overflowed its flag bits")
+		}
+		flag := msg.Flags[bitsUsed/8]&(1<<uint(bitsUsed%8)) != 0
+		bitsUsed++
+
+		// Leaves, and nodes with no matches beneath them, have their
+		// hash included directly.
+		if height == 0 || !flag {
+			if hashesUsed >= len(msg.Hashes) {
+				return chainhash.Hash{}, nil, errors.New(
+					"merkle block overflowed its hashes",
+				)
+			}
+			hash := *msg.Hashes[hashesUsed]
+			hashesUsed++
+
+			if height != 0 || !flag {
+				return hash, nil, nil
+			}
+			matches = append(matches, MerkleMatch{
+				TxHash: hash,
+				Proof: MerkleProof{
+					BlockHash: blockHash,
+					TxIndex:   pos,
+				},
+			})
+			return hash, []int{len(matches) - 1}, nil
+		}
+
+		left, inLeft, err := traverse(height-1, pos*2)
+		if err != nil {
+			return chainhash.Hash{}, nil, err
+		}
+		right, inRight := left, []int(nil)
+		if pos*2+1 < treeWidth(height-1) {
+			right, inRight, err = traverse(height-1, pos*2+1)
+			if err != nil {
+				return chainhash.Hash{}, nil, err
+			}
+
+			// Identical siblings allow a tree to be mutated
+			// without changing its root (CVE-2012-2459), so
+			// they're rejected.
+			if right == left {
+				return chainhash.Hash{}, nil, errors.New(
+					"merkle block has identical sibling " +
+						"hashes",
+				)
+			}
+		}
+
+		for _, i := range inLeft {
+			matches[i].Proof.Branch = append(
+				matches[i].Proof.Branch, right,
+			)
+		}
+		for _, i := range inRight {
+			matches[i].Proof.Branch = append(
+				matches[i].Proof.Branch, left,
+			)
+		}
+
+		return *blockchain.HashMerkleBranches(&left, &right),
+			append(inLeft, inRight...), nil
+	}
+
+	var height uint32
+	for treeWidth(height) > 1 {
+		height++
+	}
+	root, _, err := traverse(height, 0)
+	if err != nil {
+		return chainhash.Hash{}, nil, err
+	}
+
+	// All of the flag bits, up to the byte boundary, and all of the hashes
+	// must have been consumed.
+	if (bitsUsed+7)/8 != len(msg.Flags) || hashesUsed != len(msg.Hashes) {
+		return chainhash.Hash{}, nil, errors.New("merkle block has " +
+			"unused flag bits or hashes")
+	}
+
+	return root, matches, nil
+}
+
+// merkleProofFromMerkleBlock extracts the merkle proof of the transaction with
+// the given hash from a merkle block matching it, such as one returned by
+// bitcoind's gettxoutproof. The merkle root committed to by the merkle block's
+// partial merkle tree must match its header.
+func merkleProofFromMerkleBlock(msg *wire.MsgMerkleBlock,
+	txHash chainhash.Hash) (*MerkleProof, error) {
+
+	root, matches, err := ExtractMerkleMatches(msg)
+	if err != nil {
+		return nil, err
+	}
+	if root != msg.Header.MerkleRoot {
+		return nil, errors.New("merkle block doesn't commit to its " +
+			"header's merkle root")
+	}
+
+	for _, match := range matches {
+		if match.TxHash == txHash {
+			proof := match.Proof
+			return &proof, nil
+		}
+	}
+
+	return nil, fmt.Errorf("merkle block doesn't match transaction %v",
+		txHash)
+}
@@ -0,0 +1,209 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/bloom"
+	"github.com/stretchr/testify/require"
+)
+
+// newMerkleTestBlock creates a block with the given number of distinct
+// transactions, committing to their merkle root.
+func newMerkleTestBlock(numTxs int) *wire.MsgBlock {
+	msgBlock := &wire.MsgBlock{
+		Header: wire.BlockHeader{Version: 1},
+	}
+	for i := 0; i < numTxs; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+		tx.AddTxOut(wire.NewTxOut(1000, nil))
+		tx.LockTime = uint32(i)
+		msgBlock.Transactions = append(msgBlock.Transactions, tx)
+	}
+
+	merkles := blockchain.BuildMerkleTreeStore(
+		btcutil.NewBlock(msgBlock).Transactions(), false,
+	)
+	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+
+	return msgBlock
+}
+
+// TestMerkleProof ensures that the merkle proofs computed from blocks and
+// extracted from merkle blocks validate against the block header, and that
+// tampered proofs don't.
+func TestMerkleProof(t *testing.T) {
+	t.Parallel()
+
+	// Odd numbers of transactions exercise the duplication of the last
+	// node of a level.
+	for numTxs := 1; numTxs <= 9; numTxs++ {
+		msgBlock := newMerkleTestBlock(numTxs)
+		block := btcutil.NewBlock(msgBlock)
+
+		header := &msgBlock.Header
+		for i, tx := range msgBlock.Transactions {
+			txHash := tx.TxHash()
+
+			proof, err := newMerkleProof(msgBlock, txHash)
+			require.NoError(t, err)
+			require.EqualValues(t, i, proof.TxIndex)
+			require.NoError(t, proof.Verify(txHash, header))
+
+			// The proof extracted from a merkle block, as returned
+			// by gettxoutproof, should be identical.
+			filter := bloom.NewFilter(
+				1, 0, 0.0001, wire.BloomUpdateNone,
+			)
+			filter.AddHash(&txHash)
+			merkleBlock, _ := bloom.NewMerkleBlock(block, filter)
+			extracted, err := merkleProofFromMerkleBlock(
+				merkleBlock, txHash,
+			)
+			require.NoError(t, err)
+			require.Equal(t, proof, extracted)
+
+			// The proof shouldn't validate for another transaction,
+			// nor once tampered with.
+			otherHash := chainhash.Hash{0x01}
+			require.Error(t, proof.Verify(otherHash, header))
+			if len(proof.Branch) > 0 {
+				proof.Branch[0][0] ^= 0xff
+				require.Error(t, proof.Verify(txHash, header))
+			}
+		}
+
+		// A merkle block not matching the transaction can't prove it.
+		filter := bloom.NewFilter(1, 0, 0.0001, wire.BloomUpdateNone)
+		merkleBlock, _ := bloom.NewMerkleBlock(block, filter)
+		_, err := merkleProofFromMerkleBlock(
+			merkleBlock, msgBlock.Transactions[0].TxHash(),
+		)
+		require.Error(t, err)
+	}
+}
+
+// TestExtractMerkleMatches ensures that the proofs of every transaction matched
+// by a merkle block are extracted, and that malformed merkle blocks are
+// rejected.
+func TestExtractMerkleMatches(t *testing.T) {
+	t.Parallel()
+
+	msgBlock := newMerkleTestBlock(7)
+	block := btcutil.NewBlock(msgBlock)
+
+	newMerkleBlock := func(block *btcutil.Block,
+		txs ...*wire.MsgTx) *wire.MsgMerkleBlock {
+
+		filter := bloom.NewFilter(
+			uint32(len(txs)), 0, 0.0001, wire.BloomUpdateNone,
+		)
+		for _, tx := range txs {
+			txHash := tx.TxHash()
+			filter.AddHash(&txHash)
+		}
+		merkleBlock, _ := bloom.NewMerkleBlock(block, filter)
+		return merkleBlock
+	}
+
+	matched := []*wire.MsgTx{
+		msgBlock.Transactions[1], msgBlock.Transactions[6],
+	}
+	root, matches, err := ExtractMerkleMatches(
+		newMerkleBlock(block, matched...),
+	)
+	require.NoError(t, err)
+	require.Equal(t, msgBlock.Header.MerkleRoot, root)
+	require.Len(t, matches, len(matched))
+	for i, match := range matches {
+		txHash := matched[i].TxHash()
+		require.Equal(t, txHash, match.TxHash)
+		require.NoError(t, match.Proof.Verify(txHash, &msgBlock.Header))
+	}
+
+	// Unused hashes or flag bits, and more hashes than transactions, are
+	// rejected.
+	merkleBlock := newMerkleBlock(block, matched[0])
+	merkleBlock.Hashes = append(merkleBlock.Hashes, &chainhash.Hash{})
+	_, _, err = ExtractMerkleMatches(merkleBlock)
+	require.Error(t, err)
+
+	merkleBlock = newMerkleBlock(block, matched[0])
+	merkleBlock.Flags = append(merkleBlock.Flags, 0)
+	_, _, err = ExtractMerkleMatches(merkleBlock)
+	require.Error(t, err)
+
+	merkleBlock = newMerkleBlock(block, matched[0])
+	merkleBlock.Transactions = uint32(len(merkleBlock.Hashes) - 1)
+	_, _, err = ExtractMerkleMatches(merkleBlock)
+	require.Error(t, err)
+
+	// Duplicating the last transaction of a block with an odd number of
+	// them doesn't change its merkle root (CVE-2012-2459), so a merkle
+	// block proving the duplicate through identical siblings must be
+	// rejected.
+	mutated := *msgBlock
+	mutated.Transactions = append(
+		msgBlock.Transactions[:7:7], msgBlock.Transactions[6],
+	)
+	mutatedBlock := btcutil.NewBlock(&mutated)
+	merkles := blockchain.BuildMerkleTreeStore(
+		mutatedBlock.Transactions(), false,
+	)
+	require.Equal(t, msgBlock.Header.MerkleRoot, *merkles[len(merkles)-1])
+
+	_, _, err = ExtractMerkleMatches(
+		newMerkleBlock(mutatedBlock, msgBlock.Transactions[6]),
+	)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "identical sibling")
+	_, err = merkleProofFromMerkleBlock(
+		newMerkleBlock(mutatedBlock, msgBlock.Transactions[6]),
+		msgBlock.Transactions[6].TxHash(),
+	)
+	require.Error(t, err)
+}
+
+// TestSimClientMerkleProof ensures that the proofs generated by a SimClient
+// validate against the header of the block confirming the transaction, and
+// that mempool transactions can't be proven.
+func TestSimClientMerkleProof(t *testing.T) {
+	t.Parallel()
+
+	c := NewSimClient(&chaincfg.RegressionNetParams)
+	defer c.Stop()
+
+	var txs []*wire.MsgTx
+	for i := 0; i < 3; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(
+			&wire.OutPoint{Hash: chainhash.Hash{byte(i + 1)}}, nil,
+			nil,
+		))
+		tx.AddTxOut(wire.NewTxOut(1000, nil))
+		txs = append(txs, tx)
+	}
+	block := c.ConnectBlock(txs[0], txs[1])
+	blockHash := block.BlockHash()
+
+	header, err := c.GetBlockHeader(&blockHash)
+	require.NoError(t, err)
+	for i, tx := range txs[:2] {
+		proof, err := c.GetMerkleProof(tx.TxHash())
+		require.NoError(t, err)
+		require.Equal(t, blockHash, proof.BlockHash)
+
+		// The coinbase precedes the transactions within the block.
+		require.EqualValues(t, i+1, proof.TxIndex)
+		require.NoError(t, proof.Verify(tx.TxHash(), header))
+	}
+
+	c.AddMempoolTx(txs[2])
+	_, err = c.GetMerkleProof(txs[2].TxHash())
+	require.Equal(t, ErrTxNotConfirmed, err)
+}
@@ -1,6 +1,7 @@
 package chain
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -13,7 +14,6 @@ import (
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcutil/gcs"
-	"github.com/btcsuite/btcutil/gcs/builder"
 	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/wtxmgr"
 	"github.com/lightninglabs/neutrino"
@@ -45,18 +45,319 @@ type NeutrinoClient struct {
 	finished   bool
 	isRescan   bool
 
+	// checkpoints is the set of trusted filter header checkpoints, sorted
+	// by height.
+	checkpoints []FilterHeaderCheckpoint
+
+	// filterHeaders is the filter header store of the chain service, which
+	// the checkpoints are validated against.
+	filterHeaders filterHeaderStore
+
+	// health periodically health checks the chain service.
+	health *healthMonitor
+
+	// timestamps caches the heights resolved by BlockHeightForTimestamp.
+	timestamps timestampResolver
+
+	// fetchLimiter rate limits the filters and blocks fetched from the
+	// chain service.
+	fetchLimiter *fetchRateLimiter
+
+	// broadcasters holds the additional endpoints transactions are
+	// published to.
+	broadcasters broadcastSet
+
+	// blockBatch coalesces the FilteredBlockConnected notifications of
+	// the client's rescans.
+	blockBatch filteredBlockBatch
+
+	// txBlocks maps the hash of each relevant transaction found within a
+	// block by the client to said block, allowing merkle proofs to be
+	// generated for them. Transactions are pruned once their block is
+	// buried deeper than waddrmgr.MaxReorgDepth below the highest block
+	// recorded, as tracked by txBlocksHeight.
+	txBlocks       map[chainhash.Hash]wtxmgr.Block
+	txBlocksHeight int32
+
+	// watchedScripts caches the output scripts of the addresses watched
+	// by the client through its rescans and FilterBlocks requests.
+	watchedScripts watchedScriptSet
+
 	clientMtx sync.Mutex
 }
 
+// ErrFilterHeaderCheckpoint is returned when a filter header synced by the
+// chain service conflicts with one of the client's filter header checkpoints.
+var ErrFilterHeaderCheckpoint = errors.New("filter header conflicts with " +
+	"checkpoint")
+
+// FilterHeaderCheckpoint is a trusted regular filter header at a given height.
+type FilterHeaderCheckpoint struct {
+	// Height is the height of the block the filter header commits to.
+	Height uint32
+
+	// FilterHeader is the expected regular filter header at Height.
+	FilterHeader chainhash.Hash
+}
+
+// NeutrinoClientStats describes the current state of a NeutrinoClient.
+type NeutrinoClientStats struct {
+	// ActiveCheckpointHeight is the height of the latest filter header
+	// checkpoint trusted by the client. This is zero if no checkpoints
+	// have been set.
+	ActiveCheckpointHeight uint32
+
+	// Healthy is whether the chain service is currently considered healthy
+	// by its periodic health check.
+	Healthy bool
+
+	// HealthCheckFailures is the number of consecutive failed health
+	// checks of the chain service.
+	HealthCheckFailures int
+
+	// FetchRateLimit is the rate, in bytes per second, to which fetching
+	// filters and blocks is limited. This is zero if unlimited.
+	FetchRateLimit int64
+
+	// FetchRateUtilization is the fraction, between 0 and 1, of the rate
+	// limiter's burst currently consumed. This is zero if unlimited.
+	FetchRateUtilization float64
+
+	// FetchedBytes is the total number of bytes of the filters and blocks
+	// fetched by the client.
+	FetchedBytes uint64
+
+	// WatchedScripts is the number of distinct output scripts watched by
+	// the client through its rescans and FilterBlocks requests.
+	WatchedScripts int
+}
+
+// filterHeaderStore is the subset of the methods of neutrino's filter header
+// store required to validate filter header checkpoints.
+type filterHeaderStore interface {
+	// FetchHeaderByHeight returns the filter header stored at the given
+	// height.
+	FetchHeaderByHeight(height uint32) (*chainhash.Hash, error)
+}
+
 // NewNeutrinoClient creates a new NeutrinoClient struct with a backing
 // ChainService.
 func NewNeutrinoClient(chainParams *chaincfg.Params,
 	chainService *neutrino.ChainService) *NeutrinoClient {
 
-	return &NeutrinoClient{
-		CS:          chainService,
-		chainParams: chainParams,
+	client := &NeutrinoClient{
+		CS:           chainService,
+		chainParams:  chainParams,
+		fetchLimiter: newFetchRateLimiter(FetchRateLimitConfig{}),
+	}
+	if chainService != nil && chainService.RegFilterHeaders != nil {
+		client.filterHeaders = chainService.RegFilterHeaders
+	}
+	client.health = newHealthMonitor(
+		"neutrino", HealthCheckConfig{}, client.checkHealth,
+		logEventSink{}.OnEvent,
+	)
+
+	return client
+}
+
+// SetHealthCheckConfig configures the periodic health check of the chain
+// service, performed by fetching its header tip. Its HealthEvents are emitted
+// through the config's EventSink, or written to the package logger if unset.
+//
+// NOTE: This must be called before the client is started.
+func (s *NeutrinoClient) SetHealthCheckConfig(cfg HealthCheckConfig) {
+	s.clientMtx.Lock()
+	defer s.clientMtx.Unlock()
+
+	s.health = newHealthMonitor(
+		"neutrino", cfg, s.checkHealth, logEventSink{}.OnEvent,
+	)
+}
+
+// IsHealthy returns whether the chain service is currently considered healthy
+// by its periodic health check. If it isn't, the error of the latest failed
+// health check is returned as well.
+func (s *NeutrinoClient) IsHealthy() (bool, error) {
+	s.clientMtx.Lock()
+	health := s.health
+	s.clientMtx.Unlock()
+
+	return health.isHealthy()
+}
+
+// SetFetchRateLimit configures the rate limit of the filters and blocks fetched
+// from the chain service, both by the client and its rescans. A rate of zero
+// disables rate limiting. The limit can be changed while the client is
+// running.
+func (s *NeutrinoClient) SetFetchRateLimit(cfg FetchRateLimitConfig) {
+	s.clientMtx.Lock()
+	defer s.clientMtx.Unlock()
+
+	if s.fetchLimiter == nil {
+		s.fetchLimiter = newFetchRateLimiter(cfg)
+		return
 	}
+	s.fetchLimiter.setConfig(cfg)
+}
+
+// checkHealth checks whether the chain service is serving data by fetching its
+// header tip, and that the filter headers it synced since don't conflict with
+// the client's checkpoints.
+func (s *NeutrinoClient) checkHealth(_ context.Context) error {
+	if _, err := s.CS.BestBlock(); err != nil {
+		return err
+	}
+
+	return s.verifyFilterHeaderCheckpoints()
+}
+
+// SetBroadcasters sets additional endpoints transactions are published to
+// through SendRawTransaction, alongside the chain service. A transaction is
+// then considered published if any of them accepts it. The chain service
+// remains the source of all notifications.
+func (s *NeutrinoClient) SetBroadcasters(broadcasters ...Broadcaster) {
+	s.broadcasters.set(broadcasters)
+}
+
+// SetRescanBatchSize sets the maximum number of consecutive filtered blocks
+// delivered at once by the client's rescans, through a FilteredBlocksConnected
+// notification. Blocks are delivered as soon as the batch is full, or once a
+// block with relevant transactions is filtered, such that matches are always
+// delivered promptly. The pending batch is also delivered once a block is
+// disconnected or the rescan finishes, while the other notifications of the
+// connected blocks are queued behind it. The default size of 1 delivers each
+// filtered block through its own FilteredBlockConnected notification.
+func (s *NeutrinoClient) SetRescanBatchSize(size int) {
+	s.blockBatch.setSize(size)
+}
+
+// SetFilterHeaderCheckpoints sets the filter header checkpoints trusted by the
+// client. The checkpoints must be provided in strictly increasing height order,
+// and must not conflict with any filter headers already stored by the backing
+// chain service. The filter headers synced by the chain service are validated
+// against them as the client is started, on every health check, and before
+// blocks are filtered through FilterBlocks, which fail with
+// ErrFilterHeaderCheckpoint on a conflict. The checkpoints should also be
+// applied to the chain service's config through ApplyFilterHeaderCheckpoints,
+// such that it discards conflicting filter headers found on disk on startup.
+func (s *NeutrinoClient) SetFilterHeaderCheckpoints(
+	checkpoints []FilterHeaderCheckpoint) error {
+
+	err := validateFilterHeaderCheckpoints(checkpoints, s.filterHeaders)
+	if err != nil {
+		return err
+	}
+
+	s.clientMtx.Lock()
+	s.checkpoints = append([]FilterHeaderCheckpoint(nil), checkpoints...)
+	s.clientMtx.Unlock()
+
+	return nil
+}
+
+// Stats returns the current state of the client.
+func (s *NeutrinoClient) Stats() NeutrinoClientStats {
+	s.clientMtx.Lock()
+	defer s.clientMtx.Unlock()
+
+	var stats NeutrinoClientStats
+	if len(s.checkpoints) > 0 {
+		last := s.checkpoints[len(s.checkpoints)-1]
+		stats.ActiveCheckpointHeight = last.Height
+	}
+	stats.Healthy, stats.HealthCheckFailures, _ = s.health.status()
+	stats.FetchRateLimit, stats.FetchRateUtilization, stats.FetchedBytes =
+		s.fetchLimiter.status()
+	stats.WatchedScripts = s.watchedScripts.size()
+
+	return stats
+}
+
+// AssertFilterHeader returns the filter header assertion for the latest of the
+// given checkpoints, suitable for neutrino's Config.AssertFilterHeader. With
+// it, neutrino trusts the filter headers it has on disk as long as they match
+// the checkpoint, and discards them to be synced anew otherwise. Nil is
+// returned if no checkpoints are provided.
+func AssertFilterHeader(
+	checkpoints []FilterHeaderCheckpoint) *headerfs.FilterHeader {
+
+	if len(checkpoints) == 0 {
+		return nil
+	}
+
+	last := checkpoints[len(checkpoints)-1]
+	return &headerfs.FilterHeader{
+		FilterHash: last.FilterHeader,
+		Height:     last.Height,
+	}
+}
+
+// ApplyFilterHeaderCheckpoints sets the assertion of the latest of the given
+// checkpoints within the config of a chain service to be created, such that the
+// filter headers it finds on disk on startup are discarded to be synced anew if
+// they conflict with it. The checkpoints must be provided in strictly
+// increasing height order.
+func ApplyFilterHeaderCheckpoints(cfg *neutrino.Config,
+	checkpoints []FilterHeaderCheckpoint) error {
+
+	err := validateFilterHeaderCheckpoints(checkpoints, nil)
+	if err != nil {
+		return err
+	}
+
+	cfg.AssertFilterHeader = AssertFilterHeader(checkpoints)
+	return nil
+}
+
+// verifyFilterHeaderCheckpoints ensures the filter headers synced by the chain
+// service don't conflict with the client's checkpoints.
+func (s *NeutrinoClient) verifyFilterHeaderCheckpoints() error {
+	s.clientMtx.Lock()
+	checkpoints := s.checkpoints
+	s.clientMtx.Unlock()
+
+	if len(checkpoints) == 0 || s.filterHeaders == nil {
+		return nil
+	}
+
+	return validateFilterHeaderCheckpoints(checkpoints, s.filterHeaders)
+}
+
+// validateFilterHeaderCheckpoints ensures the checkpoints are sorted by
+// strictly increasing height, and that none of them conflict with the filter
+// headers found within the store, if one is provided.
+func validateFilterHeaderCheckpoints(checkpoints []FilterHeaderCheckpoint,
+	store filterHeaderStore) error {
+
+	for i, checkpoint := range checkpoints {
+		if i > 0 && checkpoint.Height <= checkpoints[i-1].Height {
+			return fmt.Errorf("filter header checkpoint at height "+
+				"%d does not follow checkpoint at height %d",
+				checkpoint.Height, checkpoints[i-1].Height)
+		}
+
+		if store == nil {
+			continue
+		}
+
+		stored, err := store.FetchHeaderByHeight(checkpoint.Height)
+		if _, ok := err.(*headerfs.ErrHeaderNotFound); ok {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if *stored != checkpoint.FilterHeader {
+			return fmt.Errorf("%w: checkpoint %v at height %d, "+
+				"stored filter header %v",
+				ErrFilterHeaderCheckpoint,
+				checkpoint.FilterHeader, checkpoint.Height,
+				stored)
+		}
+	}
+
+	return nil
 }
 
 // BackEnd returns the name of the driver.
@@ -66,6 +367,9 @@ func (s *NeutrinoClient) BackEnd() string {
 
 // Start replicates the RPC client's Start method.
 func (s *NeutrinoClient) Start() error {
+	if err := s.verifyFilterHeaderCheckpoints(); err != nil {
+		return err
+	}
 	if err := s.CS.Start(); err != nil {
 		return fmt.Errorf("error starting chain service: %v", err)
 	}
@@ -86,6 +390,8 @@ func (s *NeutrinoClient) Start() error {
 			}
 		}()
 		go s.notificationHandler()
+
+		s.health.Start()
 	}
 	return nil
 }
@@ -97,6 +403,7 @@ func (s *NeutrinoClient) Stop() {
 	if !s.started {
 		return
 	}
+	s.health.Stop()
 	close(s.quit)
 	s.started = false
 }
@@ -111,7 +418,11 @@ func (s *NeutrinoClient) GetBlock(hash *chainhash.Hash) (*wire.MsgBlock, error)
 	// TODO(roasbeef): add a block cache?
 	//  * which evication strategy? depends on use case
 	//  Should the block cache be INSIDE neutrino instead of in btcwallet?
-	block, err := s.CS.GetBlock(*hash)
+	block, err := s.limiter().fetchBlock(nil, func() (*btcutil.Block,
+		error) {
+
+		return s.CS.GetBlock(*hash)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -161,6 +472,96 @@ func (s *NeutrinoClient) GetBlockHeader(
 	return s.CS.GetBlockHeader(blockHash)
 }
 
+// BlockHeightForTimestamp returns the height of the first block with a
+// timestamp at or after the given one, or the height of the tip if the
+// timestamp is after it. The block is located through a binary search over the
+// chain service's block header store.
+func (s *NeutrinoClient) BlockHeightForTimestamp(ts time.Time) (int32, error) {
+	headers := s.CS.BlockHeaders
+	_, tipHeight, err := headers.ChainTip()
+	if err != nil {
+		return 0, err
+	}
+
+	return s.timestamps.resolve(
+		ts, int32(tipHeight),
+		func(height int32) (*wire.BlockHeader, error) {
+			return headers.FetchHeaderByHeight(uint32(height))
+		},
+	)
+}
+
+// GetMerkleProof returns a merkle proof of the inclusion of the transaction
+// within its block, computed from the block fetched through the chain
+// service. As neutrino has no transaction index, only the relevant
+// transactions found within blocks by the client since it was created can be
+// located, and only for as long as their block is within
+// waddrmgr.MaxReorgDepth blocks of the highest one they were found within.
+// ErrTxNotConfirmed is returned if the block of the transaction is no longer
+// part of the main chain.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (s *NeutrinoClient) GetMerkleProof(txHash chainhash.Hash) (*MerkleProof,
+	error) {
+
+	s.clientMtx.Lock()
+	txBlock, ok := s.txBlocks[txHash]
+	s.clientMtx.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("block of transaction %v is unknown",
+			txHash)
+	}
+
+	blockHash := txBlock.Hash
+	height, err := s.CS.GetBlockHeight(&blockHash)
+	if err != nil {
+		return nil, ErrTxNotConfirmed
+	}
+	mainChainHash, err := s.CS.GetBlockHash(int64(height))
+	if err != nil {
+		return nil, err
+	}
+	if *mainChainHash != blockHash {
+		return nil, ErrTxNotConfirmed
+	}
+
+	block, err := s.GetBlock(&blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMerkleProof(block, txHash)
+}
+
+// recordTxBlock records the block in which a relevant transaction was found,
+// pruning the transactions whose block is now buried below the reorg safety
+// depth.
+func (s *NeutrinoClient) recordTxBlock(txHash chainhash.Hash,
+	block wtxmgr.Block) {
+
+	s.clientMtx.Lock()
+	defer s.clientMtx.Unlock()
+
+	if s.txBlocks == nil {
+		s.txBlocks = make(map[chainhash.Hash]wtxmgr.Block)
+	}
+	s.txBlocks[txHash] = block
+
+	// The transactions only need to be checked again once a higher block
+	// is recorded.
+	if block.Height <= s.txBlocksHeight {
+		return
+	}
+	s.txBlocksHeight = block.Height
+
+	pruneHeight := block.Height - waddrmgr.MaxReorgDepth
+	for hash, txBlock := range s.txBlocks {
+		if txBlock.Height < pruneHeight {
+			delete(s.txBlocks, hash)
+		}
+	}
+}
+
 // IsCurrent returns whether the chain backend considers its view of the network
 // as "current".
 func (s *NeutrinoClient) IsCurrent() bool {
@@ -168,8 +569,17 @@ func (s *NeutrinoClient) IsCurrent() bool {
 }
 
 // SendRawTransaction replicates the RPC client's SendRawTransaction command.
+// The transaction is also published to the client's broadcasters, if any.
 func (s *NeutrinoClient) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (
 	*chainhash.Hash, error) {
+
+	return s.broadcasters.broadcast(tx, allowHighFees, s.sendTransaction)
+}
+
+// sendTransaction publishes the transaction through the chain service.
+func (s *NeutrinoClient) sendTransaction(tx *wire.MsgTx, _ bool) (
+	*chainhash.Hash, error) {
+
 	err := s.CS.SendTransaction(tx)
 	if err != nil {
 		return nil, err
@@ -188,11 +598,19 @@ func (s *NeutrinoClient) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool)
 func (s *NeutrinoClient) FilterBlocks(
 	req *FilterBlocksRequest) (*FilterBlocksResponse, error) {
 
+	// The filters are validated against the filter headers of the chain
+	// service, so they can't be trusted if those conflict with the
+	// checkpoints.
+	if err := s.verifyFilterHeaderCheckpoints(); err != nil {
+		return nil, err
+	}
+
 	blockFilterer := NewBlockFilterer(s.chainParams, req)
 
 	// Construct the watchlist using the addresses and outpoints contained
-	// in the filter blocks request.
-	watchList, err := buildFilterBlocksWatchList(req)
+	// in the filter blocks request, whose scripts are cached across
+	// requests.
+	watchList, err := s.filterBlocksQuerySet(req)
 	if err != nil {
 		return nil, err
 	}
@@ -217,8 +635,7 @@ func (s *NeutrinoClient) FilterBlocks(
 			continue
 		}
 
-		key := builder.DeriveKey(&blk.Hash)
-		matched, err := filter.MatchAny(key, watchList)
+		matched, err := matchBlockFilter(filter, &blk.Hash, watchList)
 		if err != nil {
 			return nil, err
 		} else if !matched {
@@ -244,6 +661,10 @@ func (s *NeutrinoClient) FilterBlocks(
 		// windows can widened with subsequent addresses. The
 		// `BatchIndex` is returned so that the caller can compute the
 		// *next* block from which to begin again.
+		for _, tx := range blockFilterer.RelevantTxns {
+			s.recordTxBlock(tx.TxHash(), blk.Block)
+		}
+
 		resp := &FilterBlocksResponse{
 			BatchIndex:         uint32(i),
 			BlockMeta:          blk,
@@ -304,6 +725,29 @@ func buildFilterBlocksWatchList(req *FilterBlocksRequest) ([][]byte, error) {
 	return watchList, nil
 }
 
+// filterBlocksQuerySet returns the output scripts of all external addresses,
+// internal addresses, and outpoints contained in the FilterBlocksRequest
+// through the client's watched script set.
+func (s *NeutrinoClient) filterBlocksQuerySet(
+	req *FilterBlocksRequest) ([][]byte, error) {
+
+	addrs := make(
+		[]btcutil.Address, 0, len(req.ExternalAddrs)+
+			len(req.InternalAddrs)+len(req.WatchedOutPoints),
+	)
+	for _, addr := range req.ExternalAddrs {
+		addrs = append(addrs, addr)
+	}
+	for _, addr := range req.InternalAddrs {
+		addrs = append(addrs, addr)
+	}
+	for _, addr := range req.WatchedOutPoints {
+		addrs = append(addrs, addr)
+	}
+
+	return s.watchedScripts.querySet(addrs...)
+}
+
 // pollCFilter attempts to fetch a CFilter from the neutrino client. This is
 // used to get around the fact that the filter headers may lag behind the
 // highest known block header.
@@ -320,9 +764,14 @@ func (s *NeutrinoClient) pollCFilter(hash *chainhash.Hash) (*gcs.Filter, error)
 			time.Sleep(100 * time.Millisecond)
 		}
 
-		filter, err = s.CS.GetCFilter(
-			*hash, wire.GCSFilterRegular, neutrino.OptimisticBatch(),
-		)
+		filter, err = s.limiter().fetchCFilter(nil, func() (*gcs.Filter,
+			error) {
+
+			return s.CS.GetCFilter(
+				*hash, wire.GCSFilterRegular,
+				neutrino.OptimisticBatch(),
+			)
+		})
 		if err != nil {
 			count++
 			continue
@@ -334,6 +783,29 @@ func (s *NeutrinoClient) pollCFilter(hash *chainhash.Hash) (*gcs.Filter, error)
 	return nil, err
 }
 
+// limiter returns the client's fetch rate limiter.
+func (s *NeutrinoClient) limiter() *fetchRateLimiter {
+	s.clientMtx.Lock()
+	defer s.clientMtx.Unlock()
+
+	return s.fetchLimiter
+}
+
+// rescanChainSource returns the chain source of a new rescan, whose filters and
+// blocks are fetched through the client's rate limiter.
+//
+// NOTE: This must be called with the client's mutex held, after the rescan's
+// quit channel has been created.
+func (s *NeutrinoClient) rescanChainSource() neutrino.ChainSource {
+	return &rateLimitedChainSource{
+		ChainSource: &neutrino.RescanChainSource{
+			ChainService: s.CS,
+		},
+		limiter: s.fetchLimiter,
+		quit:    s.rescanQuit,
+	}
+}
+
 // Rescan replicates the RPC client's Rescan command.
 func (s *NeutrinoClient) Rescan(startHash *chainhash.Hash, addrs []btcutil.Address,
 	outPoints map[wire.OutPoint]btcutil.Address) error {
@@ -354,6 +826,10 @@ func (s *NeutrinoClient) Rescan(startHash *chainhash.Hash, addrs []btcutil.Addre
 		s.rescan = nil
 		s.rescanErr = nil
 	}
+
+	// The blocks pending delivery from the previous rescan, if any, will
+	// be notified again by the new one.
+	s.blockBatch.reset()
 	s.rescanQuit = make(chan struct{})
 	s.scanning = true
 	s.finished = false
@@ -397,12 +873,17 @@ func (s *NeutrinoClient) Rescan(startHash *chainhash.Hash, addrs []btcutil.Addre
 		}
 	}
 
+	if _, err := s.watchedScripts.querySet(addrs...); err != nil {
+		return err
+	}
+
 	var inputsToWatch []neutrino.InputWithScript
 	for op, addr := range outPoints {
-		addrScript, err := txscript.PayToAddrScript(addr)
+		scripts, err := s.watchedScripts.querySet(addr)
 		if err != nil {
 			return err
 		}
+		addrScript := scripts[0]
 
 		inputsToWatch = append(inputsToWatch, neutrino.InputWithScript{
 			OutPoint: op,
@@ -412,9 +893,7 @@ func (s *NeutrinoClient) Rescan(startHash *chainhash.Hash, addrs []btcutil.Addre
 
 	s.clientMtx.Lock()
 	newRescan := neutrino.NewRescan(
-		&neutrino.RescanChainSource{
-			ChainService: s.CS,
-		},
+		s.rescanChainSource(),
 		neutrino.NotificationHandlers(rpcclient.NotificationHandlers{
 			OnBlockConnected:         s.onBlockConnected,
 			OnFilteredBlockConnected: s.onFilteredBlockConnected,
@@ -448,6 +927,10 @@ func (s *NeutrinoClient) NotifyBlocks() error {
 
 // NotifyReceived replicates the RPC client's NotifyReceived command.
 func (s *NeutrinoClient) NotifyReceived(addrs []btcutil.Address) error {
+	if _, err := s.watchedScripts.querySet(addrs...); err != nil {
+		return err
+	}
+
 	s.clientMtx.Lock()
 
 	// If we have a rescan running, we just need to add the appropriate
@@ -467,9 +950,7 @@ func (s *NeutrinoClient) NotifyReceived(addrs []btcutil.Address) error {
 
 	// Rescan with just the specified addresses.
 	newRescan := neutrino.NewRescan(
-		&neutrino.RescanChainSource{
-			ChainService: s.CS,
-		},
+		s.rescanChainSource(),
 		neutrino.NotificationHandlers(rpcclient.NotificationHandlers{
 			OnBlockConnected:         s.onBlockConnected,
 			OnFilteredBlockConnected: s.onFilteredBlockConnected,
@@ -517,6 +998,8 @@ func (s *NeutrinoClient) onFilteredBlockConnected(height int32,
 		},
 	}
 	for _, tx := range relevantTxs {
+		s.recordTxBlock(*tx.Hash(), ntfn.Block.Block)
+
 		rec, err := wtxmgr.NewTxRecordFromMsgTx(tx.MsgTx(),
 			header.Timestamp)
 		if err != nil {
@@ -528,11 +1011,7 @@ func (s *NeutrinoClient) onFilteredBlockConnected(height int32,
 		ntfn.RelevantTxs = append(ntfn.RelevantTxs, rec)
 	}
 
-	select {
-	case s.enqueueNotification <- ntfn:
-	case <-s.quit:
-		return
-	case <-s.rescanQuit:
+	if !s.queueFilteredBlock(ntfn) {
 		return
 	}
 
@@ -548,6 +1027,11 @@ func (s *NeutrinoClient) onFilteredBlockConnected(height int32,
 // channel.
 func (s *NeutrinoClient) onBlockDisconnected(hash *chainhash.Hash, height int32,
 	t time.Time) {
+	s.timestamps.blockDisconnected(height)
+
+	if !s.flushFilteredBlocks() {
+		return
+	}
 	select {
 	case s.enqueueNotification <- BlockDisconnected{
 		Block: wtxmgr.Block{
@@ -563,18 +1047,17 @@ func (s *NeutrinoClient) onBlockDisconnected(hash *chainhash.Hash, height int32,
 
 func (s *NeutrinoClient) onBlockConnected(hash *chainhash.Hash, height int32,
 	time time.Time) {
+	// The notifications of the block are queued behind the filtered blocks
+	// pending delivery, such that they follow them.
+	//
 	// TODO: Move this closure out and parameterize it? Is it useful
 	// outside here?
 	sendRescanProgress := func() {
-		select {
-		case s.enqueueNotification <- &RescanProgress{
+		s.queueNotification(&RescanProgress{
 			Hash:   hash,
 			Height: height,
 			Time:   time,
-		}:
-		case <-s.quit:
-		case <-s.rescanQuit:
-		}
+		})
 	}
 	// Only send BlockConnected notification if we're processing blocks
 	// before the birthday. Otherwise, we can just update using
@@ -604,17 +1087,13 @@ func (s *NeutrinoClient) onBlockConnected(hash *chainhash.Hash, height int32,
 			}
 		}
 		s.clientMtx.Unlock()
-		select {
-		case s.enqueueNotification <- BlockConnected{
+		s.queueNotification(BlockConnected{
 			Block: wtxmgr.Block{
 				Hash:   *hash,
 				Height: height,
 			},
 			Time: time,
-		}:
-		case <-s.quit:
-		case <-s.rescanQuit:
-		}
+		})
 	}
 
 	// Check if we're able to dispatch our final RescanFinished notification
@@ -627,6 +1106,17 @@ func (s *NeutrinoClient) onBlockConnected(hash *chainhash.Hash, height int32,
 // chain. If the notification has already been dispatched, then it won't be done
 // again.
 func (s *NeutrinoClient) dispatchRescanFinished() {
+	// Only send the RescanFinished notification once, and only after the
+	// progress of the rescan past the birthday has been sent.
+	s.clientMtx.Lock()
+	if s.lastFilteredBlockHeader == nil || s.finished ||
+		!s.lastProgressSent {
+
+		s.clientMtx.Unlock()
+		return
+	}
+	s.clientMtx.Unlock()
+
 	bs, err := s.CS.BestBlock()
 	if err != nil {
 		log.Errorf("Can't get chain service's best block: %s", err)
@@ -634,7 +1124,6 @@ func (s *NeutrinoClient) dispatchRescanFinished() {
 	}
 
 	s.clientMtx.Lock()
-	// Only send the RescanFinished notification once.
 	if s.lastFilteredBlockHeader == nil || s.finished {
 		s.clientMtx.Unlock()
 		return
@@ -656,6 +1145,9 @@ func (s *NeutrinoClient) dispatchRescanFinished() {
 	header := s.lastFilteredBlockHeader
 	s.clientMtx.Unlock()
 
+	if !s.flushFilteredBlocks() {
+		return
+	}
 	select {
 	case s.enqueueNotification <- &RescanFinished{
 		Hash:   &bs.Hash,
@@ -0,0 +1,160 @@
+package chain
+
+import "sync"
+
+// filteredBlockBatch accumulates the FilteredBlockConnected notifications of
+// consecutive blocks, such that they can be delivered at once. The other
+// notifications of the blocks, such as their BlockConnected notifications, are
+// queued behind the batch while it's pending, such that they follow it.
+type filteredBlockBatch struct {
+	mtx      sync.Mutex
+	size     int
+	pending  []FilteredBlockConnected
+	trailing []interface{}
+}
+
+// setSize sets the maximum number of blocks of the batch. Sizes below 1 are
+// treated as 1, which disables batching.
+func (b *filteredBlockBatch) setSize(size int) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.size = size
+}
+
+// reset drops the blocks and notifications pending delivery.
+func (b *filteredBlockBatch) reset() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.pending = nil
+	b.trailing = nil
+}
+
+// add adds the block to the batch, returning the blocks and trailing
+// notifications to deliver if the batch is full, the block has relevant
+// transactions or flush is set.
+func (b *filteredBlockBatch) add(ntfn FilteredBlockConnected,
+	flush bool) ([]FilteredBlockConnected, []interface{}) {
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.pending = append(b.pending, ntfn)
+	if !flush && len(b.pending) < b.size && len(ntfn.RelevantTxs) == 0 {
+		return nil, nil
+	}
+
+	return b.take()
+}
+
+// queueBehind queues the notification behind the batch, returning false if no
+// blocks are pending delivery, in which case it should be delivered right
+// away.
+func (b *filteredBlockBatch) queueBehind(ntfn interface{}) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if len(b.pending) == 0 {
+		return false
+	}
+	b.trailing = append(b.trailing, ntfn)
+
+	return true
+}
+
+// flush returns the blocks and trailing notifications pending delivery,
+// emptying the batch.
+func (b *filteredBlockBatch) flush() ([]FilteredBlockConnected,
+	[]interface{}) {
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.take()
+}
+
+// take returns the blocks and trailing notifications pending delivery,
+// emptying the batch.
+//
+// NOTE: This must be called with the mutex held.
+func (b *filteredBlockBatch) take() ([]FilteredBlockConnected,
+	[]interface{}) {
+
+	blocks, trailing := b.pending, b.trailing
+	b.pending, b.trailing = nil, nil
+
+	return blocks, trailing
+}
+
+// queueFilteredBlock queues the notification of a filtered block, delivering
+// the pending batch of blocks if it's complete. Blocks are only batched while
+// the rescan is catching up with the chain, such that once it has finished,
+// each block is delivered as soon as it's connected. False is returned if the
+// client or its rescan is shutting down.
+func (s *NeutrinoClient) queueFilteredBlock(
+	ntfn FilteredBlockConnected) bool {
+
+	s.clientMtx.Lock()
+	finished := s.finished
+	s.clientMtx.Unlock()
+
+	return s.deliverFilteredBlocks(s.blockBatch.add(ntfn, finished))
+}
+
+// flushFilteredBlocks delivers the pending batch of filtered blocks, if any.
+// False is returned if the client or its rescan is shutting down.
+func (s *NeutrinoClient) flushFilteredBlocks() bool {
+	return s.deliverFilteredBlocks(s.blockBatch.flush())
+}
+
+// deliverFilteredBlocks delivers the notifications of the given filtered
+// blocks, followed by the notifications queued behind them. A single block is
+// delivered through its FilteredBlockConnected notification, while multiple
+// blocks are delivered through a FilteredBlocksConnected notification. False
+// is returned if the client or its rescan is shutting down.
+func (s *NeutrinoClient) deliverFilteredBlocks(blocks []FilteredBlockConnected,
+	trailing []interface{}) bool {
+
+	var ntfns []interface{}
+	switch {
+	case len(blocks) == 1:
+		ntfns = append(ntfns, blocks[0])
+
+	case len(blocks) > 1:
+		ntfns = append(ntfns, FilteredBlocksConnected{Blocks: blocks})
+	}
+	ntfns = append(ntfns, trailing...)
+
+	for _, ntfn := range ntfns {
+		if !s.deliverNotification(ntfn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// queueNotification delivers a notification of the rescan, queueing it behind
+// the pending batch of filtered blocks if any, such that it follows them.
+// False is returned if the client or its rescan is shutting down.
+func (s *NeutrinoClient) queueNotification(ntfn interface{}) bool {
+	if s.blockBatch.queueBehind(ntfn) {
+		return true
+	}
+
+	return s.deliverNotification(ntfn)
+}
+
+// deliverNotification delivers the notification. False is returned if the
+// client or its rescan is shutting down.
+func (s *NeutrinoClient) deliverNotification(ntfn interface{}) bool {
+	select {
+	case s.enqueueNotification <- ntfn:
+		return true
+	case <-s.quit:
+		return false
+	case <-s.rescanQuit:
+		return false
+	}
+}
@@ -0,0 +1,200 @@
+package chain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFilteredBlockBatching ensures that the filtered blocks of a rescan are
+// batched up to the configured size, with blocks containing relevant
+// transactions flushing the batch immediately.
+func TestFilteredBlockBatching(t *testing.T) {
+	t.Parallel()
+
+	client := &NeutrinoClient{
+		enqueueNotification: make(chan interface{}, 10),
+		quit:                make(chan struct{}),
+		rescanQuit:          make(chan struct{}),
+	}
+
+	var height int32
+	queue := func(relevant bool) FilteredBlockConnected {
+		t.Helper()
+
+		height++
+		ntfn := FilteredBlockConnected{
+			Block: &wtxmgr.BlockMeta{
+				Block: wtxmgr.Block{Height: height},
+			},
+		}
+		if relevant {
+			rec, err := wtxmgr.NewTxRecordFromMsgTx(
+				&wire.MsgTx{Version: 1}, ntfn.Block.Time,
+			)
+			require.NoError(t, err)
+			ntfn.RelevantTxs = []*wtxmgr.TxRecord{rec}
+		}
+		require.True(t, client.queueFilteredBlock(ntfn))
+
+		return ntfn
+	}
+	requireNotification := func(expected interface{}) {
+		t.Helper()
+
+		require.Len(t, client.enqueueNotification, 1)
+		require.Equal(t, expected, <-client.enqueueNotification)
+	}
+
+	// By default, each block is delivered on its own.
+	requireNotification(queue(false))
+	requireNotification(queue(true))
+
+	// Empty blocks are batched until the batch is full.
+	client.SetRescanBatchSize(3)
+	first, second := queue(false), queue(false)
+	require.Empty(t, client.enqueueNotification)
+	third := queue(false)
+	requireNotification(FilteredBlocksConnected{
+		Blocks: []FilteredBlockConnected{first, second, third},
+	})
+
+	// A block with relevant transactions flushes the batch.
+	empty := queue(false)
+	require.Empty(t, client.enqueueNotification)
+	matching := queue(true)
+	requireNotification(FilteredBlocksConnected{
+		Blocks: []FilteredBlockConnected{empty, matching},
+	})
+
+	// Pending blocks are delivered when flushed, a single one through its
+	// own notification.
+	empty = queue(false)
+	require.Empty(t, client.enqueueNotification)
+	require.True(t, client.flushFilteredBlocks())
+	requireNotification(empty)
+
+	require.True(t, client.flushFilteredBlocks())
+	require.Empty(t, client.enqueueNotification)
+
+	// Pending blocks are dropped once reset.
+	queue(false)
+	client.blockBatch.reset()
+	require.True(t, client.flushFilteredBlocks())
+	require.Empty(t, client.enqueueNotification)
+}
+
+// TestFilteredBlockBatchCallbackOrder ensures that the notifications of the
+// blocks connected by a rescan catching up with the chain, notified through
+// its callbacks in the order neutrino invokes them, are queued behind the
+// pending batch of filtered blocks rather than flushing it, while blocks are
+// no longer batched once the rescan has finished.
+func TestFilteredBlockBatchCallbackOrder(t *testing.T) {
+	t.Parallel()
+
+	// The rescan is catching up without reporting its progress, such that
+	// no RescanFinished notification is dispatched.
+	client := &NeutrinoClient{
+		enqueueNotification: make(chan interface{}, 10),
+		quit:                make(chan struct{}),
+		rescanQuit:          make(chan struct{}),
+	}
+	client.SetRescanBatchSize(3)
+
+	var (
+		height   int32
+		prevHash chainhash.Hash
+	)
+	connect := func(relevant bool) (FilteredBlockConnected,
+		BlockConnected) {
+
+		t.Helper()
+
+		height++
+		header := &wire.BlockHeader{
+			PrevBlock: prevHash,
+			Timestamp: time.Unix(int64(height)*600, 0),
+		}
+		prevHash = header.BlockHash()
+
+		var relevantTxs []*btcutil.Tx
+		if relevant {
+			relevantTxs = append(relevantTxs, btcutil.NewTx(
+				&wire.MsgTx{Version: 1},
+			))
+		}
+		client.onFilteredBlockConnected(height, header, relevantTxs)
+		client.onBlockConnected(&prevHash, height, header.Timestamp)
+
+		filtered := FilteredBlockConnected{
+			Block: &wtxmgr.BlockMeta{
+				Block: wtxmgr.Block{
+					Hash:   prevHash,
+					Height: height,
+				},
+				Time: header.Timestamp,
+			},
+		}
+		for _, tx := range relevantTxs {
+			rec, err := wtxmgr.NewTxRecordFromMsgTx(
+				tx.MsgTx(), header.Timestamp,
+			)
+			require.NoError(t, err)
+			filtered.RelevantTxs = append(filtered.RelevantTxs, rec)
+		}
+
+		return filtered, BlockConnected(*filtered.Block)
+	}
+	requireNotifications := func(expected ...interface{}) {
+		t.Helper()
+
+		require.Len(t, client.enqueueNotification, len(expected))
+		for _, ntfn := range expected {
+			require.Equal(t, ntfn, <-client.enqueueNotification)
+		}
+	}
+
+	// The BlockConnected notifications of the blocks pending delivery
+	// follow the batch once it's full.
+	filtered1, connected1 := connect(false)
+	filtered2, connected2 := connect(false)
+	requireNotifications()
+	filtered3, connected3 := connect(false)
+	requireNotifications(
+		FilteredBlocksConnected{
+			Blocks: []FilteredBlockConnected{
+				filtered1, filtered2, filtered3,
+			},
+		},
+		connected1, connected2, connected3,
+	)
+
+	// A block with relevant transactions is delivered right away.
+	filtered4, connected4 := connect(true)
+	requireNotifications(filtered4, connected4)
+
+	// Disconnecting a block delivers the pending batch, and the
+	// notifications queued behind it, first.
+	filtered5, connected5 := connect(false)
+	requireNotifications()
+	client.onBlockDisconnected(&prevHash, 5, time.Time{})
+	requireNotifications(
+		filtered5, connected5, BlockDisconnected{
+			Block: wtxmgr.Block{Hash: prevHash, Height: 5},
+		},
+	)
+
+	// Once the rescan has finished, each block is delivered as soon as
+	// it's connected.
+	client.clientMtx.Lock()
+	client.finished = true
+	client.clientMtx.Unlock()
+
+	filtered6, connected6 := connect(false)
+	requireNotifications(filtered6, connected6)
+}
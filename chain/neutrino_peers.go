@@ -0,0 +1,152 @@
+package chain
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/lightninglabs/neutrino"
+)
+
+// TrustedPeersConfig contains the peers a neutrino chain service connects to
+// on startup, bypassing the discovery of peers through DNS seeds when used
+// exclusively.
+type TrustedPeersConfig struct {
+	// Peers are the addresses of the trusted peers, as a host or IP with an
+	// optional port, which defaults to the one of the network.
+	Peers []string
+
+	// Exclusive restricts the chain service to the trusted peers. No other
+	// peer is ever dialed, and DNS seeds aren't queried.
+	Exclusive bool
+}
+
+// Apply configures the given chain service config to connect to the trusted
+// peers first, or exclusively. Without the exclusive flag, the trusted peers
+// are connected to on startup alongside those specified by the config, and
+// peers are still discovered through DNS seeds. In exclusive mode, the
+// trusted peers replace the peers of the config, and its dialer and name
+// resolver are wrapped such that only the trusted peers can be resolved and
+// dialed, so that the chain service can't connect anywhere else even if it
+// learns about other peers.
+func (c TrustedPeersConfig) Apply(cfg *neutrino.Config) error {
+	if !c.Exclusive {
+		cfg.AddPeers = append(cfg.AddPeers, c.Peers...)
+		return nil
+	}
+
+	if len(c.Peers) == 0 {
+		return fmt.Errorf("exclusive mode requires at least one " +
+			"trusted peer")
+	}
+
+	filter, err := newTrustedPeerFilter(
+		c.Peers, cfg.ChainParams.DefaultPort, cfg.NameResolver,
+		cfg.Dialer,
+	)
+	if err != nil {
+		return err
+	}
+
+	cfg.ConnectPeers = append([]string(nil), c.Peers...)
+	cfg.AddPeers = nil
+	cfg.NameResolver = filter.resolve
+	cfg.Dialer = filter.dial
+
+	return nil
+}
+
+// trustedPeerFilter restricts the name resolution and dialing of a chain
+// service to a set of trusted peers.
+type trustedPeerFilter struct {
+	resolver func(string) ([]net.IP, error)
+	dialer   func(net.Addr) (net.Conn, error)
+
+	// ports maps the host of each trusted peer to its ports.
+	ports map[string][]string
+
+	// mtx guards allowed.
+	mtx sync.Mutex
+
+	// allowed is the set of addresses, as IP and port, the trusted peers
+	// have been resolved to.
+	allowed map[string]struct{}
+}
+
+// newTrustedPeerFilter creates a filter for the given peers, resolving and
+// dialing them through the given functions, which default to those of the
+// net package if nil.
+func newTrustedPeerFilter(peers []string, defaultPort string,
+	resolver func(string) ([]net.IP, error),
+	dialer func(net.Addr) (net.Conn, error)) (*trustedPeerFilter, error) {
+
+	if resolver == nil {
+		resolver = net.LookupIP
+	}
+	if dialer == nil {
+		dialer = func(addr net.Addr) (net.Conn, error) {
+			return net.Dial(addr.Network(), addr.String())
+		}
+	}
+
+	// The peers are parsed as the chain service would.
+	ports := make(map[string][]string, len(peers))
+	for _, peer := range peers {
+		host, port, err := net.SplitHostPort(peer)
+		if err != nil {
+			if _, ok := err.(*net.AddrError); !ok {
+				return nil, fmt.Errorf("invalid trusted peer "+
+					"%v: %w", peer, err)
+			}
+			host, port = peer, defaultPort
+		}
+		ports[host] = append(ports[host], port)
+	}
+
+	return &trustedPeerFilter{
+		resolver: resolver,
+		dialer:   dialer,
+		ports:    ports,
+		allowed:  make(map[string]struct{}),
+	}, nil
+}
+
+// resolve resolves the host of a trusted peer, allowing the resulting
+// addresses to be dialed. Any other host, such as those of DNS seeds, is
+// refused.
+func (f *trustedPeerFilter) resolve(host string) ([]net.IP, error) {
+	ports, ok := f.ports[host]
+	if !ok {
+		return nil, fmt.Errorf("host %v isn't a trusted peer", host)
+	}
+
+	ips, err := f.resolver(host)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	for _, ip := range ips {
+		for _, port := range ports {
+			f.allowed[net.JoinHostPort(ip.String(), port)] =
+				struct{}{}
+		}
+	}
+
+	return ips, nil
+}
+
+// dial dials the given address if a trusted peer has been resolved to it.
+func (f *trustedPeerFilter) dial(addr net.Addr) (net.Conn, error) {
+	f.mtx.Lock()
+	_, ok := f.allowed[addr.String()]
+	f.mtx.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("address %v isn't a trusted peer", addr)
+	}
+
+	return f.dialer(addr)
+}
@@ -0,0 +1,177 @@
+package chain
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcwallet/walletdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
+	"github.com/lightninglabs/neutrino"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingNet resolves and dials through the net package, recording the
+// hosts resolved and the addresses dialed.
+type recordingNet struct {
+	mtx      sync.Mutex
+	resolved []string
+	dialed   []string
+}
+
+func (r *recordingNet) resolve(host string) ([]net.IP, error) {
+	r.mtx.Lock()
+	r.resolved = append(r.resolved, host)
+	r.mtx.Unlock()
+
+	return net.LookupIP(host)
+}
+
+func (r *recordingNet) dial(addr net.Addr) (net.Conn, error) {
+	r.mtx.Lock()
+	r.dialed = append(r.dialed, addr.String())
+	r.mtx.Unlock()
+
+	return net.Dial(addr.Network(), addr.String())
+}
+
+func (r *recordingNet) history() ([]string, []string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return append([]string(nil), r.resolved...),
+		append([]string(nil), r.dialed...)
+}
+
+// TestTrustedPeersConfig ensures that trusted peers are added to the peers of
+// a chain service config, and that they replace them in exclusive mode.
+func TestTrustedPeersConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := neutrino.Config{
+		ChainParams: chaincfg.TestNet3Params,
+		AddPeers:    []string{"10.0.0.1"},
+	}
+	trusted := TrustedPeersConfig{Peers: []string{"127.0.0.1:18333"}}
+	require.NoError(t, trusted.Apply(&cfg))
+	require.Equal(t, []string{"10.0.0.1", "127.0.0.1:18333"}, cfg.AddPeers)
+	require.Empty(t, cfg.ConnectPeers)
+	require.Nil(t, cfg.Dialer)
+	require.Nil(t, cfg.NameResolver)
+
+	// Exclusive mode requires trusted peers.
+	cfg = neutrino.Config{ChainParams: chaincfg.TestNet3Params}
+	require.Error(t, TrustedPeersConfig{Exclusive: true}.Apply(&cfg))
+
+	// Only the trusted peers can be resolved and dialed in exclusive
+	// mode, with the network's default port used if none is specified.
+	var recorder recordingNet
+	cfg = neutrino.Config{
+		ChainParams:  chaincfg.TestNet3Params,
+		AddPeers:     []string{"10.0.0.1"},
+		Dialer:       recorder.dial,
+		NameResolver: recorder.resolve,
+	}
+	trusted = TrustedPeersConfig{
+		Peers:     []string{"127.0.0.1"},
+		Exclusive: true,
+	}
+	require.NoError(t, trusted.Apply(&cfg))
+	require.Equal(t, []string{"127.0.0.1"}, cfg.ConnectPeers)
+	require.Empty(t, cfg.AddPeers)
+
+	_, err := cfg.NameResolver("testnet-seed.bitcoin.jonasschnelli.ch")
+	require.Error(t, err)
+	ips, err := cfg.NameResolver("127.0.0.1")
+	require.NoError(t, err)
+	require.NotEmpty(t, ips)
+
+	_, err = cfg.Dialer(&net.TCPAddr{IP: ips[0], Port: 8333})
+	require.Error(t, err)
+	_, err = cfg.Dialer(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"),
+		Port: 18333})
+	require.Error(t, err)
+
+	resolved, dialed := recorder.history()
+	require.Equal(t, []string{"127.0.0.1"}, resolved)
+	require.Empty(t, dialed)
+}
+
+// TestTrustedPeersExclusive ensures that a chain service configured with
+// exclusive trusted peers only connects to them.
+func TestTrustedPeersExclusive(t *testing.T) {
+	t.Parallel()
+
+	// The trusted peer accepts connections without speaking the protocol,
+	// which is enough to know it was dialed.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	accepted := make(chan struct{}, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+
+			select {
+			case accepted <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	tempDir, err := ioutil.TempDir("", "neutrino")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	db, err := walletdb.Create(
+		"bdb", filepath.Join(tempDir, "neutrino.db"), true,
+		time.Second*10,
+	)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var recorder recordingNet
+	cfg := neutrino.Config{
+		DataDir:      tempDir,
+		Database:     db,
+		ChainParams:  chaincfg.TestNet3Params,
+		AddPeers:     []string{"127.0.0.2:18333"},
+		Dialer:       recorder.dial,
+		NameResolver: recorder.resolve,
+	}
+	trusted := TrustedPeersConfig{
+		Peers:     []string{listener.Addr().String()},
+		Exclusive: true,
+	}
+	require.NoError(t, trusted.Apply(&cfg))
+
+	cs, err := neutrino.NewChainService(cfg)
+	require.NoError(t, err)
+	require.NoError(t, cs.Start())
+	defer cs.Stop()
+
+	select {
+	case <-accepted:
+	case <-time.After(10 * time.Second):
+		t.Fatal("trusted peer wasn't dialed")
+	}
+
+	resolved, dialed := recorder.history()
+	require.NotEmpty(t, dialed)
+	for _, addr := range dialed {
+		require.Equal(t, listener.Addr().String(), addr)
+	}
+	for _, host := range resolved {
+		require.Equal(t, "127.0.0.1", host)
+	}
+}
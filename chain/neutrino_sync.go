@@ -0,0 +1,131 @@
+package chain
+
+import (
+	"errors"
+
+	"github.com/lightninglabs/neutrino"
+)
+
+// ErrNoChainService is returned when querying the sync status of a
+// NeutrinoClient without a backing chain service.
+var ErrNoChainService = errors.New("no backing chain service")
+
+// SyncStatus describes how far the chain service of a NeutrinoClient has
+// synced towards the best tip known from its peers.
+type SyncStatus struct {
+	// Synced is whether both the block headers and filter headers have
+	// reached the best known tip.
+	Synced bool
+
+	// BlockHeadersSynced is whether the block headers have reached the
+	// best known tip.
+	BlockHeadersSynced bool
+
+	// FilterHeadersSynced is whether the filter headers have reached the
+	// best known tip.
+	FilterHeadersSynced bool
+
+	// Height is the local height up to which both block headers and filter
+	// headers are available.
+	Height int32
+
+	// BestPeerHeight is the highest height announced by the connected
+	// peers. This is zero if no peers are connected.
+	BestPeerHeight int32
+
+	// BlocksBehind is the estimated number of blocks Height lags behind
+	// the best known tip.
+	BlocksBehind int32
+}
+
+// syncStatusSource is the subset of the state of neutrino's chain service
+// required to determine its sync status.
+type syncStatusSource interface {
+	// blockHeaderHeight returns the height of the block header tip.
+	blockHeaderHeight() (uint32, error)
+
+	// filterHeaderHeight returns the height of the filter header tip.
+	filterHeaderHeight() (uint32, error)
+
+	// peerHeights returns the heights announced by the connected peers.
+	peerHeights() []int32
+}
+
+// chainServiceSyncSource is a syncStatusSource backed by a chain service.
+type chainServiceSyncSource struct {
+	cs *neutrino.ChainService
+}
+
+func (c chainServiceSyncSource) blockHeaderHeight() (uint32, error) {
+	_, height, err := c.cs.BlockHeaders.ChainTip()
+	return height, err
+}
+
+func (c chainServiceSyncSource) filterHeaderHeight() (uint32, error) {
+	_, height, err := c.cs.RegFilterHeaders.ChainTip()
+	return height, err
+}
+
+func (c chainServiceSyncSource) peerHeights() []int32 {
+	peers := c.cs.Peers()
+	heights := make([]int32, 0, len(peers))
+	for _, peer := range peers {
+		heights = append(heights, peer.LastBlock())
+	}
+	return heights
+}
+
+// SyncStatus returns whether the block headers and filter headers of the
+// chain service have caught up with the best tip known from its peers, along
+// with the heights involved. The best known tip is the highest of the block
+// header tip and the heights announced by the connected peers. As the tip
+// can't be known without peers, the headers aren't considered synced while
+// none are connected. The status is derived from the in-memory state of the
+// chain service, so it can be queried repeatedly.
+func (s *NeutrinoClient) SyncStatus() (*SyncStatus, error) {
+	if s.CS == nil {
+		return nil, ErrNoChainService
+	}
+
+	return syncStatus(chainServiceSyncSource{cs: s.CS})
+}
+
+// syncStatus determines the sync status of the given source.
+func syncStatus(source syncStatusSource) (*SyncStatus, error) {
+	blockHeight, err := source.blockHeaderHeight()
+	if err != nil {
+		return nil, err
+	}
+	filterHeight, err := source.filterHeaderHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	var status SyncStatus
+	peerHeights := source.peerHeights()
+	for _, height := range peerHeights {
+		if height > status.BestPeerHeight {
+			status.BestPeerHeight = height
+		}
+	}
+
+	// Filter headers are only synced following block headers, so the
+	// local height is bounded by the filter header tip.
+	status.Height = int32(blockHeight)
+	if filterHeight < blockHeight {
+		status.Height = int32(filterHeight)
+	}
+
+	tip := status.BestPeerHeight
+	if int32(blockHeight) > tip {
+		tip = int32(blockHeight)
+	}
+	if len(peerHeights) > 0 {
+		status.BlockHeadersSynced = int32(blockHeight) >= tip
+		status.FilterHeadersSynced = int32(filterHeight) >= tip
+	}
+	status.Synced = status.BlockHeadersSynced && status.FilterHeadersSynced
+	status.BlocksBehind = tip - status.Height
+
+	return &status, nil
+}
@@ -0,0 +1,99 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mockSyncSource is a syncStatusSource simulating a chain service syncing
+// headers from its peers.
+type mockSyncSource struct {
+	blockHeight  uint32
+	filterHeight uint32
+	peers        []int32
+	err          error
+}
+
+func (m *mockSyncSource) blockHeaderHeight() (uint32, error) {
+	return m.blockHeight, m.err
+}
+
+func (m *mockSyncSource) filterHeaderHeight() (uint32, error) {
+	return m.filterHeight, m.err
+}
+
+func (m *mockSyncSource) peerHeights() []int32 {
+	return append([]int32(nil), m.peers...)
+}
+
+// TestSyncStatus ensures that the sync status only flips to synced once both
+// block headers and filter headers catch up with the best peer height.
+func TestSyncStatus(t *testing.T) {
+	t.Parallel()
+
+	source := &mockSyncSource{}
+
+	// Without peers, the tip isn't known so the headers aren't synced.
+	status, err := syncStatus(source)
+	require.NoError(t, err)
+	require.Equal(t, &SyncStatus{}, status)
+
+	// Block headers sync ahead of filter headers.
+	source.peers = []int32{900, 1000}
+	source.blockHeight = 600
+	source.filterHeight = 400
+	status, err = syncStatus(source)
+	require.NoError(t, err)
+	require.Equal(t, &SyncStatus{
+		Height:         400,
+		BestPeerHeight: 1000,
+		BlocksBehind:   600,
+	}, status)
+
+	source.blockHeight = 1000
+	status, err = syncStatus(source)
+	require.NoError(t, err)
+	require.Equal(t, &SyncStatus{
+		BlockHeadersSynced: true,
+		Height:             400,
+		BestPeerHeight:     1000,
+		BlocksBehind:       600,
+	}, status)
+
+	// Once the filter headers catch up as well, the status is synced.
+	source.filterHeight = 1000
+	status, err = syncStatus(source)
+	require.NoError(t, err)
+	require.Equal(t, &SyncStatus{
+		Synced:              true,
+		BlockHeadersSynced:  true,
+		FilterHeadersSynced: true,
+		Height:              1000,
+		BestPeerHeight:      1000,
+	}, status)
+
+	// Peers lagging behind the local tip don't affect it.
+	source.peers = []int32{990}
+	status, err = syncStatus(source)
+	require.NoError(t, err)
+	require.True(t, status.Synced)
+	require.Zero(t, status.BlocksBehind)
+
+	// A new block announced by a peer makes the status lag again.
+	source.peers = append(source.peers, 1001)
+	status, err = syncStatus(source)
+	require.NoError(t, err)
+	require.False(t, status.Synced)
+	require.EqualValues(t, 1, status.BlocksBehind)
+
+	// Errors reading the header tips are returned.
+	source.err = errors.New("header store failure")
+	_, err = syncStatus(source)
+	require.Error(t, err)
+
+	// A client without a chain service can't report its status.
+	_, err = (&NeutrinoClient{}).SyncStatus()
+	require.True(t, errors.Is(err, ErrNoChainService))
+}
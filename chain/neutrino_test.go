@@ -0,0 +1,198 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/lightninglabs/neutrino"
+	"github.com/lightninglabs/neutrino/headerfs"
+	"github.com/stretchr/testify/require"
+)
+
+// mockFilterHeaderStore is a filterHeaderStore backed by a map of filter
+// headers indexed by height.
+type mockFilterHeaderStore map[uint32]chainhash.Hash
+
+func (m mockFilterHeaderStore) FetchHeaderByHeight(
+	height uint32) (*chainhash.Hash, error) {
+
+	header, ok := m[height]
+	if !ok {
+		return nil, &headerfs.ErrHeaderNotFound{}
+	}
+	return &header, nil
+}
+
+// TestValidateFilterHeaderCheckpoints ensures that filter header checkpoints
+// are rejected if they're not sorted by height or conflict with the filter
+// headers already stored.
+func TestValidateFilterHeaderCheckpoints(t *testing.T) {
+	t.Parallel()
+
+	store := mockFilterHeaderStore{
+		100: chainhash.Hash{0x01},
+		200: chainhash.Hash{0x02},
+	}
+
+	testCases := []struct {
+		name        string
+		checkpoints []FilterHeaderCheckpoint
+		valid       bool
+	}{
+		{
+			name:  "no checkpoints",
+			valid: true,
+		},
+		{
+			name: "matching and unknown checkpoints",
+			checkpoints: []FilterHeaderCheckpoint{
+				{Height: 100, FilterHeader: chainhash.Hash{0x01}},
+				{Height: 200, FilterHeader: chainhash.Hash{0x02}},
+				{Height: 300, FilterHeader: chainhash.Hash{0x03}},
+			},
+			valid: true,
+		},
+		{
+			name: "decreasing heights",
+			checkpoints: []FilterHeaderCheckpoint{
+				{Height: 200, FilterHeader: chainhash.Hash{0x02}},
+				{Height: 100, FilterHeader: chainhash.Hash{0x01}},
+			},
+			valid: false,
+		},
+		{
+			name: "duplicate heights",
+			checkpoints: []FilterHeaderCheckpoint{
+				{Height: 300, FilterHeader: chainhash.Hash{0x03}},
+				{Height: 300, FilterHeader: chainhash.Hash{0x03}},
+			},
+			valid: false,
+		},
+		{
+			name: "conflicts with stored header",
+			checkpoints: []FilterHeaderCheckpoint{
+				{Height: 200, FilterHeader: chainhash.Hash{0x04}},
+			},
+			valid: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := validateFilterHeaderCheckpoints(
+			testCase.checkpoints, store,
+		)
+		if testCase.valid {
+			require.NoError(t, err, testCase.name)
+		} else {
+			require.Error(t, err, testCase.name)
+		}
+	}
+}
+
+// TestNeutrinoClientCheckpointStats ensures the active checkpoint height is
+// exposed through the client's stats, and that it's asserted to neutrino.
+func TestNeutrinoClientCheckpointStats(t *testing.T) {
+	t.Parallel()
+
+	client := &NeutrinoClient{}
+	require.Zero(t, client.Stats().ActiveCheckpointHeight)
+
+	checkpoints := []FilterHeaderCheckpoint{
+		{Height: 100, FilterHeader: chainhash.Hash{0x01}},
+		{Height: 200, FilterHeader: chainhash.Hash{0x02}},
+	}
+	require.NoError(t, client.SetFilterHeaderCheckpoints(checkpoints))
+	require.EqualValues(t, 200, client.Stats().ActiveCheckpointHeight)
+
+	assertion := AssertFilterHeader(checkpoints)
+	require.NotNil(t, assertion)
+	require.EqualValues(t, 200, assertion.Height)
+	require.Equal(t, chainhash.Hash{0x02}, assertion.FilterHash)
+
+	// Rejected checkpoints should leave the active ones untouched.
+	err := client.SetFilterHeaderCheckpoints([]FilterHeaderCheckpoint{
+		{Height: 300}, {Height: 250},
+	})
+	require.Error(t, err)
+	require.EqualValues(t, 200, client.Stats().ActiveCheckpointHeight)
+}
+
+// TestNeutrinoClientFilterHeaderCheckpoints ensures the filter headers synced
+// by the chain service are validated against the client's checkpoints, such
+// that a conflicting header fails its health check and FilterBlocks, and that
+// the latest checkpoint is asserted through the chain service's config.
+func TestNeutrinoClientFilterHeaderCheckpoints(t *testing.T) {
+	t.Parallel()
+
+	store := mockFilterHeaderStore{
+		100: chainhash.Hash{0x01},
+	}
+	client := &NeutrinoClient{filterHeaders: store}
+
+	checkpoints := []FilterHeaderCheckpoint{
+		{Height: 100, FilterHeader: chainhash.Hash{0x01}},
+		{Height: 200, FilterHeader: chainhash.Hash{0x02}},
+	}
+	require.NoError(t, client.SetFilterHeaderCheckpoints(checkpoints))
+	require.NoError(t, client.verifyFilterHeaderCheckpoints())
+
+	var cfg neutrino.Config
+	require.NoError(t, ApplyFilterHeaderCheckpoints(&cfg, checkpoints))
+	require.Equal(
+		t, AssertFilterHeader(checkpoints), cfg.AssertFilterHeader,
+	)
+
+	// The chain service syncing a filter header matching the checkpoint
+	// is accepted.
+	store[200] = chainhash.Hash{0x02}
+	require.NoError(t, client.verifyFilterHeaderCheckpoints())
+
+	// While one conflicting with it is rejected.
+	store[200] = chainhash.Hash{0x03}
+	err := client.verifyFilterHeaderCheckpoints()
+	require.True(t, errors.Is(err, ErrFilterHeaderCheckpoint))
+
+	_, err = client.FilterBlocks(&FilterBlocksRequest{})
+	require.True(t, errors.Is(err, ErrFilterHeaderCheckpoint))
+
+	// Checkpoints conflicting with the stored headers can't be set.
+	err = client.SetFilterHeaderCheckpoints(checkpoints)
+	require.True(t, errors.Is(err, ErrFilterHeaderCheckpoint))
+}
+
+// TestNeutrinoClientTxBlocksPruning ensures the blocks of relevant
+// transactions are only kept while they're within the reorg safety depth of
+// the highest block recorded.
+func TestNeutrinoClientTxBlocksPruning(t *testing.T) {
+	t.Parallel()
+
+	client := &NeutrinoClient{}
+
+	oldBlock := wtxmgr.Block{Hash: chainhash.Hash{0x01}, Height: 100}
+	client.recordTxBlock(chainhash.Hash{0xaa}, oldBlock)
+
+	// A block at the reorg safety depth keeps the transaction around,
+	// while a lower one being recorded doesn't trigger any pruning.
+	safeBlock := wtxmgr.Block{
+		Hash:   chainhash.Hash{0x02},
+		Height: oldBlock.Height + waddrmgr.MaxReorgDepth,
+	}
+	client.recordTxBlock(chainhash.Hash{0xbb}, safeBlock)
+	client.recordTxBlock(chainhash.Hash{0xcc}, oldBlock)
+	require.Len(t, client.txBlocks, 3)
+
+	// Once the older block is buried any deeper, its transactions are
+	// pruned.
+	newBlock := wtxmgr.Block{
+		Hash:   chainhash.Hash{0x03},
+		Height: safeBlock.Height + 1,
+	}
+	client.recordTxBlock(chainhash.Hash{0xdd}, newBlock)
+	require.Equal(t, map[chainhash.Hash]wtxmgr.Block{
+		{0xbb}: safeBlock,
+		{0xdd}: newBlock,
+	}, client.txBlocks)
+}
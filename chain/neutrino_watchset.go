@@ -0,0 +1,92 @@
+package chain
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/gcs"
+	"github.com/btcsuite/btcutil/gcs/builder"
+)
+
+// watchedAddrKey identifies the output script of an address without encoding
+// it, as addresses of different types may share the same script address.
+type watchedAddrKey struct {
+	addrType      reflect.Type
+	scriptAddress string
+}
+
+// watchedScriptSet caches the output scripts of the addresses watched by a
+// client, which are the query set BIP158 filters are matched against. Scripts
+// are derived once as addresses are first watched, such that the query sets
+// of large rescans aren't rebuilt from scratch for every batch of blocks.
+//
+// NOTE: The scripts themselves must still be hashed for every filter, as
+// BIP158 filters are keyed by the hash of their block.
+type watchedScriptSet struct {
+	mtx     sync.Mutex
+	scripts map[watchedAddrKey][]byte
+}
+
+// script returns the output script of the given address, deriving and adding
+// it to the set if it's not within it yet.
+//
+// NOTE: This must be called with the set's mutex held.
+func (w *watchedScriptSet) script(addr btcutil.Address) ([]byte, error) {
+	key := watchedAddrKey{
+		addrType:      reflect.TypeOf(addr),
+		scriptAddress: string(addr.ScriptAddress()),
+	}
+	if script, ok := w.scripts[key]; ok {
+		return script, nil
+	}
+
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	if w.scripts == nil {
+		w.scripts = make(map[watchedAddrKey][]byte)
+	}
+	w.scripts[key] = script
+
+	return script, nil
+}
+
+// querySet returns the output scripts of the given addresses, adding those
+// not yet watched to the set.
+func (w *watchedScriptSet) querySet(
+	addrs ...btcutil.Address) ([][]byte, error) {
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	scripts := make([][]byte, 0, len(addrs))
+	for _, addr := range addrs {
+		script, err := w.script(addr)
+		if err != nil {
+			return nil, err
+		}
+		scripts = append(scripts, script)
+	}
+
+	return scripts, nil
+}
+
+// size returns the number of scripts within the set.
+func (w *watchedScriptSet) size() int {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return len(w.scripts)
+}
+
+// matchBlockFilter returns whether the filter of the block with the given hash
+// likely matches any of the scripts of the query set.
+func matchBlockFilter(filter *gcs.Filter, blockHash *chainhash.Hash,
+	querySet [][]byte) (bool, error) {
+
+	return filter.MatchAny(builder.DeriveKey(blockHash), querySet)
+}
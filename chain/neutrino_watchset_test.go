@@ -0,0 +1,156 @@
+package chain
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/gcs"
+	"github.com/btcsuite/btcutil/gcs/builder"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// testWatchAddr returns a distinct P2WPKH address for each index.
+func testWatchAddr(t testing.TB, i int) btcutil.Address {
+	var hash [20]byte
+	binary.BigEndian.PutUint64(hash[:], uint64(i))
+
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		hash[:], &chaincfg.RegressionNetParams,
+	)
+	require.NoError(t, err)
+	return addr
+}
+
+// testBlockFilter returns the filter of the block with the given hash
+// containing the scripts of the given addresses.
+func testBlockFilter(t testing.TB, blockHash *chainhash.Hash,
+	addrs ...btcutil.Address) *gcs.Filter {
+
+	scripts := make([][]byte, 0, len(addrs))
+	for _, addr := range addrs {
+		script, err := txscript.PayToAddrScript(addr)
+		require.NoError(t, err)
+		scripts = append(scripts, script)
+	}
+
+	filter, err := gcs.BuildGCSFilter(
+		builder.DefaultP, builder.DefaultM,
+		builder.DeriveKey(blockHash), scripts,
+	)
+	require.NoError(t, err)
+	return filter
+}
+
+// TestNeutrinoWatchedScripts ensures the scripts of the addresses of
+// FilterBlocks requests are cached within the client's watched script set,
+// with their number exposed through its stats, while blocks are only matched
+// against the scripts of the request being served.
+func TestNeutrinoWatchedScripts(t *testing.T) {
+	t.Parallel()
+
+	client := &NeutrinoClient{}
+	require.Zero(t, client.Stats().WatchedScripts)
+
+	// The same address being both external and watched by an outpoint is
+	// only added once, even if its P2PKH counterpart shares its hash.
+	witnessAddr := testWatchAddr(t, 0)
+	legacyAddr, err := btcutil.NewAddressPubKeyHash(
+		witnessAddr.ScriptAddress(), &chaincfg.RegressionNetParams,
+	)
+	require.NoError(t, err)
+
+	req := &FilterBlocksRequest{
+		ExternalAddrs: map[waddrmgr.ScopedIndex]btcutil.Address{
+			{Index: 0}: witnessAddr,
+		},
+		InternalAddrs: map[waddrmgr.ScopedIndex]btcutil.Address{
+			{Index: 0}: legacyAddr,
+		},
+		WatchedOutPoints: map[wire.OutPoint]btcutil.Address{
+			{Index: 0}: witnessAddr,
+		},
+	}
+	querySet, err := client.filterBlocksQuerySet(req)
+	require.NoError(t, err)
+	require.Len(t, querySet, 3)
+	require.Equal(t, 2, client.Stats().WatchedScripts)
+
+	watchList, err := buildFilterBlocksWatchList(req)
+	require.NoError(t, err)
+	require.ElementsMatch(t, watchList, querySet)
+
+	// A later request only matches blocks paying to its own addresses,
+	// while its scripts are added to the set.
+	querySet, err = client.filterBlocksQuerySet(&FilterBlocksRequest{
+		ExternalAddrs: map[waddrmgr.ScopedIndex]btcutil.Address{
+			{Index: 1}: testWatchAddr(t, 1),
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, client.Stats().WatchedScripts)
+
+	blockHash := chainhash.Hash{1}
+	filter := testBlockFilter(t, &blockHash, witnessAddr)
+	matched, err := matchBlockFilter(filter, &blockHash, querySet)
+	require.NoError(t, err)
+	require.False(t, matched)
+
+	filter = testBlockFilter(t, &blockHash, testWatchAddr(t, 1))
+	matched, err = matchBlockFilter(filter, &blockHash, querySet)
+	require.NoError(t, err)
+	require.True(t, matched)
+}
+
+// BenchmarkFilterBlocks compares the time taken to build the query set of a
+// FilterBlocks request and match it against a block's filter with 10 and
+// 10,000 watched scripts.
+func BenchmarkFilterBlocks(b *testing.B) {
+	const blockScripts = 2000
+
+	blockHash := chainhash.Hash{1}
+	blockAddrs := make([]btcutil.Address, 0, blockScripts)
+	for i := 0; i < blockScripts; i++ {
+		blockAddrs = append(blockAddrs, testWatchAddr(b, -1-i))
+	}
+	filter := testBlockFilter(b, &blockHash, blockAddrs...)
+
+	for _, numWatched := range []int{10, 10000} {
+		req := &FilterBlocksRequest{
+			ExternalAddrs: make(
+				map[waddrmgr.ScopedIndex]btcutil.Address,
+				numWatched,
+			),
+		}
+		for i := 0; i < numWatched; i++ {
+			idx := waddrmgr.ScopedIndex{Index: uint32(i)}
+			req.ExternalAddrs[idx] = testWatchAddr(b, i)
+		}
+
+		client := &NeutrinoClient{}
+		name := fmt.Sprintf("%d scripts", numWatched)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				querySet, err := client.filterBlocksQuerySet(
+					req,
+				)
+				if err != nil {
+					b.Fatal(err)
+				}
+				_, err = matchBlockFilter(
+					filter, &blockHash, querySet,
+				)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
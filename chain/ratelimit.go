@@ -0,0 +1,238 @@
+package chain
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/gcs"
+	"github.com/lightninglabs/neutrino"
+)
+
+// errRateLimiterQuit is returned when a wait for the fetch rate limiter is
+// interrupted by the quit channel.
+var errRateLimiterQuit = errors.New("rate limited fetch interrupted")
+
+// FetchRateLimitConfig contains the parameters with which the compact filters
+// and blocks fetched by a NeutrinoClient are rate limited.
+type FetchRateLimitConfig struct {
+	// BytesPerSecond is the sustained rate, in bytes per second, at which
+	// filters and blocks are fetched. Zero disables rate limiting.
+	BytesPerSecond int64
+
+	// Burst is the number of bytes that can be fetched at once after the
+	// limiter has been idle, smoothing bursts exceeding it over time. It
+	// defaults to one second worth of BytesPerSecond.
+	Burst int64
+}
+
+// fetchRateLimiter is a token bucket limiting the rate at which bytes are
+// fetched. As the size of a filter or block is only known once fetched, each
+// fetch first waits for the bucket to be refilled to a positive level, and is
+// then charged for its size, which may leave the bucket in debt. A fetch larger
+// than the burst is therefore only delayed, and never blocked indefinitely. A
+// nil limiter doesn't limit fetches.
+type fetchRateLimiter struct {
+	// now and after allow the clock to be mocked in tests.
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+
+	// mtx guards the fields below.
+	mtx     sync.Mutex
+	cfg     FetchRateLimitConfig
+	tokens  float64
+	last    time.Time
+	fetched uint64
+}
+
+// newFetchRateLimiter creates a limiter with a full bucket.
+func newFetchRateLimiter(cfg FetchRateLimitConfig) *fetchRateLimiter {
+	l := &fetchRateLimiter{
+		now:   time.Now,
+		after: time.After,
+	}
+	l.setConfig(cfg)
+
+	return l
+}
+
+// setConfig replaces the limiter's config, refilling its bucket.
+func (l *fetchRateLimiter) setConfig(cfg FetchRateLimitConfig) {
+	if cfg.BytesPerSecond < 0 {
+		cfg.BytesPerSecond = 0
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = cfg.BytesPerSecond
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.cfg = cfg
+	l.tokens = float64(cfg.Burst)
+	l.last = l.now()
+}
+
+// refill adds the tokens accrued since the last refill to the bucket, up to
+// its burst.
+//
+// NOTE: This must be called with the mutex held.
+func (l *fetchRateLimiter) refill() {
+	now := l.now()
+	elapsed := now.Sub(l.last).Seconds()
+	l.last = now
+
+	l.tokens += elapsed * float64(l.cfg.BytesPerSecond)
+	if l.tokens > float64(l.cfg.Burst) {
+		l.tokens = float64(l.cfg.Burst)
+	}
+}
+
+// wait blocks until the bucket is no longer in debt, such that a fetch can be
+// performed, or until quit is closed.
+func (l *fetchRateLimiter) wait(quit <-chan struct{}) error {
+	if l == nil {
+		return nil
+	}
+
+	for {
+		l.mtx.Lock()
+		if l.cfg.BytesPerSecond == 0 {
+			l.mtx.Unlock()
+			return nil
+		}
+		l.refill()
+		if l.tokens > 0 {
+			l.mtx.Unlock()
+			return nil
+		}
+		delay := time.Duration(
+			(1 - l.tokens) / float64(l.cfg.BytesPerSecond) *
+				float64(time.Second),
+		)
+		l.mtx.Unlock()
+
+		select {
+		case <-l.after(delay):
+		case <-quit:
+			return errRateLimiterQuit
+		}
+	}
+}
+
+// charge charges the bucket for the given number of fetched bytes.
+func (l *fetchRateLimiter) charge(n int) {
+	if l == nil {
+		return
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.fetched += uint64(n)
+	if l.cfg.BytesPerSecond == 0 {
+		return
+	}
+	l.refill()
+	l.tokens -= float64(n)
+}
+
+// status returns the configured rate in bytes per second, the fraction of the
+// burst currently consumed, and the total number of bytes fetched.
+func (l *fetchRateLimiter) status() (int64, float64, uint64) {
+	if l == nil {
+		return 0, 0, 0
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.cfg.BytesPerSecond == 0 {
+		return 0, 0, l.fetched
+	}
+	l.refill()
+
+	utilization := 1 - l.tokens/float64(l.cfg.Burst)
+	switch {
+	case utilization < 0:
+		utilization = 0
+	case utilization > 1:
+		utilization = 1
+	}
+
+	return l.cfg.BytesPerSecond, utilization, l.fetched
+}
+
+// fetchBlock fetches a block through the limiter.
+func (l *fetchRateLimiter) fetchBlock(quit <-chan struct{},
+	fetch func() (*btcutil.Block, error)) (*btcutil.Block, error) {
+
+	if err := l.wait(quit); err != nil {
+		return nil, err
+	}
+	block, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	l.charge(block.MsgBlock().SerializeSize())
+
+	return block, nil
+}
+
+// fetchCFilter fetches a compact filter through the limiter.
+func (l *fetchRateLimiter) fetchCFilter(quit <-chan struct{},
+	fetch func() (*gcs.Filter, error)) (*gcs.Filter, error) {
+
+	if err := l.wait(quit); err != nil {
+		return nil, err
+	}
+	filter, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		filterBytes, err := filter.NBytes()
+		if err != nil {
+			return nil, err
+		}
+		l.charge(len(filterBytes))
+	}
+
+	return filter, nil
+}
+
+// rateLimitedChainSource is a neutrino.ChainSource whose blocks and filters
+// are fetched through a fetchRateLimiter.
+type rateLimitedChainSource struct {
+	neutrino.ChainSource
+
+	limiter *fetchRateLimiter
+	quit    <-chan struct{}
+}
+
+// A compile-time check to ensure that rateLimitedChainSource implements the
+// neutrino.ChainSource interface.
+var _ neutrino.ChainSource = (*rateLimitedChainSource)(nil)
+
+// GetBlock returns the block with the given hash once allowed by the limiter.
+func (s *rateLimitedChainSource) GetBlock(hash chainhash.Hash,
+	opts ...neutrino.QueryOption) (*btcutil.Block, error) {
+
+	return s.limiter.fetchBlock(s.quit, func() (*btcutil.Block, error) {
+		return s.ChainSource.GetBlock(hash, opts...)
+	})
+}
+
+// GetCFilter returns the filter of the given type for the block with the
+// given hash once allowed by the limiter.
+func (s *rateLimitedChainSource) GetCFilter(hash chainhash.Hash,
+	filterType wire.FilterType,
+	opts ...neutrino.QueryOption) (*gcs.Filter, error) {
+
+	return s.limiter.fetchCFilter(s.quit, func() (*gcs.Filter, error) {
+		return s.ChainSource.GetCFilter(hash, filterType, opts...)
+	})
+}
@@ -0,0 +1,118 @@
+package chain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/require"
+)
+
+// mockClock is a clock whose time only advances when waited on.
+type mockClock struct {
+	now time.Time
+}
+
+func (c *mockClock) Now() time.Time {
+	return c.now
+}
+
+func (c *mockClock) After(d time.Duration) <-chan time.Time {
+	c.now = c.now.Add(d)
+
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// TestFetchRateLimiter ensures that the limiter caps the throughput of a burst
+// of fetches to its configured rate, without blocking fetches larger than its
+// burst, and that it can be disabled.
+func TestFetchRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	clock := &mockClock{now: time.Unix(1600000000, 0)}
+	limiter := &fetchRateLimiter{now: clock.Now, after: clock.After}
+
+	const (
+		rate  = 10000
+		burst = 20000
+	)
+	limiter.setConfig(FetchRateLimitConfig{
+		BytesPerSecond: rate,
+		Burst:          burst,
+	})
+
+	block := btcutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{{
+			TxOut: []*wire.TxOut{
+				wire.NewTxOut(0, make([]byte, 1000)),
+			},
+		}},
+	})
+	blockSize := block.MsgBlock().SerializeSize()
+	fetch := func() (*btcutil.Block, error) {
+		return block, nil
+	}
+
+	// Simulate a burst of fetches, which should be smoothed out to the
+	// configured rate once the initial burst has been consumed.
+	const numFetches = 200
+	start := clock.Now()
+	for i := 0; i < numFetches; i++ {
+		_, err := limiter.fetchBlock(nil, fetch)
+		require.NoError(t, err)
+	}
+	elapsed := clock.Now().Sub(start).Seconds()
+
+	total := float64(numFetches * blockSize)
+	minElapsed := (total - burst - float64(blockSize)) / rate
+	maxElapsed := (total - burst + float64(blockSize)) / rate
+	require.GreaterOrEqual(t, elapsed, minElapsed)
+	require.LessOrEqual(t, elapsed, maxElapsed)
+
+	// The throughput past the initial burst shouldn't exceed the rate.
+	require.LessOrEqual(t, (total-burst)/elapsed, rate*1.01)
+
+	limit, utilization, fetched := limiter.status()
+	require.EqualValues(t, rate, limit)
+	require.InDelta(t, 1, utilization, float64(blockSize)/burst)
+	require.EqualValues(t, total, fetched)
+
+	// Once idle, the bucket refills up to its burst.
+	clock.now = clock.now.Add(time.Hour)
+	_, utilization, _ = limiter.status()
+	require.Zero(t, utilization)
+
+	// A fetch larger than the burst is let through, only delaying the
+	// following one until the debt it incurred is repaid.
+	limiter.charge(3 * burst)
+	start = clock.Now()
+	require.NoError(t, limiter.wait(nil))
+	waited := clock.Now().Sub(start)
+	require.InDelta(t, 2*burst/rate, waited.Seconds(), 0.01)
+
+	// A wait can be interrupted.
+	limiter.charge(burst)
+	limiter.after = func(time.Duration) <-chan time.Time {
+		return nil
+	}
+	quit := make(chan struct{})
+	close(quit)
+	require.Equal(t, errRateLimiterQuit, limiter.wait(quit))
+
+	// Once disabled, fetches are no longer delayed.
+	limiter.setConfig(FetchRateLimitConfig{})
+	start = clock.Now()
+	for i := 0; i < numFetches; i++ {
+		_, err := limiter.fetchBlock(nil, fetch)
+		require.NoError(t, err)
+	}
+	require.Equal(t, start, clock.Now())
+
+	limit, utilization, fetched = limiter.status()
+	require.Zero(t, limit)
+	require.Zero(t, utilization)
+	require.EqualValues(t, 2*total+4*burst, fetched)
+}
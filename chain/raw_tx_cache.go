@@ -0,0 +1,178 @@
+package chain
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// defaultRawTxCacheSize is the default number of raw transactions
+	// cached by a BitcoindConn.
+	defaultRawTxCacheSize = 1000
+)
+
+// rawTxCacheEntry is a raw transaction cached along with the hash of the block
+// it was confirmed in, if any.
+type rawTxCacheEntry struct {
+	txHash    chainhash.Hash
+	tx        *wire.MsgTx
+	blockHash *chainhash.Hash
+}
+
+// rawTxCache is a bounded LRU cache of raw transactions keyed by their hash.
+//
+// Entries for confirmed transactions remain valid until their block is
+// disconnected. Entries for unconfirmed transactions only remain valid until
+// the next block is connected, as the transaction may have been confirmed,
+// replaced, or evicted from the mempool by then.
+type rawTxCache struct {
+	mtx      sync.Mutex
+	capacity int
+	entries  map[chainhash.Hash]*list.Element
+	lru      *list.List
+
+	// byBlock tracks the hashes of the cached transactions confirmed in
+	// each block.
+	byBlock map[chainhash.Hash]map[chainhash.Hash]struct{}
+
+	// unconfirmed tracks the hashes of the cached unconfirmed
+	// transactions.
+	unconfirmed map[chainhash.Hash]struct{}
+
+	hits   uint64
+	misses uint64
+}
+
+// newRawTxCache creates a raw transaction cache holding up to capacity
+// transactions.
+func newRawTxCache(capacity int) *rawTxCache {
+	if capacity <= 0 {
+		capacity = defaultRawTxCacheSize
+	}
+
+	return &rawTxCache{
+		capacity:    capacity,
+		entries:     make(map[chainhash.Hash]*list.Element),
+		lru:         list.New(),
+		byBlock:     make(map[chainhash.Hash]map[chainhash.Hash]struct{}),
+		unconfirmed: make(map[chainhash.Hash]struct{}),
+	}
+}
+
+// get returns the cached transaction with the given hash along with the hash
+// of the block it was confirmed in, if any. The lookup is recorded as either a
+// hit or a miss.
+func (c *rawTxCache) get(txHash *chainhash.Hash) (*wire.MsgTx,
+	*chainhash.Hash, bool) {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	elem, ok := c.entries[*txHash]
+	if !ok {
+		c.misses++
+		return nil, nil, false
+	}
+
+	c.hits++
+	c.lru.MoveToFront(elem)
+	entry := elem.Value.(*rawTxCacheEntry)
+	return entry.tx, entry.blockHash, true
+}
+
+// add caches the transaction, which is unconfirmed if blockHash is nil,
+// evicting the least recently used transaction if the cache is full.
+func (c *rawTxCache) add(tx *wire.MsgTx, blockHash *chainhash.Hash) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	txHash := tx.TxHash()
+	if elem, ok := c.entries[txHash]; ok {
+		c.removeElement(elem)
+	}
+
+	entry := &rawTxCacheEntry{
+		txHash:    txHash,
+		tx:        tx,
+		blockHash: blockHash,
+	}
+	c.entries[txHash] = c.lru.PushFront(entry)
+	if blockHash == nil {
+		c.unconfirmed[txHash] = struct{}{}
+	} else {
+		txs, ok := c.byBlock[*blockHash]
+		if !ok {
+			txs = make(map[chainhash.Hash]struct{})
+			c.byBlock[*blockHash] = txs
+		}
+		txs[txHash] = struct{}{}
+	}
+
+	for c.lru.Len() > c.capacity {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// blockConnected invalidates all cached unconfirmed transactions.
+func (c *rawTxCache) blockConnected() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for txHash := range c.unconfirmed {
+		c.removeElement(c.entries[txHash])
+	}
+}
+
+// blockDisconnected invalidates the cached transactions confirmed in the block
+// with the given hash.
+func (c *rawTxCache) blockDisconnected(blockHash *chainhash.Hash) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for txHash := range c.byBlock[*blockHash] {
+		c.removeElement(c.entries[txHash])
+	}
+}
+
+// remove invalidates the cached transactions with the given hashes, if any.
+func (c *rawTxCache) remove(txHashes map[chainhash.Hash]struct{}) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for txHash := range txHashes {
+		if elem, ok := c.entries[txHash]; ok {
+			c.removeElement(elem)
+		}
+	}
+}
+
+// stats returns the number of cache hits and misses, along with the number of
+// cached transactions.
+func (c *rawTxCache) stats() (uint64, uint64, int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.hits, c.misses, c.lru.Len()
+}
+
+// removeElement removes the cached transaction of the given element.
+//
+// NOTE: This must be called with the cache's mutex held.
+func (c *rawTxCache) removeElement(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*rawTxCacheEntry)
+	delete(c.entries, entry.txHash)
+
+	if entry.blockHash == nil {
+		delete(c.unconfirmed, entry.txHash)
+		return
+	}
+
+	txs := c.byBlock[*entry.blockHash]
+	delete(txs, entry.txHash)
+	if len(txs) == 0 {
+		delete(c.byBlock, *entry.blockHash)
+	}
+}
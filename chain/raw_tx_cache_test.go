@@ -0,0 +1,111 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// newCacheTestTx returns a unique transaction for the given index.
+func newCacheTestTx(i byte) *wire.MsgTx {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{i}}, nil, nil,
+	))
+	return tx
+}
+
+// TestRawTxCache ensures that the raw transaction cache is bounded, that
+// unconfirmed transactions are invalidated once a block is connected, that
+// confirmed transactions are invalidated once their block is disconnected, and
+// that hits and misses are counted. Transactions are also invalidated once
+// evicted from a client's mempool, and unconfirmed ones once a client processes
+// a reorg.
+func TestRawTxCache(t *testing.T) {
+	t.Parallel()
+
+	cache := newRawTxCache(3)
+
+	var (
+		block1 = chainhash.Hash{0x01}
+		block2 = chainhash.Hash{0x02}
+
+		confirmed1  = newCacheTestTx(1)
+		confirmed2  = newCacheTestTx(2)
+		unconfirmed = newCacheTestTx(3)
+		extra       = newCacheTestTx(4)
+	)
+	cache.add(confirmed1, &block1)
+	cache.add(confirmed2, &block2)
+	cache.add(unconfirmed, nil)
+
+	assertCached := func(tx *wire.MsgTx, blockHash *chainhash.Hash) {
+		t.Helper()
+
+		txHash := tx.TxHash()
+		cachedTx, cachedBlock, ok := cache.get(&txHash)
+		require.True(t, ok, "expected %v to be cached", txHash)
+		require.Equal(t, tx, cachedTx)
+		require.Equal(t, blockHash, cachedBlock)
+	}
+	assertNotCached := func(tx *wire.MsgTx) {
+		t.Helper()
+
+		txHash := tx.TxHash()
+		_, _, ok := cache.get(&txHash)
+		require.False(t, ok, "expected %v to not be cached", txHash)
+	}
+
+	assertCached(confirmed1, &block1)
+	assertCached(confirmed2, &block2)
+	assertCached(unconfirmed, nil)
+
+	// Adding a transaction to the full cache should evict the least
+	// recently used one.
+	cache.add(extra, &block2)
+	assertNotCached(confirmed1)
+	assertCached(extra, &block2)
+
+	// Connecting a block should only invalidate unconfirmed transactions.
+	cache.blockConnected()
+	assertNotCached(unconfirmed)
+	assertCached(confirmed2, &block2)
+
+	// Disconnecting a block should invalidate the transactions confirmed
+	// in it.
+	cache.add(confirmed1, &block1)
+	cache.blockDisconnected(&block2)
+	assertNotCached(confirmed2)
+	assertNotCached(extra)
+	assertCached(confirmed1, &block1)
+
+	hits, misses, size := cache.stats()
+	require.EqualValues(t, 6, hits)
+	require.EqualValues(t, 4, misses)
+	require.Equal(t, 1, size)
+
+	stats := BitcoindConnStats{
+		RawTxCacheHits:   hits,
+		RawTxCacheMisses: misses,
+	}
+	require.InDelta(t, 6.0/10.0, stats.RawTxCacheHitRatio(), 1e-9)
+	require.Zero(t, BitcoindConnStats{}.RawTxCacheHitRatio())
+
+	// Transactions evicted from a client's mempool are invalidated, as
+	// are unconfirmed ones once a client processes a reorg.
+	conn := &BitcoindConn{
+		rawTxCache:  cache,
+		blockHashes: newBlockHashCache(3, 6),
+	}
+	cache.add(unconfirmed, nil)
+	conn.mempoolEvicted(map[chainhash.Hash]struct{}{
+		confirmed1.TxHash(): {},
+	})
+	assertNotCached(confirmed1)
+	assertCached(unconfirmed, nil)
+
+	conn.reorged()
+	assertNotCached(unconfirmed)
+}
@@ -5,6 +5,7 @@
 package chain
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"time"
@@ -33,6 +34,16 @@ type RPCClient struct {
 	dequeueNotification chan interface{}
 	currentBlock        chan *waddrmgr.BlockStamp
 
+	// health periodically health checks the btcd node.
+	health *healthMonitor
+
+	// timestamps caches the heights resolved by BlockHeightForTimestamp.
+	timestamps timestampResolver
+
+	// broadcasters holds the additional endpoints transactions are
+	// published to.
+	broadcasters broadcastSet
+
 	quit    chan struct{}
 	wg      sync.WaitGroup
 	started bool
@@ -84,14 +95,105 @@ func NewRPCClient(chainParams *chaincfg.Params, connect, user, pass string, cert
 		return nil, err
 	}
 	client.Client = rpcClient
+	client.health = newHealthMonitor(
+		"btcd", HealthCheckConfig{}, client.checkHealth,
+		logEventSink{}.OnEvent,
+	)
 	return client, nil
 }
 
+// SetHealthCheckConfig configures the periodic health check of the btcd node,
+// performed through getblockcount. Its HealthEvents are emitted through the
+// config's EventSink, or written to the package logger if unset.
+//
+// NOTE: This must be called before the client is started.
+func (c *RPCClient) SetHealthCheckConfig(cfg HealthCheckConfig) {
+	c.health = newHealthMonitor(
+		"btcd", cfg, c.checkHealth, logEventSink{}.OnEvent,
+	)
+}
+
+// IsHealthy returns whether the btcd node is currently considered healthy by
+// its periodic health check. If it isn't, the error of the latest failed health
+// check is returned as well.
+func (c *RPCClient) IsHealthy() (bool, error) {
+	return c.health.isHealthy()
+}
+
+// checkHealth checks whether the btcd node is serving data by querying its
+// block count.
+func (c *RPCClient) checkHealth(_ context.Context) error {
+	_, err := c.GetBlockCount()
+	return err
+}
+
+// BlockHeightForTimestamp returns the height of the first block with a
+// timestamp at or after the given one, or the height of the tip if the
+// timestamp is after it. The block is located through a binary search over the
+// block headers served by btcd.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *RPCClient) BlockHeightForTimestamp(ts time.Time) (int32, error) {
+	_, tipHeight, err := c.GetBestBlock()
+	if err != nil {
+		return 0, err
+	}
+
+	return c.timestamps.resolve(ts, tipHeight, fetchHeaderByHeight(c))
+}
+
+// GetMerkleProof returns a merkle proof of the inclusion of the transaction
+// within its block, computed from the block served by btcd, or
+// ErrTxNotConfirmed if it's within the mempool. Locating the block of a
+// confirmed transaction requires btcd's transaction index.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *RPCClient) GetMerkleProof(txHash chainhash.Hash) (*MerkleProof,
+	error) {
+
+	tx, err := c.GetRawTransactionVerbose(&txHash)
+	if err != nil {
+		return nil, err
+	}
+	if tx.BlockHash == "" {
+		return nil, ErrTxNotConfirmed
+	}
+	blockHash, err := chainhash.NewHashFromStr(tx.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	block, err := c.GetBlock(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMerkleProof(block, txHash)
+}
+
 // BackEnd returns the name of the driver.
 func (c *RPCClient) BackEnd() string {
 	return "btcd"
 }
 
+// SendRawTransaction submits the encoded transaction to the server, which
+// will then relay it to the network. The transaction is also published to the
+// client's broadcasters, if any.
+func (c *RPCClient) SendRawTransaction(tx *wire.MsgTx,
+	allowHighFees bool) (*chainhash.Hash, error) {
+
+	return c.broadcasters.broadcast(
+		tx, allowHighFees, c.Client.SendRawTransaction,
+	)
+}
+
+// SetBroadcasters sets additional endpoints transactions are published to
+// through SendRawTransaction, alongside the btcd node. A transaction is then
+// considered published if any of them accepts it. The btcd node remains the
+// source of all notifications.
+func (c *RPCClient) SetBroadcasters(broadcasters ...Broadcaster) {
+	c.broadcasters.set(broadcasters)
+}
+
 // Start attempts to establish a client connection with the remote server.
 // If successful, handler goroutines are started to process notifications
 // sent by the server.  After a limited number of connection attempts, this
@@ -120,6 +222,9 @@ func (c *RPCClient) Start() error {
 
 	c.wg.Add(1)
 	go c.handler()
+
+	c.health.Start()
+
 	return nil
 }
 
@@ -130,6 +235,7 @@ func (c *RPCClient) Stop() {
 	select {
 	case <-c.quit:
 	default:
+		c.health.Stop()
 		close(c.quit)
 		c.Client.Shutdown()
 
@@ -326,6 +432,8 @@ func (c *RPCClient) onBlockConnected(hash *chainhash.Hash, height int32, time ti
 }
 
 func (c *RPCClient) onBlockDisconnected(hash *chainhash.Hash, height int32, time time.Time) {
+	c.timestamps.blockDisconnected(height)
+
 	select {
 	case c.enqueueNotification <- BlockDisconnected{
 		Block: wtxmgr.Block{
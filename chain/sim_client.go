@@ -0,0 +1,717 @@
+package chain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+const (
+	// simBlockInterval is the time between the timestamps of consecutive
+	// blocks of a SimClient's chain.
+	simBlockInterval = 10 * time.Minute
+)
+
+var (
+	// ErrSimBlockNotFound is returned by a SimClient when a requested block
+	// is unknown to it.
+	ErrSimBlockNotFound = errors.New("block not found")
+)
+
+// SimClient is an in-memory implementation of the chain.Interface intended for
+// tests. Its chain starts at the genesis block of its chain parameters, and is
+// driven programmatically by connecting and disconnecting blocks, adding
+// transactions to its mempool, and triggering reorgs. It dispatches the same
+// notifications as the bitcoind backend in response.
+//
+// All blocks and notifications are deterministic, so that tests driving a
+// SimClient in the same way always observe the same chain.
+type SimClient struct {
+	started int32 // To be used atomically.
+	stopped int32 // To be used atomically.
+
+	chainParams *chaincfg.Params
+
+	mtx sync.Mutex
+
+	// chain is the main chain, indexed by height.
+	chain []*wire.MsgBlock
+
+	// heights maps the hash of each main chain block to its height.
+	heights map[chainhash.Hash]int32
+
+	// blocks holds every block known to the client, including those
+	// disconnected from the main chain.
+	blocks map[chainhash.Hash]*wire.MsgBlock
+
+	// blocksCreated is the number of blocks created by the client, which
+	// is committed to by each block's coinbase to ensure blocks at the
+	// same height of competing chains differ.
+	blocksCreated uint32
+
+	// mempool holds the unconfirmed transactions, with mempoolOrder
+	// tracking the order in which they were added.
+	mempool      map[chainhash.Hash]*wire.MsgTx
+	mempoolOrder []chainhash.Hash
+
+	// watchedAddrs and watchedOutPoints determine which transactions are
+	// relevant to the caller.
+	watchedAddrs     map[string]struct{}
+	watchedOutPoints map[wire.OutPoint]struct{}
+
+	notifyBlocks      bool
+	notificationQueue *ConcurrentQueue
+
+	// timestamps caches the heights resolved by BlockHeightForTimestamp.
+	timestamps timestampResolver
+
+	quit chan struct{}
+}
+
+// Compile time check to ensure SimClient satisfies the chain.Interface
+// interface.
+var _ Interface = (*SimClient)(nil)
+
+// NewSimClient creates a SimClient whose chain consists of the genesis block
+// of the given chain parameters.
+func NewSimClient(chainParams *chaincfg.Params) *SimClient {
+	genesis := chainParams.GenesisBlock
+	genesisHash := genesis.BlockHash()
+
+	c := &SimClient{
+		chainParams: chainParams,
+		chain:       []*wire.MsgBlock{genesis},
+		heights:     map[chainhash.Hash]int32{genesisHash: 0},
+		blocks: map[chainhash.Hash]*wire.MsgBlock{
+			genesisHash: genesis,
+		},
+		mempool:           make(map[chainhash.Hash]*wire.MsgTx),
+		watchedAddrs:      make(map[string]struct{}),
+		watchedOutPoints:  make(map[wire.OutPoint]struct{}),
+		notificationQueue: NewConcurrentQueue(20),
+		quit:              make(chan struct{}),
+	}
+
+	// The queue is started right away, so that the chain can be driven
+	// before the client is started.
+	c.notificationQueue.Start()
+
+	return c
+}
+
+// ConnectBlock extends the main chain with a block containing a coinbase
+// transaction followed by the given transactions, which are removed from the
+// mempool along with any conflicting ones. The new block is returned.
+func (c *SimClient) ConnectBlock(txs ...*wire.MsgTx) *wire.MsgBlock {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.connectBlock(txs)
+}
+
+// DisconnectBlock disconnects the tip of the main chain, returning the
+// transactions it confirmed, other than its coinbase, to the mempool. The
+// genesis block can't be disconnected.
+func (c *SimClient) DisconnectBlock() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.disconnectBlock()
+}
+
+// Reorg disconnects the given number of blocks from the tip of the main chain,
+// and then connects a block for each of the given sets of transactions, as
+// done by ConnectBlock. For the new chain to have more work than the old one,
+// as is the case for reorgs of real chains, more blocks must be connected than
+// disconnected. The blocks connected are returned.
+func (c *SimClient) Reorg(depth int, blocks ...[]*wire.MsgTx) ([]*wire.MsgBlock,
+	error) {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if depth >= len(c.chain) {
+		return nil, fmt.Errorf("unable to disconnect %d blocks from "+
+			"chain of height %d", depth, len(c.chain)-1)
+	}
+
+	for i := 0; i < depth; i++ {
+		if err := c.disconnectBlock(); err != nil {
+			return nil, err
+		}
+	}
+
+	connected := make([]*wire.MsgBlock, 0, len(blocks))
+	for _, txs := range blocks {
+		connected = append(connected, c.connectBlock(txs))
+	}
+
+	return connected, nil
+}
+
+// AddMempoolTx adds a transaction to the mempool, dispatching a RelevantTx
+// notification if it's relevant to the caller.
+func (c *SimClient) AddMempoolTx(tx *wire.MsgTx) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.addMempoolTx(tx)
+
+	if c.filterTx(tx) {
+		c.notifyRelevantTx(tx, nil)
+	}
+}
+
+// MempoolTxs returns the transactions in the mempool, in the order they were
+// added.
+func (c *SimClient) MempoolTxs() []*wire.MsgTx {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	txs := make([]*wire.MsgTx, 0, len(c.mempoolOrder))
+	for _, txHash := range c.mempoolOrder {
+		txs = append(txs, c.mempool[txHash])
+	}
+	return txs
+}
+
+// Start dispatches a ClientConnected notification to the caller.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) Start() error {
+	if !atomic.CompareAndSwapInt32(&c.started, 0, 1) {
+		return nil
+	}
+
+	c.notify(ClientConnected{})
+
+	return nil
+}
+
+// Stop stops the client from dispatching notifications.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) Stop() {
+	if !atomic.CompareAndSwapInt32(&c.stopped, 0, 1) {
+		return
+	}
+
+	close(c.quit)
+	c.notificationQueue.Stop()
+}
+
+// WaitForShutdown returns immediately, as the client has no goroutines of its
+// own.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) WaitForShutdown() {}
+
+// GetBestBlock returns the hash and height of the tip of the main chain.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) GetBestBlock() (*chainhash.Hash, int32, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	tipHash := c.chain[len(c.chain)-1].BlockHash()
+	return &tipHash, int32(len(c.chain) - 1), nil
+}
+
+// GetBlock returns the block with the given hash, which may no longer be part
+// of the main chain.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) GetBlock(hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	block, ok := c.blocks[*hash]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrSimBlockNotFound, hash)
+	}
+	return block, nil
+}
+
+// GetBlockHash returns the hash of the main chain block at the given height.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) GetBlockHash(height int64) (*chainhash.Hash, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if height < 0 || height >= int64(len(c.chain)) {
+		return nil, fmt.Errorf("%w: height %d", ErrSimBlockNotFound,
+			height)
+	}
+	hash := c.chain[height].BlockHash()
+	return &hash, nil
+}
+
+// GetBlockHeight returns the height of the main chain block with the given
+// hash.
+func (c *SimClient) GetBlockHeight(hash *chainhash.Hash) (int32, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	height, ok := c.heights[*hash]
+	if !ok {
+		return 0, fmt.Errorf("%w: %v", ErrSimBlockNotFound, hash)
+	}
+	return height, nil
+}
+
+// GetBlockHeader returns the header of the block with the given hash, which
+// may no longer be part of the main chain.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) GetBlockHeader(
+	hash *chainhash.Hash) (*wire.BlockHeader, error) {
+
+	block, err := c.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	header := block.Header
+	return &header, nil
+}
+
+// BlockHeightForTimestamp returns the height of the first main chain block
+// with a timestamp at or after the given one, or the height of the tip if the
+// timestamp is after it.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) BlockHeightForTimestamp(ts time.Time) (int32, error) {
+	_, tipHeight, err := c.GetBestBlock()
+	if err != nil {
+		return 0, err
+	}
+
+	return c.timestamps.resolve(ts, tipHeight, fetchHeaderByHeight(c))
+}
+
+// GetMerkleProof returns a merkle proof of the inclusion of the transaction
+// within its main chain block, or ErrTxNotConfirmed if it's within the mempool.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) GetMerkleProof(txHash chainhash.Hash) (*MerkleProof,
+	error) {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if _, ok := c.mempool[txHash]; ok {
+		return nil, ErrTxNotConfirmed
+	}
+	for _, block := range c.chain {
+		for _, tx := range block.Transactions {
+			if tx.TxHash() == txHash {
+				return newMerkleProof(block, txHash)
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("transaction %v not found", txHash)
+}
+
+// IsCurrent returns true, as the client's chain is always considered synced.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) IsCurrent() bool {
+	return true
+}
+
+// FilterBlocks scans the blocks contained in the FilterBlocksRequest for any
+// addresses of interest. For each requested block, the corresponding compact
+// filter will first be checked for matches, skipping those that do not report
+// anything. If the filter returns a positive match, the full block will be
+// fetched and filtered. This method returns a FilterBlocksResponse for the
+// first block containing a matching address. If no matches are found in the
+// range of blocks requested, the returned response will be nil.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) FilterBlocks(
+	req *FilterBlocksRequest) (*FilterBlocksResponse, error) {
+
+	blockFilterer := NewBlockFilterer(c.chainParams, req)
+
+	for i, block := range req.Blocks {
+		rawBlock, err := c.GetBlock(&block.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if !blockFilterer.FilterBlock(rawBlock) {
+			continue
+		}
+
+		resp := &FilterBlocksResponse{
+			BatchIndex:         uint32(i),
+			BlockMeta:          block,
+			FoundExternalAddrs: blockFilterer.FoundExternal,
+			FoundInternalAddrs: blockFilterer.FoundInternal,
+			FoundOutPoints:     blockFilterer.FoundOutPoints,
+			RelevantTxns:       blockFilterer.RelevantTxns,
+		}
+
+		return resp, nil
+	}
+
+	// No addresses were found for this range.
+	return nil, nil
+}
+
+// BlockStamp returns the tip of the main chain.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) BlockStamp() (*waddrmgr.BlockStamp, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	tip := c.chain[len(c.chain)-1]
+	return &waddrmgr.BlockStamp{
+		Hash:      tip.BlockHash(),
+		Height:    int32(len(c.chain) - 1),
+		Timestamp: tip.Header.Timestamp,
+	}, nil
+}
+
+// SendRawTransaction adds the transaction to the mempool as done by
+// AddMempoolTx.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) SendRawTransaction(tx *wire.MsgTx,
+	_ bool) (*chainhash.Hash, error) {
+
+	c.AddMempoolTx(tx)
+
+	txHash := tx.TxHash()
+	return &txHash, nil
+}
+
+// Rescan adds the given addresses and outpoints to the client's watch list,
+// and then dispatches notifications for every main chain block after the one
+// with the given hash, followed by a RescanFinished notification.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) Rescan(startHash *chainhash.Hash, addrs []btcutil.Address,
+	outPoints map[wire.OutPoint]btcutil.Address) error {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	startHeight, ok := c.heights[*startHash]
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrSimBlockNotFound, startHash)
+	}
+
+	for _, addr := range addrs {
+		c.watchedAddrs[addr.EncodeAddress()] = struct{}{}
+	}
+	for op := range outPoints {
+		c.watchedOutPoints[op] = struct{}{}
+	}
+
+	for height := startHeight + 1; height < int32(len(c.chain)); height++ {
+		c.notifyBlockConnected(c.chain[height], height)
+	}
+
+	tip := c.chain[len(c.chain)-1]
+	tipHash := tip.BlockHash()
+	c.notify(&RescanFinished{
+		Hash:   &tipHash,
+		Height: int32(len(c.chain) - 1),
+		Time:   tip.Header.Timestamp,
+	})
+
+	return nil
+}
+
+// NotifyReceived adds the given addresses to the client's watch list.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) NotifyReceived(addrs []btcutil.Address) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, addr := range addrs {
+		c.watchedAddrs[addr.EncodeAddress()] = struct{}{}
+	}
+
+	return nil
+}
+
+// NotifyBlocks enables the dispatching of block notifications.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) NotifyBlocks() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.notifyBlocks = true
+
+	return nil
+}
+
+// Notifications returns a channel to retrieve notifications from.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) Notifications() <-chan interface{} {
+	return c.notificationQueue.ChanOut()
+}
+
+// BackEnd returns the name of the driver.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *SimClient) BackEnd() string {
+	return "sim"
+}
+
+// connectBlock extends the main chain with a block confirming the given
+// transactions.
+//
+// NOTE: This must be called with the client's mutex held.
+func (c *SimClient) connectBlock(txs []*wire.MsgTx) *wire.MsgBlock {
+	tip := c.chain[len(c.chain)-1]
+	height := int32(len(c.chain))
+
+	// The coinbase commits to the block's height, along with the number
+	// of blocks created so far to ensure this block differs from any
+	// other at the same height.
+	var sigScript [8]byte
+	binary.BigEndian.PutUint32(sigScript[:4], uint32(height))
+	binary.BigEndian.PutUint32(sigScript[4:], c.blocksCreated)
+	c.blocksCreated++
+
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(
+		wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex),
+		sigScript[:], nil,
+	))
+	coinbase.AddTxOut(wire.NewTxOut(
+		blockchain.CalcBlockSubsidy(height, c.chainParams),
+		[]byte{txscript.OP_TRUE},
+	))
+
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   1,
+			PrevBlock: tip.BlockHash(),
+			Timestamp: tip.Header.Timestamp.Add(simBlockInterval),
+			Bits:      c.chainParams.PowLimitBits,
+		},
+		Transactions: append([]*wire.MsgTx{coinbase}, txs...),
+	}
+	merkles := blockchain.BuildMerkleTreeStore(
+		btcutil.NewBlock(block).Transactions(), false,
+	)
+	block.Header.MerkleRoot = *merkles[len(merkles)-1]
+
+	blockHash := block.BlockHash()
+	c.chain = append(c.chain, block)
+	c.heights[blockHash] = height
+	c.blocks[blockHash] = block
+
+	c.removeConfirmedMempoolTxs(block)
+	c.notifyBlockConnected(block, height)
+
+	return block
+}
+
+// disconnectBlock disconnects the tip of the main chain.
+//
+// NOTE: This must be called with the client's mutex held.
+func (c *SimClient) disconnectBlock() error {
+	if len(c.chain) == 1 {
+		return errors.New("unable to disconnect genesis block")
+	}
+
+	height := int32(len(c.chain) - 1)
+	block := c.chain[height]
+	blockHash := block.BlockHash()
+
+	c.chain = c.chain[:height]
+	delete(c.heights, blockHash)
+	c.timestamps.blockDisconnected(height)
+
+	for _, tx := range block.Transactions[1:] {
+		c.addMempoolTx(tx)
+	}
+
+	if c.notifyBlocks {
+		c.notify(BlockDisconnected{
+			Block: wtxmgr.Block{
+				Hash:   blockHash,
+				Height: height,
+			},
+			Time: block.Header.Timestamp,
+		})
+	}
+
+	return nil
+}
+
+// addMempoolTx adds the transaction to the mempool if not already in it.
+//
+// NOTE: This must be called with the client's mutex held.
+func (c *SimClient) addMempoolTx(tx *wire.MsgTx) {
+	txHash := tx.TxHash()
+	if _, ok := c.mempool[txHash]; ok {
+		return
+	}
+
+	c.mempool[txHash] = tx
+	c.mempoolOrder = append(c.mempoolOrder, txHash)
+}
+
+// removeConfirmedMempoolTxs removes the transactions confirmed by the block
+// from the mempool, along with those spending the same outputs.
+//
+// NOTE: This must be called with the client's mutex held.
+func (c *SimClient) removeConfirmedMempoolTxs(block *wire.MsgBlock) {
+	confirmed := make(map[chainhash.Hash]struct{}, len(block.Transactions))
+	spent := make(map[wire.OutPoint]struct{})
+	for _, tx := range block.Transactions {
+		confirmed[tx.TxHash()] = struct{}{}
+		for _, txIn := range tx.TxIn {
+			spent[txIn.PreviousOutPoint] = struct{}{}
+		}
+	}
+
+	order := c.mempoolOrder[:0]
+	for _, txHash := range c.mempoolOrder {
+		_, remove := confirmed[txHash]
+		for _, txIn := range c.mempool[txHash].TxIn {
+			if _, ok := spent[txIn.PreviousOutPoint]; ok {
+				remove = true
+			}
+		}
+
+		if remove {
+			delete(c.mempool, txHash)
+			continue
+		}
+		order = append(order, txHash)
+	}
+	c.mempoolOrder = order
+}
+
+// filterTx determines whether the transaction is relevant to the caller, as it
+// either spends a watched outpoint or pays to a watched address. The outputs
+// paying to watched addresses are added to the watched outpoints.
+//
+// NOTE: This must be called with the client's mutex held.
+func (c *SimClient) filterTx(tx *wire.MsgTx) bool {
+	var isRelevant bool
+	for _, txIn := range tx.TxIn {
+		if _, ok := c.watchedOutPoints[txIn.PreviousOutPoint]; ok {
+			isRelevant = true
+			break
+		}
+	}
+
+	for i, txOut := range tx.TxOut {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			txOut.PkScript, c.chainParams,
+		)
+		if err != nil {
+			// Non-standard outputs can be safely skipped.
+			continue
+		}
+
+		for _, addr := range addrs {
+			if _, ok := c.watchedAddrs[addr.EncodeAddress()]; ok {
+				isRelevant = true
+				op := wire.OutPoint{
+					Hash:  tx.TxHash(),
+					Index: uint32(i),
+				}
+				c.watchedOutPoints[op] = struct{}{}
+			}
+		}
+	}
+
+	return isRelevant
+}
+
+// notifyBlockConnected dispatches a RelevantTx notification for each of the
+// block's relevant transactions, followed by FilteredBlockConnected and
+// BlockConnected notifications if block notifications are enabled.
+//
+// NOTE: This must be called with the client's mutex held.
+func (c *SimClient) notifyBlockConnected(block *wire.MsgBlock, height int32) {
+	blockMeta := &wtxmgr.BlockMeta{
+		Block: wtxmgr.Block{
+			Hash:   block.BlockHash(),
+			Height: height,
+		},
+		Time: block.Header.Timestamp,
+	}
+
+	var relevantTxs []*wtxmgr.TxRecord
+	for _, tx := range block.Transactions {
+		if !c.filterTx(tx) {
+			continue
+		}
+
+		rec := c.notifyRelevantTx(tx, blockMeta)
+		if rec != nil {
+			relevantTxs = append(relevantTxs, rec)
+		}
+	}
+
+	if !c.notifyBlocks {
+		return
+	}
+
+	c.notify(FilteredBlockConnected{
+		Block:       blockMeta,
+		RelevantTxs: relevantTxs,
+	})
+	c.notify(BlockConnected(*blockMeta))
+}
+
+// notifyRelevantTx dispatches a RelevantTx notification for the transaction,
+// which is unconfirmed if block is nil. The transaction's record is returned.
+//
+// NOTE: This must be called with the client's mutex held.
+func (c *SimClient) notifyRelevantTx(tx *wire.MsgTx,
+	block *wtxmgr.BlockMeta) *wtxmgr.TxRecord {
+
+	received := time.Unix(0, 0)
+	if block != nil {
+		received = block.Time
+	}
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, received)
+	if err != nil {
+		log.Errorf("Cannot create transaction record for relevant "+
+			"tx: %v", err)
+		return nil
+	}
+
+	c.notify(RelevantTx{
+		TxRecord: rec,
+		Block:    block,
+	})
+
+	return rec
+}
+
+// notify queues a notification to the caller.
+func (c *SimClient) notify(ntfn interface{}) {
+	select {
+	case c.notificationQueue.ChanIn() <- ntfn:
+	case <-c.quit:
+	}
+}
@@ -0,0 +1,144 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/require"
+)
+
+// nextSimNotification returns the next notification dispatched by the client.
+func nextSimNotification(t *testing.T, c *SimClient) interface{} {
+	t.Helper()
+
+	select {
+	case ntfn := <-c.Notifications():
+		return ntfn
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected notification")
+		return nil
+	}
+}
+
+// TestSimClient ensures that driving a SimClient's chain dispatches the same
+// notifications as the other backends.
+func TestSimClient(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.RegressionNetParams
+	c := NewSimClient(params)
+	require.NoError(t, c.Start())
+	defer c.Stop()
+	require.IsType(t, ClientConnected{}, nextSimNotification(t, c))
+
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), params,
+	)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	require.NoError(t, c.NotifyBlocks())
+	require.NoError(t, c.NotifyReceived([]btcutil.Address{addr}))
+
+	// A mempool transaction paying to a watched address should be notified
+	// as unconfirmed.
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{1}}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, pkScript))
+	c.AddMempoolTx(tx)
+
+	relevant := nextSimNotification(t, c).(RelevantTx)
+	require.Equal(t, tx.TxHash(), relevant.TxRecord.Hash)
+	require.Nil(t, relevant.Block)
+
+	// Confirming it should notify it along with the block connected, and
+	// remove it from the mempool.
+	block1 := c.ConnectBlock(tx)
+	block1Hash := block1.BlockHash()
+
+	relevant = nextSimNotification(t, c).(RelevantTx)
+	require.Equal(t, tx.TxHash(), relevant.TxRecord.Hash)
+	require.Equal(t, block1Hash, relevant.Block.Hash)
+	require.EqualValues(t, 1, relevant.Block.Height)
+
+	filtered := nextSimNotification(t, c).(FilteredBlockConnected)
+	require.Equal(t, block1Hash, filtered.Block.Hash)
+	require.Len(t, filtered.RelevantTxs, 1)
+
+	connected := nextSimNotification(t, c).(BlockConnected)
+	require.Equal(t, block1Hash, connected.Hash)
+	require.Empty(t, c.MempoolTxs())
+
+	bestHash, bestHeight, err := c.GetBestBlock()
+	require.NoError(t, err)
+	require.Equal(t, block1Hash, *bestHash)
+	require.EqualValues(t, 1, bestHeight)
+
+	// Spending the output paying to the watched address should be
+	// relevant, as its outpoint is now watched.
+	spend := wire.NewMsgTx(wire.TxVersion)
+	spend.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: tx.TxHash(), Index: 0}, nil, nil,
+	))
+	spend.AddTxOut(wire.NewTxOut(900, []byte{txscript.OP_TRUE}))
+	c.AddMempoolTx(spend)
+
+	relevant = nextSimNotification(t, c).(RelevantTx)
+	require.Equal(t, spend.TxHash(), relevant.TxRecord.Hash)
+
+	// Reorging out the block should disconnect it, return its transaction
+	// to the mempool, and connect the blocks of the new chain.
+	newBlocks, err := c.Reorg(1, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, newBlocks, 2)
+	require.NotEqual(t, block1Hash, newBlocks[0].BlockHash())
+
+	disconnected := nextSimNotification(t, c).(BlockDisconnected)
+	require.Equal(t, block1Hash, disconnected.Hash)
+	require.EqualValues(t, 1, disconnected.Height)
+
+	for i, block := range newBlocks {
+		filtered := nextSimNotification(t, c).(FilteredBlockConnected)
+		require.Equal(t, block.BlockHash(), filtered.Block.Hash)
+		require.EqualValues(t, i+1, filtered.Block.Height)
+		require.Empty(t, filtered.RelevantTxs)
+
+		connected := nextSimNotification(t, c).(BlockConnected)
+		require.Equal(t, block.BlockHash(), connected.Hash)
+	}
+
+	mempool := c.MempoolTxs()
+	require.Len(t, mempool, 2)
+	require.Equal(t, spend.TxHash(), mempool[0].TxHash())
+	require.Equal(t, tx.TxHash(), mempool[1].TxHash())
+
+	// The stale block should remain retrievable by hash, but not by
+	// height.
+	_, err = c.GetBlock(&block1Hash)
+	require.NoError(t, err)
+	_, err = c.GetBlockHeight(&block1Hash)
+	require.True(t, errors.Is(err, ErrSimBlockNotFound))
+	hash, err := c.GetBlockHash(1)
+	require.NoError(t, err)
+	require.Equal(t, newBlocks[0].BlockHash(), *hash)
+
+	// Rescanning from the genesis block should notify every block after
+	// it, followed by the end of the rescan.
+	genesisHash := params.GenesisBlock.BlockHash()
+	require.NoError(t, c.Rescan(&genesisHash, nil, nil))
+	for _, block := range newBlocks {
+		filtered := nextSimNotification(t, c).(FilteredBlockConnected)
+		require.Equal(t, block.BlockHash(), filtered.Block.Hash)
+		require.IsType(t, BlockConnected{}, nextSimNotification(t, c))
+	}
+	finished := nextSimNotification(t, c).(*RescanFinished)
+	require.Equal(t, newBlocks[1].BlockHash(), *finished.Hash)
+	require.EqualValues(t, 2, finished.Height)
+}
@@ -0,0 +1,120 @@
+package chain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// maxTimestampCacheSize is the maximum number of timestamps whose
+	// resolved heights are cached by a timestampResolver.
+	maxTimestampCacheSize = 1000
+)
+
+// headerByHeight returns the header of the main chain block at the given
+// height.
+type headerByHeight func(height int32) (*wire.BlockHeader, error)
+
+// blockHeaderFetcher is implemented by the backends able to fetch main chain
+// block headers by height through their block hash.
+type blockHeaderFetcher interface {
+	GetBlockHash(int64) (*chainhash.Hash, error)
+	GetBlockHeader(*chainhash.Hash) (*wire.BlockHeader, error)
+}
+
+// fetchHeaderByHeight returns a headerByHeight fetching headers through the
+// backend's block hashes.
+func fetchHeaderByHeight(backend blockHeaderFetcher) headerByHeight {
+	return func(height int32) (*wire.BlockHeader, error) {
+		hash, err := backend.GetBlockHash(int64(height))
+		if err != nil {
+			return nil, err
+		}
+		return backend.GetBlockHeader(hash)
+	}
+}
+
+// timestampResolver maps timestamps to the height of the first main chain
+// block with a timestamp at or after them, caching the heights it has
+// resolved. Its zero value is ready to be used.
+type timestampResolver struct {
+	mtx   sync.Mutex
+	cache map[int64]int32
+}
+
+// resolve returns the height of the first block up to the tip with a
+// timestamp at or after the given one, or the tip's height if there is none.
+// The block is located through a binary search over the headers of the chain,
+// so as block timestamps aren't strictly increasing, a block close to the
+// first one may be returned for timestamps within a run of out-of-order
+// blocks.
+func (r *timestampResolver) resolve(ts time.Time, tipHeight int32,
+	header headerByHeight) (int32, error) {
+
+	// Block timestamps have a resolution of one second, so we'll round
+	// the timestamp up to the next second.
+	secs := ts.Unix()
+	if ts.Nanosecond() > 0 {
+		secs++
+	}
+	ts = time.Unix(secs, 0)
+
+	r.mtx.Lock()
+	height, ok := r.cache[secs]
+	r.mtx.Unlock()
+	if ok && height <= tipHeight {
+		return height, nil
+	}
+
+	// Timestamps after the tip are clamped to it. These aren't cached, as
+	// the height they resolve to changes as blocks are connected.
+	tip, err := header(tipHeight)
+	if err != nil {
+		return 0, err
+	}
+	if tip.Timestamp.Before(ts) {
+		return tipHeight, nil
+	}
+
+	low, high := int32(0), tipHeight
+	for low < high {
+		mid := low + (high-low)/2
+		midHeader, err := header(mid)
+		if err != nil {
+			return 0, err
+		}
+
+		if midHeader.Timestamp.Before(ts) {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+
+	r.mtx.Lock()
+	if r.cache == nil || len(r.cache) >= maxTimestampCacheSize {
+		r.cache = make(map[int64]int32)
+	}
+	r.cache[secs] = low
+	r.mtx.Unlock()
+
+	return low, nil
+}
+
+// blockDisconnected evicts the cached heights at or above the height of the
+// disconnected block, as the first block at or after their timestamp may
+// differ within the new main chain. The heights below it are still resolved
+// to the same blocks.
+func (r *timestampResolver) blockDisconnected(height int32) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for secs, cached := range r.cache {
+		if cached >= height {
+			delete(r.cache, secs)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package chain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBlockHeightForTimestamp ensures that timestamps are resolved to the
+// height of the first block at or after them, clamped to the tip.
+func TestBlockHeightForTimestamp(t *testing.T) {
+	t.Parallel()
+
+	c := NewSimClient(&chaincfg.RegressionNetParams)
+	defer c.Stop()
+
+	const numBlocks = 20
+	for i := 0; i < numBlocks; i++ {
+		c.ConnectBlock()
+	}
+
+	genesisTime := chaincfg.RegressionNetParams.GenesisBlock.Header.Timestamp
+	knownHash, err := c.GetBlockHash(7)
+	require.NoError(t, err)
+	knownHeader, err := c.GetBlockHeader(knownHash)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		ts     time.Time
+		height int32
+	}{{
+		name:   "known block",
+		ts:     knownHeader.Timestamp,
+		height: 7,
+	}, {
+		name:   "after previous block",
+		ts:     knownHeader.Timestamp.Add(-time.Second),
+		height: 7,
+	}, {
+		name:   "after known block",
+		ts:     knownHeader.Timestamp.Add(time.Millisecond),
+		height: 8,
+	}, {
+		name:   "before genesis",
+		ts:     genesisTime.Add(-time.Hour),
+		height: 0,
+	}, {
+		name:   "future",
+		ts:     time.Now().Add(time.Hour),
+		height: numBlocks,
+	}}
+	for _, test := range tests {
+		height, err := c.BlockHeightForTimestamp(test.ts)
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.height, height, test.name)
+	}
+
+	// Future timestamps should follow the tip as blocks are connected.
+	c.ConnectBlock()
+	height, err := c.BlockHeightForTimestamp(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.EqualValues(t, numBlocks+1, height)
+}
+
+// TestTimestampResolverCache ensures that resolved heights are cached until
+// their block is disconnected, while timestamps clamped to the tip aren't.
+func TestTimestampResolverCache(t *testing.T) {
+	t.Parallel()
+
+	genesis := time.Unix(1600000000, 0)
+	var fetched int
+	header := func(height int32) (*wire.BlockHeader, error) {
+		fetched++
+		return &wire.BlockHeader{
+			Timestamp: genesis.Add(
+				time.Duration(height) * 10 * time.Minute,
+			),
+		}, nil
+	}
+
+	var r timestampResolver
+	ts := genesis.Add(55 * time.Minute)
+	height, err := r.resolve(ts, 100, header)
+	require.NoError(t, err)
+	require.EqualValues(t, 6, height)
+	require.NotZero(t, fetched)
+
+	// Resolving the same timestamp again shouldn't fetch any headers.
+	fetched = 0
+	height, err = r.resolve(ts, 100, header)
+	require.NoError(t, err)
+	require.EqualValues(t, 6, height)
+	require.Zero(t, fetched)
+
+	// Nor should resolving it once the chain has grown.
+	height, err = r.resolve(ts, 200, header)
+	require.NoError(t, err)
+	require.EqualValues(t, 6, height)
+	require.Zero(t, fetched)
+
+	// Timestamps after the tip are clamped, and resolved anew as the tip
+	// moves.
+	future := genesis.Add(time.Duration(150) * 10 * time.Minute)
+	height, err = r.resolve(future, 100, header)
+	require.NoError(t, err)
+	require.EqualValues(t, 100, height)
+	height, err = r.resolve(future, 200, header)
+	require.NoError(t, err)
+	require.EqualValues(t, 150, height)
+
+	// Disconnecting a block evicts the heights resolved at or above it,
+	// which are resolved anew, while those below it are still cached.
+	r.blockDisconnected(150)
+	fetched = 0
+	height, err = r.resolve(ts, 200, header)
+	require.NoError(t, err)
+	require.EqualValues(t, 6, height)
+	require.Zero(t, fetched)
+
+	height, err = r.resolve(future, 200, header)
+	require.NoError(t, err)
+	require.EqualValues(t, 150, height)
+	require.NotZero(t, fetched)
+}
@@ -326,6 +326,19 @@ var helpDescsEnUS = map[string]string{
 	"signrawtransactionerror-txid":      "The transaction hash of the referenced previous output",
 	"signrawtransactionerror-vout":      "The output index of the referenced previous output",
 
+	// SignRawTransactionWithWalletCmd help.
+	"signrawtransactionwithwallet--synopsis": "Signs transaction inputs using private keys from this wallet.\n" +
+		"The valid sighashtype options are ALL, NONE, SINGLE, ALL|ANYONECANPAY, NONE|ANYONECANPAY, and SINGLE|ANYONECANPAY.\n" +
+		"The amount of previous outputs not tracked by this wallet is required to sign segwit inputs.",
+	"signrawtransactionwithwallet-rawtx":       "Unsigned or partially unsigned transaction to sign encoded as a hexadecimal string",
+	"signrawtransactionwithwallet-inputs":      "Previous outputs spent by the transaction that this wallet may not be tracking",
+	"signrawtransactionwithwallet-sighashtype": "Sighash type",
+
+	// SignRawTransactionWithWalletResult help.
+	"signrawtransactionwithwalletresult-hex":      "The resulting transaction encoded as a hexadecimal string",
+	"signrawtransactionwithwalletresult-complete": "Whether all input signatures have been created",
+	"signrawtransactionwithwalletresult-errors":   "Script verification errors (if exists)",
+
 	// ValidateAddressCmd help.
 	"validateaddress--synopsis": "Verify that an address is valid.\n" +
 		"Extra details are returned if the address is controlled by this wallet.\n" +
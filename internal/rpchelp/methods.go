@@ -56,6 +56,7 @@ var Methods = []struct {
 	{"settxfee", returnsBool},
 	{"signmessage", returnsString},
 	{"signrawtransaction", []interface{}{(*btcjson.SignRawTransactionResult)(nil)}},
+	{"signrawtransactionwithwallet", []interface{}{(*btcjson.SignRawTransactionWithWalletResult)(nil)}},
 	{"validateaddress", []interface{}{(*btcjson.ValidateAddressWalletResult)(nil)}},
 	{"verifymessage", returnsBool},
 	{"walletlock", nil},
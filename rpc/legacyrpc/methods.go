@@ -72,43 +72,44 @@ var rpcHandlers = map[string]struct {
 	noHelp bool
 }{
 	// Reference implementation wallet methods (implemented)
-	"addmultisigaddress":     {handler: addMultiSigAddress},
-	"createmultisig":         {handler: createMultiSig},
-	"dumpprivkey":            {handler: dumpPrivKey},
-	"getaccount":             {handler: getAccount},
-	"getaccountaddress":      {handler: getAccountAddress},
-	"getaddressesbyaccount":  {handler: getAddressesByAccount},
-	"getbalance":             {handler: getBalance},
-	"getbestblockhash":       {handler: getBestBlockHash},
-	"getblockcount":          {handler: getBlockCount},
-	"getinfo":                {handlerWithChain: getInfo},
-	"getnewaddress":          {handler: getNewAddress},
-	"getrawchangeaddress":    {handler: getRawChangeAddress},
-	"getreceivedbyaccount":   {handler: getReceivedByAccount},
-	"getreceivedbyaddress":   {handler: getReceivedByAddress},
-	"gettransaction":         {handler: getTransaction},
-	"help":                   {handler: helpNoChainRPC, handlerWithChain: helpWithChainRPC},
-	"importprivkey":          {handler: importPrivKey},
-	"keypoolrefill":          {handler: keypoolRefill},
-	"listaccounts":           {handler: listAccounts},
-	"listlockunspent":        {handler: listLockUnspent},
-	"listreceivedbyaccount":  {handler: listReceivedByAccount},
-	"listreceivedbyaddress":  {handler: listReceivedByAddress},
-	"listsinceblock":         {handlerWithChain: listSinceBlock},
-	"listtransactions":       {handler: listTransactions},
-	"listunspent":            {handler: listUnspent},
-	"lockunspent":            {handler: lockUnspent},
-	"sendfrom":               {handlerWithChain: sendFrom},
-	"sendmany":               {handler: sendMany},
-	"sendtoaddress":          {handler: sendToAddress},
-	"settxfee":               {handler: setTxFee},
-	"signmessage":            {handler: signMessage},
-	"signrawtransaction":     {handlerWithChain: signRawTransaction},
-	"validateaddress":        {handler: validateAddress},
-	"verifymessage":          {handler: verifyMessage},
-	"walletlock":             {handler: walletLock},
-	"walletpassphrase":       {handler: walletPassphrase},
-	"walletpassphrasechange": {handler: walletPassphraseChange},
+	"addmultisigaddress":           {handler: addMultiSigAddress},
+	"createmultisig":               {handler: createMultiSig},
+	"dumpprivkey":                  {handler: dumpPrivKey},
+	"getaccount":                   {handler: getAccount},
+	"getaccountaddress":            {handler: getAccountAddress},
+	"getaddressesbyaccount":        {handler: getAddressesByAccount},
+	"getbalance":                   {handler: getBalance},
+	"getbestblockhash":             {handler: getBestBlockHash},
+	"getblockcount":                {handler: getBlockCount},
+	"getinfo":                      {handlerWithChain: getInfo},
+	"getnewaddress":                {handler: getNewAddress},
+	"getrawchangeaddress":          {handler: getRawChangeAddress},
+	"getreceivedbyaccount":         {handler: getReceivedByAccount},
+	"getreceivedbyaddress":         {handler: getReceivedByAddress},
+	"gettransaction":               {handler: getTransaction},
+	"help":                         {handler: helpNoChainRPC, handlerWithChain: helpWithChainRPC},
+	"importprivkey":                {handler: importPrivKey},
+	"keypoolrefill":                {handler: keypoolRefill},
+	"listaccounts":                 {handler: listAccounts},
+	"listlockunspent":              {handler: listLockUnspent},
+	"listreceivedbyaccount":        {handler: listReceivedByAccount},
+	"listreceivedbyaddress":        {handler: listReceivedByAddress},
+	"listsinceblock":               {handlerWithChain: listSinceBlock},
+	"listtransactions":             {handler: listTransactions},
+	"listunspent":                  {handler: listUnspent},
+	"lockunspent":                  {handler: lockUnspent},
+	"sendfrom":                     {handlerWithChain: sendFrom},
+	"sendmany":                     {handler: sendMany},
+	"sendtoaddress":                {handler: sendToAddress},
+	"settxfee":                     {handler: setTxFee},
+	"signmessage":                  {handler: signMessage},
+	"signrawtransaction":           {handlerWithChain: signRawTransaction},
+	"signrawtransactionwithwallet": {handler: signRawTransactionWithWallet},
+	"validateaddress":              {handler: validateAddress},
+	"verifymessage":                {handler: verifyMessage},
+	"walletlock":                   {handler: walletLock},
+	"walletpassphrase":             {handler: walletPassphrase},
+	"walletpassphrasechange":       {handler: walletPassphraseChange},
 
 	// Reference implementation methods (still unimplemented)
 	"backupwallet":         {handler: unimplemented, noHelp: true},
@@ -342,8 +343,9 @@ func addMultiSigAddress(icmd interface{}, w *wallet.Wallet) (interface{}, error)
 		return nil, err
 	}
 
+	// Adding an already known multisig address isn't an error.
 	p2shAddr, err := w.ImportP2SHRedeemScript(script)
-	if err != nil {
+	if err != nil && !errors.Is(err, wallet.ErrAddressAlreadyImported) {
 		return nil, err
 	}
 
@@ -615,7 +617,7 @@ func importPrivKey(icmd interface{}, w *wallet.Wallet) (interface{}, error) {
 	// Import the private key, handling any errors.
 	_, err = w.ImportPrivateKey(waddrmgr.KeyScopeBIP0044, wif, nil, *cmd.Rescan)
 	switch {
-	case waddrmgr.IsError(err, waddrmgr.ErrDuplicateAddress):
+	case errors.Is(err, wallet.ErrAddressAlreadyImported):
 		// Do not return duplicate key errors to the client.
 		return nil, nil
 	case waddrmgr.IsError(err, waddrmgr.ErrLocked):
@@ -888,13 +890,18 @@ func getTransaction(icmd interface{}, w *wallet.Wallet) (interface{}, error) {
 			}
 		}
 
+		category := credCat
+		if cred.SelfTransfer {
+			category = wallet.CreditSelfTransfer.String()
+		}
+
 		ret.Details = append(ret.Details, btcjson.GetTransactionDetailsResult{
 			// Fields left zeroed:
 			//   InvolvesWatchOnly
 			//   Fee
 			Account:  accountName,
 			Address:  address,
-			Category: credCat,
+			Category: category,
 			Amount:   cred.Amount.ToBTC(),
 			Vout:     cred.Index,
 		})
@@ -1300,7 +1307,43 @@ func listUnspent(icmd interface{}, w *wallet.Wallet) (interface{}, error) {
 		}
 	}
 
-	return w.ListUnspent(int32(*cmd.MinConf), int32(*cmd.MaxConf), "")
+	unspent, err := w.ListUnspent(int32(*cmd.MinConf), int32(*cmd.MaxConf), "")
+	if err != nil {
+		return nil, err
+	}
+
+	frozenOutputs, err := w.FrozenOutputs()
+	if err != nil {
+		return nil, err
+	}
+	frozen := make(map[wire.OutPoint]struct{}, len(frozenOutputs))
+	for _, op := range frozenOutputs {
+		frozen[op] = struct{}{}
+	}
+
+	results := make([]listUnspentResult, 0, len(unspent))
+	for _, result := range unspent {
+		txHash, err := chainhash.NewHashFromStr(result.TxID)
+		if err != nil {
+			return nil, err
+		}
+		op := wire.OutPoint{Hash: *txHash, Index: result.Vout}
+		_, isFrozen := frozen[op]
+
+		results = append(results, listUnspentResult{
+			ListUnspentResult: result,
+			Frozen:            isFrozen,
+		})
+	}
+
+	return results, nil
+}
+
+// listUnspentResult is a listunspent result that additionally flags frozen
+// outputs, which are excluded from coin selection.
+type listUnspentResult struct {
+	*btcjson.ListUnspentResult
+	Frozen bool `json:"frozen,omitempty"`
 }
 
 // lockUnspent handles the lockunspent command.
@@ -1554,6 +1597,28 @@ func signMessage(icmd interface{}, w *wallet.Wallet) (interface{}, error) {
 	return base64.StdEncoding.EncodeToString(sigbytes), nil
 }
 
+// parseSigHashType parses the sighash flags of the signrawtransaction and
+// signrawtransactionwithwallet commands.
+func parseSigHashType(flags string) (txscript.SigHashType, error) {
+	switch flags {
+	case "ALL":
+		return txscript.SigHashAll, nil
+	case "NONE":
+		return txscript.SigHashNone, nil
+	case "SINGLE":
+		return txscript.SigHashSingle, nil
+	case "ALL|ANYONECANPAY":
+		return txscript.SigHashAll | txscript.SigHashAnyOneCanPay, nil
+	case "NONE|ANYONECANPAY":
+		return txscript.SigHashNone | txscript.SigHashAnyOneCanPay, nil
+	case "SINGLE|ANYONECANPAY":
+		return txscript.SigHashSingle | txscript.SigHashAnyOneCanPay, nil
+	default:
+		e := errors.New("invalid sighash parameter")
+		return 0, InvalidParameterError{e}
+	}
+}
+
 // signRawTransaction handles the signrawtransaction command.
 func signRawTransaction(icmd interface{}, w *wallet.Wallet, chainClient *chain.RPCClient) (interface{}, error) {
 	cmd := icmd.(*btcjson.SignRawTransactionCmd)
@@ -1569,23 +1634,9 @@ func signRawTransaction(icmd interface{}, w *wallet.Wallet, chainClient *chain.R
 		return nil, DeserializationError{e}
 	}
 
-	var hashType txscript.SigHashType
-	switch *cmd.Flags {
-	case "ALL":
-		hashType = txscript.SigHashAll
-	case "NONE":
-		hashType = txscript.SigHashNone
-	case "SINGLE":
-		hashType = txscript.SigHashSingle
-	case "ALL|ANYONECANPAY":
-		hashType = txscript.SigHashAll | txscript.SigHashAnyOneCanPay
-	case "NONE|ANYONECANPAY":
-		hashType = txscript.SigHashNone | txscript.SigHashAnyOneCanPay
-	case "SINGLE|ANYONECANPAY":
-		hashType = txscript.SigHashSingle | txscript.SigHashAnyOneCanPay
-	default:
-		e := errors.New("invalid sighash parameter")
-		return nil, InvalidParameterError{e}
+	hashType, err := parseSigHashType(*cmd.Flags)
+	if err != nil {
+		return nil, err
 	}
 
 	// TODO: really we probably should look these up with btcd anyway to
@@ -1709,6 +1760,19 @@ func signRawTransaction(icmd interface{}, w *wallet.Wallet, chainClient *chain.R
 		panic(err)
 	}
 
+	signErrors := signRawTransactionErrors(&tx, signErrs)
+	return btcjson.SignRawTransactionResult{
+		Hex:      hex.EncodeToString(buf.Bytes()),
+		Complete: len(signErrors) == 0,
+		Errors:   signErrors,
+	}, nil
+}
+
+// signRawTransactionErrors converts the signature errors of a signed
+// transaction to their JSON-RPC representation.
+func signRawTransactionErrors(tx *wire.MsgTx,
+	signErrs []wallet.SignatureError) []btcjson.SignRawTransactionError {
+
 	signErrors := make([]btcjson.SignRawTransactionError, 0, len(signErrs))
 	for _, e := range signErrs {
 		input := tx.TxIn[e.InputIndex]
@@ -1720,8 +1784,93 @@ func signRawTransaction(icmd interface{}, w *wallet.Wallet, chainClient *chain.R
 			Error:     e.Error.Error(),
 		})
 	}
+	return signErrors
+}
 
-	return btcjson.SignRawTransactionResult{
+// signRawTransactionWithWallet handles the signrawtransactionwithwallet
+// command. Unlike signrawtransaction, only the wallet's keys are used, and the
+// previous outputs not described by the request must be known to the wallet.
+func signRawTransactionWithWallet(icmd interface{},
+	w *wallet.Wallet) (interface{}, error) {
+
+	cmd := icmd.(*btcjson.SignRawTransactionWithWalletCmd)
+
+	serializedTx, err := decodeHexStr(cmd.RawTx)
+	if err != nil {
+		return nil, err
+	}
+	var tx wire.MsgTx
+	err = tx.Deserialize(bytes.NewBuffer(serializedTx))
+	if err != nil {
+		e := errors.New("TX decode failed")
+		return nil, DeserializationError{e}
+	}
+
+	hashType, err := parseSigHashType(*cmd.SigHashType)
+	if err != nil {
+		return nil, err
+	}
+
+	prevOuts := make(map[wire.OutPoint]*wallet.RawTxPrevOut)
+	var cmdInputs []btcjson.RawTxWitnessInput
+	if cmd.Inputs != nil {
+		cmdInputs = *cmd.Inputs
+	}
+	for _, rti := range cmdInputs {
+		inputHash, err := chainhash.NewHashFromStr(rti.Txid)
+		if err != nil {
+			return nil, DeserializationError{err}
+		}
+
+		// Witness scripts can only be signed for with keys provided
+		// alongside them, which this command doesn't take.
+		if rti.WitnessScript != nil {
+			e := errors.New("witness scripts are not supported")
+			return nil, InvalidParameterError{e}
+		}
+
+		prevOut := &wallet.RawTxPrevOut{}
+		prevOut.PkScript, err = decodeHexStr(rti.ScriptPubKey)
+		if err != nil {
+			return nil, err
+		}
+		if rti.RedeemScript != nil {
+			prevOut.RedeemScript, err = decodeHexStr(
+				*rti.RedeemScript,
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if rti.Amount != nil {
+			prevOut.Amount, err = btcutil.NewAmount(*rti.Amount)
+			if err != nil {
+				return nil, InvalidParameterError{err}
+			}
+		}
+
+		prevOuts[wire.OutPoint{
+			Hash:  *inputHash,
+			Index: rti.Vout,
+		}] = prevOut
+	}
+
+	signErrs, err := w.SignRawTransaction(&tx, hashType, prevOuts)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(tx.SerializeSize())
+
+	// All returned errors (not OOM, which panics) encountered during
+	// bytes.Buffer writes are unexpected.
+	if err = tx.Serialize(&buf); err != nil {
+		panic(err)
+	}
+
+	signErrors := signRawTransactionErrors(&tx, signErrs)
+	return btcjson.SignRawTransactionWithWalletResult{
 		Hex:      hex.EncodeToString(buf.Bytes()),
 		Complete: len(signErrors) == 0,
 		Errors:   signErrors,
@@ -1759,14 +1908,14 @@ func validateAddress(icmd interface{}, w *wallet.Wallet) (interface{}, error) {
 	// information about it available and it is "mine".
 	result.IsMine = true
 	acctName, err := w.AccountName(
-		waddrmgr.KeyScopeBIP0044, ainfo.InternalAccount(),
+		waddrmgr.KeyScopeBIP0044, ainfo.Account,
 	)
 	if err != nil {
 		return nil, &ErrAccountNameNotFound
 	}
 	result.Account = acctName
 
-	switch ma := ainfo.(type) {
+	switch ma := ainfo.Address.(type) {
 	case waddrmgr.ManagedPubKeyAddress:
 		result.IsCompressed = ma.Compressed()
 		result.PubKey = ma.ExportPubKey()
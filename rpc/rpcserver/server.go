@@ -44,10 +44,10 @@ import (
 
 // Public API version constants
 const (
-	semverString = "2.0.1"
+	semverString = "2.1.0"
 	semverMajor  = 2
-	semverMinor  = 0
-	semverPatch  = 1
+	semverMinor  = 1
+	semverPatch  = 0
 )
 
 // translateError creates a new gRPC error with an appropriate error code for
@@ -82,6 +82,10 @@ func errorCode(err error) codes.Code {
 		err = e.Err
 	}
 
+	if errors.Is(err, wallet.ErrAddressAlreadyImported) {
+		return codes.AlreadyExists
+	}
+
 	switch err {
 	case wallet.ErrLoaded:
 		return codes.FailedPrecondition
@@ -577,7 +581,30 @@ func marshalHashes(v []*chainhash.Hash) [][]byte {
 func (s *walletServer) TransactionNotifications(req *pb.TransactionNotificationsRequest,
 	svr pb.WalletService_TransactionNotificationsServer) error {
 
-	n := s.wallet.NtfnServer.TransactionNotifications()
+	var n wallet.TransactionNotificationsClient
+	if req.ResumeBlockHash != nil {
+		resumeHash, err := chainhash.NewHash(req.ResumeBlockHash)
+		if err != nil {
+			return status.Errorf(
+				codes.InvalidArgument, "%s", err.Error(),
+			)
+		}
+		n, err = s.wallet.NtfnServer.ResumeTransactionNotifications(
+			wallet.TransactionNotificationsConfig{},
+			&waddrmgr.BlockStamp{
+				Hash:   *resumeHash,
+				Height: req.ResumeBlockHeight,
+			},
+		)
+		if errors.Is(err, wallet.ErrUnknownResumeBlock) {
+			return status.Errorf(codes.NotFound, "%s", err.Error())
+		}
+		if err != nil {
+			return translateError(err)
+		}
+	} else {
+		n = s.wallet.NtfnServer.TransactionNotifications()
+	}
 	defer n.Done()
 
 	ctxDone := svr.Context().Done()
@@ -1112,6 +1112,8 @@ func (*PublishTransactionResponse) ProtoMessage()               {}
 func (*PublishTransactionResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
 
 type TransactionNotificationsRequest struct {
+	ResumeBlockHash   []byte `protobuf:"bytes,1,opt,name=resume_block_hash,json=resumeBlockHash,proto3" json:"resume_block_hash,omitempty"`
+	ResumeBlockHeight int32  `protobuf:"varint,2,opt,name=resume_block_height,json=resumeBlockHeight" json:"resume_block_height,omitempty"`
 }
 
 func (m *TransactionNotificationsRequest) Reset()         { *m = TransactionNotificationsRequest{} }
@@ -1121,6 +1123,20 @@ func (*TransactionNotificationsRequest) Descriptor() ([]byte, []int) {
 	return fileDescriptor0, []int{33}
 }
 
+func (m *TransactionNotificationsRequest) GetResumeBlockHash() []byte {
+	if m != nil {
+		return m.ResumeBlockHash
+	}
+	return nil
+}
+
+func (m *TransactionNotificationsRequest) GetResumeBlockHeight() int32 {
+	if m != nil {
+		return m.ResumeBlockHeight
+	}
+	return 0
+}
+
 type TransactionNotificationsResponse struct {
 	// Sorted by increasing height.  This is a repeated field so many new blocks
 	// in a new best chain can be notified at once during a reorganize.
@@ -2487,155 +2503,157 @@ var _WalletLoaderService_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("api.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 2391 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xac, 0x59, 0x5b, 0x73, 0xdc, 0x48,
-	0x15, 0x66, 0x2c, 0xdf, 0x72, 0xe6, 0xde, 0xbe, 0x4d, 0x94, 0x38, 0xc9, 0x2a, 0xbb, 0xd9, 0xec,
-	0x2e, 0x98, 0x60, 0x02, 0x2c, 0xc5, 0x56, 0xd8, 0xc4, 0x64, 0xd9, 0x21, 0xc1, 0x99, 0x92, 0x93,
-	0x4d, 0xaa, 0xa0, 0x98, 0x92, 0x35, 0xed, 0x58, 0x78, 0x46, 0x9a, 0x48, 0x9a, 0x38, 0xe1, 0x89,
-	0xa2, 0x8a, 0x47, 0x5e, 0x80, 0x07, 0x0a, 0x6a, 0x5f, 0xf8, 0x05, 0x54, 0xf1, 0xc2, 0x23, 0xfc,
-	0x06, 0x1e, 0xf9, 0x17, 0xfc, 0x02, 0xfa, 0x72, 0x7a, 0xd4, 0x2d, 0x69, 0xc6, 0xf6, 0x16, 0x6f,
-	0xa3, 0xaf, 0xcf, 0x39, 0x7d, 0xfa, 0xf4, 0xb9, 0xf6, 0xc0, 0x25, 0x6f, 0x1c, 0xec, 0x8c, 0xe3,
-	0x28, 0x8d, 0xc8, 0xa5, 0x53, 0x6f, 0x38, 0xa4, 0x69, 0x3c, 0xf6, 0x9d, 0x16, 0x34, 0xbe, 0xa0,
-	0x71, 0x12, 0x44, 0xa1, 0x4b, 0x5f, 0x4d, 0x68, 0x92, 0x3a, 0xff, 0xaa, 0x40, 0x73, 0x0a, 0x25,
-	0xe3, 0x28, 0x4c, 0x28, 0x79, 0x0f, 0x1a, 0xaf, 0x25, 0xd4, 0x4f, 0xd2, 0x38, 0x08, 0x5f, 0x76,
-	0x2a, 0x37, 0x2a, 0xb7, 0x2f, 0xb9, 0x75, 0x44, 0x0f, 0x04, 0x48, 0xd6, 0x61, 0x69, 0xe4, 0xfd,
-	0x32, 0x8a, 0x3b, 0x0b, 0x6c, 0xb5, 0xee, 0xca, 0x0f, 0x81, 0x06, 0x21, 0x43, 0x2d, 0x44, 0xf9,
-	0x07, 0x47, 0xc7, 0x5e, 0xea, 0x1f, 0x77, 0x16, 0x25, 0x2a, 0x3e, 0xc8, 0x35, 0x80, 0x71, 0x4c,
-	0x63, 0x3a, 0xa4, 0x5e, 0x42, 0x3b, 0x4b, 0x62, 0x13, 0x0d, 0xe1, 0x8a, 0x1c, 0x4e, 0x82, 0xe1,
-	0xa0, 0x3f, 0xa2, 0xa9, 0x37, 0xf0, 0x52, 0xaf, 0xb3, 0x2c, 0x15, 0x11, 0xe8, 0x4f, 0x11, 0x74,
-	0xfe, 0x69, 0x01, 0x79, 0x1a, 0x7b, 0x61, 0xe2, 0xf9, 0x29, 0x53, 0xef, 0x47, 0x0c, 0x0f, 0x86,
-	0x09, 0x21, 0xb0, 0x78, 0xec, 0x25, 0xc7, 0x42, 0xf9, 0x9a, 0x2b, 0x7e, 0x93, 0x1b, 0x50, 0x4d,
-	0x33, 0x4a, 0xa1, 0x79, 0xcd, 0xd5, 0x21, 0xf2, 0x03, 0x58, 0x1e, 0xd0, 0xc3, 0x20, 0x4d, 0xd8,
-	0x01, 0xac, 0xdb, 0xd5, 0xdd, 0x9b, 0x3b, 0x53, 0xf3, 0xed, 0x14, 0x37, 0xd9, 0xe9, 0x86, 0xe3,
-	0x49, 0xea, 0x22, 0x0b, 0xb9, 0x07, 0x2b, 0x7e, 0x4c, 0x07, 0x9c, 0x7b, 0x51, 0x70, 0xbf, 0x3b,
-	0x9f, 0xfb, 0xc9, 0x24, 0xe5, 0xec, 0x8a, 0x89, 0xb4, 0xc0, 0x3a, 0xa2, 0xd2, 0x12, 0x96, 0xcb,
-	0x7f, 0x92, 0xab, 0x70, 0x29, 0x0d, 0x46, 0xec, 0xa6, 0xbc, 0xd1, 0x58, 0x9c, 0xde, 0x72, 0x33,
-	0xc0, 0x7e, 0x05, 0x4b, 0x42, 0x01, 0x6e, 0xdf, 0x20, 0x1c, 0xd0, 0x37, 0xe2, 0xb0, 0xcc, 0xbe,
-	0xe2, 0x83, 0x7c, 0x00, 0x2d, 0x66, 0xcd, 0xd7, 0x41, 0x34, 0x49, 0xfa, 0x9e, 0xef, 0x47, 0x93,
-	0x30, 0xc5, 0xcb, 0x6a, 0x2a, 0xfc, 0xbe, 0x84, 0xc9, 0xfb, 0xd0, 0xcc, 0x48, 0x47, 0x82, 0xd2,
-	0x12, 0xbb, 0x35, 0xa6, 0x94, 0x02, 0xb5, 0x9f, 0xc2, 0xb2, 0xd4, 0x7a, 0xc6, 0x9e, 0x1d, 0x58,
-	0x31, 0xb7, 0x52, 0x9f, 0xc4, 0x86, 0xd5, 0x20, 0x4c, 0x69, 0x1c, 0x7a, 0x43, 0x21, 0x7b, 0xd5,
-	0x9d, 0x7e, 0x3b, 0x7f, 0xa9, 0x40, 0xed, 0xc1, 0x30, 0xf2, 0x4f, 0xe6, 0x5d, 0xde, 0x26, 0x2c,
-	0x1f, 0xd3, 0xe0, 0xe5, 0xb1, 0x94, 0xbc, 0xe4, 0xe2, 0x97, 0x69, 0x23, 0x2b, 0x67, 0x23, 0x72,
-	0x1f, 0x6a, 0xda, 0xfd, 0xaa, 0x8b, 0xd9, 0x9e, 0x7b, 0x31, 0xae, 0xc1, 0xe2, 0x3c, 0x81, 0x06,
-	0xda, 0xe9, 0x81, 0x37, 0xf4, 0x42, 0x9f, 0xea, 0xa7, 0xac, 0x98, 0xa7, 0xbc, 0x09, 0xf5, 0x34,
-	0x4a, 0xbd, 0x61, 0xff, 0x50, 0x92, 0x0a, 0x5d, 0x2d, 0x26, 0x90, 0x83, 0xc8, 0xee, 0xd4, 0xa1,
-	0xda, 0x63, 0x21, 0xa4, 0x82, 0xb0, 0x01, 0x35, 0xf9, 0x29, 0x03, 0x90, 0x87, 0xe9, 0x3e, 0x4d,
-	0x4f, 0xa3, 0xf8, 0x44, 0x51, 0x7c, 0x0c, 0xcd, 0x29, 0x92, 0x45, 0x29, 0xd7, 0xef, 0x35, 0xed,
-	0x87, 0x72, 0x05, 0x35, 0xa9, 0x4b, 0x14, 0xc9, 0x9d, 0xef, 0xc3, 0x3a, 0xea, 0xbe, 0x3f, 0x19,
-	0x1d, 0xd2, 0x18, 0x25, 0x92, 0x77, 0xa0, 0x86, 0x2a, 0xf7, 0x43, 0x6f, 0x44, 0x31, 0xc4, 0xab,
-	0x88, 0xed, 0x33, 0xc8, 0xb9, 0x07, 0x1b, 0x39, 0x56, 0x7d, 0x6b, 0xe4, 0x15, 0x2b, 0xd9, 0xd6,
-	0x1a, 0xb9, 0xd3, 0x86, 0x26, 0xf2, 0x27, 0xea, 0x1c, 0xff, 0xb0, 0xa0, 0x95, 0x61, 0x28, 0xee,
-	0x87, 0xb0, 0x8a, 0x8c, 0x09, 0x13, 0x94, 0x0f, 0xba, 0x3c, 0xb9, 0x02, 0xdc, 0x29, 0x13, 0xf9,
-	0x3a, 0x10, 0x7f, 0x12, 0xc7, 0x94, 0xe9, 0x73, 0xc8, 0x9d, 0xa8, 0x2f, 0x5c, 0x47, 0x06, 0x77,
-	0x0b, 0x57, 0x84, 0x77, 0x7d, 0xce, 0xdd, 0xe8, 0x0e, 0xac, 0xe7, 0xa8, 0xa5, 0x53, 0x59, 0xc2,
-	0xa9, 0x88, 0x41, 0x2f, 0x56, 0xec, 0xdf, 0x2c, 0xc0, 0x8a, 0x0a, 0x94, 0xf3, 0x9d, 0xbd, 0x60,
-	0xde, 0x85, 0x82, 0x79, 0x8b, 0x9e, 0x62, 0x15, 0x3d, 0x85, 0x1f, 0x8d, 0xbe, 0x91, 0x41, 0xd2,
-	0x3f, 0xa1, 0x6f, 0xfb, 0xd2, 0xe7, 0x64, 0x16, 0x6d, 0xa9, 0x95, 0x47, 0xf4, 0xed, 0x9e, 0x50,
-	0x8e, 0x51, 0xab, 0x90, 0xd2, 0xa8, 0x97, 0x24, 0xb5, 0x5a, 0x31, 0xa8, 0x47, 0xe3, 0x28, 0x4e,
-	0xe9, 0x40, 0xa3, 0x5e, 0x46, 0x6a, 0x5c, 0x51, 0xd4, 0xce, 0x0b, 0x58, 0x77, 0x29, 0x3f, 0x8b,
-	0xb2, 0x3f, 0x3a, 0xd2, 0x39, 0x0d, 0x72, 0x19, 0x56, 0x43, 0x7a, 0xaa, 0x1b, 0x63, 0x85, 0x7d,
-	0x0b, 0x3f, 0xdb, 0x82, 0x8d, 0x9c, 0x64, 0x8c, 0x83, 0xe7, 0x40, 0xf6, 0xd9, 0x19, 0x73, 0x1b,
-	0xf2, 0xaa, 0xe1, 0x25, 0xc9, 0xf8, 0x38, 0xe6, 0x55, 0x43, 0x26, 0x08, 0x0d, 0x39, 0x87, 0xe9,
-	0x9d, 0x4f, 0x60, 0xcd, 0x10, 0x7c, 0x31, 0xbf, 0xfe, 0x73, 0x05, 0xf5, 0x1a, 0x0c, 0x62, 0x9a,
-	0x28, 0xdf, 0x9e, 0x93, 0x13, 0xbe, 0x0b, 0x8b, 0x27, 0x2c, 0x3b, 0x0a, 0x4d, 0x1a, 0xbb, 0x8e,
-	0xe6, 0xdc, 0x45, 0x31, 0x3b, 0x8f, 0x18, 0xa5, 0x2b, 0xe8, 0x9d, 0x5d, 0x58, 0xe4, 0x5f, 0x2c,
-	0xd3, 0xb6, 0x1e, 0x74, 0x7b, 0x77, 0xee, 0xdc, 0xbd, 0xdb, 0x7f, 0xf8, 0xe2, 0xe9, 0x43, 0x77,
-	0xff, 0xfe, 0xe3, 0xd6, 0xd7, 0x74, 0xb4, 0xbb, 0x8f, 0x68, 0xc5, 0xf9, 0x26, 0x1e, 0x4d, 0x09,
-	0xc5, 0xa3, 0x71, 0xe5, 0x24, 0x84, 0x91, 0xae, 0x3e, 0x9d, 0x3f, 0x54, 0x60, 0xab, 0x2b, 0x2e,
-	0xbb, 0x17, 0x07, 0xaf, 0xbd, 0x94, 0xb2, 0x1b, 0x3f, 0xaf, 0xa9, 0x67, 0x27, 0xfb, 0x5b, 0xbc,
-	0x9e, 0x08, 0x71, 0xc2, 0xb5, 0x4e, 0x83, 0x23, 0xe1, 0xde, 0xac, 0x76, 0x8f, 0xa7, 0xbb, 0x3c,
-	0x0f, 0x8e, 0x78, 0x4e, 0x67, 0x5a, 0xf8, 0x5e, 0x28, 0x7c, 0x7a, 0xd5, 0xc5, 0x2f, 0xc7, 0x86,
-	0x4e, 0x51, 0x29, 0x74, 0x8b, 0x10, 0x1a, 0x18, 0x1e, 0x17, 0xf4, 0xc1, 0xef, 0xc0, 0x66, 0xcc,
-	0x38, 0x02, 0x56, 0x6d, 0x99, 0xb3, 0x87, 0x47, 0x41, 0x3c, 0xf2, 0x64, 0x51, 0x90, 0x05, 0x65,
-	0x43, 0xad, 0xee, 0xe9, 0x8b, 0x6c, 0xbf, 0xe6, 0x74, 0x3f, 0x34, 0x27, 0xab, 0x7d, 0x22, 0x4c,
-	0xc5, 0x3e, 0x96, 0x2b, 0x3f, 0x78, 0x21, 0x4a, 0xc6, 0x34, 0x1c, 0x78, 0x87, 0x43, 0x95, 0xf7,
-	0x33, 0x80, 0x97, 0xd8, 0x60, 0xc4, 0x64, 0x4e, 0x62, 0xda, 0x8f, 0xe9, 0xa9, 0x17, 0x0f, 0x54,
-	0x89, 0x55, 0xb0, 0x2b, 0x50, 0xe7, 0x4f, 0x0b, 0xb0, 0xf9, 0x63, 0x9a, 0x6a, 0x65, 0x69, 0xea,
-	0x63, 0x3b, 0xb0, 0xc6, 0xaa, 0x5a, 0x9c, 0xb2, 0x6a, 0xa1, 0xa7, 0x3a, 0x79, 0x33, 0x6d, 0xb5,
-	0x94, 0xe5, 0xba, 0x5d, 0xd8, 0xc8, 0xd3, 0x67, 0x15, 0xb4, 0xed, 0xae, 0x99, 0x1c, 0xb2, 0x9c,
-	0x7e, 0x08, 0x6d, 0xa6, 0x72, 0x6e, 0x07, 0x4b, 0xec, 0xd0, 0x94, 0x0b, 0x99, 0x7c, 0xa6, 0x8f,
-	0x49, 0x2b, 0xa5, 0x2f, 0x0a, 0x73, 0xb6, 0x75, 0x6a, 0x29, 0xfb, 0x1e, 0x5c, 0x61, 0x0d, 0x61,
-	0x30, 0x9a, 0x8c, 0x98, 0x09, 0x7c, 0x9e, 0x82, 0x8d, 0xda, 0xbc, 0x24, 0xf8, 0x2e, 0x23, 0x89,
-	0x2b, 0x28, 0x74, 0x33, 0x38, 0x7f, 0x67, 0xce, 0x5a, 0x30, 0x0d, 0xde, 0xc9, 0x67, 0x40, 0x18,
-	0x23, 0xbb, 0x5a, 0x43, 0xa4, 0x2c, 0x28, 0x5b, 0x5a, 0xcc, 0xe9, 0x7d, 0x86, 0xdb, 0x16, 0x2c,
-	0xba, 0x3c, 0xd2, 0x83, 0xf5, 0x49, 0x58, 0x22, 0x69, 0xe1, 0x3c, 0x8d, 0xc3, 0x1a, 0xb2, 0x1a,
-	0x5a, 0xb3, 0x26, 0x7b, 0x6b, 0xef, 0xd8, 0x0b, 0x5f, 0xd2, 0xde, 0x34, 0x76, 0xd4, 0x8d, 0x7e,
-	0x0c, 0x16, 0x0b, 0x10, 0x71, 0x83, 0x8d, 0xdd, 0x5b, 0x9a, 0xf0, 0x19, 0x0c, 0x3b, 0x3c, 0x12,
-	0x38, 0x0b, 0x77, 0xfa, 0x88, 0xf5, 0xc6, 0x5a, 0x80, 0xca, 0x8a, 0x57, 0x67, 0x68, 0xc6, 0xc6,
-	0xc9, 0x78, 0xe2, 0xd5, 0xc8, 0xe4, 0x5d, 0xd6, 0x19, 0x9a, 0x91, 0x39, 0xd7, 0xc0, 0x62, 0x92,
-	0x49, 0x15, 0x56, 0x7a, 0x6e, 0xf7, 0x8b, 0xfb, 0x4f, 0x1f, 0xb2, 0x0c, 0x03, 0xb0, 0xdc, 0x7b,
-	0xf6, 0xe0, 0x71, 0x77, 0x8f, 0xe5, 0x15, 0x16, 0x90, 0x45, 0x8d, 0x30, 0x20, 0x7f, 0xcd, 0x1c,
-	0xf6, 0xb3, 0x49, 0xa8, 0x1f, 0xfa, 0xec, 0xa4, 0xc8, 0xcb, 0x9f, 0x17, 0xbf, 0xa4, 0xa9, 0xea,
-	0x37, 0x55, 0xa3, 0x24, 0x40, 0xd9, 0x6d, 0xce, 0x89, 0x58, 0x6b, 0x4e, 0xc4, 0x92, 0x4f, 0xc0,
-	0x0e, 0x42, 0x7f, 0x38, 0x19, 0xd0, 0xfe, 0x34, 0xe4, 0xfc, 0x28, 0x08, 0x0f, 0x99, 0xd6, 0x09,
-	0x66, 0x9a, 0x0e, 0x52, 0x74, 0x91, 0x60, 0x4f, 0xad, 0xf3, 0xa0, 0x51, 0xdc, 0xbe, 0x38, 0x72,
-	0x3f, 0xf1, 0xe3, 0x60, 0x2c, 0x0b, 0xe9, 0xaa, 0xbb, 0x86, 0x8b, 0xd2, 0x1c, 0x07, 0x62, 0xc9,
-	0xf9, 0xab, 0x05, 0x5b, 0x05, 0x13, 0xa0, 0x63, 0xfe, 0x1c, 0x5a, 0x09, 0x9b, 0x68, 0x7c, 0x5e,
-	0x67, 0x23, 0xd1, 0x3b, 0x2b, 0xb7, 0xfc, 0x96, 0x76, 0xdf, 0x33, 0xb8, 0x77, 0x7a, 0xd8, 0x7f,
-	0xe3, 0xac, 0xd0, 0x54, 0xa2, 0xe4, 0x77, 0xc2, 0xcb, 0x9d, 0x6c, 0x23, 0x0c, 0x33, 0x56, 0x05,
-	0x86, 0x56, 0xbc, 0x0d, 0x2d, 0x3c, 0xc8, 0xf8, 0x44, 0x9d, 0x45, 0x3a, 0x41, 0x43, 0xe2, 0xbd,
-	0x13, 0x79, 0x0c, 0xfb, 0x3f, 0x15, 0x68, 0x98, 0x1b, 0xf2, 0x21, 0x42, 0x0b, 0x03, 0x3d, 0xdf,
-	0x34, 0x35, 0x5c, 0x64, 0x03, 0xa6, 0x8a, 0x3c, 0x5f, 0x5f, 0x0e, 0x06, 0xb2, 0x26, 0x54, 0x25,
-	0xd6, 0x15, 0xe3, 0x01, 0xcb, 0xf7, 0xc6, 0x78, 0x81, 0x5f, 0xe4, 0x0a, 0x5c, 0xca, 0x74, 0x5b,
-	0x14, 0xe2, 0x57, 0xc7, 0xa8, 0x15, 0x97, 0xcb, 0xb3, 0x05, 0xef, 0x75, 0x79, 0x5f, 0x8f, 0xf3,
-	0x51, 0x15, 0xb1, 0xa7, 0x81, 0x6c, 0xa6, 0x8e, 0xe2, 0x68, 0x34, 0xbd, 0x65, 0xd1, 0xc6, 0xac,
-	0xba, 0x35, 0x0e, 0xaa, 0x9b, 0x75, 0xfe, 0x58, 0x81, 0xcd, 0x83, 0xe0, 0x65, 0x58, 0xe2, 0xa7,
-	0x67, 0x55, 0x3a, 0xe6, 0x88, 0x09, 0x8d, 0x03, 0x6f, 0x18, 0xfc, 0xca, 0xcc, 0x0b, 0x18, 0x74,
-	0x1b, 0xd9, 0xaa, 0x26, 0x9d, 0xab, 0x15, 0x84, 0x53, 0x83, 0x50, 0x39, 0x54, 0xd6, 0xdd, 0x9a,
-	0x00, 0xbb, 0x12, 0x73, 0x5e, 0xc1, 0x56, 0x41, 0x2b, 0x74, 0x9d, 0xdc, 0xbc, 0x5a, 0x29, 0xce,
-	0xab, 0x77, 0x61, 0x73, 0x12, 0x26, 0x8c, 0x9d, 0xa9, 0x65, 0x6e, 0xb5, 0x20, 0xb6, 0x5a, 0x57,
-	0xab, 0x5d, 0x7d, 0xcb, 0x9f, 0xc0, 0xe5, 0xde, 0xe4, 0x70, 0x18, 0x24, 0xc7, 0x25, 0xb6, 0xf8,
-	0x06, 0x10, 0x14, 0x58, 0xdc, 0xbb, 0x2d, 0x57, 0x34, 0x2e, 0xe7, 0x2a, 0xd8, 0x65, 0xb2, 0x30,
-	0x37, 0xbc, 0x03, 0xd7, 0x35, 0x78, 0x3f, 0x4a, 0x83, 0xa3, 0xc0, 0xf7, 0xf4, 0xa2, 0xe6, 0x7c,
-	0xb9, 0x00, 0x37, 0x66, 0xd3, 0xa0, 0x25, 0x3e, 0x85, 0xa6, 0x97, 0xa6, 0x9e, 0x7f, 0xcc, 0xd4,
-	0x12, 0xb5, 0xe6, 0xcc, 0xd4, 0xde, 0x50, 0xf4, 0x02, 0x4d, 0x78, 0xfd, 0x1d, 0x50, 0x53, 0x02,
-	0x37, 0x11, 0x0b, 0x02, 0x05, 0x23, 0xe1, 0xac, 0x02, 0x60, 0x7d, 0xd5, 0x02, 0xc0, 0xf3, 0x51,
-	0x89, 0x44, 0x11, 0x4b, 0x54, 0x4e, 0xa4, 0x35, 0xb7, 0x53, 0x64, 0xfc, 0x5c, 0xac, 0x3b, 0xbf,
-	0xab, 0xc0, 0xf6, 0x01, 0x6b, 0x23, 0xd2, 0x90, 0xf5, 0x6b, 0x65, 0x16, 0x9c, 0x93, 0x65, 0x59,
-	0x31, 0x0f, 0xa3, 0x7e, 0xc8, 0x99, 0xde, 0xf6, 0x99, 0x2b, 0x70, 0x31, 0xc2, 0x65, 0x57, 0xdd,
-	0x66, 0x18, 0x09, 0x61, 0x6f, 0x9f, 0x49, 0x98, 0xf7, 0x6c, 0x19, 0xad, 0xa4, 0x94, 0x73, 0x7a,
-	0x5d, 0x51, 0x0a, 0x2d, 0x9c, 0xdf, 0x2f, 0xc0, 0xb5, 0x59, 0xfa, 0xe0, 0x6d, 0xfd, 0x7f, 0x93,
-	0xc6, 0x23, 0x58, 0x11, 0x6d, 0x14, 0x95, 0xaf, 0x4a, 0x66, 0xde, 0x9c, 0xaf, 0x89, 0x58, 0x66,
-	0x8c, 0xae, 0x92, 0x60, 0x3f, 0x83, 0x15, 0xc4, 0x2e, 0xa2, 0xe5, 0x75, 0xa8, 0x6a, 0xd1, 0x85,
-	0x4a, 0x42, 0x16, 0xc6, 0xce, 0x36, 0x5c, 0x51, 0xc3, 0x72, 0x99, 0x8f, 0xff, 0xb7, 0x02, 0x57,
-	0xcb, 0xd7, 0x2f, 0x34, 0x7b, 0x9c, 0x67, 0xae, 0x2c, 0x1f, 0x19, 0xad, 0x0b, 0x8d, 0x8c, 0x8b,
-	0x17, 0x1a, 0x19, 0x97, 0x66, 0x8c, 0x8c, 0xbf, 0xad, 0xc0, 0xda, 0x5e, 0x4c, 0x59, 0xfb, 0xfe,
-	0x5c, 0x5c, 0x97, 0x72, 0xd7, 0x8f, 0xa0, 0x3d, 0xe6, 0x19, 0xc3, 0xef, 0x17, 0x72, 0x6e, 0x4b,
-	0x2e, 0x68, 0xfd, 0x0b, 0xcb, 0x46, 0x6a, 0x92, 0x28, 0xb4, 0x3a, 0x6d, 0x5c, 0xd1, 0xc8, 0x09,
-	0x2c, 0x26, 0x94, 0x0e, 0xb0, 0xbe, 0x89, 0xdf, 0xce, 0x26, 0xac, 0x9b, 0x6a, 0x60, 0x6e, 0xfa,
-	0x14, 0xda, 0x4f, 0x98, 0x2b, 0x7c, 0x75, 0xe5, 0x9c, 0x75, 0x20, 0xba, 0x04, 0x94, 0xcb, 0xd0,
-	0xbd, 0x61, 0x94, 0x98, 0xa7, 0x76, 0x36, 0x98, 0x31, 0x74, 0x14, 0x89, 0x19, 0x2c, 0x91, 0x87,
-	0x6f, 0x82, 0x24, 0x7b, 0x29, 0xd9, 0x81, 0x75, 0x13, 0x46, 0x3f, 0x61, 0x05, 0x94, 0x0a, 0x44,
-	0xe8, 0xc4, 0x06, 0x26, 0xf9, 0xe5, 0x7c, 0x59, 0x81, 0xce, 0x01, 0xef, 0xe6, 0xf7, 0x38, 0x59,
-	0x98, 0x4c, 0x12, 0x77, 0xec, 0xab, 0x33, 0xb1, 0xd4, 0x87, 0x8f, 0x44, 0x7d, 0x73, 0x0a, 0x6c,
-	0x20, 0x8c, 0xe3, 0x22, 0x7f, 0xa3, 0x9b, 0x24, 0xfc, 0xca, 0xa7, 0xae, 0x35, 0xfd, 0xe6, 0x6b,
-	0xdc, 0x22, 0x8c, 0x5c, 0x59, 0x77, 0xfa, 0xcd, 0xeb, 0x94, 0x4f, 0x63, 0xf4, 0x6b, 0x8a, 0x05,
-	0x5c, 0x87, 0x9c, 0x2b, 0x70, 0xb9, 0x44, 0x3d, 0x79, 0xa8, 0x5d, 0x77, 0xfa, 0x2e, 0x7d, 0x40,
-	0xe3, 0xd7, 0x81, 0xcf, 0xd3, 0xfd, 0x0a, 0x22, 0xe4, 0xb2, 0x16, 0xec, 0xe6, 0xeb, 0xb5, 0x6d,
-	0x97, 0x2d, 0xa1, 0xcc, 0x7f, 0x57, 0xa1, 0x2e, 0x2d, 0xa8, 0x64, 0x7e, 0x0f, 0x16, 0xf9, 0x33,
-	0x1b, 0xd9, 0xd4, 0xb8, 0xb4, 0x67, 0x38, 0x7b, 0xab, 0x80, 0x4f, 0x6b, 0xcf, 0x0a, 0x3e, 0xa7,
-	0x19, 0xca, 0x98, 0x6f, 0x74, 0x86, 0x32, 0xf9, 0xc7, 0x3a, 0x17, 0xea, 0xc6, 0x53, 0x1a, 0xb9,
-	0x5e, 0x7c, 0xe1, 0x32, 0xde, 0xe7, 0xec, 0x1b, 0xb3, 0x09, 0x50, 0xe6, 0x1e, 0xac, 0xaa, 0xb7,
-	0x31, 0x62, 0x97, 0x3e, 0x98, 0x49, 0x49, 0x57, 0xe6, 0x3c, 0xa6, 0xf1, 0xa3, 0xa9, 0xa7, 0x26,
-	0xfd, 0x68, 0xe6, 0x7c, 0x6d, 0x1c, 0x2d, 0x3f, 0x0a, 0xbf, 0x80, 0x66, 0x6e, 0x22, 0x23, 0xef,
-	0x68, 0xe4, 0xe5, 0x83, 0xac, 0xed, 0xcc, 0x23, 0x41, 0xc9, 0x13, 0xe8, 0xcc, 0x6a, 0x0b, 0xc8,
-	0x87, 0xe5, 0x55, 0xb8, 0x2c, 0xf7, 0xda, 0x1f, 0x9d, 0x8b, 0x56, 0x6e, 0x7a, 0xa7, 0x42, 0x22,
-	0xd6, 0x24, 0x96, 0xd6, 0x14, 0x72, 0xfb, 0x1c, 0x65, 0x47, 0x6e, 0xf9, 0xc1, 0xb9, 0x0b, 0x14,
-	0xdb, 0x30, 0xc8, 0x9e, 0x68, 0x8d, 0xed, 0x6e, 0x95, 0xb8, 0x40, 0xd9, 0x66, 0xef, 0x9f, 0x49,
-	0x37, 0xdd, 0xea, 0x67, 0xd0, 0xca, 0x4f, 0x71, 0xc4, 0x39, 0x7b, 0xe8, 0xb4, 0x6f, 0xce, 0xa5,
-	0xc9, 0x9c, 0xdc, 0x78, 0xc7, 0x33, 0x9c, 0xbc, 0xec, 0xed, 0xd0, 0x70, 0xf2, 0xd2, 0x27, 0x40,
-	0xf2, 0x18, 0xaa, 0xda, 0x4b, 0x1d, 0xd9, 0xce, 0xbf, 0x9d, 0x99, 0xf2, 0xae, 0xcd, 0x5a, 0xce,
-	0x49, 0xc3, 0x6c, 0xb7, 0x3d, 0xf7, 0x25, 0xae, 0x28, 0x2d, 0xf7, 0xa6, 0xc6, 0x8c, 0x99, 0x7f,
-	0xa3, 0x32, 0x8c, 0x39, 0xe3, 0x55, 0xcd, 0x30, 0xe6, 0xac, 0x47, 0x2e, 0x1e, 0x56, 0xb9, 0x89,
-	0xd0, 0x08, 0xab, 0xf2, 0x71, 0xdb, 0x08, 0xab, 0x59, 0xe3, 0x28, 0x93, 0x9c, 0x1b, 0x37, 0x0c,
-	0xc9, 0xe5, 0x03, 0x92, 0x21, 0x79, 0xd6, 0xb4, 0xe2, 0x01, 0x29, 0x4e, 0x02, 0x44, 0xff, 0x0f,
-	0x6c, 0xe6, 0xd0, 0x61, 0xbf, 0x77, 0x06, 0x15, 0x66, 0xf5, 0xbf, 0x59, 0xaa, 0x5c, 0x3e, 0x8e,
-	0x3c, 0xd6, 0xc3, 0xa9, 0xdc, 0xfe, 0x04, 0x6a, 0x7a, 0xb9, 0x24, 0xfa, 0xdd, 0x95, 0x94, 0x57,
-	0xfb, 0xfa, 0xcc, 0x75, 0x3c, 0x0b, 0x13, 0xa8, 0xf7, 0x0c, 0x86, 0xc0, 0x92, 0x9e, 0xc6, 0x10,
-	0x58, 0xd6, 0x6c, 0x90, 0x2e, 0x40, 0xd6, 0x2a, 0x90, 0xab, 0x1a, 0x79, 0xa1, 0x07, 0xb1, 0xb7,
-	0x67, 0xac, 0x66, 0x6e, 0xac, 0x75, 0x12, 0x86, 0x1b, 0x17, 0xfb, 0x0e, 0xc3, 0x8d, 0x4b, 0x1a,
-	0x10, 0xf2, 0x0b, 0x68, 0x17, 0x2a, 0x33, 0xd1, 0x7d, 0x74, 0x56, 0x5b, 0x61, 0xbf, 0x3b, 0x9f,
-	0x48, 0xca, 0x3f, 0x5c, 0x16, 0x7f, 0x43, 0x7f, 0xfb, 0x7f, 0x01, 0x00, 0x00, 0xff, 0xff, 0x22,
-	0xfc, 0x70, 0xb3, 0x93, 0x1e, 0x00, 0x00,
+	// 2425 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x59, 0xdd, 0x6e, 0x1c, 0x49,
+	0xf5, 0xdf, 0x76, 0xfb, 0xf3, 0xcc, 0x77, 0x79, 0x3c, 0x9e, 0x74, 0x62, 0xc7, 0xe9, 0xec, 0x26,
+	0xd9, 0xec, 0xae, 0xff, 0xf9, 0x9b, 0x2c, 0x2c, 0x62, 0x15, 0x36, 0x31, 0x59, 0xd6, 0x24, 0x38,
+	0x56, 0x3b, 0xd9, 0x44, 0x5a, 0x44, 0xab, 0xdd, 0x5d, 0xb6, 0x0b, 0xcf, 0x54, 0x4f, 0xba, 0x7b,
+	0xe2, 0x18, 0x09, 0x09, 0x21, 0x71, 0xc9, 0x0d, 0x70, 0x81, 0x40, 0x7b, 0xc3, 0x13, 0x20, 0x71,
+	0xc3, 0x25, 0xfb, 0x0c, 0x5c, 0xf2, 0x16, 0x3c, 0x01, 0xaa, 0xaf, 0xe9, 0xaa, 0xe9, 0x9e, 0xb1,
+	0xbd, 0xe2, 0x6e, 0xfa, 0x9c, 0x5f, 0x9d, 0x3a, 0x75, 0xea, 0x7c, 0xd4, 0x39, 0x03, 0x4b, 0xc1,
+	0x80, 0x6c, 0x0e, 0x92, 0x38, 0x8b, 0xd1, 0xd2, 0x69, 0xd0, 0xeb, 0xe1, 0x2c, 0x19, 0x84, 0x6e,
+	0x13, 0xea, 0x5f, 0xe2, 0x24, 0x25, 0x31, 0xf5, 0xf0, 0xeb, 0x21, 0x4e, 0x33, 0xf7, 0x1b, 0x0b,
+	0x1a, 0x23, 0x52, 0x3a, 0x88, 0x69, 0x8a, 0xd1, 0x7b, 0x50, 0x7f, 0x23, 0x48, 0x7e, 0x9a, 0x25,
+	0x84, 0x1e, 0x75, 0xad, 0x0d, 0xeb, 0xce, 0x92, 0x57, 0x93, 0xd4, 0x7d, 0x4e, 0x44, 0x6d, 0x98,
+	0xeb, 0x07, 0xbf, 0x88, 0x93, 0xee, 0xcc, 0x86, 0x75, 0xa7, 0xe6, 0x89, 0x0f, 0x4e, 0x25, 0x34,
+	0x4e, 0xba, 0xb6, 0xa4, 0x12, 0x2a, 0xa8, 0x83, 0x20, 0x0b, 0x8f, 0xbb, 0xb3, 0x82, 0xca, 0x3f,
+	0xd0, 0x3a, 0xc0, 0x20, 0xc1, 0x09, 0xee, 0xe1, 0x20, 0xc5, 0xdd, 0x39, 0xbe, 0x89, 0x46, 0x61,
+	0x8a, 0x1c, 0x0c, 0x49, 0x2f, 0xf2, 0xfb, 0x38, 0x0b, 0xa2, 0x20, 0x0b, 0xba, 0xf3, 0x42, 0x11,
+	0x4e, 0xfd, 0xa9, 0x24, 0xba, 0xff, 0xb4, 0x01, 0x3d, 0x4f, 0x02, 0x9a, 0x06, 0x61, 0x46, 0x62,
+	0xfa, 0x23, 0x9c, 0x05, 0xa4, 0x97, 0x22, 0x04, 0xb3, 0xc7, 0x41, 0x7a, 0xcc, 0x95, 0xaf, 0x7a,
+	0xfc, 0x37, 0xda, 0x80, 0x4a, 0x96, 0x23, 0xb9, 0xe6, 0x55, 0x4f, 0x27, 0xa1, 0x1f, 0xc0, 0x7c,
+	0x84, 0x0f, 0x48, 0x96, 0x76, 0xed, 0x0d, 0xfb, 0x4e, 0x65, 0xeb, 0xe6, 0xe6, 0xc8, 0x7c, 0x9b,
+	0xc5, 0x4d, 0x36, 0x77, 0xe8, 0x60, 0x98, 0x79, 0x72, 0x09, 0x7a, 0x00, 0x0b, 0x61, 0x82, 0x23,
+	0xb6, 0x7a, 0x96, 0xaf, 0x7e, 0x77, 0xfa, 0xea, 0x67, 0xc3, 0x8c, 0x2d, 0x57, 0x8b, 0x50, 0x13,
+	0xec, 0x43, 0x2c, 0x2c, 0x61, 0x7b, 0xec, 0x27, 0xba, 0x06, 0x4b, 0x19, 0xe9, 0xe3, 0x34, 0x0b,
+	0xfa, 0x03, 0x7e, 0x7a, 0xdb, 0xcb, 0x09, 0xce, 0x6b, 0x98, 0xe3, 0x0a, 0x30, 0xfb, 0x12, 0x1a,
+	0xe1, 0xb7, 0xfc, 0xb0, 0x35, 0x4f, 0x7c, 0xa0, 0xf7, 0xa1, 0x39, 0x48, 0xf0, 0x1b, 0x12, 0x0f,
+	0x53, 0x3f, 0x08, 0xc3, 0x78, 0x48, 0x33, 0x79, 0x59, 0x0d, 0x45, 0x7f, 0x28, 0xc8, 0xe8, 0x36,
+	0x34, 0x72, 0x68, 0x9f, 0x23, 0x6d, 0xbe, 0x5b, 0x7d, 0x84, 0xe4, 0x54, 0xe7, 0x39, 0xcc, 0x0b,
+	0xad, 0x27, 0xec, 0xd9, 0x85, 0x05, 0x73, 0x2b, 0xf5, 0x89, 0x1c, 0x58, 0x24, 0x34, 0xc3, 0x09,
+	0x0d, 0x7a, 0x5c, 0xf6, 0xa2, 0x37, 0xfa, 0x76, 0xff, 0x62, 0x41, 0xf5, 0x51, 0x2f, 0x0e, 0x4f,
+	0xa6, 0x5d, 0x5e, 0x07, 0xe6, 0x8f, 0x31, 0x39, 0x3a, 0x16, 0x92, 0xe7, 0x3c, 0xf9, 0x65, 0xda,
+	0xc8, 0x1e, 0xb3, 0x11, 0x7a, 0x08, 0x55, 0xed, 0x7e, 0xd5, 0xc5, 0xac, 0x4d, 0xbd, 0x18, 0xcf,
+	0x58, 0xe2, 0x3e, 0x83, 0xba, 0xb4, 0xd3, 0xa3, 0xa0, 0x17, 0xd0, 0x10, 0xeb, 0xa7, 0xb4, 0xcc,
+	0x53, 0xde, 0x84, 0x5a, 0x16, 0x67, 0x41, 0xcf, 0x3f, 0x10, 0x50, 0xae, 0xab, 0xed, 0x55, 0x39,
+	0x51, 0x2e, 0x77, 0x6b, 0x50, 0xd9, 0x23, 0xf4, 0x48, 0x05, 0x61, 0x1d, 0xaa, 0xe2, 0x53, 0x04,
+	0x20, 0x0b, 0xd3, 0x5d, 0x9c, 0x9d, 0xc6, 0xc9, 0x89, 0x42, 0x7c, 0x02, 0x8d, 0x11, 0x25, 0x8f,
+	0x52, 0xa6, 0xdf, 0x1b, 0xec, 0x53, 0xc1, 0x91, 0x9a, 0xd4, 0x04, 0x55, 0xc2, 0xdd, 0xef, 0x43,
+	0x5b, 0xea, 0xbe, 0x3b, 0xec, 0x1f, 0xe0, 0x44, 0x4a, 0x44, 0x37, 0xa0, 0x2a, 0x55, 0xf6, 0x69,
+	0xd0, 0xc7, 0x32, 0xc4, 0x2b, 0x92, 0xb6, 0x1b, 0xf4, 0xb1, 0xfb, 0x00, 0x56, 0xc6, 0x96, 0xea,
+	0x5b, 0xcb, 0xb5, 0x9c, 0x93, 0x6f, 0xad, 0xc1, 0xdd, 0x16, 0x34, 0xe4, 0xfa, 0x54, 0x9d, 0xe3,
+	0x1f, 0x36, 0x34, 0x73, 0x9a, 0x14, 0xf7, 0x43, 0x58, 0x94, 0x0b, 0xd3, 0xae, 0x55, 0x08, 0xba,
+	0x71, 0xb8, 0x22, 0x78, 0xa3, 0x45, 0xe8, 0x43, 0x40, 0xe1, 0x30, 0x49, 0x30, 0xcd, 0xfc, 0x03,
+	0xe6, 0x44, 0x3e, 0x77, 0x1d, 0x11, 0xdc, 0x4d, 0xc9, 0xe1, 0xde, 0xf5, 0x05, 0x73, 0xa3, 0x7b,
+	0xd0, 0x1e, 0x43, 0x0b, 0xa7, 0xb2, 0xb9, 0x53, 0x21, 0x03, 0xcf, 0x39, 0xce, 0x6f, 0x66, 0x60,
+	0x41, 0x05, 0xca, 0xc5, 0xce, 0x5e, 0x30, 0xef, 0x4c, 0xc1, 0xbc, 0x45, 0x4f, 0xb1, 0x8b, 0x9e,
+	0xc2, 0x8e, 0x86, 0xdf, 0x8a, 0x20, 0xf1, 0x4f, 0xf0, 0x99, 0x2f, 0x7c, 0x4e, 0x64, 0xd1, 0xa6,
+	0xe2, 0x3c, 0xc1, 0x67, 0xdb, 0x5c, 0xb9, 0x0f, 0x01, 0x11, 0x5a, 0x40, 0xcf, 0x09, 0x34, 0xa1,
+	0x25, 0xe8, 0xfe, 0x20, 0x4e, 0x32, 0x1c, 0x69, 0xe8, 0x79, 0x89, 0x96, 0x1c, 0x85, 0x76, 0x5f,
+	0x41, 0xdb, 0xc3, 0xec, 0x2c, 0xca, 0xfe, 0xd2, 0x91, 0x2e, 0x68, 0x90, 0x2b, 0xb0, 0x48, 0xf1,
+	0xa9, 0x6e, 0x8c, 0x05, 0x8a, 0x4f, 0xb9, 0x9f, 0xad, 0xc2, 0xca, 0x98, 0x64, 0x19, 0x07, 0x2f,
+	0x01, 0xed, 0xe2, 0xb7, 0xd9, 0xd8, 0x86, 0xac, 0x6a, 0x04, 0x69, 0x3a, 0x38, 0x4e, 0x58, 0xd5,
+	0x10, 0x09, 0x42, 0xa3, 0x5c, 0xc0, 0xf4, 0xee, 0xa7, 0xb0, 0x6c, 0x08, 0xbe, 0x9c, 0x5f, 0xff,
+	0xd9, 0x92, 0x7a, 0x45, 0x51, 0x82, 0x53, 0xe5, 0xdb, 0x53, 0x72, 0xc2, 0x77, 0x61, 0xf6, 0x84,
+	0xd0, 0x88, 0x6b, 0x52, 0xdf, 0x72, 0x35, 0xe7, 0x2e, 0x8a, 0xd9, 0x7c, 0x42, 0x68, 0xe4, 0x71,
+	0xbc, 0xbb, 0x05, 0xb3, 0xec, 0x0b, 0xb5, 0xa1, 0xf9, 0x68, 0x67, 0xef, 0xde, 0xbd, 0xfb, 0xf7,
+	0xfd, 0xc7, 0xaf, 0x9e, 0x3f, 0xf6, 0x76, 0x1f, 0x3e, 0x6d, 0xbe, 0xa3, 0x53, 0x77, 0x76, 0x25,
+	0xd5, 0x72, 0xff, 0x0f, 0x96, 0x0d, 0xa1, 0xf2, 0x68, 0x4c, 0x39, 0x41, 0x92, 0x91, 0xae, 0x3e,
+	0xdd, 0x3f, 0x58, 0xb0, 0xba, 0xc3, 0x2f, 0x7b, 0x2f, 0x21, 0x6f, 0x82, 0x0c, 0x3f, 0xc1, 0x67,
+	0x17, 0x35, 0xf5, 0xe4, 0x64, 0x7f, 0x8b, 0xd5, 0x13, 0x2e, 0x8e, 0xbb, 0xd6, 0x29, 0x39, 0xe4,
+	0xee, 0xbd, 0xe4, 0xd5, 0x06, 0xa3, 0x5d, 0x5e, 0x92, 0x43, 0x96, 0xd3, 0x13, 0x9c, 0x86, 0x01,
+	0xe5, 0x3e, 0xbd, 0xe8, 0xc9, 0x2f, 0xd7, 0x81, 0x6e, 0x51, 0x29, 0xe9, 0x16, 0x14, 0xea, 0x32,
+	0x3c, 0x2e, 0xe9, 0x83, 0x1f, 0x43, 0x27, 0xc1, 0xaf, 0x87, 0x24, 0xc1, 0x91, 0x1f, 0xc6, 0xf4,
+	0x90, 0x24, 0xfd, 0x40, 0x14, 0x05, 0x51, 0x50, 0x56, 0x14, 0x77, 0x5b, 0x67, 0xba, 0x14, 0x1a,
+	0xa3, 0xfd, 0xa4, 0x39, 0xdb, 0x30, 0xc7, 0xc3, 0x94, 0xef, 0x63, 0x7b, 0xe2, 0x83, 0x15, 0xa2,
+	0x74, 0x80, 0x69, 0x14, 0x1c, 0xf4, 0x54, 0xde, 0xcf, 0x09, 0xac, 0xc4, 0x92, 0x7e, 0x3f, 0xc8,
+	0x86, 0x09, 0xf6, 0x13, 0x7c, 0x1a, 0x24, 0x91, 0x2a, 0xb1, 0x8a, 0xec, 0x71, 0xaa, 0xfb, 0xa7,
+	0x19, 0xe8, 0xfc, 0x18, 0x67, 0x5a, 0x59, 0x1a, 0xf9, 0xd8, 0x26, 0x2c, 0xa7, 0x59, 0x90, 0x64,
+	0x84, 0x1e, 0xe9, 0xa9, 0x4e, 0xdc, 0x4c, 0x4b, 0xb1, 0xf2, 0x5c, 0xb7, 0x05, 0x2b, 0xe3, 0xf8,
+	0xbc, 0x82, 0xb6, 0xbc, 0x65, 0x73, 0x05, 0x67, 0xa1, 0xbb, 0xd0, 0xc2, 0x34, 0x1a, 0xdb, 0xc1,
+	0xe6, 0x3b, 0x34, 0x04, 0x23, 0x97, 0xbf, 0x09, 0xcb, 0x26, 0x56, 0x48, 0x9f, 0xe5, 0xe6, 0x6c,
+	0xe9, 0x68, 0x21, 0xfb, 0x01, 0x5c, 0xed, 0x13, 0x4a, 0xfa, 0xc3, 0xbe, 0x9f, 0xe0, 0x90, 0xa5,
+	0x60, 0xa3, 0x36, 0xcf, 0xf1, 0x75, 0x57, 0x24, 0xc4, 0xe3, 0x08, 0xdd, 0x0c, 0xee, 0xdf, 0x2d,
+	0x58, 0x2d, 0x98, 0x46, 0xde, 0xc9, 0xe7, 0x80, 0xfa, 0x84, 0xe2, 0xc8, 0x14, 0x29, 0x0a, 0xca,
+	0xaa, 0x16, 0x73, 0xfa, 0x3b, 0xc3, 0x6b, 0xf1, 0x25, 0xba, 0x3c, 0xb4, 0x07, 0xed, 0x21, 0x2d,
+	0x91, 0x34, 0x73, 0x91, 0x87, 0xc3, 0xb2, 0x5c, 0x6a, 0x68, 0xfd, 0x8d, 0x05, 0xab, 0xdb, 0xc7,
+	0x01, 0x3d, 0xc2, 0x7b, 0xa3, 0xd8, 0x51, 0x37, 0xfa, 0x09, 0xd8, 0x27, 0xf8, 0x8c, 0xdf, 0x60,
+	0x7d, 0xeb, 0x96, 0x26, 0x7c, 0xc2, 0x82, 0x4d, 0x16, 0x09, 0x6c, 0x09, 0x73, 0xfa, 0xb8, 0x17,
+	0xf9, 0x5a, 0x80, 0x8a, 0x8a, 0x57, 0x8b, 0x7b, 0x51, 0xbe, 0x8c, 0xc1, 0x58, 0xe2, 0xd5, 0x60,
+	0xe2, 0x2e, 0x6b, 0x14, 0x9f, 0xe6, 0x30, 0x77, 0x1d, 0xec, 0x27, 0xf8, 0x0c, 0x55, 0x60, 0x61,
+	0xcf, 0xdb, 0xf9, 0xf2, 0xe1, 0xf3, 0xc7, 0xcd, 0x77, 0x10, 0xc0, 0xfc, 0xde, 0x8b, 0x47, 0x4f,
+	0x77, 0xb6, 0x9b, 0x16, 0x0b, 0xc8, 0xa2, 0x46, 0x32, 0x20, 0x7f, 0x3d, 0x03, 0x9d, 0xcf, 0x87,
+	0x54, 0x3f, 0xf4, 0xf9, 0x49, 0x91, 0x95, 0xbf, 0x20, 0x39, 0xc2, 0x99, 0x7a, 0x6f, 0xaa, 0x87,
+	0x12, 0x27, 0x8a, 0xd7, 0xe6, 0x94, 0x88, 0xb5, 0xa7, 0x44, 0x2c, 0xfa, 0x14, 0x1c, 0x42, 0xc3,
+	0xde, 0x30, 0xc2, 0xfe, 0x28, 0xe4, 0xc2, 0x98, 0xd0, 0x83, 0x20, 0xc5, 0xa9, 0xcc, 0x34, 0x5d,
+	0x89, 0xd8, 0x91, 0x80, 0x6d, 0xc5, 0x67, 0x41, 0xa3, 0x56, 0x87, 0xfc, 0xc8, 0x7e, 0x1a, 0x26,
+	0x64, 0x20, 0x0a, 0xe9, 0xa2, 0xb7, 0x2c, 0x99, 0xc2, 0x1c, 0xfb, 0x9c, 0xe5, 0xfe, 0xd5, 0x86,
+	0xd5, 0x82, 0x09, 0xa4, 0x63, 0xfe, 0x0c, 0x9a, 0x29, 0xee, 0xe1, 0x90, 0xd5, 0xd9, 0x98, 0xbf,
+	0x9d, 0x95, 0x5b, 0xfe, 0xbf, 0x76, 0xdf, 0x13, 0x56, 0x6f, 0xee, 0xc9, 0xf7, 0xb7, 0xec, 0x15,
+	0x1a, 0x4a, 0x94, 0xf8, 0x4e, 0x59, 0xb9, 0x13, 0xcf, 0x08, 0xc3, 0x8c, 0x15, 0x4e, 0x93, 0x56,
+	0xbc, 0x03, 0x4d, 0x79, 0x90, 0xc1, 0x89, 0x3a, 0x8b, 0x70, 0x82, 0xba, 0xa0, 0xef, 0x9d, 0x88,
+	0x63, 0x38, 0xff, 0xb6, 0xa0, 0x6e, 0x6e, 0xc8, 0x9a, 0x08, 0x2d, 0x0c, 0xf4, 0x7c, 0xd3, 0xd0,
+	0xe8, 0x3c, 0x1b, 0xdc, 0x80, 0xaa, 0x38, 0x9f, 0x2f, 0x1a, 0x03, 0x51, 0x13, 0x2a, 0x82, 0xb6,
+	0xc3, 0x48, 0x2c, 0xdf, 0x1b, 0xed, 0x85, 0xfc, 0x42, 0x57, 0x61, 0x29, 0xd7, 0x6d, 0x96, 0x8b,
+	0x5f, 0x1c, 0x48, 0xad, 0x98, 0x5c, 0x96, 0x2d, 0xd8, 0x5b, 0x97, 0xbd, 0xeb, 0x65, 0x7f, 0x54,
+	0x91, 0xb4, 0xe7, 0x44, 0x3c, 0xa6, 0x0e, 0x93, 0xb8, 0x3f, 0xba, 0x65, 0xfe, 0x8c, 0x59, 0xf4,
+	0xaa, 0x8c, 0xa8, 0x6e, 0xd6, 0xfd, 0xa3, 0x05, 0x9d, 0x7d, 0x72, 0x44, 0x4b, 0xfc, 0xf4, 0xbc,
+	0x4a, 0xf7, 0x31, 0x74, 0x52, 0x9c, 0x90, 0xa0, 0x47, 0x7e, 0x69, 0xe6, 0x05, 0x19, 0x74, 0x2b,
+	0x39, 0x57, 0x93, 0xce, 0xd4, 0x22, 0x74, 0x64, 0x10, 0x2c, 0x9a, 0xca, 0x9a, 0x57, 0x25, 0x54,
+	0x59, 0x04, 0xa7, 0xee, 0x6b, 0x58, 0x2d, 0x68, 0x25, 0x5d, 0x67, 0xac, 0x5f, 0xb5, 0x8a, 0xfd,
+	0xea, 0x7d, 0xe8, 0x0c, 0x69, 0x4a, 0x8e, 0x58, 0xba, 0x32, 0xb7, 0x9a, 0xe1, 0x5b, 0xb5, 0x15,
+	0x77, 0x47, 0xdf, 0xf2, 0x27, 0x70, 0x65, 0x6f, 0x78, 0xd0, 0x23, 0xe9, 0x71, 0x89, 0x2d, 0x3e,
+	0x02, 0x24, 0x05, 0x16, 0xf7, 0x6e, 0x09, 0x8e, 0xb6, 0xca, 0xbd, 0x06, 0x4e, 0x99, 0x2c, 0x99,
+	0x1b, 0x7e, 0x05, 0xd7, 0x35, 0xf2, 0x6e, 0x9c, 0x91, 0x43, 0x12, 0x06, 0x46, 0x51, 0xbb, 0x0b,
+	0xad, 0x04, 0xa7, 0xc3, 0x3e, 0x2e, 0x96, 0xb4, 0x86, 0x60, 0x18, 0x05, 0xc7, 0xc4, 0xea, 0x0d,
+	0x61, 0x4b, 0x47, 0x73, 0x86, 0xfb, 0xf5, 0x0c, 0x6c, 0x4c, 0xde, 0x5f, 0x5a, 0xf9, 0x33, 0x68,
+	0x04, 0x59, 0x16, 0x84, 0xc7, 0x38, 0x12, 0x62, 0xcf, 0x2d, 0x1b, 0x75, 0x85, 0xe7, 0xd4, 0x94,
+	0xd5, 0xf6, 0x08, 0x9b, 0x12, 0x98, 0xf9, 0xab, 0x5e, 0x3d, 0xc2, 0x06, 0x70, 0x52, 0x71, 0xb1,
+	0xbf, 0x6d, 0x71, 0x61, 0xb9, 0xae, 0x44, 0x22, 0x37, 0x22, 0x16, 0xdd, 0x6e, 0xd5, 0xeb, 0x16,
+	0x17, 0x7e, 0xc1, 0xf9, 0xee, 0xef, 0x2c, 0x58, 0xdb, 0x1f, 0x60, 0x9a, 0x51, 0x9c, 0xa6, 0xa5,
+	0xb7, 0x33, 0x39, 0x83, 0xdf, 0x85, 0x16, 0x8d, 0x7d, 0xca, 0x16, 0x9d, 0xf9, 0x43, 0x9a, 0x32,
+	0x31, 0xfc, 0x26, 0x16, 0xbd, 0x06, 0x8d, 0xb9, 0xb0, 0xb3, 0x17, 0x82, 0xcc, 0xde, 0x83, 0x39,
+	0x56, 0x20, 0xc5, 0x0c, 0xa0, 0xa6, 0x90, 0x5c, 0x0b, 0xf7, 0xf7, 0x33, 0xb0, 0x3e, 0x49, 0x1f,
+	0x79, 0x5b, 0xff, 0xdb, 0x84, 0xf4, 0x04, 0x16, 0xf8, 0x13, 0x0d, 0x8b, 0x89, 0x95, 0x99, 0x93,
+	0xa7, 0x6b, 0xc2, 0xd9, 0x11, 0x4e, 0x3c, 0x25, 0xc1, 0x79, 0x01, 0x0b, 0x92, 0x76, 0x19, 0x2d,
+	0xaf, 0x43, 0x85, 0xd0, 0x71, 0x25, 0x21, 0x4f, 0x11, 0xee, 0x1a, 0x5c, 0x55, 0x8d, 0x78, 0xc9,
+	0x0d, 0xb9, 0xff, 0xb1, 0xe0, 0x5a, 0x39, 0xff, 0x52, 0x7d, 0xcd, 0x45, 0x7a, 0xd6, 0xf2, 0x76,
+	0xd4, 0xbe, 0x54, 0x3b, 0x3a, 0x7b, 0xa9, 0x76, 0x74, 0x6e, 0x42, 0x3b, 0xfa, 0x5b, 0x0b, 0x96,
+	0xb7, 0x13, 0x1c, 0x64, 0xf8, 0x25, 0xbf, 0x2e, 0xe5, 0xae, 0x1f, 0x40, 0x6b, 0xc0, 0xb2, 0x51,
+	0xe8, 0x17, 0xf2, 0x79, 0x53, 0x30, 0xb4, 0xb7, 0xd1, 0x47, 0x80, 0x54, 0x97, 0x52, 0x78, 0x46,
+	0xb5, 0x24, 0x47, 0x83, 0x23, 0x98, 0x4d, 0x31, 0x8e, 0x64, 0xed, 0xe4, 0xbf, 0xdd, 0x0e, 0xb4,
+	0x4d, 0x35, 0x64, 0xde, 0xfb, 0x0c, 0x5a, 0xcf, 0x06, 0x98, 0x7e, 0x7b, 0xe5, 0xdc, 0x36, 0x20,
+	0x5d, 0x82, 0x94, 0xdb, 0x06, 0xb4, 0xdd, 0x8b, 0x53, 0xf3, 0xd4, 0xee, 0x0a, 0x2c, 0x1b, 0x54,
+	0x09, 0x5e, 0x81, 0x65, 0x41, 0x79, 0xfc, 0x96, 0xa4, 0xf9, 0x14, 0x66, 0x13, 0xda, 0x26, 0x59,
+	0xfa, 0x49, 0x07, 0xe6, 0x31, 0xa7, 0x70, 0x9d, 0x16, 0x3d, 0xf9, 0xe5, 0x7e, 0x6d, 0x41, 0x77,
+	0x3f, 0x0b, 0x92, 0x6c, 0x9b, 0xc1, 0x68, 0x3a, 0x4c, 0xbd, 0x41, 0xa8, 0xce, 0x74, 0x1b, 0x1a,
+	0x72, 0x00, 0xe5, 0x9b, 0x1d, 0x66, 0x5d, 0x92, 0x65, 0x2b, 0xca, 0xe6, 0x7f, 0xc3, 0x14, 0x27,
+	0x9a, 0x6b, 0x8d, 0xbe, 0x19, 0x8f, 0x59, 0xe4, 0x34, 0x4e, 0x94, 0x75, 0x47, 0xdf, 0xac, 0x06,
+	0x86, 0x38, 0x91, 0x7e, 0x8d, 0xe5, 0xe3, 0x40, 0x27, 0xb9, 0x57, 0xe1, 0x4a, 0x89, 0x7a, 0xe2,
+	0x50, 0x5b, 0xde, 0x68, 0xe6, 0xbd, 0x8f, 0x93, 0x37, 0x24, 0x64, 0xe9, 0x7e, 0x41, 0x52, 0xd0,
+	0x15, 0x2d, 0xd8, 0xcd, 0xc9, 0xb8, 0xe3, 0x94, 0xb1, 0xa4, 0xcc, 0x7f, 0x55, 0xa0, 0x26, 0x2c,
+	0xa8, 0x64, 0x7e, 0x0f, 0x66, 0xd9, 0x08, 0x0f, 0x75, 0xb4, 0x55, 0xda, 0x88, 0xcf, 0x59, 0x2d,
+	0xd0, 0x47, 0xb5, 0x67, 0x41, 0x8e, 0xea, 0x0c, 0x65, 0xcc, 0xf9, 0x9f, 0xe3, 0x94, 0xb1, 0xa4,
+	0x04, 0x0f, 0x6a, 0xc6, 0x98, 0x0e, 0x5d, 0x2f, 0x4e, 0xcf, 0x8c, 0xd9, 0x9f, 0xb3, 0x31, 0x19,
+	0x20, 0x65, 0x6e, 0xc3, 0xe2, 0x43, 0x35, 0x5d, 0x73, 0x4a, 0x87, 0x71, 0x42, 0xd2, 0xd5, 0x29,
+	0x83, 0x3a, 0x76, 0x34, 0x35, 0xc6, 0xd2, 0x8f, 0x66, 0xf6, 0xee, 0x8e, 0x53, 0xc6, 0x92, 0x12,
+	0x5e, 0x41, 0x63, 0xac, 0xdb, 0x43, 0x37, 0x34, 0x78, 0x79, 0x93, 0xec, 0xb8, 0xd3, 0x20, 0x52,
+	0xf2, 0x10, 0xba, 0x93, 0x9e, 0x05, 0xe8, 0x6e, 0x79, 0x15, 0x2e, 0xcb, 0xbd, 0xce, 0x07, 0x17,
+	0xc2, 0x8a, 0x4d, 0xef, 0x59, 0x28, 0x86, 0x4e, 0x79, 0x4d, 0x41, 0x77, 0x2e, 0x50, 0x76, 0xc4,
+	0x96, 0xef, 0x5f, 0xb8, 0x40, 0xdd, 0xb3, 0x10, 0xc9, 0xc7, 0xbf, 0xc6, 0x76, 0xb7, 0x4a, 0x5c,
+	0xa0, 0x6c, 0xb3, 0xdb, 0xe7, 0xe2, 0x46, 0x5b, 0x7d, 0x05, 0xcd, 0xf1, 0x0e, 0x11, 0xb9, 0xe7,
+	0x37, 0xb4, 0xce, 0xcd, 0xa9, 0x98, 0xdc, 0xc9, 0x8d, 0x19, 0xa1, 0xe1, 0xe4, 0x65, 0x73, 0x49,
+	0x67, 0x63, 0x32, 0x40, 0xca, 0x7c, 0x0a, 0x15, 0x6d, 0x0a, 0x88, 0xd6, 0xc6, 0xe7, 0x72, 0xa6,
+	0xbc, 0xf5, 0x49, 0xec, 0x31, 0x69, 0x32, 0xdb, 0xad, 0x4d, 0x9d, 0xf2, 0x39, 0xeb, 0x93, 0xd8,
+	0x52, 0xda, 0x57, 0xd0, 0x1c, 0x9f, 0x7f, 0x19, 0xc6, 0x9c, 0x30, 0xb1, 0x73, 0x6e, 0x4e, 0xc5,
+	0xe4, 0x61, 0x35, 0xd6, 0x6d, 0x1a, 0x61, 0x55, 0xde, 0xca, 0x3b, 0xee, 0x34, 0x48, 0x2e, 0x79,
+	0xac, 0x95, 0x31, 0x24, 0x97, 0x37, 0x5f, 0x8e, 0x3b, 0x0d, 0x22, 0x25, 0x07, 0x80, 0x8a, 0x5d,
+	0x06, 0xd2, 0xff, 0x5f, 0x9b, 0xd8, 0xd0, 0x38, 0xef, 0x9d, 0x83, 0x92, 0x59, 0xfd, 0x6f, 0xb6,
+	0x2a, 0x97, 0x4f, 0xe3, 0x20, 0xc2, 0x89, 0xca, 0xed, 0xcf, 0xa0, 0xaa, 0x97, 0x4b, 0xa4, 0xdf,
+	0x5d, 0x49, 0x79, 0x75, 0xae, 0x4f, 0xe4, 0xcb, 0xb3, 0x3c, 0x83, 0xaa, 0xfe, 0x66, 0x30, 0x04,
+	0x96, 0xbc, 0x69, 0x9c, 0xeb, 0x13, 0xf9, 0x52, 0xe0, 0x0e, 0x40, 0xfe, 0x54, 0x40, 0xd7, 0x34,
+	0x78, 0xe1, 0x0d, 0xe2, 0xac, 0x4d, 0xe0, 0xe6, 0x6e, 0xac, 0xbd, 0x24, 0x0c, 0x37, 0x2e, 0xbe,
+	0x3b, 0x9c, 0xf5, 0x49, 0x6c, 0x29, 0xed, 0xe7, 0xd0, 0x2a, 0x54, 0x66, 0xa4, 0xfb, 0xe8, 0xa4,
+	0x67, 0x85, 0xf3, 0xee, 0x74, 0x90, 0x90, 0x7f, 0x30, 0xcf, 0xff, 0xe2, 0xfe, 0xce, 0x7f, 0x07,
+	0x00, 0xd0, 0xaf, 0x72, 0xfb, 0xef, 0x1e, 0x00, 0x00,
 }
@@ -11,9 +11,11 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcwallet/walletdb"
 )
 
@@ -238,6 +240,41 @@ var (
 	// addresses hash if the address has been used or not.
 	usedAddrBucketName = []byte("usedaddrs")
 
+	// usedIndexBucketName is the name of the bucket that stores, for each
+	// branch of an account, a bitmap of the address indexes that have
+	// received a credit.
+	//
+	// account || branch => bitmap
+	usedIndexBucketName = []byte("usedindexes")
+
+	// usedIndexHeightBucketName is the name of the bucket that stores the
+	// lowest height of a block containing a credit to each used address
+	// index, or -1 if only unmined credits were recorded. This allows the
+	// bitmaps to be rolled back on reorgs.
+	//
+	// account || branch || index => height
+	usedIndexHeightBucketName = []byte("usedindexheights")
+
+	// disabledAcctBucketName is the name of the bucket that stores the
+	// numbers of the accounts that have been disabled.
+	//
+	// account => 0
+	disabledAcctBucketName = []byte("disabledaccts")
+
+	// changeAddrTypeBucketName is the name of the bucket that stores the
+	// address types of the internal addresses of the accounts, from the
+	// index of the first internal address of each type onwards. Internal
+	// addresses with no stored type use the account's address schema.
+	//
+	// account || index => address type
+	changeAddrTypeBucketName = []byte("changeaddrtypes")
+
+	// addrLabelBucketName is the name of the bucket that stores the labels
+	// of the addresses, such as those imported from a wallet dump.
+	//
+	// sha256(address id) => label
+	addrLabelBucketName = []byte("addrlabels")
+
 	// meta is used to store meta-data about the address manager
 	// e.g. last account number
 	metaBucketName = []byte("meta")
@@ -269,6 +306,7 @@ var (
 	// Db related key names (main bucket).
 	mgrVersionName    = []byte("mgrver")
 	mgrCreateDateName = []byte("mgrcreated")
+	mgrNetName        = []byte("mgrnet")
 
 	// Crypto related key names (main bucket).
 	masterPrivKeyName   = []byte("mpriv")
@@ -284,6 +322,8 @@ var (
 	birthdayName              = []byte("birthday")
 	birthdayBlockName         = []byte("birthdayblock")
 	birthdayBlockVerifiedName = []byte("birthdayblockverified")
+	historicalScanSkippedName = []byte("historicalscanskipped")
+	notificationJournalName   = []byte("ntfnjournal")
 )
 
 // uint32ToBytes converts a 32 bit unsigned integer into a 4-byte slice in
@@ -650,6 +690,36 @@ func putCryptoKeys(ns walletdb.ReadWriteBucket, pubKeyEncrypted, privKeyEncrypte
 	return nil
 }
 
+// FetchNetwork loads the network the manager was created for from the
+// database. A ManagerError with an error code of ErrNoExist is returned if the
+// manager was created before the network was persisted.
+func FetchNetwork(ns walletdb.ReadBucket) (wire.BitcoinNet, error) {
+	bucket := ns.NestedReadBucket(mainBucketName)
+
+	buf := bucket.Get(mgrNetName)
+	if buf == nil {
+		str := "network not stored in database"
+		return 0, managerError(ErrNoExist, str, nil)
+	}
+	if len(buf) != 4 {
+		str := "malformed network stored in database"
+		return 0, managerError(ErrDatabase, str, nil)
+	}
+
+	return wire.BitcoinNet(binary.LittleEndian.Uint32(buf)), nil
+}
+
+// PutNetwork stores the network the manager was created for to the database.
+func PutNetwork(ns walletdb.ReadWriteBucket, net wire.BitcoinNet) error {
+	bucket := ns.NestedReadWriteBucket(mainBucketName)
+
+	if err := bucket.Put(mgrNetName, uint32ToBytes(uint32(net))); err != nil {
+		str := "failed to store network"
+		return managerError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
 // fetchWatchingOnly loads the watching-only flag from the database.
 func fetchWatchingOnly(ns walletdb.ReadBucket) (bool, error) {
 	bucket := ns.NestedReadBucket(mainBucketName)
@@ -1540,15 +1610,22 @@ func fetchAddressByHash(ns walletdb.ReadBucket, scope *KeyScope,
 func fetchAddressUsed(ns walletdb.ReadBucket, scope *KeyScope,
 	addressID []byte) bool {
 
+	addrHash := sha256.Sum256(addressID)
+	return fetchAddressHashUsed(ns, scope, addrHash[:])
+}
+
+// fetchAddressHashUsed returns true if the address whose id hashes to the
+// provided hash was flagged as used.
+func fetchAddressHashUsed(ns walletdb.ReadBucket, scope *KeyScope,
+	addrHash []byte) bool {
+
 	scopedBucket, err := fetchReadScopeBucket(ns, scope)
 	if err != nil {
 		return false
 	}
 
 	bucket := scopedBucket.NestedReadBucket(usedAddrBucketName)
-
-	addrHash := sha256.Sum256(addressID)
-	return bucket.Get(addrHash[:]) != nil
+	return bucket.Get(addrHash) != nil
 }
 
 // markAddressUsed flags the provided address id as used in the database.
@@ -1577,6 +1654,350 @@ func markAddressUsed(ns walletdb.ReadWriteBucket, scope *KeyScope,
 	return nil
 }
 
+// usedIndexesKey returns the key of the used address index bitmap of an account
+// branch.
+func usedIndexesKey(account, branch uint32) []byte {
+	var key [8]byte
+	binary.LittleEndian.PutUint32(key[:4], account)
+	binary.LittleEndian.PutUint32(key[4:], branch)
+	return key[:]
+}
+
+// fetchUsedIndexes returns the bitmap of the used address indexes of the given
+// account branch.
+func fetchUsedIndexes(ns walletdb.ReadBucket, scope *KeyScope, account,
+	branch uint32) ([]byte, error) {
+
+	scopedBucket, err := fetchReadScopeBucket(ns, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// The bucket is only created once the first index is marked used.
+	bucket := scopedBucket.NestedReadBucket(usedIndexBucketName)
+	if bucket == nil {
+		return nil, nil
+	}
+
+	bitmap := bucket.Get(usedIndexesKey(account, branch))
+	return append([]byte(nil), bitmap...), nil
+}
+
+// markIndexUsed flags the address index of the given account branch as used
+// by a credit at the given height, or an unmined credit if height is -1.
+func markIndexUsed(ns walletdb.ReadWriteBucket, scope *KeyScope, account,
+	branch, index uint32, height int32) error {
+
+	scopedBucket, err := fetchWriteScopeBucket(ns, scope)
+	if err != nil {
+		return err
+	}
+	bitmaps, err := scopedBucket.CreateBucketIfNotExists(
+		usedIndexBucketName,
+	)
+	if err != nil {
+		str := "failed to create used indexes bucket"
+		return managerError(ErrDatabase, str, err)
+	}
+	heights, err := scopedBucket.CreateBucketIfNotExists(
+		usedIndexHeightBucketName,
+	)
+	if err != nil {
+		str := "failed to create used index heights bucket"
+		return managerError(ErrDatabase, str, err)
+	}
+
+	bitmapKey := usedIndexesKey(account, branch)
+	bitmap := bitmaps.Get(bitmapKey)
+	byteIndex, mask := index/8, byte(1)<<(index%8)
+	if uint32(len(bitmap)) <= byteIndex {
+		grown := make([]byte, byteIndex+1)
+		copy(grown, bitmap)
+		bitmap = grown
+	} else {
+		bitmap = append([]byte(nil), bitmap...)
+	}
+	bitmap[byteIndex] |= mask
+	if err := bitmaps.Put(bitmapKey, bitmap); err != nil {
+		str := fmt.Sprintf("failed to mark index %d of account %d "+
+			"branch %d used", index, account, branch)
+		return managerError(ErrDatabase, str, err)
+	}
+
+	// Only the lowest height of a block containing a credit to the index
+	// is stored, with unmined credits only being stored if no mined ones
+	// were recorded.
+	heightKey := append(bitmapKey, uint32ToBytes(index)...)
+	if v := heights.Get(heightKey); v != nil {
+		oldHeight := int32(binary.LittleEndian.Uint32(v))
+		if height < 0 || (oldHeight >= 0 && oldHeight <= height) {
+			return nil
+		}
+	}
+	err = heights.Put(heightKey, uint32ToBytes(uint32(height)))
+	if err != nil {
+		str := fmt.Sprintf("failed to store height of index %d of "+
+			"account %d branch %d", index, account, branch)
+		return managerError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// rollbackUsedIndexes handles the removal of all blocks at the given height
+// onwards by revisiting each index whose lowest credit height is within them.
+// The index remains marked used by an unmined credit if stillUsed returns
+// true for it. Otherwise, it's no longer marked used.
+func rollbackUsedIndexes(ns walletdb.ReadWriteBucket, scope *KeyScope,
+	height int32,
+	stillUsed func(account, branch, index uint32) (bool, error)) error {
+
+	scopedBucket, err := fetchWriteScopeBucket(ns, scope)
+	if err != nil {
+		return err
+	}
+	bitmaps := scopedBucket.NestedReadWriteBucket(usedIndexBucketName)
+	heights := scopedBucket.NestedReadWriteBucket(usedIndexHeightBucketName)
+	if bitmaps == nil || heights == nil {
+		return nil
+	}
+
+	// The buckets can't be modified while iterating over them, so we'll
+	// gather the indexes to revisit first.
+	var rolledBack [][]byte
+	err = heights.ForEach(func(k, v []byte) error {
+		if int32(binary.LittleEndian.Uint32(v)) >= height {
+			rolledBack = append(rolledBack, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		str := "failed to iterate used index heights"
+		return managerError(ErrDatabase, str, err)
+	}
+
+	for _, k := range rolledBack {
+		account := binary.LittleEndian.Uint32(k[:4])
+		branch := binary.LittleEndian.Uint32(k[4:8])
+		index := binary.LittleEndian.Uint32(k[8:])
+
+		used, err := stillUsed(account, branch, index)
+		if err != nil {
+			return err
+		}
+		if used {
+			unmined := int32(-1)
+			err := heights.Put(k, uint32ToBytes(uint32(unmined)))
+			if err != nil {
+				str := fmt.Sprintf("failed to store height of "+
+					"index %d of account %d branch %d",
+					index, account, branch)
+				return managerError(ErrDatabase, str, err)
+			}
+			continue
+		}
+
+		if err := heights.Delete(k); err != nil {
+			str := fmt.Sprintf("failed to delete height of index "+
+				"%d of account %d branch %d", index, account,
+				branch)
+			return managerError(ErrDatabase, str, err)
+		}
+
+		bitmapKey := k[:8]
+		bitmap := append([]byte(nil), bitmaps.Get(bitmapKey)...)
+		byteIndex := index / 8
+		if uint32(len(bitmap)) <= byteIndex {
+			continue
+		}
+		bitmap[byteIndex] &^= byte(1) << (index % 8)
+		if err := bitmaps.Put(bitmapKey, bitmap); err != nil {
+			str := fmt.Sprintf("failed to mark index %d of "+
+				"account %d branch %d unused", index, account,
+				branch)
+			return managerError(ErrDatabase, str, err)
+		}
+	}
+
+	return nil
+}
+
+// isAccountDisabled returns whether the account with the given number has been
+// disabled.
+func isAccountDisabled(ns walletdb.ReadBucket, scope *KeyScope,
+	account uint32) (bool, error) {
+
+	scopedBucket, err := fetchReadScopeBucket(ns, scope)
+	if err != nil {
+		return false, err
+	}
+
+	// The bucket is only created once the first account is disabled.
+	bucket := scopedBucket.NestedReadBucket(disabledAcctBucketName)
+	if bucket == nil {
+		return false, nil
+	}
+
+	return bucket.Get(uint32ToBytes(account)) != nil, nil
+}
+
+// putAccountDisabled disables or re-enables the account with the given number.
+func putAccountDisabled(ns walletdb.ReadWriteBucket, scope *KeyScope,
+	account uint32, disabled bool) error {
+
+	scopedBucket, err := fetchWriteScopeBucket(ns, scope)
+	if err != nil {
+		return err
+	}
+	bucket, err := scopedBucket.CreateBucketIfNotExists(
+		disabledAcctBucketName,
+	)
+	if err != nil {
+		str := "failed to create disabled accounts bucket"
+		return managerError(ErrDatabase, str, err)
+	}
+
+	key := uint32ToBytes(account)
+	if disabled {
+		err = bucket.Put(key, []byte{0})
+	} else {
+		err = bucket.Delete(key)
+	}
+	if err != nil {
+		str := fmt.Sprintf("failed to store disabled state of "+
+			"account %d", account)
+		return managerError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// changeAddrTypeRange is the address type of the internal addresses of an
+// account from the given index onwards, up to the index of the next range.
+type changeAddrTypeRange struct {
+	index    uint32
+	addrType AddressType
+}
+
+// fetchChangeAddrTypes returns the address type ranges of the internal
+// addresses of the account with the given number, ordered by index.
+func fetchChangeAddrTypes(ns walletdb.ReadBucket, scope *KeyScope,
+	account uint32) ([]changeAddrTypeRange, error) {
+
+	scopedBucket, err := fetchReadScopeBucket(ns, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// The bucket is only created once the first address type is stored.
+	bucket := scopedBucket.NestedReadBucket(changeAddrTypeBucketName)
+	if bucket == nil {
+		return nil, nil
+	}
+
+	var ranges []changeAddrTypeRange
+	prefix := uint32ToBytes(account)
+	err = bucket.ForEach(func(k, v []byte) error {
+		if len(k) != 8 || len(v) != 1 || !bytes.HasPrefix(k, prefix) {
+			return nil
+		}
+		ranges = append(ranges, changeAddrTypeRange{
+			index:    binary.LittleEndian.Uint32(k[4:]),
+			addrType: AddressType(v[0]),
+		})
+		return nil
+	})
+	if err != nil {
+		str := fmt.Sprintf("failed to fetch change address types of "+
+			"account %d", account)
+		return nil, managerError(ErrDatabase, str, err)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].index < ranges[j].index
+	})
+	return ranges, nil
+}
+
+// putChangeAddrType stores the address type of the internal addresses of the
+// account with the given number, from the given index onwards.
+func putChangeAddrType(ns walletdb.ReadWriteBucket, scope *KeyScope,
+	account uint32, r changeAddrTypeRange) error {
+
+	scopedBucket, err := fetchWriteScopeBucket(ns, scope)
+	if err != nil {
+		return err
+	}
+	bucket, err := scopedBucket.CreateBucketIfNotExists(
+		changeAddrTypeBucketName,
+	)
+	if err != nil {
+		str := "failed to create change address types bucket"
+		return managerError(ErrDatabase, str, err)
+	}
+
+	key := append(uint32ToBytes(account), uint32ToBytes(r.index)...)
+	if err := bucket.Put(key, []byte{byte(r.addrType)}); err != nil {
+		str := fmt.Sprintf("failed to store change address type of "+
+			"account %d", account)
+		return managerError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// fetchAddressLabel returns the label of the address with the given id, or an
+// empty string if it has none.
+func fetchAddressLabel(ns walletdb.ReadBucket, scope *KeyScope,
+	addressID []byte) (string, error) {
+
+	scopedBucket, err := fetchReadScopeBucket(ns, scope)
+	if err != nil {
+		return "", err
+	}
+
+	// The bucket is only created once the first address is labeled.
+	bucket := scopedBucket.NestedReadBucket(addrLabelBucketName)
+	if bucket == nil {
+		return "", nil
+	}
+
+	addrHash := sha256.Sum256(addressID)
+	return string(bucket.Get(addrHash[:])), nil
+}
+
+// putAddressLabel stores the label of the address with the given id, or
+// removes it if the label is empty.
+func putAddressLabel(ns walletdb.ReadWriteBucket, scope *KeyScope,
+	addressID []byte, label string) error {
+
+	scopedBucket, err := fetchWriteScopeBucket(ns, scope)
+	if err != nil {
+		return err
+	}
+	bucket, err := scopedBucket.CreateBucketIfNotExists(
+		addrLabelBucketName,
+	)
+	if err != nil {
+		str := "failed to create address labels bucket"
+		return managerError(ErrDatabase, str, err)
+	}
+
+	addrHash := sha256.Sum256(addressID)
+	if label == "" {
+		err = bucket.Delete(addrHash[:])
+	} else {
+		err = bucket.Put(addrHash[:], []byte(label))
+	}
+	if err != nil {
+		str := fmt.Sprintf("failed to store label of address %x",
+			addressID)
+		return managerError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
 // fetchAddress loads address information for the provided address id from the
 // database.  The returned value is one of the address rows for the specific
 // address type.  The caller should use type assertions to ascertain the type.
@@ -1615,6 +2036,22 @@ func putAddress(ns walletdb.ReadWriteBucket, scope *KeyScope,
 	return putAddrAccountIndex(ns, scope, row.account, addrHash[:])
 }
 
+// putChainedAddressRow stores the provided chained address information to the
+// database, without updating the next index of its branch.
+func putChainedAddressRow(ns walletdb.ReadWriteBucket, scope *KeyScope,
+	addressID []byte, account uint32, status syncStatus, branch,
+	index uint32, addrType addressType) error {
+
+	addrRow := dbAddressRow{
+		addrType:   addrType,
+		account:    account,
+		addTime:    uint64(time.Now().Unix()),
+		syncStatus: status,
+		rawData:    serializeChainedAddress(branch, index),
+	}
+	return putAddress(ns, scope, addressID, &addrRow)
+}
+
 // putChainedAddress stores the provided chained address information to the
 // database.
 func putChainedAddress(ns walletdb.ReadWriteBucket, scope *KeyScope,
@@ -1626,14 +2063,10 @@ func putChainedAddress(ns walletdb.ReadWriteBucket, scope *KeyScope,
 		return err
 	}
 
-	addrRow := dbAddressRow{
-		addrType:   addrType,
-		account:    account,
-		addTime:    uint64(time.Now().Unix()),
-		syncStatus: status,
-		rawData:    serializeChainedAddress(branch, index),
-	}
-	if err := putAddress(ns, scope, addressID, &addrRow); err != nil {
+	err = putChainedAddressRow(
+		ns, scope, addressID, account, status, branch, index, addrType,
+	)
+	if err != nil {
 		return err
 	}
 
@@ -1710,16 +2143,16 @@ func putChainedAddress(ns walletdb.ReadWriteBucket, scope *KeyScope,
 }
 
 // putImportedAddress stores the provided imported address information to the
-// database.
+// database, recording the time it was added at.
 func putImportedAddress(ns walletdb.ReadWriteBucket, scope *KeyScope,
-	addressID []byte, account uint32, status syncStatus,
+	addressID []byte, account uint32, status syncStatus, addTime time.Time,
 	encryptedPubKey, encryptedPrivKey []byte) error {
 
 	rawData := serializeImportedAddress(encryptedPubKey, encryptedPrivKey)
 	addrRow := dbAddressRow{
 		addrType:   adtImport,
 		account:    account,
-		addTime:    uint64(time.Now().Unix()),
+		addTime:    uint64(addTime.Unix()),
 		syncStatus: status,
 		rawData:    rawData,
 	}
@@ -1787,6 +2220,21 @@ func fetchAddrAccount(ns walletdb.ReadBucket, scope *KeyScope,
 func forEachAccountAddress(ns walletdb.ReadBucket, scope *KeyScope,
 	account uint32, fn func(rowInterface interface{}) error) error {
 
+	return forEachAccountAddressHash(
+		ns, scope, account,
+		func(_ []byte, rowInterface interface{}) error {
+			return fn(rowInterface)
+		},
+	)
+}
+
+// forEachAccountAddressHash calls the given function with each address of the
+// given account stored in the manager along with the hash of the address ID it
+// is keyed by, breaking early on error.
+func forEachAccountAddressHash(ns walletdb.ReadBucket, scope *KeyScope,
+	account uint32,
+	fn func(addrHash []byte, rowInterface interface{}) error) error {
+
 	scopedBucket, err := fetchReadScopeBucket(ns, scope)
 	if err != nil {
 		return err
@@ -1818,7 +2266,7 @@ func forEachAccountAddress(ns walletdb.ReadBucket, scope *KeyScope,
 			return err
 		}
 
-		return fn(addrRow)
+		return fn(k, addrRow)
 	})
 	if err != nil {
 		return maybeConvertDbError(err)
@@ -2344,6 +2792,89 @@ func putBirthdayBlockVerification(ns walletdb.ReadWriteBucket, verified bool) er
 	return nil
 }
 
+// fetchHistoricalScanSkipped retrieves the bit that determines whether the
+// wallet skipped scanning historical blocks when it was first synced.
+func fetchHistoricalScanSkipped(ns walletdb.ReadBucket) bool {
+	bucket := ns.NestedReadBucket(syncBucketName)
+	skippedValue := bucket.Get(historicalScanSkippedName)
+
+	// If there is no value stored, the historical blocks weren't skipped.
+	if len(skippedValue) != 1 {
+		return false
+	}
+
+	return skippedValue[0] != 0
+}
+
+// putHistoricalScanSkipped stores a bit that determines whether the wallet
+// skipped scanning historical blocks when it was first synced.
+func putHistoricalScanSkipped(ns walletdb.ReadWriteBucket, skipped bool) error {
+	var encoded byte
+	if skipped {
+		encoded = 1
+	}
+
+	bucket := ns.NestedReadWriteBucket(syncBucketName)
+	err := bucket.Put(historicalScanSkippedName, []byte{encoded})
+	if err != nil {
+		str := "failed to store historical scan skipped flag"
+		return managerError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// fetchNotificationJournal retrieves the last block whose notification was
+// fully processed by the wallet from the database. If no such block has been
+// recorded, an ErrNoExist error is returned.
+//
+// The block is serialized as follows:
+//   [0:4]   block height
+//   [4:36]  block hash
+//   [36:44] block timestamp
+func fetchNotificationJournal(ns walletdb.ReadBucket) (*BlockStamp, error) {
+	bucket := ns.NestedReadBucket(syncBucketName)
+	journal := bucket.Get(notificationJournalName)
+	if journal == nil {
+		str := "notification journal not set"
+		return nil, managerError(ErrNoExist, str, nil)
+	}
+	if len(journal) != 44 {
+		str := "malformed notification journal stored in database"
+		return nil, managerError(ErrDatabase, str, nil)
+	}
+
+	var block BlockStamp
+	block.Height = int32(binary.BigEndian.Uint32(journal[:4]))
+	copy(block.Hash[:], journal[4:36])
+	t := int64(binary.BigEndian.Uint64(journal[36:]))
+	block.Timestamp = time.Unix(t, 0)
+
+	return &block, nil
+}
+
+// putNotificationJournal stores the last block whose notification was fully
+// processed by the wallet to the database.
+//
+// The block is serialized as follows:
+//   [0:4]   block height
+//   [4:36]  block hash
+//   [36:44] block timestamp
+func putNotificationJournal(ns walletdb.ReadWriteBucket, block *BlockStamp) error {
+	var journal [44]byte
+	binary.BigEndian.PutUint32(journal[:4], uint32(block.Height))
+	copy(journal[4:36], block.Hash[:])
+	binary.BigEndian.PutUint64(journal[36:], uint64(block.Timestamp.Unix()))
+
+	bucket := ns.NestedReadWriteBucket(syncBucketName)
+	if err := bucket.Put(notificationJournalName, journal[:]); err != nil {
+		str := "failed to store notification journal"
+		return managerError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
 // managerExists returns whether or not the manager has already been created
 // in the given database namespace.
 func managerExists(ns walletdb.ReadBucket) bool {
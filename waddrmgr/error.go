@@ -143,6 +143,14 @@ const (
 	// ErrAccountNotCached is returned when we attempt to perform an
 	// operation that relies on an account begin cached but it isn't.
 	ErrAccountNotCached
+
+	// ErrAccountDisabled is returned when we attempt to derive a new
+	// address for an account that has been disabled.
+	ErrAccountDisabled
+
+	// ErrInvalidAddrType is returned when we attempt to derive addresses
+	// of a type that can't be derived for an account.
+	ErrInvalidAddrType
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -170,6 +178,8 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrEmptyPassphrase:   "ErrEmptyPassphrase",
 	ErrScopeNotFound:     "ErrScopeNotFound",
 	ErrAccountNotCached:  "ErrAccountNotCached",
+	ErrAccountDisabled:   "ErrAccountDisabled",
+	ErrInvalidAddrType:   "ErrInvalidAddrType",
 }
 
 // String returns the ErrorCode as a human-readable name.
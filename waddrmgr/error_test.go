@@ -39,6 +39,8 @@ func TestErrorCodeStringer(t *testing.T) {
 		{waddrmgr.ErrWrongNet, "ErrWrongNet"},
 		{waddrmgr.ErrCallBackBreak, "ErrCallBackBreak"},
 		{waddrmgr.ErrEmptyPassphrase, "ErrEmptyPassphrase"},
+		{waddrmgr.ErrAccountDisabled, "ErrAccountDisabled"},
+		{waddrmgr.ErrInvalidAddrType, "ErrInvalidAddrType"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 	t.Logf("Running %d tests", len(tests))
@@ -77,6 +77,11 @@ const (
 	// branch.
 	InternalBranch uint32 = 1
 
+	// DefaultGapLimit is the default number of consecutive unused
+	// addresses past the last used one of each account branch that are
+	// expected to be derived and watched, as recommended by BIP0044.
+	DefaultGapLimit = 20
+
 	// saltSize is the number of bytes of the salt used when hashing
 	// private passphrases.
 	saltSize = 32
@@ -170,6 +175,10 @@ type accountInfo struct {
 	// derivation scheme into our KeyScopeBIP-0049Plus manager.
 	addrSchema *ScopeAddrSchema
 
+	// changeAddrTypes are the address types set for ranges of internal
+	// addresses of the account, ordered by index.
+	changeAddrTypes []changeAddrTypeRange
+
 	// masterKeyFingerprint represents the fingerprint of the root key
 	// corresponding to the master public key (also known as the key with
 	// derivation path m/). This may be required by some hardware wallets
@@ -220,6 +229,56 @@ type AccountProperties struct {
 	// AddrSchema, if non-nil, specifies an address schema override for
 	// address generation only applicable to the account.
 	AddrSchema *ScopeAddrSchema
+
+	// ChangeAddrType is the type of the next internal address derived for
+	// the account, as determined by its address schema unless another
+	// type was set through SetAccountChangeAddrType.
+	//
+	// NOTE: This isn't set for the imported account.
+	ChangeAddrType AddressType
+
+	// Disabled indicates whether the account has been disabled, such that
+	// it no longer issues new external addresses and its outputs are
+	// excluded from default coin selection.
+	Disabled bool
+}
+
+// BranchDerivationState describes the addresses derived on a branch of an
+// account.
+type BranchDerivationState struct {
+	// NextIndex is the index of the next address to be derived on the
+	// branch, which is also the number of addresses derived so far.
+	NextIndex uint32
+
+	// LastUsedIndex is the index of the highest address of the branch
+	// that has been used.
+	//
+	// NOTE: This is only set if HasUsed is true.
+	LastUsedIndex uint32
+
+	// HasUsed indicates whether any address of the branch has been used.
+	HasUsed bool
+}
+
+// DerivationState describes the derivation of the addresses of an account,
+// as returned by AccountDerivationState.
+type DerivationState struct {
+	// KeyScope is the key scope the account belongs to.
+	KeyScope KeyScope
+
+	// Account is the internal number of the account.
+	Account uint32
+
+	// External describes the addresses derived on the external branch.
+	External BranchDerivationState
+
+	// Internal describes the addresses derived on the internal branch.
+	Internal BranchDerivationState
+
+	// GapLimit is the number of consecutive unused addresses past the last
+	// used one of each branch that are expected to be derived and watched,
+	// as set through SetGapLimit.
+	GapLimit uint32
 }
 
 // unlockDeriveInfo houses the information needed to derive a private key for a
@@ -346,6 +405,7 @@ type Manager struct {
 
 	syncState    syncState
 	watchingOnly bool
+	gapLimit     uint32
 	birthday     time.Time
 	locked       bool
 	closed       bool
@@ -389,6 +449,44 @@ type Manager struct {
 	hashedPrivPassphrase [sha512.Size]byte
 }
 
+// SetGapLimit sets the number of consecutive unused addresses past the last
+// used one of each account branch that are expected to be derived and watched,
+// as reported by AccountDerivationState. A limit of zero resets it to the
+// default of DefaultGapLimit.
+func (m *Manager) SetGapLimit(limit uint32) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.gapLimit = limit
+}
+
+// GapLimit returns the number of consecutive unused addresses past the last
+// used one of each account branch that are expected to be derived and watched.
+func (m *Manager) GapLimit() uint32 {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	if m.gapLimit == 0 {
+		return DefaultGapLimit
+	}
+	return m.gapLimit
+}
+
+// AccountDerivationState returns the derivation state of the account of the
+// given key scope, reporting the next index to be derived and the last used
+// one of both of its branches, along with the gap limit in effect. As it only
+// relies on public data, it also works for watch-only accounts.
+func (m *Manager) AccountDerivationState(ns walletdb.ReadBucket,
+	scope KeyScope, account uint32) (*DerivationState, error) {
+
+	scopedMgr, err := m.FetchScopedKeyManager(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return scopedMgr.DerivationState(ns, account)
+}
+
 // WatchOnly returns true if the root manager is in watch only mode, and false
 // otherwise.
 func (m *Manager) WatchOnly() bool {
@@ -429,6 +527,58 @@ func (m *Manager) IsWatchOnlyAccount(ns walletdb.ReadBucket, keyScope KeyScope,
 	return scopedMgr.IsWatchOnlyAccount(ns, account)
 }
 
+// SetAccountDisabled disables or re-enables the account with the given key
+// scope. A disabled account is kept along with its addresses, but no longer
+// issues new external addresses, and its outputs are excluded from default
+// coin selection. Change addresses are still derived for it, such that its
+// outputs can be spent through explicit coin control or sweeps.
+func (m *Manager) SetAccountDisabled(ns walletdb.ReadWriteBucket,
+	keyScope KeyScope, account uint32, disabled bool) error {
+
+	scopedMgr, err := m.FetchScopedKeyManager(keyScope)
+	if err != nil {
+		return err
+	}
+	return scopedMgr.SetAccountDisabled(ns, account, disabled)
+}
+
+// VerifyAccountAddresses re-derives each address stored for the account with
+// the given key scope from the expected account extended public key, and
+// returns the branches and indexes of the stored addresses that don't match
+// the derived ones. This allows detecting corrupted address entries or skewed
+// indexes, e.g. after a restore. Only public derivation is used, so watch-only
+// accounts can be verified as well.
+func (m *Manager) VerifyAccountAddresses(ns walletdb.ReadBucket,
+	keyScope KeyScope, account uint32,
+	expectedXpub *hdkeychain.ExtendedKey) ([]MismatchedAddress, error) {
+
+	scopedMgr, err := m.FetchScopedKeyManager(keyScope)
+	if err != nil {
+		return nil, err
+	}
+	return scopedMgr.VerifyAccountAddresses(ns, account, expectedXpub)
+}
+
+// RestoreAccountAddresses re-derives the addresses of the account with the
+// given key scope from the expected account extended public key, and restores
+// those missing from the manager up to the last one whose address ID is
+// referenced, such as by the outputs of the wallet's credits. This allows
+// recovering from corrupted address entries, e.g. after a partial write. The
+// missing addresses are returned, without being restored if dryRun is set.
+func (m *Manager) RestoreAccountAddresses(ns walletdb.ReadWriteBucket,
+	keyScope KeyScope, account uint32,
+	expectedXpub *hdkeychain.ExtendedKey, referenced map[string]struct{},
+	dryRun bool) ([]MissingAddress, error) {
+
+	scopedMgr, err := m.FetchScopedKeyManager(keyScope)
+	if err != nil {
+		return nil, err
+	}
+	return scopedMgr.RestoreAccountAddresses(
+		ns, account, expectedXpub, referenced, dryRun,
+	)
+}
+
 // lock performs a best try effort to remove and zero all secret keys associated
 // with the address manager.
 //
@@ -692,6 +842,133 @@ func (m *Manager) NeuterRootKey(ns walletdb.ReadWriteBucket) error {
 	return ns.NestedReadWriteBucket(mainBucketName).Delete(masterHDPrivName)
 }
 
+// DeriveFromPath derives the key at the given BIP0032 path, relative to
+// accountKey, and returns the address of the given type backed by it. This
+// allows deriving addresses at paths not covered by any of the manager's key
+// scopes, such as those used by other wallets. If accountKey is nil, the path
+// is relative to the master root key, which requires the manager to be
+// unlocked and its root key to not have been neutered. Hardened path elements
+// are only supported when deriving from a private key.
+//
+// If bs is not nil, the public key at the path is imported into the
+// watch-only set of the key scope producing the address type, with bs as the
+// block the key was first seen at. Otherwise the address is returned without
+// being stored. In both cases, the address is reported as imported, as it
+// doesn't belong to any of the manager's accounts.
+//
+// Unlike the keys of the manager's accounts, the path is derived as described
+// by BIP0032, so that it matches the keys derived by other wallets.
+func (m *Manager) DeriveFromPath(ns walletdb.ReadWriteBucket,
+	accountKey *hdkeychain.ExtendedKey, path []uint32,
+	addrType AddressType, bs *BlockStamp) (ManagedAddress, error) {
+
+	scopes := m.ScopesForExternalAddrType(addrType)
+	if len(scopes) == 0 {
+		str := fmt.Sprintf("no scope produces addresses of type %v",
+			addrType)
+		return nil, managerError(ErrScopeNotFound, str, nil)
+	}
+	scopedMgr, err := m.FetchScopedKeyManager(scopes[0])
+	if err != nil {
+		return nil, err
+	}
+
+	key := accountKey
+	if key == nil {
+		key, err = m.masterRootPrivKey(ns)
+		if err != nil {
+			return nil, err
+		}
+		defer key.Zero()
+	}
+	for _, index := range path {
+		key, err = key.Derive(index)
+		if err != nil {
+			str := fmt.Sprintf("failed to derive child %d", index)
+			return nil, managerError(ErrKeyChain, str, err)
+		}
+	}
+	pubKey, err := key.ECPubKey()
+	if err != nil {
+		str := "failed to convert derived key to public key"
+		return nil, managerError(ErrKeyChain, str, err)
+	}
+
+	if bs != nil {
+		return scopedMgr.ImportPublicKey(ns, pubKey, bs)
+	}
+
+	scopedMgr.mtx.Lock()
+	defer scopedMgr.mtx.Unlock()
+
+	var managedAddr *managedAddress
+	if key.IsPrivate() && !m.WatchOnly() && !m.IsLocked() {
+		privKey, err := key.ECPrivKey()
+		if err != nil {
+			str := "failed to convert derived key to private key"
+			return nil, managerError(ErrKeyChain, str, err)
+		}
+		managedAddr, err = newManagedAddress(
+			scopedMgr, ImportedDerivationPath, privKey, true,
+			addrType,
+		)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		managedAddr, err = newManagedAddressWithoutPrivKey(
+			scopedMgr, ImportedDerivationPath, pubKey, true,
+			addrType,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	managedAddr.imported = true
+
+	return managedAddr, nil
+}
+
+// masterRootPrivKey returns the decrypted master root private key. The manager
+// must be unlocked, and the root key must not have been neutered.
+func (m *Manager) masterRootPrivKey(
+	ns walletdb.ReadBucket) (*hdkeychain.ExtendedKey, error) {
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	if m.watchingOnly {
+		return nil, managerError(ErrWatchingOnly, errWatchingOnly, nil)
+	}
+	if m.locked {
+		return nil, managerError(ErrLocked, errLocked, nil)
+	}
+
+	masterRootPrivEnc, _ := fetchMasterHDKeys(ns)
+	if masterRootPrivEnc == nil {
+		str := "master root private key has been neutered"
+		return nil, managerError(ErrWatchingOnly, str, nil)
+	}
+
+	serializedMasterRootPriv, err := m.cryptoKeyPriv.Decrypt(
+		masterRootPrivEnc,
+	)
+	if err != nil {
+		str := "failed to decrypt master root serialized private key"
+		return nil, managerError(ErrLocked, str, err)
+	}
+	rootPriv, err := hdkeychain.NewKeyFromString(
+		string(serializedMasterRootPriv),
+	)
+	zero.Bytes(serializedMasterRootPriv)
+	if err != nil {
+		str := "failed to create master extended private key"
+		return nil, managerError(ErrKeyChain, str, err)
+	}
+
+	return rootPriv, nil
+}
+
 // Address returns a managed address given the passed address if it is known to
 // the address manager. A managed address differs from the passed address in
 // that it also potentially contains extra information needed to sign
@@ -1163,6 +1440,47 @@ func (m *Manager) Lock() error {
 	return nil
 }
 
+// CheckPassphrase verifies that the specified passphrase is the one protecting
+// the master private key, without unlocking the address manager or otherwise
+// changing its state. The key derived from the passphrase in the process is
+// zeroed before returning. An invalid passphrase will return an error with the
+// ErrWrongPassphrase code.
+//
+// This function will return an error if invoked on a watching-only address
+// manager.
+func (m *Manager) CheckPassphrase(passphrase []byte) error {
+	// A watching-only address manager has no private passphrase.
+	if m.watchingOnly {
+		return managerError(ErrWatchingOnly, errWatchingOnly, nil)
+	}
+
+	// We'll derive the key into a copy of the master private key, such
+	// that the one used by the manager is left untouched. The manager's
+	// mutex isn't held during the derivation itself, as it's expensive.
+	var masterKeyPriv snacl.SecretKey
+	m.mtx.RLock()
+	params := m.masterKeyPriv.Marshal()
+	m.mtx.RUnlock()
+	if err := masterKeyPriv.Unmarshal(params); err != nil {
+		str := "failed to unmarshal master private key"
+		return managerError(ErrCrypto, str, err)
+	}
+
+	err := masterKeyPriv.DeriveKey(&passphrase)
+	masterKeyPriv.Zero()
+	switch {
+	case err == snacl.ErrInvalidPassword:
+		str := "invalid passphrase for master private key"
+		return managerError(ErrWrongPassphrase, str, nil)
+
+	case err != nil:
+		str := "failed to derive master private key"
+		return managerError(ErrCrypto, str, err)
+	}
+
+	return nil
+}
+
 // Unlock derives the master private key from the specified passphrase.  An
 // invalid passphrase will return an error.  Otherwise, the derived secret key
 // is stored in memory until the address manager is locked.  Any failures that
@@ -1808,6 +2126,25 @@ func Create(ns walletdb.ReadWriteBucket, rootKey *hdkeychain.ExtendedKey,
 	chainParams *chaincfg.Params, config *ScryptOptions,
 	birthday time.Time) error {
 
+	return CreateWithScopes(
+		ns, rootKey, pubPassphrase, privPassphrase, chainParams, config,
+		birthday, DefaultKeyScopes,
+	)
+}
+
+// CreateWithScopes is the same as Create, but only creates the scoped managers
+// for the given key scopes, each with its default account, rather than those
+// of DefaultKeyScopes. The address schema of each scope is determined by
+// ScopeAddrMap, so a ManagerError with an error code of ErrScopeNotFound is
+// returned for any scope not within it.
+//
+// NOTE: The scopes are ignored when creating a watching-only manager, as no
+// scoped managers are created for it.
+func CreateWithScopes(ns walletdb.ReadWriteBucket,
+	rootKey *hdkeychain.ExtendedKey, pubPassphrase, privPassphrase []byte,
+	chainParams *chaincfg.Params, config *ScryptOptions,
+	birthday time.Time, scopes []KeyScope) error {
+
 	// If the seed argument is nil we create in watchingOnly mode.
 	isWatchingOnly := rootKey == nil
 
@@ -1827,7 +2164,15 @@ func Create(ns walletdb.ReadWriteBucket, rootKey *hdkeychain.ExtendedKey,
 	// Perform the initial bucket creation and database namespace setup.
 	defaultScopes := map[KeyScope]ScopeAddrSchema{}
 	if !isWatchingOnly {
-		defaultScopes = ScopeAddrMap
+		for _, scope := range scopes {
+			scopeSchema, ok := ScopeAddrMap[scope]
+			if !ok {
+				str := fmt.Sprintf("no address schema known "+
+					"for scope %v", scope)
+				return managerError(ErrScopeNotFound, str, nil)
+			}
+			defaultScopes[scope] = scopeSchema
+		}
 	}
 	if err := createManagerNS(ns, defaultScopes); err != nil {
 		return maybeConvertDbError(err)
@@ -1931,10 +2276,10 @@ func Create(ns walletdb.ReadWriteBucket, rootKey *hdkeychain.ExtendedKey,
 			return managerError(ErrKeyChain, str, err)
 		}
 
-		// Next, for each registers default manager scope, we'll
-		// create the hardened cointype key for it, as well as the
-		// first default account.
-		for _, defaultScope := range DefaultKeyScopes {
+		// Next, for each registered manager scope, we'll create the
+		// hardened cointype key for it, as well as the first default
+		// account.
+		for defaultScope := range defaultScopes {
 			err := createManagerKeyScope(
 				ns, defaultScope, rootKey, cryptoKeyPub, cryptoKeyPriv,
 			)
@@ -1985,6 +2330,13 @@ func Create(ns walletdb.ReadWriteBucket, rootKey *hdkeychain.ExtendedKey,
 		return maybeConvertDbError(err)
 	}
 
+	// Save the network the address manager is created for, so that it
+	// can't be opened for a different one later on.
+	err = PutNetwork(ns, chainParams.Net)
+	if err != nil {
+		return maybeConvertDbError(err)
+	}
+
 	// Save the initial synced to state.
 	err = PutSyncedTo(ns, &syncInfo.syncedTo)
 	if err != nil {
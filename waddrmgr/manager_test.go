@@ -2859,3 +2859,552 @@ func TestDeriveFromKeyPathCache(t *testing.T) {
 	require.Equal(t, cachedKey.Serialize(), cachedKey2.Serialize())
 	require.Equal(t, derivedKey.Serialize(), cachedKey2.Serialize())
 }
+
+// TestDeriveFromPath ensures that addresses are derived at the exact custom
+// path given, either from the master root key or from an account key, and
+// that they can be imported into the watch-only set.
+func TestDeriveFromPath(t *testing.T) {
+	t.Parallel()
+
+	teardown, db := emptyDB(t)
+	defer teardown()
+
+	// We'll start the test by creating a new root manager that will be
+	// used for the duration of the test.
+	var mgr *Manager
+	err := walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns, err := tx.CreateTopLevelBucket(waddrmgrNamespaceKey)
+		if err != nil {
+			return err
+		}
+		err = Create(
+			ns, rootKey, pubPassphrase, privPassphrase,
+			&chaincfg.MainNetParams, fastScrypt, time.Time{},
+		)
+		if err != nil {
+			return err
+		}
+		mgr, err = Open(ns, pubPassphrase, &chaincfg.MainNetParams)
+		if err != nil {
+			return err
+		}
+
+		return mgr.Unlock(ns, privPassphrase)
+	})
+	require.NoError(t, err, "create/open: unexpected error: %v", err)
+
+	defer mgr.Close()
+
+	// The reference address is the one at m/44'/0'/0'/0/5 derived from the
+	// test seed.
+	const refAddr = "1Q18qK11ZnPtSX7bHF97Uop5PLmDM7qroD"
+	path := []uint32{
+		44 + hdkeychain.HardenedKeyStart,
+		hdkeychain.HardenedKeyStart,
+		hdkeychain.HardenedKeyStart,
+		0,
+		5,
+	}
+
+	deriveFromPath := func(accountKey *hdkeychain.ExtendedKey,
+		path []uint32, bs *BlockStamp) (ManagedAddress, error) {
+
+		var addr ManagedAddress
+		err := walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+			ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+
+			var err error
+			addr, err = mgr.DeriveFromPath(
+				ns, accountKey, path, PubKeyHash, bs,
+			)
+			return err
+		})
+		return addr, err
+	}
+
+	// Deriving from the master root key should result in the reference
+	// address, backed by its private key.
+	addr, err := deriveFromPath(nil, path, nil)
+	require.NoError(t, err, "unable to derive from path: %v", err)
+	require.Equal(t, refAddr, addr.Address().EncodeAddress())
+	require.True(t, addr.Imported())
+
+	privKey, err := addr.(ManagedPubKeyAddress).PrivKey()
+	require.NoError(t, err, "unable to retrieve private key: %v", err)
+	require.Equal(
+		t, addr.(ManagedPubKeyAddress).PubKey().SerializeCompressed(),
+		privKey.PubKey().SerializeCompressed(),
+	)
+
+	// The same address should be derived from the account's public key
+	// with the remaining unhardened path elements, while hardened elements
+	// can't be derived from it.
+	acctKey := rootKey
+	for _, index := range path[:3] {
+		acctKey, err = acctKey.Derive(index)
+		require.NoError(t, err)
+	}
+	acctPubKey, err := acctKey.Neuter()
+	require.NoError(t, err)
+
+	addr, err = deriveFromPath(acctPubKey, path[3:], nil)
+	require.NoError(t, err, "unable to derive from path: %v", err)
+	require.Equal(t, refAddr, addr.Address().EncodeAddress())
+
+	_, err = deriveFromPath(acctPubKey, path[2:], nil)
+	if !IsError(err, ErrKeyChain) {
+		t.Fatalf("expected ErrKeyChain, got %v", err)
+	}
+
+	// Importing the address should make it known to the manager.
+	bs := &BlockStamp{Height: 100}
+	addr, err = deriveFromPath(nil, path, bs)
+	require.NoError(t, err, "unable to import from path: %v", err)
+	require.Equal(t, refAddr, addr.Address().EncodeAddress())
+
+	err = walletdb.View(db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(waddrmgrNamespaceKey)
+		_, err := mgr.Address(ns, addr.Address())
+		return err
+	})
+	require.NoError(t, err, "imported address not found: %v", err)
+
+	// Once locked, the master root key can no longer be derived from.
+	require.NoError(t, mgr.Lock())
+	_, err = deriveFromPath(nil, path, nil)
+	if !IsError(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+}
+
+// TestSetAccountDisabled ensures that a disabled account no longer issues new
+// external addresses, that its disabled state persists across restarts and is
+// reported within its properties, and that it can be re-enabled.
+func TestSetAccountDisabled(t *testing.T) {
+	t.Parallel()
+
+	teardown, db := emptyDB(t)
+	defer teardown()
+
+	var mgr *Manager
+	err := walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns, err := tx.CreateTopLevelBucket(waddrmgrNamespaceKey)
+		if err != nil {
+			return err
+		}
+		err = Create(
+			ns, rootKey, pubPassphrase, privPassphrase,
+			&chaincfg.MainNetParams, fastScrypt, time.Time{},
+		)
+		if err != nil {
+			return err
+		}
+		mgr, err = Open(ns, pubPassphrase, &chaincfg.MainNetParams)
+		return err
+	})
+	require.NoError(t, err, "create/open: unexpected error: %v", err)
+
+	defer func() {
+		mgr.Close()
+	}()
+
+	const account = DefaultAccountNum
+	scope := KeyScopeBIP0084
+
+	setDisabled := func(account uint32, disabled bool) error {
+		return walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+			ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+			return mgr.SetAccountDisabled(
+				ns, scope, account, disabled,
+			)
+		})
+	}
+
+	// checkDisabled ensures the account reports the expected disabled
+	// state, and that it only issues external addresses if enabled.
+	checkDisabled := func(disabled bool) {
+		t.Helper()
+
+		scopedMgr, err := mgr.FetchScopedKeyManager(scope)
+		require.NoError(t, err)
+
+		err = walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+			ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+
+			props, err := scopedMgr.AccountProperties(ns, account)
+			require.NoError(t, err)
+			require.Equal(t, disabled, props.Disabled)
+
+			_, err = scopedMgr.NextExternalAddresses(ns, account, 1)
+			if disabled {
+				require.True(t, IsError(err, ErrAccountDisabled))
+			} else {
+				require.NoError(t, err)
+			}
+
+			// Change addresses are still derived regardless.
+			_, err = scopedMgr.NextInternalAddresses(ns, account, 1)
+			require.NoError(t, err)
+
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	checkDisabled(false)
+
+	// An unknown account can't be disabled.
+	err = setDisabled(account+1, true)
+	require.True(t, IsError(err, ErrAccountNotFound))
+
+	require.NoError(t, setDisabled(account, true))
+	checkDisabled(true)
+
+	// The disabled state should persist once the manager is reopened.
+	mgr.Close()
+	err = walletdb.View(db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(waddrmgrNamespaceKey)
+		mgr, err = Open(ns, pubPassphrase, &chaincfg.MainNetParams)
+		return err
+	})
+	require.NoError(t, err)
+	checkDisabled(true)
+
+	require.NoError(t, setDisabled(account, false))
+	checkDisabled(false)
+}
+
+// TestReplaceImportedPublicKey ensures that replacing the key of an imported
+// address keeps the time it was added at and its sync status.
+func TestReplaceImportedPublicKey(t *testing.T) {
+	t.Parallel()
+
+	teardown, db := emptyDB(t)
+	defer teardown()
+
+	var mgr *Manager
+	err := walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns, err := tx.CreateTopLevelBucket(waddrmgrNamespaceKey)
+		if err != nil {
+			return err
+		}
+		err = Create(
+			ns, rootKey, pubPassphrase, privPassphrase,
+			&chaincfg.MainNetParams, fastScrypt, time.Time{},
+		)
+		if err != nil {
+			return err
+		}
+		mgr, err = Open(ns, pubPassphrase, &chaincfg.MainNetParams)
+		return err
+	})
+	require.NoError(t, err, "create/open: unexpected error: %v", err)
+
+	defer func() {
+		mgr.Close()
+	}()
+
+	scope := KeyScopeBIP0084
+	scopedMgr, err := mgr.FetchScopedKeyManager(scope)
+	require.NoError(t, err)
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	pubKey := privKey.PubKey()
+	addressID := btcutil.Hash160(pubKey.SerializeCompressed())
+	addTime := time.Unix(1000, 0)
+
+	err = walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+
+		_, err := scopedMgr.ImportPublicKey(ns, pubKey, nil)
+		require.NoError(t, err)
+
+		// The address is marked as added earlier and fully synced.
+		row, err := scopedMgr.importedAddressRow(
+			ns, addressID, pubKey.SerializeCompressed(),
+		)
+		require.NoError(t, err)
+		err = putImportedAddress(
+			ns, &scope, addressID, ImportedAddrAccount, ssFull,
+			addTime, row.encryptedPubKey, row.encryptedPrivKey,
+		)
+		require.NoError(t, err)
+
+		// A duplicate import fails, while replacing its key keeps
+		// both.
+		_, err = scopedMgr.ImportPublicKey(ns, pubKey, nil)
+		require.True(t, IsError(err, ErrDuplicateAddress))
+		_, err = scopedMgr.ReplaceImportedPublicKey(ns, pubKey)
+		require.NoError(t, err)
+
+		row, err = scopedMgr.importedAddressRow(
+			ns, addressID, pubKey.SerializeCompressed(),
+		)
+		require.NoError(t, err)
+		require.Equal(t, uint64(addTime.Unix()), row.addTime)
+		require.Equal(t, ssFull, row.syncStatus)
+
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// TestVerifyAccountAddresses ensures that the addresses stored for a
+// watch-only account are verified against the expected account key, with
+// corrupted entries reported by their index.
+func TestVerifyAccountAddresses(t *testing.T) {
+	t.Parallel()
+
+	teardown, db := emptyDB(t)
+	defer teardown()
+
+	var mgr *Manager
+	err := walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns, err := tx.CreateTopLevelBucket(waddrmgrNamespaceKey)
+		if err != nil {
+			return err
+		}
+		err = Create(
+			ns, nil, pubPassphrase, nil,
+			&chaincfg.MainNetParams, fastScrypt, time.Time{},
+		)
+		if err != nil {
+			return err
+		}
+		mgr, err = Open(ns, pubPassphrase, &chaincfg.MainNetParams)
+		if err != nil {
+			return err
+		}
+
+		_, err = mgr.NewScopedKeyManager(
+			ns, KeyScopeBIP0044, ScopeAddrMap[KeyScopeBIP0044],
+		)
+		return err
+	})
+	require.NoError(t, err, "create/open: unexpected error: %v", err)
+	defer mgr.Close()
+
+	const account = 1000
+	scope := KeyScopeBIP0044
+	scopedMgr, err := mgr.FetchScopedKeyManager(scope)
+	require.NoError(t, err)
+
+	accountKey := deriveTestAccountKey(t)
+	require.NotNil(t, accountKey)
+	accountXpub, err := accountKey.Neuter()
+	require.NoError(t, err)
+
+	err = walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		err := scopedMgr.NewRawAccountWatchingOnly(
+			ns, account, accountXpub, 0, nil,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = scopedMgr.NextExternalAddresses(ns, account, 5)
+		if err != nil {
+			return err
+		}
+		_, err = scopedMgr.NextInternalAddresses(ns, account, 3)
+		return err
+	})
+	require.NoError(t, err)
+
+	verify := func(key *hdkeychain.ExtendedKey) []MismatchedAddress {
+		t.Helper()
+
+		var mismatches []MismatchedAddress
+		err := walletdb.View(db, func(tx walletdb.ReadTx) error {
+			ns := tx.ReadBucket(waddrmgrNamespaceKey)
+			var err error
+			mismatches, err = mgr.VerifyAccountAddresses(
+				ns, scope, account, key,
+			)
+			return err
+		})
+		require.NoError(t, err)
+		return mismatches
+	}
+
+	// All of the stored addresses derive from the account key.
+	require.Empty(t, verify(accountXpub))
+
+	// None of them derive from the key of another account.
+	otherKey, err := accountKey.Derive(1 + hdkeychain.HardenedKeyStart)
+	require.NoError(t, err)
+	otherXpub, err := otherKey.Neuter()
+	require.NoError(t, err)
+	var otherMismatches []MismatchedAddress
+	for i := uint32(0); i < 5; i++ {
+		otherMismatches = append(otherMismatches, MismatchedAddress{
+			Branch: ExternalBranch,
+			Index:  i,
+		})
+	}
+	for i := uint32(0); i < 3; i++ {
+		otherMismatches = append(otherMismatches, MismatchedAddress{
+			Branch: InternalBranch,
+			Index:  i,
+		})
+	}
+	require.Equal(t, otherMismatches, verify(otherXpub))
+
+	// Store an address that doesn't derive from the account key at the
+	// next external index, which should then be reported.
+	err = walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		return putChainedAddress(
+			ns, &scope, bytes.Repeat([]byte{0x01}, 20), account,
+			ssFull, ExternalBranch, 5, adtChain,
+		)
+	})
+	require.NoError(t, err)
+	require.Equal(t, []MismatchedAddress{{
+		Branch: ExternalBranch,
+		Index:  5,
+	}}, verify(accountXpub))
+
+	// Unknown accounts can't be verified.
+	err = walletdb.View(db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(waddrmgrNamespaceKey)
+		_, err := mgr.VerifyAccountAddresses(
+			ns, scope, account+1, accountXpub,
+		)
+		return err
+	})
+	require.True(t, IsError(err, ErrAccountNotFound))
+}
+
+// TestAccountDerivationState ensures that the derivation state of an account
+// reports the next indexes of its branches as addresses are derived, the last
+// used ones as they're used, and the gap limit in effect, including once the
+// manager is watch-only.
+func TestAccountDerivationState(t *testing.T) {
+	t.Parallel()
+
+	teardown, db := emptyDB(t)
+	defer teardown()
+
+	var mgr *Manager
+	err := walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns, err := tx.CreateTopLevelBucket(waddrmgrNamespaceKey)
+		if err != nil {
+			return err
+		}
+		err = Create(
+			ns, rootKey, pubPassphrase, privPassphrase,
+			&chaincfg.MainNetParams, fastScrypt, time.Time{},
+		)
+		if err != nil {
+			return err
+		}
+		mgr, err = Open(ns, pubPassphrase, &chaincfg.MainNetParams)
+		return err
+	})
+	require.NoError(t, err, "create/open: unexpected error: %v", err)
+	defer mgr.Close()
+
+	const account = DefaultAccountNum
+	scope := KeyScopeBIP0084
+	scopedMgr, err := mgr.FetchScopedKeyManager(scope)
+	require.NoError(t, err)
+
+	// checkState ensures the account reports the expected derivation
+	// state.
+	checkState := func(expected DerivationState) {
+		t.Helper()
+
+		err := walletdb.View(db, func(tx walletdb.ReadTx) error {
+			ns := tx.ReadBucket(waddrmgrNamespaceKey)
+			state, err := mgr.AccountDerivationState(
+				ns, scope, account,
+			)
+			require.NoError(t, err)
+			require.Equal(t, expected, *state)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	expected := DerivationState{
+		KeyScope: scope,
+		Account:  account,
+		GapLimit: DefaultGapLimit,
+	}
+	checkState(expected)
+
+	// The next indexes advance as addresses are derived on each branch.
+	var externalAddrs []ManagedAddress
+	err = walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		externalAddrs, err = scopedMgr.NextExternalAddresses(
+			ns, account, 5,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = scopedMgr.NextInternalAddresses(ns, account, 3)
+		return err
+	})
+	require.NoError(t, err)
+	expected.External.NextIndex = 5
+	expected.Internal.NextIndex = 3
+	checkState(expected)
+
+	// Addresses are considered used whether they're marked used or their
+	// index is recorded as having received a credit.
+	err = walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		err := scopedMgr.MarkUsed(ns, externalAddrs[1].Address())
+		if err != nil {
+			return err
+		}
+		err = scopedMgr.MarkUsed(ns, externalAddrs[3].Address())
+		if err != nil {
+			return err
+		}
+		return scopedMgr.MarkIndexUsed(
+			ns, account, InternalBranch, 1, 100,
+		)
+	})
+	require.NoError(t, err)
+	expected.External.LastUsedIndex = 3
+	expected.External.HasUsed = true
+	expected.Internal.LastUsedIndex = 1
+	expected.Internal.HasUsed = true
+	checkState(expected)
+
+	err = walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		_, err := scopedMgr.NextExternalAddresses(ns, account, 2)
+		return err
+	})
+	require.NoError(t, err)
+	expected.External.NextIndex = 7
+	checkState(expected)
+
+	mgr.SetGapLimit(50)
+	expected.GapLimit = 50
+	checkState(expected)
+
+	// The state is still reported once the manager is watch-only.
+	err = walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		return mgr.ConvertToWatchingOnly(ns)
+	})
+	require.NoError(t, err)
+	checkState(expected)
+
+	// The imported account has no derivation state.
+	err = walletdb.View(db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(waddrmgrNamespaceKey)
+		_, err := mgr.AccountDerivationState(
+			ns, scope, ImportedAddrAccount,
+		)
+		require.True(t, IsError(err, ErrInvalidAccount))
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -35,6 +35,10 @@ var versions = []migration.Version{
 		Number:    8,
 		Migration: storeMaxReorgDepth,
 	},
+	{
+		Number:    9,
+		Migration: populateUsedIndexes,
+	},
 }
 
 // getLatestVersion returns the version number of the latest database version.
@@ -414,3 +418,62 @@ func storeMaxReorgDepth(ns walletdb.ReadWriteBucket) error {
 
 	return nil
 }
+
+// populateUsedIndexes is a migration responsible for populating the used
+// address index bitmaps of each account branch from the addresses already
+// flagged as used. As the heights of their credits are unknown, they're
+// recorded as unmined, such that they're never rolled back.
+func populateUsedIndexes(ns walletdb.ReadWriteBucket) error {
+	var scopes []KeyScope
+	err := forEachKeyScope(ns, func(scope KeyScope) error {
+		scopes = append(scopes, scope)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, scope := range scopes {
+		scope := scope
+
+		scopedBucket, err := fetchReadScopeBucket(ns, &scope)
+		if err != nil {
+			return err
+		}
+		usedAddrs := scopedBucket.NestedReadBucket(usedAddrBucketName)
+
+		// The buckets can't be modified while iterating over the
+		// addresses, so we'll gather the used ones first.
+		var used []*dbChainAddressRow
+		err = scopedBucket.NestedReadBucket(addrBucketName).ForEach(
+			func(k, v []byte) error {
+				if v == nil || usedAddrs.Get(k) == nil {
+					return nil
+				}
+
+				row, err := fetchAddressByHash(ns, &scope, k)
+				if err != nil {
+					return err
+				}
+				if chainRow, ok := row.(*dbChainAddressRow); ok {
+					used = append(used, chainRow)
+				}
+				return nil
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range used {
+			err := markIndexUsed(
+				ns, &scope, row.account, row.branch, row.index, -1,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
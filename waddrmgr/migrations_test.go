@@ -450,3 +450,89 @@ func TestMigrationStoreMaxReorgDepth(t *testing.T) {
 		}
 	}
 }
+
+// TestMigrationPopulateUsedIndexes ensures that the populateUsedIndexes
+// migration records the indexes of the addresses already flagged as used
+// within the bitmaps of their account branch.
+func TestMigrationPopulateUsedIndexes(t *testing.T) {
+	t.Parallel()
+
+	scope := KeyScopeBIP0084
+	addrs := []struct {
+		branch uint32
+		index  uint32
+		used   bool
+	}{
+		{branch: ExternalBranch, index: 0, used: true},
+		{branch: ExternalBranch, index: 1, used: false},
+		{branch: ExternalBranch, index: 9, used: true},
+		{branch: InternalBranch, index: 0, used: false},
+		{branch: InternalBranch, index: 2, used: true},
+	}
+
+	beforeMigration := func(ns walletdb.ReadWriteBucket) error {
+		for i, addr := range addrs {
+			addressID := []byte{byte(i)}
+			err := putChainedAddress(
+				ns, &scope, addressID, DefaultAccountNum,
+				ssFull, addr.branch, addr.index, adtChain,
+			)
+			if err != nil {
+				return err
+			}
+			if !addr.used {
+				continue
+			}
+			err = markAddressUsed(ns, &scope, addressID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	afterMigration := func(ns walletdb.ReadWriteBucket) error {
+		for _, addr := range addrs {
+			bitmap, err := fetchUsedIndexes(
+				ns, &scope, DefaultAccountNum, addr.branch,
+			)
+			if err != nil {
+				return err
+			}
+			used := UsedIndexes(bitmap).IsUsed(addr.index)
+			if used != addr.used {
+				return fmt.Errorf("expected index %d of branch "+
+					"%d to have used=%v, got %v", addr.index,
+					addr.branch, addr.used, used)
+			}
+		}
+
+		// The migrated indexes should be recorded as unmined, so that
+		// they're never rolled back.
+		err := rollbackUsedIndexes(
+			ns, &scope, 0,
+			func(uint32, uint32, uint32) (bool, error) {
+				return false, nil
+			},
+		)
+		if err != nil {
+			return err
+		}
+		bitmap, err := fetchUsedIndexes(
+			ns, &scope, DefaultAccountNum, ExternalBranch,
+		)
+		if err != nil {
+			return err
+		}
+		highest, ok := UsedIndexes(bitmap).HighestUsed()
+		if !ok || highest != 9 {
+			return fmt.Errorf("expected highest used index 9, "+
+				"got %d (found=%v)", highest, ok)
+		}
+		return nil
+	}
+
+	applyMigration(
+		t, beforeMigration, afterMigration, populateUsedIndexes, false,
+	)
+}
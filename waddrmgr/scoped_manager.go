@@ -1,9 +1,13 @@
 package waddrmgr
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg"
@@ -295,9 +299,10 @@ func (s *ScopedKeyManager) keyToManaged(derivedKey *hdkeychain.ExtendedKey,
 
 	// Choose the appropriate type of address to derive since it's possible
 	// for a watch-only account to have a different schema from the
-	// manager's.
-	internal := derivationPath.Branch == InternalBranch
-	addrType := s.accountAddrType(acctInfo, internal)
+	// manager's, and for internal addresses to be of another type.
+	addrType := s.addrTypeAt(
+		acctInfo, derivationPath.Branch, derivationPath.Index,
+	)
 
 	// Create a new managed address based on the public or private key
 	// depending on whether the passed key is private.  Also, zero the key
@@ -464,6 +469,13 @@ func (s *ScopedKeyManager) loadAccountInfo(ns walletdb.ReadBucket,
 		return nil, managerError(ErrDatabase, str, nil)
 	}
 
+	acctInfo.changeAddrTypes, err = fetchChangeAddrTypes(
+		ns, &s.scope, account,
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	// Derive and cache the managed address for the last external address.
 	branch, index := ExternalBranch, acctInfo.nextExternalIndex
 	if index > 0 {
@@ -550,6 +562,9 @@ func (s *ScopedKeyManager) AccountProperties(ns walletdb.ReadBucket,
 		props.IsWatchOnly = s.rootManager.WatchOnly() ||
 			acctInfo.acctKeyPriv == nil
 		props.AddrSchema = acctInfo.addrSchema
+		props.ChangeAddrType = s.addrTypeAt(
+			acctInfo, InternalBranch, acctInfo.nextInternalIndex,
+		)
 
 		// Export the account public key with the correct version
 		// corresponding to the manager's key scope for non-watch-only
@@ -590,9 +605,94 @@ func (s *ScopedKeyManager) AccountProperties(ns walletdb.ReadBucket,
 		props.ImportedKeyCount = importedKeyCount
 	}
 
+	disabled, err := isAccountDisabled(ns, &s.scope, account)
+	if err != nil {
+		return nil, err
+	}
+	props.Disabled = disabled
+
 	return props, nil
 }
 
+// DerivationState returns the derivation state of the account, reporting the
+// next index to be derived and the last used one of both of its branches,
+// along with the gap limit in effect. An address is considered used if it was
+// either marked used or recorded as having received a credit through
+// MarkIndexUsed.
+func (s *ScopedKeyManager) DerivationState(ns walletdb.ReadBucket,
+	account uint32) (*DerivationState, error) {
+
+	// The imported account has no addresses to derive.
+	if account == ImportedAddrAccount {
+		str := "imported account has no derivation state"
+		return nil, managerError(ErrInvalidAccount, str, nil)
+	}
+
+	// The gap limit is read before acquiring the scoped manager's lock to
+	// not hold both locks at once.
+	state := &DerivationState{
+		KeyScope: s.scope,
+		Account:  account,
+		GapLimit: s.rootManager.GapLimit(),
+	}
+
+	s.mtx.Lock()
+	acctInfo, err := s.loadAccountInfo(ns, account)
+	s.mtx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	state.External.NextIndex = acctInfo.nextExternalIndex
+	state.Internal.NextIndex = acctInfo.nextInternalIndex
+
+	// markUsed records the index as used on the branch if it's the highest
+	// one found so far.
+	markUsed := func(branch, index uint32) {
+		branchState := &state.External
+		switch branch {
+		case ExternalBranch:
+		case InternalBranch:
+			branchState = &state.Internal
+		default:
+			return
+		}
+
+		if !branchState.HasUsed || index > branchState.LastUsedIndex {
+			branchState.LastUsedIndex = index
+			branchState.HasUsed = true
+		}
+	}
+
+	for _, branch := range []uint32{ExternalBranch, InternalBranch} {
+		usedIndexes, err := s.UsedIndexes(ns, account, branch)
+		if err != nil {
+			return nil, err
+		}
+		if index, ok := usedIndexes.HighestUsed(); ok {
+			markUsed(branch, index)
+		}
+	}
+
+	err = forEachAccountAddressHash(
+		ns, &s.scope, account,
+		func(addrHash []byte, rowInterface interface{}) error {
+			row, ok := rowInterface.(*dbChainAddressRow)
+			if !ok {
+				return nil
+			}
+			if fetchAddressHashUsed(ns, &s.scope, addrHash) {
+				markUsed(row.branch, row.index)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
 // cachedKey is an entry within the LRU map that stores private keys that are
 // to be used frequently. We use this wrapper struct to be able too report the
 // size of a given element to the cache.
@@ -916,6 +1016,33 @@ func (s *ScopedKeyManager) AddrAccount(ns walletdb.ReadBucket,
 	return account, nil
 }
 
+// SetAddressLabel sets the label of the given address, replacing any existing
+// one, or removes it if the label is empty. The address must be known to the
+// manager.
+func (s *ScopedKeyManager) SetAddressLabel(ns walletdb.ReadWriteBucket,
+	address btcutil.Address, label string) error {
+
+	addr, err := s.Address(ns, address)
+	if err != nil {
+		return err
+	}
+
+	addressID := addr.Address().ScriptAddress()
+	return putAddressLabel(ns, &s.scope, addressID, label)
+}
+
+// AddressLabel returns the label of the given address, or an empty string if
+// it has none.
+func (s *ScopedKeyManager) AddressLabel(ns walletdb.ReadBucket,
+	address btcutil.Address) (string, error) {
+
+	if pka, ok := address.(*btcutil.AddressPubKey); ok {
+		address = pka.AddressPubKeyHash()
+	}
+
+	return fetchAddressLabel(ns, &s.scope, address.ScriptAddress())
+}
+
 // accountAddrType determines the type of address that should be generated for
 // an account based on whether it's an internal address or not.
 func (s *ScopedKeyManager) accountAddrType(acctInfo *accountInfo,
@@ -933,12 +1060,34 @@ func (s *ScopedKeyManager) accountAddrType(acctInfo *accountInfo,
 	return addrSchema.ExternalAddrType
 }
 
+// addrTypeAt determines the type of the address of an account at the given
+// branch and index, taking into account the address types set for ranges of
+// its internal addresses.
+func (s *ScopedKeyManager) addrTypeAt(acctInfo *accountInfo, branch,
+	index uint32) AddressType {
+
+	if branch != InternalBranch {
+		return s.accountAddrType(acctInfo, false)
+	}
+
+	addrType := s.accountAddrType(acctInfo, true)
+	for _, r := range acctInfo.changeAddrTypes {
+		if r.index > index {
+			break
+		}
+		addrType = r.addrType
+	}
+	return addrType
+}
+
 // nextAddresses returns the specified number of next chained address from the
-// branch indicated by the internal flag.
+// branch indicated by the internal flag. The addresses are of the given type,
+// if any, or of the one of the account otherwise.
 //
 // This function MUST be called with the manager lock held for writes.
 func (s *ScopedKeyManager) nextAddresses(ns walletdb.ReadWriteBucket,
-	account uint32, numAddresses uint32, internal bool) ([]ManagedAddress, error) {
+	account uint32, numAddresses uint32, internal bool,
+	addrType *AddressType) ([]ManagedAddress, error) {
 
 	// The next address can only be generated for accounts that have
 	// already been created.
@@ -963,11 +1112,6 @@ func (s *ScopedKeyManager) nextAddresses(ns walletdb.ReadWriteBucket,
 		nextIndex = acctInfo.nextInternalIndex
 	}
 
-	// Choose the appropriate type of address to derive since it's possible
-	// for a watch-only account to have a different schema from the
-	// manager's.
-	addrType := s.accountAddrType(acctInfo, internal)
-
 	// Ensure the requested number of addresses doesn't exceed the maximum
 	// allowed for this account.
 	if numAddresses > MaxAddressesPerAccount || nextIndex+numAddresses >
@@ -1026,12 +1170,21 @@ func (s *ScopedKeyManager) nextAddresses(ns walletdb.ReadWriteBucket,
 			Index:           nextIndex - 1,
 		}
 
+		// Choose the appropriate type of address to derive since it's
+		// possible for a watch-only account to have a different schema
+		// from the manager's, and for internal addresses to be of
+		// another type.
+		derivedType := s.addrTypeAt(acctInfo, branchNum, nextIndex-1)
+		if addrType != nil {
+			derivedType = *addrType
+		}
+
 		// Create a new managed address based on the public or private
 		// key depending on whether the generated key is private.
 		// Also, zero the next key after creating the managed address
 		// from it.
 		addr, err := newManagedAddressFromExtKey(
-			s, derivationPath, nextKey, addrType,
+			s, derivationPath, nextKey, derivedType,
 		)
 		if err != nil {
 			return nil, err
@@ -1160,11 +1313,6 @@ func (s *ScopedKeyManager) extendAddresses(ns walletdb.ReadWriteBucket,
 		nextIndex = acctInfo.nextInternalIndex
 	}
 
-	// Choose the appropriate type of address to derive since it's possible
-	// for a watch-only account to have a different schema from the
-	// manager's.
-	addrType := s.accountAddrType(acctInfo, internal)
-
 	// If the last index requested is already lower than the next index, we
 	// can return early.
 	if lastIndex < nextIndex {
@@ -1235,7 +1383,8 @@ func (s *ScopedKeyManager) extendAddresses(ns walletdb.ReadWriteBucket,
 		// Also, zero the next key after creating the managed address
 		// from it.
 		addr, err := newManagedAddressFromExtKey(
-			s, derivationPath, nextKey, addrType,
+			s, derivationPath, nextKey,
+			s.addrTypeAt(acctInfo, branchNum, nextIndex-1),
 		)
 		if err != nil {
 			return err
@@ -1329,7 +1478,17 @@ func (s *ScopedKeyManager) NextExternalAddresses(ns walletdb.ReadWriteBucket,
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
-	return s.nextAddresses(ns, account, numAddresses, false)
+	// Disabled accounts no longer issue new external addresses.
+	disabled, err := isAccountDisabled(ns, &s.scope, account)
+	if err != nil {
+		return nil, err
+	}
+	if disabled {
+		str := fmt.Sprintf("account %d is disabled", account)
+		return nil, managerError(ErrAccountDisabled, str, nil)
+	}
+
+	return s.nextAddresses(ns, account, numAddresses, false, nil)
 }
 
 // NextInternalAddresses returns the specified number of next chained addresses
@@ -1346,7 +1505,144 @@ func (s *ScopedKeyManager) NextInternalAddresses(ns walletdb.ReadWriteBucket,
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
-	return s.nextAddresses(ns, account, numAddresses, true)
+	return s.nextAddresses(ns, account, numAddresses, true, nil)
+}
+
+// NextInternalAddressesOfType returns the specified number of next chained
+// internal addresses of the account, like NextInternalAddresses, but of the
+// given address type rather than the account's. The following internal
+// addresses remain of the account's type.
+func (s *ScopedKeyManager) NextInternalAddressesOfType(
+	ns walletdb.ReadWriteBucket, account uint32, numAddresses uint32,
+	addrType AddressType) ([]ManagedAddress, error) {
+
+	// Enforce maximum account number.
+	if account > MaxAccountNum {
+		err := managerError(ErrAccountNumTooHigh, errAcctTooHigh, nil)
+		return nil, err
+	}
+	if err := checkChangeAddrType(addrType); err != nil {
+		return nil, err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	acctInfo, err := s.loadAccountInfo(ns, account)
+	if err != nil {
+		return nil, err
+	}
+	nextIndex := acctInfo.nextInternalIndex
+	acctType := s.addrTypeAt(acctInfo, InternalBranch, nextIndex)
+	if addrType == acctType || numAddresses == 0 {
+		return s.nextAddresses(ns, account, numAddresses, true, nil)
+	}
+
+	addrs, err := s.nextAddresses(
+		ns, account, numAddresses, true, &addrType,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The type of the derived addresses is stored, such that they're
+	// derived again as such, followed by the account's type for the next
+	// ones.
+	lastIndex := addrs[len(addrs)-1].(*managedAddress).derivationPath.Index
+	err = s.putChangeAddrTypes(
+		ns, account, acctInfo,
+		changeAddrTypeRange{index: nextIndex, addrType: addrType},
+		changeAddrTypeRange{index: lastIndex + 1, addrType: acctType},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return addrs, nil
+}
+
+// SetAccountChangeAddrType sets the type of the internal addresses derived for
+// the given account belonging to this scoped manager from now on, such as the
+// change addresses of the transactions it funds, overriding the one of its
+// address schema. The internal addresses already derived remain of their type.
+func (s *ScopedKeyManager) SetAccountChangeAddrType(
+	ns walletdb.ReadWriteBucket, account uint32,
+	addrType AddressType) error {
+
+	if err := checkChangeAddrType(addrType); err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	acctInfo, err := s.loadAccountInfo(ns, account)
+	if err != nil {
+		return err
+	}
+
+	return s.putChangeAddrTypes(ns, account, acctInfo, changeAddrTypeRange{
+		index:    acctInfo.nextInternalIndex,
+		addrType: addrType,
+	})
+}
+
+// checkChangeAddrType ensures internal addresses of the given type can be
+// derived for an account.
+func checkChangeAddrType(addrType AddressType) error {
+	switch addrType {
+	case PubKeyHash, NestedWitnessPubKey, WitnessPubKey:
+		return nil
+
+	default:
+		str := fmt.Sprintf("unable to derive internal addresses of "+
+			"type %d", addrType)
+		return managerError(ErrInvalidAddrType, str, nil)
+	}
+}
+
+// putChangeAddrTypes stores the address types of the internal addresses of the
+// account from the indexes of the given ranges onwards, and sets them within
+// the account's cached information once the database transaction commits.
+//
+// This function MUST be called with the manager lock held for writes.
+func (s *ScopedKeyManager) putChangeAddrTypes(ns walletdb.ReadWriteBucket,
+	account uint32, acctInfo *accountInfo,
+	ranges ...changeAddrTypeRange) error {
+
+	changeAddrTypes := append(
+		[]changeAddrTypeRange(nil), acctInfo.changeAddrTypes...,
+	)
+	for _, r := range ranges {
+		err := putChangeAddrType(ns, &s.scope, account, r)
+		if err != nil {
+			return err
+		}
+
+		// Any range stored at the same index is replaced, and the
+		// ranges are kept ordered by index.
+		i := sort.Search(len(changeAddrTypes), func(i int) bool {
+			return changeAddrTypes[i].index >= r.index
+		})
+		if i < len(changeAddrTypes) &&
+			changeAddrTypes[i].index == r.index {
+
+			changeAddrTypes[i] = r
+			continue
+		}
+		changeAddrTypes = append(changeAddrTypes, changeAddrTypeRange{})
+		copy(changeAddrTypes[i+1:], changeAddrTypes[i:])
+		changeAddrTypes[i] = r
+	}
+
+	ns.Tx().OnCommit(func() {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+
+		acctInfo.changeAddrTypes = changeAddrTypes
+	})
+
+	return nil
 }
 
 // ExtendExternalAddresses ensures that all valid external keys through
@@ -1816,6 +2112,31 @@ func (s *ScopedKeyManager) RenameAccount(ns walletdb.ReadWriteBucket,
 func (s *ScopedKeyManager) ImportPrivateKey(ns walletdb.ReadWriteBucket,
 	wif *btcutil.WIF, bs *BlockStamp) (ManagedPubKeyAddress, error) {
 
+	return s.importPrivateKey(ns, wif, bs, false)
+}
+
+// ReplaceImportedPrivateKey replaces the key of an address already imported
+// into the address manager with the given private key, e.g. to make an address
+// imported through its public key spendable. The time the address was added,
+// its sync status and the manager's start block are left untouched, as any
+// rescan for the address has already been accounted for.
+//
+// This function will return an error if the address manager is locked and not
+// watching-only, or if the address isn't known. If the address is known but
+// wasn't imported, ErrDuplicateAddress is returned.
+func (s *ScopedKeyManager) ReplaceImportedPrivateKey(
+	ns walletdb.ReadWriteBucket, wif *btcutil.WIF) (ManagedPubKeyAddress,
+	error) {
+
+	return s.importPrivateKey(ns, wif, nil, true)
+}
+
+// importPrivateKey imports a WIF private key into the address manager, or
+// replaces the key of the already imported address if requested.
+func (s *ScopedKeyManager) importPrivateKey(ns walletdb.ReadWriteBucket,
+	wif *btcutil.WIF, bs *BlockStamp, replace bool) (ManagedPubKeyAddress,
+	error) {
+
 	// Ensure the address is intended for network the address manager is
 	// associated with.
 	if !wif.IsForNet(s.rootManager.chainParams) {
@@ -1849,7 +2170,7 @@ func (s *ScopedKeyManager) ImportPrivateKey(ns walletdb.ReadWriteBucket,
 
 	err := s.importPublicKey(
 		ns, wif.SerializePubKey(), encryptedPrivKey,
-		s.addrSchema.ExternalAddrType, bs,
+		s.addrSchema.ExternalAddrType, bs, replace,
 	)
 	if err != nil {
 		return nil, err
@@ -1876,6 +2197,7 @@ func (s *ScopedKeyManager) ImportPublicKey(ns walletdb.ReadWriteBucket,
 	serializedPubKey := pubKey.SerializeCompressed()
 	err := s.importPublicKey(
 		ns, serializedPubKey, nil, s.addrSchema.ExternalAddrType, bs,
+		false,
 	)
 	if err != nil {
 		return nil, err
@@ -1884,12 +2206,45 @@ func (s *ScopedKeyManager) ImportPublicKey(ns walletdb.ReadWriteBucket,
 	return s.toImportedPublicManagedAddress(pubKey, true)
 }
 
+// ReplaceImportedPublicKey replaces the key of an address already imported
+// into the address manager with the given public key. The private key of the
+// address, if any, is kept. The time the address was added, its sync status
+// and the manager's start block are left untouched, as any rescan for the
+// address has already been accounted for.
+//
+// This function will return an error if the address isn't known. If the
+// address is known but wasn't imported, ErrDuplicateAddress is returned.
+func (s *ScopedKeyManager) ReplaceImportedPublicKey(ns walletdb.ReadWriteBucket,
+	pubKey *btcec.PublicKey) (ManagedAddress, error) {
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	serializedPubKey := pubKey.SerializeCompressed()
+	err := s.importPublicKey(
+		ns, serializedPubKey, nil, s.addrSchema.ExternalAddrType, nil,
+		true,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The address is loaded from the database rather than created from
+	// the public key, as it may have kept its private key.
+	managedAddr, err := s.toImportedPublicManagedAddress(pubKey, true)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadAndCacheAddress(ns, managedAddr.Address())
+}
+
 // importPublicKey imports a public key into the address manager and updates the
 // wallet's start block if necessary. An error is returned if the public key
-// already exists.
+// already exists, unless the key of the already imported address is to be
+// replaced.
 func (s *ScopedKeyManager) importPublicKey(ns walletdb.ReadWriteBucket,
 	serializedPubKey, encryptedPrivKey []byte, addrType AddressType,
-	bs *BlockStamp) error {
+	bs *BlockStamp, replace bool) error {
 
 	// Compute the addressID for our key based on its address type.
 	var addressID []byte
@@ -1912,16 +2267,38 @@ func (s *ScopedKeyManager) importPublicKey(ns walletdb.ReadWriteBucket,
 		addressID = btcutil.Hash160(witnessScript)
 
 	default:
-		return fmt.Errorf("unsupported address type %v", addrType)
+		return fmt.Errorf("unsupported address type %d", addrType)
 	}
 
-	// Prevent duplicates.
+	// Prevent duplicates, unless the key of an imported address is being
+	// replaced, in which case its add time and sync status are kept.
 	alreadyExists := s.existsAddress(ns, addressID)
-	if alreadyExists {
+	if alreadyExists && !replace {
 		str := fmt.Sprintf("address for public key %x already exists",
 			serializedPubKey)
 		return managerError(ErrDuplicateAddress, str, nil)
 	}
+	status := ssNone
+	addTime := time.Now()
+	if replace {
+		row, err := s.importedAddressRow(
+			ns, addressID, serializedPubKey,
+		)
+		if err != nil {
+			return err
+		}
+		status = row.syncStatus
+		addTime = time.Unix(int64(row.addTime), 0)
+
+		// Replacing a key with its public key alone mustn't drop the
+		// private key of the address.
+		if encryptedPrivKey == nil {
+			encryptedPrivKey = row.encryptedPrivKey
+		}
+
+		// The cached address may not reflect the replaced key.
+		delete(s.addrs, addrKey(addressID))
+	}
 
 	// Encrypt public key.
 	encryptedPubKey, err := s.rootManager.cryptoKeyPub.Encrypt(
@@ -1943,7 +2320,7 @@ func (s *ScopedKeyManager) importPublicKey(ns walletdb.ReadWriteBucket,
 	// Save the new imported address to the db and update start block (if
 	// needed) in a single transaction.
 	err = putImportedAddress(
-		ns, &s.scope, addressID, ImportedAddrAccount, ssNone,
+		ns, &s.scope, addressID, ImportedAddrAccount, status, addTime,
 		encryptedPubKey, encryptedPrivKey,
 	)
 	if err != nil {
@@ -1968,6 +2345,28 @@ func (s *ScopedKeyManager) importPublicKey(ns walletdb.ReadWriteBucket,
 	return nil
 }
 
+// importedAddressRow returns the database row of the imported address for the
+// public key, which must be known to the address manager.
+//
+// This function MUST be called with the manager lock held for reads.
+func (s *ScopedKeyManager) importedAddressRow(ns walletdb.ReadBucket,
+	addressID, serializedPubKey []byte) (*dbImportedAddressRow, error) {
+
+	rowInterface, err := fetchAddress(ns, &s.scope, addressID)
+	if err != nil {
+		return nil, maybeConvertDbError(err)
+	}
+
+	row, ok := rowInterface.(*dbImportedAddressRow)
+	if !ok || row.account != ImportedAddrAccount {
+		str := fmt.Sprintf("address for public key %x already exists "+
+			"and is not imported", serializedPubKey)
+		return nil, managerError(ErrDuplicateAddress, str, nil)
+	}
+
+	return row, nil
+}
+
 // toImportedPrivateManagedAddress converts an imported private key to an
 // imported managed address.
 func (s *ScopedKeyManager) toImportedPrivateManagedAddress(
@@ -2165,6 +2564,74 @@ func (s *ScopedKeyManager) MarkUsed(ns walletdb.ReadWriteBucket,
 	return nil
 }
 
+// UsedIndexes is a bitmap of the address indexes of an account branch that
+// have received a credit, with index i being represented by bit i%8 of byte
+// i/8.
+type UsedIndexes []byte
+
+// IsUsed returns whether the address at the given index has received a credit.
+func (u UsedIndexes) IsUsed(index uint32) bool {
+	byteIndex := index / 8
+	if uint32(len(u)) <= byteIndex {
+		return false
+	}
+	return u[byteIndex]&(byte(1)<<(index%8)) != 0
+}
+
+// HighestUsed returns the highest address index that has received a credit,
+// if any.
+func (u UsedIndexes) HighestUsed() (uint32, bool) {
+	for i := len(u) - 1; i >= 0; i-- {
+		for bit := 7; bit >= 0; bit-- {
+			if u[i]&(byte(1)<<uint(bit)) != 0 {
+				return uint32(i*8 + bit), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// MarkIndexUsed records the address at the given index of the account branch
+// as having received a credit confirmed at the given height, or an unmined
+// credit if the height is -1. The resulting bitmap allows a resuming client to
+// skip deriving and watching the unused addresses of the branch.
+func (s *ScopedKeyManager) MarkIndexUsed(ns walletdb.ReadWriteBucket, account,
+	branch, index uint32, height int32) error {
+
+	err := markIndexUsed(ns, &s.scope, account, branch, index, height)
+	if err != nil {
+		return maybeConvertDbError(err)
+	}
+	return nil
+}
+
+// UsedIndexes returns the bitmap of the address indexes of the account branch
+// that have received a credit, as recorded by MarkIndexUsed.
+func (s *ScopedKeyManager) UsedIndexes(ns walletdb.ReadBucket, account,
+	branch uint32) (UsedIndexes, error) {
+
+	bitmap, err := fetchUsedIndexes(ns, &s.scope, account, branch)
+	if err != nil {
+		return nil, maybeConvertDbError(err)
+	}
+	return UsedIndexes(bitmap), nil
+}
+
+// RollbackUsedIndexes handles the removal of all blocks at the given height
+// onwards by revisiting each used address index whose lowest recorded credit
+// is within them. The index remains used, by an unmined credit, if stillUsed
+// returns true for it. Otherwise, it's no longer marked used.
+func (s *ScopedKeyManager) RollbackUsedIndexes(ns walletdb.ReadWriteBucket,
+	height int32,
+	stillUsed func(account, branch, index uint32) (bool, error)) error {
+
+	err := rollbackUsedIndexes(ns, &s.scope, height, stillUsed)
+	if err != nil {
+		return maybeConvertDbError(err)
+	}
+	return nil
+}
+
 // ChainParams returns the chain parameters for this address manager.
 func (s *ScopedKeyManager) ChainParams() *chaincfg.Params {
 	// NOTE: No need for mutex here since the net field does not change
@@ -2293,6 +2760,299 @@ func (s *ScopedKeyManager) IsWatchOnlyAccount(ns walletdb.ReadBucket,
 	return acctInfo.acctKeyPriv == nil, nil
 }
 
+// SetAccountDisabled disables or re-enables the given account belonging to
+// this scoped manager. A disabled account no longer issues new external
+// addresses.
+func (s *ScopedKeyManager) SetAccountDisabled(ns walletdb.ReadWriteBucket,
+	account uint32, disabled bool) error {
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// The account must exist, with the imported account always existing.
+	if account != ImportedAddrAccount {
+		if _, err := s.loadAccountInfo(ns, account); err != nil {
+			return err
+		}
+	}
+
+	return putAccountDisabled(ns, &s.scope, account, disabled)
+}
+
+// IsAccountDisabled determines if the given account belonging to this scoped
+// manager has been disabled.
+func (s *ScopedKeyManager) IsAccountDisabled(ns walletdb.ReadBucket,
+	account uint32) (bool, error) {
+
+	return isAccountDisabled(ns, &s.scope, account)
+}
+
+// MismatchedAddress identifies a chained address of an account found by
+// VerifyAccountAddresses not to derive from the expected account key.
+type MismatchedAddress struct {
+	// Branch is the branch of the account the address is stored on.
+	Branch uint32
+
+	// Index is the index of the address within its branch.
+	Index uint32
+}
+
+// VerifyAccountAddresses re-derives each chained address of the given account
+// stored in this scoped manager from the expected account extended key, and
+// returns the branches and indexes of the stored addresses that don't match
+// the derived ones, sorted by branch and index. Only public derivation is
+// used, so this works for watch-only accounts, and a private extended key is
+// neutered before use.
+func (s *ScopedKeyManager) VerifyAccountAddresses(ns walletdb.ReadBucket,
+	account uint32, expectedXpub *hdkeychain.ExtendedKey) (
+	[]MismatchedAddress, error) {
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	acctInfo, err := s.loadAccountInfo(ns, account)
+	if err != nil {
+		return nil, err
+	}
+
+	acctKey, err := expectedXpub.Neuter()
+	if err != nil {
+		str := "failed to neuter expected account key"
+		return nil, managerError(ErrKeyChain, str, err)
+	}
+
+	branchKeys := make(map[uint32]*hdkeychain.ExtendedKey, 2)
+	for _, branch := range []uint32{ExternalBranch, InternalBranch} {
+		branchKey, err := acctKey.DeriveNonStandard(branch) // nolint:staticcheck
+		if err != nil {
+			str := fmt.Sprintf("failed to derive extended key "+
+				"branch %d", branch)
+			return nil, managerError(ErrKeyChain, str, err)
+		}
+		branchKeys[branch] = branchKey
+	}
+
+	var mismatches []MismatchedAddress
+	verifyAddr := func(addrHash []byte, rowInterface interface{}) error {
+		row, ok := rowInterface.(*dbChainAddressRow)
+		if !ok {
+			return nil
+		}
+		mismatch := MismatchedAddress{
+			Branch: row.branch,
+			Index:  row.index,
+		}
+
+		branchKey, ok := branchKeys[row.branch]
+		if !ok {
+			mismatches = append(mismatches, mismatch)
+			return nil
+		}
+		addrKey, err := branchKey.DeriveNonStandard(row.index) // nolint:staticcheck
+		if err != nil {
+			str := fmt.Sprintf("failed to derive child extended "+
+				"key -- branch %d, child %d", row.branch,
+				row.index)
+			return managerError(ErrKeyChain, str, err)
+		}
+
+		ma, err := newManagedAddressFromExtKey(
+			s, DerivationPath{
+				InternalAccount: account,
+				Account:         acctKey.ChildIndex(),
+				Branch:          row.branch,
+				Index:           row.index,
+			}, addrKey,
+			s.addrTypeAt(acctInfo, row.branch, row.index),
+		)
+		if err != nil {
+			return err
+		}
+
+		derivedHash := sha256.Sum256(ma.Address().ScriptAddress())
+		if !bytes.Equal(derivedHash[:], addrHash) {
+			mismatches = append(mismatches, mismatch)
+		}
+		return nil
+	}
+	err = forEachAccountAddressHash(ns, &s.scope, account, verifyAddr)
+	if err != nil {
+		return nil, maybeConvertDbError(err)
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		if mismatches[i].Branch != mismatches[j].Branch {
+			return mismatches[i].Branch < mismatches[j].Branch
+		}
+		return mismatches[i].Index < mismatches[j].Index
+	})
+
+	return mismatches, nil
+}
+
+// MissingAddress is a chained address of an account found missing from the
+// manager by RestoreAccountAddresses.
+type MissingAddress struct {
+	// Branch is the branch of the account the address derives from.
+	Branch uint32
+
+	// Index is the index of the address within its branch.
+	Index uint32
+
+	// Address is the address itself.
+	Address btcutil.Address
+}
+
+// RestoreAccountAddresses re-derives the chained addresses of the given account
+// stored in this scoped manager from the expected account extended key, and
+// restores those missing from the manager, such as after a partial write. On
+// each branch, addresses are derived through the gap limit past the branch's
+// next index, and every missing address up to the last one whose address ID is
+// referenced is restored, extending the branch if needed. The referenced
+// address IDs are keyed by their string conversion, and usually correspond to
+// the outputs of the wallet's credits. The missing addresses are returned
+// sorted by branch and index, without being restored if dryRun is set. Only
+// public derivation is used, so this works for watch-only accounts, and a
+// private extended key is neutered before use.
+func (s *ScopedKeyManager) RestoreAccountAddresses(
+	ns walletdb.ReadWriteBucket, account uint32,
+	expectedXpub *hdkeychain.ExtendedKey, referenced map[string]struct{},
+	dryRun bool) ([]MissingAddress, error) {
+
+	// The gap limit is read before acquiring the scoped manager's lock to
+	// not hold both locks at once.
+	gapLimit := s.rootManager.GapLimit()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	acctInfo, err := s.loadAccountInfo(ns, account)
+	if err != nil {
+		return nil, err
+	}
+
+	acctKey, err := expectedXpub.Neuter()
+	if err != nil {
+		str := "failed to neuter expected account key"
+		return nil, managerError(ErrKeyChain, str, err)
+	}
+
+	var missing []MissingAddress
+	for _, branch := range []uint32{ExternalBranch, InternalBranch} {
+		nextIndex := acctInfo.nextExternalIndex
+		if branch == InternalBranch {
+			nextIndex = acctInfo.nextInternalIndex
+		}
+
+		branchMissing, err := s.missingBranchAddresses(
+			ns, acctInfo, acctKey, account, branch,
+			nextIndex+gapLimit, referenced,
+		)
+		if err != nil {
+			return nil, err
+		}
+		missing = append(missing, branchMissing...)
+
+		if dryRun {
+			continue
+		}
+
+		// The addresses past the branch's next index extend it, while
+		// the others are restored in place, as they're in ascending
+		// order.
+		for _, addr := range branchMissing {
+			addressID := addr.Address.ScriptAddress()
+			if addr.Index >= nextIndex {
+				err = putChainedAddress(
+					ns, &s.scope, addressID, account,
+					ssFull, branch, addr.Index, adtChain,
+				)
+			} else {
+				err = putChainedAddressRow(
+					ns, &s.scope, addressID, account,
+					ssFull, branch, addr.Index, adtChain,
+				)
+			}
+			if err != nil {
+				return nil, maybeConvertDbError(err)
+			}
+		}
+	}
+
+	// The account's next indexes may have been extended, so its cached
+	// information is reloaded once committed.
+	if !dryRun && len(missing) > 0 {
+		ns.Tx().OnCommit(func() {
+			s.InvalidateAccountCache(account)
+		})
+	}
+
+	return missing, nil
+}
+
+// missingBranchAddresses derives the addresses of the account's branch from
+// the account key up to, but excluding, the given index, and returns those
+// missing from the manager up to the last one whose address ID is referenced.
+//
+// This function MUST be called with the manager lock held.
+func (s *ScopedKeyManager) missingBranchAddresses(ns walletdb.ReadBucket,
+	acctInfo *accountInfo, acctKey *hdkeychain.ExtendedKey, account,
+	branch, endIndex uint32,
+	referenced map[string]struct{}) ([]MissingAddress, error) {
+
+	branchKey, err := acctKey.DeriveNonStandard(branch) // nolint:staticcheck
+	if err != nil {
+		str := fmt.Sprintf("failed to derive extended key branch %d",
+			branch)
+		return nil, managerError(ErrKeyChain, str, err)
+	}
+
+	// Every address up to the end index is derived, as the missing ones
+	// are only known to be needed once an address past them is found to
+	// be referenced.
+	var (
+		missing   []MissingAddress
+		numNeeded int
+	)
+	for index := uint32(0); index < endIndex; index++ {
+		addrKey, err := branchKey.DeriveNonStandard(index) // nolint:staticcheck
+		if err == hdkeychain.ErrInvalidChild {
+			continue
+		}
+		if err != nil {
+			str := fmt.Sprintf("failed to derive child extended "+
+				"key -- branch %d, child %d", branch, index)
+			return nil, managerError(ErrKeyChain, str, err)
+		}
+
+		ma, err := newManagedAddressFromExtKey(
+			s, DerivationPath{
+				InternalAccount: account,
+				Account:         acctKey.ChildIndex(),
+				Branch:          branch,
+				Index:           index,
+			}, addrKey, s.addrTypeAt(acctInfo, branch, index),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		addressID := ma.Address().ScriptAddress()
+		if !existsAddress(ns, &s.scope, addressID) {
+			missing = append(missing, MissingAddress{
+				Branch:  branch,
+				Index:   index,
+				Address: ma.Address(),
+			})
+		}
+		if _, ok := referenced[string(addressID)]; ok {
+			numNeeded = len(missing)
+		}
+	}
+
+	return missing[:numNeeded], nil
+}
+
 // cloneKeyWithVersion clones an extended key to use the version corresponding
 // to the manager's key scope. This should only be used for non-watch-only
 // accounts as they are stored within the database using the legacy BIP-0044
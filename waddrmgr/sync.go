@@ -133,3 +133,37 @@ func (m *Manager) SetBirthdayBlock(ns walletdb.ReadWriteBucket,
 	}
 	return putBirthdayBlockVerification(ns, verified)
 }
+
+// HistoricalScanSkipped returns whether the manager started syncing from the
+// chain tip at the time it was first synchronized, rather than from its
+// birthday block, meaning that no historical blocks have been scanned.
+func (m *Manager) HistoricalScanSkipped(ns walletdb.ReadBucket) bool {
+	return fetchHistoricalScanSkipped(ns)
+}
+
+// SetHistoricalScanSkipped sets whether the historical blocks of the manager
+// have been skipped when syncing.
+func (m *Manager) SetHistoricalScanSkipped(ns walletdb.ReadWriteBucket,
+	skipped bool) error {
+
+	return putHistoricalScanSkipped(ns, skipped)
+}
+
+// NotificationJournal returns the last block whose notification was fully
+// processed by the wallet. If no such block has been recorded, an ErrNoExist
+// error is returned.
+func (m *Manager) NotificationJournal(ns walletdb.ReadBucket) (*BlockStamp, error) {
+	return fetchNotificationJournal(ns)
+}
+
+// SetNotificationJournal records the block whose notification was processed
+// by the wallet.
+//
+// NOTE: This should be called within the same database transaction as the
+// updates resulting from the block's notification, such that the journal
+// always reflects the state of the database.
+func (m *Manager) SetNotificationJournal(ns walletdb.ReadWriteBucket,
+	block *BlockStamp) error {
+
+	return putNotificationJournal(ns, block)
+}
@@ -0,0 +1,374 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+var (
+	// ErrTxInputsRespent is returned when attempting to abandon a
+	// transaction that has an input spent by another transaction.
+	ErrTxInputsRespent = errors.New("transaction input is already spent " +
+		"by another transaction")
+
+	// ErrTxNotFunded is returned when attempting to rebuild a transaction
+	// that doesn't spend any wallet outputs, as the wallet has no way to
+	// determine which account should fund it.
+	ErrTxNotFunded = errors.New("transaction is not funded by the wallet")
+
+	// ErrNoConflictingInput is returned when rebuilding a transaction none
+	// of whose inputs can be spent by the new transaction, which would
+	// then be able to confirm along with the original.
+	ErrNoConflictingInput = errors.New("none of the transaction's inputs " +
+		"can be spent by its replacement")
+)
+
+// AbandonAndRebuild abandons the unconfirmed wallet transaction with the given
+// hash, freeing its inputs, and replaces it with a newly funded transaction
+// paying the same outputs at the given fee rate, expressed in sat/kb. The
+// change outputs of the original are not carried over, and the new transaction
+// is funded by the account that funded the original. It spends at least one of
+// the original's inputs, such that both can't confirm. The transaction is
+// published and labeled as the original before being returned along with the
+// hash of the abandoned transaction, which is no longer known to the wallet
+// once this succeeds.
+//
+// The original transaction can't be abandoned if it has confirmed, or if any
+// of its inputs have been spent by another transaction. If the new transaction
+// can't be created, the original is restored along with any unconfirmed
+// transactions spending it, and the locks and leases of its inputs.
+func (w *Wallet) AbandonAndRebuild(txHash chainhash.Hash,
+	feeRate btcutil.Amount) (chainhash.Hash, *wire.MsgTx, error) {
+
+	var (
+		details       *wtxmgr.TxDetails
+		descendants   []*wtxmgr.TxDetails
+		fundingScript []byte
+	)
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		var err error
+		details, err = w.TxStore.TxDetails(txmgrNs, &txHash)
+		if err != nil || details == nil {
+			return err
+		}
+		if details.Block.Height != -1 || len(details.Debits) == 0 {
+			return nil
+		}
+
+		err = w.checkInputsNotRespent(txmgrNs, &details.MsgTx)
+		if err != nil {
+			return err
+		}
+
+		// The new transaction will be funded by the account of the
+		// first wallet output spent by the original.
+		debit := details.Debits[0]
+		prevOut := details.MsgTx.TxIn[debit.Index].PreviousOutPoint
+		prevDetails, err := w.TxStore.TxDetails(txmgrNs, &prevOut.Hash)
+		if err != nil {
+			return err
+		}
+		if prevDetails == nil ||
+			int(prevOut.Index) >= len(prevDetails.MsgTx.TxOut) {
+
+			return ErrTxNotFunded
+		}
+		fundingScript = prevDetails.MsgTx.TxOut[prevOut.Index].PkScript
+
+		// The transactions spending the original are removed along
+		// with it, so they're kept to be restored if the new
+		// transaction can't be created.
+		descendants, err = w.unminedDescendants(txmgrNs, txHash)
+		return err
+	})
+	if err != nil {
+		return chainhash.Hash{}, nil, err
+	}
+	if details == nil {
+		return chainhash.Hash{}, nil, ErrTxNotFound
+	}
+	if details.Block.Height != -1 {
+		return chainhash.Hash{}, nil, ErrTxAlreadyConfirmed
+	}
+	if len(details.Debits) == 0 {
+		return chainhash.Hash{}, nil, ErrTxNotFunded
+	}
+
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+		fundingScript, w.chainParams,
+	)
+	if err != nil {
+		return chainhash.Hash{}, nil, err
+	}
+	if len(addrs) == 0 {
+		return chainhash.Hash{}, nil, ErrTxNotFunded
+	}
+	info, err := w.AddressInfo(addrs[0])
+	if err != nil {
+		return chainhash.Hash{}, nil, err
+	}
+
+	// Carry over every output that isn't change.
+	change := make(map[uint32]struct{}, len(details.Credits))
+	for _, credit := range details.Credits {
+		if credit.Change {
+			change[credit.Index] = struct{}{}
+		}
+	}
+	var outputs []*wire.TxOut
+	for i, txOut := range details.MsgTx.TxOut {
+		if _, ok := change[uint32(i)]; ok {
+			continue
+		}
+		outputs = append(outputs, wire.NewTxOut(
+			txOut.Value, txOut.PkScript,
+		))
+	}
+	if len(outputs) == 0 {
+		return chainhash.Hash{}, nil, fmt.Errorf("transaction %v only "+
+			"has change outputs", txHash)
+	}
+
+	// With the replacement's parameters determined, we'll abandon the
+	// original, removing it along with any transactions spending it, and
+	// free its inputs.
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		txmgrNs := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+		return w.TxStore.RemoveUnminedTx(txmgrNs, &details.TxRecord)
+	})
+	if err != nil {
+		return chainhash.Hash{}, nil, err
+	}
+	restoreLocks := w.releaseInputs(&details.MsgTx)
+
+	log.Infof("Abandoned transaction %v", txHash)
+
+	tx, err := w.SendOutputs(
+		outputs, &info.KeyScope, info.Account, 1, feeRate,
+		CoinSelectionLargest, details.Label,
+		withConflictingInputs(&details.MsgTx),
+	)
+	if err != nil {
+		restoreLocks()
+
+		restoreErr := walletdb.Update(w.db, func(
+			dbtx walletdb.ReadWriteTx) error {
+
+			for _, d := range descendants {
+				err := w.addRelevantTx(dbtx, &d.TxRecord, nil)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if restoreErr != nil {
+			log.Errorf("Unable to restore abandoned transaction "+
+				"%v: %v", txHash, restoreErr)
+		}
+
+		return chainhash.Hash{}, nil, err
+	}
+
+	// With the new transaction published, the abandoned ones won't be
+	// restored, so the labels of their outputs can be deleted.
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		txmgrNs := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+		for _, d := range descendants {
+			err := w.TxStore.DeleteOutputLabels(txmgrNs, d.Hash)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Unable to delete the output labels of abandoned "+
+			"transaction %v: %v", txHash, err)
+	}
+
+	return txHash, tx, nil
+}
+
+// unminedDescendants returns the details of the unconfirmed transaction with
+// the given hash followed by those of the unconfirmed transactions descending
+// from it, each following its ancestors.
+func (w *Wallet) unminedDescendants(txmgrNs walletdb.ReadBucket,
+	txHash chainhash.Hash) ([]*wtxmgr.TxDetails, error) {
+
+	unmined, err := w.TxStore.UnminedTxs(txmgrNs)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := make([]*wtxmgr.TxDetails, 0, len(unmined))
+	for _, tx := range unmined {
+		hash := tx.TxHash()
+		details, err := w.TxStore.TxDetails(txmgrNs, &hash)
+		if err != nil {
+			return nil, err
+		}
+		if details != nil {
+			pkg = append(pkg, details)
+		}
+	}
+	pkg = sortPackage(pkg)
+
+	byHash := make(map[chainhash.Hash]*wtxmgr.TxDetails, len(pkg))
+	for _, d := range pkg {
+		byHash[d.Hash] = d
+	}
+	hashes := packageDescendants(pkg, txHash)
+	descendants := make([]*wtxmgr.TxDetails, 0, len(hashes))
+	for _, hash := range hashes {
+		if d, ok := byHash[hash]; ok {
+			descendants = append(descendants, d)
+		}
+	}
+
+	return descendants, nil
+}
+
+// checkInputsNotRespent ensures none of the inputs of the unconfirmed
+// transaction are spent by another unconfirmed transaction.
+func (w *Wallet) checkInputsNotRespent(txmgrNs walletdb.ReadBucket,
+	tx *wire.MsgTx) error {
+
+	inputs := make(map[wire.OutPoint]struct{}, len(tx.TxIn))
+	for _, txIn := range tx.TxIn {
+		inputs[txIn.PreviousOutPoint] = struct{}{}
+	}
+
+	unmined, err := w.TxStore.UnminedTxs(txmgrNs)
+	if err != nil {
+		return err
+	}
+
+	txHash := tx.TxHash()
+	for _, other := range unmined {
+		otherHash := other.TxHash()
+		if otherHash == txHash {
+			continue
+		}
+		for _, txIn := range other.TxIn {
+			if _, ok := inputs[txIn.PreviousOutPoint]; !ok {
+				continue
+			}
+			return fmt.Errorf("%w: %v is spent by %v",
+				ErrTxInputsRespent, txIn.PreviousOutPoint,
+				otherHash)
+		}
+	}
+
+	return nil
+}
+
+// releaseInputs unlocks and releases any leases of the transaction's inputs.
+// The returned function locks and leases them again until their leases would
+// have expired.
+func (w *Wallet) releaseInputs(tx *wire.MsgTx) func() {
+	var (
+		locked   []wire.OutPoint
+		released []*wtxmgr.LockedOutput
+	)
+	restore := func() {
+		for _, op := range locked {
+			w.LockOutpoint(op)
+		}
+		for _, lease := range released {
+			duration := time.Until(lease.Expiration)
+			if duration <= 0 {
+				continue
+			}
+			_, err := w.LeaseOutput(
+				lease.LockID, lease.Outpoint, duration,
+			)
+			if err != nil {
+				log.Warnf("Unable to lease output %v: %v",
+					lease.Outpoint, err)
+			}
+		}
+	}
+
+	inputs := make(map[wire.OutPoint]struct{}, len(tx.TxIn))
+	for _, txIn := range tx.TxIn {
+		inputs[txIn.PreviousOutPoint] = struct{}{}
+		if w.LockedOutpoint(txIn.PreviousOutPoint) {
+			locked = append(locked, txIn.PreviousOutPoint)
+		}
+		w.UnlockOutpoint(txIn.PreviousOutPoint)
+	}
+
+	leases, err := w.ListLeasedOutputs()
+	if err != nil {
+		log.Warnf("Unable to list leased outputs: %v", err)
+		return restore
+	}
+	for _, lease := range leases {
+		if _, ok := inputs[lease.Outpoint]; !ok {
+			continue
+		}
+		err := w.ReleaseOutput(lease.LockID, lease.Outpoint)
+		if err != nil {
+			log.Warnf("Unable to release output %v: %v",
+				lease.Outpoint, err)
+			continue
+		}
+		released = append(released, lease)
+	}
+
+	return restore
+}
+
+// withConflictingInputs requires the created transaction to spend at least
+// one of the inputs of the given transaction, which are selected before any
+// other output, such that both transactions can't confirm.
+func withConflictingInputs(tx *wire.MsgTx) TxCreateOption {
+	return func(opts *txCreateOptions) {
+		opts.conflictingInputs = make(
+			map[wire.OutPoint]struct{}, len(tx.TxIn),
+		)
+		for _, txIn := range tx.TxIn {
+			opts.conflictingInputs[txIn.PreviousOutPoint] =
+				struct{}{}
+		}
+	}
+}
+
+// selectConflictingFirst orders the selectable outputs such that those spent
+// by the conflicting inputs come first, keeping their order otherwise. An
+// error is returned if none of them are selectable.
+func selectConflictingFirst(selectable []wtxmgr.Credit,
+	conflicting map[wire.OutPoint]struct{}) ([]wtxmgr.Credit, error) {
+
+	ordered := make([]wtxmgr.Credit, 0, len(selectable))
+	for _, credit := range selectable {
+		if _, ok := conflicting[credit.OutPoint]; ok {
+			ordered = append(ordered, credit)
+		}
+	}
+	if len(ordered) == 0 {
+		return nil, ErrNoConflictingInput
+	}
+	for _, credit := range selectable {
+		if _, ok := conflicting[credit.OutPoint]; !ok {
+			ordered = append(ordered, credit)
+		}
+	}
+
+	return ordered, nil
+}
@@ -0,0 +1,275 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// TestAbandonAndRebuild ensures that an unconfirmed transaction can be
+// abandoned and replaced by one paying the same outputs, and that confirmed
+// transactions or those with re-spent inputs are not abandoned.
+func TestAbandonAndRebuild(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	// Fund the wallet with a confirmed output.
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	fundingTx := wire.NewMsgTx(wire.TxVersion)
+	fundingTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	fundingTx.AddTxOut(wire.NewTxOut(btcutil.SatoshiPerBitcoin, pkScript))
+	addUtxo(t, w, fundingTx)
+
+	// A confirmed transaction can't be abandoned.
+	_, _, err = w.AbandonAndRebuild(fundingTx.TxHash(), 1000)
+	if !errors.Is(err, ErrTxAlreadyConfirmed) {
+		t.Fatalf("expected ErrTxAlreadyConfirmed, got %v", err)
+	}
+
+	// Send an output to a foreign address.
+	destAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), w.chainParams,
+	)
+	if err != nil {
+		t.Fatalf("unable to create destination address: %v", err)
+	}
+	destScript, err := txscript.PayToAddrScript(destAddr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	const value = 10000000
+	original, err := w.SendOutputs(
+		[]*wire.TxOut{wire.NewTxOut(value, destScript)},
+		&waddrmgr.KeyScopeBIP0084, 0, 1, 1000, CoinSelectionLargest,
+		"payment",
+	)
+	if err != nil {
+		t.Fatalf("unable to send outputs: %v", err)
+	}
+
+	originalOut := wire.OutPoint{Hash: original.TxHash()}
+	if err := w.SetOutputLabel(originalOut, "alice"); err != nil {
+		t.Fatalf("unable to label output: %v", err)
+	}
+
+	// Abandoning it should result in a new transaction paying the same
+	// output, with the original no longer being known to the wallet, nor
+	// the labels of its outputs.
+	abandoned, rebuilt, err := w.AbandonAndRebuild(original.TxHash(), 5000)
+	if err != nil {
+		t.Fatalf("unable to abandon and rebuild transaction: %v", err)
+	}
+	if abandoned != original.TxHash() {
+		t.Fatalf("expected abandoned transaction %v, got %v",
+			original.TxHash(), abandoned)
+	}
+	if rebuilt.TxHash() == original.TxHash() {
+		t.Fatalf("expected a new transaction")
+	}
+
+	// The rebuilt transaction must conflict with the original.
+	var conflicts bool
+	for _, txIn := range rebuilt.TxIn {
+		for _, originalIn := range original.TxIn {
+			prevOut := originalIn.PreviousOutPoint
+			if txIn.PreviousOutPoint == prevOut {
+				conflicts = true
+			}
+		}
+	}
+	if !conflicts {
+		t.Fatalf("expected rebuilt transaction to spend an input of " +
+			"the original")
+	}
+	var found bool
+	for _, txOut := range rebuilt.TxOut {
+		if txOut.Value == value &&
+			bytes.Equal(txOut.PkScript, destScript) {
+
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected rebuilt transaction to pay %v to %v",
+			btcutil.Amount(value), destAddr)
+	}
+
+	var details, rebuiltDetails *wtxmgr.TxDetails
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		originalHash := original.TxHash()
+		details, err = w.TxStore.TxDetails(ns, &originalHash)
+		if err != nil {
+			return err
+		}
+		rebuiltHash := rebuilt.TxHash()
+		rebuiltDetails, err = w.TxStore.TxDetails(ns, &rebuiltHash)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch transaction details: %v", err)
+	}
+	if details != nil {
+		t.Fatalf("expected original transaction to be abandoned")
+	}
+	label, err := w.OutputLabel(originalOut)
+	if err != nil {
+		t.Fatalf("unable to fetch output label: %v", err)
+	}
+	if label != "" {
+		t.Fatalf("expected output label of abandoned transaction to "+
+			"be deleted, got %q", label)
+	}
+	if rebuiltDetails == nil || rebuiltDetails.Label != "payment" {
+		t.Fatalf("expected rebuilt transaction to be labeled as the " +
+			"original")
+	}
+
+	// Once another transaction spends one of its inputs, the rebuilt
+	// transaction can't be abandoned.
+	conflict := wire.NewMsgTx(wire.TxVersion)
+	conflict.AddTxIn(wire.NewTxIn(
+		&rebuilt.TxIn[0].PreviousOutPoint, nil, nil,
+	))
+	conflict.AddTxOut(wire.NewTxOut(value, destScript))
+	addUnminedTx(t, w, conflict)
+
+	_, _, err = w.AbandonAndRebuild(rebuilt.TxHash(), 10000)
+	if !errors.Is(err, ErrTxInputsRespent) {
+		t.Fatalf("expected ErrTxInputsRespent, got %v", err)
+	}
+}
+
+// TestAbandonAndRebuildRestore ensures that the abandoned transaction and the
+// unconfirmed transactions spending it are restored if the new transaction
+// can't be created.
+func TestAbandonAndRebuildRestore(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	fundingTx := wire.NewMsgTx(wire.TxVersion)
+	fundingTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	fundingTx.AddTxOut(wire.NewTxOut(btcutil.SatoshiPerBitcoin, pkScript))
+	addUtxo(t, w, fundingTx)
+
+	destAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), w.chainParams,
+	)
+	if err != nil {
+		t.Fatalf("unable to create destination address: %v", err)
+	}
+	destScript, err := txscript.PayToAddrScript(destAddr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	original, err := w.SendOutputs(
+		[]*wire.TxOut{wire.NewTxOut(10000000, destScript)},
+		&waddrmgr.KeyScopeBIP0084, 0, 1, 1000, CoinSelectionLargest,
+		"payment",
+	)
+	if err != nil {
+		t.Fatalf("unable to send outputs: %v", err)
+	}
+	originalHash := original.TxHash()
+
+	// Spend the change of the original with another wallet transaction.
+	var changeIndex uint32
+	for i, txOut := range original.TxOut {
+		if !bytes.Equal(txOut.PkScript, destScript) {
+			changeIndex = uint32(i)
+		}
+	}
+	child := wire.NewMsgTx(wire.TxVersion)
+	child.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: originalHash, Index: changeIndex}, nil,
+		nil,
+	))
+	child.AddTxOut(wire.NewTxOut(
+		original.TxOut[changeIndex].Value-1000, pkScript,
+	))
+	addUnminedTx(t, w, child, 0)
+	childHash := child.TxHash()
+
+	// Lease the original's input, which should be leased again once the
+	// original is restored.
+	leaseID := wtxmgr.LockID{0x01}
+	leasedOutpoint := original.TxIn[0].PreviousOutPoint
+	_, err = w.LeaseOutput(leaseID, leasedOutpoint, time.Hour)
+	if err != nil {
+		t.Fatalf("unable to lease output: %v", err)
+	}
+
+	// A fee rate above the wallet's bounds prevents the new transaction
+	// from being created, so both transactions should be restored.
+	_, _, err = w.AbandonAndRebuild(
+		originalHash, DefaultFeeRateBounds.Max+1,
+	)
+	if !errors.Is(err, ErrFeeRateOutOfBounds) {
+		t.Fatalf("expected ErrFeeRateOutOfBounds, got %v", err)
+	}
+
+	leases, err := w.ListLeasedOutputs()
+	if err != nil {
+		t.Fatalf("unable to list leased outputs: %v", err)
+	}
+	if len(leases) != 1 || leases[0].LockID != leaseID ||
+		leases[0].Outpoint != leasedOutpoint {
+
+		t.Fatalf("expected lease of %v to be restored, got %v",
+			leasedOutpoint, leases)
+	}
+
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		for _, hash := range []chainhash.Hash{originalHash, childHash} {
+			details, err := w.TxStore.TxDetails(ns, &hash)
+			if err != nil {
+				return err
+			}
+			if details == nil {
+				t.Fatalf("expected transaction %v to be "+
+					"restored", hash)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch transaction details: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// AccountRecoveryResult reports the outcome of recovering an account through
+// RecoverAccounts.
+type AccountRecoveryResult struct {
+	// Scope is the key scope of the account.
+	Scope waddrmgr.KeyScope
+
+	// Account is the number of the account.
+	Account uint32
+
+	// Active indicates whether any of the account's addresses were found
+	// to be used on chain.
+	Active bool
+
+	// UsedAddresses is the number of the account's addresses that were
+	// found to be used on chain.
+	UsedAddresses int
+}
+
+// RecoverAccounts recovers the accounts 0 through numAccounts-1 of each of the
+// given key scopes in a single rescan, rather than one rescan per account. The
+// first recoveryWindow external and internal addresses of every account are
+// derived, creating the accounts that don't exist yet, and registered with the
+// chain client, after which the chain is rescanned from the wallet's birthday.
+// The results report, for every account, whether it showed any activity.
+//
+// Unlike the recovery performed when syncing a restored wallet, the address
+// window isn't extended as addresses are found to be used, so addresses
+// derived past the recovery window aren't recovered.
+func (w *Wallet) RecoverAccounts(scopes []waddrmgr.KeyScope, numAccounts,
+	recoveryWindow uint32) ([]AccountRecoveryResult, error) {
+
+	chainClient, done, err := w.useChainClient()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	switch {
+	case len(scopes) == 0:
+		return nil, errors.New("no key scopes to recover")
+	case numAccounts == 0:
+		return nil, errors.New("no accounts to recover")
+	case numAccounts-1 > waddrmgr.MaxAccountNum:
+		return nil, errors.New("too many accounts to recover")
+	case recoveryWindow == 0:
+		return nil, errors.New("recovery window must be positive")
+	}
+
+	scopedMgrs := make([]*waddrmgr.ScopedKeyManager, 0, len(scopes))
+	for _, scope := range scopes {
+		scopedMgr, err := w.Manager.FetchScopedKeyManager(scope)
+		if err != nil {
+			return nil, err
+		}
+		scopedMgrs = append(scopedMgrs, scopedMgr)
+	}
+
+	// Derive the recovery window of every account, such that all of their
+	// addresses are included in the wallet's active addresses to rescan
+	// for.
+	var (
+		startStamp waddrmgr.BlockStamp
+		addrs      []btcutil.Address
+		unspent    []wtxmgr.Credit
+	)
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		ns := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
+
+		for _, scopedMgr := range scopedMgrs {
+			for acct := uint32(0); acct < numAccounts; acct++ {
+				err := recoverAccountWindow(
+					ns, scopedMgr, acct, recoveryWindow,
+				)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		startStamp, _, err = w.Manager.BirthdayBlock(ns)
+		switch {
+		case waddrmgr.IsError(err, waddrmgr.ErrBirthdayBlockNotSet):
+			startStamp = waddrmgr.BlockStamp{
+				Hash: *w.chainParams.GenesisHash,
+			}
+		case err != nil:
+			return err
+		}
+
+		addrs, unspent, err = w.activeData(dbtx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Recovering %d accounts of %d key scopes from height %d",
+		numAccounts, len(scopes), startStamp.Height)
+
+	if err := chainClient.NotifyReceived(addrs); err != nil {
+		return nil, err
+	}
+
+	// The rescan is resubmitted to the new backend if it's switched, so
+	// we're done with the current one.
+	done()
+	err = w.rescanAndWait(addrs, unspent, &startStamp)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []AccountRecoveryResult
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(waddrmgrNamespaceKey)
+
+		for _, scopedMgr := range scopedMgrs {
+			for acct := uint32(0); acct < numAccounts; acct++ {
+				result := AccountRecoveryResult{
+					Scope:   scopedMgr.Scope(),
+					Account: acct,
+				}
+				countUsed := func(
+					addr waddrmgr.ManagedAddress) error {
+
+					if addr.Used(ns) {
+						result.UsedAddresses++
+					}
+					return nil
+				}
+				err := scopedMgr.ForEachAccountAddress(
+					ns, acct, countUsed,
+				)
+				if err != nil {
+					return err
+				}
+				result.Active = result.UsedAddresses > 0
+
+				results = append(results, result)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// recoverAccountWindow derives the first recoveryWindow external and internal
+// addresses of the account, creating it if it doesn't exist yet.
+func recoverAccountWindow(ns walletdb.ReadWriteBucket,
+	scopedMgr *waddrmgr.ScopedKeyManager, account,
+	recoveryWindow uint32) error {
+
+	_, err := scopedMgr.AccountProperties(ns, account)
+	switch {
+	case waddrmgr.IsError(err, waddrmgr.ErrAccountNotFound):
+		if err := scopedMgr.NewRawAccount(ns, account); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	}
+
+	lastIndex := recoveryWindow - 1
+	err = scopedMgr.ExtendExternalAddresses(ns, account, lastIndex)
+	if err != nil {
+		return err
+	}
+	return scopedMgr.ExtendInternalAddresses(ns, account, lastIndex)
+}
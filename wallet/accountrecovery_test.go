@@ -0,0 +1,196 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// rescanningChainClient is a mock chain client whose rescans notify the wallet
+// of the transactions paying to the rescanned addresses.
+type rescanningChainClient struct {
+	notifyingChainClient
+
+	txs     []*wire.MsgTx
+	block   wtxmgr.BlockMeta
+	tip     waddrmgr.BlockStamp
+	rescans int
+}
+
+// Rescan notifies the wallet of the mock chain client's transactions paying
+// to any of the given addresses, followed by the end of the rescan.
+func (c *rescanningChainClient) Rescan(_ *chainhash.Hash,
+	addrs []btcutil.Address, _ map[wire.OutPoint]btcutil.Address) error {
+
+	c.rescans++
+
+	watched := make(map[string]struct{}, len(addrs))
+	for _, addr := range addrs {
+		watched[addr.EncodeAddress()] = struct{}{}
+	}
+
+	for _, tx := range c.txs {
+		relevant := false
+		for _, txOut := range tx.TxOut {
+			_, outAddrs, _, err := txscript.ExtractPkScriptAddrs(
+				txOut.PkScript, &chaincfg.TestNet3Params,
+			)
+			if err != nil {
+				return err
+			}
+			for _, addr := range outAddrs {
+				_, ok := watched[addr.EncodeAddress()]
+				relevant = relevant || ok
+			}
+		}
+		if !relevant {
+			continue
+		}
+
+		rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+		if err != nil {
+			return err
+		}
+		c.notifications <- chain.RelevantTx{
+			TxRecord: rec,
+			Block:    &c.block,
+		}
+	}
+
+	c.notifications <- &chain.RescanFinished{
+		Hash:   &c.tip.Hash,
+		Height: c.tip.Height,
+		Time:   c.tip.Timestamp,
+	}
+	return nil
+}
+
+// TestRecoverAccounts ensures that the accounts of a restored wallet are
+// recovered through a single rescan, reporting the accounts that were used.
+func TestRecoverAccounts(t *testing.T) {
+	t.Parallel()
+
+	seed, err := hdkeychain.GenerateSeed(hdkeychain.MinSeedBytes)
+	require.NoError(t, err)
+
+	pubPass := []byte("hello")
+	privPass := []byte("world")
+	scope := waddrmgr.KeyScopeBIP0084
+
+	createWallet := func() *Wallet {
+		dir, err := ioutil.TempDir("", "test_recover_accounts")
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, os.RemoveAll(dir))
+		})
+
+		loader := NewLoader(
+			&chaincfg.TestNet3Params, dir, true, defaultDBTimeout,
+			250,
+		)
+		w, err := loader.CreateNewWallet(
+			pubPass, privPass, seed, time.Now(),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, loader.UnloadWallet())
+		})
+
+		err = w.Unlock(privPass, time.After(10*time.Minute))
+		require.NoError(t, err)
+		w.chainClient = &mockChainClient{}
+
+		return w
+	}
+
+	// Fund the first and last accounts of a wallet with three accounts.
+	original := createWallet()
+	for i := 1; i < 3; i++ {
+		_, err := original.NextAccount(
+			scope, fmt.Sprintf("account %d", i),
+		)
+		require.NoError(t, err)
+	}
+
+	var txs []*wire.MsgTx
+	for _, account := range []uint32{0, 2} {
+		addr, err := original.NewAddress(account, scope)
+		require.NoError(t, err)
+		pkScript, err := txscript.PayToAddrScript(addr)
+		require.NoError(t, err)
+
+		tx := wire.NewMsgTx(2)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Index: account},
+		})
+		tx.AddTxOut(wire.NewTxOut(100000, pkScript))
+		txs = append(txs, tx)
+	}
+
+	// Restore the wallet from its seed, which only knows of its default
+	// account, and recover its accounts.
+	w := createWallet()
+	chainClient := &rescanningChainClient{
+		notifyingChainClient: notifyingChainClient{
+			notifications: make(chan interface{}),
+		},
+		txs: txs,
+		block: wtxmgr.BlockMeta{
+			Block: wtxmgr.Block{
+				Hash:   chainhash.Hash{1},
+				Height: 100,
+			},
+			Time: time.Now(),
+		},
+		tip: w.Manager.SyncedTo(),
+	}
+	w.chainClient = chainClient
+
+	w.wg.Add(4)
+	go w.handleChainNotifications()
+	go w.rescanBatchHandler()
+	go w.rescanProgressHandler()
+	go w.rescanRPCHandler()
+
+	results, err := w.RecoverAccounts(
+		[]waddrmgr.KeyScope{scope}, 3, 20,
+	)
+	require.NoError(t, err)
+	require.Equal(t, 1, chainClient.rescans)
+
+	require.Len(t, results, 3)
+	for i, result := range results {
+		require.Equal(t, scope, result.Scope)
+		require.Equal(t, uint32(i), result.Account)
+	}
+	require.True(t, results[0].Active)
+	require.Equal(t, 1, results[0].UsedAddresses)
+	require.False(t, results[1].Active)
+	require.Zero(t, results[1].UsedAddresses)
+	require.True(t, results[2].Active)
+	require.Equal(t, 1, results[2].UsedAddresses)
+
+	// The recovered funds are credited to their accounts.
+	for _, account := range []uint32{0, 2} {
+		balances, err := w.CalculateAccountBalances(account, 0)
+		require.NoError(t, err)
+		require.Equal(t, btcutil.Amount(100000), balances.Total)
+	}
+}
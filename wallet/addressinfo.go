@@ -0,0 +1,114 @@
+package wallet
+
+import (
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// AddressInfo consolidates everything the wallet knows about one of its
+// addresses.
+type AddressInfo struct {
+	// Address is the managed address as known to the address manager.
+	Address waddrmgr.ManagedAddress
+
+	// KeyScope is the key scope of the manager that owns the address.
+	KeyScope waddrmgr.KeyScope
+
+	// Account is the internal account number the address belongs to.
+	Account uint32
+
+	// AccountName is the name of the account the address belongs to, which
+	// also serves as the address' label.
+	AccountName string
+
+	// AddrType is the type of the address.
+	AddrType waddrmgr.AddressType
+
+	// Imported indicates whether the address was imported rather than
+	// derived from one of the wallet's accounts.
+	Imported bool
+
+	// Internal indicates whether the address was derived for internal use,
+	// such as change.
+	Internal bool
+
+	// Used indicates whether the address has been used in a transaction.
+	Used bool
+
+	// WatchOnly indicates whether the wallet is unable to sign for the
+	// address, either because the wallet itself or the address' account is
+	// watch-only.
+	WatchOnly bool
+
+	// OriginKnown indicates whether the derivation path of the address'
+	// key is known. This is false for script addresses and for imported
+	// keys whose origin was not provided.
+	OriginKnown bool
+
+	// DerivationPath is the derivation path of the address' key from the
+	// wallet's root key, including the master key fingerprint if known.
+	//
+	// NOTE: This is only set if OriginKnown is true.
+	DerivationPath waddrmgr.DerivationPath
+
+	// PubKey is the public key backing the address.
+	//
+	// NOTE: This is nil for script addresses.
+	PubKey *btcec.PublicKey
+}
+
+// AddressInfo returns detailed information regarding a wallet address. If the
+// address is not known to the wallet, an error with the
+// waddrmgr.ErrAddressNotFound code is returned.
+func (w *Wallet) AddressInfo(a btcutil.Address) (*AddressInfo, error) {
+	var info *AddressInfo
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		addrmgrNs := tx.ReadBucket(waddrmgrNamespaceKey)
+
+		scopedMgr, account, err := w.Manager.AddrAccount(addrmgrNs, a)
+		if err != nil {
+			return err
+		}
+		managedAddr, err := scopedMgr.Address(addrmgrNs, a)
+		if err != nil {
+			return err
+		}
+		props, err := scopedMgr.AccountProperties(addrmgrNs, account)
+		if err != nil {
+			return err
+		}
+
+		info = &AddressInfo{
+			Address:     managedAddr,
+			KeyScope:    scopedMgr.Scope(),
+			Account:     account,
+			AccountName: props.AccountName,
+			AddrType:    managedAddr.AddrType(),
+			Imported:    managedAddr.Imported(),
+			Internal:    managedAddr.Internal(),
+			Used:        managedAddr.Used(addrmgrNs),
+			WatchOnly:   props.IsWatchOnly,
+		}
+
+		pubKeyAddr, ok := managedAddr.(waddrmgr.ManagedPubKeyAddress)
+		if !ok {
+			return nil
+		}
+		info.PubKey = pubKeyAddr.PubKey()
+
+		_, path, ok := pubKeyAddr.DerivationInfo()
+		if ok {
+			info.OriginKnown = true
+			info.DerivationPath = path
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
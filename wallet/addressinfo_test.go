@@ -0,0 +1,58 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAddressInfo ensures that AddressInfo reports the expected details for a
+// derived address, and an ErrAddressNotFound error for an unknown one.
+func TestAddressInfo(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	scope := waddrmgr.KeyScopeBIP0084
+	if _, err := w.NewAddress(0, scope); err != nil {
+		t.Fatalf("unable to derive address: %v", err)
+	}
+	addr, err := w.NewAddress(0, scope)
+	if err != nil {
+		t.Fatalf("unable to derive address: %v", err)
+	}
+
+	info, err := w.AddressInfo(addr)
+	require.NoError(t, err)
+	require.Equal(t, addr.String(), info.Address.Address().String())
+	require.Equal(t, scope, info.KeyScope)
+	require.Equal(t, uint32(0), info.Account)
+	require.Equal(t, "default", info.AccountName)
+	require.Equal(t, waddrmgr.WitnessPubKey, info.AddrType)
+	require.False(t, info.Imported)
+	require.False(t, info.Internal)
+	require.False(t, info.Used)
+	require.False(t, info.WatchOnly)
+	require.True(t, info.OriginKnown)
+	require.Equal(t, uint32(0), info.DerivationPath.Branch)
+	require.Equal(t, uint32(1), info.DerivationPath.Index)
+	require.NotNil(t, info.PubKey)
+
+	pubKeyHash := btcutil.Hash160(info.PubKey.SerializeCompressed())
+	require.Equal(t, addr.ScriptAddress(), pubKeyHash)
+
+	// An address that isn't known to the wallet should result in an
+	// ErrAddressNotFound error.
+	unknown, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), w.chainParams,
+	)
+	require.NoError(t, err)
+
+	_, err = w.AddressInfo(unknown)
+	if !waddrmgr.IsError(err, waddrmgr.ErrAddressNotFound) {
+		t.Fatalf("expected ErrAddressNotFound, got %v", err)
+	}
+}
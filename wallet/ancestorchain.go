@@ -0,0 +1,139 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// DefaultMaxAncestorChainLength is the default maximum number of unconfirmed
+// ancestors, including itself, of a transaction created by the wallet, leaving
+// headroom below bitcoind's default limit of 25 ancestors.
+const DefaultMaxAncestorChainLength = 24
+
+// ErrAncestorChainTooLong is returned when a transaction can only be funded by
+// unconfirmed outputs whose spend would exceed the wallet's maximum ancestor
+// chain length. Waiting for some of their ancestors to confirm allows them to
+// be spent.
+var ErrAncestorChainTooLong = errors.New("unconfirmed ancestor chain too " +
+	"long, wait for confirmations")
+
+// SetMaxAncestorChainLength sets the maximum number of unconfirmed ancestors,
+// including itself, of a transaction created by the wallet, such that it isn't
+// rejected by the mempool policy of the chain backend. As with the mempool
+// policy, the ancestors of all of the transaction's inputs are counted
+// together, each only once. Unconfirmed outputs whose spend would exceed it
+// aren't selected as inputs. A length of zero disables the limit. By default,
+// DefaultMaxAncestorChainLength is used.
+//
+// NOTE: This should be called before the wallet is used to create any
+// transactions.
+func (w *Wallet) SetMaxAncestorChainLength(length uint32) {
+	w.maxAncestorChainLength = length
+}
+
+// filterAncestorChains splits the given credits into those which can be spent
+// on their own without exceeding the wallet's maximum ancestor chain length,
+// and those which can't.
+func (w *Wallet) filterAncestorChains(txmgrNs walletdb.ReadBucket,
+	credits []wtxmgr.Credit) ([]wtxmgr.Credit, []wtxmgr.Credit, error) {
+
+	if w.maxAncestorChainLength == 0 {
+		return credits, nil, nil
+	}
+
+	var spendable, tooLong []wtxmgr.Credit
+	for _, credit := range credits {
+		if credit.Height != -1 {
+			spendable = append(spendable, credit)
+			continue
+		}
+
+		ancestors := make(map[chainhash.Hash]struct{})
+		err := w.unconfirmedAncestors(txmgrNs, &credit.Hash, ancestors)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// The spend is an ancestor of itself.
+		if uint32(len(ancestors))+1 > w.maxAncestorChainLength {
+			tooLong = append(tooLong, credit)
+			continue
+		}
+		spendable = append(spendable, credit)
+	}
+
+	return spendable, tooLong, nil
+}
+
+// checkAncestorChain ensures that the unconfirmed ancestors of the transaction,
+// counted together over all of its inputs, don't exceed the wallet's maximum
+// ancestor chain length.
+func (w *Wallet) checkAncestorChain(txmgrNs walletdb.ReadBucket,
+	tx *wire.MsgTx) error {
+
+	if w.maxAncestorChainLength == 0 {
+		return nil
+	}
+
+	ancestors := make(map[chainhash.Hash]struct{})
+	for _, txIn := range tx.TxIn {
+		err := w.unconfirmedAncestors(
+			txmgrNs, &txIn.PreviousOutPoint.Hash, ancestors,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if uint32(len(ancestors))+1 > w.maxAncestorChainLength {
+		return fmt.Errorf("%w: the selected inputs have %d "+
+			"unconfirmed ancestors", ErrAncestorChainTooLong,
+			len(ancestors))
+	}
+
+	return nil
+}
+
+// unconfirmedAncestors adds the transaction with the given hash, if it's an
+// unconfirmed wallet transaction, and its unconfirmed ancestors to the given
+// set. The search stops once the set exceeds the wallet's maximum ancestor
+// chain length, as the exact count is then irrelevant.
+func (w *Wallet) unconfirmedAncestors(txmgrNs walletdb.ReadBucket,
+	txHash *chainhash.Hash, ancestors map[chainhash.Hash]struct{}) error {
+
+	if _, ok := ancestors[*txHash]; ok {
+		return nil
+	}
+	if uint32(len(ancestors)) > w.maxAncestorChainLength {
+		return nil
+	}
+
+	details, err := w.TxStore.TxDetails(txmgrNs, txHash)
+	if err != nil {
+		return err
+	}
+	if details == nil || details.Block.Height != -1 {
+		return nil
+	}
+	ancestors[*txHash] = struct{}{}
+
+	for _, txIn := range details.MsgTx.TxIn {
+		err := w.unconfirmedAncestors(
+			txmgrNs, &txIn.PreviousOutPoint.Hash, ancestors,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
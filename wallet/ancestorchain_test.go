@@ -0,0 +1,113 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMaxAncestorChainLength ensures that the wallet refuses to extend a chain
+// of unconfirmed self-spends beyond its maximum ancestor chain length.
+func TestMaxAncestorChainLength(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+	require.Equal(
+		t, uint32(DefaultMaxAncestorChainLength),
+		w.maxAncestorChainLength,
+	)
+
+	const maxLength = 3
+	w.SetMaxAncestorChainLength(maxLength)
+	fundWallet(t, w, 1000000)
+
+	send := func() (*wire.MsgTx, error) {
+		return w.SendOutputs(
+			[]*wire.TxOut{wire.NewTxOut(10000, testScriptP2WKH)},
+			&waddrmgr.KeyScopeBIP0084, 0, 0, 1000,
+			CoinSelectionLargest, "",
+		)
+	}
+
+	// Each transaction spends the unconfirmed change of the previous one,
+	// up to the maximum chain length.
+	var prev *wire.MsgTx
+	for i := 0; i < maxLength; i++ {
+		tx, err := send()
+		require.NoError(t, err)
+		require.Len(t, tx.TxIn, 1)
+		if prev != nil {
+			prevOut := tx.TxIn[0].PreviousOutPoint
+			require.Equal(t, prev.TxHash(), prevOut.Hash)
+		}
+		prev = tx
+	}
+
+	// The next self-spend would exceed the limit.
+	_, err := send()
+	require.True(t, errors.Is(err, ErrAncestorChainTooLong), err)
+
+	// Without a limit, the chain can be extended further.
+	w.SetMaxAncestorChainLength(0)
+	_, err = send()
+	require.NoError(t, err)
+}
+
+// TestMaxAncestorChainLengthUnion ensures that the unconfirmed ancestors of all
+// of the inputs of a transaction are counted together against the wallet's
+// maximum ancestor chain length.
+func TestMaxAncestorChainLengthUnion(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	const maxLength = 3
+	w.SetMaxAncestorChainLength(maxLength)
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	// The wallet's only outputs are unconfirmed: one at the end of a chain
+	// of two transactions, and one from a transaction of its own.
+	spend := func(prevOut wire.OutPoint) *wire.MsgTx {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(&prevOut, nil, nil))
+		tx.AddTxOut(wire.NewTxOut(50000, pkScript))
+		addUnminedTx(t, w, tx, 0)
+		return tx
+	}
+	parent := spend(wire.OutPoint{Hash: chainhash.Hash{0x01}})
+	spend(wire.OutPoint{Hash: parent.TxHash()})
+	spend(wire.OutPoint{Hash: chainhash.Hash{0x02}})
+
+	send := func() (*wire.MsgTx, error) {
+		return w.SendOutputs(
+			[]*wire.TxOut{wire.NewTxOut(80000, testScriptP2WKH)},
+			&waddrmgr.KeyScopeBIP0084, 0, 0, 1000,
+			CoinSelectionLargest, "",
+		)
+	}
+
+	// Each output can be spent on its own, but spending both would result
+	// in three unconfirmed ancestors.
+	_, err = send()
+	require.True(t, errors.Is(err, ErrAncestorChainTooLong), err)
+
+	w.SetMaxAncestorChainLength(maxLength + 1)
+	tx, err := send()
+	require.NoError(t, err)
+	require.Len(t, tx.TxIn, 2)
+}
@@ -0,0 +1,316 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"github.com/btcsuite/btcwallet/wallet/txsizes"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// anchorInputVirtualSize is the virtual size of an input spending an
+// ephemeral anchor: its outpoint, empty signature script and sequence, plus a
+// byte accounting for its empty witness.
+const anchorInputVirtualSize = 32 + 4 + 1 + 4 + 1
+
+// anchorSpendLeaseDuration is the duration for which the wallet outputs
+// selected to spend an ephemeral anchor are leased, giving the caller time to
+// publish the child along with its parent.
+const anchorSpendLeaseDuration = 10 * time.Minute
+
+var (
+	// AnchorSpendLockID is the ID used to lease the wallet outputs selected
+	// to spend an ephemeral anchor.
+	AnchorSpendLockID = wtxmgr.LockID(
+		chainhash.HashH([]byte("btcwallet/anchor-spend")),
+	)
+
+	// ErrInvalidEphemeralAnchor is returned when creating a transaction
+	// with an ephemeral anchor that isn't its only zero-value output, or
+	// isn't a TRUC transaction.
+	ErrInvalidEphemeralAnchor = errors.New("invalid ephemeral anchor")
+
+	// ErrNoEphemeralAnchor is returned when attempting to spend the
+	// ephemeral anchor of a transaction that has none.
+	ErrNoEphemeralAnchor = errors.New("transaction has no ephemeral " +
+		"anchor")
+
+	// ErrInsufficientAnchorFunds is returned when the wallet has no
+	// confirmed outputs large enough to pay for the spend of an ephemeral
+	// anchor.
+	ErrInsufficientAnchorFunds = errors.New("insufficient funds to " +
+		"spend ephemeral anchor")
+)
+
+// EphemeralAnchorScript returns the keyless pay-to-anchor (P2A) output script
+// of ephemeral anchors, which anyone can spend without a signature.
+func EphemeralAnchorScript() []byte {
+	return []byte{txscript.OP_1, txscript.OP_DATA_2, 0x4e, 0x73}
+}
+
+// WithEphemeralAnchor adds a zero-value ephemeral anchor output to the created
+// transaction, allowing its fee to be bumped later on through
+// SpendEphemeralAnchor. The transaction is created as a version 3 transaction
+// subject to the TRUC policy, and the anchor must be its only zero-value
+// output. As relay policy requires transactions with zero-value outputs to
+// pay no fee, they're usually created with a fee rate of zero and relayed
+// along with the spend of their anchor.
+func WithEphemeralAnchor() TxCreateOption {
+	return func(opts *txCreateOptions) {
+		opts.txVersion = trucTxVersion
+		opts.ephemeralAnchor = true
+	}
+}
+
+// withEphemeralAnchor returns the outputs along with an ephemeral anchor
+// output, ensuring none of them already has a zero value.
+func withEphemeralAnchor(outputs []*wire.TxOut) ([]*wire.TxOut, error) {
+	for idx, output := range outputs {
+		if output.Value == 0 {
+			return nil, fmt.Errorf("%w: output %d has a zero value",
+				ErrInvalidEphemeralAnchor, idx)
+		}
+	}
+
+	anchored := make([]*wire.TxOut, 0, len(outputs)+1)
+	anchored = append(anchored, outputs...)
+	return append(anchored, wire.NewTxOut(0, EphemeralAnchorScript())), nil
+}
+
+// ephemeralAnchorIndex returns the index of the ephemeral anchor output of the
+// transaction, ensuring it's a TRUC transaction for which the anchor is the
+// only zero-value output.
+func ephemeralAnchorIndex(tx *wire.MsgTx) (uint32, error) {
+	anchorIdx := -1
+	for idx, output := range tx.TxOut {
+		if output.Value != 0 {
+			continue
+		}
+		if !bytes.Equal(output.PkScript, EphemeralAnchorScript()) ||
+			anchorIdx != -1 {
+
+			return 0, fmt.Errorf("%w: output %d isn't the only "+
+				"zero-value output", ErrInvalidEphemeralAnchor,
+				idx)
+		}
+		anchorIdx = idx
+	}
+
+	switch {
+	case anchorIdx == -1:
+		return 0, ErrNoEphemeralAnchor
+
+	case tx.Version != trucTxVersion:
+		return 0, fmt.Errorf("%w: version %d transaction isn't a TRUC "+
+			"transaction", ErrInvalidEphemeralAnchor, tx.Version)
+	}
+
+	return uint32(anchorIdx), nil
+}
+
+// SpendEphemeralAnchor creates a child transaction spending the ephemeral
+// anchor of the given parent transaction, which paid the given fee, along with
+// confirmed outputs of the account, such that both transactions together pay
+// the given fee rate. The child pays its change back to the account. As a
+// TRUC transaction spending an unconfirmed one, its virtual size is limited to
+// 1000 vbytes, and only confirmed wallet outputs are selected for it to have
+// no other unconfirmed ancestor. The child is returned signed, but isn't
+// published. The wallet outputs it spends are leased to AnchorSpendLockID for
+// ten minutes, so that they aren't selected elsewhere before it's published,
+// and should be released through ReleaseOutput if it won't be.
+func (w *Wallet) SpendEphemeralAnchor(parent *wire.MsgTx,
+	parentFee btcutil.Amount, keyScope *waddrmgr.KeyScope, account uint32,
+	feeSatPerKB btcutil.Amount) (*txauthor.AuthoredTx, error) {
+
+	anchorIdx, err := ephemeralAnchorIndex(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	chainClient, done, err := w.useChainClient()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	bs, err := chainClient.BlockStamp()
+	if err != nil {
+		return nil, err
+	}
+
+	child := wire.NewMsgTx(trucTxVersion)
+	var (
+		selected    []wtxmgr.Credit
+		prevScripts [][]byte
+		prevValues  []btcutil.Amount
+		total, fee  btcutil.Amount
+	)
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		_, changeSource, err := w.addrMgrWithChangeSource(
+			dbtx, keyScope, account,
+		)
+		if err != nil {
+			return err
+		}
+
+		eligible, err := w.findEligibleOutputs(
+			dbtx, keyScope, account, 1, bs,
+		)
+		if err != nil {
+			return err
+		}
+		sort.Sort(sort.Reverse(byAmount(eligible)))
+
+		changeScript, err := changeSource.NewScript()
+		if err != nil {
+			return err
+		}
+
+		// We'll add the largest outputs until they're able to pay for
+		// the fee deficit of the package, with change that isn't dust.
+		// The child must always pay for its own size at the fee rate.
+		parentSize := txVirtualSize(parent)
+		var (
+			p2wpkh, nested int
+			funded         bool
+		)
+		for _, credit := range eligible {
+			switch {
+			case txscript.IsPayToWitnessPubKeyHash(credit.PkScript):
+				p2wpkh++
+			case txscript.IsPayToScriptHash(credit.PkScript):
+				nested++
+			default:
+				continue
+			}
+			selected = append(selected, credit)
+			total += credit.Amount
+
+			childSize := txsizes.EstimateVirtualSize(
+				0, p2wpkh, nested, nil, changeSource.ScriptSize,
+			) + anchorInputVirtualSize
+			if childSize > maxTRUCChildVirtualSize {
+				return fmt.Errorf("%w: virtual size of %d "+
+					"exceeds %d for child of unconfirmed "+
+					"transaction", ErrTRUCViolation,
+					childSize, maxTRUCChildVirtualSize)
+			}
+
+			fee = txrules.FeeForSerializeSize(
+				feeSatPerKB, parentSize+childSize,
+			) - parentFee
+			minFee := txrules.FeeForSerializeSize(
+				feeSatPerKB, childSize,
+			)
+			if fee < minFee {
+				fee = minFee
+			}
+			change := wire.NewTxOut(int64(total-fee), changeScript)
+			if change.Value > 0 && !txrules.IsDustOutput(
+				change, txrules.DefaultRelayFeePerKb,
+			) {
+
+				funded = true
+				break
+			}
+		}
+		if !funded {
+			return ErrInsufficientAnchorFunds
+		}
+
+		// The child's fee is capped relative to the value of the
+		// outputs of the transaction it bumps.
+		ceiling := w.currentFeeCeiling()
+		err = ceiling.check(fee, txauthor.SumOutputValues(parent.TxOut))
+		if err != nil {
+			return err
+		}
+
+		// The anchor is spent by the last input, so that the
+		// previous outputs of the wallet's inputs line up with them.
+		// The wallet's inputs are leased along with the reservation of
+		// the change address.
+		txmgrNs := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+		for _, credit := range selected {
+			credit := credit
+			_, err := w.TxStore.LockOutput(
+				txmgrNs, AnchorSpendLockID, credit.OutPoint,
+				anchorSpendLeaseDuration,
+			)
+			if err != nil {
+				return err
+			}
+			child.AddTxIn(wire.NewTxIn(&credit.OutPoint, nil, nil))
+			prevScripts = append(prevScripts, credit.PkScript)
+			prevValues = append(prevValues, credit.Amount)
+		}
+		child.AddTxIn(wire.NewTxIn(
+			&wire.OutPoint{Hash: parent.TxHash(), Index: anchorIdx},
+			nil, nil,
+		))
+
+		child.AddTxOut(wire.NewTxOut(int64(total-fee), changeScript))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The leases are released if the child can't be signed.
+	releaseInputs := func() {
+		for _, credit := range selected {
+			err := w.ReleaseOutput(
+				AnchorSpendLockID, credit.OutPoint,
+			)
+			if err != nil {
+				log.Warnf("Unable to release output %v: %v",
+					credit.OutPoint, err)
+			}
+		}
+	}
+
+	// The anchor is keyless, so only the wallet's inputs need to be
+	// signed.
+	sigHashes := txscript.NewTxSigHashes(child)
+	for idx, pkScript := range prevScripts {
+		witness, sigScript, err := w.ComputeInputScript(
+			child, wire.NewTxOut(int64(prevValues[idx]), pkScript),
+			idx, sigHashes, txscript.SigHashAll, nil,
+		)
+		if err != nil {
+			releaseInputs()
+			return nil, err
+		}
+		child.TxIn[idx].Witness = witness
+		child.TxIn[idx].SignatureScript = sigScript
+	}
+	if err := validateMsgTx(child, prevScripts, prevValues); err != nil {
+		releaseInputs()
+		return nil, err
+	}
+
+	return &txauthor.AuthoredTx{
+		Tx: child,
+		PrevScripts: append(
+			prevScripts, parent.TxOut[anchorIdx].PkScript,
+		),
+		PrevInputValues: append(prevValues, 0),
+		TotalInput:      total,
+		ChangeIndex:     0,
+	}, nil
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEphemeralAnchor ensures that a TRUC transaction with an ephemeral anchor
+// can be created without a fee, and that its anchor can then be spent by a
+// child bringing the fee rate of the package up to the one requested.
+func TestEphemeralAnchor(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	fundWallet(t, w, 1000000)
+	fundWallet(t, w, 500000)
+	outputs := []*wire.TxOut{wire.NewTxOut(100000, testScriptP2WKH)}
+	anchorScript := EphemeralAnchorScript()
+
+	// The anchor must be the only zero-value output of a TRUC
+	// transaction.
+	_, err := w.CreateSimpleTx(
+		nil, 0, []*wire.TxOut{wire.NewTxOut(0, testScriptP2WKH)}, 1, 0,
+		CoinSelectionLargest, true, WithEphemeralAnchor(),
+	)
+	require.True(t, errors.Is(err, ErrInvalidEphemeralAnchor), err)
+
+	_, err = w.CreateSimpleTx(
+		nil, 0, outputs, 1, 0, CoinSelectionLargest, true,
+		WithEphemeralAnchor(), WithTxVersion(2),
+	)
+	require.True(t, errors.Is(err, ErrInvalidEphemeralAnchor), err)
+
+	// Create the parent without a fee, paying to an anchor alongside its
+	// outputs.
+	parent, err := w.CreateSimpleTx(
+		nil, 0, outputs, 1, 0, CoinSelectionLargest, false,
+		WithEphemeralAnchor(),
+	)
+	require.NoError(t, err)
+	require.EqualValues(t, trucTxVersion, parent.Tx.Version)
+	require.Len(t, parent.Tx.TxOut, 3)
+
+	var anchors int
+	for _, output := range parent.Tx.TxOut {
+		if output.Value == 0 {
+			require.Equal(t, anchorScript, output.PkScript)
+			anchors++
+		}
+	}
+	require.Equal(t, 1, anchors)
+
+	parentFee := parent.TotalInput -
+		txauthor.SumOutputValues(parent.Tx.TxOut)
+	require.Zero(t, parentFee)
+
+	// Record the parent as unconfirmed, such that the output it spends
+	// isn't selected by the child.
+	addUnminedTx(t, w, parent.Tx, uint32(parent.ChangeIndex))
+
+	// The anchor of a transaction without one can't be spent.
+	_, err = w.SpendEphemeralAnchor(
+		&wire.MsgTx{Version: trucTxVersion, TxOut: outputs}, 0, nil, 0,
+		10000,
+	)
+	require.True(t, errors.Is(err, ErrNoEphemeralAnchor), err)
+
+	// The child should spend the anchor along with the remaining confirmed
+	// output, paying for the whole package.
+	const feeRate = btcutil.Amount(10000)
+	child, err := w.SpendEphemeralAnchor(parent.Tx, parentFee, nil, 0,
+		feeRate)
+	require.NoError(t, err)
+	require.EqualValues(t, trucTxVersion, child.Tx.Version)
+	require.Len(t, child.Tx.TxIn, 2)
+	require.Len(t, child.Tx.TxOut, 1)
+
+	anchorIn := child.Tx.TxIn[1]
+	require.Equal(t, parent.Tx.TxHash(), anchorIn.PreviousOutPoint.Hash)
+	anchorOut := parent.Tx.TxOut[anchorIn.PreviousOutPoint.Index]
+	require.Equal(t, anchorScript, anchorOut.PkScript)
+	require.Empty(t, anchorIn.SignatureScript)
+	require.Empty(t, anchorIn.Witness)
+	for _, txIn := range parent.Tx.TxIn {
+		require.NotEqual(t, txIn.PreviousOutPoint,
+			child.Tx.TxIn[0].PreviousOutPoint)
+	}
+
+	childSize := txVirtualSize(child.Tx)
+	require.LessOrEqual(t, childSize, maxTRUCChildVirtualSize)
+
+	childFee := child.TotalInput - txauthor.SumOutputValues(child.Tx.TxOut)
+	packageSize := txVirtualSize(parent.Tx) + childSize
+	require.GreaterOrEqual(t, int64(parentFee+childFee)*1000,
+		int64(feeRate)*int64(packageSize))
+
+	// The wallet's input is leased until the child is published, so
+	// another child can't be funded in the meantime.
+	leases, err := w.ListLeasedOutputs()
+	require.NoError(t, err)
+	require.Len(t, leases, 1)
+	require.Equal(t, AnchorSpendLockID, leases[0].LockID)
+	require.Equal(t, child.Tx.TxIn[0].PreviousOutPoint, leases[0].Outpoint)
+
+	_, err = w.SpendEphemeralAnchor(parent.Tx, parentFee, nil, 0, feeRate)
+	require.True(t, errors.Is(err, ErrInsufficientAnchorFunds), err)
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/btcsuite/btcwallet/snacl"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+)
+
+const (
+	// backupVersion is the version of the encrypted backup format.
+	backupVersion = 1
+
+	// backupKeyParamsLen is the length of the marshalled parameters of the
+	// key an encrypted backup is encrypted with: its salt, digest, and
+	// scrypt parameters N, r and p.
+	backupKeyParamsLen = snacl.KeySize + sha256.Size + 24
+)
+
+var (
+	// backupMagic prefixes every encrypted backup, followed by the backup
+	// version, the marshalled parameters of the passphrase-derived key,
+	// and the encrypted database.
+	backupMagic = []byte("btcwbak")
+
+	// ErrBackupEncrypted is returned when restoring an encrypted backup
+	// without a passphrase.
+	ErrBackupEncrypted = errors.New("backup is encrypted")
+
+	// ErrBackupNotEncrypted is returned when restoring a plaintext backup
+	// with a passphrase.
+	ErrBackupNotEncrypted = errors.New("backup is not encrypted")
+
+	// ErrBackupPassphrase is returned when restoring an encrypted backup
+	// with the wrong passphrase.
+	ErrBackupPassphrase = errors.New("invalid backup passphrase")
+)
+
+// Backup writes a snapshot of the wallet database to w. If the passphrase is
+// empty, the snapshot is a plaintext copy of the database, which can be
+// opened as is. Otherwise, the snapshot is encrypted with a key derived from
+// the passphrase, such that it can be stored in untrusted locations, and must
+// be decrypted through RestoreFromBackup before use. The encryption is
+// authenticated, so a backup that has been tampered with fails to restore.
+func (w *Wallet) Backup(dst io.Writer, passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return w.db.Copy(dst)
+	}
+
+	var snapshot bytes.Buffer
+	if err := w.db.Copy(&snapshot); err != nil {
+		return err
+	}
+
+	opts := waddrmgr.DefaultScryptOptions
+	key, err := snacl.NewSecretKey(&passphrase, opts.N, opts.R, opts.P)
+	if err != nil {
+		return err
+	}
+	defer key.Zero()
+
+	encrypted, err := key.Encrypt(snapshot.Bytes())
+	if err != nil {
+		return err
+	}
+
+	var header bytes.Buffer
+	header.Write(backupMagic)
+	header.WriteByte(backupVersion)
+	header.Write(key.Marshal())
+	if _, err := dst.Write(header.Bytes()); err != nil {
+		return err
+	}
+	_, err = dst.Write(encrypted)
+	return err
+}
+
+// RestoreFromBackup writes the wallet database contained in a backup created
+// through Backup to dst, from which the wallet can then be opened. The
+// passphrase must be the one the backup was created with, which is empty for
+// plaintext backups.
+func RestoreFromBackup(src io.Reader, dst io.Writer, passphrase []byte) error {
+	backup, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+
+	encrypted := bytes.HasPrefix(backup, backupMagic)
+	switch {
+	case !encrypted && len(passphrase) == 0:
+		_, err := dst.Write(backup)
+		return err
+
+	case !encrypted:
+		return ErrBackupNotEncrypted
+
+	case len(passphrase) == 0:
+		return ErrBackupEncrypted
+	}
+
+	backup = backup[len(backupMagic):]
+	if len(backup) == 0 || backup[0] != backupVersion {
+		return errors.New("unknown backup version")
+	}
+	backup = backup[1:]
+
+	if len(backup) < backupKeyParamsLen {
+		return snacl.ErrMalformed
+	}
+
+	var key snacl.SecretKey
+	err = key.Unmarshal(backup[:backupKeyParamsLen])
+	if err != nil {
+		return err
+	}
+	err = key.DeriveKey(&passphrase)
+	if errors.Is(err, snacl.ErrInvalidPassword) {
+		return ErrBackupPassphrase
+	}
+	if err != nil {
+		return err
+	}
+	defer key.Zero()
+
+	snapshot, err := key.Decrypt(backup[backupKeyParamsLen:])
+	if err != nil {
+		return err
+	}
+
+	_, err = dst.Write(snapshot)
+	return err
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBackupRoundTrip ensures that a wallet restored from a backup, with or
+// without a passphrase, contains the same addresses as the original.
+func TestBackupRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+
+	// restore restores the backup into a new wallet directory, opening the
+	// wallet to ensure it knows the address.
+	restore := func(backup []byte, passphrase []byte) {
+		t.Helper()
+
+		dir, err := ioutil.TempDir("", "test_wallet_restore")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		var db bytes.Buffer
+		err = RestoreFromBackup(
+			bytes.NewReader(backup), &db, passphrase,
+		)
+		require.NoError(t, err)
+		err = ioutil.WriteFile(
+			filepath.Join(dir, WalletDBName), db.Bytes(), 0600,
+		)
+		require.NoError(t, err)
+
+		loader := NewLoader(
+			&chaincfg.TestNet3Params, dir, true, defaultDBTimeout,
+			250,
+		)
+		restored, err := loader.OpenExistingWallet(
+			[]byte("hello"), false,
+		)
+		require.NoError(t, err)
+		defer loader.UnloadWallet()
+
+		have, err := restored.HaveAddress(addr)
+		require.NoError(t, err)
+		require.True(t, have)
+	}
+
+	// A backup without a passphrase is a plaintext copy of the database.
+	var plaintext bytes.Buffer
+	require.NoError(t, w.Backup(&plaintext, nil))
+	require.False(t, bytes.HasPrefix(plaintext.Bytes(), backupMagic))
+	restore(plaintext.Bytes(), nil)
+
+	err = RestoreFromBackup(
+		bytes.NewReader(plaintext.Bytes()), ioutil.Discard,
+		[]byte("passphrase"),
+	)
+	require.True(t, errors.Is(err, ErrBackupNotEncrypted), err)
+
+	// A backup with a passphrase can only be restored with it.
+	passphrase := []byte("passphrase")
+	var encrypted bytes.Buffer
+	require.NoError(t, w.Backup(&encrypted, passphrase))
+	require.True(t, bytes.HasPrefix(encrypted.Bytes(), backupMagic))
+	restore(encrypted.Bytes(), passphrase)
+
+	err = RestoreFromBackup(
+		bytes.NewReader(encrypted.Bytes()), ioutil.Discard, nil,
+	)
+	require.True(t, errors.Is(err, ErrBackupEncrypted), err)
+
+	err = RestoreFromBackup(
+		bytes.NewReader(encrypted.Bytes()), ioutil.Discard,
+		[]byte("wrong"),
+	)
+	require.True(t, errors.Is(err, ErrBackupPassphrase), err)
+
+	// Tampering with an encrypted backup is detected.
+	tampered := append([]byte(nil), encrypted.Bytes()...)
+	tampered[len(tampered)-1] ^= 1
+	err = RestoreFromBackup(
+		bytes.NewReader(tampered), ioutil.Discard, passphrase,
+	)
+	require.Error(t, err)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// BalanceAtHeight returns the balance of the account as of the block at the
+// given height, i.e. the sum of the outputs credited to the account by blocks
+// up to and including it which were unspent at that point. Later blocks and
+// unconfirmed transactions are ignored. As the transactions of blocks
+// disconnected by reorgs are no longer recorded as mined, only the history of
+// the main chain is accounted for.
+func (w *Wallet) BalanceAtHeight(account uint32,
+	height int32) (btcutil.Amount, error) {
+
+	if height < 0 {
+		return 0, fmt.Errorf("invalid height %d", height)
+	}
+	if syncedTo := w.Manager.SyncedTo(); height > syncedTo.Height {
+		return 0, fmt.Errorf("height %d is above the synced height %d",
+			height, syncedTo.Height)
+	}
+
+	var balance btcutil.Amount
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		// inAccount returns whether the output script pays to the
+		// account, caching the result as scripts are often reused.
+		scriptAccounts := make(map[string]bool)
+		inAccount := func(pkScript []byte) bool {
+			ok, cached := scriptAccounts[string(pkScript)]
+			if cached {
+				return ok
+			}
+
+			_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+				pkScript, w.chainParams,
+			)
+			if err == nil && len(addrs) > 0 {
+				_, addrAcct, err := w.Manager.AddrAccount(
+					addrmgrNs, addrs[0],
+				)
+				ok = err == nil && addrAcct == account
+			}
+			scriptAccounts[string(pkScript)] = ok
+			return ok
+		}
+
+		// Replay the history of the main chain in order, tracking the
+		// account's unspent outputs. Within each block, its credits
+		// are applied before its debits, as a transaction may spend
+		// an output created by another one in the same block.
+		unspent := make(map[wire.OutPoint]btcutil.Amount)
+		replay := func(details []wtxmgr.TxDetails) (bool, error) {
+			for i := range details {
+				detail := &details[i]
+				txOuts := detail.MsgTx.TxOut
+				for _, credit := range detail.Credits {
+					txOut := txOuts[credit.Index]
+					if !inAccount(txOut.PkScript) {
+						continue
+					}
+					op := wire.OutPoint{
+						Hash:  detail.Hash,
+						Index: credit.Index,
+					}
+					unspent[op] = credit.Amount
+				}
+			}
+			for i := range details {
+				detail := &details[i]
+				for _, debit := range detail.Debits {
+					txIn := detail.MsgTx.TxIn[debit.Index]
+					delete(unspent, txIn.PreviousOutPoint)
+				}
+			}
+			return false, nil
+		}
+		err := w.TxStore.RangeTransactions(txmgrNs, 0, height, replay)
+		if err != nil {
+			return err
+		}
+
+		for _, amount := range unspent {
+			balance += amount
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
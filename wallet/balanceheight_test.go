@@ -0,0 +1,138 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// addMinedTx records the transaction as mined in a block at the given height,
+// crediting the wallet with the outputs at the given indexes.
+func addMinedTx(t *testing.T, w *Wallet, tx *wire.MsgTx, height int32,
+	credits ...uint32) {
+
+	t.Helper()
+
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+	require.NoError(t, err)
+	block := &wtxmgr.BlockMeta{
+		Block: wtxmgr.Block{
+			Hash:   chainhash.Hash{byte(height), byte(height >> 8)},
+			Height: height,
+		},
+		Time: time.Unix(1600000000+int64(height)*600, 0),
+	}
+
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		ns := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+		if err := w.TxStore.InsertTx(ns, rec, block); err != nil {
+			return err
+		}
+		for _, idx := range credits {
+			err := w.TxStore.AddCredit(ns, rec, block, idx, false)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// TestBalanceAtHeight ensures that the balance of an account as of a past
+// height only accounts for the credits and debits of blocks up to it.
+func TestBalanceAtHeight(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	const (
+		height       = 1000
+		creditAmount = 100000
+		changeAmount = 40000
+	)
+
+	// Credit the account at height N, with an output not belonging to the
+	// wallet alongside it.
+	creditTx := wire.NewMsgTx(wire.TxVersion)
+	creditTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	creditTx.AddTxOut(wire.NewTxOut(creditAmount, pkScript))
+	creditTx.AddTxOut(wire.NewTxOut(500000, testScriptP2WKH))
+	addMinedTx(t, w, creditTx, height, 0)
+
+	// Spend it at height N+5, paying change back to the account.
+	spendTx := wire.NewMsgTx(wire.TxVersion)
+	spendTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: creditTx.TxHash()}, nil, nil,
+	))
+	spendTx.AddTxOut(wire.NewTxOut(50000, testScriptP2WKH))
+	spendTx.AddTxOut(wire.NewTxOut(changeAmount, pkScript))
+	addMinedTx(t, w, spendTx, height+5, 1)
+
+	// Unconfirmed credits shouldn't be accounted for.
+	unminedTx := wire.NewMsgTx(wire.TxVersion)
+	unminedTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x02}}, nil, nil,
+	))
+	unminedTx.AddTxOut(wire.NewTxOut(70000, pkScript))
+	addUnminedTx(t, w, unminedTx, 0)
+
+	setSyncedHeight(t, w, height+10)
+
+	tests := []struct {
+		height  int32
+		balance btcutil.Amount
+	}{
+		{height - 1, 0},
+		{height, creditAmount},
+		{height + 2, creditAmount},
+		{height + 5, changeAmount},
+		{height + 10, changeAmount},
+	}
+	for _, test := range tests {
+		balance, err := w.BalanceAtHeight(0, test.height)
+		require.NoError(t, err)
+		require.Equal(t, test.balance, balance, "height %d",
+			test.height)
+	}
+
+	// Other accounts have no balance.
+	balance, err := w.BalanceAtHeight(1, height)
+	require.NoError(t, err)
+	require.Zero(t, balance)
+
+	// Heights the wallet hasn't synced to yet can't be queried.
+	_, err = w.BalanceAtHeight(0, height+11)
+	require.Error(t, err)
+
+	// Once the block of the spend is reorged away, it should no longer be
+	// accounted for.
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		ns := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+		return w.TxStore.Rollback(ns, height+5)
+	})
+	require.NoError(t, err)
+	balance, err = w.BalanceAtHeight(0, height+5)
+	require.NoError(t, err)
+	require.EqualValues(t, creditAmount, balance)
+}
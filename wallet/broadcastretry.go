@@ -0,0 +1,186 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/chain"
+)
+
+// DefaultBroadcastRetryBackoff is the default delay before the first retry of
+// a broadcast that failed due to a transient connection error.
+const DefaultBroadcastRetryBackoff = time.Second
+
+// ErrBroadcastOutcomeUnknown is returned when a transaction couldn't be
+// broadcast due to a transient connection error, even after retrying as
+// configured through SetBroadcastRetryPolicy. As the backend may have accepted
+// the transaction regardless, it's kept to be rebroadcast rather than removed
+// from the wallet.
+var ErrBroadcastOutcomeUnknown = errors.New("broadcast outcome unknown")
+
+// BroadcastStatus describes where a successfully published transaction is
+// known to be.
+type BroadcastStatus uint8
+
+const (
+	// BroadcastAccepted indicates that the backend accepted the
+	// transaction into its mempool.
+	BroadcastAccepted BroadcastStatus = iota
+
+	// BroadcastInMempool indicates that the transaction was already within
+	// the backend's mempool, such as when an earlier attempt whose
+	// response was lost had succeeded.
+	BroadcastInMempool
+
+	// BroadcastInChain indicates that the transaction was already
+	// confirmed.
+	BroadcastInChain
+)
+
+// String returns a human-readable name of the broadcast status.
+func (s BroadcastStatus) String() string {
+	switch s {
+	case BroadcastAccepted:
+		return "accepted"
+	case BroadcastInMempool:
+		return "in mempool"
+	case BroadcastInChain:
+		return "in chain"
+	default:
+		return fmt.Sprintf("unknown broadcast status %d", uint8(s))
+	}
+}
+
+// BroadcastResult is the result of successfully publishing a transaction.
+type BroadcastResult struct {
+	// Txid is the hash of the published transaction.
+	Txid chainhash.Hash
+
+	// Status is where the transaction is known to be as a result of the
+	// broadcast.
+	Status BroadcastStatus
+
+	// Attempts is the number of times the transaction was sent to the
+	// backend.
+	Attempts uint32
+}
+
+// BroadcastRetryPolicy determines how broadcasts failing due to transient
+// connection errors with the backend, such as timeouts, are retried. As such a
+// broadcast may have succeeded regardless, a retry finding the transaction
+// already within the mempool or the chain is reported as a success.
+type BroadcastRetryPolicy struct {
+	// MaxRetries is the number of times a broadcast is retried after its
+	// first attempt. Broadcasts aren't retried if it's zero.
+	MaxRetries uint32
+
+	// InitialBackoff is the delay before the first retry, which doubles
+	// with every following retry. If it's zero,
+	// DefaultBroadcastRetryBackoff is used.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between retries. The delay is uncapped if
+	// it's zero.
+	MaxBackoff time.Duration
+}
+
+// SetBroadcastRetryPolicy sets how broadcasts failing due to transient
+// connection errors are retried. By default, they aren't retried, and the
+// transaction is removed from the wallet as for any other failure.
+//
+// NOTE: This should be called before the wallet is used to publish any
+// transactions.
+func (w *Wallet) SetBroadcastRetryPolicy(policy BroadcastRetryPolicy) {
+	w.broadcastRetryPolicy = policy
+}
+
+// sendRawTransaction sends the transaction to the backend, retrying transient
+// connection errors according to the wallet's broadcast retry policy. The
+// number of attempts made is returned along with the error of the last one.
+func (w *Wallet) sendRawTransaction(chainClient chain.Interface,
+	tx *wire.MsgTx) (uint32, error) {
+
+	policy := w.broadcastRetryPolicy
+	backoff := policy.InitialBackoff
+	if backoff == 0 {
+		backoff = DefaultBroadcastRetryBackoff
+	}
+
+	for attempt := uint32(1); ; attempt++ {
+		_, err := chainClient.SendRawTransaction(tx, false)
+		if err == nil || !isTransientBroadcastErr(err) ||
+			attempt > policy.MaxRetries {
+
+			return attempt, err
+		}
+
+		log.Warnf("Unable to broadcast transaction %v (attempt %d), "+
+			"retrying in %v: %v", tx.TxHash(), attempt, backoff,
+			err)
+
+		select {
+		case <-time.After(backoff):
+		case <-w.quitChan():
+			return attempt, ErrWalletShuttingDown
+		}
+
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// isTransientBroadcastErr returns whether the broadcast error is due to the
+// connection with the backend, rather than the backend rejecting the
+// transaction, such that the backend may or may not have received it.
+func isTransientBroadcastErr(err error) bool {
+	// A transaction rejected by all endpoints of the chain client is only
+	// classified by the primary backend's error, as the errors of its
+	// broadcasters are joined into the message of a BroadcastError.
+	var broadcastErr *chain.BroadcastError
+	if errors.As(err, &broadcastErr) {
+		err = broadcastErr.Unwrap()
+		if err == nil {
+			return false
+		}
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr),
+		errors.Is(err, rpcclient.ErrClientDisconnect),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.EPIPE):
+
+		return true
+	}
+
+	// The backend may only return the message of the underlying error.
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{
+		"connection refused", "connection reset", "broken pipe",
+		"i/o timeout",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/stretchr/testify/require"
+)
+
+// timeoutError is a transport level timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// lossyChainClient is a mock chain client accepting the transactions sent
+// through it, whose responses to the first sends are lost, timing out.
+type lossyChainClient struct {
+	mockChainClient
+
+	timeouts  int
+	confirmed bool
+	sends     int
+}
+
+func (c *lossyChainClient) SendRawTransaction(tx *wire.MsgTx,
+	_ bool) (*chainhash.Hash, error) {
+
+	c.sends++
+	switch {
+	case c.sends <= c.timeouts:
+		return nil, timeoutError{}
+
+	// The transaction was accepted by the first send, regardless of its
+	// response being lost.
+	case c.sends > 1 && c.confirmed:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCTxAlreadyInChain,
+			Message: "Transaction already in block chain",
+		}
+	case c.sends > 1:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCTxRejected,
+			Message: "txn-already-in-mempool",
+		}
+	}
+
+	txHash := tx.TxHash()
+	return &txHash, nil
+}
+
+// TestPublishTransactionRetry ensures that broadcasts timing out are retried
+// according to the wallet's broadcast retry policy, reporting a retry finding
+// the transaction already accepted as a success.
+func TestPublishTransactionRetry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		policy    BroadcastRetryPolicy
+		timeouts  int
+		confirmed bool
+		status    BroadcastStatus
+		attempts  uint32
+		err       error
+		unmined   bool
+	}{
+		{
+			name:     "accepted",
+			policy:   BroadcastRetryPolicy{MaxRetries: 2},
+			status:   BroadcastAccepted,
+			attempts: 1,
+			unmined:  true,
+		},
+		{
+			name: "accepted into mempool on timeout",
+			policy: BroadcastRetryPolicy{
+				MaxRetries:     2,
+				InitialBackoff: time.Millisecond,
+			},
+			timeouts: 1,
+			status:   BroadcastInMempool,
+			attempts: 2,
+			unmined:  true,
+		},
+		{
+			name: "confirmed on timeout",
+			policy: BroadcastRetryPolicy{
+				MaxRetries:     2,
+				InitialBackoff: time.Millisecond,
+			},
+			timeouts:  2,
+			confirmed: true,
+			status:    BroadcastInChain,
+			attempts:  3,
+		},
+		{
+			name:     "no retries",
+			timeouts: 1,
+			attempts: 1,
+			err:      timeoutError{},
+		},
+		{
+			name: "retries exhausted",
+			policy: BroadcastRetryPolicy{
+				MaxRetries:     2,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     time.Millisecond,
+			},
+			timeouts: 3,
+			attempts: 3,
+			err:      ErrBroadcastOutcomeUnknown,
+			unmined:  true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			w, cleanup := testWallet(t)
+			defer cleanup()
+
+			chainClient := &lossyChainClient{
+				timeouts:  test.timeouts,
+				confirmed: test.confirmed,
+			}
+			w.chainClient = chainClient
+			w.SetBroadcastRetryPolicy(test.policy)
+
+			addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+			require.NoError(t, err)
+			pkScript, err := txscript.PayToAddrScript(addr)
+			require.NoError(t, err)
+			tx := wire.NewMsgTx(2)
+			tx.AddTxIn(&wire.TxIn{
+				PreviousOutPoint: wire.OutPoint{
+					Hash: chainhash.Hash{1},
+				},
+			})
+			tx.AddTxOut(wire.NewTxOut(100000, pkScript))
+
+			result, err := w.PublishTransactionWithResult(tx, "")
+			require.Equal(t, int(test.attempts), chainClient.sends)
+			if test.err != nil {
+				require.True(t, errors.Is(err, test.err), err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tx.TxHash(), result.Txid)
+				require.Equal(t, test.status, result.Status)
+				require.Equal(t, test.attempts, result.Attempts)
+			}
+
+			// Unless it's confirmed, the transaction is kept to be
+			// rebroadcast if retries were configured, as it may
+			// have been accepted.
+			var unmined bool
+			txHash := tx.TxHash()
+			view := func(tx walletdb.ReadTx) error {
+				ns := tx.ReadBucket(wtxmgrNamespaceKey)
+				details, err := w.TxStore.TxDetails(ns, &txHash)
+				unmined = details != nil
+				return err
+			}
+			require.NoError(t, walletdb.View(w.db, view))
+			require.Equal(t, test.unmined, unmined)
+		})
+	}
+}
+
+// TestIsTransientBroadcastErr ensures that only connection errors are deemed
+// transient, classifying a transaction rejected by all endpoints by the error
+// of the primary backend alone.
+func TestIsTransientBroadcastErr(t *testing.T) {
+	t.Parallel()
+
+	rejected := &btcjson.RPCError{
+		Code:    btcjson.ErrRPCTxRejected,
+		Message: "min relay fee not met",
+	}
+	refused := errors.New("dial tcp: connection refused")
+
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{
+			name:      "timeout",
+			err:       timeoutError{},
+			transient: true,
+		},
+		{
+			name:      "connection message",
+			err:       refused,
+			transient: true,
+		},
+		{
+			name: "rejected",
+			err:  rejected,
+		},
+		{
+			name: "primary rejected",
+			err: &chain.BroadcastError{
+				Errs: []error{rejected, refused},
+			},
+		},
+		{
+			name: "primary unreachable",
+			err: &chain.BroadcastError{
+				Errs: []error{refused, rejected},
+			},
+			transient: true,
+		},
+	}
+
+	for _, test := range tests {
+		require.Equal(
+			t, test.transient, isTransientBroadcastErr(test.err),
+			test.name,
+		)
+	}
+}
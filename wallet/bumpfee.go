@@ -0,0 +1,964 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"github.com/btcsuite/btcwallet/wallet/txsizes"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+var (
+	// ErrTxNotFound is returned when attempting to bump the fee of a
+	// transaction that is not known to the wallet.
+	ErrTxNotFound = errors.New("transaction not known to wallet")
+
+	// ErrTxAlreadyConfirmed is returned when attempting to bump the fee of
+	// a transaction that has already confirmed.
+	ErrTxAlreadyConfirmed = errors.New("transaction has already confirmed")
+
+	// ErrTxNotReplaceable is returned when attempting to bump the fee of a
+	// transaction through RBF that can't be replaced by the wallet.
+	ErrTxNotReplaceable = errors.New("transaction cannot be replaced")
+
+	// ErrNoCPFPOutput is returned when attempting to bump the fee of a
+	// transaction through CPFP that has no unspent output controlled by
+	// the wallet.
+	ErrNoCPFPOutput = errors.New("transaction has no unspent wallet " +
+		"output to spend")
+
+	// ErrTxNotBumpable is returned when attempting to bump the fee of a
+	// transaction through either RBF or CPFP that can't be replaced by
+	// the wallet and has no unspent output controlled by the wallet.
+	ErrTxNotBumpable = errors.New("transaction can neither be replaced " +
+		"nor spent by a child")
+
+	// ErrInsufficientBumpFunds is returned when the transaction created to
+	// bump the fee of another can't afford the higher fee, as the output
+	// it's deducted from is too small to cover it.
+	ErrInsufficientBumpFunds = errors.New("insufficient funds to pay " +
+		"the higher fee")
+
+	// ErrFeeExceedsCeiling is returned when the transaction created to
+	// bump the fee of another would pay a fee above the wallet's
+	// FeeCeiling.
+	ErrFeeExceedsCeiling = errors.New("fee exceeds ceiling")
+
+	// ErrInvalidFeeCeiling is returned when attempting to set an invalid
+	// fee ceiling.
+	ErrInvalidFeeCeiling = errors.New("invalid fee ceiling")
+)
+
+// DefaultFeeCeiling is the fee ceiling used by the wallet unless configured
+// otherwise through SetFeeCeiling.
+var DefaultFeeCeiling = FeeCeiling{
+	MaxPercent: 10,
+	MaxFee:     btcutil.Amount(1e6),
+}
+
+// FeeCeiling caps the fee of the transactions created to bump the fee of
+// another, such as replacements created by BumpTransactionFee or by fee
+// escalation, to prevent runaway fees. A fee must be within both caps.
+type FeeCeiling struct {
+	// MaxPercent is the maximum fee, as a percentage of the total value
+	// of the outputs of the transaction whose fee is bumped. A value of
+	// zero disables the cap.
+	MaxPercent float64
+
+	// MaxFee is the maximum absolute fee. A value of zero disables the
+	// cap.
+	MaxFee btcutil.Amount
+}
+
+// check returns an error wrapping ErrFeeExceedsCeiling if the fee exceeds the
+// ceiling for a transaction with the given total output value.
+func (c *FeeCeiling) check(fee, outputValue btcutil.Amount) error {
+	if c.MaxFee > 0 && fee > c.MaxFee {
+		return fmt.Errorf("%w: fee of %v exceeds the maximum of %v",
+			ErrFeeExceedsCeiling, fee, c.MaxFee)
+	}
+	if c.MaxPercent > 0 {
+		maxFee := btcutil.Amount(
+			float64(outputValue) * c.MaxPercent / 100,
+		)
+		if fee > maxFee {
+			return fmt.Errorf("%w: fee of %v exceeds %v%% of the "+
+				"output value of %v", ErrFeeExceedsCeiling, fee,
+				c.MaxPercent, outputValue)
+		}
+	}
+
+	return nil
+}
+
+// SetFeeCeiling sets the ceiling for the fees of the transactions created to
+// bump the fee of another, either explicitly through BumpTransactionFee or
+// through fee escalation. Bumps exceeding it fail with an error wrapping
+// ErrFeeExceedsCeiling. By default, this is DefaultFeeCeiling.
+func (w *Wallet) SetFeeCeiling(ceiling FeeCeiling) error {
+	if ceiling.MaxPercent < 0 || ceiling.MaxPercent > 100 {
+		return fmt.Errorf("%w: percentage must be between 0 and 100",
+			ErrInvalidFeeCeiling)
+	}
+	if ceiling.MaxFee < 0 {
+		return fmt.Errorf("%w: max fee must not be negative",
+			ErrInvalidFeeCeiling)
+	}
+
+	w.feeEscalationMtx.Lock()
+	w.feeCeiling = ceiling
+	w.feeEscalationMtx.Unlock()
+
+	return nil
+}
+
+// currentFeeCeiling returns the current fee ceiling.
+func (w *Wallet) currentFeeCeiling() FeeCeiling {
+	w.feeEscalationMtx.Lock()
+	defer w.feeEscalationMtx.Unlock()
+
+	return w.feeCeiling
+}
+
+// FeeBumpPolicy determines the mechanisms BumpTransactionFee may use to bump
+// the fee of a transaction.
+type FeeBumpPolicy uint8
+
+const (
+	// RBFOnly only allows bumping the fee of a transaction by replacing
+	// it, as described in BIP 125.
+	RBFOnly FeeBumpPolicy = iota
+
+	// CPFPOnly only allows bumping the fee of a transaction by spending
+	// one of its outputs with a child transaction paying for both.
+	CPFPOnly
+
+	// RBFThenCPFP attempts to replace the transaction, falling back to
+	// spending one of its outputs with a child transaction if it can't be
+	// replaced.
+	RBFThenCPFP
+)
+
+// FeeBumpMechanism is the mechanism used to bump the fee of a transaction.
+type FeeBumpMechanism uint8
+
+const (
+	// FeeBumpRBF indicates the transaction was replaced by a version of
+	// it paying a higher fee.
+	FeeBumpRBF FeeBumpMechanism = iota
+
+	// FeeBumpCPFP indicates a child transaction was created to pay for
+	// the transaction.
+	FeeBumpCPFP
+)
+
+// String returns a human readable representation of the mechanism.
+func (m FeeBumpMechanism) String() string {
+	switch m {
+	case FeeBumpRBF:
+		return "RBF"
+	case FeeBumpCPFP:
+		return "CPFP"
+	default:
+		return fmt.Sprintf("unknown mechanism %d", uint8(m))
+	}
+}
+
+// FeeBumpResult is the result of bumping the fee of a transaction.
+type FeeBumpResult struct {
+	// Tx is the transaction published to bump the fee. This is either
+	// the replacement transaction or the child transaction, depending on
+	// the mechanism used.
+	Tx *wire.MsgTx
+
+	// Mechanism is the mechanism used to bump the fee.
+	Mechanism FeeBumpMechanism
+
+	// Fee is the fee paid by Tx.
+	Fee btcutil.Amount
+
+	// Published contains every transaction published to bump the fee, in
+	// the order they were published. When the transaction is replaced
+	// along with its unconfirmed ancestors, this includes the replacement
+	// of each ancestor followed by Tx.
+	Published []*wire.MsgTx
+
+	// Replaced contains the hashes of every transaction replaced, in the
+	// same order as their replacements in Published.
+	Replaced []chainhash.Hash
+}
+
+// mempoolAncestorSource is implemented by chain backends able to retrieve the
+// unconfirmed ancestors of a mempool transaction, such as bitcoind.
+type mempoolAncestorSource interface {
+	GetMempoolAncestors(*chainhash.Hash) (
+		map[chainhash.Hash]*btcjson.GetMempoolEntryResult, error)
+}
+
+// incrementalRelayFeeSource is implemented by chain backends able to report
+// the incremental relay fee of their mempool, such as bitcoind.
+type incrementalRelayFeeSource interface {
+	GetIncrementalRelayFee() (btcutil.Amount, error)
+}
+
+// BumpTransactionFee bumps the fee of the unconfirmed wallet transaction with
+// the given hash to the given fee rate, expressed in sat/kb, using the
+// mechanisms allowed by the policy. Replacing a transaction requires it to
+// signal replaceability, to spend only wallet outputs, and to have a change
+// output the additional fee can be deducted from. Spending a child requires
+// the transaction to have an unspent output controlled by the wallet. The
+// resulting transactions are published before being returned.
+//
+// If the chain backend reports unconfirmed ancestors of the transaction, such
+// as bitcoind through getmempoolancestors, the fee of the whole package is
+// bumped to the given fee rate. Replacing the transaction then requires its
+// ancestors to be replaceable wallet transactions as well, with each of those
+// paying less than the fee rate replaced along with it. Otherwise, the child
+// pays for the fee deficit of the whole package.
+//
+// When the fee can't be bumped, the reason is reported through the returned
+// error, which matches one of ErrTxAlreadyConfirmed, ErrTxNotReplaceable,
+// ErrNoCPFPOutput, ErrTxNotBumpable when neither mechanism allowed by
+// RBFThenCPFP applies, ErrInsufficientBumpFunds or ErrFeeExceedsCeiling
+// through errors.Is.
+func (w *Wallet) BumpTransactionFee(txHash chainhash.Hash,
+	feeSatPerKB btcutil.Amount, policy FeeBumpPolicy) (*FeeBumpResult,
+	error) {
+
+	var details *wtxmgr.TxDetails
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		var err error
+		details, err = w.TxStore.TxDetails(txmgrNs, &txHash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if details == nil {
+		return nil, ErrTxNotFound
+	}
+	if details.Block.Height != -1 {
+		return nil, ErrTxAlreadyConfirmed
+	}
+
+	ancestors, err := w.mempoolAncestors(&txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *FeeBumpResult
+	switch policy {
+	case RBFOnly:
+		result, err = w.bumpFeeRBF(details, ancestors, feeSatPerKB)
+
+	case CPFPOnly:
+		result, err = w.bumpFeeCPFP(details, ancestors, feeSatPerKB)
+
+	case RBFThenCPFP:
+		result, err = w.bumpFeeRBF(details, ancestors, feeSatPerKB)
+		if !errors.Is(err, ErrTxNotReplaceable) {
+			break
+		}
+
+		log.Debugf("Unable to replace transaction %v, falling back "+
+			"to CPFP: %v", txHash, err)
+
+		rbfErr := err
+		result, err = w.bumpFeeCPFP(details, ancestors, feeSatPerKB)
+		if errors.Is(err, ErrNoCPFPOutput) {
+			return nil, fmt.Errorf("%w: %v", ErrTxNotBumpable,
+				rbfErr)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown fee bump policy %d", policy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// With the transactions created, we'll publish them in order, such
+	// that ancestors are published before their descendants. This also
+	// adds them to the wallet's store as unconfirmed transactions. Each
+	// replacement is recorded as soon as it's published, such that the
+	// store reflects the replacements accepted by the backend even if a
+	// later one fails.
+	for i, tx := range result.Published {
+		if err := w.PublishTransaction(tx, ""); err != nil {
+			return nil, err
+		}
+		if i >= len(result.Replaced) {
+			continue
+		}
+
+		err := w.recordTxReplacement(result.Replaced[i], tx.TxHash())
+		if err != nil {
+			return nil, err
+		}
+		w.notifyTxReplaced(result.Replaced[i], tx.TxHash())
+	}
+
+	return result, nil
+}
+
+// recordTxReplacement records the replacement of the transaction with the
+// given hash. As a replaced transaction can no longer confirm, it's removed
+// from the store along with any transactions spending from it, which may have
+// already been removed along with a replaced ancestor.
+func (w *Wallet) recordTxReplacement(replacedHash,
+	replacementHash chainhash.Hash) error {
+
+	return walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		txmgrNs := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+
+		err := w.TxStore.PutTxReplacement(
+			txmgrNs, replacedHash, replacementHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		replaced, err := w.TxStore.TxDetails(txmgrNs, &replacedHash)
+		if err != nil {
+			return err
+		}
+		if replaced == nil {
+			return nil
+		}
+
+		return w.TxStore.DiscardUnminedTx(txmgrNs, &replaced.TxRecord)
+	})
+}
+
+// mempoolAncestors returns the mempool entries of the unconfirmed ancestors of
+// the transaction with the given hash, keyed by their hash. No ancestors are
+// returned if the chain backend is unable to retrieve them.
+func (w *Wallet) mempoolAncestors(txHash *chainhash.Hash) (
+	map[chainhash.Hash]*btcjson.GetMempoolEntryResult, error) {
+
+	chainClient, done, err := w.useChainClient()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	source, ok := chainClient.(mempoolAncestorSource)
+	if !ok {
+		return nil, nil
+	}
+	return source.GetMempoolAncestors(txHash)
+}
+
+// incrementalRelayFee returns the fee rate, in sat/kb, by which a replacement
+// must increase the fee paid for the transactions it replaces. It's reported
+// by the chain backend if able to, and otherwise defaults to
+// txrules.DefaultRelayFeePerKb, which matches bitcoind's default.
+func (w *Wallet) incrementalRelayFee() (btcutil.Amount, error) {
+	chainClient, done, err := w.useChainClient()
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	source, ok := chainClient.(incrementalRelayFeeSource)
+	if !ok {
+		return txrules.DefaultRelayFeePerKb, nil
+	}
+	feeRate, err := source.GetIncrementalRelayFee()
+	if err != nil {
+		return 0, err
+	}
+	if feeRate <= 0 {
+		return txrules.DefaultRelayFeePerKb, nil
+	}
+	return feeRate, nil
+}
+
+// minReplacementFee returns the minimum fee of a replacement of the given
+// virtual size paying the given fee rate, which must exceed the fee of the
+// transactions it evicts by at least the incremental relay fee for its own
+// size, as required by BIP 125.
+func minReplacementFee(evictedFee, feeSatPerKB,
+	incrementalFeeSatPerKB btcutil.Amount, vsize int) btcutil.Amount {
+
+	fee := txrules.FeeForSerializeSize(feeSatPerKB, vsize)
+	minFee := evictedFee + txrules.FeeForSerializeSize(
+		incrementalFeeSatPerKB, vsize,
+	)
+	if fee < minFee {
+		return minFee
+	}
+	return fee
+}
+
+// signalsReplacement returns whether the transaction signals replaceability
+// as described in BIP 125.
+func signalsReplacement(tx *wire.MsgTx) bool {
+	for _, txIn := range tx.TxIn {
+		if txIn.Sequence < wire.MaxTxInSequenceNum-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// txVirtualSize returns the virtual size of the transaction.
+func txVirtualSize(tx *wire.MsgTx) int {
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+	return int((weight + blockchain.WitnessScaleFactor - 1) /
+		blockchain.WitnessScaleFactor)
+}
+
+// txFee returns the fee paid by the transaction, and whether it could be
+// determined. The fee can only be determined if the wallet knows the values
+// of all the outputs spent by the transaction.
+func txFee(details *wtxmgr.TxDetails) (btcutil.Amount, bool) {
+	if len(details.Debits) != len(details.MsgTx.TxIn) {
+		return 0, false
+	}
+
+	var totalIn btcutil.Amount
+	for _, debit := range details.Debits {
+		totalIn += debit.Amount
+	}
+	return totalIn - txauthor.SumOutputValues(details.MsgTx.TxOut), true
+}
+
+// replaceableChangeIndex returns the index of the change output of the
+// transaction described by details, if the wallet is able to replace it. An
+// error wrapping ErrTxNotReplaceable is returned otherwise.
+func replaceableChangeIndex(details *wtxmgr.TxDetails) (int, error) {
+	if !signalsReplacement(&details.MsgTx) {
+		return 0, fmt.Errorf("%w: transaction does not signal "+
+			"replaceability", ErrTxNotReplaceable)
+	}
+
+	if _, ok := txFee(details); !ok {
+		return 0, fmt.Errorf("%w: transaction spends outputs not "+
+			"controlled by the wallet", ErrTxNotReplaceable)
+	}
+
+	for _, credit := range details.Credits {
+		if credit.Change {
+			return int(credit.Index), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: transaction has no change output",
+		ErrTxNotReplaceable)
+}
+
+// bumpFeeRBF creates a replacement of the transaction described by details
+// paying the given fee rate, with the additional fee deducted from its change
+// output. If the fee rate only slightly exceeds that of the transaction, the
+// replacement pays more, such that it increases the fee by at least the
+// incremental relay fee as required by BIP 125. If the transaction has
+// unconfirmed ancestors, they're replaced along with it. An error wrapping
+// ErrTxNotReplaceable is returned if the wallet is unable to replace the
+// transaction.
+func (w *Wallet) bumpFeeRBF(details *wtxmgr.TxDetails,
+	ancestors map[chainhash.Hash]*btcjson.GetMempoolEntryResult,
+	feeSatPerKB btcutil.Amount) (*FeeBumpResult, error) {
+
+	incrementalFee, err := w.incrementalRelayFee()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ancestors) > 0 {
+		return w.bumpPackageFeeRBF(
+			details, ancestors, feeSatPerKB, incrementalFee,
+		)
+	}
+
+	changeIndex, err := replaceableChangeIndex(details)
+	if err != nil {
+		return nil, err
+	}
+	oldFee, _ := txFee(details)
+
+	vsize := txVirtualSize(&details.MsgTx)
+	newFee := txrules.FeeForSerializeSize(feeSatPerKB, vsize)
+	if newFee <= oldFee {
+		return nil, fmt.Errorf("fee of %v at the requested fee rate "+
+			"does not exceed the current fee of %v", newFee, oldFee)
+	}
+	newFee = minReplacementFee(oldFee, feeSatPerKB, incrementalFee, vsize)
+
+	replacement, err := w.createReplacement(
+		details, changeIndex, newFee, nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FeeBumpResult{
+		Tx:        replacement,
+		Mechanism: FeeBumpRBF,
+		Fee:       newFee,
+		Published: []*wire.MsgTx{replacement},
+		Replaced:  []chainhash.Hash{details.Hash},
+	}, nil
+}
+
+// bumpPackageFeeRBF replaces the transaction described by details along with
+// those of its unconfirmed ancestors paying less than the given fee rate, such
+// that every transaction of the package pays at least the fee rate. All of
+// the ancestors must be replaceable wallet transactions. Each replacement
+// increases the fee of the transactions it evicts by at least the given
+// incremental relay fee rate.
+func (w *Wallet) bumpPackageFeeRBF(details *wtxmgr.TxDetails,
+	ancestors map[chainhash.Hash]*btcjson.GetMempoolEntryResult,
+	feeSatPerKB, incrementalFeeSatPerKB btcutil.Amount) (*FeeBumpResult,
+	error) {
+
+	pkg := []*wtxmgr.TxDetails{details}
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		for hash := range ancestors {
+			hash := hash
+			ancestor, err := w.TxStore.TxDetails(txmgrNs, &hash)
+			if err != nil {
+				return err
+			}
+			if ancestor == nil || ancestor.Block.Height != -1 {
+				return fmt.Errorf("%w: ancestor %v is not an "+
+					"unconfirmed wallet transaction",
+					ErrTxNotReplaceable, hash)
+			}
+			pkg = append(pkg, ancestor)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	pkg = sortPackage(pkg)
+
+	var (
+		changeIndexes = make(map[chainhash.Hash]int, len(pkg))
+		fees          = make(map[chainhash.Hash]btcutil.Amount, len(pkg))
+		pkgFee        btcutil.Amount
+		pkgSize       int
+	)
+	for _, d := range pkg {
+		changeIndex, err := replaceableChangeIndex(d)
+		if err != nil {
+			return nil, fmt.Errorf("unable to replace %v: %w",
+				d.Hash, err)
+		}
+		changeIndexes[d.Hash] = changeIndex
+
+		fee, _ := txFee(d)
+		fees[d.Hash] = fee
+		pkgFee += fee
+		pkgSize += txVirtualSize(&d.MsgTx)
+	}
+
+	newPkgFee := txrules.FeeForSerializeSize(feeSatPerKB, pkgSize)
+	if newPkgFee <= pkgFee {
+		return nil, fmt.Errorf("fee of %v at the requested fee rate "+
+			"does not exceed the current package fee of %v",
+			newPkgFee, pkgFee)
+	}
+
+	var (
+		result = &FeeBumpResult{
+			Mechanism: FeeBumpRBF,
+		}
+		replacements = make(map[chainhash.Hash]*wire.MsgTx, len(pkg))
+		evicted      = make(map[chainhash.Hash]struct{}, len(pkg))
+	)
+	for _, d := range pkg {
+		vsize := txVirtualSize(&d.MsgTx)
+		fee := txrules.FeeForSerializeSize(feeSatPerKB, vsize)
+
+		var spendsReplaced bool
+		for _, txIn := range d.MsgTx.TxIn {
+			prevHash := txIn.PreviousOutPoint.Hash
+			if _, ok := replacements[prevHash]; ok {
+				spendsReplaced = true
+				break
+			}
+		}
+
+		// Transactions spending a replaced transaction must be
+		// replaced as well, while others can be kept if they already
+		// pay the fee rate.
+		if !spendsReplaced && fees[d.Hash] >= fee {
+			continue
+		}
+
+		// Unless it was already evicted from the mempool along with a
+		// replaced ancestor, the replacement must pay for the evicted
+		// transaction and its descendants, along with its own relay
+		// fee, as required by BIP 125.
+		if !spendsReplaced {
+			var evictedFee btcutil.Amount
+			for _, hash := range packageDescendants(pkg, d.Hash) {
+				if _, ok := evicted[hash]; ok {
+					continue
+				}
+				evicted[hash] = struct{}{}
+				evictedFee += fees[hash]
+			}
+
+			fee = minReplacementFee(
+				evictedFee, feeSatPerKB, incrementalFeeSatPerKB,
+				vsize,
+			)
+		}
+
+		replacement, err := w.createReplacement(
+			d, changeIndexes[d.Hash], fee, replacements,
+		)
+		if err != nil {
+			return nil, err
+		}
+		replacements[d.Hash] = replacement
+
+		result.Published = append(result.Published, replacement)
+		result.Replaced = append(result.Replaced, d.Hash)
+		if d.Hash == details.Hash {
+			result.Tx = replacement
+			result.Fee = fee
+		}
+	}
+
+	return result, nil
+}
+
+// sortPackage sorts the transactions of a package such that each of them
+// follows its ancestors within the package.
+func sortPackage(pkg []*wtxmgr.TxDetails) []*wtxmgr.TxDetails {
+	inPkg := make(map[chainhash.Hash]struct{}, len(pkg))
+	for _, d := range pkg {
+		inPkg[d.Hash] = struct{}{}
+	}
+
+	sorted := make([]*wtxmgr.TxDetails, 0, len(pkg))
+	added := make(map[chainhash.Hash]struct{}, len(pkg))
+	for len(sorted) < len(pkg) {
+		for _, d := range pkg {
+			if _, ok := added[d.Hash]; ok {
+				continue
+			}
+
+			ready := true
+			for _, txIn := range d.MsgTx.TxIn {
+				prevHash := txIn.PreviousOutPoint.Hash
+				_, isAncestor := inPkg[prevHash]
+				_, isAdded := added[prevHash]
+				if isAncestor && !isAdded {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+
+			sorted = append(sorted, d)
+			added[d.Hash] = struct{}{}
+		}
+	}
+
+	return sorted
+}
+
+// packageDescendants returns the hash of the transaction with the given hash
+// along with those of its descendants within the sorted package.
+func packageDescendants(pkg []*wtxmgr.TxDetails,
+	txHash chainhash.Hash) []chainhash.Hash {
+
+	descendants := []chainhash.Hash{txHash}
+	isDescendant := map[chainhash.Hash]struct{}{txHash: {}}
+	for _, d := range pkg {
+		if _, ok := isDescendant[d.Hash]; ok {
+			continue
+		}
+
+		for _, txIn := range d.MsgTx.TxIn {
+			prevHash := txIn.PreviousOutPoint.Hash
+			if _, ok := isDescendant[prevHash]; ok {
+				descendants = append(descendants, d.Hash)
+				isDescendant[d.Hash] = struct{}{}
+				break
+			}
+		}
+	}
+
+	return descendants
+}
+
+// createReplacement creates a replacement of the transaction described by
+// details paying the given fee, with the difference deducted from its change
+// output. Inputs spending a transaction found in replacements are updated to
+// spend its replacement instead.
+func (w *Wallet) createReplacement(details *wtxmgr.TxDetails, changeIndex int,
+	fee btcutil.Amount,
+	replacements map[chainhash.Hash]*wire.MsgTx) (*wire.MsgTx, error) {
+
+	replacement := details.MsgTx.Copy()
+
+	// The previous input scripts are no longer valid with the modified
+	// change output, so we'll need to sign the transaction again.
+	prevValues := make([]btcutil.Amount, len(replacement.TxIn))
+	for _, debit := range details.Debits {
+		prevValues[debit.Index] = debit.Amount
+	}
+	for _, txIn := range replacement.TxIn {
+		txIn.SignatureScript = nil
+		txIn.Witness = nil
+	}
+
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		prevScripts, err := w.TxStore.PreviousPkScripts(
+			txmgrNs, &details.TxRecord, nil,
+		)
+		if err != nil {
+			return err
+		}
+		if len(prevScripts) != len(replacement.TxIn) {
+			return fmt.Errorf("%w: transaction spends outputs "+
+				"not controlled by the wallet",
+				ErrTxNotReplaceable)
+		}
+
+		for i, txIn := range replacement.TxIn {
+			prevOut := &txIn.PreviousOutPoint
+			parent, ok := replacements[prevOut.Hash]
+			if !ok {
+				continue
+			}
+
+			prevOut.Hash = parent.TxHash()
+			prevScripts[i] = parent.TxOut[prevOut.Index].PkScript
+			prevValues[i] = btcutil.Amount(
+				parent.TxOut[prevOut.Index].Value,
+			)
+		}
+
+		var totalIn btcutil.Amount
+		for _, value := range prevValues {
+			totalIn += value
+		}
+		oldFee := totalIn - txauthor.SumOutputValues(replacement.TxOut)
+
+		change := replacement.TxOut[changeIndex]
+		oldChange := btcutil.Amount(change.Value)
+		change.Value -= int64(fee - oldFee)
+		if change.Value < 0 ||
+			txrules.IsDustOutput(change, txrules.DefaultRelayFeePerKb) {
+
+			return fmt.Errorf("%w: change output of %v is "+
+				"unable to cover the additional fee of %v",
+				ErrInsufficientBumpFunds, oldChange,
+				fee-oldFee)
+		}
+
+		// The replacement pays in fees what it no longer pays to its
+		// change output, so its fee is capped relative to the value
+		// of the outputs of the original.
+		ceiling := w.currentFeeCeiling()
+		err = ceiling.check(fee, txauthor.SumOutputValues(
+			details.MsgTx.TxOut,
+		))
+		if err != nil {
+			return err
+		}
+
+		err = txauthor.AddAllInputScripts(
+			replacement, prevScripts, prevValues,
+			secretSource{w.Manager, addrmgrNs},
+		)
+		if err != nil {
+			return err
+		}
+
+		return validateMsgTx(replacement, prevScripts, prevValues)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return replacement, nil
+}
+
+// bumpFeeCPFP creates a child transaction spending the largest unspent wallet
+// output of the transaction described by details, such that both transactions
+// together pay the given fee rate. If the transaction has unconfirmed
+// ancestors, the child also pays for their fee deficit, such that the whole
+// package pays the fee rate. If the fee paid by the parent can't be
+// determined, the child pays for its size by itself.
+func (w *Wallet) bumpFeeCPFP(details *wtxmgr.TxDetails,
+	ancestors map[chainhash.Hash]*btcjson.GetMempoolEntryResult,
+	feeSatPerKB btcutil.Amount) (*FeeBumpResult, error) {
+
+	var credit *wtxmgr.CreditRecord
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		for i := range details.Credits {
+			c := &details.Credits[i]
+			if c.Spent {
+				continue
+			}
+			op := wire.OutPoint{Hash: details.Hash, Index: c.Index}
+			pkScript := details.MsgTx.TxOut[c.Index].PkScript
+			if w.LockedOutpoint(op) ||
+				w.TxStore.IsFrozenOutput(txmgrNs, op) ||
+				w.TxStore.IsSelectionDenylisted(
+					txmgrNs, op, pkScript,
+				) {
+
+				continue
+			}
+			if credit == nil || c.Amount > credit.Amount {
+				credit = c
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if credit == nil {
+		return nil, ErrNoCPFPOutput
+	}
+
+	prevOut := details.MsgTx.TxOut[credit.Index]
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+		prevOut.PkScript, w.chainParams,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) != 1 {
+		return nil, ErrNoCPFPOutput
+	}
+
+	child := wire.NewMsgTx(wire.TxVersion)
+	child.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: details.Hash, Index: credit.Index}, nil,
+		nil,
+	))
+
+	var fee btcutil.Amount
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		addrmgrNs := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
+
+		scopedMgr, account, err := w.Manager.AddrAccount(
+			addrmgrNs, addrs[0],
+		)
+		if err != nil {
+			return err
+		}
+		keyScope := scopedMgr.Scope()
+
+		_, changeSource, err := w.addrMgrWithChangeSource(
+			dbtx, &keyScope, account,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Determine the fee required for the child to bring the fee
+		// rate of the package up to the one requested. The child must
+		// always pay for its own size at the fee rate.
+		var p2pkh, p2wpkh, nested int
+		switch {
+		case txscript.IsPayToScriptHash(prevOut.PkScript):
+			nested = 1
+		case txscript.IsPayToWitnessPubKeyHash(prevOut.PkScript):
+			p2wpkh = 1
+		default:
+			p2pkh = 1
+		}
+		childSize := txsizes.EstimateVirtualSize(
+			p2pkh, p2wpkh, nested, nil, changeSource.ScriptSize,
+		)
+		parentSize := txVirtualSize(&details.MsgTx)
+		parentFee, _ := txFee(details)
+		for _, ancestor := range ancestors {
+			ancestorFee, err := btcutil.NewAmount(ancestor.Fees.Base)
+			if err != nil {
+				return err
+			}
+			parentSize += int(ancestor.VSize)
+			parentFee += ancestorFee
+		}
+
+		fee = txrules.FeeForSerializeSize(
+			feeSatPerKB, parentSize+childSize,
+		) - parentFee
+		minFee := txrules.FeeForSerializeSize(feeSatPerKB, childSize)
+		if fee < minFee {
+			fee = minFee
+		}
+
+		// The child's fee is capped relative to the value of the
+		// outputs of the transaction it bumps.
+		ceiling := w.currentFeeCeiling()
+		err = ceiling.check(fee, txauthor.SumOutputValues(
+			details.MsgTx.TxOut,
+		))
+		if err != nil {
+			return err
+		}
+
+		changeScript, err := changeSource.NewScript()
+		if err != nil {
+			return err
+		}
+		output := wire.NewTxOut(int64(credit.Amount-fee), changeScript)
+		if output.Value < 0 ||
+			txrules.IsDustOutput(output, txrules.DefaultRelayFeePerKb) {
+
+			return fmt.Errorf("%w: output of %v is unable to "+
+				"cover the child fee of %v",
+				ErrInsufficientBumpFunds, credit.Amount, fee)
+		}
+		child.AddTxOut(output)
+
+		prevScripts := [][]byte{prevOut.PkScript}
+		prevValues := []btcutil.Amount{credit.Amount}
+		err = txauthor.AddAllInputScripts(
+			child, prevScripts, prevValues,
+			secretSource{w.Manager, addrmgrNs},
+		)
+		if err != nil {
+			return err
+		}
+
+		return validateMsgTx(child, prevScripts, prevValues)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &FeeBumpResult{
+		Tx:        child,
+		Mechanism: FeeBumpCPFP,
+		Fee:       fee,
+		Published: []*wire.MsgTx{child},
+	}, nil
+}
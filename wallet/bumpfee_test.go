@@ -0,0 +1,794 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// addUnminedTx adds the transaction to the wallet as unconfirmed, marking the
+// given outputs as credits.
+func addUnminedTx(t *testing.T, w *Wallet, tx *wire.MsgTx,
+	credits ...uint32) *wtxmgr.TxRecord {
+
+	t.Helper()
+
+	var b bytes.Buffer
+	if err := tx.Serialize(&b); err != nil {
+		t.Fatalf("unable to serialize tx: %v", err)
+	}
+	rec, err := wtxmgr.NewTxRecord(b.Bytes(), time.Now())
+	if err != nil {
+		t.Fatalf("unable to create tx record: %v", err)
+	}
+
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		ns := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+		if err := w.TxStore.InsertTx(ns, rec, nil); err != nil {
+			return err
+		}
+		for _, idx := range credits {
+			err := w.TxStore.AddCredit(ns, rec, nil, idx, false)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed inserting tx: %v", err)
+	}
+
+	return rec
+}
+
+// TestBumpTransactionFeeCPFPFallback ensures that the fee of a transaction not
+// signaling replaceability is bumped through CPFP when allowed by the policy,
+// and that a clear error is returned when the wallet owns none of its outputs.
+func TestBumpTransactionFeeCPFPFallback(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+
+	// Create a transaction that doesn't signal replaceability, spending
+	// an output foreign to the wallet and paying to one of its addresses.
+	const value = 100000
+	parent := wire.NewMsgTx(wire.TxVersion)
+	parent.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	parent.AddTxOut(wire.NewTxOut(value, pkScript))
+	parentRec := addUnminedTx(t, w, parent, 0)
+
+	// Replacing the transaction isn't possible, as it doesn't signal it.
+	const feeRate = btcutil.Amount(5000)
+	_, err = w.BumpTransactionFee(parentRec.Hash, feeRate, RBFOnly)
+	if !errors.Is(err, ErrTxNotReplaceable) {
+		t.Fatalf("expected ErrTxNotReplaceable, got: %v", err)
+	}
+
+	// Falling back to CPFP should result in a child spending the output
+	// owned by the wallet.
+	result, err := w.BumpTransactionFee(parentRec.Hash, feeRate, RBFThenCPFP)
+	if err != nil {
+		t.Fatalf("unable to bump fee: %v", err)
+	}
+	if result.Mechanism != FeeBumpCPFP {
+		t.Fatalf("expected mechanism %v, got %v", FeeBumpCPFP,
+			result.Mechanism)
+	}
+
+	child := result.Tx
+	if len(child.TxIn) != 1 ||
+		child.TxIn[0].PreviousOutPoint.Hash != parentRec.Hash ||
+		child.TxIn[0].PreviousOutPoint.Index != 0 {
+
+		t.Fatalf("expected child to spend %v:0, got %v",
+			parentRec.Hash, child.TxIn)
+	}
+	if len(child.TxOut) != 1 {
+		t.Fatalf("expected a single child output, got %d",
+			len(child.TxOut))
+	}
+	if btcutil.Amount(child.TxOut[0].Value)+result.Fee != value {
+		t.Fatalf("expected child output to be %v minus fee %v, got %v",
+			btcutil.Amount(value), result.Fee,
+			btcutil.Amount(child.TxOut[0].Value))
+	}
+
+	// As the fee of the parent is unknown, the child should pay for the
+	// size of both at the requested fee rate.
+	packageSize := txVirtualSize(parent) + txVirtualSize(child)
+	minFee := feeRate * btcutil.Amount(packageSize) / 1000
+	if result.Fee < minFee {
+		t.Fatalf("expected child fee of at least %v, got %v", minFee,
+			result.Fee)
+	}
+
+	// The child should have been added to the wallet.
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		childHash := child.TxHash()
+		details, err := w.TxStore.TxDetails(ns, &childHash)
+		if err != nil {
+			return err
+		}
+		if details == nil {
+			t.Fatal("expected child to be found within the wallet")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A transaction without any outputs owned by the wallet can't have
+	// its fee bumped through CPFP.
+	foreign := wire.NewMsgTx(wire.TxVersion)
+	foreign.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x02}}, nil, nil,
+	))
+	foreign.AddTxOut(wire.NewTxOut(value, []byte{txscript.OP_TRUE}))
+	foreignRec := addUnminedTx(t, w, foreign)
+
+	_, err = w.BumpTransactionFee(foreignRec.Hash, feeRate, CPFPOnly)
+	if err != ErrNoCPFPOutput {
+		t.Fatalf("expected ErrNoCPFPOutput, got: %v", err)
+	}
+
+	// Nor can it be replaced, so neither mechanism applies.
+	_, err = w.BumpTransactionFee(foreignRec.Hash, feeRate, RBFThenCPFP)
+	if !errors.Is(err, ErrTxNotBumpable) {
+		t.Fatalf("expected ErrTxNotBumpable, got: %v", err)
+	}
+}
+
+// mempoolEntries maps the hashes of mempool transactions to their entries.
+type mempoolEntries map[chainhash.Hash]*btcjson.GetMempoolEntryResult
+
+// ancestorChainClient is a mock chain client reporting the given mempool
+// ancestors of each transaction. If reject is set, transactions are rejected
+// once the given number of them has been accepted.
+type ancestorChainClient struct {
+	mockChainClient
+
+	ancestors map[chainhash.Hash]mempoolEntries
+	reject    bool
+	accept    int
+}
+
+func (c *ancestorChainClient) GetMempoolAncestors(txHash *chainhash.Hash) (
+	map[chainhash.Hash]*btcjson.GetMempoolEntryResult, error) {
+
+	return c.ancestors[*txHash], nil
+}
+
+func (c *ancestorChainClient) SendRawTransaction(tx *wire.MsgTx, _ bool) (
+	*chainhash.Hash, error) {
+
+	if c.reject {
+		if c.accept == 0 {
+			return nil, errors.New("transaction rejected")
+		}
+		c.accept--
+	}
+
+	txHash := tx.TxHash()
+	return &txHash, nil
+}
+
+// TestBumpTransactionFeePackage ensures that the fee of a transaction with an
+// unconfirmed wallet ancestor is bumped by replacing both, such that each of
+// them pays the requested fee rate, and that a child paying for the whole
+// package is created when an ancestor isn't controlled by the wallet.
+func TestBumpTransactionFeePackage(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	chainClient := &ancestorChainClient{
+		ancestors: make(map[chainhash.Hash]mempoolEntries),
+	}
+	w.chainClient = chainClient
+
+	newScript := func(change bool) []byte {
+		t.Helper()
+
+		var (
+			addr btcutil.Address
+			err  error
+		)
+		if change {
+			addr, err = w.NewChangeAddress(0, waddrmgr.KeyScopeBIP0084)
+		} else {
+			addr, err = w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+		}
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("unable to create pkScript: %v", err)
+		}
+		return pkScript
+	}
+	foreignAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), w.chainParams,
+	)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	foreignScript, err := txscript.PayToAddrScript(foreignAddr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+
+	// spendChange creates and adds to the wallet a signed transaction
+	// signaling replaceability, spending the given wallet output, paying
+	// to a foreign address, and returning the rest as change.
+	const (
+		payment = 100000
+		lowFee  = 200
+	)
+	spendChange := func(prevOut wire.OutPoint,
+		prevTxOut *wire.TxOut) *wire.MsgTx {
+
+		t.Helper()
+
+		tx := wire.NewMsgTx(wire.TxVersion)
+		txIn := wire.NewTxIn(&prevOut, nil, nil)
+		txIn.Sequence = wire.MaxTxInSequenceNum - 2
+		tx.AddTxIn(txIn)
+		tx.AddTxOut(wire.NewTxOut(payment, foreignScript))
+		tx.AddTxOut(wire.NewTxOut(
+			prevTxOut.Value-payment-lowFee, newScript(true),
+		))
+
+		err := walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+			addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+			err := txauthor.AddAllInputScripts(
+				tx, [][]byte{prevTxOut.PkScript},
+				[]btcutil.Amount{btcutil.Amount(prevTxOut.Value)},
+				secretSource{w.Manager, addrmgrNs},
+			)
+			if err != nil {
+				return err
+			}
+
+			rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+			if err != nil {
+				return err
+			}
+			return w.addRelevantTx(dbtx, rec, nil)
+		})
+		if err != nil {
+			t.Fatalf("unable to add tx: %v", err)
+		}
+		return tx
+	}
+
+	// Create a chain of two unconfirmed wallet transactions, with the
+	// child spending the change output of its parent.
+	funding := wire.NewMsgTx(wire.TxVersion)
+	funding.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	funding.AddTxOut(wire.NewTxOut(1000000, newScript(false)))
+	addUtxo(t, w, funding)
+
+	parent := spendChange(
+		wire.OutPoint{Hash: funding.TxHash()}, funding.TxOut[0],
+	)
+	child := spendChange(
+		wire.OutPoint{Hash: parent.TxHash(), Index: 1}, parent.TxOut[1],
+	)
+	chainClient.ancestors[child.TxHash()] = mempoolEntries{
+		parent.TxHash(): {
+			VSize: int32(txVirtualSize(parent)),
+			Fees: btcjson.MempoolFees{
+				Base: btcutil.Amount(lowFee).ToBTC(),
+			},
+		},
+	}
+
+	// Bumping the fee of the child should replace both transactions.
+	const feeRate = btcutil.Amount(10000)
+	result, err := w.BumpTransactionFee(child.TxHash(), feeRate, RBFOnly)
+	if err != nil {
+		t.Fatalf("unable to bump fee: %v", err)
+	}
+	if result.Mechanism != FeeBumpRBF {
+		t.Fatalf("expected mechanism %v, got %v", FeeBumpRBF,
+			result.Mechanism)
+	}
+	if len(result.Published) != 2 || len(result.Replaced) != 2 {
+		t.Fatalf("expected 2 transactions to be replaced, got %d "+
+			"replaced by %d", len(result.Replaced),
+			len(result.Published))
+	}
+	if result.Replaced[0] != parent.TxHash() ||
+		result.Replaced[1] != child.TxHash() {
+
+		t.Fatalf("expected %v and %v to be replaced, got %v",
+			parent.TxHash(), child.TxHash(), result.Replaced)
+	}
+
+	newParent, newChild := result.Published[0], result.Published[1]
+	if result.Tx != newChild {
+		t.Fatal("expected result transaction to be the child's " +
+			"replacement")
+	}
+	spent := newChild.TxIn[0].PreviousOutPoint
+	if spent.Hash != newParent.TxHash() || spent.Index != 1 {
+		t.Fatalf("expected child replacement to spend %v:1, got %v",
+			newParent.TxHash(), spent)
+	}
+
+	// Each replacement should pay the fee rate, with the parent's
+	// replacement also paying for the transactions it evicts.
+	parentFee := btcutil.Amount(funding.TxOut[0].Value) -
+		txauthor.SumOutputValues(newParent.TxOut)
+	childFee := btcutil.Amount(newParent.TxOut[1].Value) -
+		txauthor.SumOutputValues(newChild.TxOut)
+	minParentFee := txrules.FeeForSerializeSize(
+		feeRate, txVirtualSize(parent),
+	)
+	if parentFee < minParentFee || parentFee <= 2*lowFee {
+		t.Fatalf("expected parent replacement fee of at least %v, "+
+			"got %v", minParentFee, parentFee)
+	}
+	minChildFee := txrules.FeeForSerializeSize(
+		feeRate, txVirtualSize(child),
+	)
+	if childFee < minChildFee || result.Fee != childFee {
+		t.Fatalf("expected child replacement fee of at least %v, "+
+			"got %v with reported fee %v", minChildFee, childFee,
+			result.Fee)
+	}
+
+	// The original transactions should have been replaced within the
+	// wallet.
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		for _, tx := range []*wire.MsgTx{parent, child} {
+			txHash := tx.TxHash()
+			details, err := w.TxStore.TxDetails(ns, &txHash)
+			if err != nil {
+				return err
+			}
+			if details != nil {
+				t.Fatalf("expected %v to be removed", txHash)
+			}
+		}
+		for _, tx := range result.Published {
+			txHash := tx.TxHash()
+			details, err := w.TxStore.TxDetails(ns, &txHash)
+			if err != nil {
+				return err
+			}
+			if details == nil {
+				t.Fatalf("expected %v to be found", txHash)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each replacement should be recorded for the transaction it replaced.
+	for i, tx := range []*wire.MsgTx{parent, child} {
+		replacement, err := w.TxReplacement(tx.TxHash())
+		if err != nil {
+			t.Fatalf("unable to fetch replacement: %v", err)
+		}
+		if replacement == nil ||
+			*replacement != result.Published[i].TxHash() {
+
+			t.Fatalf("expected replacement %v of %v, got %v",
+				result.Published[i].TxHash(), tx.TxHash(),
+				replacement)
+		}
+	}
+
+	// If the replacement of the child is rejected, the replacement of the
+	// parent accepted before it should still be recorded, with the
+	// original child removed along with its parent.
+	funding = wire.NewMsgTx(wire.TxVersion)
+	funding.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x04}}, nil, nil,
+	))
+	funding.AddTxOut(wire.NewTxOut(1000000, newScript(false)))
+	addUtxo(t, w, funding)
+
+	parent = spendChange(
+		wire.OutPoint{Hash: funding.TxHash()}, funding.TxOut[0],
+	)
+	child = spendChange(
+		wire.OutPoint{Hash: parent.TxHash(), Index: 1}, parent.TxOut[1],
+	)
+	chainClient.ancestors[child.TxHash()] = mempoolEntries{
+		parent.TxHash(): {
+			VSize: int32(txVirtualSize(parent)),
+			Fees: btcjson.MempoolFees{
+				Base: btcutil.Amount(lowFee).ToBTC(),
+			},
+		},
+	}
+
+	chainClient.reject, chainClient.accept = true, 1
+	_, err = w.BumpTransactionFee(child.TxHash(), feeRate, RBFOnly)
+	if err == nil {
+		t.Fatal("expected the rejected replacement to fail the bump")
+	}
+	chainClient.reject = false
+
+	newParentHash, err := w.TxReplacement(parent.TxHash())
+	if err != nil {
+		t.Fatalf("unable to fetch replacement: %v", err)
+	}
+	if newParentHash == nil {
+		t.Fatal("expected the parent's replacement to be recorded")
+	}
+	newChildHash, err := w.TxReplacement(child.TxHash())
+	if err != nil {
+		t.Fatalf("unable to fetch replacement: %v", err)
+	}
+	if newChildHash != nil {
+		t.Fatalf("expected no replacement of the child, got %v",
+			newChildHash)
+	}
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		for _, txHash := range []chainhash.Hash{
+			parent.TxHash(), child.TxHash(),
+		} {
+			txHash := txHash
+			details, err := w.TxStore.TxDetails(ns, &txHash)
+			if err != nil {
+				return err
+			}
+			if details != nil {
+				t.Fatalf("expected %v to be removed", txHash)
+			}
+		}
+		details, err := w.TxStore.TxDetails(ns, newParentHash)
+		if err != nil {
+			return err
+		}
+		if details == nil {
+			t.Fatalf("expected %v to be found", newParentHash)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A transaction with a foreign ancestor should instead have its fee
+	// bumped by a child paying for the whole package.
+	foreignParent := wire.NewMsgTx(wire.TxVersion)
+	foreignParent.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x02}}, nil, nil,
+	))
+	foreignParent.AddTxOut(wire.NewTxOut(1000000, newScript(false)))
+	foreignParentRec := addUnminedTx(t, w, foreignParent, 0)
+
+	const ancestorSize = 500
+	chainClient.ancestors[foreignParentRec.Hash] = mempoolEntries{
+		{0x03}: {
+			VSize: ancestorSize,
+			Fees: btcjson.MempoolFees{
+				Base: btcutil.Amount(lowFee).ToBTC(),
+			},
+		},
+	}
+
+	_, err = w.BumpTransactionFee(foreignParentRec.Hash, feeRate, RBFOnly)
+	if !errors.Is(err, ErrTxNotReplaceable) {
+		t.Fatalf("expected ErrTxNotReplaceable, got: %v", err)
+	}
+
+	result, err = w.BumpTransactionFee(
+		foreignParentRec.Hash, feeRate, RBFThenCPFP,
+	)
+	if err != nil {
+		t.Fatalf("unable to bump fee: %v", err)
+	}
+	if result.Mechanism != FeeBumpCPFP || len(result.Published) != 1 ||
+		len(result.Replaced) != 0 {
+
+		t.Fatalf("expected a single child to be published, got %v "+
+			"publishing %d and replacing %d", result.Mechanism,
+			len(result.Published), len(result.Replaced))
+	}
+
+	// The child should pay for the size of the whole package, minus the
+	// fee already paid by the ancestor. Its size is estimated before being
+	// signed, so we allow for a signature shorter than estimated.
+	packageSize := ancestorSize + txVirtualSize(foreignParent) +
+		txVirtualSize(result.Tx)
+	minFee := txrules.FeeForSerializeSize(feeRate, packageSize) - lowFee
+	if result.Fee < minFee-1 {
+		t.Fatalf("expected child fee of at least %v, got %v", minFee,
+			result.Fee)
+	}
+}
+
+// incrementalFeeChainClient is a mock chain client reporting the given
+// incremental relay fee.
+type incrementalFeeChainClient struct {
+	mockChainClient
+
+	incrementalFee btcutil.Amount
+}
+
+func (c *incrementalFeeChainClient) GetIncrementalRelayFee() (btcutil.Amount,
+	error) {
+
+	return c.incrementalFee, nil
+}
+
+// TestBumpTransactionFeeIncrementalRelayFee ensures that a replacement whose
+// fee rate only slightly exceeds that of the original transaction pays enough
+// to increase the fee by the incremental relay fee reported by the backend.
+func TestBumpTransactionFeeIncrementalRelayFee(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	const incrementalFee = btcutil.Amount(5000)
+	w.chainClient = &incrementalFeeChainClient{
+		incrementalFee: incrementalFee,
+	}
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	changeAddr, err := w.NewChangeAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	changeScript, err := txscript.PayToAddrScript(changeAddr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+
+	const (
+		funding = 1000000
+		payment = 100000
+		oldFee  = 2000
+	)
+	fundingTx := wire.NewMsgTx(wire.TxVersion)
+	fundingTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	fundingTx.AddTxOut(wire.NewTxOut(funding, pkScript))
+	addUtxo(t, w, fundingTx)
+
+	// Create a transaction signaling replaceability paying a fee of about
+	// 14 sat/vbyte.
+	tx := wire.NewMsgTx(wire.TxVersion)
+	txIn := wire.NewTxIn(
+		&wire.OutPoint{Hash: fundingTx.TxHash()}, nil, nil,
+	)
+	txIn.Sequence = wire.MaxTxInSequenceNum - 2
+	tx.AddTxIn(txIn)
+	tx.AddTxOut(wire.NewTxOut(payment, testScriptP2WKH))
+	tx.AddTxOut(wire.NewTxOut(funding-payment-oldFee, changeScript))
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+		err := txauthor.AddAllInputScripts(
+			tx, [][]byte{pkScript},
+			[]btcutil.Amount{btcutil.Amount(funding)},
+			secretSource{w.Manager, addrmgrNs},
+		)
+		if err != nil {
+			return err
+		}
+
+		rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+		if err != nil {
+			return err
+		}
+		return w.addRelevantTx(dbtx, rec, nil)
+	})
+	if err != nil {
+		t.Fatalf("unable to add tx: %v", err)
+	}
+
+	// Bumping to a fee rate 1 sat/vbyte above the original's raises the
+	// fee rate, but not the absolute fee by the incremental relay fee of
+	// 5 sat/vbyte, so the replacement should pay that instead.
+	vsize := txVirtualSize(tx)
+	feeRate := oldFee*1000/btcutil.Amount(vsize) + 1000
+	if txrules.FeeForSerializeSize(feeRate, vsize) <= oldFee {
+		t.Fatalf("fee rate %v doesn't exceed the original's", feeRate)
+	}
+
+	result, err := w.BumpTransactionFee(tx.TxHash(), feeRate, RBFOnly)
+	if err != nil {
+		t.Fatalf("unable to bump fee: %v", err)
+	}
+	minFee := oldFee + txrules.FeeForSerializeSize(incrementalFee, vsize)
+	if result.Fee != minFee {
+		t.Fatalf("expected replacement fee of %v, got %v", minFee,
+			result.Fee)
+	}
+
+	var totalOut btcutil.Amount
+	for _, txOut := range result.Tx.TxOut {
+		totalOut += btcutil.Amount(txOut.Value)
+	}
+	if funding-totalOut != minFee {
+		t.Fatalf("expected replacement to pay %v, got %v", minFee,
+			funding-totalOut)
+	}
+}
+
+// TestBumpTransactionFeeFailureReasons ensures that each reason preventing the
+// fee of a transaction from being bumped is reported through its own error.
+func TestBumpTransactionFeeFailureReasons(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+
+	// newTx creates a transaction that doesn't signal replaceability,
+	// spending an output foreign to the wallet to the given script.
+	var nextPrevOut byte
+	newTx := func(value int64, pkScript []byte) *wire.MsgTx {
+		nextPrevOut++
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(
+			&wire.OutPoint{Hash: chainhash.Hash{nextPrevOut}},
+			nil, nil,
+		))
+		tx.AddTxOut(wire.NewTxOut(value, pkScript))
+		return tx
+	}
+
+	// A confirmed transaction no longer needs its fee bumped.
+	confirmed := newTx(100000, pkScript)
+	confirmedRec, err := wtxmgr.NewTxRecordFromMsgTx(confirmed, time.Now())
+	if err != nil {
+		t.Fatalf("unable to create tx record: %v", err)
+	}
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		ns := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+		return w.TxStore.InsertTx(ns, confirmedRec, &wtxmgr.BlockMeta{
+			Block: wtxmgr.Block{Height: 100},
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to insert tx: %v", err)
+	}
+
+	// A transaction that can't be replaced, and without an output owned
+	// by the wallet, can't be bumped through either mechanism.
+	foreign := addUnminedTx(t, w, newTx(100000, []byte{txscript.OP_TRUE}))
+
+	// The wallet output of a transaction too small to pay for a child at
+	// the requested fee rate can't afford the higher fee.
+	small := addUnminedTx(t, w, newTx(1000, pkScript), 0)
+
+	// The fee of a child bumping the fee of a transaction can't exceed
+	// the fee ceiling relative to its output value.
+	capped := addUnminedTx(t, w, newTx(100000, pkScript), 0)
+
+	tests := []struct {
+		name    string
+		txHash  chainhash.Hash
+		policy  FeeBumpPolicy
+		ceiling FeeCeiling
+		err     error
+	}{
+		{
+			name:    "confirmed",
+			txHash:  confirmedRec.Hash,
+			policy:  RBFThenCPFP,
+			ceiling: DefaultFeeCeiling,
+			err:     ErrTxAlreadyConfirmed,
+		},
+		{
+			name:    "not replaceable",
+			txHash:  foreign.Hash,
+			policy:  RBFOnly,
+			ceiling: DefaultFeeCeiling,
+			err:     ErrTxNotReplaceable,
+		},
+		{
+			name:    "no cpfp output",
+			txHash:  foreign.Hash,
+			policy:  CPFPOnly,
+			ceiling: DefaultFeeCeiling,
+			err:     ErrNoCPFPOutput,
+		},
+		{
+			name:    "not bumpable",
+			txHash:  foreign.Hash,
+			policy:  RBFThenCPFP,
+			ceiling: DefaultFeeCeiling,
+			err:     ErrTxNotBumpable,
+		},
+		{
+			name:    "insufficient funds",
+			txHash:  small.Hash,
+			policy:  RBFThenCPFP,
+			ceiling: FeeCeiling{},
+			err:     ErrInsufficientBumpFunds,
+		},
+		{
+			name:    "fee ceiling exceeded",
+			txHash:  capped.Hash,
+			policy:  RBFThenCPFP,
+			ceiling: FeeCeiling{MaxFee: 100},
+			err:     ErrFeeExceedsCeiling,
+		},
+	}
+
+	const feeRate = btcutil.Amount(10000)
+	for _, test := range tests {
+		if err := w.SetFeeCeiling(test.ceiling); err != nil {
+			t.Fatalf("unable to set fee ceiling: %v", err)
+		}
+
+		_, err := w.BumpTransactionFee(
+			test.txHash, feeRate, test.policy,
+		)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("%s: expected %v, got: %v", test.name,
+				test.err, err)
+		}
+
+		// Each reason is distinct from the others.
+		for _, other := range tests {
+			if other.err != test.err && errors.Is(err, other.err) {
+				t.Fatalf("%s: unexpected %v", test.name,
+					other.err)
+			}
+		}
+	}
+}
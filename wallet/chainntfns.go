@@ -6,12 +6,15 @@ package wallet
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcwallet/chain"
 	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/walletdb"
@@ -25,15 +28,49 @@ const (
 	birthdayBlockDelta = 2 * time.Hour
 )
 
+// handleChainNotifications handles the notifications of the wallet's current
+// chain client.
 func (w *Wallet) handleChainNotifications() {
-	defer w.wg.Done()
-
-	chainClient, err := w.requireChainClient()
-	if err != nil {
+	w.chainSwitchMtx.RLock()
+	w.chainClientLock.Lock()
+	chainClient, ntfnsQuit := w.chainClient, w.chainNtfnsQuit
+	ntfnsWg := w.chainNtfnsWg
+	w.chainClientLock.Unlock()
+	w.chainSwitchMtx.RUnlock()
+
+	if chainClient == nil {
 		log.Errorf("handleChainNotifications called without RPC client")
+		if ntfnsWg != nil {
+			ntfnsWg.Done()
+		}
+		w.wg.Done()
 		return
 	}
 
+	w.handleClientNotifications(chainClient, ntfnsQuit, ntfnsWg, nil)
+}
+
+// handleClientNotifications handles the notifications of the given chain
+// client until the wallet shuts down, or the ntfnsQuit channel is closed once
+// the client is switched for another one, after which ntfnsWg is marked done.
+// If synced is not nil, the result of synchronizing the wallet with the client
+// once it's connected is delivered over it, rather than causing a panic on
+// failure.
+func (w *Wallet) handleClientNotifications(chainClient chain.Interface,
+	ntfnsQuit <-chan struct{}, ntfnsWg *sync.WaitGroup,
+	synced chan<- error) {
+
+	defer w.wg.Done()
+	if ntfnsWg != nil {
+		defer ntfnsWg.Done()
+	}
+	defer func() {
+		if synced != nil {
+			synced <- errors.New("chain client notifications " +
+				"stopped before synchronizing")
+		}
+	}()
+
 	catchUpHashes := func(w *Wallet, client chain.Interface,
 		height int32) error {
 		// TODO(aakselrod): There's a race condition here, which
@@ -85,6 +122,12 @@ func (w *Wallet) handleChainNotifications() {
 	}
 
 	for {
+		select {
+		case <-ntfnsQuit:
+			return
+		default:
+		}
+
 		select {
 		case n, ok := <-chainClient.Notifications():
 			if !ok {
@@ -95,60 +138,124 @@ func (w *Wallet) handleChainNotifications() {
 			var err error
 			switch n := n.(type) {
 			case chain.ClientConnected:
-				// Before attempting to sync with our backend,
-				// we'll make sure that our birthday block has
-				// been set correctly to potentially prevent
-				// missing relevant events.
-				birthdayStore := &walletBirthdayStore{
-					db:      w.db,
-					manager: w.Manager,
+				syncErr := w.syncWithClient(chainClient)
+				if synced != nil {
+					synced <- syncErr
+					synced = nil
+					break
 				}
-				birthdayBlock, err := birthdaySanityCheck(
-					chainClient, birthdayStore,
-				)
-				if err != nil && !waddrmgr.IsError(err, waddrmgr.ErrBirthdayBlockNotSet) {
-					panic(fmt.Errorf("unable to sanity "+
-						"check wallet birthday block: %v",
-						err))
-				}
-
-				err = w.syncWithChain(birthdayBlock)
-				if err != nil && !w.ShuttingDown() {
+				if syncErr != nil && !w.ShuttingDown() {
 					panic(fmt.Errorf("unable to synchronize "+
-						"wallet to chain: %v", err))
+						"wallet to chain: %v", syncErr))
 				}
 			case chain.BlockConnected:
 				err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
 					return w.connectBlock(tx, wtxmgr.BlockMeta(n))
 				})
 				notificationName = "block connected"
+				if err == nil {
+					w.triggerCoinbaseSweep()
+
+					if w.currentFeeEscalationPolicy() != nil &&
+						w.ChainSynced() {
+
+						w.triggerRebroadcast()
+					}
+				}
 			case chain.BlockDisconnected:
-				err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
-					return w.disconnectBlock(tx, wtxmgr.BlockMeta(n))
+				err = w.updateWithEvents(func(
+					tx walletdb.ReadWriteTx,
+					events *eventBatch) error {
+
+					block := wtxmgr.BlockMeta(n)
+					err := events.watchRollback(tx, block)
+					if err != nil {
+						return err
+					}
+					return w.disconnectBlock(tx, block)
 				})
 				notificationName = "block disconnected"
+
+				// A reorg deeper than we're willing to roll back
+				// requires operator intervention, so we'll stop
+				// processing any further notifications, and
+				// creating or publishing transactions.
+				if errors.Is(err, ErrReorgTooDeep) {
+					log.Criticalf("Halting chain notification "+
+						"processing: %v", err)
+					w.haltOnReorg(err)
+					return
+				}
 			case chain.RelevantTx:
-				err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
-					return w.addRelevantTx(tx, n.TxRecord, n.Block)
+				// An unconfirmed transaction from the mempool
+				// replaced the wallet's transactions spending
+				// some of the same inputs.
+				var replaced []chainhash.Hash
+				err = w.updateWithEvents(func(
+					tx walletdb.ReadWriteTx,
+					events *eventBatch) error {
+
+					err := events.watchTxs(tx, n.TxRecord)
+					if err != nil {
+						return err
+					}
+					replaced, err = w.recordTxReplacements(
+						tx, n.TxRecord, n.Block,
+					)
+					if err != nil {
+						return err
+					}
+					return w.addNotifiedTx(
+						tx, n.TxRecord, n.Block,
+					)
 				})
 				notificationName = "relevant transaction"
+				if err == nil {
+					for _, txHash := range replaced {
+						w.notifyTxReplaced(
+							txHash, n.TxRecord.Hash,
+						)
+					}
+				}
 			case chain.FilteredBlockConnected:
 				// Atomically update for the whole block.
-				if len(n.RelevantTxs) > 0 {
-					err = walletdb.Update(w.db, func(
-						tx walletdb.ReadWriteTx) error {
-						var err error
-						for _, rec := range n.RelevantTxs {
-							err = w.addRelevantTx(tx, rec,
-								n.Block)
-							if err != nil {
-								return err
-							}
-						}
-						return nil
-					})
-				}
+				err = w.updateWithEvents(func(
+					tx walletdb.ReadWriteTx,
+					events *eventBatch) error {
+
+					err := events.watchTxs(
+						tx, n.RelevantTxs...,
+					)
+					if err != nil {
+						return err
+					}
+					return w.connectFilteredBlock(tx, n)
+				})
 				notificationName = "filtered block connected"
+			case chain.FilteredBlocksConnected:
+				// Atomically update for the whole batch.
+				err = w.updateWithEvents(func(
+					tx walletdb.ReadWriteTx,
+					events *eventBatch) error {
+
+					for _, block := range n.Blocks {
+						recs := block.RelevantTxs
+						err := events.watchTxs(
+							tx, recs...,
+						)
+						if err != nil {
+							return err
+						}
+						err = w.connectFilteredBlock(
+							tx, block,
+						)
+						if err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+				notificationName = "filtered blocks connected"
 
 			// The following require some database maintenance, but also
 			// need to be reported to the wallet's rescan goroutine.
@@ -190,12 +297,35 @@ func (w *Wallet) handleChainNotifications() {
 					"%v notification: %v", notificationName,
 					err)
 			}
+		case <-ntfnsQuit:
+			return
 		case <-w.quit:
 			return
 		}
 	}
 }
 
+// syncWithClient synchronizes the wallet with the given chain client once it's
+// connected.
+func (w *Wallet) syncWithClient(chainClient chain.Interface) error {
+	// Before attempting to sync with our backend, we'll make sure that our
+	// birthday block has been set correctly to potentially prevent missing
+	// relevant events.
+	birthdayStore := &walletBirthdayStore{
+		db:      w.db,
+		manager: w.Manager,
+	}
+	birthdayBlock, err := birthdaySanityCheck(chainClient, birthdayStore)
+	if err != nil &&
+		!waddrmgr.IsError(err, waddrmgr.ErrBirthdayBlockNotSet) {
+
+		return fmt.Errorf("unable to sanity check wallet birthday "+
+			"block: %v", err)
+	}
+
+	return w.syncWithChain(birthdayBlock)
+}
+
 // connectBlock handles a chain server notification by marking a wallet
 // that's currently in-sync with the chain server as being synced up to
 // the passed block.
@@ -211,6 +341,15 @@ func (w *Wallet) connectBlock(dbtx walletdb.ReadWriteTx, b wtxmgr.BlockMeta) err
 	if err != nil {
 		return err
 	}
+	err = w.Manager.SetNotificationJournal(addrmgrNs, &bs)
+	if err != nil {
+		return err
+	}
+
+	// A connected block ends any reorg in progress.
+	dbtx.OnCommit(func() {
+		w.reorgDepth = 0
+	})
 
 	// Notify interested clients of the connected block.
 	//
@@ -232,7 +371,21 @@ func (w *Wallet) disconnectBlock(dbtx walletdb.ReadWriteTx, b wtxmgr.BlockMeta)
 
 	// Disconnect the removed block and all blocks after it if we know about
 	// the disconnected block. Otherwise, the block is in the future.
-	if b.Height <= w.Manager.SyncedTo().Height {
+	syncedTo := w.Manager.SyncedTo()
+	if b.Height <= syncedTo.Height {
+		// Refuse to roll back more blocks than allowed, as a reorg this
+		// deep is more likely caused by a faulty backend. Backends
+		// disconnect blocks one at a time from the tip, so the depth
+		// of the reorg is the number of blocks rolled back since the
+		// last block was connected.
+		depth := w.reorgDepth + uint32(syncedTo.Height-b.Height+1)
+		if depth > w.maxReorgDepth {
+			return fmt.Errorf("%w: disconnecting block %v at "+
+				"height %d would roll back %d blocks, exceeding "+
+				"the limit of %d", ErrReorgTooDeep, b.Hash,
+				b.Height, depth, w.maxReorgDepth)
+		}
+
 		hash, err := w.Manager.BlockHash(addrmgrNs, b.Height)
 		if err != nil {
 			return err
@@ -258,11 +411,23 @@ func (w *Wallet) disconnectBlock(dbtx walletdb.ReadWriteTx, b wtxmgr.BlockMeta)
 			if err != nil {
 				return err
 			}
+			err = w.Manager.SetNotificationJournal(addrmgrNs, &bs)
+			if err != nil {
+				return err
+			}
 
 			err = w.TxStore.Rollback(txmgrNs, b.Height)
 			if err != nil {
 				return err
 			}
+			err = w.rollbackUsedIndexes(dbtx, b.Height)
+			if err != nil {
+				return err
+			}
+
+			dbtx.OnCommit(func() {
+				w.reorgDepth = depth
+			})
 		}
 	}
 
@@ -272,7 +437,219 @@ func (w *Wallet) disconnectBlock(dbtx walletdb.ReadWriteTx, b wtxmgr.BlockMeta)
 	return nil
 }
 
+// connectFilteredBlock handles a chain server notification for a block
+// filtered for the wallet by inserting all of its relevant transactions. The
+// block is recorded in the notification journal within the same database
+// transaction, such that the wallet can resume from the block following it if
+// it's shut down before the block is marked as synced.
+func (w *Wallet) connectFilteredBlock(dbtx walletdb.ReadWriteTx,
+	n chain.FilteredBlockConnected) error {
+
+	for _, rec := range n.RelevantTxs {
+		if err := w.addNotifiedTx(dbtx, rec, n.Block); err != nil {
+			return err
+		}
+	}
+
+	if n.Block == nil {
+		return nil
+	}
+
+	addrmgrNs := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
+	return w.Manager.SetNotificationJournal(addrmgrNs, &waddrmgr.BlockStamp{
+		Height:    n.Block.Height,
+		Hash:      n.Block.Hash,
+		Timestamp: n.Block.Time,
+	})
+}
+
+// replayNotificationJournal brings the wallet's synced state up to the last
+// block recorded in its notification journal. This is required if the wallet
+// was shut down after processing a block's relevant transactions but before
+// marking it as synced, and allows the wallet to resume from the block
+// following it rather than processing it again. Journaled blocks that are no
+// longer part of the main chain are ignored, as they'll be rolled back.
+func (w *Wallet) replayNotificationJournal(chainClient chainConn) error {
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+
+		journal, err := w.Manager.NotificationJournal(ns)
+		if waddrmgr.IsError(err, waddrmgr.ErrNoExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		syncedTo := w.Manager.SyncedTo()
+		if journal.Height <= syncedTo.Height {
+			return nil
+		}
+
+		hash, err := chainClient.GetBlockHash(int64(journal.Height))
+		if err != nil {
+			return err
+		}
+		if *hash != journal.Hash {
+			log.Debugf("Ignoring stale notification journal block "+
+				"%v at height %d", journal.Hash, journal.Height)
+			return nil
+		}
+
+		log.Infof("Resuming from notification journal block %v at "+
+			"height %d", journal.Hash, journal.Height)
+
+		// We'll store the hashes of all blocks up to the journaled one,
+		// just as we would have if their notifications had been fully
+		// processed.
+		for h := syncedTo.Height + 1; h <= journal.Height; h++ {
+			hash, err := chainClient.GetBlockHash(int64(h))
+			if err != nil {
+				return err
+			}
+			header, err := chainClient.GetBlockHeader(hash)
+			if err != nil {
+				return err
+			}
+
+			err = w.Manager.SetSyncedTo(ns, &waddrmgr.BlockStamp{
+				Height:    h,
+				Hash:      *hash,
+				Timestamp: header.Timestamp,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// markUsedIndex records the derivation index of the address as used by a
+// credit within the given block, or an unmined credit if the block is nil.
+// Addresses without a derivation index, such as imported ones, are skipped.
+func (w *Wallet) markUsedIndex(addrmgrNs walletdb.ReadWriteBucket,
+	ma waddrmgr.ManagedAddress, block *wtxmgr.BlockMeta) error {
+
+	pubKeyAddr, ok := ma.(waddrmgr.ManagedPubKeyAddress)
+	if !ok || ma.Imported() {
+		return nil
+	}
+	scope, path, ok := pubKeyAddr.DerivationInfo()
+	if !ok {
+		return nil
+	}
+	scopedMgr, err := w.Manager.FetchScopedKeyManager(scope)
+	if err != nil {
+		return err
+	}
+
+	height := int32(-1)
+	if block != nil {
+		height = block.Height
+	}
+	return scopedMgr.MarkIndexUsed(
+		addrmgrNs, path.InternalAccount, path.Branch, path.Index,
+		height,
+	)
+}
+
+// rollbackUsedIndexes rolls back the used address indexes of every key scope
+// after the transaction store was rolled back to the given height. Indexes
+// with credits within the removed blocks remain used only if the wallet still
+// has an unmined transaction paying to them.
+func (w *Wallet) rollbackUsedIndexes(dbtx walletdb.ReadWriteTx,
+	height int32) error {
+
+	addrmgrNs := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
+	txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+	type usedIndex struct {
+		scope                  waddrmgr.KeyScope
+		account, branch, index uint32
+	}
+	unminedIndexes := make(map[usedIndex]struct{})
+
+	unmined, err := w.TxStore.UnminedTxs(txmgrNs)
+	if err != nil {
+		return err
+	}
+	for _, tx := range unmined {
+		for _, output := range tx.TxOut {
+			_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+				output.PkScript, w.chainParams,
+			)
+			if err != nil {
+				// Non-standard outputs are skipped.
+				continue
+			}
+
+			for _, addr := range addrs {
+				ma, err := w.Manager.Address(addrmgrNs, addr)
+				if err != nil {
+					continue
+				}
+				pubKeyAddr, ok := ma.(waddrmgr.ManagedPubKeyAddress)
+				if !ok {
+					continue
+				}
+				scope, path, ok := pubKeyAddr.DerivationInfo()
+				if !ok {
+					continue
+				}
+				unminedIndexes[usedIndex{
+					scope:   scope,
+					account: path.InternalAccount,
+					branch:  path.Branch,
+					index:   path.Index,
+				}] = struct{}{}
+			}
+		}
+	}
+
+	for _, scopedMgr := range w.Manager.ActiveScopedKeyManagers() {
+		scope := scopedMgr.Scope()
+		err := scopedMgr.RollbackUsedIndexes(
+			addrmgrNs, height,
+			func(account, branch, index uint32) (bool, error) {
+				_, ok := unminedIndexes[usedIndex{
+					scope:   scope,
+					account: account,
+					branch:  branch,
+					index:   index,
+				}]
+				return ok, nil
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (w *Wallet) addRelevantTx(dbtx walletdb.ReadWriteTx, rec *wtxmgr.TxRecord, block *wtxmgr.BlockMeta) error {
+	return w.insertRelevantTx(dbtx, rec, block, false)
+}
+
+// addNotifiedTx records a relevant transaction notified by the chain backend,
+// invoking the hooks of the rescan in progress, if any, as it may have been
+// discovered by the rescan. Transactions recorded by the wallet otherwise,
+// e.g. when publishing or restoring them, don't reach the hooks.
+func (w *Wallet) addNotifiedTx(dbtx walletdb.ReadWriteTx,
+	rec *wtxmgr.TxRecord, block *wtxmgr.BlockMeta) error {
+
+	return w.insertRelevantTx(dbtx, rec, block, true)
+}
+
+// insertRelevantTx records a relevant transaction along with its credits,
+// invoking the hooks of the rescan in progress if rescanHooks is set.
+func (w *Wallet) insertRelevantTx(dbtx walletdb.ReadWriteTx,
+	rec *wtxmgr.TxRecord, block *wtxmgr.BlockMeta,
+	rescanHooks bool) error {
+
 	addrmgrNs := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
 	txmgrNs := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
 
@@ -295,37 +672,15 @@ func (w *Wallet) addRelevantTx(dbtx walletdb.ReadWriteTx, rec *wtxmgr.TxRecord,
 
 	// Check every output to determine whether it is controlled by a wallet
 	// key.  If so, mark the output as a credit.
-	for i, output := range rec.MsgTx.TxOut {
-		_, addrs, _, err := txscript.ExtractPkScriptAddrs(output.PkScript,
-			w.chainParams)
-		if err != nil {
-			// Non-standard outputs are skipped.
-			continue
-		}
-		for _, addr := range addrs {
-			ma, err := w.Manager.Address(addrmgrNs, addr)
-			if err == nil {
-				// TODO: Credits should be added with the
-				// account they belong to, so wtxmgr is able to
-				// track per-account balances.
-				err = w.TxStore.AddCredit(txmgrNs, rec, block, uint32(i),
-					ma.Internal())
-				if err != nil {
-					return err
-				}
-				err = w.Manager.MarkUsed(addrmgrNs, addr)
-				if err != nil {
-					return err
-				}
-				log.Debugf("Marked address %v used", addr)
-				continue
-			}
+	if err := w.addWalletCredits(addrmgrNs, txmgrNs, rec, block); err != nil {
+		return err
+	}
 
-			// Missing addresses are skipped.  Other errors should
-			// be propagated.
-			if !waddrmgr.IsError(err, waddrmgr.ErrAddressNotFound) {
-				return err
-			}
+	// Let the hooks of the rescan in progress, if any, process the
+	// transaction within the same database transaction.
+	if rescanHooks {
+		if err := w.runRescanTxHooks(dbtx, rec, block); err != nil {
+			return err
 		}
 	}
 
@@ -365,6 +720,124 @@ func (w *Wallet) addRelevantTx(dbtx walletdb.ReadWriteTx, rec *wtxmgr.TxRecord,
 	return nil
 }
 
+// addWalletCredits records every output of the transaction controlled by a
+// wallet key as a credit, marking its address as used. Outputs to non-standard
+// scripts, such as bare multisig scripts the wallet holds a key for or bare
+// scripts imported into the wallet, are recorded according to the wallet's
+// NonStandardScriptPolicy, while those paying to the wallet's time-locked
+// scripts are always recorded. Outputs paying to non-change addresses of a
+// transaction spending the wallet's own outputs are recorded as
+// self-transfers.
+func (w *Wallet) addWalletCredits(addrmgrNs walletdb.ReadWriteBucket,
+	txmgrNs walletdb.ReadWriteBucket, rec *wtxmgr.TxRecord,
+	block *wtxmgr.BlockMeta) error {
+
+	var recBlock *wtxmgr.Block
+	if block != nil {
+		recBlock = &block.Block
+	}
+	prevScripts, err := w.TxStore.PreviousPkScripts(
+		txmgrNs, rec, recBlock,
+	)
+	if err != nil {
+		return err
+	}
+	spendsWallet := len(prevScripts) > 0
+
+	for i, output := range rec.MsgTx.TxOut {
+		class, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			output.PkScript, w.chainParams,
+		)
+		if err != nil {
+			// Unparsable outputs are skipped.
+			continue
+		}
+
+		switch class {
+		case txscript.MultiSigTy, txscript.NonStandardTy:
+			if w.nonStandardPolicy == SkipNonStandardScripts {
+				continue
+			}
+
+			// A bare script imported into the wallet is known by
+			// its pay-to-script-hash address.
+			scriptAddr, err := btcutil.NewAddressScriptHash(
+				output.PkScript, w.chainParams,
+			)
+			if err != nil {
+				return err
+			}
+			addrs = append(addrs, scriptAddr)
+
+		// Outputs paying to one of the wallet's time-locked scripts
+		// are recorded as credits, as the wallet holds the key they
+		// commit to.
+		case txscript.WitnessV0ScriptHashTy:
+			_, _, ok := w.TxStore.TimeLockedScript(
+				txmgrNs, output.PkScript,
+			)
+			if !ok {
+				break
+			}
+
+			kind := wtxmgr.CreditKindReceive
+			if spendsWallet {
+				kind = wtxmgr.CreditKindSelfTransfer
+			}
+			err := w.TxStore.AddCreditOfKind(
+				txmgrNs, rec, block, uint32(i), kind,
+			)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, addr := range addrs {
+			ma, err := w.Manager.Address(addrmgrNs, addr)
+			if err == nil {
+				kind := wtxmgr.CreditKindReceive
+				switch {
+				case ma.Internal():
+					kind = wtxmgr.CreditKindChange
+				case spendsWallet:
+					kind = wtxmgr.CreditKindSelfTransfer
+				}
+
+				// TODO: Credits should be added with the
+				// account they belong to, so wtxmgr is able to
+				// track per-account balances.
+				err = w.TxStore.AddCreditOfKind(
+					txmgrNs, rec, block, uint32(i), kind,
+				)
+				if err != nil {
+					return err
+				}
+				err = w.Manager.MarkUsed(
+					addrmgrNs, ma.Address(),
+				)
+				if err != nil {
+					return err
+				}
+				err = w.markUsedIndex(addrmgrNs, ma, block)
+				if err != nil {
+					return err
+				}
+				log.Debugf("Marked address %v used", addr)
+				continue
+			}
+
+			// Missing addresses are skipped.  Other errors should
+			// be propagated.
+			if !waddrmgr.IsError(err, waddrmgr.ErrAddressNotFound) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 // chainConn is an interface that abstracts the chain connection logic required
 // to perform a wallet's birthday block sanity check.
 type chainConn interface {
@@ -1,6 +1,7 @@
 package wallet
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
@@ -8,9 +9,13 @@ import (
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/chain"
 	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
 	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
 )
 
 const (
@@ -308,3 +313,418 @@ func TestBirthdaySanityCheckHigherEstimate(t *testing.T) {
 			"%v vs %v", birthdayStore.syncedTo, birthdayBlock)
 	}
 }
+
+// TestReplayNotificationJournal ensures that a wallet shut down after
+// processing a block's relevant transactions, but before marking the block as
+// synced, resumes from the block following it.
+func TestReplayNotificationJournal(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	const numBlocks = 10
+	chainConn := createMockChainConn(
+		chaincfg.TestNet3Params.GenesisBlock, numBlocks,
+		defaultBlockInterval,
+	)
+	blockStamp := func(height int32) waddrmgr.BlockStamp {
+		hash := chainConn.blockHashes[uint32(height)]
+		return waddrmgr.BlockStamp{
+			Height:    height,
+			Hash:      hash,
+			Timestamp: chainConn.blocks[hash].Header.Timestamp,
+		}
+	}
+
+	// Process every block up to the one before the crash.
+	const crashHeight = 5
+	for height := int32(1); height < crashHeight; height++ {
+		bs := blockStamp(height)
+		err := walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+			return w.connectBlock(tx, wtxmgr.BlockMeta{
+				Block: wtxmgr.Block{
+					Hash:   bs.Hash,
+					Height: bs.Height,
+				},
+				Time: bs.Timestamp,
+			})
+		})
+		if err != nil {
+			t.Fatalf("unable to connect block %d: %v", height, err)
+		}
+	}
+
+	// Then, process the relevant transactions of the block at which the
+	// wallet crashes, without ever marking it as synced.
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	tx.AddTxOut(wire.NewTxOut(100000, pkScript))
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+	if err != nil {
+		t.Fatalf("unable to create tx record: %v", err)
+	}
+
+	crashStamp := blockStamp(crashHeight)
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		return w.connectFilteredBlock(dbtx, chain.FilteredBlockConnected{
+			Block: &wtxmgr.BlockMeta{
+				Block: wtxmgr.Block{
+					Hash:   crashStamp.Hash,
+					Height: crashStamp.Height,
+				},
+				Time: crashStamp.Timestamp,
+			},
+			RelevantTxs: []*wtxmgr.TxRecord{rec},
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to connect filtered block: %v", err)
+	}
+	if w.Manager.SyncedTo().Height != crashHeight-1 {
+		t.Fatalf("expected wallet to be synced to height %d, got %d",
+			crashHeight-1, w.Manager.SyncedTo().Height)
+	}
+
+	// Replaying the journal on restart should mark the crash block as
+	// synced, such that the wallet resumes from the block following it.
+	if err := w.replayNotificationJournal(chainConn); err != nil {
+		t.Fatalf("unable to replay notification journal: %v", err)
+	}
+	syncedTo := w.Manager.SyncedTo()
+	if syncedTo.Height != crashStamp.Height ||
+		syncedTo.Hash != crashStamp.Hash {
+
+		t.Fatalf("expected wallet to be synced to %v, got %v",
+			crashStamp, syncedTo)
+	}
+
+	// The transactions of the crash block should have been recorded
+	// exactly once.
+	var unspent []wtxmgr.Credit
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		var err error
+		unspent, err = w.TxStore.UnspentOutputs(ns)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch unspent outputs: %v", err)
+	}
+	if len(unspent) != 1 || unspent[0].Height != crashHeight {
+		t.Fatalf("expected a single output confirmed at height %d, "+
+			"got %v", crashHeight, unspent)
+	}
+
+	// Replaying the journal again should have no effect.
+	if err := w.replayNotificationJournal(chainConn); err != nil {
+		t.Fatalf("unable to replay notification journal: %v", err)
+	}
+	if w.Manager.SyncedTo() != syncedTo {
+		t.Fatalf("expected wallet to remain synced to %v, got %v",
+			syncedTo, w.Manager.SyncedTo())
+	}
+}
+
+// notifyingChainClient is a mock chain client that delivers the notifications
+// sent over its channel to the wallet.
+type notifyingChainClient struct {
+	mockChainClient
+
+	notifications chan interface{}
+}
+
+// Notifications returns a channel over which the wallet receives the
+// notifications of the mock chain client.
+func (c *notifyingChainClient) Notifications() <-chan interface{} {
+	return c.notifications
+}
+
+// TestMaxReorgDepth ensures that the wallet refuses to roll back a reorg
+// deeper than its maximum reorg depth, counted over consecutive disconnects of
+// the tip, and halts processing chain notifications instead.
+func TestMaxReorgDepth(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	const (
+		numBlocks     = 10
+		maxReorgDepth = 3
+	)
+	w.SetMaxReorgDepth(maxReorgDepth)
+
+	chainConn := createMockChainConn(
+		chaincfg.TestNet3Params.GenesisBlock, numBlocks,
+		defaultBlockInterval,
+	)
+	blockMeta := func(height int32) wtxmgr.BlockMeta {
+		hash := chainConn.blockHashes[uint32(height)]
+		return wtxmgr.BlockMeta{
+			Block: wtxmgr.Block{Hash: hash, Height: height},
+			Time:  chainConn.blocks[hash].Header.Timestamp,
+		}
+	}
+	for height := int32(1); height <= numBlocks; height++ {
+		err := walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+			return w.connectBlock(tx, blockMeta(height))
+		})
+		if err != nil {
+			t.Fatalf("unable to connect block %d: %v", height, err)
+		}
+	}
+	w.SetChainSynced(true)
+
+	chainClient := &chainConnNotifyingClient{
+		notifyingChainClient: notifyingChainClient{
+			notifications: make(chan interface{}),
+		},
+		conn: chainConn,
+	}
+	w.chainClient = chainClient
+
+	done := make(chan struct{})
+	w.wg.Add(1)
+	go func() {
+		w.handleChainNotifications()
+		close(done)
+	}()
+
+	// Disconnecting the tip and connecting it again should end the reorg,
+	// so that it doesn't count towards the depth of the next one.
+	chainClient.notifications <- chain.BlockDisconnected(
+		blockMeta(numBlocks),
+	)
+	chainClient.notifications <- chain.BlockConnected(blockMeta(numBlocks))
+
+	// Disconnecting the tip up to the limit should roll back each block,
+	// while disconnecting one more should halt the notification handler
+	// without rolling it back.
+	for i := int32(0); i <= maxReorgDepth; i++ {
+		chainClient.notifications <- chain.BlockDisconnected(
+			blockMeta(numBlocks - i),
+		)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected notification processing to halt")
+	}
+
+	syncedTo := w.Manager.SyncedTo()
+	if syncedTo.Height != numBlocks-maxReorgDepth {
+		t.Fatalf("expected wallet to remain synced to height %d, "+
+			"got %d", numBlocks-maxReorgDepth, syncedTo.Height)
+	}
+
+	// The same disconnect should be rejected with ErrReorgTooDeep.
+	err := walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		return w.disconnectBlock(tx, blockMeta(numBlocks-maxReorgDepth))
+	})
+	if !errors.Is(err, ErrReorgTooDeep) {
+		t.Fatalf("expected ErrReorgTooDeep, got %v", err)
+	}
+
+	// The wallet should refuse to create or publish transactions.
+	if !errors.Is(w.ReorgHalted(), ErrReorgTooDeep) {
+		t.Fatalf("expected halt error ErrReorgTooDeep, got %v",
+			w.ReorgHalted())
+	}
+	_, err = w.txToOutputs(
+		nil, &waddrmgr.KeyScopeBIP0084, 0, 1, 1000,
+		CoinSelectionLargest, true, defaultTxCreateOptions(),
+	)
+	if !errors.Is(err, ErrReorgTooDeep) {
+		t.Fatalf("expected ErrReorgTooDeep creating a transaction, "+
+			"got %v", err)
+	}
+	_, err = w.publishTransaction(wire.NewMsgTx(wire.TxVersion))
+	if !errors.Is(err, ErrReorgTooDeep) {
+		t.Fatalf("expected ErrReorgTooDeep publishing a transaction, "+
+			"got %v", err)
+	}
+}
+
+// TestUsedIndexes ensures that the used address index bitmaps match the
+// wallet's credit history after a series of receives, and after rolling back
+// blocks containing some of them.
+func TestUsedIndexes(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	scope := waddrmgr.KeyScopeBIP0084
+	scopedMgr, err := w.Manager.FetchScopedKeyManager(scope)
+	if err != nil {
+		t.Fatalf("unable to fetch scoped manager: %v", err)
+	}
+
+	var external, internal [][]byte
+	for i := 0; i < 5; i++ {
+		addr, err := w.NewAddress(0, scope)
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("unable to create pkScript: %v", err)
+		}
+		external = append(external, pkScript)
+	}
+	for i := 0; i < 2; i++ {
+		addr, err := w.NewChangeAddress(0, scope)
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("unable to create pkScript: %v", err)
+		}
+		internal = append(internal, pkScript)
+	}
+
+	// receive adds a transaction paying to the given script, confirmed at
+	// the given height or unmined if it's -1.
+	receive := func(prevOut wire.OutPoint, pkScript []byte, height int32) {
+		t.Helper()
+
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(&prevOut, nil, nil))
+		tx.AddTxOut(wire.NewTxOut(100000, pkScript))
+
+		rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+		if err != nil {
+			t.Fatalf("unable to create tx record: %v", err)
+		}
+		var block *wtxmgr.BlockMeta
+		if height >= 0 {
+			block = &wtxmgr.BlockMeta{
+				Block: wtxmgr.Block{
+					Hash:   chainhash.Hash{byte(height)},
+					Height: height,
+				},
+				Time: time.Unix(int64(height), 0),
+			}
+		}
+		err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+			return w.addRelevantTx(dbtx, rec, block)
+		})
+		if err != nil {
+			t.Fatalf("unable to add tx: %v", err)
+		}
+	}
+
+	// assertUsedIndexes ensures the used indexes of each branch match the
+	// expected ones, as well as the indexes of the wallet's credits.
+	assertUsedIndexes := func(expExternal, expInternal []uint32) {
+		t.Helper()
+
+		err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+			addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+			txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+			credits, err := w.TxStore.UnspentOutputs(txmgrNs)
+			if err != nil {
+				return err
+			}
+			creditScripts := make(map[string]struct{})
+			for _, credit := range credits {
+				creditScripts[string(credit.PkScript)] = struct{}{}
+			}
+
+			branches := []struct {
+				branch  uint32
+				scripts [][]byte
+				exp     []uint32
+			}{
+				{waddrmgr.ExternalBranch, external, expExternal},
+				{waddrmgr.InternalBranch, internal, expInternal},
+			}
+			for _, b := range branches {
+				used, err := scopedMgr.UsedIndexes(
+					addrmgrNs, 0, b.branch,
+				)
+				if err != nil {
+					return err
+				}
+
+				expUsed := make(map[uint32]bool)
+				for _, index := range b.exp {
+					expUsed[index] = true
+				}
+				for i, pkScript := range b.scripts {
+					index := uint32(i)
+					_, hasCredit := creditScripts[string(pkScript)]
+					if used.IsUsed(index) != expUsed[index] ||
+						hasCredit != expUsed[index] {
+
+						t.Fatalf("expected index %d of branch "+
+							"%d to have used=%v, got used=%v "+
+							"with credit=%v", index,
+							b.branch, expUsed[index],
+							used.IsUsed(index), hasCredit)
+					}
+				}
+
+				highest, ok := used.HighestUsed()
+				if len(b.exp) == 0 {
+					if ok {
+						t.Fatalf("expected no used index "+
+							"in branch %d, got %d",
+							b.branch, highest)
+					}
+					continue
+				}
+				expHighest := b.exp[len(b.exp)-1]
+				if !ok || highest != expHighest {
+					t.Fatalf("expected highest used index %d "+
+						"in branch %d, got %d", expHighest,
+						b.branch, highest)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertUsedIndexes(nil, nil)
+
+	// Receive to a few external addresses and a change address, with one
+	// of the receives being a coinbase output.
+	coinbase := wire.OutPoint{Index: wire.MaxPrevOutIndex}
+	receive(wire.OutPoint{Hash: chainhash.Hash{0x01}}, external[0], 100)
+	receive(wire.OutPoint{Hash: chainhash.Hash{0x02}}, external[2], -1)
+	receive(coinbase, external[3], 101)
+	receive(wire.OutPoint{Hash: chainhash.Hash{0x03}}, internal[1], 101)
+	assertUsedIndexes([]uint32{0, 2, 3}, []uint32{1})
+
+	// Rolling back the block at height 101 should remove the coinbase
+	// output, while the other transaction returns to the mempool and
+	// should remain used.
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		txmgrNs := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+		if err := w.TxStore.Rollback(txmgrNs, 101); err != nil {
+			return err
+		}
+		return w.rollbackUsedIndexes(dbtx, 101)
+	})
+	if err != nil {
+		t.Fatalf("unable to roll back: %v", err)
+	}
+	assertUsedIndexes([]uint32{0, 2}, []uint32{1})
+}
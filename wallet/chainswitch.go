@@ -0,0 +1,111 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"sync"
+
+	"github.com/btcsuite/btcwallet/chain"
+)
+
+// SwitchChainBackend switches the chain backend the wallet is synchronized
+// with for the given one, e.g. to fail over to another node, without
+// restarting the wallet. The notifications of the current backend are no
+// longer handled and it's stopped, with the operations requiring a backend
+// being paused until the new one is in place. The wallet then catches up with
+// the new backend from the last block it processed once it's notified of the
+// backend's connection, registering all of its addresses and unspent outputs
+// for notifications, and a rescan in progress is performed again by the new
+// backend. It returns once the catch-up rescan has been performed.
+//
+// The operations using the current backend are completed with it before it's
+// stopped, while those started once the switch is in progress use the new one.
+//
+// The new backend must already be started. If the wallet isn't synchronized
+// with a backend yet, it's synchronized with the new one through
+// SynchronizeRPC instead.
+func (w *Wallet) SwitchChainBackend(newChain chain.Interface) error {
+	if w.ShuttingDown() {
+		return ErrWalletShuttingDown
+	}
+
+	w.chainSwitchMtx.Lock()
+	w.chainClientLock.Lock()
+	oldChain := w.chainClient
+	if oldChain == nil {
+		w.chainClientLock.Unlock()
+		w.chainSwitchMtx.Unlock()
+
+		w.SynchronizeRPC(newChain)
+		return nil
+	}
+
+	// Stop handling the notifications of the old backend before
+	// installing the new one, such that they're no longer reflected in the
+	// wallet.
+	if w.chainNtfnsQuit != nil {
+		close(w.chainNtfnsQuit)
+	}
+	oldNtfnsWg := w.chainNtfnsWg
+	oldUsers := w.chainClientUsers
+	ntfnsQuit := make(chan struct{})
+	ntfnsWg := &sync.WaitGroup{}
+	ntfnsWg.Add(1)
+	w.chainClient = newChain
+	w.chainNtfnsQuit = ntfnsQuit
+	w.chainNtfnsWg = ntfnsWg
+	w.chainClientUsers = &sync.WaitGroup{}
+	w.setChainClientBirthday(newChain)
+	w.chainClientLock.Unlock()
+
+	w.SetChainSynced(false)
+
+	// The rescan in progress on the old backend will never finish, so
+	// it's resubmitted to the new one.
+	w.triggerRescanResubmit()
+	w.chainSwitchMtx.Unlock()
+
+	// The operations still using the old backend are drained before it's
+	// stopped. As they may require the chain client again, this is done
+	// once the switch lock is released, with them using the new backend
+	// from now on.
+	if oldUsers != nil {
+		oldUsers.Wait()
+	}
+	oldChain.Stop()
+
+	// The old backend's notification being handled may still be in
+	// progress, so we'll wait for its handler to exit before handling the
+	// new backend's.
+	if oldNtfnsWg != nil {
+		oldNtfnsWg.Wait()
+	}
+
+	log.Infof("Switched chain backend from %v to %v", oldChain.BackEnd(),
+		newChain.BackEnd())
+
+	// Finally, the new backend's handler catches up with it once notified
+	// of its connection, which also registers the wallet's addresses and
+	// unspent outputs for notifications.
+	synced := make(chan error, 1)
+	w.wg.Add(1)
+	go w.handleClientNotifications(newChain, ntfnsQuit, ntfnsWg, synced)
+
+	select {
+	case err := <-synced:
+		return err
+	case <-w.quitChan():
+		return ErrWalletShuttingDown
+	}
+}
+
+// triggerRescanResubmit signals the rescan batch handler to resubmit the rescan
+// in progress to the current chain client, if it was dispatched to another.
+func (w *Wallet) triggerRescanResubmit() {
+	select {
+	case w.rescanResubmit <- struct{}{}:
+	default:
+	}
+}
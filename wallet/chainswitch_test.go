@@ -0,0 +1,200 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// switchingChainClient is a mock chain backend serving its own view of a
+// chain, whose rescans finish at its tip.
+type switchingChainClient struct {
+	notifyingChainClient
+	*mockChainConn
+
+	stopped chan struct{}
+	rescans uint32
+}
+
+// IsCurrent returns whether the backend is synced to the chain tip.
+func (c *switchingChainClient) IsCurrent() bool {
+	return true
+}
+
+// Stop marks the backend as stopped.
+func (c *switchingChainClient) Stop() {
+	close(c.stopped)
+}
+
+// Rescan notifies the wallet of the end of the rescan at the backend's tip.
+func (c *switchingChainClient) Rescan(*chainhash.Hash, []btcutil.Address,
+	map[wire.OutPoint]btcutil.Address) error {
+
+	atomic.AddUint32(&c.rescans, 1)
+
+	tip := c.blockHashes[c.chainTip]
+	c.notifications <- &chain.RescanFinished{
+		Hash:   &tip,
+		Height: int32(c.chainTip),
+		Time:   c.blocks[tip].Header.Timestamp,
+	}
+	return nil
+}
+
+// TestSwitchChainBackend ensures that the wallet switched to another chain
+// backend stops processing the notifications of the old one and catches up
+// with the new one from the last block it processed, only once.
+func TestSwitchChainBackend(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+	w.recoveryWindow = 0
+
+	// Both backends share the same chain, with the new one being ahead of
+	// the old one.
+	const (
+		oldTip = 8
+		newTip = 12
+	)
+	chainConn := createMockChainConn(
+		chaincfg.TestNet3Params.GenesisBlock, newTip,
+		defaultBlockInterval,
+	)
+	newBackend := func(tip uint32) *switchingChainClient {
+		return &switchingChainClient{
+			notifyingChainClient: notifyingChainClient{
+				notifications: make(chan interface{}, 1),
+			},
+			mockChainConn: &mockChainConn{
+				chainTip:    tip,
+				blockHashes: chainConn.blockHashes,
+				blocks:      chainConn.blocks,
+			},
+			stopped: make(chan struct{}),
+		}
+	}
+	blockStamp := func(height int32) waddrmgr.BlockStamp {
+		hash := chainConn.blockHashes[uint32(height)]
+		return waddrmgr.BlockStamp{
+			Height:    height,
+			Hash:      hash,
+			Timestamp: chainConn.blocks[hash].Header.Timestamp,
+		}
+	}
+	syncedTo := func(height int32) {
+		t.Helper()
+
+		require.Eventually(t, func() bool {
+			return w.Manager.SyncedTo().Height == height
+		}, 10*time.Second, 10*time.Millisecond)
+	}
+
+	err := walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		return w.Manager.SetBirthdayBlock(ns, blockStamp(1), true)
+	})
+	require.NoError(t, err)
+
+	// The wallet processes the blocks notified by the old backend up to
+	// its tip.
+	oldChain := newBackend(oldTip)
+	w.chainClient = nil
+	w.SynchronizeRPC(oldChain)
+	defer func() {
+		w.Stop()
+		w.WaitForShutdown()
+	}()
+
+	for height := int32(1); height <= oldTip; height++ {
+		bs := blockStamp(height)
+		oldChain.notifications <- chain.BlockConnected{
+			Block: wtxmgr.Block{Hash: bs.Hash, Height: height},
+			Time:  bs.Timestamp,
+		}
+	}
+	syncedTo(oldTip)
+
+	// Once switched to the new backend, the old one is stopped and the
+	// wallet catches up with the new one from the last block it processed,
+	// such that every block of the chain has been processed.
+	//
+	// Like the actual backends, the new one has notified the wallet of its
+	// connection once started, which is when the wallet catches up with
+	// it.
+	//
+	// An operation still using the old backend is completed with it before
+	// it's stopped, while those started during the switch use the new one.
+	inUse, done, err := w.useChainClient()
+	require.NoError(t, err)
+	require.Equal(t, oldChain, inUse)
+
+	newChain := newBackend(newTip)
+	newChain.notifications <- chain.ClientConnected{}
+	switchErr := make(chan error, 1)
+	go func() {
+		switchErr <- w.SwitchChainBackend(newChain)
+	}()
+
+	require.Eventually(t, func() bool {
+		return w.ChainClient() == newChain
+	}, 10*time.Second, 10*time.Millisecond)
+	select {
+	case <-oldChain.stopped:
+		t.Fatal("old chain backend stopped while in use")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	done()
+	select {
+	case err := <-switchErr:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("chain backend not switched")
+	}
+	require.Equal(t, newChain, w.ChainClient())
+	require.Equal(t, uint32(1), atomic.LoadUint32(&newChain.rescans))
+
+	select {
+	case <-oldChain.stopped:
+	default:
+		t.Fatal("old chain backend not stopped")
+	}
+	syncedTo(newTip)
+
+	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(waddrmgrNamespaceKey)
+		for height := int32(1); height <= newTip; height++ {
+			hash, err := w.Manager.BlockHash(ns, height)
+			require.NoError(t, err)
+			require.Equal(t, blockStamp(height).Hash, *hash)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, blockStamp(newTip), w.Manager.SyncedTo())
+
+	// The notifications of the old backend are no longer processed.
+	bs := blockStamp(newTip)
+	oldChain.notifications <- chain.BlockDisconnected{
+		Block: wtxmgr.Block{Hash: bs.Hash, Height: bs.Height},
+		Time:  bs.Timestamp,
+	}
+	time.Sleep(100 * time.Millisecond)
+	require.Len(t, oldChain.notifications, 1)
+	require.Equal(t, bs, w.Manager.SyncedTo())
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+)
+
+// ErrInvalidChangeAddress is returned when the change address given through
+// WithChangeAddress isn't valid for the wallet's network.
+var ErrInvalidChangeAddress = errors.New("invalid change address")
+
+// WithChangeAddress sends the change of the created transaction to the given
+// address rather than to a new change address of the account, as required by
+// protocols returning change to a fixed address. The address need not belong
+// to the wallet, in which case the change is effectively sent: it isn't
+// credited to the wallet once the transaction is recorded, and doesn't count
+// towards its balance.
+func WithChangeAddress(addr btcutil.Address) TxCreateOption {
+	return func(opts *txCreateOptions) {
+		opts.changeAddress = addr
+	}
+}
+
+// explicitChangeSource returns a change source paying to the given address.
+func (w *Wallet) explicitChangeSource(
+	addr btcutil.Address) (*txauthor.ChangeSource, error) {
+
+	if !addr.IsForNet(w.chainParams) {
+		return nil, fmt.Errorf("%w: %v is not for %v",
+			ErrInvalidChangeAddress, addr, w.chainParams.Name)
+	}
+
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidChangeAddress, err)
+	}
+
+	return &txauthor.ChangeSource{
+		ScriptSize: len(script),
+		NewScript: func() ([]byte, error) {
+			return script, nil
+		},
+	}, nil
+}
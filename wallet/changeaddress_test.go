@@ -0,0 +1,80 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExplicitChangeAddress ensures that the change of a transaction can be
+// sent to an explicit address outside of the wallet, in which case it's
+// accounted for as sent rather than as part of the wallet's balance.
+func TestExplicitChangeAddress(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	const (
+		funds  = 1000000
+		amount = 100000
+	)
+	fundWallet(t, w, funds)
+
+	outputs := []*wire.TxOut{wire.NewTxOut(amount, testScriptP2WKH)}
+
+	// An address of another network is rejected.
+	mainnetAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), &chaincfg.MainNetParams,
+	)
+	require.NoError(t, err)
+	_, err = w.SendOutputs(
+		outputs, &waddrmgr.KeyScopeBIP0084, 0, 1, 1000,
+		CoinSelectionLargest, "", WithChangeAddress(mainnetAddr),
+	)
+	require.True(t, errors.Is(err, ErrInvalidChangeAddress), err)
+
+	changeAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		bytes.Repeat([]byte{0x01}, 20), w.chainParams,
+	)
+	require.NoError(t, err)
+	changeScript, err := txscript.PayToAddrScript(changeAddr)
+	require.NoError(t, err)
+
+	tx, err := w.SendOutputs(
+		outputs, &waddrmgr.KeyScopeBIP0084, 0, 1, 1000,
+		CoinSelectionLargest, "", WithChangeAddress(changeAddr),
+	)
+	require.NoError(t, err)
+	require.Len(t, tx.TxOut, 2)
+
+	var change *wire.TxOut
+	for _, txOut := range tx.TxOut {
+		if txOut.Value != amount {
+			change = txOut
+		}
+	}
+	require.NotNil(t, change)
+	require.Equal(t, changeScript, change.PkScript)
+
+	// Both the amount sent and the change left the wallet, so nothing
+	// remains of its balance.
+	balance, err := w.CalculateBalance(0)
+	require.NoError(t, err)
+	require.Equal(t, btcutil.Amount(0), balance)
+
+	balances, err := w.CalculateAccountBalances(0, 0)
+	require.NoError(t, err)
+	require.Equal(t, btcutil.Amount(0), balances.Total)
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// SetAccountChangeAddressType sets the address type of the change outputs of
+// the transactions funded by the given account from now on, overriding the
+// internal address type of the account's address schema, e.g. to send the
+// change of a native segwit account to nested segwit addresses. The type used
+// for a single transaction can still be overridden through
+// WithChangeAddressType.
+//
+// NOTE: Change addresses of another type than the account's address schema
+// aren't found when the wallet is recovered from its seed.
+func (w *Wallet) SetAccountChangeAddressType(scope waddrmgr.KeyScope,
+	account uint32, addrType waddrmgr.AddressType) error {
+
+	manager, err := w.Manager.FetchScopedKeyManager(scope)
+	if err != nil {
+		return err
+	}
+
+	var props *waddrmgr.AccountProperties
+	err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		addrmgrNs := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		err := manager.SetAccountChangeAddrType(
+			addrmgrNs, account, addrType,
+		)
+		if err != nil {
+			return err
+		}
+		props, err = manager.AccountProperties(addrmgrNs, account)
+		return err
+	})
+	if err == nil {
+		w.NtfnServer.notifyAccountProperties(props)
+	}
+	return err
+}
+
+// WithChangeAddressType sets the address type of the change output of the
+// created transaction, overriding the change address type of the funding
+// account. It has no effect when the change is sent to an address given
+// through WithChangeAddress.
+func WithChangeAddressType(addrType waddrmgr.AddressType) TxCreateOption {
+	return func(opts *txCreateOptions) {
+		opts.changeAddrType = &addrType
+	}
+}
+
+// newChangeAddressOfType returns a new change address of the given type for
+// the account, rather than of the account's change address type.
+func (w *Wallet) newChangeAddressOfType(addrmgrNs walletdb.ReadWriteBucket,
+	account uint32, scope waddrmgr.KeyScope,
+	addrType waddrmgr.AddressType) (btcutil.Address, error) {
+
+	manager, err := w.Manager.FetchScopedKeyManager(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := manager.NextInternalAddressesOfType(
+		addrmgrNs, account, 1, addrType,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return addrs[0].Address(), nil
+}
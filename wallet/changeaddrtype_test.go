@@ -0,0 +1,121 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAccountChangeAddressType ensures that the change of the transactions
+// funded by an account is sent to addresses of the change address type set for
+// it, unless another one is given for a transaction, and that the wallet keeps
+// recognizing the change addresses as its own.
+func TestAccountChangeAddressType(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	fundWallet(t, w, 1000000)
+	fundWallet(t, w, 1000000)
+	fundWallet(t, w, 1000000)
+	fundWallet(t, w, 1000000)
+
+	scope := waddrmgr.KeyScopeBIP0084
+	account := uint32(0)
+
+	// changeOutput creates a transaction funded by the account, returning
+	// the script class of its change output and the change address.
+	var changeAddrs []waddrmgr.ManagedAddress
+	changeOutput := func(opts ...TxCreateOption) txscript.ScriptClass {
+		t.Helper()
+
+		tx, err := w.CreateSimpleTx(
+			&scope, account, []*wire.TxOut{
+				wire.NewTxOut(100000, testScriptP2WKH),
+			}, 1, 1000, CoinSelectionLargest, false, opts...,
+		)
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, tx.ChangeIndex, 0)
+
+		pkScript := tx.Tx.TxOut[tx.ChangeIndex].PkScript
+		class, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			pkScript, w.chainParams,
+		)
+		require.NoError(t, err)
+		require.Len(t, addrs, 1)
+
+		info, err := w.AddressInfo(addrs[0])
+		require.NoError(t, err)
+		require.True(t, info.Address.Internal())
+		changeAddrs = append(changeAddrs, info.Address)
+
+		return class
+	}
+
+	// By default, the change is sent to native segwit addresses as per
+	// the account's address schema.
+	require.Equal(t, txscript.WitnessV0PubKeyHashTy, changeOutput())
+
+	err := w.SetAccountChangeAddressType(
+		scope, account, waddrmgr.NestedWitnessPubKey,
+	)
+	require.NoError(t, err)
+	require.Equal(t, txscript.ScriptHashTy, changeOutput())
+
+	// A type given for a transaction takes precedence over the account's,
+	// which remains in use for the following transactions.
+	require.Equal(t, txscript.PubKeyHashTy, changeOutput(
+		WithChangeAddressType(waddrmgr.PubKeyHash),
+	))
+	require.Equal(t, txscript.ScriptHashTy, changeOutput())
+
+	props, err := w.AccountProperties(scope, account)
+	require.NoError(t, err)
+	require.Equal(t, waddrmgr.NestedWitnessPubKey, props.ChangeAddrType)
+
+	// Once the account is loaded from the database again, every change
+	// address is still derived as its type.
+	manager, err := w.Manager.FetchScopedKeyManager(scope)
+	require.NoError(t, err)
+	manager.InvalidateAccountCache(account)
+
+	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		addrmgrNs := tx.ReadBucket(waddrmgrNamespaceKey)
+		mismatches, err := manager.VerifyAccountAddresses(
+			addrmgrNs, account, props.AccountPubKey,
+		)
+		require.NoError(t, err)
+		require.Empty(t, mismatches)
+
+		for _, changeAddr := range changeAddrs {
+			pubKeyAddr := changeAddr.(waddrmgr.ManagedPubKeyAddress)
+			_, path, _ := pubKeyAddr.DerivationInfo()
+			derived, err := manager.DeriveFromKeyPath(
+				addrmgrNs, path,
+			)
+			require.NoError(t, err)
+			require.Equal(
+				t, changeAddr.AddrType(), derived.AddrType(),
+			)
+			require.Equal(
+				t, changeAddr.Address().String(),
+				derived.Address().String(),
+			)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Types that can't be derived for an account are rejected.
+	err = w.SetAccountChangeAddressType(scope, account, waddrmgr.Script)
+	require.True(t, waddrmgr.IsError(err, waddrmgr.ErrInvalidAddrType))
+}
@@ -0,0 +1,282 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// reorgTestBlock returns the block of the test chain at the given height.
+func reorgTestBlock(height int32) wtxmgr.BlockMeta {
+	return wtxmgr.BlockMeta{
+		Block: wtxmgr.Block{
+			Hash: chainhash.Hash{
+				byte(height), byte(height >> 8), 0xcb,
+			},
+			Height: height,
+		},
+		Time: time.Unix(1600000000+int64(height)*600, 0),
+	}
+}
+
+// connectReorgTestBlocks connects the blocks of the test chain to the wallet
+// up to the given height, confirming the given transactions within the last
+// one.
+func connectReorgTestBlocks(t *testing.T, w *Wallet, height int32,
+	txs ...*wire.MsgTx) {
+
+	t.Helper()
+
+	for next := w.Manager.SyncedTo().Height + 1; next <= height; next++ {
+		block := reorgTestBlock(next)
+		err := walletdb.Update(w.db, func(
+			dbtx walletdb.ReadWriteTx) error {
+
+			if next < height {
+				return w.connectBlock(dbtx, block)
+			}
+
+			for _, tx := range txs {
+				rec, err := wtxmgr.NewTxRecordFromMsgTx(
+					tx, block.Time,
+				)
+				if err != nil {
+					return err
+				}
+				err = w.addRelevantTx(dbtx, rec, &block)
+				if err != nil {
+					return err
+				}
+			}
+			return w.connectBlock(dbtx, block)
+		})
+		require.NoError(t, err)
+	}
+}
+
+// disconnectReorgTestBlocks disconnects the blocks of the test chain from the
+// wallet down to the given height, inclusive.
+func disconnectReorgTestBlocks(t *testing.T, w *Wallet, height int32) {
+	t.Helper()
+
+	err := walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		return w.disconnectBlock(dbtx, reorgTestBlock(height))
+	})
+	require.NoError(t, err)
+	require.Equal(t, height-1, w.Manager.SyncedTo().Height)
+}
+
+// newReorgTestCoinbase returns a coinbase transaction paying the given value
+// to a new address of the wallet.
+func newReorgTestCoinbase(t *testing.T, w *Wallet,
+	value int64) *wire.MsgTx {
+
+	t.Helper()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(
+		wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex),
+		[]byte{0x01, 0x02}, nil,
+	))
+	coinbase.AddTxOut(wire.NewTxOut(value, pkScript))
+	return coinbase
+}
+
+// assertNoWalletFunds asserts that the wallet holds no funds, with no trace
+// left of the given transactions.
+func assertNoWalletFunds(t *testing.T, w *Wallet, txs ...*wire.MsgTx) {
+	t.Helper()
+
+	balances, err := w.CalculateAccountBalances(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, Balances{}, balances)
+
+	for _, confs := range []int32{0, 1} {
+		balance, err := w.CalculateBalance(confs)
+		require.NoError(t, err)
+		require.Zero(t, balance)
+	}
+
+	leased, err := w.ListLeasedOutputs()
+	require.NoError(t, err)
+	require.Empty(t, leased)
+
+	unspent, err := w.ListUnspent(0, 9999999, "")
+	require.NoError(t, err)
+	require.Empty(t, unspent)
+
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		for _, tx := range txs {
+			txHash := tx.TxHash()
+			details, err := w.TxStore.TxDetails(ns, &txHash)
+			if err != nil {
+				return err
+			}
+			require.Nil(t, details, txHash)
+		}
+
+		unmined, err := w.TxStore.UnminedTxs(ns)
+		if err != nil {
+			return err
+		}
+		require.Empty(t, unmined)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// TestReorgImmatureCoinbase ensures that reorging out the block of an
+// immature coinbase output removes it from the wallet, without leaving any
+// immature balance behind.
+func TestReorgImmatureCoinbase(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+	w.chainClient = &headerChainClient{}
+	w.SetChainSynced(true)
+
+	const (
+		startHeight    = 100
+		coinbaseHeight = startHeight + 1
+		value          = 50 * btcutil.SatoshiPerBitcoin
+	)
+	setSyncedHeight(t, w, startHeight)
+
+	coinbase := newReorgTestCoinbase(t, w, value)
+	connectReorgTestBlocks(t, w, coinbaseHeight, coinbase)
+	connectReorgTestBlocks(t, w, coinbaseHeight+10)
+
+	balances, err := w.CalculateAccountBalances(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, btcutil.Amount(value), balances.Total)
+	require.Equal(t, btcutil.Amount(value), balances.ImmatureReward)
+	require.Zero(t, balances.Spendable)
+
+	// Lease the immature output, e.g. to sweep it once it matures.
+	_, err = w.LeaseOutput(
+		wtxmgr.LockID{0x01}, wire.OutPoint{Hash: coinbase.TxHash()},
+		time.Hour,
+	)
+	require.NoError(t, err)
+
+	disconnectReorgTestBlocks(t, w, coinbaseHeight)
+	assertNoWalletFunds(t, w, coinbase)
+
+	// The coinbase isn't credited again once the chain is extended.
+	connectReorgTestBlocks(t, w, coinbaseHeight+10)
+	assertNoWalletFunds(t, w, coinbase)
+}
+
+// TestReorgSpentCoinbase ensures that reorging out the block of a matured
+// coinbase output removes the transactions spending it from the wallet,
+// whether they're mined or not, along with their descendants.
+func TestReorgSpentCoinbase(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		mineSpend   bool
+		mineDescend bool
+	}{
+		{
+			name: "unmined spend",
+		},
+		{
+			name:      "mined spend with unmined descendant",
+			mineSpend: true,
+		},
+		{
+			name:        "mined spend and descendant",
+			mineSpend:   true,
+			mineDescend: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			testReorgSpentCoinbase(
+				t, test.mineSpend, test.mineDescend,
+			)
+		})
+	}
+}
+
+func testReorgSpentCoinbase(t *testing.T, mineSpend, mineDescend bool) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+	w.chainClient = &headerChainClient{}
+	w.SetChainSynced(true)
+
+	const (
+		startHeight    = 100
+		coinbaseHeight = startHeight + 1
+		value          = 50 * btcutil.SatoshiPerBitcoin
+	)
+	setSyncedHeight(t, w, startHeight)
+	maturity := int32(w.chainParams.CoinbaseMaturity)
+
+	coinbase := newReorgTestCoinbase(t, w, value)
+	connectReorgTestBlocks(t, w, coinbaseHeight, coinbase)
+	connectReorgTestBlocks(t, w, coinbaseHeight+maturity-1)
+
+	balances, err := w.CalculateAccountBalances(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, btcutil.Amount(value), balances.Spendable)
+	require.Zero(t, balances.ImmatureReward)
+
+	// Spend the matured coinbase, with its change spent by a descendant.
+	spend, err := w.SendOutputs(
+		[]*wire.TxOut{wire.NewTxOut(100000, testScriptP2WKH)},
+		&waddrmgr.KeyScopeBIP0084, 0, 1, 1000, CoinSelectionLargest,
+		"",
+	)
+	require.NoError(t, err)
+	prevOut := spend.TxIn[0].PreviousOutPoint
+	require.Equal(t, coinbase.TxHash(), prevOut.Hash)
+
+	height := coinbaseHeight + maturity - 1
+	if mineSpend {
+		height++
+		connectReorgTestBlocks(t, w, height, spend)
+	}
+
+	descendant, err := w.SendOutputs(
+		[]*wire.TxOut{wire.NewTxOut(100000, testScriptP2WKH)},
+		&waddrmgr.KeyScopeBIP0084, 0, 0, 1000, CoinSelectionLargest,
+		"",
+	)
+	require.NoError(t, err)
+	prevOut = descendant.TxIn[0].PreviousOutPoint
+	require.Equal(t, spend.TxHash(), prevOut.Hash)
+
+	if mineDescend {
+		height++
+		connectReorgTestBlocks(t, w, height, descendant)
+	}
+
+	disconnectReorgTestBlocks(t, w, coinbaseHeight)
+	assertNoWalletFunds(t, w, coinbase, spend, descendant)
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// CoinbasePreference determines how coinbase outputs are selected relative to
+// regular ones when funding a transaction.
+type CoinbasePreference uint8
+
+const (
+	// CoinbaseNoPreference selects coinbase and regular outputs alike,
+	// according to the coin selection strategy.
+	CoinbaseNoPreference CoinbasePreference = iota
+
+	// PreferCoinbaseFirst selects coinbase outputs before any regular
+	// ones, each in the order of the coin selection strategy.
+	PreferCoinbaseFirst
+
+	// AvoidMixingCoinbase funds the transaction with either only regular
+	// outputs or only coinbase outputs, trying regular ones first. If
+	// neither suffices, both are selected according to the coin selection
+	// strategy, in which case MixesCoinbase reports the created
+	// transaction as mixing them.
+	AvoidMixingCoinbase
+)
+
+// WithCoinbasePreference sets how the inputs of the created transaction are
+// selected among coinbase and regular outputs, which defaults to
+// CoinbaseNoPreference.
+func WithCoinbasePreference(pref CoinbasePreference) TxCreateOption {
+	return func(opts *txCreateOptions) {
+		opts.coinbasePreference = pref
+	}
+}
+
+// splitCoinbase splits the credits into coinbase and regular ones, preserving
+// their order.
+func splitCoinbase(credits []wtxmgr.Credit) ([]wtxmgr.Credit,
+	[]wtxmgr.Credit) {
+
+	var coinbase, regular []wtxmgr.Credit
+	for _, credit := range credits {
+		if credit.FromCoinBase {
+			coinbase = append(coinbase, credit)
+		} else {
+			regular = append(regular, credit)
+		}
+	}
+
+	return coinbase, regular
+}
+
+// authorWithCoinbasePreference creates an unsigned transaction paying to the
+// outputs, selecting its inputs among the given credits, in order, according
+// to the coinbase preference.
+func authorWithCoinbasePreference(outputs []*wire.TxOut,
+	feeSatPerKb btcutil.Amount, credits []wtxmgr.Credit,
+	changeSource *txauthor.ChangeSource,
+	pref CoinbasePreference) (*txauthor.AuthoredTx, error) {
+
+	author := func(credits []wtxmgr.Credit) (*txauthor.AuthoredTx, error) {
+		return txauthor.NewUnsignedTransaction(
+			outputs, feeSatPerKb, makeInputSource(credits),
+			changeSource,
+		)
+	}
+
+	coinbase, regular := splitCoinbase(credits)
+
+	switch pref {
+	case PreferCoinbaseFirst:
+		return author(append(coinbase, regular...))
+
+	case AvoidMixingCoinbase:
+		// The change source is only invoked once enough inputs have
+		// been found, so a failed attempt doesn't derive a change
+		// address.
+		for _, kind := range [][]wtxmgr.Credit{regular, coinbase} {
+			if len(kind) == 0 {
+				continue
+			}
+
+			tx, err := author(kind)
+			if _, ok := err.(txauthor.InputSourceError); ok {
+				continue
+			}
+			return tx, err
+		}
+
+		tx, err := author(credits)
+		if err != nil {
+			return nil, err
+		}
+		if mixesCoinbase(tx.Tx, coinbaseOutPoints(coinbase)) {
+			log.Warnf("Unable to fund transaction without mixing " +
+				"coinbase and regular outputs")
+		}
+		return tx, nil
+
+	default:
+		return author(credits)
+	}
+}
+
+// MixesCoinbase returns whether the transaction spends both coinbase outputs
+// and regular ones, as transactions created with the AvoidMixingCoinbase
+// preference may do if neither kind of output suffices on its own. Outputs
+// spent by the transaction that are unknown to the wallet are considered
+// regular.
+func (w *Wallet) MixesCoinbase(tx *wire.MsgTx) (bool, error) {
+	coinbase := make(map[wire.OutPoint]struct{})
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		for _, txIn := range tx.TxIn {
+			prevOut := txIn.PreviousOutPoint
+			details, err := w.TxStore.TxDetails(
+				txmgrNs, &prevOut.Hash,
+			)
+			if err != nil {
+				return err
+			}
+			if details != nil &&
+				blockchain.IsCoinBaseTx(&details.MsgTx) {
+
+				coinbase[prevOut] = struct{}{}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return mixesCoinbase(tx, coinbase), nil
+}
+
+// coinbaseOutPoints returns the set of outpoints of the coinbase credits.
+func coinbaseOutPoints(coinbase []wtxmgr.Credit) map[wire.OutPoint]struct{} {
+	outPoints := make(map[wire.OutPoint]struct{}, len(coinbase))
+	for _, credit := range coinbase {
+		outPoints[credit.OutPoint] = struct{}{}
+	}
+
+	return outPoints
+}
+
+// mixesCoinbase returns whether the transaction spends both some of the given
+// coinbase outpoints and other outputs.
+func mixesCoinbase(tx *wire.MsgTx,
+	coinbase map[wire.OutPoint]struct{}) bool {
+
+	var numCoinbase int
+	for _, txIn := range tx.TxIn {
+		if _, ok := coinbase[txIn.PreviousOutPoint]; ok {
+			numCoinbase++
+		}
+	}
+
+	return numCoinbase > 0 && numCoinbase < len(tx.TxIn)
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCoinbasePreference ensures that coin selection honors the coinbase
+// preference given a mixed set of coinbase and regular outputs.
+func TestCoinbasePreference(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	// Fund the wallet with mature coinbase outputs worth slightly more in
+	// total than its regular outputs.
+	coinbaseTx := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{
+				Index: wire.MaxPrevOutIndex,
+			},
+			SignatureScript: []byte{0x01, 0x01},
+		}},
+		TxOut: []*wire.TxOut{
+			wire.NewTxOut(400000, pkScript),
+			wire.NewTxOut(350000, pkScript),
+		},
+	}
+	regularTx := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{
+			wire.NewTxOut(500000, pkScript),
+			wire.NewTxOut(200000, pkScript),
+		},
+	}
+	addUtxo(t, w, coinbaseTx)
+	addUtxo(t, w, regularTx)
+
+	// create returns the number of coinbase and regular inputs of the
+	// transaction paying the amount, created with the given preference,
+	// and whether it mixes them.
+	create := func(amount int64, pref CoinbasePreference) (int, int,
+		bool) {
+
+		t.Helper()
+
+		tx, err := w.CreateSimpleTx(
+			nil, 0, []*wire.TxOut{
+				wire.NewTxOut(amount, testScriptP2WKH),
+			}, 1, 1000, CoinSelectionLargest, true,
+			WithCoinbasePreference(pref),
+		)
+		require.NoError(t, err)
+
+		var numCoinbase, numRegular int
+		for _, txIn := range tx.Tx.TxIn {
+			switch txIn.PreviousOutPoint.Hash {
+			case coinbaseTx.TxHash():
+				numCoinbase++
+			case regularTx.TxHash():
+				numRegular++
+			default:
+				t.Fatalf("unexpected input %v",
+					txIn.PreviousOutPoint)
+			}
+		}
+		mixed, err := w.MixesCoinbase(tx.Tx)
+		require.NoError(t, err)
+		return numCoinbase, numRegular, mixed
+	}
+
+	tests := []struct {
+		name        string
+		amount      int64
+		pref        CoinbasePreference
+		numCoinbase int
+		numRegular  int
+		mixed       bool
+	}{
+		{
+			// The largest outputs are selected regardless of
+			// their kind.
+			name:        "no preference",
+			amount:      600000,
+			pref:        CoinbaseNoPreference,
+			numCoinbase: 1,
+			numRegular:  1,
+			mixed:       true,
+		},
+		{
+			name:        "prefer coinbase first, coinbase suffices",
+			amount:      100000,
+			pref:        PreferCoinbaseFirst,
+			numCoinbase: 1,
+		},
+		{
+			// All coinbase outputs should be spent before the
+			// regular ones.
+			name:        "prefer coinbase first, coinbase exhausted",
+			amount:      800000,
+			pref:        PreferCoinbaseFirst,
+			numCoinbase: 2,
+			numRegular:  1,
+			mixed:       true,
+		},
+		{
+			name:       "avoid mixing, regular suffices",
+			amount:     600000,
+			pref:       AvoidMixingCoinbase,
+			numRegular: 2,
+		},
+		{
+			name:        "avoid mixing, only coinbase suffices",
+			amount:      720000,
+			pref:        AvoidMixingCoinbase,
+			numCoinbase: 2,
+		},
+		{
+			// With neither kind sufficing on its own, the outputs
+			// are mixed, as reported by MixesCoinbase.
+			name:        "avoid mixing, mixed fallback",
+			amount:      1000000,
+			pref:        AvoidMixingCoinbase,
+			numCoinbase: 2,
+			numRegular:  1,
+			mixed:       true,
+		},
+	}
+
+	for _, test := range tests {
+		numCoinbase, numRegular, mixed := create(test.amount, test.pref)
+		require.Equal(t, test.numCoinbase, numCoinbase, test.name)
+		require.Equal(t, test.numRegular, numRegular, test.name)
+		require.Equal(t, test.mixed, mixed, test.name)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+const (
+	// coinbaseSweepLeaseDuration is the duration for which the coinbase
+	// outputs being swept are leased, preventing them from being selected
+	// elsewhere while the sweep is being created and published.
+	coinbaseSweepLeaseDuration = 10 * time.Minute
+
+	// coinbaseSweepLabel is the label given to the coinbase sweep
+	// transactions created by the wallet.
+	coinbaseSweepLabel = "coinbase sweep"
+)
+
+var (
+	// CoinbaseSweepLockID is the ID used to lease the coinbase outputs
+	// being swept by the wallet.
+	CoinbaseSweepLockID = wtxmgr.LockID(
+		chainhash.HashH([]byte("btcwallet/coinbase-sweep")),
+	)
+
+	// ErrInvalidSweepConfig is returned when attempting to enable coinbase
+	// sweeping with an invalid configuration.
+	ErrInvalidSweepConfig = errors.New("invalid coinbase sweep config")
+)
+
+// CoinbaseSweepConfig contains the parameters with which the wallet sweeps its
+// coinbase outputs once they mature.
+type CoinbaseSweepConfig struct {
+	// Address is the address mature coinbase outputs are swept to.
+	Address btcutil.Address
+
+	// FeeRate is the fee rate, in satoshis per kB, paid by the sweep
+	// transactions.
+	FeeRate btcutil.Amount
+}
+
+// EnableCoinbaseSweep enables sweeping the wallet's coinbase outputs to the
+// configured address once they mature. Any coinbase outputs that are already
+// mature are swept once the wallet is started and synced to the chain.
+func (w *Wallet) EnableCoinbaseSweep(cfg CoinbaseSweepConfig) error {
+	if cfg.Address == nil || !cfg.Address.IsForNet(w.chainParams) {
+		return fmt.Errorf("%w: address must be set and belong to %v",
+			ErrInvalidSweepConfig, w.chainParams.Name)
+	}
+	if cfg.FeeRate <= 0 {
+		return fmt.Errorf("%w: fee rate must be positive",
+			ErrInvalidSweepConfig)
+	}
+
+	w.coinbaseSweepMtx.Lock()
+	w.coinbaseSweepCfg = &cfg
+	w.coinbaseSweepMtx.Unlock()
+
+	w.triggerCoinbaseSweep()
+
+	return nil
+}
+
+// DisableCoinbaseSweep disables sweeping the wallet's mature coinbase outputs.
+// Sweeps already published are unaffected.
+func (w *Wallet) DisableCoinbaseSweep() {
+	w.coinbaseSweepMtx.Lock()
+	w.coinbaseSweepCfg = nil
+	w.coinbaseSweepMtx.Unlock()
+}
+
+// coinbaseSweepConfig returns the current coinbase sweep config, or nil if
+// sweeping is disabled.
+func (w *Wallet) coinbaseSweepConfig() *CoinbaseSweepConfig {
+	w.coinbaseSweepMtx.Lock()
+	defer w.coinbaseSweepMtx.Unlock()
+
+	return w.coinbaseSweepCfg
+}
+
+// triggerCoinbaseSweep requests the coinbase sweeper to check for mature
+// coinbase outputs without blocking the caller.
+func (w *Wallet) triggerCoinbaseSweep() {
+	select {
+	case w.coinbaseSweepTrigger <- struct{}{}:
+	default:
+	}
+}
+
+// coinbaseSweeper sweeps the wallet's mature coinbase outputs whenever it's
+// triggered and sweeping is enabled.
+//
+// NOTE: This must be run as a goroutine.
+func (w *Wallet) coinbaseSweeper() {
+	defer w.wg.Done()
+
+	quit := w.quitChan()
+	for {
+		select {
+		case <-w.coinbaseSweepTrigger:
+		case <-quit:
+			return
+		}
+
+		cfg := w.coinbaseSweepConfig()
+		if cfg == nil || !w.ChainSynced() {
+			continue
+		}
+
+		if _, err := w.sweepMatureCoinbases(cfg); err != nil {
+			log.Errorf("Unable to sweep mature coinbase outputs: %v",
+				err)
+		}
+	}
+}
+
+// matureCoinbaseCredits returns the wallet's unspent coinbase outputs that
+// have reached maturity at the height the wallet is synced to. Outputs that
+// are leased, frozen, denylisted, or spent by an unconfirmed transaction, such
+// as a pending sweep, are not returned.
+func (w *Wallet) matureCoinbaseCredits() ([]wtxmgr.Credit, error) {
+	var credits []wtxmgr.Credit
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		unspent, err := w.TxStore.UnspentOutputs(txmgrNs)
+		if err != nil {
+			return err
+		}
+
+		syncedHeight := w.Manager.SyncedTo().Height
+		maturity := int32(w.chainParams.CoinbaseMaturity)
+		for _, credit := range unspent {
+			if !credit.FromCoinBase {
+				continue
+			}
+			if !confirmed(maturity, credit.Height, syncedHeight) {
+				continue
+			}
+			op := credit.OutPoint
+			if w.LockedOutpoint(op) ||
+				w.TxStore.IsFrozenOutput(txmgrNs, op) ||
+				w.TxStore.IsSelectionDenylisted(
+					txmgrNs, op, credit.PkScript,
+				) {
+
+				continue
+			}
+			credits = append(credits, credit)
+		}
+
+		return nil
+	})
+	return credits, err
+}
+
+// sweepMatureCoinbases creates and publishes a transaction sweeping all of the
+// wallet's mature coinbase outputs according to cfg. The outputs are leased
+// for the duration of the sweep to prevent them from being spent concurrently.
+// If there are no outputs to sweep, nil is returned.
+func (w *Wallet) sweepMatureCoinbases(
+	cfg *CoinbaseSweepConfig) (*CoinbaseSweepNotification, error) {
+
+	credits, err := w.matureCoinbaseCredits()
+	if err != nil || len(credits) == 0 {
+		return nil, err
+	}
+
+	// Lease every output we intend to sweep. Any we're unable to lease
+	// have been leased elsewhere in the meantime, so we'll leave them be.
+	var leased []wtxmgr.Credit
+	for _, credit := range credits {
+		_, err := w.LeaseOutput(
+			CoinbaseSweepLockID, credit.OutPoint,
+			coinbaseSweepLeaseDuration,
+		)
+		switch {
+		case errors.Is(err, wtxmgr.ErrOutputAlreadyLocked):
+			continue
+		case err != nil:
+			w.releaseCoinbaseSweepLeases(leased)
+			return nil, err
+		}
+		leased = append(leased, credit)
+	}
+	if len(leased) == 0 {
+		return nil, nil
+	}
+
+	ntfn, err := w.createCoinbaseSweep(leased, cfg)
+	if err == nil {
+		err = w.PublishTransaction(ntfn.Tx, coinbaseSweepLabel)
+	}
+	if err != nil {
+		w.releaseCoinbaseSweepLeases(leased)
+		return nil, err
+	}
+
+	log.Infof("Swept %d mature coinbase output(s) totaling %v to %v in "+
+		"transaction %v", len(ntfn.Inputs), ntfn.Amount, cfg.Address,
+		ntfn.Tx.TxHash())
+
+	w.NtfnServer.notifyCoinbaseSweep(ntfn)
+
+	return ntfn, nil
+}
+
+// createCoinbaseSweep creates a signed transaction spending all of the given
+// credits to the configured sweep address at the configured fee rate.
+func (w *Wallet) createCoinbaseSweep(credits []wtxmgr.Credit,
+	cfg *CoinbaseSweepConfig) (*CoinbaseSweepNotification, error) {
+
+	pkScript, err := txscript.PayToAddrScript(cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, fee, err := w.createSweepTx(credits, pkScript, cfg.FeeRate)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make([]wire.OutPoint, 0, len(credits))
+	for _, credit := range credits {
+		inputs = append(inputs, credit.OutPoint)
+	}
+
+	return &CoinbaseSweepNotification{
+		Tx:      tx,
+		Inputs:  inputs,
+		Address: cfg.Address,
+		Amount:  btcutil.Amount(tx.TxOut[0].Value),
+		Fee:     fee,
+	}, nil
+}
+
+// releaseCoinbaseSweepLeases releases the leases acquired for a coinbase sweep
+// that could not be completed.
+func (w *Wallet) releaseCoinbaseSweepLeases(credits []wtxmgr.Credit) {
+	for _, credit := range credits {
+		err := w.ReleaseOutput(CoinbaseSweepLockID, credit.OutPoint)
+		if err != nil {
+			log.Warnf("Unable to release coinbase output %v: %v",
+				credit.OutPoint, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// setSyncedHeight marks the wallet as synced up to the given height.
+func setSyncedHeight(t *testing.T, w *Wallet, height int32) {
+	t.Helper()
+
+	err := walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		ns := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
+		return w.Manager.SetSyncedTo(ns, &waddrmgr.BlockStamp{
+			Height:    height,
+			Hash:      chainhash.Hash{byte(height)},
+			Timestamp: time.Now(),
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to set synced height: %v", err)
+	}
+}
+
+// TestCoinbaseSweep ensures that coinbase outputs are only swept once they've
+// matured, and that they're never swept more than once.
+func TestCoinbaseSweep(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	sweepAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), w.chainParams,
+	)
+	if err != nil {
+		t.Fatalf("unable to create sweep address: %v", err)
+	}
+
+	// An invalid configuration should be rejected.
+	err = w.EnableCoinbaseSweep(CoinbaseSweepConfig{Address: sweepAddr})
+	if !errors.Is(err, ErrInvalidSweepConfig) {
+		t.Fatalf("expected ErrInvalidSweepConfig, got %v", err)
+	}
+
+	// Add a coinbase output paying to the wallet at height 1.
+	const (
+		value       = 50 * btcutil.SatoshiPerBitcoin
+		blockHeight = 1
+	)
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(
+		wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex),
+		[]byte{0x51, 0x51}, nil,
+	))
+	coinbase.AddTxOut(wire.NewTxOut(value, pkScript))
+
+	var b bytes.Buffer
+	if err := coinbase.Serialize(&b); err != nil {
+		t.Fatalf("unable to serialize tx: %v", err)
+	}
+	rec, err := wtxmgr.NewTxRecord(b.Bytes(), time.Now())
+	if err != nil {
+		t.Fatalf("unable to create tx record: %v", err)
+	}
+	block := &wtxmgr.BlockMeta{
+		Block: wtxmgr.Block{
+			Hash:   chainhash.Hash{blockHeight},
+			Height: blockHeight,
+		},
+		Time: time.Now(),
+	}
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		ns := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+		if err := w.TxStore.InsertTx(ns, rec, block); err != nil {
+			return err
+		}
+		return w.TxStore.AddCredit(ns, rec, block, 0, false)
+	})
+	if err != nil {
+		t.Fatalf("failed inserting tx: %v", err)
+	}
+
+	cfg := &CoinbaseSweepConfig{
+		Address: sweepAddr,
+		FeeRate: 5000,
+	}
+
+	// The output hasn't matured yet, so there should be nothing to sweep.
+	maturity := int32(w.chainParams.CoinbaseMaturity)
+	setSyncedHeight(t, w, blockHeight+maturity-2)
+	ntfn, err := w.sweepMatureCoinbases(cfg)
+	if err != nil {
+		t.Fatalf("unable to sweep coinbase outputs: %v", err)
+	}
+	if ntfn != nil {
+		t.Fatalf("expected immature coinbase output to not be swept")
+	}
+
+	// Once it matures, it should be swept to the configured address, with
+	// a notification being sent for the sweep.
+	client := w.NtfnServer.CoinbaseSweepNotifications()
+	defer client.Done()
+	received := make(chan *CoinbaseSweepNotification, 1)
+	go func() {
+		received <- <-client.C
+	}()
+
+	setSyncedHeight(t, w, blockHeight+maturity-1)
+	ntfn, err = w.sweepMatureCoinbases(cfg)
+	if err != nil {
+		t.Fatalf("unable to sweep coinbase outputs: %v", err)
+	}
+	if ntfn == nil {
+		t.Fatalf("expected mature coinbase output to be swept")
+	}
+
+	sweepTx := ntfn.Tx
+	if len(sweepTx.TxIn) != 1 ||
+		sweepTx.TxIn[0].PreviousOutPoint.Hash != rec.Hash {
+
+		t.Fatalf("expected sweep to spend %v:0, got %v", rec.Hash,
+			sweepTx.TxIn)
+	}
+	sweepScript, err := txscript.PayToAddrScript(sweepAddr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	if len(sweepTx.TxOut) != 1 ||
+		!bytes.Equal(sweepTx.TxOut[0].PkScript, sweepScript) {
+
+		t.Fatalf("expected a single output to the sweep address")
+	}
+	if ntfn.Amount+ntfn.Fee != value {
+		t.Fatalf("expected sweep of %v minus fee %v, got %v",
+			btcutil.Amount(value), ntfn.Fee, ntfn.Amount)
+	}
+
+	select {
+	case n := <-received:
+		if n.Tx.TxHash() != sweepTx.TxHash() {
+			t.Fatalf("expected notification for sweep %v, got %v",
+				sweepTx.TxHash(), n.Tx.TxHash())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected coinbase sweep notification")
+	}
+
+	// The output is now spent by the pending sweep, so it shouldn't be
+	// swept again.
+	ntfn, err = w.sweepMatureCoinbases(cfg)
+	if err != nil {
+		t.Fatalf("unable to sweep coinbase outputs: %v", err)
+	}
+	if ntfn != nil {
+		t.Fatalf("expected pending sweep to not be swept again")
+	}
+}
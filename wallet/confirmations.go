@@ -0,0 +1,296 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// ErrTxConflicted is returned when waiting for the confirmation of a
+// transaction that is removed from the wallet, either because a conflicting
+// transaction replaced or double spent it, or because it was abandoned.
+var ErrTxConflicted = errors.New("transaction conflicted or abandoned")
+
+// DefaultFinalityDepth is the default number of confirmations at which a
+// transaction is considered final.
+const DefaultFinalityDepth = 6
+
+// TxFinality describes how final a wallet transaction is.
+type TxFinality uint8
+
+const (
+	// TxUnconfirmed indicates the transaction hasn't been mined.
+	TxUnconfirmed TxFinality = iota
+
+	// TxPendingConfirmed indicates the transaction has been mined, but
+	// hasn't reached the finality depth yet, or, for coinbase
+	// transactions, hasn't matured yet.
+	TxPendingConfirmed
+
+	// TxFinal indicates the transaction has reached the finality depth,
+	// and, for coinbase transactions, has matured.
+	TxFinal
+)
+
+// String returns the string representation of the transaction finality.
+func (f TxFinality) String() string {
+	switch f {
+	case TxUnconfirmed:
+		return "unconfirmed"
+	case TxPendingConfirmed:
+		return "pending-confirmed"
+	case TxFinal:
+		return "final"
+	default:
+		return "unknown"
+	}
+}
+
+// ConfirmationReference determines the chain height the confirmation counts
+// reported by the wallet are computed against.
+type ConfirmationReference uint8
+
+const (
+	// ConfirmationsFromSyncedTip computes confirmation counts against the
+	// last block processed by the wallet. This is the default.
+	ConfirmationsFromSyncedTip ConfirmationReference = iota
+
+	// ConfirmationsFromBestChain computes confirmation counts against the
+	// best height of the chain backend, fetched for every query, such that
+	// they aren't undercounted while the wallet catches up with the chain.
+	ConfirmationsFromBestChain
+)
+
+// String returns the string representation of the confirmation reference.
+func (r ConfirmationReference) String() string {
+	switch r {
+	case ConfirmationsFromSyncedTip:
+		return "synced-tip"
+	case ConfirmationsFromBestChain:
+		return "best-chain"
+	default:
+		return "unknown"
+	}
+}
+
+// SetConfirmationReference sets the chain height the confirmation counts of
+// the wallet's transaction listings and received totals, and of
+// TransactionFinality, are computed against. Computing them against the
+// backend's best height costs an RPC for every query, and is only used when
+// it's ahead of the last block processed by the wallet. Balances, ListUnspent
+// and the outputs eligible for coin selection keep being computed against the
+// last processed block.
+//
+// NOTE: This should be called before the wallet is queried for the
+// confirmations of any transactions.
+func (w *Wallet) SetConfirmationReference(ref ConfirmationReference) {
+	w.confirmationReference = ref
+}
+
+// confirmationHeight returns the chain height confirmation counts are computed
+// against, according to the wallet's confirmation reference. The last block
+// processed by the wallet is used if the backend's best height can't be
+// fetched.
+func (w *Wallet) confirmationHeight() int32 {
+	syncedHeight := w.Manager.SyncedTo().Height
+	if w.confirmationReference != ConfirmationsFromBestChain {
+		return syncedHeight
+	}
+
+	chainClient := w.ChainClient()
+	if chainClient == nil {
+		return syncedHeight
+	}
+	_, bestHeight, err := chainClient.GetBestBlock()
+	if err != nil {
+		log.Warnf("Unable to fetch best height for confirmation "+
+			"counts: %v", err)
+		return syncedHeight
+	}
+	if bestHeight > syncedHeight {
+		return bestHeight
+	}
+	return syncedHeight
+}
+
+// SetFinalityDepth sets the number of confirmations at which a transaction is
+// considered final by TransactionFinality and IsFinal, e.g. to gate actions
+// on transactions that are unlikely to be reorged out of the chain. Coinbase
+// transactions are only considered final once they have matured as well. A
+// depth of zero is treated as one. By default, DefaultFinalityDepth is used.
+//
+// NOTE: This should be called before the wallet is queried for the finality
+// of any transactions.
+func (w *Wallet) SetFinalityDepth(depth uint32) {
+	if depth == 0 {
+		depth = 1
+	}
+	w.finalityDepth = depth
+}
+
+// TransactionFinality returns how final the wallet transaction with the given
+// hash is, given the wallet's finality depth and the coinbase maturity of the
+// chain. ErrTxNotFound is returned if the transaction isn't known to the
+// wallet.
+func (w *Wallet) TransactionFinality(txHash chainhash.Hash) (TxFinality,
+	error) {
+
+	curHeight := w.confirmationHeight()
+
+	var finality TxFinality
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		details, err := w.TxStore.TxDetails(txmgrNs, &txHash)
+		if err != nil {
+			return err
+		}
+		if details == nil {
+			return ErrTxNotFound
+		}
+
+		finality = w.txFinality(details.Block.Height,
+			blockchain.IsCoinBaseTx(&details.MsgTx), curHeight)
+		return nil
+	})
+	return finality, err
+}
+
+// IsFinal returns whether the wallet transaction with the given hash has
+// reached the wallet's finality depth, and, for coinbase transactions, has
+// matured. ErrTxNotFound is returned if the transaction isn't known to the
+// wallet.
+func (w *Wallet) IsFinal(txHash chainhash.Hash) (bool, error) {
+	finality, err := w.TransactionFinality(txHash)
+	if err != nil {
+		return false, err
+	}
+	return finality == TxFinal, nil
+}
+
+// txFinality returns the finality of a transaction mined at the given height,
+// or -1 if unmined, given the current chain height.
+func (w *Wallet) txFinality(txHeight int32, coinbase bool,
+	curHeight int32) TxFinality {
+
+	if txHeight == -1 {
+		return TxUnconfirmed
+	}
+
+	depth := int32(w.finalityDepth)
+	if maturity := int32(w.chainParams.CoinbaseMaturity); coinbase &&
+		maturity > depth {
+
+		depth = maturity
+	}
+	if confirmed(depth, txHeight, curHeight) {
+		return TxFinal
+	}
+	return TxPendingConfirmed
+}
+
+// WaitForConfirmations blocks until the wallet transaction with the given hash
+// has reached the given number of confirmations, the context is cancelled, or
+// the transaction is removed from the wallet, in which case ErrTxConflicted is
+// returned. The confirmations are tracked through the wallet's transaction
+// notifications, so blocks disconnected by reorgs reduce them again until the
+// transaction is reconfirmed. ErrTxNotFound is returned if the transaction
+// isn't known to the wallet.
+func (w *Wallet) WaitForConfirmations(ctx context.Context,
+	txHash chainhash.Hash, confs uint32) error {
+
+	// Subscribe to notifications before reading the current state of the
+	// transaction, so that no block is missed in between.
+	txNtfns := w.NtfnServer.TransactionNotifications()
+	defer txNtfns.Done()
+	replaceNtfns := w.NtfnServer.TxReplacedNotifications()
+	defer replaceNtfns.Done()
+
+	var txHeight, tip int32
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		details, err := w.TxStore.TxDetails(txmgrNs, &txHash)
+		if err != nil {
+			return err
+		}
+		if details == nil {
+			return ErrTxNotFound
+		}
+
+		txHeight = details.Block.Height
+		tip = w.Manager.SyncedTo().Height
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		if confirms(txHeight, tip) >= int32(confs) {
+			return nil
+		}
+
+		select {
+		case n := <-txNtfns.C:
+			// As notifications are sent before the changes they
+			// describe are committed, the state of the transaction
+			// is tracked from them rather than queried. If blocks
+			// were disconnected down to the height of the
+			// transaction's block, its block was disconnected.
+			attached := n.AttachedBlocks
+			if len(n.DetachedBlocks) > 0 && len(attached) > 0 &&
+				attached[0].Height <= txHeight {
+
+				txHeight = -1
+			}
+			for _, block := range attached {
+				tip = block.Height
+				for _, tx := range block.Transactions {
+					if *tx.Hash == txHash {
+						txHeight = block.Height
+					}
+				}
+			}
+
+			// Each notification includes all of the wallet's
+			// unconfirmed transactions, so an unconfirmed
+			// transaction missing from them has been removed.
+			unmined := n.UnminedTransactionHashes
+			if txHeight == -1 && !containsHash(unmined, txHash) {
+				return fmt.Errorf("%w: %v", ErrTxConflicted,
+					txHash)
+			}
+
+		case n := <-replaceNtfns.C:
+			if n.OldTxHash == txHash {
+				return fmt.Errorf("%w: %v replaced by %v",
+					ErrTxConflicted, txHash, n.NewTxHash)
+			}
+
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-w.quitChan():
+			return ErrWalletShuttingDown
+		}
+	}
+}
+
+// containsHash returns whether the hash is within the given hashes.
+func containsHash(hashes []*chainhash.Hash, hash chainhash.Hash) bool {
+	for _, h := range hashes {
+		if *h == hash {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,351 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// headerChainClient is a mock chain client returning an empty header for any
+// block, as required to disconnect blocks.
+type headerChainClient struct {
+	mockChainClient
+}
+
+func (c *headerChainClient) GetBlockHeader(*chainhash.Hash) (*wire.BlockHeader,
+	error) {
+
+	return &wire.BlockHeader{Timestamp: time.Now()}, nil
+}
+
+// waitForNtfnClients waits until the expected number of clients are registered
+// for transaction and replacement notifications.
+func waitForNtfnClients(t *testing.T, w *Wallet, numClients int) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		s := w.NtfnServer
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.transactions) == numClients &&
+			len(s.replaceClients) == numClients
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestWaitForConfirmations ensures that WaitForConfirmations returns once the
+// transaction reaches the target number of confirmations, accounting for
+// blocks disconnected by reorgs, and fails for conflicted transactions.
+func TestWaitForConfirmations(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+	w.chainClient = &headerChainClient{}
+	w.SetChainSynced(true)
+
+	const startHeight = 100
+	setSyncedHeight(t, w, startHeight)
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	tx.AddTxOut(wire.NewTxOut(100000, pkScript))
+	rec := addUnminedTx(t, w, tx, 0)
+
+	// blockMeta returns the block at the given height of the given fork.
+	blockMeta := func(height int32, fork byte) wtxmgr.BlockMeta {
+		return wtxmgr.BlockMeta{
+			Block: wtxmgr.Block{
+				Hash:   chainhash.Hash{byte(height), fork},
+				Height: height,
+			},
+			Time: time.Now(),
+		}
+	}
+
+	// connect connects the block at the given height of the given fork,
+	// confirming the transaction within it if requested.
+	connect := func(height int32, fork byte, withTx bool) {
+		block := blockMeta(height, fork)
+		err := walletdb.Update(w.db, func(
+			dbtx walletdb.ReadWriteTx) error {
+
+			if withTx {
+				err := w.addRelevantTx(dbtx, rec, &block)
+				if err != nil {
+					return err
+				}
+			}
+			return w.connectBlock(dbtx, block)
+		})
+		require.NoError(t, err)
+	}
+
+	// disconnect disconnects the block at the given height of the given
+	// fork.
+	disconnect := func(height int32, fork byte) {
+		block := blockMeta(height, fork)
+		err := walletdb.Update(w.db, func(
+			dbtx walletdb.ReadWriteTx) error {
+
+			return w.disconnectBlock(dbtx, block)
+		})
+		require.NoError(t, err)
+	}
+
+	const confs = 3
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- w.WaitForConfirmations(
+			context.Background(), tx.TxHash(), confs,
+		)
+	}()
+	waitForNtfnClients(t, w, 1)
+
+	// assertWaiting asserts that the call hasn't returned yet.
+	assertWaiting := func() {
+		t.Helper()
+
+		select {
+		case err := <-errChan:
+			t.Fatalf("returned before reaching the target: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// Confirm the transaction at the next height.
+	connect(startHeight+1, 0, true)
+	assertWaiting()
+
+	// Reorg out the block confirming it, which should reset its
+	// confirmations until it's confirmed again. Reorgs are only notified
+	// once the new chain is longer than the old one.
+	disconnect(startHeight+1, 0)
+	connect(startHeight+1, 1, false)
+	connect(startHeight+2, 1, false)
+	assertWaiting()
+
+	connect(startHeight+3, 1, true)
+	assertWaiting()
+	connect(startHeight+4, 1, false)
+	assertWaiting()
+
+	// The third confirmation should complete the wait.
+	connect(startHeight+5, 1, false)
+	select {
+	case err := <-errChan:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not return after reaching the target")
+	}
+
+	// Reaching the target before waiting returns immediately.
+	err = w.WaitForConfirmations(context.Background(), tx.TxHash(), confs)
+	require.NoError(t, err)
+
+	// Unknown transactions can't be waited for.
+	err = w.WaitForConfirmations(
+		context.Background(), chainhash.Hash{0xff}, confs,
+	)
+	require.True(t, errors.Is(err, ErrTxNotFound))
+
+	// The wait is cancelled along with its context.
+	ctx, cancel := context.WithTimeout(
+		context.Background(), 50*time.Millisecond,
+	)
+	defer cancel()
+	err = w.WaitForConfirmations(ctx, tx.TxHash(), confs+10)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// Waiting for a transaction that's replaced should fail.
+	replacedTx := wire.NewMsgTx(wire.TxVersion)
+	replacedTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x02}}, nil, nil,
+	))
+	replacedTx.AddTxOut(wire.NewTxOut(100000, pkScript))
+	addUnminedTx(t, w, replacedTx, 0)
+
+	waitForNtfnClients(t, w, 0)
+	go func() {
+		errChan <- w.WaitForConfirmations(
+			context.Background(), replacedTx.TxHash(), confs,
+		)
+	}()
+	waitForNtfnClients(t, w, 1)
+	w.NtfnServer.notifyTxReplaced(&TxReplaced{
+		OldTxHash: replacedTx.TxHash(),
+		NewTxHash: chainhash.Hash{0x03},
+	})
+	select {
+	case err := <-errChan:
+		require.True(t, errors.Is(err, ErrTxConflicted))
+	case <-time.After(5 * time.Second):
+		t.Fatal("did not return after the transaction was replaced")
+	}
+}
+
+// TestTransactionFinality ensures that transactions are only final once they
+// reach the finality depth, with coinbase transactions also having to mature.
+func TestTransactionFinality(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	const (
+		txHeight      = 100
+		finalityDepth = 3
+	)
+	w.SetFinalityDepth(finalityDepth)
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	tx.AddTxOut(wire.NewTxOut(100000, testScriptP2WKH))
+	addMinedTx(t, w, tx, txHeight)
+
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(
+		wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex),
+		[]byte{0x01, 0x02}, nil,
+	))
+	coinbase.AddTxOut(wire.NewTxOut(100000, testScriptP2WKH))
+	addMinedTx(t, w, coinbase, txHeight)
+
+	unmined := wire.NewMsgTx(wire.TxVersion)
+	unmined.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x02}}, nil, nil,
+	))
+	unmined.AddTxOut(wire.NewTxOut(100000, testScriptP2WKH))
+	addUnminedTx(t, w, unmined)
+
+	// assertFinality asserts the finality of the transaction once the
+	// wallet is synced to the given height.
+	assertFinality := func(tx *wire.MsgTx, syncedHeight int32,
+		expected TxFinality) {
+
+		t.Helper()
+
+		setSyncedHeight(t, w, syncedHeight)
+		finality, err := w.TransactionFinality(tx.TxHash())
+		require.NoError(t, err)
+		require.Equal(t, expected, finality)
+
+		final, err := w.IsFinal(tx.TxHash())
+		require.NoError(t, err)
+		require.Equal(t, expected == TxFinal, final)
+	}
+
+	// A regular transaction is final once it reaches the finality depth.
+	assertFinality(unmined, txHeight+finalityDepth, TxUnconfirmed)
+	assertFinality(tx, txHeight+finalityDepth-2, TxPendingConfirmed)
+	assertFinality(tx, txHeight+finalityDepth-1, TxFinal)
+
+	// A coinbase transaction is only final once it has matured, past the
+	// finality depth.
+	maturity := int32(w.chainParams.CoinbaseMaturity)
+	assertFinality(coinbase, txHeight+finalityDepth-1, TxPendingConfirmed)
+	assertFinality(coinbase, txHeight+maturity-2, TxPendingConfirmed)
+	assertFinality(coinbase, txHeight+maturity-1, TxFinal)
+
+	_, err := w.IsFinal(chainhash.Hash{0x03})
+	require.True(t, errors.Is(err, ErrTxNotFound), err)
+}
+
+// bestHeightChainClient is a mock chain client reporting a fixed best height.
+type bestHeightChainClient struct {
+	mockChainClient
+	bestHeight int32
+}
+
+func (c *bestHeightChainClient) GetBestBlock() (*chainhash.Hash, int32,
+	error) {
+
+	return &chainhash.Hash{}, c.bestHeight, nil
+}
+
+// TestConfirmationReference ensures that the confirmation counts of a wallet
+// lagging behind the chain are computed against the backend's best height when
+// requested, rather than against the last block processed by the wallet.
+func TestConfirmationReference(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	const (
+		txHeight     = 100
+		syncedHeight = 101
+		bestHeight   = 110
+	)
+	w.chainClient = &bestHeightChainClient{bestHeight: bestHeight}
+	w.SetFinalityDepth(5)
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	tx.AddTxOut(wire.NewTxOut(100000, pkScript))
+	addMinedTx(t, w, tx, txHeight, 0)
+	setSyncedHeight(t, w, syncedHeight)
+
+	// assertConfirmations asserts the confirmations reported for the
+	// transaction, along with its finality. Those of its output, listed
+	// for spending, are always computed against the last processed block.
+	assertConfirmations := func(confs int64, finality TxFinality) {
+		t.Helper()
+
+		txs, err := w.ListTransactions(0, 10)
+		require.NoError(t, err)
+		require.Len(t, txs, 1)
+		require.Equal(t, confs, txs[0].Confirmations)
+
+		unspent, err := w.ListUnspent(0, 9999999, "")
+		require.NoError(t, err)
+		require.Len(t, unspent, 1)
+		require.EqualValues(
+			t, syncedHeight-txHeight+1, unspent[0].Confirmations,
+		)
+
+		txFinality, err := w.TransactionFinality(tx.TxHash())
+		require.NoError(t, err)
+		require.Equal(t, finality, txFinality)
+	}
+
+	// By default, the confirmations are undercounted while the wallet
+	// catches up with the chain.
+	assertConfirmations(syncedHeight-txHeight+1, TxPendingConfirmed)
+
+	w.SetConfirmationReference(ConfirmationsFromBestChain)
+	assertConfirmations(bestHeight-txHeight+1, TxFinal)
+
+	// The last processed block is still used if the backend lags behind
+	// the wallet.
+	w.chainClient = &bestHeightChainClient{bestHeight: txHeight}
+	assertConfirmations(syncedHeight-txHeight+1, TxPendingConfirmed)
+}
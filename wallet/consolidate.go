@@ -0,0 +1,126 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// DefaultConsolidationFeeCeiling is the default highest fee rate, in satoshis
+// per kB, at which the wallet consolidates its outputs.
+const DefaultConsolidationFeeCeiling btcutil.Amount = 5000
+
+var (
+	// ErrConsolidationFeeTooHigh is returned when attempting to
+	// consolidate the wallet's outputs at a fee rate above the
+	// consolidation fee ceiling.
+	ErrConsolidationFeeTooHigh = errors.New("fee rate exceeds " +
+		"consolidation fee ceiling")
+
+	// ErrNothingToConsolidate is returned when the account doesn't have
+	// enough eligible outputs to consolidate.
+	ErrNothingToConsolidate = errors.New("not enough outputs to " +
+		"consolidate")
+)
+
+// SetConsolidationFeeCeiling sets the highest fee rate, in satoshis per kB, at
+// which ConsolidateUTXOs consolidates the wallet's outputs. By default,
+// DefaultConsolidationFeeCeiling is used.
+//
+// NOTE: This should be called before the wallet is used to create any
+// transactions.
+func (w *Wallet) SetConsolidationFeeCeiling(feeRate btcutil.Amount) {
+	w.consolidationFeeCeiling = feeRate
+}
+
+// ConsolidateUTXOs creates a signed transaction sweeping up to maxInputs of the
+// smallest confirmed outputs of the account into a single output paying to a
+// new change address of the account, at the given fee rate in satoshis per kB.
+// Among outputs of the same value, the oldest are consolidated first. As
+// consolidation is only worthwhile when fees are low,
+// ErrConsolidationFeeTooHigh is returned if the fee rate exceeds the
+// consolidation fee ceiling. Outputs that would cost more to spend than their
+// value at the fee rate are skipped, and ErrNothingToConsolidate is returned
+// if fewer than two outputs remain.
+//
+// NOTE: The transaction is not published, and its inputs are not locked.
+func (w *Wallet) ConsolidateUTXOs(account uint32, maxInputs int,
+	feeRate btcutil.Amount) (*wire.MsgTx, error) {
+
+	if maxInputs < 2 {
+		return nil, fmt.Errorf("at least two inputs are required to "+
+			"consolidate, got %d", maxInputs)
+	}
+	if feeRate > w.consolidationFeeCeiling {
+		return nil, fmt.Errorf("%w: fee rate of %v/kB exceeds %v/kB",
+			ErrConsolidationFeeTooHigh, feeRate,
+			w.consolidationFeeCeiling)
+	}
+
+	chainClient, done, err := w.useChainClient()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	bs, err := chainClient.BlockStamp()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		credits  []wtxmgr.Credit
+		pkScript []byte
+	)
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		eligible, err := w.findEligibleOutputs(
+			dbtx, nil, account, 1, bs,
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, credit := range eligible {
+			credit := credit
+			if inputYieldsPositively(&credit, feeRate) {
+				credits = append(credits, credit)
+			}
+		}
+		if len(credits) < 2 {
+			return ErrNothingToConsolidate
+		}
+
+		sort.SliceStable(credits, func(i, j int) bool {
+			if credits[i].Amount != credits[j].Amount {
+				return credits[i].Amount < credits[j].Amount
+			}
+			return credits[i].Height < credits[j].Height
+		})
+		if len(credits) > maxInputs {
+			credits = credits[:maxInputs]
+		}
+
+		_, changeSource, err := w.addrMgrWithChangeSource(
+			dbtx, nil, account,
+		)
+		if err != nil {
+			return err
+		}
+		pkScript, err = changeSource.NewScript()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tx, _, err := w.createSweepTx(credits, pkScript, feeRate)
+	return tx, err
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConsolidateUTXOs ensures that the smallest outputs of an account are
+// consolidated into a single output paying to the wallet, and that
+// consolidation is refused above the consolidation fee ceiling.
+func TestConsolidateUTXOs(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	// Fund the wallet with a large output, which shouldn't be
+	// consolidated, along with many small ones.
+	const (
+		numSmall   = 10
+		smallValue = 10000
+		feeRate    = btcutil.Amount(1000)
+	)
+	large := fundWallet(t, w, 1000000)
+	small := make(map[wire.OutPoint]struct{}, numSmall)
+	for i := 0; i < numSmall; i++ {
+		tx := fundWallet(t, w, smallValue)
+		small[wire.OutPoint{Hash: tx.TxHash()}] = struct{}{}
+	}
+
+	// Consolidating requires at least two inputs, at a fee rate below
+	// the ceiling.
+	_, err := w.ConsolidateUTXOs(0, 1, feeRate)
+	require.Error(t, err)
+
+	w.SetConsolidationFeeCeiling(feeRate - 1)
+	_, err = w.ConsolidateUTXOs(0, numSmall, feeRate)
+	require.True(t, errors.Is(err, ErrConsolidationFeeTooHigh), err)
+	w.SetConsolidationFeeCeiling(DefaultConsolidationFeeCeiling)
+
+	tx, err := w.ConsolidateUTXOs(0, numSmall, feeRate)
+	require.NoError(t, err)
+	require.Len(t, tx.TxIn, numSmall)
+	require.Len(t, tx.TxOut, 1)
+
+	for _, txIn := range tx.TxIn {
+		require.Contains(t, small, txIn.PreviousOutPoint)
+		require.NotEqual(t, large.TxHash(), txIn.PreviousOutPoint.Hash)
+	}
+
+	// The consolidated output pays to the wallet, with the fee subtracted
+	// from the consolidated amount.
+	_, err = w.fetchOutputAddr(tx.TxOut[0].PkScript)
+	require.NoError(t, err)
+	require.Less(t, tx.TxOut[0].Value, int64(numSmall*smallValue))
+
+	fee := btcutil.Amount(numSmall*smallValue - tx.TxOut[0].Value)
+	require.GreaterOrEqual(t, int64(fee)*1000,
+		int64(feeRate)*int64(txVirtualSize(tx)))
+
+	// Without enough eligible outputs, there's nothing to consolidate.
+	_, err = w.ConsolidateUTXOs(1, numSmall, feeRate)
+	require.True(t, errors.Is(err, ErrNothingToConsolidate), err)
+}
@@ -108,13 +108,21 @@ func (s secretSource) GetScript(addr btcutil.Address) ([]byte, error) {
 // input scripts added and SHOULD NOT be broadcasted.
 func (w *Wallet) txToOutputs(outputs []*wire.TxOut, keyScope *waddrmgr.KeyScope,
 	account uint32, minconf int32, feeSatPerKb btcutil.Amount,
-	coinSelectionStrategy CoinSelectionStrategy, dryRun bool) (
-	*txauthor.AuthoredTx, error) {
+	coinSelectionStrategy CoinSelectionStrategy, dryRun bool,
+	opts *txCreateOptions) (*txauthor.AuthoredTx, error) {
 
-	chainClient, err := w.requireChainClient()
+	if err := w.ReorgHalted(); err != nil {
+		return nil, err
+	}
+	if err := w.checkTxFeeRate(feeSatPerKb, opts); err != nil {
+		return nil, err
+	}
+
+	chainClient, done, err := w.useChainClient()
 	if err != nil {
 		return nil, err
 	}
+	defer done()
 
 	// Get current block's height and hash.
 	bs, err := chainClient.BlockStamp()
@@ -122,14 +130,32 @@ func (w *Wallet) txToOutputs(outputs []*wire.TxOut, keyScope *waddrmgr.KeyScope,
 		return nil, err
 	}
 
+	if opts.ephemeralAnchor {
+		outputs, err = withEphemeralAnchor(outputs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := w.txSizeLimits.checkOutputs(outputs); err != nil {
+		return nil, err
+	}
+
 	var tx *txauthor.AuthoredTx
 	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
-		addrmgrNs, changeSource, err := w.addrMgrWithChangeSource(
-			dbtx, keyScope, account,
+		addrmgrNs, changeSource, err := w.addrMgrWithChangeSourceOfType(
+			dbtx, keyScope, account, opts.changeAddrType,
 		)
 		if err != nil {
 			return err
 		}
+		if opts.changeAddress != nil {
+			changeSource, err = w.explicitChangeSource(
+				opts.changeAddress,
+			)
+			if err != nil {
+				return err
+			}
+		}
 
 		eligible, err := w.findEligibleOutputs(
 			dbtx, keyScope, account, minconf, bs,
@@ -138,13 +164,23 @@ func (w *Wallet) txToOutputs(outputs []*wire.TxOut, keyScope *waddrmgr.KeyScope,
 			return err
 		}
 
-		var inputSource txauthor.InputSource
+		// Unconfirmed outputs whose spend would exceed the maximum
+		// ancestor chain length are only considered once the selection
+		// fails without them, to report why.
+		eligible, tooLong, err := w.filterAncestorChains(
+			dbtx.ReadBucket(wtxmgrNamespaceKey), eligible,
+		)
+		if err != nil {
+			return err
+		}
+
+		var selectable []wtxmgr.Credit
 
 		switch coinSelectionStrategy {
 		// Pick largest outputs first.
 		case CoinSelectionLargest:
 			sort.Sort(sort.Reverse(byAmount(eligible)))
-			inputSource = makeInputSource(eligible)
+			selectable = eligible
 
 		// Select coins at random. This prevents the creation of ever
 		// smaller utxos over time that may never become economical to
@@ -170,21 +206,91 @@ func (w *Wallet) txToOutputs(outputs []*wire.TxOut, keyScope *waddrmgr.KeyScope,
 					positivelyYielding[j], positivelyYielding[i]
 			})
 
-			inputSource = makeInputSource(positivelyYielding)
+			selectable = positivelyYielding
+		}
+
+		// Replacements must spend one of the inputs they conflict
+		// with, which are selected first.
+		if opts.conflictingInputs != nil {
+			selectable, err = selectConflictingFirst(
+				selectable, opts.conflictingInputs,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		tx, err = authorWithTargetChange(
+			outputs, feeSatPerKb, selectable, changeSource,
+			opts.coinbasePreference, opts.targetChange,
+		)
+		if _, ok := err.(txauthor.InputSourceError); ok &&
+			len(tooLong) > 0 {
+
+			_, retryErr := authorWithTargetChange(
+				outputs, feeSatPerKb,
+				append(selectable, tooLong...), changeSource,
+				opts.coinbasePreference, opts.targetChange,
+			)
+			if retryErr == nil {
+				return fmt.Errorf("%w: %d unconfirmed outputs "+
+					"can't be spent yet",
+					ErrAncestorChainTooLong, len(tooLong))
+			}
+		}
+		if err != nil {
+			return err
 		}
 
-		tx, err = txauthor.NewUnsignedTransaction(
-			outputs, feeSatPerKb, inputSource, changeSource,
+		// While each selected output can be spent on its own, their
+		// ancestors counted together may still exceed the limit.
+		err = w.checkAncestorChain(
+			dbtx.ReadBucket(wtxmgrNamespaceKey), tx.Tx,
 		)
 		if err != nil {
 			return err
 		}
 
-		// Randomize change position, if change exists, before signing.
-		// This doesn't affect the serialize size, so the change amount
-		// will still be valid.
-		if tx.ChangeIndex >= 0 {
-			tx.RandomizeChangePosition()
+		// The version, sequences and lock-time don't affect the size
+		// of the transaction, so they can be set once the inputs have
+		// been selected.
+		tx.Tx.Version = opts.txVersion
+		setRelativeLockSequences(tx.Tx, selectable)
+		if w.antiFeeSniping {
+			setAntiFeeSnipingLockTime(tx.Tx, bs.Height)
+		}
+		setTimeLocks(tx.Tx, selectable)
+		if opts.ephemeralAnchor {
+			if _, err := ephemeralAnchorIndex(tx.Tx); err != nil {
+				return err
+			}
+		}
+		err = w.checkTxVersion(
+			dbtx.ReadBucket(wtxmgrNamespaceKey), tx.Tx,
+			tx.PrevScripts,
+		)
+		if err != nil {
+			return err
+		}
+		err = w.txSizeLimits.checkTx(tx.Tx, tx.PrevScripts)
+		if err != nil {
+			return err
+		}
+
+		// Order the inputs and outputs according to the wallet's
+		// policy before signing. With the default policy, the inputs
+		// are kept in the order in which they're selected and the
+		// change position is randomized, if change exists. This
+		// doesn't affect the serialize size, so the change amount will
+		// still be valid.
+		switch w.txOrderingPolicy {
+		case BIP69Ordering:
+			tx.SortBIP69()
+
+		default:
+			if tx.ChangeIndex >= 0 {
+				tx.RandomizeChangePosition()
+			}
 		}
 
 		// If a dry run was requested, we return now before adding the
@@ -197,6 +303,19 @@ func (w *Wallet) txToOutputs(outputs []*wire.TxOut, keyScope *waddrmgr.KeyScope,
 			return walletdb.ErrDryRunRollBack
 		}
 
+		// Lease the selected inputs if requested, such that they're
+		// only committed along with the change address reserved for
+		// the transaction.
+		if opts.fundingLease != nil {
+			err = w.leaseInputs(
+				dbtx.ReadWriteBucket(wtxmgrNamespaceKey), tx.Tx,
+				opts.fundingLease,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Before committing the transaction, we'll sign our inputs. If
 		// the inputs are part of a watch-only account, there's no
 		// private key information stored, so we'll skip signing such.
@@ -218,9 +337,13 @@ func (w *Wallet) txToOutputs(outputs []*wire.TxOut, keyScope *waddrmgr.KeyScope,
 			return err
 		}
 		if !watchOnly {
-			err = tx.AddAllInputScripts(
-				secretSource{w.Manager, addrmgrNs},
-			)
+			secrets := secretSource{w.Manager, addrmgrNs}
+			txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+			err = tx.AddAllInputScripts(timeLockedSecretSource{
+				secretSource: secrets,
+				txStore:      w.TxStore,
+				txmgrNs:      txmgrNs,
+			})
 			if err != nil {
 				return err
 			}
@@ -233,7 +356,13 @@ func (w *Wallet) txToOutputs(outputs []*wire.TxOut, keyScope *waddrmgr.KeyScope,
 			}
 		}
 
-		if tx.ChangeIndex >= 0 && account == waddrmgr.ImportedAddrAccount {
+		// Change sent to an explicit change address doesn't move into
+		// the default account, and its address is already watched if
+		// it belongs to the wallet.
+		explicitChange := opts.changeAddress != nil
+		if tx.ChangeIndex >= 0 && !explicitChange &&
+			account == waddrmgr.ImportedAddrAccount {
+
 			changeAmount := btcutil.Amount(
 				tx.Tx.TxOut[tx.ChangeIndex].Value,
 			)
@@ -245,7 +374,7 @@ func (w *Wallet) txToOutputs(outputs []*wire.TxOut, keyScope *waddrmgr.KeyScope,
 		// Finally, we'll request the backend to notify us of the
 		// transaction that pays to the change address, if there is one,
 		// when it confirms.
-		if tx.ChangeIndex >= 0 {
+		if tx.ChangeIndex >= 0 && !explicitChange {
 			changePkScript := tx.Tx.TxOut[tx.ChangeIndex].PkScript
 			_, addrs, _, err := txscript.ExtractPkScriptAddrs(
 				changePkScript, w.chainParams,
@@ -290,7 +419,8 @@ func (w *Wallet) findEligibleOutputs(dbtx walletdb.ReadTx,
 
 		// Only include this output if it meets the required number of
 		// confirmations.  Coinbase transactions must have have reached
-		// maturity before their outputs may be spent.
+		// maturity before their outputs may be spent, as must outputs
+		// with a relative lock-time.
 		if !confirmed(minconf, output.Height, bs.Height) {
 			continue
 		}
@@ -300,14 +430,38 @@ func (w *Wallet) findEligibleOutputs(dbtx walletdb.ReadTx,
 				continue
 			}
 		}
+		if !relativeLockMature(output, bs.Height) {
+			continue
+		}
 
-		// Locked unspent outputs are skipped.
+		// Outputs paying to time-locked scripts can only be spent once
+		// their lock-time expires.
+		if !timeLockMature(output, bs.Height) {
+			continue
+		}
+
+		// Outputs to non-standard scripts may require keys the wallet
+		// doesn't hold, so they're never selected.
+		if output.NonStandard {
+			continue
+		}
+
+		// Locked, frozen and denylisted unspent outputs are skipped.
 		if w.LockedOutpoint(output.OutPoint) {
 			continue
 		}
+		if w.TxStore.IsFrozenOutput(txmgrNs, output.OutPoint) {
+			continue
+		}
+		if w.TxStore.IsSelectionDenylisted(
+			txmgrNs, output.OutPoint, output.PkScript,
+		) {
+			continue
+		}
 
 		// Only include the output if it is associated with the passed
-		// account.
+		// account. Outputs paying to time-locked scripts are associated
+		// with the account of the key the script commits to.
 		//
 		// TODO: Handle multisig outputs by determining if enough of the
 		// addresses are controlled.
@@ -316,6 +470,15 @@ func (w *Wallet) findEligibleOutputs(dbtx walletdb.ReadTx,
 		if err != nil || len(addrs) != 1 {
 			continue
 		}
+		if output.LockTime != 0 {
+			witnessScript, _, _ := w.TxStore.TimeLockedScript(
+				txmgrNs, output.PkScript,
+			)
+			addrs[0], err = w.timeLockedKeyAddress(witnessScript)
+			if err != nil {
+				continue
+			}
+		}
 		scopedMgr, addrAcct, err := w.Manager.AddrAccount(addrmgrNs, addrs[0])
 		if err != nil {
 			continue
@@ -326,6 +489,18 @@ func (w *Wallet) findEligibleOutputs(dbtx walletdb.ReadTx,
 		if addrAcct != account {
 			continue
 		}
+
+		// Outputs of disabled accounts can only be spent explicitly.
+		disabled, err := scopedMgr.IsAccountDisabled(
+			addrmgrNs, addrAcct,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if disabled {
+			continue
+		}
+
 		eligible = append(eligible, *output)
 	}
 	return eligible, nil
@@ -352,25 +527,44 @@ func (w *Wallet) addrMgrWithChangeSource(dbtx walletdb.ReadWriteTx,
 	changeKeyScope *waddrmgr.KeyScope, account uint32) (
 	walletdb.ReadWriteBucket, *txauthor.ChangeSource, error) {
 
-	// Determine the address type for change addresses of the given account.
+	return w.addrMgrWithChangeSourceOfType(
+		dbtx, changeKeyScope, account, nil,
+	)
+}
+
+// addrMgrWithChangeSourceOfType returns the address manager bucket and a
+// change source like addrMgrWithChangeSource, with the change addresses being
+// of the given type if any, rather than of the account's change address type.
+func (w *Wallet) addrMgrWithChangeSourceOfType(dbtx walletdb.ReadWriteTx,
+	changeKeyScope *waddrmgr.KeyScope, account uint32,
+	changeAddrType *waddrmgr.AddressType) (walletdb.ReadWriteBucket,
+	*txauthor.ChangeSource, error) {
+
+	// As a hack to allow spending from the imported account, change
+	// addresses are created from account 0.
 	if changeKeyScope == nil {
 		changeKeyScope = &waddrmgr.KeyScopeBIP0084
 	}
-	addrType := waddrmgr.ScopeAddrMap[*changeKeyScope].InternalAddrType
+	changeAccount := account
+	if account == waddrmgr.ImportedAddrAccount {
+		changeAccount = 0
+	}
 
-	// It's possible for the account to have an address schema override, so
-	// prefer that if it exists.
+	// Determine the address type for change addresses of the given account,
+	// which accounts for any address schema override or change address type
+	// set for it, unless a type is given.
 	addrmgrNs := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
 	scopeMgr, err := w.Manager.FetchScopedKeyManager(*changeKeyScope)
 	if err != nil {
 		return nil, nil, err
 	}
-	accountInfo, err := scopeMgr.AccountProperties(addrmgrNs, account)
+	accountInfo, err := scopeMgr.AccountProperties(addrmgrNs, changeAccount)
 	if err != nil {
 		return nil, nil, err
 	}
-	if accountInfo.AddrSchema != nil {
-		addrType = accountInfo.AddrSchema.InternalAddrType
+	addrType := accountInfo.ChangeAddrType
+	if changeAddrType != nil {
+		addrType = *changeAddrType
 	}
 
 	// Compute the expected size of the script for the change address type.
@@ -385,20 +579,19 @@ func (w *Wallet) addrMgrWithChangeSource(dbtx walletdb.ReadWriteTx,
 	}
 
 	newChangeScript := func() ([]byte, error) {
-		// Derive the change output script. As a hack to allow spending
-		// from the imported account, change addresses are created from
-		// account 0.
+		// Derive the change output script.
 		var (
 			changeAddr btcutil.Address
 			err        error
 		)
-		if account == waddrmgr.ImportedAddrAccount {
-			changeAddr, err = w.newChangeAddress(
-				addrmgrNs, 0, *changeKeyScope,
+		if changeAddrType != nil {
+			changeAddr, err = w.newChangeAddressOfType(
+				addrmgrNs, changeAccount, *changeKeyScope,
+				*changeAddrType,
 			)
 		} else {
 			changeAddr, err = w.newChangeAddress(
-				addrmgrNs, account, *changeKeyScope,
+				addrmgrNs, changeAccount, *changeKeyScope,
 			)
 		}
 		if err != nil {
@@ -410,9 +603,46 @@ func (w *Wallet) addrMgrWithChangeSource(dbtx walletdb.ReadWriteTx,
 	return addrmgrNs, &txauthor.ChangeSource{
 		ScriptSize: scriptSize,
 		NewScript:  newChangeScript,
+		DustPolicy: w.dustChangePolicy,
 	}, nil
 }
 
+// SetDustChangePolicy sets the policy used to handle the change of
+// transactions created by the wallet when a change output for it would be
+// dust. By default, dust change is added to the transaction fee.
+//
+// NOTE: This should be called before the wallet is used to create any
+// transactions.
+func (w *Wallet) SetDustChangePolicy(policy txauthor.DustChangePolicy) {
+	w.dustChangePolicy = policy
+}
+
+// TxOrderingPolicy determines how the inputs and outputs of the transactions
+// created by the wallet are ordered.
+type TxOrderingPolicy uint8
+
+const (
+	// InsertionOrdering keeps the inputs in the order in which they're
+	// selected, and the outputs in the order in which they're provided,
+	// with the change output inserted at a random position.
+	InsertionOrdering TxOrderingPolicy = iota
+
+	// BIP69Ordering sorts the inputs and outputs lexicographically as per
+	// BIP 69, such that their order doesn't fingerprint the wallet.
+	BIP69Ordering
+)
+
+// SetTxOrderingPolicy sets the policy used to order the inputs and outputs of
+// transactions created by the wallet. By default, InsertionOrdering is used.
+// The change index reported along with created transactions accounts for the
+// ordering. PSBTs funded by the wallet are always sorted as per BIP 69.
+//
+// NOTE: This should be called before the wallet is used to create any
+// transactions.
+func (w *Wallet) SetTxOrderingPolicy(policy TxOrderingPolicy) {
+	w.txOrderingPolicy = policy
+}
+
 // validateMsgTx verifies transaction input scripts for tx.  All previous output
 // scripts from outputs redeemed by the transaction, in the same order they are
 // spent, must be passed in the prevScripts slice.
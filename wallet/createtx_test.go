@@ -77,6 +77,7 @@ func TestTxToOutputsDryRun(t *testing.T) {
 	// database us not inflated.
 	dryRunTx, err := w.txToOutputs(
 		txOuts, nil, 0, 1, 1000, CoinSelectionLargest, true,
+		defaultTxCreateOptions(),
 	)
 	if err != nil {
 		t.Fatalf("unable to author tx: %v", err)
@@ -94,6 +95,7 @@ func TestTxToOutputsDryRun(t *testing.T) {
 
 	dryRunTx2, err := w.txToOutputs(
 		txOuts, nil, 0, 1, 1000, CoinSelectionLargest, true,
+		defaultTxCreateOptions(),
 	)
 	if err != nil {
 		t.Fatalf("unable to author tx: %v", err)
@@ -129,6 +131,7 @@ func TestTxToOutputsDryRun(t *testing.T) {
 	// to the database.
 	tx, err := w.txToOutputs(
 		txOuts, nil, 0, 1, 1000, CoinSelectionLargest, false,
+		defaultTxCreateOptions(),
 	)
 	if err != nil {
 		t.Fatalf("unable to author tx: %v", err)
@@ -274,6 +277,7 @@ func TestTxToOutputsRandom(t *testing.T) {
 	createTx := func() *txauthor.AuthoredTx {
 		tx, err := w.txToOutputs(
 			txOuts, nil, 0, 1, feeSatPerKb, CoinSelectionRandom, true,
+			defaultTxCreateOptions(),
 		)
 		require.NoError(t, err)
 		return tx
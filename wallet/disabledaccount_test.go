@@ -0,0 +1,85 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSetAccountDisabled ensures that a disabled account no longer issues new
+// addresses and that its outputs are excluded from coin selection, while still
+// allowing them to be swept explicitly.
+func TestSetAccountDisabled(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	const account = 0
+	keyScope := waddrmgr.KeyScopeBIP0084
+
+	addr, err := w.NewAddress(account, keyScope)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	incomingTx := wire.NewMsgTx(wire.TxVersion)
+	incomingTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	incomingTx.AddTxOut(wire.NewTxOut(1000000, pkScript))
+	addUtxo(t, w, incomingTx)
+
+	require.NoError(t, w.SetAccountDisabled(keyScope, account, true))
+
+	// The disabled state should be reported when listing accounts.
+	accounts, err := w.Accounts(keyScope)
+	require.NoError(t, err)
+	var found bool
+	for _, acct := range accounts.Accounts {
+		if acct.AccountNumber == account {
+			require.True(t, acct.Disabled)
+			found = true
+		}
+	}
+	require.True(t, found)
+
+	// New addresses should no longer be issued for the account.
+	_, err = w.NewAddress(account, keyScope)
+	require.True(t, waddrmgr.IsError(err, waddrmgr.ErrAccountDisabled))
+
+	// Its outputs should be excluded from coin selection.
+	_, err = w.CreateSimpleTx(
+		&keyScope, account, []*wire.TxOut{
+			wire.NewTxOut(100000, testScriptP2WKH),
+		}, 1, 1000, CoinSelectionLargest, true,
+	)
+	_, ok := err.(txauthor.InputSourceError)
+	require.True(t, ok, "expected InputSourceError, got %v", err)
+
+	// Sweeping them explicitly should still be allowed.
+	destAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), w.chainParams,
+	)
+	require.NoError(t, err)
+	sweepTx, err := w.SweepOutputs(
+		[]wire.OutPoint{{Hash: incomingTx.TxHash()}}, destAddr, 1000,
+	)
+	require.NoError(t, err)
+	require.Len(t, sweepTx.TxIn, 1)
+
+	// Once re-enabled, the account should issue addresses again.
+	require.NoError(t, w.SetAccountDisabled(keyScope, account, false))
+	_, err = w.NewAddress(account, keyScope)
+	require.NoError(t, err)
+}
@@ -0,0 +1,622 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// dumpMasterKeyPrefix prefixes the comment of a bitcoind dumpwallet file
+// containing its HD master key.
+const dumpMasterKeyPrefix = "# extended private masterkey:"
+
+// DumpKeyKind describes the use of a key within the bitcoind wallet it was
+// dumped from.
+type DumpKeyKind uint8
+
+const (
+	// DumpKeyReceive is a key handed out to receive funds, which may have
+	// a label.
+	DumpKeyReceive DumpKeyKind = iota
+
+	// DumpKeyChange is a key used for change outputs.
+	DumpKeyChange
+
+	// DumpKeyReserve is a key of the keypool, which was never handed out.
+	DumpKeyReserve
+
+	// DumpKeyHDSeed is an HD seed of the wallet, either active or
+	// inactive, from which its keys are derived rather than one that
+	// receives funds.
+	DumpKeyHDSeed
+)
+
+// String returns a human-readable name of the key kind.
+func (k DumpKeyKind) String() string {
+	switch k {
+	case DumpKeyReceive:
+		return "receive"
+	case DumpKeyChange:
+		return "change"
+	case DumpKeyReserve:
+		return "reserve"
+	case DumpKeyHDSeed:
+		return "hdseed"
+	default:
+		return fmt.Sprintf("unknown key kind %d", uint8(k))
+	}
+}
+
+// DumpKey is a private key found within a bitcoind dumpwallet file.
+type DumpKey struct {
+	// WIF is the private key.
+	WIF *btcutil.WIF
+
+	// Birthday is the time the key was created at, which is the Unix
+	// epoch if it's unknown.
+	Birthday time.Time
+
+	// Kind is the use of the key within the dumped wallet.
+	Kind DumpKeyKind
+
+	// Label is the label of the address of a receive key.
+	Label string
+
+	// Addresses are the addresses of the key known to the dumped wallet,
+	// hinting at the address types it was used with.
+	Addresses []btcutil.Address
+
+	// HDKeyPath is the derivation path of the key, if it's derived from
+	// the HD seed of the wallet.
+	HDKeyPath string
+}
+
+// DumpScript is a script found within a bitcoind dumpwallet file, such as the
+// redeem script of a multisig address, which the dumped wallet watches but
+// doesn't necessarily hold all the keys for.
+type DumpScript struct {
+	// Script is the redeem script.
+	Script []byte
+
+	// Birthday is the time the script was created at, which is the Unix
+	// epoch if it's unknown.
+	Birthday time.Time
+}
+
+// DumpWallet is the parsed contents of a bitcoind dumpwallet file.
+type DumpWallet struct {
+	// MasterKey is the HD master key of the dumped wallet, if any.
+	MasterKey *hdkeychain.ExtendedKey
+
+	// Keys are the private keys of the dumped wallet.
+	Keys []DumpKey
+
+	// Scripts are the scripts watched by the dumped wallet.
+	Scripts []DumpScript
+
+	// Skipped is the number of malformed lines skipped while parsing.
+	Skipped int
+}
+
+// ParseDumpWallet parses a wallet dumped through bitcoind's dumpwallet RPC for
+// the given network, extracting its HD master key along with its private keys
+// and scripts. Malformed lines, or entries for another network, are skipped
+// with a warning rather than failing the whole parse, and counted in the
+// result.
+func ParseDumpWallet(r io.Reader, params *chaincfg.Params) (*DumpWallet,
+	error) {
+
+	dump := &DumpWallet{}
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		var err error
+		switch {
+		case strings.HasPrefix(line, dumpMasterKeyPrefix):
+			dump.MasterKey, err = parseDumpMasterKey(
+				strings.TrimPrefix(line, dumpMasterKeyPrefix),
+				params,
+			)
+
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+
+		default:
+			err = dump.parseEntry(line, params)
+		}
+		if err != nil {
+			log.Warnf("Skipping malformed line %d of wallet dump: "+
+				"%v", lineNum, err)
+			dump.Skipped++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return dump, nil
+}
+
+// parseDumpMasterKey parses the HD master key of a wallet dump for the given
+// network.
+func parseDumpMasterKey(s string, params *chaincfg.Params) (
+	*hdkeychain.ExtendedKey, error) {
+
+	masterKey, err := hdkeychain.NewKeyFromString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	if !masterKey.IsPrivate() {
+		return nil, errors.New("master key is not private")
+	}
+	if !masterKey.IsForNet(params) {
+		return nil, fmt.Errorf("master key is not for %s", params.Name)
+	}
+
+	return masterKey, nil
+}
+
+// parseEntry parses a key or script entry of a wallet dump, which is of the
+// form "<key> <time> <flags> # addr=<addrs> hdkeypath=<path>".
+func (d *DumpWallet) parseEntry(line string, params *chaincfg.Params) error {
+	// The comment of the entry starts at the first field prefixed by '#',
+	// as its labels may contain it.
+	fields := strings.Fields(line)
+	var comment []string
+	for i, field := range fields {
+		if strings.HasPrefix(field, "#") {
+			fields, comment = fields[:i], fields[i:]
+			comment[0] = strings.TrimPrefix(comment[0], "#")
+			break
+		}
+	}
+	if len(fields) < 2 {
+		return errors.New("missing key or creation time")
+	}
+	birthday, err := time.Parse(time.RFC3339, fields[1])
+	if err != nil {
+		return fmt.Errorf("invalid creation time: %v", err)
+	}
+
+	flags := make(map[string]string, len(fields)-2)
+	for _, field := range fields[2:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid field %q", field)
+		}
+		flags[kv[0]] = kv[1]
+	}
+
+	// Scripts are dumped as hex rather than as private keys.
+	if flags["script"] == "1" {
+		script, err := hex.DecodeString(fields[0])
+		if err != nil {
+			return fmt.Errorf("invalid script: %v", err)
+		}
+		d.Scripts = append(d.Scripts, DumpScript{
+			Script:   script,
+			Birthday: birthday,
+		})
+		return nil
+	}
+
+	wif, err := btcutil.DecodeWIF(fields[0])
+	if err != nil {
+		return fmt.Errorf("invalid private key: %v", err)
+	}
+	if !wif.IsForNet(params) {
+		return fmt.Errorf("private key is not for %s", params.Name)
+	}
+
+	key := DumpKey{
+		WIF:      wif,
+		Birthday: birthday,
+	}
+	_, hasLabel := flags["label"]
+	switch {
+	case flags["hdseed"] == "1", flags["inactivehdseed"] == "1":
+		key.Kind = DumpKeyHDSeed
+	case flags["reserve"] == "1":
+		key.Kind = DumpKeyReserve
+	case flags["change"] == "1":
+		key.Kind = DumpKeyChange
+	case hasLabel:
+		key.Kind = DumpKeyReceive
+
+		// Labels are percent-encoded to escape whitespace within
+		// them.
+		key.Label, err = url.PathUnescape(flags["label"])
+		if err != nil {
+			return fmt.Errorf("invalid label: %v", err)
+		}
+	default:
+		return errors.New("unknown key kind")
+	}
+
+	for _, field := range comment {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "addr":
+			for _, s := range strings.Split(kv[1], ",") {
+				addr, err := btcutil.DecodeAddress(s, params)
+				if err != nil {
+					return fmt.Errorf("invalid address "+
+						"%q: %v", s, err)
+				}
+				key.Addresses = append(key.Addresses, addr)
+			}
+
+		case "hdkeypath":
+			key.HDKeyPath = kv[1]
+		}
+	}
+
+	d.Keys = append(d.Keys, key)
+	return nil
+}
+
+// keyScopes returns the key scopes the key should be imported into, which are
+// those of the address types it was used with by the dumped wallet. Without
+// any such hint, a compressed key is imported into every default scope, while
+// an uncompressed key can only have been used with legacy addresses.
+func (k *DumpKey) keyScopes() []waddrmgr.KeyScope {
+	if len(k.Addresses) == 0 {
+		if !k.WIF.CompressPubKey {
+			return []waddrmgr.KeyScope{waddrmgr.KeyScopeBIP0044}
+		}
+		return waddrmgr.DefaultKeyScopes
+	}
+
+	var scopes []waddrmgr.KeyScope
+	seen := make(map[waddrmgr.KeyScope]struct{}, len(k.Addresses))
+	for _, addr := range k.Addresses {
+		var scope waddrmgr.KeyScope
+		switch addr.(type) {
+		case *btcutil.AddressPubKeyHash:
+			scope = waddrmgr.KeyScopeBIP0044
+		case *btcutil.AddressScriptHash:
+			scope = waddrmgr.KeyScopeBIP0049Plus
+		case *btcutil.AddressWitnessPubKeyHash:
+			scope = waddrmgr.KeyScopeBIP0084
+		default:
+			continue
+		}
+
+		// Witness addresses require compressed keys.
+		if scope != waddrmgr.KeyScopeBIP0044 && !k.WIF.CompressPubKey {
+			continue
+		}
+		if _, ok := seen[scope]; ok {
+			continue
+		}
+		seen[scope] = struct{}{}
+		scopes = append(scopes, scope)
+	}
+
+	return scopes
+}
+
+// DumpWalletImport is the result of importing a wallet dump through
+// ImportDumpWallet.
+type DumpWalletImport struct {
+	// Addresses are the addresses of the imported private keys, which
+	// are spendable by the wallet.
+	Addresses []btcutil.Address
+
+	// Labels maps the encoded addresses of the imported receive keys to
+	// their labels within the dumped wallet.
+	Labels map[string]string
+
+	// Reserved is the number of imported keys which were never handed
+	// out by the dumped wallet, as they belonged to its keypool.
+	Reserved int
+
+	// WatchOnly are the addresses of the imported scripts, which are only
+	// spendable if the wallet holds the keys required by them.
+	WatchOnly []btcutil.Address
+
+	// MasterKeyImported indicates whether the key of the dump's HD master
+	// key was imported.
+	MasterKeyImported bool
+
+	// Ignored is the number of entries not imported, which are either HD
+	// seeds or already known to the wallet.
+	Ignored int
+}
+
+// ImportDumpWallet imports the private keys and scripts of a wallet dump parsed
+// through ParseDumpWallet into the imported account, and rescans the chain for
+// them once, from the earliest creation time within the dump. Each private key
+// is imported into the key scopes of the address types it was used with by the
+// dumped wallet. Keypool keys are imported along with the others, as they may
+// have been handed out since the dump, and are reported separately. Scripts
+// are imported as watch-only P2SH addresses.
+//
+// HD seeds aren't imported, as their keys don't receive funds themselves. As
+// the wallet is unable to derive keys following the dumped wallet's HD chains,
+// the key of its HD master key, if any, is imported on its own, while keys
+// derived by the dumped wallet after its dump are only found if they were part
+// of its keypool.
+func (w *Wallet) ImportDumpWallet(dump *DumpWallet) (*DumpWalletImport,
+	error) {
+
+	chainClient, done, err := w.useChainClient()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	keys := append([]DumpKey(nil), dump.Keys...)
+	if dump.MasterKey != nil {
+		privKey, err := dump.MasterKey.ECPrivKey()
+		if err != nil {
+			return nil, err
+		}
+		wif, err := btcutil.NewWIF(privKey, w.chainParams, true)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, DumpKey{
+			WIF:  wif,
+			Kind: DumpKeyReceive,
+		})
+	}
+
+	// The single rescan covering every entry starts from the earliest of
+	// their creation times.
+	earliest := time.Now()
+	for _, key := range dump.Keys {
+		if key.Birthday.Before(earliest) {
+			earliest = key.Birthday
+		}
+	}
+	for _, script := range dump.Scripts {
+		if script.Birthday.Before(earliest) {
+			earliest = script.Birthday
+		}
+	}
+	bs, err := w.dumpBirthdayBlock(chainClient, earliest)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DumpWalletImport{
+		Labels: make(map[string]string),
+	}
+	var (
+		addrs   []btcutil.Address
+		unspent []wtxmgr.Credit
+	)
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		ns := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
+
+		for i, key := range keys {
+			isMasterKey := dump.MasterKey != nil && i == len(keys)-1
+
+			imported, err := w.importDumpKey(ns, &key, bs)
+			if err != nil {
+				return err
+			}
+			if len(imported) == 0 {
+				result.Ignored++
+				continue
+			}
+
+			result.Addresses = append(result.Addresses, imported...)
+			switch {
+			case isMasterKey:
+				result.MasterKeyImported = true
+			case key.Kind == DumpKeyReserve:
+				result.Reserved++
+			case key.Kind == DumpKeyReceive && key.Label != "":
+				for _, addr := range imported {
+					err := w.setAddressLabel(
+						ns, addr, key.Label,
+					)
+					if err != nil {
+						return err
+					}
+					result.Labels[addr.EncodeAddress()] =
+						key.Label
+				}
+			}
+		}
+
+		// Like regular P2SH redeem scripts imported through
+		// ImportP2SHRedeemScript, the scripts are imported into the
+		// BIP0044 scope.
+		scopedMgr, err := w.Manager.FetchScopedKeyManager(
+			waddrmgr.KeyScopeBIP0044,
+		)
+		if err != nil {
+			return err
+		}
+		for _, script := range dump.Scripts {
+			maddr, err := scopedMgr.ImportScript(
+				ns, script.Script, bs,
+			)
+			if waddrmgr.IsError(err, waddrmgr.ErrDuplicateAddress) {
+				result.Ignored++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			result.WatchOnly = append(
+				result.WatchOnly, maddr.Address(),
+			)
+		}
+
+		// We'll only move our birthday back to cover the dump, as
+		// moving it forward could miss relevant chain events while
+		// rescanning. Without a birthday block, it's determined from
+		// the birthday during the initial sync.
+		birthdayBlock, _, err := w.Manager.BirthdayBlock(ns)
+		switch {
+		case waddrmgr.IsError(err, waddrmgr.ErrBirthdayBlockNotSet):
+			if bs.Timestamp.Before(w.Manager.Birthday()) {
+				err := w.Manager.SetBirthday(ns, bs.Timestamp)
+				if err != nil {
+					return err
+				}
+			}
+
+		case err != nil:
+			return err
+
+		case bs.Height < birthdayBlock.Height:
+			err := w.Manager.SetBirthday(ns, bs.Timestamp)
+			if err != nil {
+				return err
+			}
+			err = w.Manager.SetBirthdayBlock(ns, *bs, false)
+			if err != nil {
+				return err
+			}
+		}
+
+		addrs, unspent, err = w.activeData(dbtx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Imported %d addresses and %d scripts from wallet dump, "+
+		"rescanning from height %d", len(result.Addresses),
+		len(result.WatchOnly), bs.Height)
+
+	// The rescan is resubmitted to the new backend if it's switched, so
+	// we're done with the current one.
+	done()
+	if err := w.rescanAndWait(addrs, unspent, bs); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// AddressLabel returns the label of an address known to the wallet, such as
+// one given to it by a wallet dump imported through ImportDumpWallet, or an
+// empty string if it has none.
+func (w *Wallet) AddressLabel(addr btcutil.Address) (string, error) {
+	var label string
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		addrmgrNs := tx.ReadBucket(waddrmgrNamespaceKey)
+
+		scopedMgr, _, err := w.Manager.AddrAccount(addrmgrNs, addr)
+		if err != nil {
+			return err
+		}
+
+		label, err = scopedMgr.AddressLabel(addrmgrNs, addr)
+		return err
+	})
+	return label, err
+}
+
+// setAddressLabel stores the label of an address known to the wallet within
+// the manager of its key scope.
+func (w *Wallet) setAddressLabel(ns walletdb.ReadWriteBucket,
+	addr btcutil.Address, label string) error {
+
+	scopedMgr, _, err := w.Manager.AddrAccount(ns, addr)
+	if err != nil {
+		return err
+	}
+
+	return scopedMgr.SetAddressLabel(ns, addr, label)
+}
+
+// importDumpKey imports the private key of a wallet dump into the imported
+// account of each of its key scopes, returning the addresses imported. HD
+// seeds and keys already known to the wallet aren't imported.
+func (w *Wallet) importDumpKey(ns walletdb.ReadWriteBucket, key *DumpKey,
+	bs *waddrmgr.BlockStamp) ([]btcutil.Address, error) {
+
+	if key.Kind == DumpKeyHDSeed {
+		return nil, nil
+	}
+
+	var imported []btcutil.Address
+	for _, scope := range key.keyScopes() {
+		scopedMgr, err := w.Manager.FetchScopedKeyManager(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		maddr, err := scopedMgr.ImportPrivateKey(ns, key.WIF, bs)
+		if waddrmgr.IsError(err, waddrmgr.ErrDuplicateAddress) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		imported = append(imported, maddr.Address())
+	}
+
+	return imported, nil
+}
+
+// dumpBirthdayBlock returns the block to rescan a wallet dump from, which is
+// the first block at or after the given creation time minus a safety window of
+// birthdayBlockDelta, as block timestamps may lag behind the time their
+// transactions were actually created.
+func (w *Wallet) dumpBirthdayBlock(chainClient chain.Interface,
+	birthday time.Time) (*waddrmgr.BlockStamp, error) {
+
+	birthday = birthday.Add(-birthdayBlockDelta)
+
+	genesis := &waddrmgr.BlockStamp{
+		Hash:      *w.chainParams.GenesisHash,
+		Timestamp: w.chainParams.GenesisBlock.Header.Timestamp,
+	}
+	if !birthday.After(genesis.Timestamp) {
+		return genesis, nil
+	}
+
+	height, err := chainClient.BlockHeightForTimestamp(birthday)
+	if err != nil {
+		return nil, err
+	}
+	if height == 0 {
+		return genesis, nil
+	}
+
+	hash, err := chainClient.GetBlockHash(int64(height))
+	if err != nil {
+		return nil, err
+	}
+	header, err := chainClient.GetBlockHeader(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &waddrmgr.BlockStamp{
+		Hash:      *hash,
+		Height:    height,
+		Timestamp: header.Timestamp,
+	}, nil
+}
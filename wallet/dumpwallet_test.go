@@ -0,0 +1,287 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestImportDumpWallet ensures that the keys of a bitcoind wallet dump are
+// parsed and imported into the imported account, skipping its malformed
+// lines, after which they're found through a single rescan and spendable.
+func TestImportDumpWallet(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	params := &chaincfg.TestNet3Params
+
+	newKey := func(compressed bool) *btcutil.WIF {
+		t.Helper()
+
+		privKey, err := btcec.NewPrivateKey(btcec.S256())
+		require.NoError(t, err)
+		wif, err := btcutil.NewWIF(privKey, params, compressed)
+		require.NoError(t, err)
+		return wif
+	}
+	pubKeyHash := func(wif *btcutil.WIF) []byte {
+		return btcutil.Hash160(wif.SerializePubKey())
+	}
+
+	seed, err := hdkeychain.GenerateSeed(hdkeychain.MinSeedBytes)
+	require.NoError(t, err)
+	masterKey, err := hdkeychain.NewMaster(seed, params)
+	require.NoError(t, err)
+
+	receiveKey := newKey(true)
+	receiveAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		pubKeyHash(receiveKey), params,
+	)
+	require.NoError(t, err)
+
+	changeKey := newKey(true)
+	changeScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_0).AddData(pubKeyHash(changeKey)).Script()
+	require.NoError(t, err)
+	changeAddr, err := btcutil.NewAddressScriptHash(changeScript, params)
+	require.NoError(t, err)
+
+	reserveKey := newKey(true)
+	reserveAddr, err := btcutil.NewAddressPubKeyHash(
+		pubKeyHash(reserveKey), params,
+	)
+	require.NoError(t, err)
+	reserveWitnessAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		pubKeyHash(reserveKey), params,
+	)
+	require.NoError(t, err)
+
+	seedKey := newKey(true)
+	seedAddr, err := btcutil.NewAddressPubKeyHash(
+		pubKeyHash(seedKey), params,
+	)
+	require.NoError(t, err)
+
+	// The dump watches a 1-of-2 multisig script between two of its keys.
+	var pubKeys []*btcutil.AddressPubKey
+	for _, wif := range []*btcutil.WIF{receiveKey, changeKey} {
+		pubKey, err := btcutil.NewAddressPubKey(
+			wif.SerializePubKey(), params,
+		)
+		require.NoError(t, err)
+		pubKeys = append(pubKeys, pubKey)
+	}
+	multiSigScript, err := txscript.MultiSigScript(pubKeys, 1)
+	require.NoError(t, err)
+	multiSigAddr, err := btcutil.NewAddressScriptHash(
+		multiSigScript, params,
+	)
+	require.NoError(t, err)
+
+	// Entries for another network should be skipped.
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	mainNetKey, err := btcutil.NewWIF(
+		privKey, &chaincfg.MainNetParams, true,
+	)
+	require.NoError(t, err)
+
+	dumpFile := fmt.Sprintf(`# Wallet dump created by Bitcoin v0.21.1
+# * Created on 2021-06-01T00:00:00Z
+# * Best block at time of backup was 100 (000000000000000000000000),
+#   mined on 2021-06-01T00:00:00Z
+
+# extended private masterkey: %s
+
+%s 2021-01-01T00:00:00Z label=savings%%20#1 # addr=%s hdkeypath=m/0'/0'/0'
+%s 2021-01-02T00:00:00Z change=1 # addr=%s hdkeypath=m/0'/1'/0'
+%s 2021-01-03T00:00:00Z reserve=1 # addr=%s,%s hdkeypath=m/0'/0'/1'
+%s 2021-01-01T00:00:00Z hdseed=1 # addr=%s hdkeypath=s
+%s 1970-01-01T00:00:01Z script=1 # addr=%s
+%s 2021-01-01T00:00:00Z label= # addr=%s
+notakey 2021-01-01T00:00:00Z label=
+%s yesterday reserve=1
+
+# End of dump
+`,
+		masterKey.String(),
+		receiveKey.String(), receiveAddr.EncodeAddress(),
+		changeKey.String(), changeAddr.EncodeAddress(),
+		reserveKey.String(), reserveAddr.EncodeAddress(),
+		reserveWitnessAddr.EncodeAddress(),
+		seedKey.String(), seedAddr.EncodeAddress(),
+		hex.EncodeToString(multiSigScript),
+		multiSigAddr.EncodeAddress(),
+		mainNetKey.String(), receiveAddr.EncodeAddress(),
+		newKey(true).String(),
+	)
+
+	// The key for another network, the invalid key and the invalid
+	// creation time should be skipped.
+	dump, err := ParseDumpWallet(strings.NewReader(dumpFile), params)
+	require.NoError(t, err)
+	require.Equal(t, 3, dump.Skipped)
+	require.NotNil(t, dump.MasterKey)
+	require.Equal(t, masterKey.String(), dump.MasterKey.String())
+
+	require.Len(t, dump.Keys, 4)
+	require.Equal(t, DumpKeyReceive, dump.Keys[0].Kind)
+	require.Equal(t, "savings #1", dump.Keys[0].Label)
+	require.Equal(t, "m/0'/0'/0'", dump.Keys[0].HDKeyPath)
+	require.Equal(t, DumpKeyChange, dump.Keys[1].Kind)
+	require.Equal(t, DumpKeyReserve, dump.Keys[2].Kind)
+	require.Len(t, dump.Keys[2].Addresses, 2)
+	require.Equal(t, DumpKeyHDSeed, dump.Keys[3].Kind)
+
+	require.Len(t, dump.Scripts, 1)
+	require.Equal(t, multiSigScript, dump.Scripts[0].Script)
+
+	// Fund each of the hinted addresses of the dump's keys, which should
+	// be found by the rescan following the import.
+	var txs []*wire.MsgTx
+	fundedAddrs := []btcutil.Address{receiveAddr, changeAddr, reserveAddr}
+	for i, addr := range fundedAddrs {
+		pkScript, err := txscript.PayToAddrScript(addr)
+		require.NoError(t, err)
+
+		tx := wire.NewMsgTx(2)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Index: uint32(i)},
+		})
+		tx.AddTxOut(wire.NewTxOut(100000, pkScript))
+		txs = append(txs, tx)
+	}
+
+	chainClient := &rescanningChainClient{
+		notifyingChainClient: notifyingChainClient{
+			notifications: make(chan interface{}),
+		},
+		txs: txs,
+		block: wtxmgr.BlockMeta{
+			Block: wtxmgr.Block{
+				Hash:   chainhash.Hash{1},
+				Height: 100,
+			},
+			Time: time.Now(),
+		},
+		tip: w.Manager.SyncedTo(),
+	}
+	w.chainClient = chainClient
+
+	w.wg.Add(4)
+	go w.handleChainNotifications()
+	go w.rescanBatchHandler()
+	go w.rescanProgressHandler()
+	go w.rescanRPCHandler()
+
+	result, err := w.ImportDumpWallet(dump)
+	require.NoError(t, err)
+	require.Equal(t, 1, chainClient.rescans)
+
+	// Every key is imported into the scopes of its hinted addresses, and
+	// the master key's into every default scope, while the HD seed is
+	// ignored.
+	require.Len(t, result.Addresses, 4+len(waddrmgr.DefaultKeyScopes))
+	require.True(t, result.MasterKeyImported)
+	require.Equal(t, 1, result.Reserved)
+	require.Equal(t, 1, result.Ignored)
+	require.Equal(t, map[string]string{
+		receiveAddr.EncodeAddress(): "savings #1",
+	}, result.Labels)
+	label, err := w.AddressLabel(receiveAddr)
+	require.NoError(t, err)
+	require.Equal(t, "savings #1", label)
+	require.Equal(t, []btcutil.Address{multiSigAddr}, result.WatchOnly)
+
+	hdSeedKnown, err := w.HaveAddress(seedAddr)
+	require.NoError(t, err)
+	require.False(t, hdSeedKnown)
+
+	keys := map[btcutil.Address]*btcutil.WIF{
+		receiveAddr:        receiveKey,
+		changeAddr:         changeKey,
+		reserveAddr:        reserveKey,
+		reserveWitnessAddr: reserveKey,
+	}
+	for addr, wif := range keys {
+		known, err := w.HaveAddress(addr)
+		require.NoError(t, err)
+		require.True(t, known, addr)
+
+		privKey, err := w.PrivKeyForAddress(addr)
+		require.NoError(t, err)
+		require.Equal(t, wif.PrivKey.Serialize(), privKey.Serialize())
+	}
+
+	// All of the funds found are spendable from the imported account,
+	// with the signatures of the transaction spending them validated.
+	balances, err := w.CalculateAccountBalances(
+		waddrmgr.ImportedAddrAccount, 0,
+	)
+	require.NoError(t, err)
+	require.Equal(t, btcutil.Amount(300000), balances.Total)
+
+	pkScript, err := txscript.PayToAddrScript(receiveAddr)
+	require.NoError(t, err)
+	tx, err := w.CreateSimpleTx(
+		nil, waddrmgr.ImportedAddrAccount,
+		[]*wire.TxOut{wire.NewTxOut(250000, pkScript)}, 0, 1000,
+		CoinSelectionLargest, false,
+	)
+	require.NoError(t, err)
+	require.Len(t, tx.Tx.TxIn, 3)
+}
+
+// timestampChainClient is a mock chain client recording the timestamps the
+// heights of blocks are looked up for.
+type timestampChainClient struct {
+	mockChainClient
+
+	timestamps []time.Time
+}
+
+func (c *timestampChainClient) BlockHeightForTimestamp(
+	timestamp time.Time) (int32, error) {
+
+	c.timestamps = append(c.timestamps, timestamp)
+	return 0, nil
+}
+
+// TestDumpBirthdayBlockSafetyWindow ensures that the block a wallet dump is
+// rescanned from is looked up for its creation time minus the birthday block
+// safety window.
+func TestDumpBirthdayBlockSafetyWindow(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	chainClient := &timestampChainClient{}
+	birthday := time.Now().Add(-24 * time.Hour)
+	bs, err := w.dumpBirthdayBlock(chainClient, birthday)
+	require.NoError(t, err)
+	require.Equal(t, *w.chainParams.GenesisHash, bs.Hash)
+	require.Len(t, chainClient.timestamps, 1)
+	require.True(t, chainClient.timestamps[0].Equal(
+		birthday.Add(-birthdayBlockDelta),
+	))
+}
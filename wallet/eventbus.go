@@ -0,0 +1,448 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// walletEventBufferSize is the number of events buffered for each wallet
+// subscription.
+const walletEventBufferSize = 256
+
+// ErrSubscriptionOverflow is returned by a wallet subscription that was
+// terminated as its buffer filled up, such that it would have otherwise missed
+// events.
+var ErrSubscriptionOverflow = errors.New("wallet subscription buffer " +
+	"overflowed")
+
+// WalletEvent is a high-level event of the wallet delivered to its
+// subscriptions, which is one of TxAddedEvent, TxConfirmationEvent,
+// BalanceEvent or ReorgEvent.
+type WalletEvent interface {
+	isWalletEvent()
+}
+
+// TxAddedEvent is delivered when a transaction relevant to the wallet is first
+// found, either unconfirmed or within a block.
+type TxAddedEvent struct {
+	// Tx summarizes the transaction and its relevance to the wallet.
+	Tx TransactionSummary
+
+	// Block is the block the transaction was found in, or nil if it's
+	// unconfirmed.
+	Block *wtxmgr.Block
+}
+
+// TxConfirmationEvent is delivered when a transaction known to the wallet is
+// confirmed, or moved back to unconfirmed by a reorg.
+type TxConfirmationEvent struct {
+	// Hash is the hash of the transaction.
+	Hash chainhash.Hash
+
+	// Block is the block the transaction now confirms in, or nil if it's
+	// unconfirmed.
+	Block *wtxmgr.Block
+}
+
+// BalanceEvent is delivered when the total balances of the wallet's accounts
+// change.
+type BalanceEvent struct {
+	// Balances are the new total balances of the accounts whose balance
+	// changed, sorted by account.
+	Balances []AccountBalance
+}
+
+// ReorgEvent is delivered when the wallet rolls back a block of its main chain
+// due to a reorg. It precedes the events of the transactions affected.
+type ReorgEvent struct {
+	// Hash is the hash of the disconnected block.
+	Hash chainhash.Hash
+
+	// Height is the height of the disconnected block.
+	Height int32
+}
+
+func (*TxAddedEvent) isWalletEvent()        {}
+func (*TxConfirmationEvent) isWalletEvent() {}
+func (*BalanceEvent) isWalletEvent()        {}
+func (*ReorgEvent) isWalletEvent()          {}
+
+// WalletSubscription delivers the events of the wallet, created through
+// Subscribe.
+type WalletSubscription struct {
+	// Events delivers the events of the wallet in the order they occurred.
+	// It's closed once the subscription ends.
+	Events <-chan WalletEvent
+
+	id     uint64
+	events chan WalletEvent
+	bus    *eventBus
+
+	// err is the reason the subscription was terminated by the wallet,
+	// protected by the bus' mutex.
+	err error
+}
+
+// Unsubscribe ends the subscription, closing its events channel. It's safe to
+// call more than once.
+func (s *WalletSubscription) Unsubscribe() {
+	s.bus.mtx.Lock()
+	defer s.bus.mtx.Unlock()
+
+	s.bus.remove(s)
+}
+
+// Err returns ErrSubscriptionOverflow if the subscription was terminated as
+// its events weren't received fast enough, or nil otherwise.
+func (s *WalletSubscription) Err() error {
+	s.bus.mtx.Lock()
+	defer s.bus.mtx.Unlock()
+
+	return s.err
+}
+
+// eventBus delivers the events of the wallet to its subscriptions.
+type eventBus struct {
+	mtx           sync.Mutex
+	subscriptions map[uint64]*WalletSubscription
+	nextID        uint64
+}
+
+// newEventBus creates an event bus without any subscriptions.
+func newEventBus() *eventBus {
+	return &eventBus{
+		subscriptions: make(map[uint64]*WalletSubscription),
+	}
+}
+
+// hasSubscriptions returns whether any subscription is registered with the
+// bus.
+func (b *eventBus) hasSubscriptions() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return len(b.subscriptions) > 0
+}
+
+// publish delivers the events to every subscription. Subscriptions whose
+// buffer is full are terminated rather than blocking the wallet.
+func (b *eventBus) publish(events []WalletEvent) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	for _, sub := range b.subscriptions {
+		for _, event := range events {
+			select {
+			case sub.events <- event:
+				continue
+			default:
+			}
+
+			log.Warnf("Terminating wallet subscription %d: %v",
+				sub.id, ErrSubscriptionOverflow)
+			sub.err = ErrSubscriptionOverflow
+			b.remove(sub)
+			break
+		}
+	}
+}
+
+// remove deregisters the subscription, closing its events channel.
+//
+// NOTE: This must be called with the bus' mutex held.
+func (b *eventBus) remove(sub *WalletSubscription) {
+	if _, ok := b.subscriptions[sub.id]; !ok {
+		return
+	}
+	delete(b.subscriptions, sub.id)
+	close(sub.events)
+}
+
+// Subscribe returns a subscription delivering the high-level events of the
+// wallet as it processes the notifications of its chain backend: relevant
+// transactions being found, their confirmation changing, account balances
+// changing, and blocks being rolled back by reorgs. Events are delivered in
+// the order they occurred, regardless of the chain backend, once the changes
+// causing them are committed.
+//
+// Up to 256 events are buffered. If the subscription falls further behind, it's
+// terminated, closing its events channel, and its Err method reports
+// ErrSubscriptionOverflow, such that subscribers are aware they missed events
+// and need to resubscribe and resync. When finished, Unsubscribe should be
+// called to release the subscription.
+func (w *Wallet) Subscribe() (*WalletSubscription, error) {
+	if w.ShuttingDown() {
+		return nil, ErrWalletShuttingDown
+	}
+
+	b := w.eventBus
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.nextID++
+	events := make(chan WalletEvent, walletEventBufferSize)
+	sub := &WalletSubscription{
+		Events: events,
+		id:     b.nextID,
+		events: events,
+		bus:    b,
+	}
+	b.subscriptions[sub.id] = sub
+
+	return sub, nil
+}
+
+// eventBatch determines the wallet events caused by processing a chain
+// notification, by comparing the state of the transactions it affects before
+// and after processing it within the same database transaction. A nil batch,
+// used when there are no subscriptions, does nothing.
+type eventBatch struct {
+	w *Wallet
+
+	// balances are the account balances before processing the
+	// notification.
+	balances map[uint32]btcutil.Amount
+
+	// txs are the transactions affected, along with their state before
+	// processing the notification.
+	txs  []watchedTx
+	seen map[chainhash.Hash]struct{}
+
+	// detached is the block disconnected by the notification, if any, and
+	// syncedHeight the height the wallet was synced to before.
+	detached     *wtxmgr.BlockMeta
+	syncedHeight int32
+
+	events []WalletEvent
+}
+
+// watchedTx is the state of a transaction affected by a chain notification
+// before it was processed.
+type watchedTx struct {
+	hash  chainhash.Hash
+	known bool
+	block wtxmgr.Block
+}
+
+// newEventBatch returns a batch for the events caused by a chain notification,
+// or nil if there are no subscriptions to deliver them to.
+func (w *Wallet) newEventBatch() *eventBatch {
+	if w.eventBus == nil || !w.eventBus.hasSubscriptions() {
+		return nil
+	}
+
+	return &eventBatch{
+		w:    w,
+		seen: make(map[chainhash.Hash]struct{}),
+	}
+}
+
+// begin records the account balances before processing the notification.
+func (b *eventBatch) begin(dbtx walletdb.ReadTx) error {
+	if b == nil {
+		return nil
+	}
+
+	var err error
+	b.balances, err = accountBalances(dbtx, b.w)
+	return err
+}
+
+// watchTxs records the state of the given transactions before processing the
+// notification.
+func (b *eventBatch) watchTxs(dbtx walletdb.ReadTx,
+	recs ...*wtxmgr.TxRecord) error {
+
+	if b == nil {
+		return nil
+	}
+
+	for _, rec := range recs {
+		if err := b.watchTx(dbtx, rec.Hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchTx records the state of the transaction before processing the
+// notification.
+func (b *eventBatch) watchTx(dbtx walletdb.ReadTx, hash chainhash.Hash) error {
+	if _, ok := b.seen[hash]; ok {
+		return nil
+	}
+	b.seen[hash] = struct{}{}
+
+	txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+	details, err := b.w.TxStore.TxDetails(txmgrNs, &hash)
+	if err != nil {
+		return err
+	}
+
+	tx := watchedTx{hash: hash}
+	if details != nil {
+		tx.known = true
+		tx.block = details.Block.Block
+	}
+	b.txs = append(b.txs, tx)
+
+	return nil
+}
+
+// watchRollback records the state of the transactions confirmed in the given
+// block or the blocks after it, which are rolled back if the block is
+// disconnected.
+func (b *eventBatch) watchRollback(dbtx walletdb.ReadTx,
+	block wtxmgr.BlockMeta) error {
+
+	if b == nil {
+		return nil
+	}
+
+	b.detached = &block
+	b.syncedHeight = b.w.Manager.SyncedTo().Height
+	if block.Height > b.syncedHeight {
+		return nil
+	}
+
+	var hashes []chainhash.Hash
+	txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+	err := b.w.TxStore.RangeTransactions(
+		txmgrNs, block.Height, b.syncedHeight,
+		func(details []wtxmgr.TxDetails) (bool, error) {
+			for i := range details {
+				hashes = append(hashes, details[i].Hash)
+			}
+			return false, nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if err := b.watchTx(dbtx, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish determines the events caused by processing the notification.
+func (b *eventBatch) finish(dbtx walletdb.ReadTx) error {
+	if b == nil {
+		return nil
+	}
+
+	if b.detached != nil && b.w.Manager.SyncedTo().Height < b.syncedHeight {
+		b.events = append(b.events, &ReorgEvent{
+			Hash:   b.detached.Hash,
+			Height: b.detached.Height,
+		})
+	}
+
+	txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+	for _, tx := range b.txs {
+		details, err := b.w.TxStore.TxDetails(txmgrNs, &tx.hash)
+		if err != nil {
+			return err
+		}
+
+		// Transactions removed from the wallet, such as coinbases of
+		// rolled back blocks, are only reflected in its balances.
+		if details == nil {
+			continue
+		}
+
+		var block *wtxmgr.Block
+		if details.Block.Height != -1 {
+			block = &details.Block.Block
+		}
+
+		switch {
+		case !tx.known:
+			b.events = append(b.events, &TxAddedEvent{
+				Tx:    makeTxSummary(dbtx, b.w, details),
+				Block: block,
+			})
+
+		case tx.block != details.Block.Block:
+			b.events = append(b.events, &TxConfirmationEvent{
+				Hash:  tx.hash,
+				Block: block,
+			})
+		}
+	}
+
+	balances, err := accountBalances(dbtx, b.w)
+	if err != nil {
+		return err
+	}
+	for account := range b.balances {
+		if _, ok := balances[account]; !ok {
+			balances[account] = 0
+		}
+	}
+	var changed []AccountBalance
+	for account, balance := range balances {
+		if balance != b.balances[account] {
+			changed = append(changed, AccountBalance{
+				Account:      account,
+				TotalBalance: balance,
+			})
+		}
+	}
+	if len(changed) > 0 {
+		sort.Slice(changed, func(i, j int) bool {
+			return changed[i].Account < changed[j].Account
+		})
+		b.events = append(b.events, &BalanceEvent{Balances: changed})
+	}
+
+	return nil
+}
+
+// publish delivers the events of the batch to the wallet's subscriptions. It
+// must only be called once the database transaction processing the
+// notification has been committed.
+func (b *eventBatch) publish() {
+	if b == nil || len(b.events) == 0 {
+		return
+	}
+
+	b.w.eventBus.publish(b.events)
+}
+
+// updateWithEvents processes a chain notification within a database
+// transaction through f, which records the transactions it affects with the
+// given batch, publishing the events it caused once committed.
+func (w *Wallet) updateWithEvents(
+	f func(walletdb.ReadWriteTx, *eventBatch) error) error {
+
+	events := w.newEventBatch()
+	err := walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		if err := events.begin(tx); err != nil {
+			return err
+		}
+		if err := f(tx, events); err != nil {
+			return err
+		}
+		return events.finish(tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	events.publish()
+	return nil
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// chainConnNotifyingClient is a mock chain client delivering the notifications
+// sent over its channel to the wallet, whose blocks are those of a mock chain
+// connection.
+type chainConnNotifyingClient struct {
+	notifyingChainClient
+
+	conn *mockChainConn
+}
+
+func (c *chainConnNotifyingClient) GetBlockHeader(
+	hash *chainhash.Hash) (*wire.BlockHeader, error) {
+
+	return c.conn.GetBlockHeader(hash)
+}
+
+// TestWalletSubscription ensures that a wallet subscription is delivered the
+// events caused by the chain notifications processed by the wallet, in order.
+func TestWalletSubscription(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	const numBlocks = 10
+	chainConn := createMockChainConn(
+		chaincfg.TestNet3Params.GenesisBlock, numBlocks,
+		defaultBlockInterval,
+	)
+	blockMeta := func(height int32) wtxmgr.BlockMeta {
+		hash := chainConn.blockHashes[uint32(height)]
+		return wtxmgr.BlockMeta{
+			Block: wtxmgr.Block{Hash: hash, Height: height},
+			Time:  chainConn.blocks[hash].Header.Timestamp,
+		}
+	}
+	for height := int32(1); height <= numBlocks; height++ {
+		block := blockMeta(height)
+		connect := func(tx walletdb.ReadWriteTx) error {
+			return w.connectBlock(tx, block)
+		}
+		require.NoError(t, walletdb.Update(w.db, connect))
+	}
+	w.SetChainSynced(true)
+
+	chainClient := &chainConnNotifyingClient{
+		notifyingChainClient: notifyingChainClient{
+			notifications: make(chan interface{}),
+		},
+		conn: chainConn,
+	}
+	w.chainClient = chainClient
+
+	sub, err := w.Subscribe()
+	require.NoError(t, err)
+
+	w.wg.Add(1)
+	go w.handleChainNotifications()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	// newTx returns a transaction paying to the wallet.
+	newTx := func(prevHash chainhash.Hash) *wtxmgr.TxRecord {
+		tx := wire.NewMsgTx(2)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Hash: prevHash},
+		})
+		tx.AddTxOut(wire.NewTxOut(100000, pkScript))
+
+		rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+		require.NoError(t, err)
+		return rec
+	}
+
+	// nextEvent returns the next event delivered to the subscription.
+	nextEvent := func() WalletEvent {
+		t.Helper()
+
+		select {
+		case event, ok := <-sub.Events:
+			require.True(t, ok)
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected wallet event")
+			return nil
+		}
+	}
+	requireBalance := func(balance btcutil.Amount) {
+		t.Helper()
+
+		require.Equal(t, &BalanceEvent{
+			Balances: []AccountBalance{{
+				Account:      0,
+				TotalBalance: balance,
+			}},
+		}, nextEvent())
+	}
+
+	// A new unconfirmed transaction should be notified along with the
+	// change in balance.
+	tip := blockMeta(numBlocks)
+	tx1 := newTx(chainhash.Hash{1})
+	chainClient.notifications <- chain.RelevantTx{TxRecord: tx1}
+
+	event := nextEvent()
+	require.IsType(t, &TxAddedEvent{}, event)
+	require.Equal(t, tx1.Hash, *event.(*TxAddedEvent).Tx.Hash)
+	require.Nil(t, event.(*TxAddedEvent).Block)
+	requireBalance(100000)
+
+	// Once it confirms, only its confirmation changes.
+	chainClient.notifications <- chain.RelevantTx{
+		TxRecord: tx1,
+		Block:    &tip,
+	}
+	require.Equal(t, &TxConfirmationEvent{
+		Hash:  tx1.Hash,
+		Block: &tip.Block,
+	}, nextEvent())
+
+	// A new transaction found within a block is notified as such.
+	tx2 := newTx(chainhash.Hash{2})
+	chainClient.notifications <- chain.RelevantTx{
+		TxRecord: tx2,
+		Block:    &tip,
+	}
+
+	event = nextEvent()
+	require.IsType(t, &TxAddedEvent{}, event)
+	require.Equal(t, tx2.Hash, *event.(*TxAddedEvent).Tx.Hash)
+	require.Equal(t, &tip.Block, event.(*TxAddedEvent).Block)
+	requireBalance(200000)
+
+	// Disconnecting the block should notify the reorg, followed by both
+	// transactions moving back to unconfirmed, without changing the
+	// balance.
+	chainClient.notifications <- chain.BlockDisconnected(tip)
+	require.Equal(t, &ReorgEvent{
+		Hash:   tip.Hash,
+		Height: tip.Height,
+	}, nextEvent())
+
+	unconfirmed := make(map[chainhash.Hash]struct{})
+	for i := 0; i < 2; i++ {
+		event := nextEvent()
+		require.IsType(t, &TxConfirmationEvent{}, event)
+		require.Nil(t, event.(*TxConfirmationEvent).Block)
+		unconfirmed[event.(*TxConfirmationEvent).Hash] = struct{}{}
+	}
+	require.Equal(t, map[chainhash.Hash]struct{}{
+		tx1.Hash: {},
+		tx2.Hash: {},
+	}, unconfirmed)
+
+	// Notifying a known transaction again doesn't cause any event.
+	chainClient.notifications <- chain.RelevantTx{TxRecord: tx1}
+	select {
+	case event := <-sub.Events:
+		t.Fatalf("unexpected event %T", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Unsubscribing closes the subscription's events channel.
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	_, ok := <-sub.Events
+	require.False(t, ok)
+	require.NoError(t, sub.Err())
+}
+
+// TestWalletSubscriptionOverflow ensures that a subscription whose buffer is
+// full is terminated rather than blocking the wallet.
+func TestWalletSubscriptionOverflow(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	sub, err := w.Subscribe()
+	require.NoError(t, err)
+
+	events := make([]WalletEvent, walletEventBufferSize+1)
+	for i := range events {
+		events[i] = &ReorgEvent{Height: int32(i)}
+	}
+	w.eventBus.publish(events)
+
+	for i := 0; i < walletEventBufferSize; i++ {
+		require.Equal(t, events[i], <-sub.Events)
+	}
+	_, ok := <-sub.Events
+	require.False(t, ok)
+	require.Equal(t, ErrSubscriptionOverflow, sub.Err())
+	require.False(t, w.eventBus.hasSubscriptions())
+}
+
+// TestWalletSubscriptionSend ensures that a subscription is delivered the
+// events caused by the wallet sending a transaction.
+func TestWalletSubscriptionSend(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	fundingTx := wire.NewMsgTx(wire.TxVersion)
+	fundingTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	fundingTx.AddTxOut(wire.NewTxOut(btcutil.SatoshiPerBitcoin, pkScript))
+	addUtxo(t, w, fundingTx)
+
+	sub, err := w.Subscribe()
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	tx, err := w.SendOutputs(
+		[]*wire.TxOut{wire.NewTxOut(10000000, testScriptP2WKH)},
+		&waddrmgr.KeyScopeBIP0084, 0, 1, 1000, CoinSelectionLargest,
+		"",
+	)
+	require.NoError(t, err)
+
+	nextEvent := func() WalletEvent {
+		t.Helper()
+
+		select {
+		case event, ok := <-sub.Events:
+			require.True(t, ok)
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected wallet event")
+			return nil
+		}
+	}
+
+	// The sent transaction should be notified along with the change in
+	// balance, which only retains its change output.
+	event := nextEvent()
+	require.IsType(t, &TxAddedEvent{}, event)
+	require.Equal(t, tx.TxHash(), *event.(*TxAddedEvent).Tx.Hash)
+	require.Nil(t, event.(*TxAddedEvent).Block)
+
+	event = nextEvent()
+	require.IsType(t, &BalanceEvent{}, event)
+	balances := event.(*BalanceEvent).Balances
+	require.Len(t, balances, 1)
+	require.Less(
+		t, int64(balances[0].TotalBalance),
+		int64(btcutil.SatoshiPerBitcoin-10000000),
+	)
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/psbt"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+const (
+	// externalSignerLeaseDuration is the duration for which the inputs of
+	// a transaction are leased while it's being signed by the external
+	// signer.
+	externalSignerLeaseDuration = 10 * time.Minute
+)
+
+var (
+	// ExternalSignerLockID is the ID used to lease the inputs of the
+	// transactions being signed by the external signer.
+	ExternalSignerLockID = wtxmgr.LockID(
+		chainhash.HashH([]byte("btcwallet/external-signer")),
+	)
+
+	// ErrNoExternalSigner is returned when attempting to send through an
+	// external signer without having registered one.
+	ErrNoExternalSigner = errors.New("no external signer registered")
+
+	// ErrAccountNotWatchOnly is returned when attempting to send from an
+	// account through an external signer when the wallet holds the
+	// account's private keys.
+	ErrAccountNotWatchOnly = errors.New("account is not watch-only")
+
+	// ErrAmbiguousAccountScope is returned when attempting to send from
+	// an account through an external signer without a key scope, while
+	// the account number matches watch-only accounts of several scopes.
+	ErrAmbiguousAccountScope = errors.New("account exists within " +
+		"multiple key scopes")
+
+	// ErrExternalSignerIncomplete is returned when the external signer
+	// returns a PSBT that can't be finalized.
+	ErrExternalSignerIncomplete = errors.New("external signer returned " +
+		"an incomplete PSBT")
+
+	// ErrExternalSignerTxModified is returned when the external signer
+	// returns a PSBT for a different transaction than the one it was asked
+	// to sign.
+	ErrExternalSignerTxModified = errors.New("external signer modified " +
+		"the transaction")
+
+	// ErrInvalidExternalSignature is returned when a signature returned by
+	// the external signer is invalid.
+	ErrInvalidExternalSignature = errors.New("invalid external signature")
+)
+
+// ExternalSigner signs the inputs of a PSBT on behalf of a watch-only wallet,
+// returning the signed packet. The inputs may be signed either with partial
+// signatures, which the wallet finalizes, or with final scripts. Returning an
+// error declines signing the packet.
+type ExternalSigner func(packet *psbt.Packet) (*psbt.Packet, error)
+
+// RegisterExternalSigner registers the signer used by
+// SendOutputsWithExternalSigner to sign transactions spending from watch-only
+// accounts. Registering a nil signer removes the current one.
+func (w *Wallet) RegisterExternalSigner(signer ExternalSigner) {
+	w.externalSignerMtx.Lock()
+	w.externalSigner = signer
+	w.externalSignerMtx.Unlock()
+}
+
+// registeredExternalSigner returns the registered external signer, or nil if
+// there is none.
+func (w *Wallet) registeredExternalSigner() ExternalSigner {
+	w.externalSignerMtx.Lock()
+	defer w.externalSignerMtx.Unlock()
+
+	return w.externalSigner
+}
+
+// SendOutputsWithExternalSigner creates a transaction paying the outputs,
+// funded by the given watch-only account, has it signed by the registered
+// external signer, and publishes it. The signatures returned by the signer are
+// verified before the transaction is published. The inputs of the transaction
+// are leased as they're selected, while it's being signed, and released once
+// it's published or if the signer declines or fails to fully sign it. If no key
+// scope is specified, the scope of the watch-only account with the given number
+// is used, and ErrAmbiguousAccountScope is returned if several scopes have one.
+func (w *Wallet) SendOutputsWithExternalSigner(outputs []*wire.TxOut,
+	keyScope *waddrmgr.KeyScope, account uint32, minconf int32,
+	satPerKb btcutil.Amount, coinSelectionStrategy CoinSelectionStrategy,
+	label string) (*wire.MsgTx, error) {
+
+	signer := w.registeredExternalSigner()
+	if signer == nil {
+		return nil, ErrNoExternalSigner
+	}
+
+	scope, err := w.watchOnlyAccountScope(keyScope, account)
+	if err != nil {
+		return nil, err
+	}
+
+	// The inputs are leased as they're selected, for as long as the
+	// transaction is being signed, so that they aren't selected elsewhere
+	// in the meantime.
+	packet, err := psbt.New(nil, outputs, wire.TxVersion, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.FundPsbt(
+		packet, &scope, minconf, account, satPerKb,
+		coinSelectionStrategy,
+		WithFundingLease(
+			ExternalSignerLockID, externalSignerLeaseDuration,
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, txIn := range packet.UnsignedTx.TxIn {
+			op := txIn.PreviousOutPoint
+			err := w.ReleaseOutput(ExternalSignerLockID, op)
+			if err != nil {
+				log.Warnf("Unable to release output %v: %v",
+					op, err)
+			}
+		}
+	}()
+
+	// The signer is handed a copy of the packet, such that the one we've
+	// funded can be used to verify what it returns.
+	unsigned, err := copyPsbt(packet)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := signer(unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("external signer declined to sign: %w",
+			err)
+	}
+
+	tx, err := extractExternallySigned(packet, signed)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.PublishTransaction(tx, label); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// watchOnlyAccountScope returns the key scope of the given watch-only account.
+// If no key scope is specified, it's resolved from the scopes holding an
+// account with the given number, of which exactly one must be watch-only.
+func (w *Wallet) watchOnlyAccountScope(keyScope *waddrmgr.KeyScope,
+	account uint32) (waddrmgr.KeyScope, error) {
+
+	var scopes []waddrmgr.KeyScope
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(waddrmgrNamespaceKey)
+
+		if keyScope != nil {
+			scopes = append(scopes, *keyScope)
+		} else {
+			mgrs := w.Manager.ActiveScopedKeyManagers()
+			for _, mgr := range mgrs {
+				_, err := mgr.AccountProperties(ns, account)
+				if err != nil {
+					continue
+				}
+				scopes = append(scopes, mgr.Scope())
+			}
+		}
+
+		var watchOnlyScopes []waddrmgr.KeyScope
+		for _, scope := range scopes {
+			watchOnly, err := w.Manager.IsWatchOnlyAccount(
+				ns, scope, account,
+			)
+			if err != nil {
+				return err
+			}
+			if watchOnly {
+				watchOnlyScopes = append(watchOnlyScopes, scope)
+			}
+		}
+
+		switch {
+		case len(watchOnlyScopes) == 0 && keyScope != nil:
+			return fmt.Errorf("%w: account %d of scope %v",
+				ErrAccountNotWatchOnly, account, *keyScope)
+
+		case len(watchOnlyScopes) == 0:
+			return fmt.Errorf("%w: account %d",
+				ErrAccountNotWatchOnly, account)
+
+		case len(watchOnlyScopes) > 1:
+			return fmt.Errorf("%w: account %d is watch-only "+
+				"within scopes %v", ErrAmbiguousAccountScope,
+				account, watchOnlyScopes)
+		}
+
+		scopes = watchOnlyScopes
+		return nil
+	})
+	if err != nil {
+		return waddrmgr.KeyScope{}, err
+	}
+
+	return scopes[0], nil
+}
+
+// extractExternallySigned finalizes the PSBT returned by the external signer
+// for the funded packet, and returns its transaction once its signatures have
+// been verified against the outputs spent by the funded packet.
+func extractExternallySigned(funded, signed *psbt.Packet) (*wire.MsgTx,
+	error) {
+
+	if signed == nil || signed.UnsignedTx == nil ||
+		len(signed.Inputs) != len(funded.Inputs) {
+
+		return nil, ErrExternalSignerIncomplete
+	}
+	if signed.UnsignedTx.TxHash() != funded.UnsignedTx.TxHash() {
+		return nil, ErrExternalSignerTxModified
+	}
+
+	// We'll only trust our own knowledge of the outputs being spent, as
+	// the signer could otherwise have us verify its signatures against
+	// different ones.
+	for i := range signed.Inputs {
+		signed.Inputs[i].WitnessUtxo = funded.Inputs[i].WitnessUtxo
+		signed.Inputs[i].NonWitnessUtxo = funded.Inputs[i].NonWitnessUtxo
+	}
+
+	if err := psbt.MaybeFinalizeAll(signed); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExternalSignerIncomplete,
+			err)
+	}
+	if !signed.IsComplete() {
+		return nil, ErrExternalSignerIncomplete
+	}
+	tx, err := psbt.Extract(signed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExternalSignerIncomplete,
+			err)
+	}
+
+	sigHashes := txscript.NewTxSigHashes(tx)
+	for i := range tx.TxIn {
+		prevOut := funded.Inputs[i].WitnessUtxo
+		vm, err := txscript.NewEngine(
+			prevOut.PkScript, tx, i, txscript.StandardVerifyFlags,
+			nil, sigHashes, prevOut.Value,
+		)
+		if err == nil {
+			err = vm.Execute()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: input %d: %v",
+				ErrInvalidExternalSignature, i, err)
+		}
+	}
+
+	return tx, nil
+}
+
+// copyPsbt returns a deep copy of the packet.
+func copyPsbt(packet *psbt.Packet) (*psbt.Packet, error) {
+	var b bytes.Buffer
+	if err := packet.Serialize(&b); err != nil {
+		return nil, err
+	}
+	return psbt.NewFromRawBytes(&b, false)
+}
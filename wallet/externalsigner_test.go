@@ -0,0 +1,199 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/btcsuite/btcutil/psbt"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSendOutputsWithExternalSigner ensures that transactions spending from a
+// watch-only account are only published once fully and validly signed by the
+// external signer, and that their inputs aren't left leased otherwise.
+func TestSendOutputsWithExternalSigner(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	// The external signer holds the keys of an account imported into the
+	// wallet as watch-only.
+	seed, err := hdkeychain.GenerateSeed(hdkeychain.MinSeedBytes)
+	require.NoError(t, err)
+	root, err := hdkeychain.NewMaster(seed, w.chainParams)
+	require.NoError(t, err)
+
+	addrType := waddrmgr.WitnessPubKey
+	acctPub := deriveAcctPubKey(
+		t, root, waddrmgr.KeyScopeBIP0084, hardenedKey(0),
+	)
+	acct, err := w.ImportAccount(
+		"signer", acctPub, root.ParentFingerprint(), &addrType,
+	)
+	require.NoError(t, err)
+
+	// Fund the watch-only account.
+	addr, err := w.NewAddress(acct.AccountNumber, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+	fundingTx := &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, pkScript)},
+	}
+	addUtxo(t, w, fundingTx)
+
+	destAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), w.chainParams,
+	)
+	require.NoError(t, err)
+	destScript, err := txscript.PayToAddrScript(destAddr)
+	require.NoError(t, err)
+
+	// signWith returns a signer adding partial signatures to every input
+	// with the key derived by the given function.
+	type keyDeriver func(path []uint32) *hdkeychain.ExtendedKey
+	signWith := func(deriveKey keyDeriver) ExternalSigner {
+		return func(packet *psbt.Packet) (*psbt.Packet, error) {
+			tx := packet.UnsignedTx
+			sigHashes := txscript.NewTxSigHashes(tx)
+			for i := range packet.Inputs {
+				in := &packet.Inputs[i]
+				key := deriveKey(in.Bip32Derivation[0].Bip32Path)
+				privKey, err := key.ECPrivKey()
+				if err != nil {
+					return nil, err
+				}
+
+				sig, err := txscript.RawTxInWitnessSignature(
+					tx, sigHashes, i, in.WitnessUtxo.Value,
+					in.WitnessUtxo.PkScript,
+					txscript.SigHashAll, privKey,
+				)
+				if err != nil {
+					return nil, err
+				}
+				in.PartialSigs = []*psbt.PartialSig{{
+					PubKey:    privKey.PubKey().SerializeCompressed(),
+					Signature: sig,
+				}}
+			}
+			return packet, nil
+		}
+	}
+	deriveFromRoot := func(path []uint32) *hdkeychain.ExtendedKey {
+		key := root
+		for _, index := range path {
+			key, err = key.Derive(index)
+			require.NoError(t, err)
+		}
+		return key
+	}
+
+	sendWithScope := func(keyScope *waddrmgr.KeyScope) (*wire.MsgTx,
+		error) {
+
+		return w.SendOutputsWithExternalSigner(
+			[]*wire.TxOut{wire.NewTxOut(100000, destScript)},
+			keyScope, acct.AccountNumber, 1, 1000,
+			CoinSelectionLargest, "external",
+		)
+	}
+	send := func() (*wire.MsgTx, error) {
+		return sendWithScope(&waddrmgr.KeyScopeBIP0084)
+	}
+	assertNotSpent := func() {
+		t.Helper()
+
+		leases, err := w.ListLeasedOutputs()
+		require.NoError(t, err)
+		require.Empty(t, leases)
+
+		utxos, err := w.ListUnspent(0, 1000, "")
+		require.NoError(t, err)
+		require.Len(t, utxos, 1)
+		require.Equal(t, fundingTx.TxHash().String(), utxos[0].TxID)
+	}
+
+	// Without a registered signer, nothing can be sent.
+	_, err = send()
+	require.True(t, errors.Is(err, ErrNoExternalSigner), err)
+
+	// A signer returning the packet unsigned should result in the send
+	// being aborted, without the inputs remaining leased.
+	w.RegisterExternalSigner(func(packet *psbt.Packet) (*psbt.Packet,
+		error) {
+
+		return packet, nil
+	})
+	_, err = send()
+	require.True(t, errors.Is(err, ErrExternalSignerIncomplete), err)
+	assertNotSpent()
+
+	// A signer signing with the wrong keys should be caught before the
+	// transaction is published.
+	deriveWrongKey := func(path []uint32) *hdkeychain.ExtendedKey {
+		wrongPath := append([]uint32(nil), path...)
+		wrongPath[len(wrongPath)-1]++
+		return deriveFromRoot(wrongPath)
+	}
+	w.RegisterExternalSigner(signWith(deriveWrongKey))
+	_, err = send()
+	require.True(t, errors.Is(err, ErrInvalidExternalSignature), err)
+	assertNotSpent()
+
+	// While being signed, the inputs are leased.
+	w.RegisterExternalSigner(func(packet *psbt.Packet) (*psbt.Packet,
+		error) {
+
+		leases, err := w.ListLeasedOutputs()
+		require.NoError(t, err)
+		require.Len(t, leases, 1)
+		require.Equal(t, ExternalSignerLockID, leases[0].LockID)
+
+		return packet, nil
+	})
+	_, err = send()
+	require.True(t, errors.Is(err, ErrExternalSignerIncomplete), err)
+	assertNotSpent()
+
+	// A signer signing correctly should result in the transaction being
+	// published. Without a key scope, the scope of the watch-only account
+	// is used.
+	w.RegisterExternalSigner(signWith(deriveFromRoot))
+	tx, err := sendWithScope(nil)
+	require.NoError(t, err)
+
+	prevOut := fundingTx.TxOut[0]
+	vm, err := txscript.NewEngine(
+		prevOut.PkScript, tx, 0, txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(tx), prevOut.Value,
+	)
+	require.NoError(t, err)
+	require.NoError(t, vm.Execute())
+
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		txHash := tx.TxHash()
+		details, err := w.TxStore.TxDetails(ns, &txHash)
+		require.NoError(t, err)
+		require.NotNil(t, details)
+		return nil
+	})
+	require.NoError(t, err)
+
+	leases, err := w.ListLeasedOutputs()
+	require.NoError(t, err)
+	require.Empty(t, leases)
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+var (
+	// ErrInvalidFeeEscalationPolicy is returned when attempting to enable
+	// fee escalation with an invalid policy.
+	ErrInvalidFeeEscalationPolicy = errors.New("invalid fee escalation " +
+		"policy")
+
+	// ErrFeeEstimateUnavailable is returned when the chain backend is
+	// unable to provide a fee estimate.
+	ErrFeeEstimateUnavailable = errors.New("fee estimate unavailable")
+)
+
+// FeeEscalationPolicy contains the parameters with which the wallet escalates
+// the fees of its unconfirmed transactions signaling replaceability as they're
+// rebroadcast.
+type FeeEscalationPolicy struct {
+	// UnconfirmedBlocks is the number of blocks a transaction must remain
+	// unconfirmed for before its fee is escalated. Once replaced, the
+	// replacement must remain unconfirmed for as many blocks again before
+	// it's escalated further.
+	UnconfirmedBlocks uint32
+
+	// ConfTarget is the number of blocks within which escalated
+	// transactions should confirm, used to estimate their new fee rate.
+	ConfTarget uint32
+
+	// MaxFeeRate is the highest fee rate, in satoshis per kB, escalated
+	// transactions may pay.
+	MaxFeeRate btcutil.Amount
+}
+
+// smartFeeEstimator is implemented by chain backends able to estimate the fee
+// rate required for a transaction to confirm within a number of blocks.
+type smartFeeEstimator interface {
+	EstimateSmartFee(confTarget int64, mode *btcjson.EstimateSmartFeeMode) (
+		*btcjson.EstimateSmartFeeResult, error)
+}
+
+// EnableFeeEscalation enables escalating the fees of the wallet's unconfirmed
+// transactions signaling replaceability according to policy whenever they're
+// rebroadcast. While enabled, unconfirmed transactions are rebroadcast on every
+// block connected once the wallet is synced to the chain. Transactions that
+// don't signal replaceability are rebroadcast unchanged.
+func (w *Wallet) EnableFeeEscalation(policy FeeEscalationPolicy) error {
+	if policy.UnconfirmedBlocks == 0 {
+		return fmt.Errorf("%w: number of unconfirmed blocks must be "+
+			"positive", ErrInvalidFeeEscalationPolicy)
+	}
+	if policy.ConfTarget == 0 {
+		return fmt.Errorf("%w: confirmation target must be positive",
+			ErrInvalidFeeEscalationPolicy)
+	}
+	if policy.MaxFeeRate <= 0 {
+		return fmt.Errorf("%w: max fee rate must be positive",
+			ErrInvalidFeeEscalationPolicy)
+	}
+
+	w.feeEscalationMtx.Lock()
+	w.feeEscalationPolicy = &policy
+	w.feeEscalationMtx.Unlock()
+
+	return nil
+}
+
+// DisableFeeEscalation disables escalating the fees of the wallet's
+// unconfirmed transactions. Replacements already published are unaffected.
+func (w *Wallet) DisableFeeEscalation() {
+	w.feeEscalationMtx.Lock()
+	w.feeEscalationPolicy = nil
+	w.unconfirmedSince = make(map[chainhash.Hash]int32)
+	w.feeEscalationMtx.Unlock()
+}
+
+// currentFeeEscalationPolicy returns the current fee escalation policy, or nil
+// if escalation is disabled.
+func (w *Wallet) currentFeeEscalationPolicy() *FeeEscalationPolicy {
+	w.feeEscalationMtx.Lock()
+	defer w.feeEscalationMtx.Unlock()
+
+	return w.feeEscalationPolicy
+}
+
+// triggerRebroadcast requests the rebroadcaster to resend the wallet's
+// unconfirmed transactions without blocking the caller.
+func (w *Wallet) triggerRebroadcast() {
+	select {
+	case w.rebroadcastTrigger <- struct{}{}:
+	default:
+	}
+}
+
+// rebroadcaster resends the wallet's unconfirmed transactions whenever it's
+// triggered, such that transactions aren't escalated concurrently. The first
+// time it's triggered once the wallet is synced, the unconfirmed transactions
+// are reconciled against the mempool before being resent.
+//
+// NOTE: This must be run as a goroutine.
+func (w *Wallet) rebroadcaster() {
+	defer w.wg.Done()
+
+	var reconciled bool
+	quit := w.quitChan()
+	for {
+		select {
+		case <-w.rebroadcastTrigger:
+		case <-quit:
+			return
+		}
+
+		if !reconciled && w.ChainSynced() {
+			w.reconcileMempoolAtStartup()
+			reconciled = true
+		}
+
+		w.resendUnminedTxs()
+	}
+}
+
+// unconfirmedBlocks records the unconfirmed transactions as seen at the given
+// height, if they weren't already, and returns the number of blocks each has
+// remained unconfirmed for. Transactions no longer unconfirmed are forgotten.
+func (w *Wallet) unconfirmedBlocks(txs []*wire.MsgTx,
+	height int32) map[chainhash.Hash]int32 {
+
+	w.feeEscalationMtx.Lock()
+	defer w.feeEscalationMtx.Unlock()
+
+	unconfirmedSince := make(map[chainhash.Hash]int32, len(txs))
+	blocks := make(map[chainhash.Hash]int32, len(txs))
+	for _, tx := range txs {
+		txHash := tx.TxHash()
+		since, ok := w.unconfirmedSince[txHash]
+		if !ok {
+			since = height
+		}
+		unconfirmedSince[txHash] = since
+		blocks[txHash] = height - since
+	}
+	w.unconfirmedSince = unconfirmedSince
+
+	return blocks
+}
+
+// markUnconfirmedSince records the transaction as seen unconfirmed since the
+// given height.
+func (w *Wallet) markUnconfirmedSince(txHash chainhash.Hash, height int32) {
+	w.feeEscalationMtx.Lock()
+	w.unconfirmedSince[txHash] = height
+	w.feeEscalationMtx.Unlock()
+}
+
+// estimateFeeRate returns the fee rate, in satoshis per kB, estimated by the
+// chain backend for a transaction to confirm within confTarget blocks.
+func (w *Wallet) estimateFeeRate(confTarget uint32) (btcutil.Amount, error) {
+	chainClient, done, err := w.useChainClient()
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+
+	estimator, ok := chainClient.(smartFeeEstimator)
+	if !ok {
+		return 0, fmt.Errorf("%w: %v backend does not estimate fees",
+			ErrFeeEstimateUnavailable, chainClient.BackEnd())
+	}
+	result, err := estimator.EstimateSmartFee(int64(confTarget), nil)
+	if err != nil {
+		return 0, err
+	}
+	if result.FeeRate == nil {
+		return 0, fmt.Errorf("%w: %v", ErrFeeEstimateUnavailable,
+			result.Errors)
+	}
+
+	return btcutil.NewAmount(*result.FeeRate)
+}
+
+// escalateFee replaces the unconfirmed transaction with one paying the fee rate
+// estimated for the policy's confirmation target, capped at its max fee rate.
+// The replacement result is returned.
+func (w *Wallet) escalateFee(tx *wire.MsgTx,
+	policy *FeeEscalationPolicy) (*FeeBumpResult, error) {
+
+	feeRate, err := w.estimateFeeRate(policy.ConfTarget)
+	if err != nil {
+		return nil, err
+	}
+	if feeRate > policy.MaxFeeRate {
+		feeRate = policy.MaxFeeRate
+	}
+
+	return w.BumpTransactionFee(tx.TxHash(), feeRate, RBFOnly)
+}
@@ -0,0 +1,411 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// feeEstimatorChainClient is a mock chain client estimating a fixed fee rate
+// and recording the transactions sent through it.
+type feeEstimatorChainClient struct {
+	mockChainClient
+
+	feeRate float64
+	sent    []*wire.MsgTx
+}
+
+func (c *feeEstimatorChainClient) EstimateSmartFee(int64,
+	*btcjson.EstimateSmartFeeMode) (*btcjson.EstimateSmartFeeResult, error) {
+
+	return &btcjson.EstimateSmartFeeResult{FeeRate: &c.feeRate}, nil
+}
+
+func (c *feeEstimatorChainClient) SendRawTransaction(tx *wire.MsgTx,
+	_ bool) (*chainhash.Hash, error) {
+
+	c.sent = append(c.sent, tx)
+	txHash := tx.TxHash()
+	return &txHash, nil
+}
+
+// TestFeeEscalation ensures that the fee of an unconfirmed transaction
+// signaling replaceability is only escalated once it has remained unconfirmed
+// for the configured number of blocks, that the escalated fee rate is capped,
+// and that transactions not signaling replaceability are resent unchanged.
+func TestFeeEscalation(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	chainClient := &feeEstimatorChainClient{feeRate: 0.0005}
+	w.chainClient = chainClient
+
+	// Policies that would never escalate any fees should be rejected.
+	err := w.EnableFeeEscalation(FeeEscalationPolicy{
+		ConfTarget: 2,
+		MaxFeeRate: 20000,
+	})
+	if !errors.Is(err, ErrInvalidFeeEscalationPolicy) {
+		t.Fatalf("expected ErrInvalidFeeEscalationPolicy, got: %v", err)
+	}
+
+	// The estimated fee rate of 50000 sat/kB exceeds the max fee rate, so
+	// escalated transactions should pay the max fee rate instead.
+	policy := FeeEscalationPolicy{
+		UnconfirmedBlocks: 3,
+		ConfTarget:        2,
+		MaxFeeRate:        20000,
+	}
+	if err := w.EnableFeeEscalation(policy); err != nil {
+		t.Fatalf("unable to enable fee escalation: %v", err)
+	}
+
+	newScript := func(change bool) []byte {
+		t.Helper()
+
+		var (
+			addr btcutil.Address
+			err  error
+		)
+		if change {
+			addr, err = w.NewChangeAddress(0, waddrmgr.KeyScopeBIP0084)
+		} else {
+			addr, err = w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+		}
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("unable to create pkScript: %v", err)
+		}
+		return pkScript
+	}
+	foreignAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), w.chainParams,
+	)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	foreignScript, err := txscript.PayToAddrScript(foreignAddr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+
+	const (
+		funding = 1000000
+		payment = 100000
+		lowFee  = 200
+	)
+	fundingTx := wire.NewMsgTx(wire.TxVersion)
+	fundingTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	fundingTx.AddTxOut(wire.NewTxOut(funding, newScript(false)))
+	fundingTx.AddTxOut(wire.NewTxOut(funding, newScript(false)))
+	addUtxo(t, w, fundingTx)
+
+	// spend creates and adds to the wallet a signed transaction spending
+	// the given funding output, paying to a foreign address, and returning
+	// the rest as change.
+	spend := func(index uint32, sequence uint32) *wire.MsgTx {
+		t.Helper()
+
+		prevOut := wire.OutPoint{Hash: fundingTx.TxHash(), Index: index}
+		prevTxOut := fundingTx.TxOut[index]
+
+		tx := wire.NewMsgTx(wire.TxVersion)
+		txIn := wire.NewTxIn(&prevOut, nil, nil)
+		txIn.Sequence = sequence
+		tx.AddTxIn(txIn)
+		tx.AddTxOut(wire.NewTxOut(payment, foreignScript))
+		tx.AddTxOut(wire.NewTxOut(
+			funding-payment-lowFee, newScript(true),
+		))
+
+		err := walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+			addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+			err := txauthor.AddAllInputScripts(
+				tx, [][]byte{prevTxOut.PkScript},
+				[]btcutil.Amount{btcutil.Amount(prevTxOut.Value)},
+				secretSource{w.Manager, addrmgrNs},
+			)
+			if err != nil {
+				return err
+			}
+
+			rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+			if err != nil {
+				return err
+			}
+			return w.addRelevantTx(dbtx, rec, nil)
+		})
+		if err != nil {
+			t.Fatalf("unable to add tx: %v", err)
+		}
+		return tx
+	}
+	rbfTx := spend(0, wire.MaxTxInSequenceNum-2)
+	finalTx := spend(1, wire.MaxTxInSequenceNum)
+
+	// rebroadcastAt simulates the wallet being synced to the given height
+	// and rebroadcasting its unconfirmed transactions, returning those
+	// sent to the chain backend.
+	rebroadcastAt := func(height int32) []*wire.MsgTx {
+		t.Helper()
+
+		setSyncedHeight(t, w, height)
+		chainClient.sent = nil
+		w.resendUnminedTxs()
+		return chainClient.sent
+	}
+
+	// Until the threshold is reached, both transactions should be resent
+	// unchanged.
+	const startHeight = 100
+	for height := startHeight; height < startHeight+3; height++ {
+		sent := rebroadcastAt(int32(height))
+		if len(sent) != 2 {
+			t.Fatalf("expected 2 transactions resent at height %d, "+
+				"got %d", height, len(sent))
+		}
+		for _, tx := range sent {
+			if tx.TxHash() != rbfTx.TxHash() &&
+				tx.TxHash() != finalTx.TxHash() {
+
+				t.Fatalf("unexpected transaction %v resent at "+
+					"height %d", tx.TxHash(), height)
+			}
+		}
+	}
+
+	// Once the threshold is reached, the transaction signaling
+	// replaceability should be replaced by one paying the max fee rate,
+	// while the other is still resent unchanged.
+	sent := rebroadcastAt(startHeight + 3)
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 transactions sent, got %d", len(sent))
+	}
+	var replacement *wire.MsgTx
+	for _, tx := range sent {
+		switch tx.TxHash() {
+		case finalTx.TxHash():
+		case rbfTx.TxHash():
+			t.Fatal("expected transaction signaling replaceability " +
+				"to be replaced")
+		default:
+			replacement = tx
+		}
+	}
+	if replacement == nil {
+		t.Fatal("expected replacement to be sent")
+	}
+	if replacement.TxIn[0].PreviousOutPoint != rbfTx.TxIn[0].PreviousOutPoint {
+		t.Fatalf("expected replacement to spend %v, got %v",
+			rbfTx.TxIn[0].PreviousOutPoint,
+			replacement.TxIn[0].PreviousOutPoint)
+	}
+	fee := funding - txauthor.SumOutputValues(replacement.TxOut)
+	maxFee := txrules.FeeForSerializeSize(
+		policy.MaxFeeRate, txVirtualSize(replacement),
+	)
+	if fee <= lowFee || fee > maxFee {
+		t.Fatalf("expected escalated fee above %v capped at %v, got %v",
+			btcutil.Amount(lowFee), maxFee, fee)
+	}
+
+	// The replaced transaction should have been removed from the wallet.
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		rbfHash := rbfTx.TxHash()
+		details, err := w.TxStore.TxDetails(ns, &rbfHash)
+		if err != nil {
+			return err
+		}
+		if details != nil {
+			t.Fatal("expected replaced transaction to be removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The replacement shouldn't be escalated again until it has remained
+	// unconfirmed for as many blocks itself, and as it already pays the
+	// max fee rate, it's resent unchanged.
+	for height := startHeight + 4; height < startHeight+8; height++ {
+		sent := rebroadcastAt(int32(height))
+		if len(sent) != 2 {
+			t.Fatalf("expected 2 transactions resent at height %d, "+
+				"got %d", height, len(sent))
+		}
+		for _, tx := range sent {
+			if tx.TxHash() != replacement.TxHash() &&
+				tx.TxHash() != finalTx.TxHash() {
+
+				t.Fatalf("unexpected transaction %v resent at "+
+					"height %d", tx.TxHash(), height)
+			}
+		}
+	}
+}
+
+// TestFeeEscalationCeiling ensures that escalations and bumps paying a fee
+// above the wallet's fee ceiling are refused, leaving the transaction to be
+// resent unchanged.
+func TestFeeEscalationCeiling(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	chainClient := &feeEstimatorChainClient{feeRate: 0.0005}
+	w.chainClient = chainClient
+
+	err := w.EnableFeeEscalation(FeeEscalationPolicy{
+		UnconfirmedBlocks: 1,
+		ConfTarget:        2,
+		MaxFeeRate:        20000,
+	})
+	if err != nil {
+		t.Fatalf("unable to enable fee escalation: %v", err)
+	}
+
+	// Invalid ceilings should be rejected.
+	err = w.SetFeeCeiling(FeeCeiling{MaxPercent: 101})
+	if !errors.Is(err, ErrInvalidFeeCeiling) {
+		t.Fatalf("expected ErrInvalidFeeCeiling, got: %v", err)
+	}
+	err = w.SetFeeCeiling(FeeCeiling{MaxFee: -1})
+	if !errors.Is(err, ErrInvalidFeeCeiling) {
+		t.Fatalf("expected ErrInvalidFeeCeiling, got: %v", err)
+	}
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	changeAddr, err := w.NewChangeAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	changeScript, err := txscript.PayToAddrScript(changeAddr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+
+	const (
+		funding = 1000000
+		payment = 100000
+		lowFee  = 200
+	)
+	fundingTx := wire.NewMsgTx(wire.TxVersion)
+	fundingTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	fundingTx.AddTxOut(wire.NewTxOut(funding, pkScript))
+	addUtxo(t, w, fundingTx)
+
+	// Create a transaction signaling replaceability paying a low fee.
+	tx := wire.NewMsgTx(wire.TxVersion)
+	txIn := wire.NewTxIn(
+		&wire.OutPoint{Hash: fundingTx.TxHash()}, nil, nil,
+	)
+	txIn.Sequence = wire.MaxTxInSequenceNum - 2
+	tx.AddTxIn(txIn)
+	tx.AddTxOut(wire.NewTxOut(payment, testScriptP2WKH))
+	tx.AddTxOut(wire.NewTxOut(funding-payment-lowFee, changeScript))
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+		err := txauthor.AddAllInputScripts(
+			tx, [][]byte{pkScript},
+			[]btcutil.Amount{btcutil.Amount(funding)},
+			secretSource{w.Manager, addrmgrNs},
+		)
+		if err != nil {
+			return err
+		}
+
+		rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+		if err != nil {
+			return err
+		}
+		return w.addRelevantTx(dbtx, rec, nil)
+	})
+	if err != nil {
+		t.Fatalf("unable to add tx: %v", err)
+	}
+
+	// rebroadcastAt simulates the wallet being synced to the given height
+	// and rebroadcasting its unconfirmed transactions, returning those
+	// sent to the chain backend.
+	rebroadcastAt := func(height int32) []*wire.MsgTx {
+		t.Helper()
+
+		setSyncedHeight(t, w, height)
+		chainClient.sent = nil
+		w.resendUnminedTxs()
+		return chainClient.sent
+	}
+
+	// The transaction is first seen as unconfirmed at the start height,
+	// and escalated from the next one on.
+	const startHeight = 100
+	rebroadcastAt(startHeight)
+
+	// The escalated fee at the max fee rate of 20000 sat/kB exceeds both
+	// an absolute cap of 1000 sat and a cap of 0.1% of the output value.
+	for i, ceiling := range []FeeCeiling{
+		{MaxFee: 1000},
+		{MaxPercent: 0.1},
+	} {
+		if err := w.SetFeeCeiling(ceiling); err != nil {
+			t.Fatalf("unable to set fee ceiling: %v", err)
+		}
+
+		// A bump above the ceiling should be refused.
+		_, err := w.BumpTransactionFee(tx.TxHash(), 20000, RBFOnly)
+		if !errors.Is(err, ErrFeeExceedsCeiling) {
+			t.Fatalf("expected ErrFeeExceedsCeiling, got: %v", err)
+		}
+
+		// Escalating the fee should be refused as well, with the
+		// transaction resent unchanged instead.
+		sent := rebroadcastAt(startHeight + 1 + int32(i))
+		if len(sent) != 1 || sent[0].TxHash() != tx.TxHash() {
+			t.Fatalf("expected transaction to be resent "+
+				"unchanged, got %v", sent)
+		}
+	}
+
+	// Once the ceiling allows it, the fee should be escalated.
+	if err := w.SetFeeCeiling(DefaultFeeCeiling); err != nil {
+		t.Fatalf("unable to set fee ceiling: %v", err)
+	}
+	sent := rebroadcastAt(startHeight + 3)
+	if len(sent) != 1 || sent[0].TxHash() == tx.TxHash() {
+		t.Fatalf("expected transaction to be replaced, got %v", sent)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+)
+
+var (
+	// ErrFeeRateOutOfBounds is returned when attempting to create a
+	// transaction at a fee rate outside of the wallet's fee rate bounds,
+	// without overriding them through WithFeeRateBoundsOverride.
+	ErrFeeRateOutOfBounds = errors.New("fee rate out of bounds")
+
+	// ErrInvalidFeeRateBounds is returned when attempting to set invalid
+	// fee rate bounds.
+	ErrInvalidFeeRateBounds = errors.New("invalid fee rate bounds")
+)
+
+// DefaultFeeRateBounds are the fee rate bounds used by the wallet unless
+// configured otherwise through SetFeeRateBounds: from the default relay fee
+// rate of 1 sat/vbyte to 1000 sat/vbyte.
+var DefaultFeeRateBounds = FeeRateBounds{
+	Min: txrules.DefaultRelayFeePerKb,
+	Max: btcutil.Amount(1e6),
+}
+
+// FeeRateBounds are the lowest and highest fee rates, in satoshis per kB, at
+// which the wallet creates transactions, guarding against fee rates given in
+// the wrong unit.
+type FeeRateBounds struct {
+	// Min is the lowest fee rate. A value of zero disables the bound.
+	Min btcutil.Amount
+
+	// Max is the highest fee rate. A value of zero disables the bound.
+	Max btcutil.Amount
+}
+
+// check returns an error wrapping ErrFeeRateOutOfBounds if the fee rate is
+// outside of the bounds.
+func (b *FeeRateBounds) check(feeSatPerKb btcutil.Amount) error {
+	if b.Min > 0 && feeSatPerKb < b.Min {
+		return fmt.Errorf("%w: fee rate of %v/kB is below the minimum "+
+			"of %v/kB", ErrFeeRateOutOfBounds, feeSatPerKb, b.Min)
+	}
+	if b.Max > 0 && feeSatPerKb > b.Max {
+		return fmt.Errorf("%w: fee rate of %v/kB exceeds the maximum "+
+			"of %v/kB", ErrFeeRateOutOfBounds, feeSatPerKb, b.Max)
+	}
+
+	return nil
+}
+
+// SetFeeRateBounds sets the lowest and highest fee rates at which the wallet
+// creates transactions. Transactions at fee rates outside of them fail with an
+// error wrapping ErrFeeRateOutOfBounds, unless WithFeeRateBoundsOverride is
+// given. The minimum doesn't apply to transactions created with
+// WithEphemeralAnchor, which usually pay no fee. By default, this is
+// DefaultFeeRateBounds.
+//
+// NOTE: This should be called before the wallet is used to create any
+// transactions.
+func (w *Wallet) SetFeeRateBounds(bounds FeeRateBounds) error {
+	if bounds.Min < 0 || bounds.Max < 0 {
+		return fmt.Errorf("%w: fee rates must not be negative",
+			ErrInvalidFeeRateBounds)
+	}
+	if bounds.Max > 0 && bounds.Min > bounds.Max {
+		return fmt.Errorf("%w: minimum of %v/kB exceeds the maximum "+
+			"of %v/kB", ErrInvalidFeeRateBounds, bounds.Min,
+			bounds.Max)
+	}
+
+	w.feeRateBounds = bounds
+	return nil
+}
+
+// checkTxFeeRate ensures the fee rate a transaction is created at is within
+// the wallet's fee rate bounds, unless overridden through the options. Only the
+// maximum applies to transactions with an ephemeral anchor, as they usually pay
+// no fee.
+func (w *Wallet) checkTxFeeRate(feeSatPerKb btcutil.Amount,
+	opts *txCreateOptions) error {
+
+	if opts.overrideFeeRateBounds {
+		return nil
+	}
+
+	feeRateBounds := w.feeRateBounds
+	if opts.ephemeralAnchor {
+		feeRateBounds.Min = 0
+	}
+	return feeRateBounds.check(feeSatPerKb)
+}
+
+// WithFeeRateBoundsOverride creates the transaction at the given fee rate even
+// if it's outside of the wallet's fee rate bounds, e.g. to deliberately pay a
+// very high fee rate.
+func WithFeeRateBoundsOverride() TxCreateOption {
+	return func(opts *txCreateOptions) {
+		opts.overrideFeeRateBounds = true
+	}
+}
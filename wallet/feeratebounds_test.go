@@ -0,0 +1,59 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFeeRateBounds ensures that transactions are only created at fee rates
+// within the wallet's fee rate bounds, unless they're overridden.
+func TestFeeRateBounds(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	fundWallet(t, w, 1000000)
+	outputs := []*wire.TxOut{wire.NewTxOut(100000, testScriptP2WKH)}
+
+	createTx := func(feeSatPerKb btcutil.Amount,
+		opts ...TxCreateOption) error {
+
+		_, err := w.CreateSimpleTx(
+			nil, 0, outputs, 1, feeSatPerKb, CoinSelectionLargest,
+			true, opts...,
+		)
+		return err
+	}
+
+	// An absurd fee rate of 2000 sat/vbyte, e.g. given in the wrong unit,
+	// is rejected unless the bounds are overridden.
+	const absurdFeeRate = 2e6
+	err := createTx(absurdFeeRate)
+	require.True(t, errors.Is(err, ErrFeeRateOutOfBounds), err)
+	require.NoError(t, createTx(absurdFeeRate, WithFeeRateBoundsOverride()))
+
+	// So is a fee rate below the relay fee rate.
+	err = createTx(500)
+	require.True(t, errors.Is(err, ErrFeeRateOutOfBounds), err)
+	require.NoError(t, createTx(500, WithFeeRateBoundsOverride()))
+	require.NoError(t, createTx(DefaultFeeRateBounds.Min))
+	require.NoError(t, createTx(DefaultFeeRateBounds.Max/10))
+
+	// Disabling the bounds allows any fee rate.
+	require.NoError(t, w.SetFeeRateBounds(FeeRateBounds{}))
+	require.NoError(t, createTx(absurdFeeRate))
+	require.NoError(t, createTx(500))
+
+	// The minimum may not exceed the maximum.
+	err = w.SetFeeRateBounds(FeeRateBounds{Min: 2000, Max: 1000})
+	require.True(t, errors.Is(err, ErrInvalidFeeRateBounds), err)
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"math/rand"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// antiFeeSnipingBackOffChance is the inverse of the probability that
+	// the lock-time of a transaction is backed off from the current block
+	// height.
+	antiFeeSnipingBackOffChance = 10
+
+	// antiFeeSnipingMaxBackOff is the maximum number of blocks the
+	// lock-time of a transaction is backed off by.
+	antiFeeSnipingMaxBackOff = 99
+)
+
+// SetAntiFeeSniping sets whether the lock-time of the transactions created by
+// the wallet is set to the current block height, such that they can only be
+// mined in the next block. This discourages miners from reorging the chain to
+// collect the fees of its recent transactions, known as fee sniping. As is
+// conventional, the lock-time is backed off by up to 99 blocks for one in ten
+// transactions, such that those broadcast late, which would otherwise be told
+// apart, have company. Inputs whose sequence is final are made non-final for
+// the lock-time to be enforced. It is disabled by default, in which case the
+// lock-time is zero, keeping transactions reproducible.
+//
+// NOTE: This should be called before the wallet is used to create any
+// transactions.
+func (w *Wallet) SetAntiFeeSniping(enabled bool) {
+	w.antiFeeSniping = enabled
+}
+
+// setAntiFeeSnipingLockTime sets the lock-time of the transaction to the given
+// block height, backed off at random, making its final sequences non-final.
+func setAntiFeeSnipingLockTime(tx *wire.MsgTx, height int32) {
+	lockTime := height
+	if rand.Intn(antiFeeSnipingBackOffChance) == 0 {
+		lockTime -= rand.Int31n(antiFeeSnipingMaxBackOff + 1)
+	}
+	if lockTime < 0 {
+		lockTime = 0
+	}
+	tx.LockTime = uint32(lockTime)
+
+	for _, txIn := range tx.TxIn {
+		if txIn.Sequence == wire.MaxTxInSequenceNum {
+			txIn.Sequence = wire.MaxTxInSequenceNum - 1
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAntiFeeSniping ensures that the lock-time of transactions created with
+// anti fee sniping enabled is set to the current block height, within the
+// back-off window, with their inputs' sequences being non-final, while it's
+// left as zero by default.
+func TestAntiFeeSniping(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	fundWallet(t, w, 1000000)
+	outputs := []*wire.TxOut{wire.NewTxOut(100000, testScriptP2WKH)}
+
+	bs, err := w.chainClient.BlockStamp()
+	require.NoError(t, err)
+
+	tx, err := w.CreateSimpleTx(
+		nil, 0, outputs, 1, 1000, CoinSelectionLargest, true,
+	)
+	require.NoError(t, err)
+	require.Zero(t, tx.Tx.LockTime)
+	for _, txIn := range tx.Tx.TxIn {
+		require.EqualValues(t, wire.MaxTxInSequenceNum, txIn.Sequence)
+	}
+
+	// As the lock-time is only backed off some of the time, enough
+	// transactions are created for both cases to be likely covered, with
+	// the last one being signed to ensure its signatures commit to the
+	// lock-time and sequences.
+	w.SetAntiFeeSniping(true)
+	const numTxs = 50
+	for i := 0; i < numTxs; i++ {
+		dryRun := i < numTxs-1
+		tx, err := w.CreateSimpleTx(
+			nil, 0, outputs, 1, 1000, CoinSelectionLargest, dryRun,
+		)
+		require.NoError(t, err)
+
+		require.LessOrEqual(t, tx.Tx.LockTime, uint32(bs.Height))
+		require.GreaterOrEqual(
+			t, tx.Tx.LockTime,
+			uint32(bs.Height-antiFeeSnipingMaxBackOff),
+		)
+		for _, txIn := range tx.Tx.TxIn {
+			require.EqualValues(
+				t, wire.MaxTxInSequenceNum-1, txIn.Sequence,
+			)
+		}
+	}
+}
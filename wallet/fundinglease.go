@@ -0,0 +1,53 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// fundingLease describes the lease acquired on the inputs of a created
+// transaction.
+type fundingLease struct {
+	id       wtxmgr.LockID
+	duration time.Duration
+}
+
+// WithFundingLease leases the inputs selected for the created transaction to
+// the given ID for the given duration, as LeaseOutput would. The leases are
+// persisted within the same database transaction that reserves the index of
+// the change address, so either both or neither are committed: if funding
+// fails, the change index is rolled back along with the leases. Until the
+// leases expire or are released, concurrent sends can't select the same
+// inputs, and as each of them reserves its own change index, no two
+// transactions funded within the window share a change address even if they
+// aren't broadcast. Dry runs don't acquire any lease.
+func WithFundingLease(id wtxmgr.LockID,
+	duration time.Duration) TxCreateOption {
+
+	return func(opts *txCreateOptions) {
+		opts.fundingLease = &fundingLease{id: id, duration: duration}
+	}
+}
+
+// leaseInputs leases the inputs of the transaction according to the lease.
+func (w *Wallet) leaseInputs(ns walletdb.ReadWriteBucket, tx *wire.MsgTx,
+	lease *fundingLease) error {
+
+	for _, txIn := range tx.TxIn {
+		_, err := w.TxStore.LockOutput(
+			ns, lease.id, txIn.PreviousOutPoint, lease.duration,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// internalKeyCount returns the number of change addresses derived for the
+// default account.
+func internalKeyCount(t *testing.T, w *Wallet) uint32 {
+	t.Helper()
+
+	manager, err := w.Manager.FetchScopedKeyManager(
+		waddrmgr.KeyScopeBIP0084,
+	)
+	require.NoError(t, err)
+
+	var props *waddrmgr.AccountProperties
+	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(waddrmgrNamespaceKey)
+		props, err = manager.AccountProperties(ns, 0)
+		return err
+	})
+	require.NoError(t, err)
+
+	return props.InternalKeyCount
+}
+
+// TestFundingLeaseConcurrentSends ensures that concurrent sends leasing their
+// inputs never select the same inputs nor the same change address, and that
+// neither leases nor change indexes are committed if funding fails.
+func TestFundingLeaseConcurrentSends(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	const numSends = 10
+	for i := 0; i < numSends; i++ {
+		fundWallet(t, w, 100000)
+	}
+
+	// Each send requires a single input, leaving change behind.
+	var (
+		wg      sync.WaitGroup
+		leaseID = wtxmgr.LockID{1}
+		txs     = make([]*txauthor.AuthoredTx, numSends)
+		errs    = make([]error, numSends)
+	)
+	outputs := []*wire.TxOut{wire.NewTxOut(50000, testScriptP2WKH)}
+	for i := 0; i < numSends; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			txs[i], errs[i] = w.CreateSimpleTx(
+				nil, 0, outputs, 1, 1000, CoinSelectionLargest,
+				false, WithFundingLease(leaseID, time.Hour),
+			)
+		}(i)
+	}
+	wg.Wait()
+
+	inputs := make(map[wire.OutPoint]struct{})
+	changeScripts := make(map[string]struct{})
+	for i, tx := range txs {
+		require.NoError(t, errs[i])
+		require.Len(t, tx.Tx.TxIn, 1)
+		require.GreaterOrEqual(t, tx.ChangeIndex, 0)
+
+		inputs[tx.Tx.TxIn[0].PreviousOutPoint] = struct{}{}
+		changeScript := tx.Tx.TxOut[tx.ChangeIndex].PkScript
+		changeScripts[string(changeScript)] = struct{}{}
+	}
+	require.Len(t, inputs, numSends)
+	require.Len(t, changeScripts, numSends)
+
+	// All of the inputs should now be leased to the ID.
+	leased, err := w.ListLeasedOutputs()
+	require.NoError(t, err)
+	require.Len(t, leased, numSends)
+	for _, output := range leased {
+		require.Equal(t, leaseID, output.LockID)
+		require.Contains(t, inputs, output.Outpoint)
+	}
+
+	// With all of the outputs leased, funding fails without reserving a
+	// change address.
+	keyCount := internalKeyCount(t, w)
+	_, err = w.CreateSimpleTx(
+		nil, 0, outputs, 1, 1000, CoinSelectionLargest, false,
+		WithFundingLease(leaseID, time.Hour),
+	)
+	require.Error(t, err)
+	require.Equal(t, keyCount, internalKeyCount(t, w))
+
+	// Once a lease is released, its output can be selected again, and a
+	// dry run doesn't lease it nor reserve a change address.
+	var released wire.OutPoint
+	for op := range inputs {
+		released = op
+		break
+	}
+	require.NoError(t, w.ReleaseOutput(leaseID, released))
+
+	tx, err := w.CreateSimpleTx(
+		nil, 0, outputs, 1, 1000, CoinSelectionLargest, true,
+		WithFundingLease(leaseID, time.Hour),
+	)
+	require.NoError(t, err)
+	require.Equal(t, released, tx.Tx.TxIn[0].PreviousOutPoint)
+	require.Equal(t, keyCount, internalKeyCount(t, w))
+
+	leased, err = w.ListLeasedOutputs()
+	require.NoError(t, err)
+	require.Len(t, leased, numSends-1)
+}
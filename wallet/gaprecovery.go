@@ -0,0 +1,269 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// SetGapRecovery sets the wallet to look for funds sent to addresses beyond
+// its gap limit whenever it's synchronized with a chain backend, such as those
+// of a wallet restored with a recovery window too small to find them. The
+// lookAhead addresses following the last derived one of both branches of
+// every account are matched against the scanDepth most recent blocks, with
+// the funds found being recorded and the account's addresses being extended
+// through the last one found to be used. Bounding both the address and block
+// ranges keeps the scan fast. A lookAhead or scanDepth of zero, the default,
+// disables the scan.
+//
+// NOTE: This must be called before the wallet is synchronized with a chain
+// backend.
+func (w *Wallet) SetGapRecovery(lookAhead, scanDepth uint32) {
+	w.gapLookAhead = lookAhead
+	w.gapScanDepth = scanDepth
+}
+
+// recoverGapAddresses scans the most recent blocks, though none before the
+// wallet's birthday block, for activity on the addresses within the gap
+// recovery look-ahead of every account.
+func (w *Wallet) recoverGapAddresses(chainClient chain.Interface,
+	birthdayBlock *waddrmgr.BlockStamp) error {
+
+	_, bestHeight, err := chainClient.GetBestBlock()
+	if err != nil {
+		return err
+	}
+	startHeight := bestHeight - int32(w.gapScanDepth) + 1
+	if birthdayBlock != nil && startHeight < birthdayBlock.Height {
+		startHeight = birthdayBlock.Height
+	}
+	if startHeight < 0 {
+		startHeight = 0
+	}
+
+	var blocks []wtxmgr.BlockMeta
+	for height := startHeight; height <= bestHeight; height++ {
+		hash, err := chainClient.GetBlockHash(int64(height))
+		if err != nil {
+			return err
+		}
+		header, err := chainClient.GetBlockHeader(hash)
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, wtxmgr.BlockMeta{
+			Block: wtxmgr.Block{Hash: *hash, Height: height},
+			Time:  header.Timestamp,
+		})
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	log.Infof("Scanning blocks %d-%d for addresses up to %d past the "+
+		"last derived ones of every account", startHeight, bestHeight,
+		w.gapLookAhead)
+
+	for _, scopedMgr := range w.Manager.ActiveScopedKeyManagers() {
+		var accounts []uint32
+		addAccount := func(account uint32) error {
+			if account != waddrmgr.ImportedAddrAccount {
+				accounts = append(accounts, account)
+			}
+			return nil
+		}
+		err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+			ns := tx.ReadBucket(waddrmgrNamespaceKey)
+			return scopedMgr.ForEachAccount(ns, addAccount)
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, account := range accounts {
+			recoverGap := func(tx walletdb.ReadWriteTx) error {
+				return w.recoverAccountGap(
+					chainClient, tx, scopedMgr, account,
+					blocks,
+				)
+			}
+			err := walletdb.Update(w.db, recoverGap)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// recoverAccountGap matches the blocks against the addresses within the gap
+// recovery look-ahead of the account, recording the relevant transactions
+// found and extending the account's addresses through the last used one.
+func (w *Wallet) recoverAccountGap(chainClient chain.Interface,
+	tx walletdb.ReadWriteTx, scopedMgr *waddrmgr.ScopedKeyManager,
+	account uint32, blocks []wtxmgr.BlockMeta) error {
+
+	ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+	scope := scopedMgr.Scope()
+
+	props, err := scopedMgr.AccountProperties(ns, account)
+	if err != nil {
+		return err
+	}
+
+	// Derive the look-ahead of both branches, following the last address
+	// derived for each, without storing them.
+	externalAddrs := make(map[waddrmgr.ScopedIndex]btcutil.Address)
+	internalAddrs := make(map[waddrmgr.ScopedIndex]btcutil.Address)
+	filterReq := &chain.FilterBlocksRequest{
+		Blocks:           blocks,
+		ExternalAddrs:    externalAddrs,
+		InternalAddrs:    internalAddrs,
+		WatchedOutPoints: make(map[wire.OutPoint]btcutil.Address),
+	}
+	deriveLookAhead := func(branch, nextIndex uint32,
+		addrs map[waddrmgr.ScopedIndex]btcutil.Address) error {
+
+		for i := uint32(0); i < w.gapLookAhead; i++ {
+			index := nextIndex + i
+			addr, err := scopedMgr.DeriveFromKeyPath(
+				ns, waddrmgr.DerivationPath{
+					InternalAccount: account,
+					Account:         props.AccountNumber,
+					Branch:          branch,
+					Index:           index,
+				},
+			)
+			switch {
+			case err == hdkeychain.ErrInvalidChild:
+				continue
+			case err != nil:
+				return err
+			}
+
+			scopedIndex := waddrmgr.ScopedIndex{
+				Scope: scope,
+				Index: index,
+			}
+			addrs[scopedIndex] = addr.Address()
+		}
+		return nil
+	}
+	err = deriveLookAhead(
+		waddrmgr.ExternalBranch, props.ExternalKeyCount, externalAddrs,
+	)
+	if err != nil {
+		return err
+	}
+	err = deriveLookAhead(
+		waddrmgr.InternalBranch, props.InternalKeyCount, internalAddrs,
+	)
+	if err != nil {
+		return err
+	}
+
+	for len(filterReq.Blocks) > 0 {
+		filterResp, err := chainClient.FilterBlocks(filterReq)
+		if err != nil {
+			return err
+		}
+
+		// An empty response signals that none of the remaining blocks
+		// are relevant.
+		if filterResp == nil {
+			return nil
+		}
+		logFilterBlocksResp(filterResp.BlockMeta, filterResp)
+
+		err = extendGapAddresses(
+			ns, scopedMgr, account, filterResp.FoundExternalAddrs,
+			externalAddrs, scopedMgr.ExtendExternalAddresses,
+		)
+		if err != nil {
+			return err
+		}
+		err = extendGapAddresses(
+			ns, scopedMgr, account, filterResp.FoundInternalAddrs,
+			internalAddrs, scopedMgr.ExtendInternalAddresses,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Spends of the outputs found are watched for within the
+		// following blocks.
+		for outPoint, addr := range filterResp.FoundOutPoints {
+			filterReq.WatchedOutPoints[outPoint] = addr
+		}
+
+		for _, txn := range filterResp.RelevantTxns {
+			txRecord, err := wtxmgr.NewTxRecordFromMsgTx(
+				txn, filterResp.BlockMeta.Time,
+			)
+			if err != nil {
+				return err
+			}
+
+			err = w.addRelevantTx(
+				tx, txRecord, &filterResp.BlockMeta,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		filterReq.Blocks = filterReq.Blocks[filterResp.BatchIndex+1:]
+	}
+
+	return nil
+}
+
+// extendGapAddresses extends the addresses of the account's branch through the
+// last one of the given found indexes, marking the found addresses as used.
+func extendGapAddresses(ns walletdb.ReadWriteBucket,
+	scopedMgr *waddrmgr.ScopedKeyManager, account uint32,
+	found map[waddrmgr.KeyScope]map[uint32]struct{},
+	addrs map[waddrmgr.ScopedIndex]btcutil.Address,
+	extend func(walletdb.ReadWriteBucket, uint32, uint32) error) error {
+
+	indexes := found[scopedMgr.Scope()]
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	var lastIndex uint32
+	for index := range indexes {
+		if index > lastIndex {
+			lastIndex = index
+		}
+	}
+	if err := extend(ns, account, lastIndex); err != nil {
+		return err
+	}
+
+	for index := range indexes {
+		scopedIndex := waddrmgr.ScopedIndex{
+			Scope: scopedMgr.Scope(),
+			Index: index,
+		}
+		err := scopedMgr.MarkUsed(ns, addrs[scopedIndex])
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Recovered %d addresses of account %d of key scope %v "+
+		"beyond the gap limit", len(indexes), account,
+		scopedMgr.Scope())
+
+	return nil
+}
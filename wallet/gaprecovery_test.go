@@ -0,0 +1,252 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/stretchr/testify/require"
+)
+
+// filteringChainClient is a mock chain backend whose blocks contain the given
+// transactions, filtering them against the addresses and outpoints requested.
+type filteringChainClient struct {
+	notifyingChainClient
+	*mockChainConn
+
+	params *chaincfg.Params
+	txs    map[int32][]*wire.MsgTx
+}
+
+// FilterBlocks returns the first of the requested blocks containing a
+// transaction paying to any of the requested addresses or spending any of the
+// watched outpoints.
+func (c *filteringChainClient) FilterBlocks(
+	req *chain.FilterBlocksRequest) (*chain.FilterBlocksResponse, error) {
+
+	type branchIndex struct {
+		scopedIndex waddrmgr.ScopedIndex
+		internal    bool
+	}
+	watched := make(map[string]branchIndex)
+	for scopedIndex, addr := range req.ExternalAddrs {
+		watched[addr.EncodeAddress()] = branchIndex{scopedIndex, false}
+	}
+	for scopedIndex, addr := range req.InternalAddrs {
+		watched[addr.EncodeAddress()] = branchIndex{scopedIndex, true}
+	}
+
+	outputAddr := func(pkScript []byte) (btcutil.Address, error) {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			pkScript, c.params,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return addrs[0], nil
+	}
+
+	for i, block := range req.Blocks {
+		foundExternal := make(map[waddrmgr.KeyScope]map[uint32]struct{})
+		foundInternal := make(map[waddrmgr.KeyScope]map[uint32]struct{})
+		found := func(index branchIndex) {
+			found := foundExternal
+			if index.internal {
+				found = foundInternal
+			}
+
+			scope := index.scopedIndex.Scope
+			if found[scope] == nil {
+				found[scope] = make(map[uint32]struct{})
+			}
+			found[scope][index.scopedIndex.Index] = struct{}{}
+		}
+
+		resp := &chain.FilterBlocksResponse{
+			BatchIndex:         uint32(i),
+			BlockMeta:          block,
+			FoundExternalAddrs: foundExternal,
+			FoundInternalAddrs: foundInternal,
+			FoundOutPoints: make(
+				map[wire.OutPoint]btcutil.Address,
+			),
+		}
+		for _, tx := range c.txs[block.Height] {
+			relevant := false
+			for _, txIn := range tx.TxIn {
+				prevOut := txIn.PreviousOutPoint
+				_, ok := req.WatchedOutPoints[prevOut]
+				relevant = relevant || ok
+			}
+			for j, txOut := range tx.TxOut {
+				addr, err := outputAddr(txOut.PkScript)
+				if err != nil {
+					return nil, err
+				}
+				index, ok := watched[addr.EncodeAddress()]
+				if !ok {
+					continue
+				}
+				found(index)
+
+				outPoint := wire.OutPoint{
+					Hash:  tx.TxHash(),
+					Index: uint32(j),
+				}
+				resp.FoundOutPoints[outPoint] = addr
+				relevant = true
+			}
+
+			if relevant {
+				resp.RelevantTxns = append(
+					resp.RelevantTxns, tx,
+				)
+			}
+		}
+
+		if len(resp.RelevantTxns) > 0 {
+			return resp, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// TestRecoverGapAddresses ensures that the gap recovery finds the funds sent
+// to addresses beyond the last derived ones of an account within its
+// look-ahead and the most recent blocks, extending the account's addresses
+// through the last one used, while ignoring those outside of either range.
+func TestRecoverGapAddresses(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	const (
+		lookAhead = 30
+		scanDepth = 5
+	)
+	w.SetGapRecovery(lookAhead, scanDepth)
+
+	scope := waddrmgr.KeyScopeBIP0084
+	scopedMgr, err := w.Manager.FetchScopedKeyManager(scope)
+	require.NoError(t, err)
+	props, err := w.AccountProperties(scope, 0)
+	require.NoError(t, err)
+
+	// deriveAddr derives the address of the account's branch at the given
+	// offset from the last derived one, without storing it.
+	deriveAddr := func(branch, offset uint32) (btcutil.Address, []byte) {
+		t.Helper()
+
+		index := props.ExternalKeyCount + offset
+		if branch == waddrmgr.InternalBranch {
+			index = props.InternalKeyCount + offset
+		}
+
+		var addr btcutil.Address
+		err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+			ns := tx.ReadBucket(waddrmgrNamespaceKey)
+			managedAddr, err := scopedMgr.DeriveFromKeyPath(
+				ns, waddrmgr.DerivationPath{
+					Branch: branch,
+					Index:  index,
+				},
+			)
+			if err != nil {
+				return err
+			}
+			addr = managedAddr.Address()
+			return nil
+		})
+		require.NoError(t, err)
+
+		pkScript, err := txscript.PayToAddrScript(addr)
+		require.NoError(t, err)
+		return addr, pkScript
+	}
+	newTx := func(prevOut wire.OutPoint, pkScript []byte) *wire.MsgTx {
+		tx := wire.NewMsgTx(2)
+		tx.AddTxIn(&wire.TxIn{PreviousOutPoint: prevOut})
+		tx.AddTxOut(wire.NewTxOut(100000, pkScript))
+		return tx
+	}
+
+	// Funds are sent beyond the gap on both branches within the scanned
+	// blocks, with the external ones being spent later on. Funds sent past
+	// the look-ahead, or before the scanned blocks, aren't found.
+	externalAddr, externalScript := deriveAddr(waddrmgr.ExternalBranch, 25)
+	internalAddr, internalScript := deriveAddr(waddrmgr.InternalBranch, 10)
+	_, pastScript := deriveAddr(waddrmgr.ExternalBranch, 40)
+	_, unscannedScript := deriveAddr(waddrmgr.ExternalBranch, 5)
+
+	externalTx := newTx(
+		wire.OutPoint{Hash: chainhash.Hash{1}}, externalScript,
+	)
+	internalTx := newTx(
+		wire.OutPoint{Hash: chainhash.Hash{2}}, internalScript,
+	)
+	spendTx := newTx(
+		wire.OutPoint{Hash: externalTx.TxHash()}, testScriptP2WKH,
+	)
+	chainClient := &filteringChainClient{
+		mockChainConn: createMockChainConn(
+			chaincfg.TestNet3Params.GenesisBlock, 10,
+			defaultBlockInterval,
+		),
+		params: w.chainParams,
+		txs: map[int32][]*wire.MsgTx{
+			3:  {newTx(wire.OutPoint{Index: 3}, unscannedScript)},
+			7:  {internalTx},
+			8:  {externalTx},
+			9:  {newTx(wire.OutPoint{Index: 9}, pastScript)},
+			10: {spendTx},
+		},
+	}
+
+	require.NoError(t, w.recoverGapAddresses(chainClient, nil))
+
+	// The account's addresses were extended through the ones found, which
+	// were marked as used.
+	recovered, err := w.AccountProperties(scope, 0)
+	require.NoError(t, err)
+	require.Equal(t, props.ExternalKeyCount+26, recovered.ExternalKeyCount)
+	require.Equal(t, props.InternalKeyCount+11, recovered.InternalKeyCount)
+
+	for _, addr := range []btcutil.Address{externalAddr, internalAddr} {
+		info, err := w.AddressInfo(addr)
+		require.NoError(t, err)
+		require.True(t, info.Used)
+	}
+
+	// The transactions found were recorded, including the spend of the
+	// external output, leaving only the internal one unspent.
+	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(wtxmgrNamespaceKey)
+
+		recorded := []*wire.MsgTx{externalTx, internalTx, spendTx}
+		for _, txn := range recorded {
+			txHash := txn.TxHash()
+			details, err := w.TxStore.TxDetails(ns, &txHash)
+			require.NoError(t, err)
+			require.NotNil(t, details)
+		}
+
+		unspent, err := w.TxStore.UnspentOutputs(ns)
+		require.NoError(t, err)
+		require.Len(t, unspent, 1)
+		require.Equal(t, internalTx.TxHash(), unspent[0].Hash)
+		return nil
+	})
+	require.NoError(t, err)
+}
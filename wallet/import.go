@@ -23,6 +23,39 @@ const (
 	pubKeyDepth = 5
 )
 
+// ErrAddressAlreadyImported is returned when importing a key or address that
+// is already known to the wallet, without requesting to overwrite it through
+// WithImportOverwrite.
+var ErrAddressAlreadyImported = errors.New("address already imported")
+
+// ImportOption is a functional option that changes how a key is imported.
+type ImportOption func(*importOptions)
+
+// importOptions holds the options set through ImportOption.
+type importOptions struct {
+	overwrite bool
+}
+
+// WithImportOverwrite replaces the key of an address that was already
+// imported into the wallet, rather than failing with ErrAddressAlreadyImported,
+// e.g. to make an address imported through its public key spendable. The time
+// the address was added, the wallet's birthday and the rescan state of the
+// address are left untouched, as the address is already tracked.
+func WithImportOverwrite() ImportOption {
+	return func(opts *importOptions) {
+		opts.overwrite = true
+	}
+}
+
+// alreadyImportedError converts the duplicate address errors of the address
+// manager into ErrAddressAlreadyImported.
+func alreadyImportedError(err error) error {
+	if waddrmgr.IsError(err, waddrmgr.ErrDuplicateAddress) {
+		return fmt.Errorf("%w: %v", ErrAddressAlreadyImported, err)
+	}
+	return err
+}
+
 // keyScopeFromPubKey returns the corresponding wallet key scope for the given
 // extended public key. The address type can usually be inferred from the key's
 // version, but may be required for certain keys to map them into the proper
@@ -362,8 +395,16 @@ func (w *Wallet) ImportAccountDryRun(name string,
 // case of legacy versions (xpub, tpub), an address type must be specified as we
 // intend to not support importing BIP-44 keys into the wallet using the legacy
 // pay-to-pubkey-hash (P2PKH) scheme.
+//
+// If the address of the key is already known to the wallet,
+// ErrAddressAlreadyImported is returned, unless WithImportOverwrite is given.
 func (w *Wallet) ImportPublicKey(pubKey *btcec.PublicKey,
-	addrType waddrmgr.AddressType) error {
+	addrType waddrmgr.AddressType, opts ...ImportOption) error {
+
+	var options importOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
 
 	// Determine what key scope the public key should belong to and import
 	// it into the key scope's default imported account.
@@ -383,16 +424,35 @@ func (w *Wallet) ImportPublicKey(pubKey *btcec.PublicKey,
 	}
 
 	// TODO: Perform rescan if requested.
-	var addr waddrmgr.ManagedAddress
+	var (
+		addr       waddrmgr.ManagedAddress
+		reimported bool
+	)
 	err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
 		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
 		addr, err = scopedKeyManager.ImportPublicKey(ns, pubKey, nil)
-		return err
+		if !waddrmgr.IsError(err, waddrmgr.ErrDuplicateAddress) ||
+			!options.overwrite {
+
+			return alreadyImportedError(err)
+		}
+
+		reimported = true
+		addr, err = scopedKeyManager.ReplaceImportedPublicKey(
+			ns, pubKey,
+		)
+		return alreadyImportedError(err)
 	})
 	if err != nil {
 		return err
 	}
 
+	// The address of a replaced key is already being watched.
+	if reimported {
+		log.Infof("Replaced key of imported address %v", addr.Address())
+		return nil
+	}
+
 	log.Infof("Imported address %v", addr.Address())
 
 	err = w.chainClient.NotifyReceived([]btcutil.Address{addr.Address()})
@@ -407,10 +467,21 @@ func (w *Wallet) ImportPublicKey(pubKey *btcec.PublicKey,
 // ImportPrivateKey imports a private key to the wallet and writes the new
 // wallet to disk.
 //
+// If the address of the key is already known to the wallet,
+// ErrAddressAlreadyImported is returned, unless WithImportOverwrite is given,
+// in which case neither the wallet's birthday is updated nor a rescan is
+// performed.
+//
 // NOTE: If a block stamp is not provided, then the wallet's birthday will be
 // set to the genesis block of the corresponding chain.
 func (w *Wallet) ImportPrivateKey(scope waddrmgr.KeyScope, wif *btcutil.WIF,
-	bs *waddrmgr.BlockStamp, rescan bool) (string, error) {
+	bs *waddrmgr.BlockStamp, rescan bool, opts ...ImportOption) (string,
+	error) {
+
+	var options importOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
 
 	manager, err := w.Manager.FetchScopedKeyManager(scope)
 	if err != nil {
@@ -435,13 +506,24 @@ func (w *Wallet) ImportPrivateKey(scope waddrmgr.KeyScope, wif *btcutil.WIF,
 	}
 
 	// Attempt to import private key into wallet.
-	var addr btcutil.Address
-	var props *waddrmgr.AccountProperties
+	var (
+		addr       btcutil.Address
+		props      *waddrmgr.AccountProperties
+		reimported bool
+	)
 	err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
 		addrmgrNs := tx.ReadWriteBucket(waddrmgrNamespaceKey)
 		maddr, err := manager.ImportPrivateKey(addrmgrNs, wif, bs)
+		if waddrmgr.IsError(err, waddrmgr.ErrDuplicateAddress) &&
+			options.overwrite {
+
+			reimported = true
+			maddr, err = manager.ReplaceImportedPrivateKey(
+				addrmgrNs, wif,
+			)
+		}
 		if err != nil {
-			return err
+			return alreadyImportedError(err)
 		}
 		addr = maddr.Address()
 		props, err = manager.AccountProperties(
@@ -451,6 +533,11 @@ func (w *Wallet) ImportPrivateKey(scope waddrmgr.KeyScope, wif *btcutil.WIF,
 			return err
 		}
 
+		// The birthday already accounts for a key being replaced.
+		if reimported {
+			return nil
+		}
+
 		// We'll only update our birthday with the new one if it is
 		// before our current one. Otherwise, if we do, we can
 		// potentially miss detecting relevant chain events that
@@ -478,8 +565,17 @@ func (w *Wallet) ImportPrivateKey(scope waddrmgr.KeyScope, wif *btcutil.WIF,
 	}
 
 	// Rescan blockchain for transactions with txout scripts paying to the
-	// imported address.
-	if rescan {
+	// imported address. The address of a replaced key is already being
+	// watched, and has been rescanned for if needed.
+	switch {
+	case reimported:
+		addrStr := addr.EncodeAddress()
+		log.Infof("Replaced key of imported payment address %s",
+			addrStr)
+
+		return addrStr, nil
+
+	case rescan:
 		job := &RescanJob{
 			Addrs:      []btcutil.Address{addr},
 			OutPoints:  nil,
@@ -491,7 +587,8 @@ func (w *Wallet) ImportPrivateKey(scope waddrmgr.KeyScope, wif *btcutil.WIF,
 		// or failure is logged elsewhere, and the channel is not
 		// required to be read, so discard the return value.
 		_ = w.SubmitRescan(job)
-	} else {
+
+	default:
 		err := w.chainClient.NotifyReceived([]btcutil.Address{addr})
 		if err != nil {
 			return "", fmt.Errorf("failed to subscribe for address ntfns for "+
@@ -2,9 +2,11 @@ package wallet
 
 import (
 	"encoding/binary"
+	"errors"
 	"strings"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcutil"
@@ -285,7 +287,115 @@ func testImportAccount(t *testing.T, w *Wallet, tc *testCase, watchOnly bool,
 		t.Fatalf("unhandled address type %v", tc.addrType)
 	}
 
-	addrManaged, err := w.AddressInfo(intAddr)
+	addrInfo, err := w.AddressInfo(intAddr)
 	require.NoError(t, err)
-	require.Equal(t, true, addrManaged.Imported())
+	require.Equal(t, true, addrInfo.Imported)
+	require.Equal(t, true, addrInfo.Address.Imported())
+	require.False(t, addrInfo.OriginKnown)
+}
+
+// TestImportDuplicateKey ensures that importing a key whose address is already
+// known fails with ErrAddressAlreadyImported, unless overwriting is requested,
+// in which case the key is replaced without moving the wallet's birthday.
+func TestImportDuplicateKey(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	wif, err := btcutil.NewWIF(privKey, &chaincfg.TestNet3Params, true)
+	require.NoError(t, err)
+	pubKeyHash := btcutil.Hash160(wif.SerializePubKey())
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		pubKeyHash, &chaincfg.TestNet3Params,
+	)
+	require.NoError(t, err)
+
+	// hasPrivKey returns whether the wallet knows the private key of the
+	// imported address.
+	hasPrivKey := func() bool {
+		t.Helper()
+
+		info, err := w.AddressInfo(addr)
+		require.NoError(t, err)
+		require.True(t, info.Imported)
+
+		_, err = info.Address.(waddrmgr.ManagedPubKeyAddress).PrivKey()
+		return err == nil
+	}
+
+	// The address is first imported through its public key alone, which
+	// can't be imported again.
+	pubKey := privKey.PubKey()
+	require.NoError(t, w.ImportPublicKey(pubKey, waddrmgr.WitnessPubKey))
+	err = w.ImportPublicKey(pubKey, waddrmgr.WitnessPubKey)
+	require.True(t, errors.Is(err, ErrAddressAlreadyImported), err)
+	require.False(t, hasPrivKey())
+
+	// Nor can its private key be imported without overwriting it, which
+	// leaves the address untouched.
+	birthday := w.Manager.Birthday()
+	genesis := &waddrmgr.BlockStamp{
+		Hash:      *w.chainParams.GenesisHash,
+		Timestamp: w.chainParams.GenesisBlock.Header.Timestamp,
+	}
+	_, err = w.ImportPrivateKey(
+		waddrmgr.KeyScopeBIP0084, wif, genesis, true,
+	)
+	require.True(t, errors.Is(err, ErrAddressAlreadyImported), err)
+	require.False(t, hasPrivKey())
+
+	// Overwriting the key makes the address spendable, without moving
+	// the birthday back to the one given for the key.
+	addrStr, err := w.ImportPrivateKey(
+		waddrmgr.KeyScopeBIP0084, wif, genesis, true,
+		WithImportOverwrite(),
+	)
+	require.NoError(t, err)
+	require.Equal(t, addr.EncodeAddress(), addrStr)
+	require.True(t, hasPrivKey())
+	require.Equal(t, birthday, w.Manager.Birthday())
+
+	// Overwriting it with its public key again keeps its private key.
+	err = w.ImportPublicKey(
+		pubKey, waddrmgr.WitnessPubKey, WithImportOverwrite(),
+	)
+	require.NoError(t, err)
+	require.True(t, hasPrivKey())
+
+	// Addresses derived by the wallet can't be overwritten.
+	derived, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	info, err := w.AddressInfo(derived)
+	require.NoError(t, err)
+	derivedKey := info.Address.(waddrmgr.ManagedPubKeyAddress).PubKey()
+	err = w.ImportPublicKey(
+		derivedKey, waddrmgr.WitnessPubKey, WithImportOverwrite(),
+	)
+	require.True(t, errors.Is(err, ErrAddressAlreadyImported), err)
+
+	// Nor can a redeem script be imported twice, though its address is
+	// still returned.
+	script, err := txscript.MultiSigScript(
+		[]*btcutil.AddressPubKey{pubKeyAddr(t, pubKey)}, 1,
+	)
+	require.NoError(t, err)
+	scriptAddr, err := w.ImportP2SHRedeemScript(script)
+	require.NoError(t, err)
+	dupAddr, err := w.ImportP2SHRedeemScript(script)
+	require.True(t, errors.Is(err, ErrAddressAlreadyImported), err)
+	require.Equal(t, scriptAddr, dupAddr)
+}
+
+// pubKeyAddr returns the pay-to-pubkey address of the public key.
+func pubKeyAddr(t *testing.T, pubKey *btcec.PublicKey) *btcutil.AddressPubKey {
+	t.Helper()
+
+	addr, err := btcutil.NewAddressPubKey(
+		pubKey.SerializeCompressed(), &chaincfg.TestNet3Params,
+	)
+	require.NoError(t, err)
+	return addr
 }
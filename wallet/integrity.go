@@ -0,0 +1,278 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// IntegrityReport describes the inconsistencies found within the wallet's
+// local state by VerifyIntegrity.
+type IntegrityReport struct {
+	// Anomalies are the inconsistencies found between the records of the
+	// transaction store.
+	Anomalies []wtxmgr.Anomaly
+
+	// UnownedCredits are the credits of the transaction store paying to
+	// scripts which are not owned by the address manager.
+	UnownedCredits []wire.OutPoint
+}
+
+// OK returns whether no inconsistencies were found.
+func (r *IntegrityReport) OK() bool {
+	return len(r.Anomalies) == 0 && len(r.UnownedCredits) == 0
+}
+
+// VerifyIntegrity cross-checks the wallet's local state within the given
+// database transaction and reports any inconsistencies found, without
+// modifying it.  The records of the transaction store are checked against
+// each other, and every credit, mined or unmined, is checked to pay to a
+// script owned by the address manager.  This is meant as a diagnostic for
+// suspected corruption, as none of the balances reported by the wallet can be
+// trusted if any inconsistencies are found.
+func (w *Wallet) VerifyIntegrity(dbtx walletdb.ReadTx) (*IntegrityReport,
+	error) {
+
+	addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+	txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+	anomalies, err := w.TxStore.VerifyIntegrity(txmgrNs)
+	if err != nil {
+		return nil, err
+	}
+	report := &IntegrityReport{Anomalies: anomalies}
+
+	collectUnowned := func(details []wtxmgr.TxDetails) (bool, error) {
+		for i := range details {
+			detail := &details[i]
+			numOutputs := len(detail.MsgTx.TxOut)
+			for _, cred := range detail.Credits {
+				// The store rejects credits referencing
+				// outputs their transaction doesn't have, but
+				// we're diagnosing corruption, so we'll make
+				// sure not to panic on them.
+				if int(cred.Index) >= numOutputs {
+					return false, fmt.Errorf("credit "+
+						"%v:%d exceeds the %d outputs "+
+						"of its transaction",
+						detail.Hash, cred.Index,
+						numOutputs)
+				}
+
+				txOut := detail.MsgTx.TxOut[cred.Index]
+				owned, err := w.ownsScript(addrmgrNs, txOut.PkScript)
+				if err != nil {
+					return false, err
+				}
+				if owned {
+					continue
+				}
+
+				report.UnownedCredits = append(
+					report.UnownedCredits, wire.OutPoint{
+						Hash:  detail.Hash,
+						Index: cred.Index,
+					},
+				)
+			}
+		}
+		return false, nil
+	}
+	err = w.TxStore.RangeTransactions(txmgrNs, 0, -1, collectUnowned)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// ownsScript returns whether any of the addresses the output script pays to is
+// owned by the address manager.
+func (w *Wallet) ownsScript(addrmgrNs walletdb.ReadBucket,
+	pkScript []byte) (bool, error) {
+
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+		pkScript, w.chainParams,
+	)
+	if err != nil {
+		// Non-standard scripts can't be owned by the address manager.
+		return false, nil
+	}
+	for _, addr := range addrs {
+		_, err := w.Manager.Address(addrmgrNs, addr)
+		switch {
+		case err == nil:
+			return true, nil
+
+		case waddrmgr.IsError(err, waddrmgr.ErrAddressNotFound):
+			continue
+
+		default:
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// AccountXpub is the extended public key of an account, from which its
+// addresses are re-derived by RepairAddresses.
+type AccountXpub struct {
+	// KeyScope is the key scope of the account.
+	KeyScope waddrmgr.KeyScope
+
+	// Account is the account number.
+	Account uint32
+
+	// Xpub is the account's extended public key.
+	Xpub *hdkeychain.ExtendedKey
+}
+
+// RepairedAddress is an address missing from the address manager, restored or
+// to be restored by RepairAddresses.
+type RepairedAddress struct {
+	// KeyScope is the key scope of the account of the address.
+	KeyScope waddrmgr.KeyScope
+
+	// Account is the account of the address.
+	Account uint32
+
+	// Branch is the branch of the account the address derives from.
+	Branch uint32
+
+	// Index is the index of the address within its branch.
+	Index uint32
+
+	// Address is the address itself.
+	Address btcutil.Address
+}
+
+// RepairAddresses restores the addresses missing from the address manager that
+// are paid to by credits of the transaction store, such as after a partial
+// write, which otherwise can't be attributed to the wallet. The addresses of
+// each account given are re-derived from its extended public key up to the
+// highest index paid to by a credit not owned by the address manager, mined or
+// unmined, and every missing address up to it is restored. The addresses going
+// past the last derived ones of their branch extend it. The missing addresses
+// are returned, and are only restored if dryRun isn't set, such that the
+// repair can first be reviewed. If the chain backend fails to watch the
+// restored addresses, they're returned along with the error, as they remain
+// restored.
+func (w *Wallet) RepairAddresses(accounts []AccountXpub,
+	dryRun bool) ([]RepairedAddress, error) {
+
+	var repaired []RepairedAddress
+	err := walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		addrmgrNs := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		// The address IDs the unowned credits pay to are the ones the
+		// missing addresses are found by.
+		referenced := make(map[string]struct{})
+		collect := func(details []wtxmgr.TxDetails) (bool, error) {
+			for i := range details {
+				detail := &details[i]
+				for _, cred := range detail.Credits {
+					txOut := detail.MsgTx.TxOut[cred.Index]
+					err := w.referenceUnownedScript(
+						addrmgrNs, txOut.PkScript,
+						referenced,
+					)
+					if err != nil {
+						return false, err
+					}
+				}
+			}
+			return false, nil
+		}
+		err := w.TxStore.RangeTransactions(
+			txmgrNs, 0, -1, collect,
+		)
+		if err != nil {
+			return err
+		}
+		if len(referenced) == 0 {
+			return nil
+		}
+
+		for _, acct := range accounts {
+			missing, err := w.Manager.RestoreAccountAddresses(
+				addrmgrNs, acct.KeyScope, acct.Account,
+				acct.Xpub, referenced, dryRun,
+			)
+			if err != nil {
+				return err
+			}
+
+			for _, addr := range missing {
+				repaired = append(repaired, RepairedAddress{
+					KeyScope: acct.KeyScope,
+					Account:  acct.Account,
+					Branch:   addr.Branch,
+					Index:    addr.Index,
+					Address:  addr.Address,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The backend is asked to notify us of the payments to the restored
+	// addresses from now on, if we're synchronized with one.
+	if !dryRun && len(repaired) > 0 {
+		chainClient, done, err := w.useChainClient()
+		if err != nil {
+			return repaired, nil
+		}
+		defer done()
+
+		addrs := make([]btcutil.Address, 0, len(repaired))
+		for _, addr := range repaired {
+			addrs = append(addrs, addr.Address)
+		}
+		if err := chainClient.NotifyReceived(addrs); err != nil {
+			return repaired, fmt.Errorf("unable to subscribe for "+
+				"address notifications: %w", err)
+		}
+	}
+
+	return repaired, nil
+}
+
+// referenceUnownedScript adds the IDs of the addresses the output script pays
+// to to the referenced ones, keyed by their string conversion, if none of them
+// is owned by the address manager.
+func (w *Wallet) referenceUnownedScript(addrmgrNs walletdb.ReadBucket,
+	pkScript []byte, referenced map[string]struct{}) error {
+
+	owned, err := w.ownsScript(addrmgrNs, pkScript)
+	if err != nil || owned {
+		return err
+	}
+
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+		pkScript, w.chainParams,
+	)
+	if err != nil {
+		// Non-standard scripts can't be paid to by derived addresses.
+		return nil
+	}
+	for _, addr := range addrs {
+		referenced[string(addr.ScriptAddress())] = struct{}{}
+	}
+
+	return nil
+}
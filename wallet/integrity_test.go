@@ -0,0 +1,295 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/stretchr/testify/require"
+)
+
+// TestVerifyIntegrity ensures that credits paying to scripts not owned by the
+// address manager are reported by VerifyIntegrity, and that credits
+// referencing outputs their transaction doesn't have fail it.
+func TestVerifyIntegrity(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	foreignAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), w.chainParams,
+	)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	foreignPkScript, err := txscript.PayToAddrScript(foreignAddr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+
+	verify := func() *IntegrityReport {
+		t.Helper()
+
+		var report *IntegrityReport
+		err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+			var err error
+			report, err = w.VerifyIntegrity(tx)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("unable to verify integrity: %v", err)
+		}
+		if len(report.Anomalies) != 0 {
+			t.Fatalf("expected no anomalies, got %v",
+				report.Anomalies)
+		}
+		return report
+	}
+
+	// A wallet with a credit paying to one of its addresses should be
+	// consistent.
+	incomingTx := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{
+			wire.NewTxOut(100000, pkScript),
+		},
+	}
+	addUtxo(t, w, incomingTx)
+	if report := verify(); !report.OK() {
+		t.Fatalf("expected consistent wallet, got %v", report)
+	}
+
+	// A credit paying to a script that's not owned by the address manager
+	// should be reported.
+	foreignTx := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{
+			wire.NewTxOut(100000, pkScript),
+			wire.NewTxOut(200000, foreignPkScript),
+		},
+	}
+	addUtxo(t, w, foreignTx)
+
+	report := verify()
+	if report.OK() {
+		t.Fatalf("expected unowned credit to be reported")
+	}
+	expected := wire.OutPoint{Hash: foreignTx.TxHash(), Index: 1}
+	if len(report.UnownedCredits) != 1 ||
+		report.UnownedCredits[0] != expected {
+
+		t.Fatalf("expected unowned credit %v, got %v", expected,
+			report.UnownedCredits)
+	}
+
+	// A credit referencing an output its transaction doesn't have, as
+	// the transaction record was corrupted, should result in an error
+	// rather than a panic.
+	unminedTx := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: 1},
+		}},
+		TxOut: []*wire.TxOut{
+			wire.NewTxOut(100000, foreignPkScript),
+			wire.NewTxOut(200000, pkScript),
+		},
+	}
+	addUnminedTx(t, w, unminedTx, 1)
+
+	truncatedTx := unminedTx.Copy()
+	truncatedTx.TxOut = truncatedTx.TxOut[:1]
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 8))
+	if err := truncatedTx.Serialize(&buf); err != nil {
+		t.Fatalf("unable to serialize transaction: %v", err)
+	}
+	err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		unminedHash := unminedTx.TxHash()
+		return ns.NestedReadWriteBucket([]byte("m")).Put(
+			unminedHash[:], buf.Bytes(),
+		)
+	})
+	if err != nil {
+		t.Fatalf("unable to corrupt transaction record: %v", err)
+	}
+
+	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		_, err := w.VerifyIntegrity(tx)
+		return err
+	})
+	if err == nil {
+		t.Fatalf("expected corrupted credit to be reported")
+	}
+}
+
+// errNotifyReceived is the error returned by failingNotifyChainClient.
+var errNotifyReceived = errors.New("unable to watch addresses")
+
+// failingNotifyChainClient is a mock chain client failing to watch addresses.
+type failingNotifyChainClient struct {
+	mockChainClient
+}
+
+func (c *failingNotifyChainClient) NotifyReceived([]btcutil.Address) error {
+	return errNotifyReceived
+}
+
+// TestRepairAddresses ensures that the addresses missing from the address
+// manager that are paid to by credits are restored from the account's extended
+// public key, only once the dry run reporting them has been reviewed, with
+// those past the last derived ones extending their branch.
+func TestRepairAddresses(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	scope := waddrmgr.KeyScopeBIP0084
+	var addrs []btcutil.Address
+	for i := 0; i < 3; i++ {
+		addr, err := w.NewAddress(0, scope)
+		require.NoError(t, err)
+		addrs = append(addrs, addr)
+	}
+	props, err := w.AccountProperties(scope, 0)
+	require.NoError(t, err)
+	lostAddr := addrs[1]
+	lostIndex := props.ExternalKeyCount - 2
+
+	// An address past the last derived one, within the gap limit, is paid
+	// to as well.
+	scopedMgr, err := w.Manager.FetchScopedKeyManager(scope)
+	require.NoError(t, err)
+	var gapAddr btcutil.Address
+	gapIndex := props.ExternalKeyCount + 5
+	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(waddrmgrNamespaceKey)
+		managedAddr, err := scopedMgr.DeriveFromKeyPath(
+			ns, waddrmgr.DerivationPath{
+				Branch: waddrmgr.ExternalBranch,
+				Index:  gapIndex,
+			},
+		)
+		if err != nil {
+			return err
+		}
+		gapAddr = managedAddr.Address()
+		return nil
+	})
+	require.NoError(t, err)
+
+	for _, addr := range []btcutil.Address{lostAddr, gapAddr} {
+		pkScript, err := txscript.PayToAddrScript(addr)
+		require.NoError(t, err)
+		addUtxo(t, w, &wire.MsgTx{
+			TxIn:  []*wire.TxIn{{}},
+			TxOut: []*wire.TxOut{wire.NewTxOut(100000, pkScript)},
+		})
+	}
+
+	// The record of the address paid to is lost, as if only partially
+	// written, leaving its credit unowned once the address manager, which
+	// caches the addresses it has derived, is opened again.
+	err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+
+		var scopeKey [8]byte
+		binary.LittleEndian.PutUint32(scopeKey[:], scope.Purpose)
+		binary.LittleEndian.PutUint32(scopeKey[4:], scope.Coin)
+		addrBucket := ns.NestedReadWriteBucket([]byte("scope")).
+			NestedReadWriteBucket(scopeKey[:]).
+			NestedReadWriteBucket([]byte("addr"))
+
+		addrHash := sha256.Sum256(lostAddr.ScriptAddress())
+		if err := addrBucket.Delete(addrHash[:]); err != nil {
+			return err
+		}
+
+		w.Manager.Close()
+		w.Manager, err = waddrmgr.Open(
+			ns, []byte("hello"), w.chainParams,
+		)
+		return err
+	})
+	require.NoError(t, err)
+
+	numUnowned := func() int {
+		t.Helper()
+
+		var report *IntegrityReport
+		err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+			var err error
+			report, err = w.VerifyIntegrity(tx)
+			return err
+		})
+		require.NoError(t, err)
+		return len(report.UnownedCredits)
+	}
+	require.Equal(t, 2, numUnowned())
+
+	// The dry run reports every missing address up to the last one paid
+	// to, without restoring them.
+	reopenedProps, err := w.AccountProperties(scope, 0)
+	require.NoError(t, err)
+	accounts := []AccountXpub{{
+		KeyScope: scope,
+		Account:  0,
+		Xpub:     reopenedProps.AccountPubKey,
+	}}
+	repaired, err := w.RepairAddresses(accounts, true)
+	require.NoError(t, err)
+	require.Len(t, repaired, 7)
+	require.Equal(t, lostIndex, repaired[0].Index)
+	require.Equal(t, lostAddr.String(), repaired[0].Address.String())
+	for i, addr := range repaired[1:] {
+		require.Equal(t, props.ExternalKeyCount+uint32(i), addr.Index)
+		require.Equal(t, waddrmgr.ExternalBranch, addr.Branch)
+	}
+	require.Equal(t, gapAddr.String(), repaired[6].Address.String())
+	require.Equal(t, 2, numUnowned())
+
+	// Once repaired, the credits are owned again, and the external branch
+	// extends through the address past the last derived one. The repaired
+	// addresses are returned even if the backend fails to watch them.
+	w.chainClient = &failingNotifyChainClient{}
+	dryRunRepaired := repaired
+	repaired, err = w.RepairAddresses(accounts, false)
+	require.True(t, errors.Is(err, errNotifyReceived))
+	require.Equal(t, dryRunRepaired, repaired)
+	require.Zero(t, numUnowned())
+
+	info, err := w.AddressInfo(lostAddr)
+	require.NoError(t, err)
+	require.Equal(t, lostIndex, info.DerivationPath.Index)
+
+	repairedProps, err := w.AccountProperties(scope, 0)
+	require.NoError(t, err)
+	require.Equal(t, gapIndex+1, repairedProps.ExternalKeyCount)
+	require.Equal(t, props.InternalKeyCount, repairedProps.InternalKeyCount)
+
+	// Nothing is left to repair.
+	repaired, err = w.RepairAddresses(accounts, false)
+	require.NoError(t, err)
+	require.Empty(t, repaired)
+}
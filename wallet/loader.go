@@ -60,6 +60,7 @@ type Loader struct {
 	localDB        bool
 	walletExists   func() (bool, error)
 	walletCreated  func(db walletdb.ReadWriteTx) error
+	defaultScopes  []waddrmgr.KeyScope
 	db             walletdb.DB
 	mu             sync.Mutex
 }
@@ -140,6 +141,15 @@ func (l *Loader) OnWalletCreated(fn func(walletdb.ReadWriteTx) error) {
 	l.walletCreated = fn
 }
 
+// SetDefaultScopes sets the key scopes that will be created, along with their
+// default account, for new non watching-only wallets. If never called, or
+// called with no scopes, the scopes of waddrmgr.DefaultKeyScopes are created.
+func (l *Loader) SetDefaultScopes(scopes ...waddrmgr.KeyScope) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.defaultScopes = scopes
+}
+
 // CreateNewWallet creates a new wallet using the provided public and private
 // passphrases.  The seed is optional.  If non-nil, addresses are derived from
 // this seed.  If nil, a secure random seed is generated.
@@ -241,9 +251,13 @@ func (l *Loader) createNewWallet(pubPassphrase, privPassphrase []byte,
 			return nil, err
 		}
 	} else {
-		err := CreateWithCallback(
+		scopes := l.defaultScopes
+		if len(scopes) == 0 {
+			scopes = waddrmgr.DefaultKeyScopes
+		}
+		err := create(
 			l.db, pubPassphrase, privPassphrase, rootKey,
-			l.chainParams, bday, l.walletCreated,
+			l.chainParams, bday, false, scopes, l.walletCreated,
 		)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,65 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+)
+
+// TestLoaderDefaultScopes ensures that a wallet created with a set of default
+// scopes can derive addresses from the default account of each of them right
+// away, and that no other scopes are created.
+//
+// NOTE: The taproot scope isn't known to this version of the address manager,
+// so the legacy and native segwit scopes are used instead.
+func TestLoaderDefaultScopes(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "loader_test")
+	if err != nil {
+		t.Fatalf("Failed to create db dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	scopes := []waddrmgr.KeyScope{
+		waddrmgr.KeyScopeBIP0044, waddrmgr.KeyScopeBIP0084,
+	}
+
+	loader := NewLoader(
+		&chaincfg.TestNet3Params, dir, true, defaultDBTimeout, 250,
+	)
+	loader.SetDefaultScopes(scopes...)
+	w, err := loader.CreateNewWallet(
+		[]byte("hello"), []byte("world"), nil, time.Now(),
+	)
+	if err != nil {
+		t.Fatalf("unable to create wallet: %v", err)
+	}
+	defer func() {
+		if err := loader.UnloadWallet(); err != nil {
+			t.Fatalf("unable to unload wallet: %v", err)
+		}
+	}()
+	w.chainClient = &mockChainClient{}
+
+	for _, scope := range scopes {
+		if _, err := w.NewAddress(0, scope); err != nil {
+			t.Fatalf("unable to create address for scope %v: %v",
+				scope, err)
+		}
+	}
+
+	_, err = w.Manager.FetchScopedKeyManager(waddrmgr.KeyScopeBIP0049Plus)
+	if !waddrmgr.IsError(err, waddrmgr.ErrScopeNotFound) {
+		t.Fatalf("expected ErrScopeNotFound for unselected scope, got %v",
+			err)
+	}
+}
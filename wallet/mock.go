@@ -41,6 +41,10 @@ func (m *mockChainClient) GetBlockHeader(*chainhash.Hash) (*wire.BlockHeader,
 	return nil, nil
 }
 
+func (m *mockChainClient) BlockHeightForTimestamp(time.Time) (int32, error) {
+	return 0, nil
+}
+
 func (m *mockChainClient) IsCurrent() bool {
 	return false
 }
@@ -63,6 +67,12 @@ func (m *mockChainClient) SendRawTransaction(*wire.MsgTx, bool) (
 	return nil, nil
 }
 
+func (m *mockChainClient) GetMerkleProof(chainhash.Hash) (*chain.MerkleProof,
+	error) {
+
+	return nil, nil
+}
+
 func (m *mockChainClient) Rescan(*chainhash.Hash, []btcutil.Address,
 	map[wire.OutPoint]btcutil.Address) error {
 	return nil
@@ -71,7 +71,9 @@ func (w *Wallet) MakeMultiSigScript(addrs []btcutil.Address, nRequired int) ([]b
 	return txscript.MultiSigScript(pubKeys, nRequired)
 }
 
-// ImportP2SHRedeemScript adds a P2SH redeem script to the wallet.
+// ImportP2SHRedeemScript adds a P2SH redeem script to the wallet. If the script
+// was already imported, its address is returned along with
+// ErrAddressAlreadyImported.
 func (w *Wallet) ImportP2SHRedeemScript(script []byte) (*btcutil.AddressScriptHash, error) {
 	var p2shAddr *btcutil.AddressScriptHash
 	err := walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
@@ -86,7 +88,7 @@ func (w *Wallet) ImportP2SHRedeemScript(script []byte) (*btcutil.AddressScriptHa
 		// As this is a regular P2SH script, we'll import this into the
 		// BIP0044 scope.
 		bip44Mgr, err := w.Manager.FetchScopedKeyManager(
-			waddrmgr.KeyScopeBIP0084,
+			waddrmgr.KeyScopeBIP0044,
 		)
 		if err != nil {
 			return err
@@ -94,17 +96,16 @@ func (w *Wallet) ImportP2SHRedeemScript(script []byte) (*btcutil.AddressScriptHa
 
 		addrInfo, err := bip44Mgr.ImportScript(addrmgrNs, script, bs)
 		if err != nil {
-			// Don't care if it's already there, but still have to
-			// set the p2shAddr since the address manager didn't
-			// return anything useful.
+			// The address is still returned if it's already there,
+			// since the address manager didn't return anything
+			// useful.
 			if waddrmgr.IsError(err, waddrmgr.ErrDuplicateAddress) {
 				// This function will never error as it always
 				// hashes the script to the correct length.
 				p2shAddr, _ = btcutil.NewAddressScriptHash(script,
 					w.chainParams)
-				return nil
 			}
-			return err
+			return alreadyImportedError(err)
 		}
 
 		p2shAddr = addrInfo.Address().(*btcutil.AddressScriptHash)
@@ -0,0 +1,35 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// nonStandardBalance sums the amounts of the unspent outputs with the given
+// number of confirmations at the given height paying to non-standard scripts.
+func (w *Wallet) nonStandardBalance(txmgrNs walletdb.ReadBucket,
+	confirms, height int32) (btcutil.Amount, error) {
+
+	unspent, err := w.TxStore.UnspentOutputs(txmgrNs)
+	if err != nil {
+		return 0, err
+	}
+
+	var balance btcutil.Amount
+	for i := range unspent {
+		output := &unspent[i]
+		if !output.NonStandard {
+			continue
+		}
+		if !confirmed(confirms, output.Height, height) {
+			continue
+		}
+		balance += output.Amount
+	}
+
+	return balance, nil
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNonStandardScriptPolicy ensures that a bare multisig output the wallet
+// holds a key for is ignored by default, and recorded as a non-standard credit
+// excluded from the balance under RecordNonStandardScripts.
+func TestNonStandardScriptPolicy(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	info, err := w.AddressInfo(addr)
+	require.NoError(t, err)
+	walletKey, err := btcutil.NewAddressPubKey(
+		info.PubKey.SerializeCompressed(), w.chainParams,
+	)
+	require.NoError(t, err)
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	foreignKey, err := btcutil.NewAddressPubKey(
+		privKey.PubKey().SerializeCompressed(), w.chainParams,
+	)
+	require.NoError(t, err)
+
+	// Build a 1-of-2 bare multisig script spendable by the wallet's key.
+	pkScript, err := txscript.MultiSigScript(
+		[]*btcutil.AddressPubKey{foreignKey, walletKey}, 1,
+	)
+	require.NoError(t, err)
+
+	// addTx processes a new transaction paying to the bare multisig
+	// script, as if it had been found during a rescan.
+	var prevHash byte
+	addTx := func() *wire.MsgTx {
+		prevHash++
+		msgTx := wire.NewMsgTx(wire.TxVersion)
+		prevOut := wire.OutPoint{Hash: chainhash.Hash{prevHash}}
+		msgTx.AddTxIn(wire.NewTxIn(&prevOut, nil, nil))
+		msgTx.AddTxOut(wire.NewTxOut(100000, pkScript))
+
+		rec, err := wtxmgr.NewTxRecordFromMsgTx(msgTx, time.Now())
+		require.NoError(t, err)
+		addRec := func(tx walletdb.ReadWriteTx) error {
+			return w.addRelevantTx(tx, rec, nil)
+		}
+		require.NoError(t, walletdb.Update(w.db, addRec))
+
+		return msgTx
+	}
+
+	// unspent returns the wallet's unspent credits by outpoint.
+	unspent := func() map[wire.OutPoint]wtxmgr.Credit {
+		var credits []wtxmgr.Credit
+		err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+			ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+			var err error
+			credits, err = w.TxStore.UnspentOutputs(ns)
+			return err
+		})
+		require.NoError(t, err)
+
+		byOutPoint := make(map[wire.OutPoint]wtxmgr.Credit)
+		for _, credit := range credits {
+			byOutPoint[credit.OutPoint] = credit
+		}
+		return byOutPoint
+	}
+
+	// By default, such outputs should be ignored.
+	skippedTx := addTx()
+	_, ok := unspent()[wire.OutPoint{Hash: skippedTx.TxHash()}]
+	require.False(t, ok)
+
+	// Once configured to record them, the output should be recorded,
+	// flagged as non-standard, while being excluded from the balance.
+	w.SetNonStandardScriptPolicy(RecordNonStandardScripts)
+	recordedTx := addTx()
+	credit, ok := unspent()[wire.OutPoint{Hash: recordedTx.TxHash()}]
+	require.True(t, ok)
+	require.True(t, credit.NonStandard)
+	require.EqualValues(t, 100000, credit.Amount)
+
+	balance, err := w.CalculateBalance(0)
+	require.NoError(t, err)
+	require.Zero(t, balance)
+}
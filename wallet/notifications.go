@@ -6,10 +6,13 @@ package wallet
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/walletdb"
@@ -25,6 +28,11 @@ import (
 // instead of the easy thing since the db can be fixed later, and we want the
 // api correct now.
 
+// ErrUnknownResumeBlock is returned when resuming transaction notifications
+// from a block that isn't in the wallet's main chain, such as one that has
+// since been reorged out.
+var ErrUnknownResumeBlock = errors.New("resume block not in main chain")
+
 // NotificationServer is a server that interested clients may hook into to
 // receive notifications of changes in a wallet.  A client is created for each
 // registered notification.  Clients are guaranteed to receive messages in the
@@ -32,15 +40,21 @@ import (
 // different clients.
 type NotificationServer struct {
 	transactions   []chan *TransactionNotifications
+	txMinCredits   map[<-chan *TransactionNotifications]btcutil.Amount
 	currentTxNtfn  *TransactionNotifications // coalesce this since wallet does not add mined txs together
 	spentness      map[uint32][]chan *SpentnessNotifications
 	accountClients []chan *AccountNotification
+	sweepClients   []chan *CoinbaseSweepNotification
+	replaceClients []chan *TxReplaced
 	mu             sync.Mutex // Only protects registered client channels
 	wallet         *Wallet    // smells like hacks
 }
 
 func newNotificationServer(wallet *Wallet) *NotificationServer {
 	return &NotificationServer{
+		txMinCredits: make(
+			map[<-chan *TransactionNotifications]btcutil.Amount,
+		),
 		spentness: make(map[uint32][]chan *SpentnessNotifications),
 		wallet:    wallet,
 	}
@@ -134,9 +148,11 @@ func makeTxSummary(dbtx walletdb.ReadTx, w *Wallet, details *wtxmgr.TxDetails) T
 		}
 		acct, internal := lookupOutputChain(dbtx, w, details, details.Credits[credIndex])
 		output := TransactionSummaryOutput{
-			Index:    uint32(i),
-			Account:  acct,
-			Internal: internal,
+			Index:        uint32(i),
+			Account:      acct,
+			Internal:     internal,
+			Amount:       details.Credits[credIndex].Amount,
+			SelfTransfer: details.Credits[credIndex].SelfTransfer,
 		}
 		outputs = append(outputs, output)
 	}
@@ -152,11 +168,27 @@ func makeTxSummary(dbtx walletdb.ReadTx, w *Wallet, details *wtxmgr.TxDetails) T
 }
 
 func totalBalances(dbtx walletdb.ReadTx, w *Wallet, m map[uint32]btcutil.Amount) error {
+	balances, err := accountBalances(dbtx, w)
+	if err != nil {
+		return err
+	}
+	for account := range m {
+		m[account] = balances[account]
+	}
+	return nil
+}
+
+// accountBalances returns the total balances of every account with unspent
+// outputs.
+func accountBalances(dbtx walletdb.ReadTx,
+	w *Wallet) (map[uint32]btcutil.Amount, error) {
+
 	addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
 	unspent, err := w.TxStore.UnspentOutputs(dbtx.ReadBucket(wtxmgrNamespaceKey))
 	if err != nil {
-		return err
+		return nil, err
 	}
+	balances := make(map[uint32]btcutil.Amount)
 	for i := range unspent {
 		output := &unspent[i]
 		var outputAcct uint32
@@ -166,13 +198,10 @@ func totalBalances(dbtx walletdb.ReadTx, w *Wallet, m map[uint32]btcutil.Amount)
 			_, outputAcct, err = w.Manager.AddrAccount(addrmgrNs, addrs[0])
 		}
 		if err == nil {
-			_, ok := m[outputAcct]
-			if ok {
-				m[outputAcct] += output.Amount
-			}
+			balances[outputAcct] += output.Amount
 		}
 	}
-	return nil
+	return balances, nil
 }
 
 func flattenBalanceMap(m map[uint32]btcutil.Amount) []AccountBalance {
@@ -228,10 +257,59 @@ func (s *NotificationServer) notifyUnminedTransaction(dbtx walletdb.ReadTx, deta
 		NewBalances:              flattenBalanceMap(bals),
 	}
 	for _, c := range clients {
-		c <- n
+		filtered := filterTxNotification(n, s.txMinCredits[c])
+		if len(filtered.UnminedTransactions) == 0 {
+			continue
+		}
+		c <- filtered
 	}
 }
 
+// belowMinCredit returns whether the transaction only credits the wallet, and
+// does so by less than the given minimum amount. Transactions spending wallet
+// outputs are never considered to be below the minimum.
+func belowMinCredit(tx *TransactionSummary, minCredit btcutil.Amount) bool {
+	if minCredit == 0 || len(tx.MyInputs) != 0 {
+		return false
+	}
+
+	var credit btcutil.Amount
+	for _, output := range tx.MyOutputs {
+		credit += output.Amount
+	}
+	return credit < minCredit
+}
+
+// filterTxNotification returns the notification without the transactions that
+// only credit the wallet by less than the given minimum amount. The
+// notification itself is returned if no transactions are filtered out.
+func filterTxNotification(n *TransactionNotifications,
+	minCredit btcutil.Amount) *TransactionNotifications {
+
+	if minCredit == 0 {
+		return n
+	}
+
+	filterTxs := func(txs []TransactionSummary) []TransactionSummary {
+		filtered := make([]TransactionSummary, 0, len(txs))
+		for i := range txs {
+			if !belowMinCredit(&txs[i], minCredit) {
+				filtered = append(filtered, txs[i])
+			}
+		}
+		return filtered
+	}
+
+	filtered := *n
+	filtered.UnminedTransactions = filterTxs(n.UnminedTransactions)
+	filtered.AttachedBlocks = make([]Block, len(n.AttachedBlocks))
+	for i, block := range n.AttachedBlocks {
+		block.Transactions = filterTxs(block.Transactions)
+		filtered.AttachedBlocks[i] = block
+	}
+	return &filtered
+}
+
 func (s *NotificationServer) notifyDetachedBlock(hash *chainhash.Hash) {
 	if s.currentTxNtfn == nil {
 		s.currentTxNtfn = &TransactionNotifications{}
@@ -316,7 +394,7 @@ func (s *NotificationServer) notifyAttachedBlock(dbtx walletdb.ReadTx, block *wt
 	s.currentTxNtfn.NewBalances = flattenBalanceMap(bals)
 
 	for _, c := range clients {
-		c <- s.currentTxNtfn
+		c <- filterTxNotification(s.currentTxNtfn, s.txMinCredits[c])
 	}
 	s.currentTxNtfn = nil
 }
@@ -380,11 +458,16 @@ type TransactionSummaryInput struct {
 
 // TransactionSummaryOutput describes wallet properties of a transaction output
 // controlled by the wallet.  The Index field marks the transaction output index
-// of the transaction (not included here).
+// of the transaction (not included here).  SelfTransfer marks outputs paying to
+// a non-change address of the wallet from a transaction spending the wallet's
+// own outputs, which move funds between the wallet's accounts rather than
+// receiving them.
 type TransactionSummaryOutput struct {
-	Index    uint32
-	Account  uint32
-	Internal bool
+	Index        uint32
+	Account      uint32
+	Internal     bool
+	Amount       btcutil.Amount
+	SelfTransfer bool
 }
 
 // AccountBalance associates a total (zero confirmation) balance with an
@@ -403,6 +486,16 @@ type TransactionNotificationsClient struct {
 	server *NotificationServer
 }
 
+// TransactionNotificationsConfig houses the options of a
+// TransactionNotificationsClient.
+type TransactionNotificationsConfig struct {
+	// MinCreditAmount is the minimum amount a transaction that only
+	// credits the wallet must credit it by to be notified. Transactions
+	// spending wallet outputs are notified regardless. A value of zero
+	// notifies all transactions.
+	MinCreditAmount btcutil.Amount
+}
+
 // TransactionNotifications returns a client for receiving
 // TransactionNotifiations notifications over a channel.  The channel is
 // unbuffered.
@@ -410,9 +503,26 @@ type TransactionNotificationsClient struct {
 // When finished, the Done method should be called on the client to disassociate
 // it from the server.
 func (s *NotificationServer) TransactionNotifications() TransactionNotificationsClient {
+	return s.TransactionNotificationsWithConfig(
+		TransactionNotificationsConfig{},
+	)
+}
+
+// TransactionNotificationsWithConfig is the same as TransactionNotifications,
+// but the returned client's notifications are filtered according to the given
+// config.
+//
+// Unmined transactions filtered out for the client are not notified at all,
+// while mined ones are omitted from the transactions of their attached block.
+func (s *NotificationServer) TransactionNotificationsWithConfig(
+	cfg TransactionNotificationsConfig) TransactionNotificationsClient {
+
 	c := make(chan *TransactionNotifications)
 	s.mu.Lock()
 	s.transactions = append(s.transactions, c)
+	if cfg.MinCreditAmount != 0 {
+		s.txMinCredits[c] = cfg.MinCreditAmount
+	}
 	s.mu.Unlock()
 	return TransactionNotificationsClient{
 		C:      c,
@@ -420,6 +530,177 @@ func (s *NotificationServer) TransactionNotifications() TransactionNotifications
 	}
 }
 
+// ResumeTransactionNotifications is the same as
+// TransactionNotificationsWithConfig, but the returned client is first
+// notified of the changes it missed since the given block, which is usually
+// the last attached block notified to it before disconnecting.
+//
+// The missed changes are replayed as a single notification attaching the
+// blocks after the resume block that contain relevant transactions, along
+// with the current tip. As the wallet doesn't record when unmined
+// transactions were added relative to its synced blocks, every unmined
+// transaction is included. Live notifications follow the replay, without any
+// being missed or duplicated in between.
+//
+// ErrUnknownResumeBlock is returned if the resume block is no longer in the
+// wallet's main chain, in which case the client should resume from an earlier
+// block.
+func (s *NotificationServer) ResumeTransactionNotifications(
+	cfg TransactionNotificationsConfig,
+	from *waddrmgr.BlockStamp) (TransactionNotificationsClient, error) {
+
+	var client TransactionNotificationsClient
+
+	// The wallet notifies clients before committing its database
+	// transactions, so a read-write transaction is used to ensure no
+	// changes are pending while the client is registered: those already
+	// committed are replayed, and later ones are notified live.
+	db := s.wallet.db
+	err := walletdb.Update(db, func(dbtx walletdb.ReadWriteTx) error {
+		n, err := s.replayTxNotification(dbtx, from)
+		if err != nil {
+			return err
+		}
+
+		// The replay is buffered ahead of live notifications, which
+		// are only delivered once it has been received.
+		var c chan *TransactionNotifications
+		if n != nil {
+			c = make(chan *TransactionNotifications, 1)
+			c <- filterTxNotification(n, cfg.MinCreditAmount)
+		} else {
+			c = make(chan *TransactionNotifications)
+		}
+
+		s.mu.Lock()
+		s.transactions = append(s.transactions, c)
+		if cfg.MinCreditAmount != 0 {
+			s.txMinCredits[c] = cfg.MinCreditAmount
+		}
+		s.mu.Unlock()
+
+		client = TransactionNotificationsClient{
+			C:      c,
+			server: s,
+		}
+		return nil
+	})
+	return client, err
+}
+
+// replayTxNotification creates a notification of the relevant transactions
+// and blocks added to the wallet after the given block, or nil if there are
+// none.
+func (s *NotificationServer) replayTxNotification(dbtx walletdb.ReadTx,
+	from *waddrmgr.BlockStamp) (*TransactionNotifications, error) {
+
+	addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+	txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+	syncedTo := s.wallet.Manager.SyncedTo()
+	if from.Height > syncedTo.Height {
+		return nil, fmt.Errorf("%w: block %v at height %d is above "+
+			"the synced height %d", ErrUnknownResumeBlock,
+			from.Hash, from.Height, syncedTo.Height)
+	}
+	hash, err := s.wallet.Manager.BlockHash(addrmgrNs, from.Height)
+	switch {
+	case waddrmgr.IsError(err, waddrmgr.ErrBlockNotFound):
+		return nil, fmt.Errorf("%w: no block at height %d",
+			ErrUnknownResumeBlock, from.Height)
+	case err != nil:
+		return nil, err
+	case *hash != from.Hash:
+		return nil, fmt.Errorf("%w: block %v at height %d was "+
+			"replaced by %v", ErrUnknownResumeBlock, from.Hash,
+			from.Height, hash)
+	}
+
+	n := &TransactionNotifications{}
+	err = s.wallet.TxStore.RangeTransactions(
+		txmgrNs, from.Height+1, -1,
+		func(details []wtxmgr.TxDetails) (bool, error) {
+			txs := make([]TransactionSummary, len(details))
+			for i := range details {
+				txs[i] = makeTxSummary(
+					dbtx, s.wallet, &details[i],
+				)
+			}
+
+			// The details are reused across calls, so the block
+			// they were mined in must be copied.
+			block := details[0].Block
+			if block.Height == -1 {
+				n.UnminedTransactions = txs
+				return false, nil
+			}
+			n.AttachedBlocks = append(n.AttachedBlocks, Block{
+				Hash:         &block.Hash,
+				Height:       block.Height,
+				Timestamp:    block.Time.Unix(),
+				Transactions: txs,
+			})
+			return false, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The tip is always attached if the client missed it, even without
+	// relevant transactions, so that the client knows the wallet's
+	// current synced block.
+	numBlocks := len(n.AttachedBlocks)
+	if syncedTo.Height > from.Height && (numBlocks == 0 ||
+		n.AttachedBlocks[numBlocks-1].Height != syncedTo.Height) {
+
+		n.AttachedBlocks = append(n.AttachedBlocks, Block{
+			Hash:      &syncedTo.Hash,
+			Height:    syncedTo.Height,
+			Timestamp: syncedTo.Timestamp.Unix(),
+		})
+	}
+	if len(n.AttachedBlocks) == 0 && len(n.UnminedTransactions) == 0 {
+		return nil, nil
+	}
+
+	n.UnminedTransactionHashes, err = s.wallet.TxStore.UnminedTxHashes(
+		txmgrNs,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	bals := make(map[uint32]btcutil.Amount)
+	for _, b := range n.AttachedBlocks {
+		relevantAccounts(s.wallet, bals, b.Transactions)
+	}
+	relevantAccounts(s.wallet, bals, n.UnminedTransactions)
+	if err := totalBalances(dbtx, s.wallet, bals); err != nil {
+		return nil, err
+	}
+	n.NewBalances = flattenBalanceMap(bals)
+
+	return n, nil
+}
+
+// SetMinCreditAmount changes the minimum amount a transaction that only
+// credits the wallet must credit it by to be notified to the client. A value
+// of zero notifies all transactions.
+func (c *TransactionNotificationsClient) SetMinCreditAmount(
+	minCredit btcutil.Amount) {
+
+	s := c.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if minCredit == 0 {
+		delete(s.txMinCredits, c.C)
+		return
+	}
+	s.txMinCredits[c.C] = minCredit
+}
+
 // Done deregisters the client from the server and drains any remaining
 // messages.  It must be called exactly once when the client is finished
 // receiving notifications.
@@ -438,6 +719,7 @@ func (c *TransactionNotificationsClient) Done() {
 			if c.C == ch {
 				clients[i] = clients[len(clients)-1]
 				s.transactions = clients[:len(clients)-1]
+				delete(s.txMinCredits, c.C)
 				close(ch)
 				break
 			}
@@ -613,3 +895,132 @@ func (c *AccountNotificationsClient) Done() {
 		s.mu.Unlock()
 	}()
 }
+
+// CoinbaseSweepNotification describes a transaction created and published by
+// the wallet to sweep its mature coinbase outputs.
+type CoinbaseSweepNotification struct {
+	Tx      *wire.MsgTx
+	Inputs  []wire.OutPoint
+	Address btcutil.Address
+	Amount  btcutil.Amount
+	Fee     btcutil.Amount
+}
+
+func (s *NotificationServer) notifyCoinbaseSweep(n *CoinbaseSweepNotification) {
+	defer s.mu.Unlock()
+	s.mu.Lock()
+	for _, c := range s.sweepClients {
+		c <- n
+	}
+}
+
+// CoinbaseSweepNotificationsClient receives CoinbaseSweepNotifications over
+// the channel C.
+type CoinbaseSweepNotificationsClient struct {
+	C      chan *CoinbaseSweepNotification
+	server *NotificationServer
+}
+
+// CoinbaseSweepNotifications returns a client for receiving a
+// CoinbaseSweepNotification for each coinbase sweep created by the wallet.
+// The channel is unbuffered.  When finished, the client's Done method should
+// be called to disassociate the client from the server.
+func (s *NotificationServer) CoinbaseSweepNotifications() CoinbaseSweepNotificationsClient {
+	c := make(chan *CoinbaseSweepNotification)
+	s.mu.Lock()
+	s.sweepClients = append(s.sweepClients, c)
+	s.mu.Unlock()
+	return CoinbaseSweepNotificationsClient{
+		C:      c,
+		server: s,
+	}
+}
+
+// Done deregisters the client from the server and drains any remaining
+// messages.  It must be called exactly once when the client is finished
+// receiving notifications.
+func (c *CoinbaseSweepNotificationsClient) Done() {
+	go func() {
+		for range c.C {
+		}
+	}()
+	go func() {
+		s := c.server
+		s.mu.Lock()
+		clients := s.sweepClients
+		for i, ch := range clients {
+			if c.C == ch {
+				clients[i] = clients[len(clients)-1]
+				s.sweepClients = clients[:len(clients)-1]
+				close(ch)
+				break
+			}
+		}
+		s.mu.Unlock()
+	}()
+}
+
+// TxReplaced describes an unconfirmed wallet transaction that left the mempool
+// after being replaced, such as through RBF, by another transaction spending
+// some of the same inputs.
+type TxReplaced struct {
+	OldTxHash chainhash.Hash
+	NewTxHash chainhash.Hash
+}
+
+func (s *NotificationServer) notifyTxReplaced(n *TxReplaced) {
+	defer s.mu.Unlock()
+	s.mu.Lock()
+	for _, c := range s.replaceClients {
+		c <- n
+	}
+}
+
+// TxReplacedNotificationsClient receives TxReplaced notifications over the
+// channel C.
+type TxReplacedNotificationsClient struct {
+	C      chan *TxReplaced
+	server *NotificationServer
+}
+
+// TxReplacedNotifications returns a client for receiving a TxReplaced
+// notification for each replaced transaction found when reconciling the
+// wallet's unconfirmed transactions against the mempool, replaced when bumping
+// its fee, or conflicting with a transaction received from the mempool.
+// Transactions that left the mempool without being replaced aren't notified.
+// The channel is unbuffered.  When finished, the client's Done method should
+// be called to disassociate the client from the server.
+func (s *NotificationServer) TxReplacedNotifications() TxReplacedNotificationsClient {
+	c := make(chan *TxReplaced)
+	s.mu.Lock()
+	s.replaceClients = append(s.replaceClients, c)
+	s.mu.Unlock()
+	return TxReplacedNotificationsClient{
+		C:      c,
+		server: s,
+	}
+}
+
+// Done deregisters the client from the server and drains any remaining
+// messages.  It must be called exactly once when the client is finished
+// receiving notifications.
+func (c *TxReplacedNotificationsClient) Done() {
+	go func() {
+		for range c.C {
+		}
+	}()
+	go func() {
+		s := c.server
+		s.mu.Lock()
+		clients := s.replaceClients
+		for i, ch := range clients {
+			if c.C == ch {
+				clients[i] = clients[len(clients)-1]
+				s.replaceClients = clients[:len(clients)-1]
+				close(ch)
+				break
+			}
+		}
+		s.mu.Unlock()
+	}()
+}
@@ -0,0 +1,287 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTransactionNotificationsMinCredit ensures that a transaction
+// notifications client with a minimum credit amount is only notified of
+// deposits of at least that amount, and that the minimum can be changed at
+// runtime.
+func TestTransactionNotificationsMinCredit(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+
+	const minCredit = 10000
+	client := w.NtfnServer.TransactionNotificationsWithConfig(
+		TransactionNotificationsConfig{MinCreditAmount: minCredit},
+	)
+	defer client.Done()
+
+	received := make(chan *TransactionNotifications, 10)
+	go func() {
+		for n := range client.C {
+			received <- n
+		}
+	}()
+
+	// deposit adds an unmined transaction paying the given amount to the
+	// wallet.
+	deposit := func(prevHash byte, amount int64) chainhash.Hash {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(
+			&wire.OutPoint{Hash: chainhash.Hash{prevHash}}, nil, nil,
+		))
+		tx.AddTxOut(wire.NewTxOut(amount, pkScript))
+
+		rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+		if err != nil {
+			t.Fatalf("unable to create tx record: %v", err)
+		}
+		err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+			return w.addRelevantTx(dbtx, rec, nil)
+		})
+		if err != nil {
+			t.Fatalf("unable to add deposit: %v", err)
+		}
+		return rec.Hash
+	}
+
+	// assertNotified ensures the next notification received is of the
+	// transaction with the given hash.
+	assertNotified := func(txHash chainhash.Hash) {
+		t.Helper()
+
+		select {
+		case n := <-received:
+			if len(n.UnminedTransactions) != 1 ||
+				*n.UnminedTransactions[0].Hash != txHash {
+
+				t.Fatalf("expected notification of %v, got %v",
+					txHash, n.UnminedTransactions)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected notification of %v", txHash)
+		}
+	}
+
+	// Only the deposit of at least the minimum credit amount should be
+	// notified. As notifications are delivered in order, receiving the
+	// latter's notification first ensures the former's wasn't sent.
+	deposit(0x01, minCredit-1)
+	aboveHash := deposit(0x02, minCredit)
+	assertNotified(aboveHash)
+
+	// Once the minimum is removed, small deposits should be notified.
+	client.SetMinCreditAmount(0)
+	smallHash := deposit(0x03, 1000)
+	assertNotified(smallHash)
+
+	select {
+	case n := <-received:
+		t.Fatalf("unexpected notification: %v", n)
+	default:
+	}
+}
+
+// TestResumeTransactionNotifications ensures that a client resuming
+// transaction notifications from the last block it was notified of is
+// replayed the blocks and transactions it missed, followed by live
+// notifications.
+func TestResumeTransactionNotifications(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	type ntfnChan = <-chan *TransactionNotifications
+
+	// receive forwards the notifications of the client, so that the
+	// wallet never blocks on delivering them.
+	receive := func(client TransactionNotificationsClient) ntfnChan {
+		received := make(chan *TransactionNotifications, 10)
+		go func() {
+			for n := range client.C {
+				received <- n
+			}
+		}()
+		return received
+	}
+
+	// nextNotification returns the next notification received.
+	nextNotification := func(received ntfnChan) *TransactionNotifications {
+
+		t.Helper()
+
+		select {
+		case n := <-received:
+			return n
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected notification")
+			return nil
+		}
+	}
+
+	// blockMeta returns the block at the given height.
+	blockMeta := func(height int32) wtxmgr.BlockMeta {
+		return wtxmgr.BlockMeta{
+			Block: wtxmgr.Block{
+				Hash:   chainhash.Hash{byte(height)},
+				Height: height,
+			},
+			Time: time.Unix(int64(height)*600, 0),
+		}
+	}
+
+	// connect connects the block at the given height, confirming the
+	// deposit of the given amount within it, if any.
+	connect := func(height int32, deposit int64) chainhash.Hash {
+		block := blockMeta(height)
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(
+			&wire.OutPoint{Hash: chainhash.Hash{byte(height), 1}},
+			nil, nil,
+		))
+		tx.AddTxOut(wire.NewTxOut(deposit, pkScript))
+		rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, block.Time)
+		require.NoError(t, err)
+
+		err = walletdb.Update(w.db, func(
+			dbtx walletdb.ReadWriteTx) error {
+
+			if deposit != 0 {
+				err := w.addRelevantTx(dbtx, rec, &block)
+				if err != nil {
+					return err
+				}
+			}
+			return w.connectBlock(dbtx, block)
+		})
+		require.NoError(t, err)
+		return rec.Hash
+	}
+
+	// The client is notified of the first block, from which it resumes
+	// after disconnecting.
+	client := w.NtfnServer.TransactionNotifications()
+	received := receive(client)
+	connect(1, 0)
+	n := nextNotification(received)
+	require.Len(t, n.AttachedBlocks, 1)
+	resumeBlock := &waddrmgr.BlockStamp{
+		Hash:   *n.AttachedBlocks[0].Hash,
+		Height: n.AttachedBlocks[0].Height,
+	}
+	client.Done()
+
+	// While disconnected, an unmined deposit is received and a block
+	// confirming another one is connected, followed by an empty block.
+	unmined := wire.NewMsgTx(wire.TxVersion)
+	unmined.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil, nil))
+	unmined.AddTxOut(wire.NewTxOut(2000, pkScript))
+	unminedRec, err := wtxmgr.NewTxRecordFromMsgTx(unmined, time.Now())
+	require.NoError(t, err)
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		return w.addRelevantTx(dbtx, unminedRec, nil)
+	})
+	require.NoError(t, err)
+
+	minedHash := connect(2, 1000)
+	connect(3, 0)
+
+	// Resuming from a block that isn't in the main chain fails.
+	_, err = w.NtfnServer.ResumeTransactionNotifications(
+		TransactionNotificationsConfig{},
+		&waddrmgr.BlockStamp{Hash: chainhash.Hash{0xff}, Height: 1},
+	)
+	require.True(t, errors.Is(err, ErrUnknownResumeBlock), err)
+	_, err = w.NtfnServer.ResumeTransactionNotifications(
+		TransactionNotificationsConfig{},
+		&waddrmgr.BlockStamp{Hash: chainhash.Hash{4}, Height: 4},
+	)
+	require.True(t, errors.Is(err, ErrUnknownResumeBlock), err)
+
+	// Once resumed, the client is first notified of everything it missed:
+	// the block confirming the deposit, the current tip and the unmined
+	// deposit.
+	client, err = w.NtfnServer.ResumeTransactionNotifications(
+		TransactionNotificationsConfig{}, resumeBlock,
+	)
+	require.NoError(t, err)
+	defer client.Done()
+	received = receive(client)
+
+	n = nextNotification(received)
+	require.Len(t, n.AttachedBlocks, 2)
+	require.Equal(t, blockMeta(2).Hash, *n.AttachedBlocks[0].Hash)
+	require.Equal(t, int32(2), n.AttachedBlocks[0].Height)
+	require.Len(t, n.AttachedBlocks[0].Transactions, 1)
+	require.Equal(t, minedHash, *n.AttachedBlocks[0].Transactions[0].Hash)
+	require.Equal(t, blockMeta(3).Hash, *n.AttachedBlocks[1].Hash)
+	require.Equal(
+		t, blockMeta(3).Time.Unix(), n.AttachedBlocks[1].Timestamp,
+	)
+	require.Empty(t, n.AttachedBlocks[1].Transactions)
+	require.Len(t, n.UnminedTransactions, 1)
+	require.Equal(t, unminedRec.Hash, *n.UnminedTransactions[0].Hash)
+	require.Equal(t, []*chainhash.Hash{&unminedRec.Hash},
+		n.UnminedTransactionHashes)
+	require.Len(t, n.NewBalances, 1)
+	require.Equal(t, btcutil.Amount(3000), n.NewBalances[0].TotalBalance)
+
+	// Blocks connected afterwards are notified live.
+	connect(4, 0)
+	n = nextNotification(received)
+	require.Len(t, n.AttachedBlocks, 1)
+	require.Equal(t, blockMeta(4).Hash, *n.AttachedBlocks[0].Hash)
+
+	select {
+	case n := <-received:
+		t.Fatalf("unexpected notification: %v", n)
+	default:
+	}
+
+	// A client resuming from the tip is only replayed the unmined deposit.
+	tip := &waddrmgr.BlockStamp{Hash: blockMeta(4).Hash, Height: 4}
+	upToDate, err := w.NtfnServer.ResumeTransactionNotifications(
+		TransactionNotificationsConfig{}, tip,
+	)
+	require.NoError(t, err)
+	defer upToDate.Done()
+
+	n = nextNotification(receive(upToDate))
+	require.Empty(t, n.AttachedBlocks)
+	require.Len(t, n.UnminedTransactions, 1)
+	require.Equal(t, unminedRec.Hash, *n.UnminedTransactions[0].Hash)
+}
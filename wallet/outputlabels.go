@@ -0,0 +1,135 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// LabeledOutput is an output to be paid by SendLabeledOutputs, along with its
+// optional label.
+type LabeledOutput struct {
+	// Address is the address the output pays to.
+	Address btcutil.Address
+
+	// Amount is the amount of the output.
+	Amount btcutil.Amount
+
+	// Label is the label to store for the output once the transaction
+	// paying it is created, or empty if it has none.
+	Label string
+}
+
+// SendLabeledOutputs creates and sends a single payment transaction paying to
+// every one of the outputs, like SendOutputs, with coin selection, dust checks
+// and fees applying to all of them at once. The label of each output, if any,
+// is stored against the transaction output paying it, and can be retrieved
+// through OutputLabel, while the label given applies to the transaction
+// itself. Labels are validated before any coins are selected, such that
+// nothing is sent if any of them is too long.
+func (w *Wallet) SendLabeledOutputs(outputs []LabeledOutput,
+	keyScope *waddrmgr.KeyScope, account uint32, minconf int32,
+	satPerKb btcutil.Amount, coinSelectionStrategy CoinSelectionStrategy,
+	label string, optFuncs ...TxCreateOption) (*wire.MsgTx, error) {
+
+	txOuts := make([]*wire.TxOut, 0, len(outputs))
+	outputLabels := make([]string, 0, len(outputs))
+	for _, output := range outputs {
+		if len(output.Label) > wtxmgr.TxLabelLimit {
+			return nil, wtxmgr.ErrLabelTooLong
+		}
+
+		pkScript, err := txscript.PayToAddrScript(output.Address)
+		if err != nil {
+			return nil, err
+		}
+		txOuts = append(
+			txOuts, wire.NewTxOut(int64(output.Amount), pkScript),
+		)
+		outputLabels = append(outputLabels, output.Label)
+	}
+
+	return w.sendOutputs(
+		txOuts, outputLabels, keyScope, account, minconf, satPerKb,
+		coinSelectionStrategy, label, optFuncs...,
+	)
+}
+
+// outputLabelIndexes maps the labels of the requested outputs to the indexes
+// of the outputs of the created transaction paying them. As the outputs may
+// have been reordered, such as when randomizing the position of the change
+// output, each label is matched to the first output not yet matched with the
+// same script and amount, which is ambiguous only among identical outputs.
+func outputLabelIndexes(createdTx *txauthor.AuthoredTx,
+	outputs []*wire.TxOut, outputLabels []string) map[uint32]string {
+
+	if len(outputLabels) == 0 {
+		return nil
+	}
+
+	indexes := make(map[uint32]string)
+	for i, label := range outputLabels {
+		if label == "" {
+			continue
+		}
+
+		output := outputs[i]
+		for j, txOut := range createdTx.Tx.TxOut {
+			if j == createdTx.ChangeIndex {
+				continue
+			}
+			if _, ok := indexes[uint32(j)]; ok {
+				continue
+			}
+			if txOut.Value != output.Value ||
+				!bytes.Equal(txOut.PkScript, output.PkScript) {
+
+				continue
+			}
+
+			indexes[uint32(j)] = label
+			break
+		}
+	}
+
+	return indexes
+}
+
+// SetOutputLabel sets the label of the output of a wallet transaction,
+// replacing any existing one, or removes it if the label is empty. The call
+// will fail if the label is too long, or if the transaction is unknown to the
+// wallet. The label is deleted along with its transaction if it's abandoned,
+// replaced or rejected while unconfirmed.
+func (w *Wallet) SetOutputLabel(op wire.OutPoint, label string) error {
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		txmgrNs := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+
+		err := w.TxStore.SetOutputLabel(txmgrNs, op, label)
+		if wtxmgr.IsNoExists(err) {
+			return ErrUnknownTransaction
+		}
+		return err
+	})
+}
+
+// OutputLabel returns the label of the output of a wallet transaction, or an
+// empty string if it has none.
+func (w *Wallet) OutputLabel(op wire.OutPoint) (string, error) {
+	var label string
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		txmgrNs := tx.ReadBucket(wtxmgrNamespaceKey)
+		label = w.TxStore.OutputLabel(txmgrNs, op)
+		return nil
+	})
+	return label, err
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSendLabeledOutputs ensures that a batch of labeled outputs is paid by a
+// single transaction, with the label of each output being stored against the
+// transaction output paying it.
+func TestSendLabeledOutputs(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	fundingTx := fundWallet(t, w, 10000000)
+
+	// One of the five recipients has no label.
+	labels := []string{"alice", "bob", "", "dave", "erin"}
+	outputs := make([]LabeledOutput, len(labels))
+	for i, label := range labels {
+		addr, err := btcutil.NewAddressWitnessPubKeyHash(
+			bytes.Repeat([]byte{byte(i + 1)}, 20), w.chainParams,
+		)
+		require.NoError(t, err)
+
+		outputs[i] = LabeledOutput{
+			Address: addr,
+			Amount:  btcutil.Amount(100000 * (i + 1)),
+			Label:   label,
+		}
+	}
+
+	// Nothing is sent if any of the labels is too long.
+	tooLong := append([]LabeledOutput(nil), outputs...)
+	tooLong[4].Label = strings.Repeat("a", wtxmgr.TxLabelLimit+1)
+	_, err := w.SendLabeledOutputs(
+		tooLong, &waddrmgr.KeyScopeBIP0084, 0, 1, 1000,
+		CoinSelectionLargest, "payouts",
+	)
+	require.Equal(t, wtxmgr.ErrLabelTooLong, err)
+
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		unspent, err := w.TxStore.UnspentOutputs(ns)
+		require.NoError(t, err)
+		require.Len(t, unspent, 1)
+		require.Equal(t, fundingTx.TxHash(), unspent[0].Hash)
+		return nil
+	})
+	require.NoError(t, err)
+
+	tx, err := w.SendLabeledOutputs(
+		outputs, &waddrmgr.KeyScopeBIP0084, 0, 1, 1000,
+		CoinSelectionLargest, "payouts",
+	)
+	require.NoError(t, err)
+	require.Len(t, tx.TxOut, len(outputs)+1)
+
+	// Each recipient's label is stored against the output paying it,
+	// while the change output has none.
+	pkScripts := make([][]byte, len(outputs))
+	for i, output := range outputs {
+		pkScripts[i], err = txscript.PayToAddrScript(output.Address)
+		require.NoError(t, err)
+	}
+	paid := make(map[int]struct{})
+	for index, txOut := range tx.TxOut {
+		label, err := w.OutputLabel(wire.OutPoint{
+			Hash:  tx.TxHash(),
+			Index: uint32(index),
+		})
+		require.NoError(t, err)
+
+		expected := ""
+		for i, output := range outputs {
+			if !bytes.Equal(txOut.PkScript, pkScripts[i]) {
+				continue
+			}
+
+			require.EqualValues(t, output.Amount, txOut.Value)
+			expected = output.Label
+			paid[i] = struct{}{}
+		}
+		require.Equal(t, expected, label)
+	}
+	require.Len(t, paid, len(outputs))
+
+	// The labels are reported by the transaction's summary, along with the
+	// transaction's own label.
+	summary, err := w.TransactionSummary(tx.TxHash())
+	require.NoError(t, err)
+	require.Equal(t, "payouts", summary.Label)
+	for index, output := range summary.Outputs {
+		label, err := w.OutputLabel(wire.OutPoint{
+			Hash:  tx.TxHash(),
+			Index: uint32(index),
+		})
+		require.NoError(t, err)
+		require.Equal(t, label, output.Label)
+	}
+}
+
+// rejectingChainClient is a mock chain client rejecting the transactions sent
+// through it as double spends.
+type rejectingChainClient struct {
+	mockChainClient
+
+	sent []*wire.MsgTx
+}
+
+func (c *rejectingChainClient) SendRawTransaction(tx *wire.MsgTx,
+	_ bool) (*chainhash.Hash, error) {
+
+	c.sent = append(c.sent, tx)
+	return nil, &btcjson.RPCError{
+		Code:    btcjson.ErrRPCTxRejected,
+		Message: "txn-mempool-conflict",
+	}
+}
+
+// TestSendLabeledOutputsRejected ensures that the output labels of a
+// transaction rejected by the backend are removed along with it.
+func TestSendLabeledOutputsRejected(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	chainClient := &rejectingChainClient{}
+	w.chainClient = chainClient
+
+	fundWallet(t, w, 10000000)
+
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		bytes.Repeat([]byte{0x01}, 20), w.chainParams,
+	)
+	require.NoError(t, err)
+	_, err = w.SendLabeledOutputs(
+		[]LabeledOutput{{
+			Address: addr,
+			Amount:  100000,
+			Label:   "alice",
+		}}, &waddrmgr.KeyScopeBIP0084, 0, 1, 1000,
+		CoinSelectionLargest, "payouts",
+	)
+	var doubleSpendErr *ErrDoubleSpend
+	require.True(t, errors.As(err, &doubleSpendErr))
+	require.Len(t, chainClient.sent, 1)
+
+	tx := chainClient.sent[0]
+	for index := range tx.TxOut {
+		label, err := w.OutputLabel(wire.OutPoint{
+			Hash:  tx.TxHash(),
+			Index: uint32(index),
+		})
+		require.NoError(t, err)
+		require.Empty(t, label)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+var (
+	// ErrInvalidMerkleProof is returned when a merkle proof fails to prove
+	// the inclusion of a transaction within a block of the main chain.
+	ErrInvalidMerkleProof = errors.New("invalid merkle proof")
+)
+
+// blockHeightSource is implemented by chain backends able to look up the
+// height of a block by its hash.
+type blockHeightSource interface {
+	GetBlockHeight(*chainhash.Hash) (int32, error)
+}
+
+// ImportPrunedFunds records the credits of a transaction confirmed in a block
+// the wallet is unable to rescan, such as one that has been pruned by the
+// chain backend. The proof must be a serialized merkle block, as returned by
+// bitcoind's gettxoutproof, proving the inclusion of the transaction within a
+// block of the main chain. At least one of the transaction's outputs must pay
+// to the wallet, otherwise ErrNotMine is returned.
+//
+// This mirrors bitcoind's importprunedfunds.
+func (w *Wallet) ImportPrunedFunds(tx *wire.MsgTx, proof []byte) error {
+	chainClient, done, err := w.useChainClient()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var merkleBlock wire.MsgMerkleBlock
+	err = merkleBlock.BtcDecode(
+		bytes.NewReader(proof), wire.ProtocolVersion, wire.BaseEncoding,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMerkleProof, err)
+	}
+
+	root, matches, err := chain.ExtractMerkleMatches(&merkleBlock)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMerkleProof, err)
+	}
+	if root != merkleBlock.Header.MerkleRoot {
+		return fmt.Errorf("%w: merkle root mismatch",
+			ErrInvalidMerkleProof)
+	}
+
+	txHash := tx.TxHash()
+	var found bool
+	for _, match := range matches {
+		if match.TxHash == txHash {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: transaction %v not proven by merkle "+
+			"block", ErrInvalidMerkleProof, txHash)
+	}
+
+	// The proven block must be part of the main chain known to the chain
+	// backend.
+	blockHash := merkleBlock.Header.BlockHash()
+	height, err := fetchBlockHeight(chainClient, &blockHash)
+	if err != nil {
+		return fmt.Errorf("%w: unable to find block %v: %v",
+			ErrInvalidMerkleProof, blockHash, err)
+	}
+	mainChainHash, err := chainClient.GetBlockHash(int64(height))
+	if err != nil {
+		return err
+	}
+	if *mainChainHash != blockHash {
+		return fmt.Errorf("%w: block %v is not part of the main chain",
+			ErrInvalidMerkleProof, blockHash)
+	}
+
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+	if err != nil {
+		return err
+	}
+	block := &wtxmgr.BlockMeta{
+		Block: wtxmgr.Block{
+			Hash:   blockHash,
+			Height: height,
+		},
+		Time: merkleBlock.Header.Timestamp,
+	}
+
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+		if !w.paysToWallet(addrmgrNs, tx) {
+			return fmt.Errorf("%w: transaction %v has no wallet "+
+				"outputs", ErrNotMine, txHash)
+		}
+
+		return w.addRelevantTx(dbtx, rec, block)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Imported pruned funds of transaction %v from block %v "+
+		"(height %d)", txHash, blockHash, height)
+
+	return nil
+}
+
+// paysToWallet returns whether any of the transaction's outputs pay to an
+// address of the wallet.
+func (w *Wallet) paysToWallet(addrmgrNs walletdb.ReadBucket,
+	tx *wire.MsgTx) bool {
+
+	for _, output := range tx.TxOut {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			output.PkScript, w.chainParams,
+		)
+		if err != nil {
+			// Non-standard outputs are skipped.
+			continue
+		}
+		for _, addr := range addrs {
+			if _, err := w.Manager.Address(addrmgrNs, addr); err == nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// fetchBlockHeight returns the height of the block with the given hash
+// according to the chain backend.
+func fetchBlockHeight(chainClient chain.Interface,
+	hash *chainhash.Hash) (int32, error) {
+
+	switch client := chainClient.(type) {
+	case *chain.RPCClient:
+		header, err := client.GetBlockHeaderVerbose(hash)
+		if err != nil {
+			return 0, err
+		}
+		return header.Height, nil
+
+	case blockHeightSource:
+		return client.GetBlockHeight(hash)
+
+	default:
+		return 0, fmt.Errorf("unable to fetch block heights from %v "+
+			"backend", chainClient.BackEnd())
+	}
+}
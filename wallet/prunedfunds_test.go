@@ -0,0 +1,189 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/bloom"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// heightChainClient is a mock chain client that knows of a single block, with
+// mainChainHash being the hash of the main chain block at its height.
+type heightChainClient struct {
+	mockChainClient
+
+	hash          chainhash.Hash
+	height        int32
+	mainChainHash chainhash.Hash
+}
+
+func (c *heightChainClient) GetBlockHeight(hash *chainhash.Hash) (int32,
+	error) {
+
+	if *hash != c.hash {
+		return 0, errors.New("block not found")
+	}
+	return c.height, nil
+}
+
+func (c *heightChainClient) GetBlockHash(height int64) (*chainhash.Hash,
+	error) {
+
+	if height != int64(c.height) {
+		return nil, errors.New("block not found")
+	}
+	return &c.mainChainHash, nil
+}
+
+// TestImportPrunedFunds ensures that a wallet output can be imported given a
+// merkle proof of its transaction's inclusion within the main chain, and that
+// invalid proofs and transactions without wallet outputs are rejected.
+func TestImportPrunedFunds(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	foreignAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), w.chainParams,
+	)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	foreignScript, err := txscript.PayToAddrScript(foreignAddr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+
+	// Create a block containing a transaction paying to the wallet, along
+	// with transactions that don't.
+	newTx := func(prevHash byte, pkScript []byte) *wire.MsgTx {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(
+			&wire.OutPoint{Hash: chainhash.Hash{prevHash}}, nil, nil,
+		))
+		tx.AddTxOut(wire.NewTxOut(100000, pkScript))
+		return tx
+	}
+	otherTx := newTx(0x01, foreignScript)
+	ourTx := newTx(0x02, pkScript)
+	foreignTx := newTx(0x03, foreignScript)
+
+	msgBlock := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   1,
+			Timestamp: time.Unix(1600000000, 0),
+		},
+		Transactions: []*wire.MsgTx{otherTx, ourTx, foreignTx},
+	}
+	block := btcutil.NewBlock(msgBlock)
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions(), false)
+	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+
+	// createProof serializes a merkle block proving the inclusion of the
+	// given transactions.
+	createProof := func(txs ...*wire.MsgTx) []byte {
+		filter := bloom.NewFilter(10, 0, 0.0001, wire.BloomUpdateNone)
+		for _, tx := range txs {
+			txHash := tx.TxHash()
+			filter.AddHash(&txHash)
+		}
+		merkleBlock, _ := bloom.NewMerkleBlock(block, filter)
+
+		var b bytes.Buffer
+		err := merkleBlock.BtcEncode(
+			&b, wire.ProtocolVersion, wire.BaseEncoding,
+		)
+		if err != nil {
+			t.Fatalf("unable to serialize merkle block: %v", err)
+		}
+		return b.Bytes()
+	}
+
+	const height = 1000
+	chainClient := &heightChainClient{
+		hash:          msgBlock.BlockHash(),
+		height:        height,
+		mainChainHash: msgBlock.BlockHash(),
+	}
+	w.chainClient = chainClient
+
+	// A proof of another transaction shouldn't be accepted for ours.
+	err = w.ImportPrunedFunds(ourTx, createProof(otherTx))
+	if !errors.Is(err, ErrInvalidMerkleProof) {
+		t.Fatalf("expected ErrInvalidMerkleProof, got %v", err)
+	}
+
+	// A proof whose hashes were tampered with should be rejected. The
+	// first hash follows the header, transaction count, and hash count.
+	proof := createProof(ourTx)
+	tampered := append([]byte(nil), proof...)
+	tampered[wire.MaxBlockHeaderPayload+4+1] ^= 0xff
+	err = w.ImportPrunedFunds(ourTx, tampered)
+	if !errors.Is(err, ErrInvalidMerkleProof) {
+		t.Fatalf("expected ErrInvalidMerkleProof, got %v", err)
+	}
+
+	// A transaction without wallet outputs should be rejected.
+	err = w.ImportPrunedFunds(foreignTx, createProof(foreignTx))
+	if !errors.Is(err, ErrNotMine) {
+		t.Fatalf("expected ErrNotMine, got %v", err)
+	}
+
+	// A valid proof of our transaction should result in its output being
+	// credited at the proven height.
+	if err := w.ImportPrunedFunds(ourTx, proof); err != nil {
+		t.Fatalf("unable to import pruned funds: %v", err)
+	}
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		unspent, err := w.TxStore.UnspentOutputs(ns)
+		if err != nil {
+			return err
+		}
+		if len(unspent) != 1 {
+			t.Fatalf("expected 1 unspent output, got %d",
+				len(unspent))
+		}
+		if unspent[0].Hash != ourTx.TxHash() ||
+			unspent[0].Height != height {
+
+			t.Fatalf("expected output of %v at height %d, got %v "+
+				"at height %d", ourTx.TxHash(), height,
+				unspent[0].Hash, unspent[0].Height)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch unspent outputs: %v", err)
+	}
+
+	// Once the block is no longer part of the main chain, the proof
+	// shouldn't be accepted.
+	chainClient.mainChainHash = chainhash.Hash{0x01}
+	err = w.ImportPrunedFunds(ourTx, proof)
+	if !errors.Is(err, ErrInvalidMerkleProof) {
+		t.Fatalf("expected ErrInvalidMerkleProof, got %v", err)
+	}
+}
@@ -6,8 +6,10 @@ package wallet
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
@@ -19,6 +21,19 @@ import (
 	"github.com/btcsuite/btcwallet/wtxmgr"
 )
 
+var (
+	// ErrMissingNonWitnessUtxo is returned when signing a PSBT input that
+	// spends a legacy output without the previous transaction attached
+	// as its non-witness UTXO.
+	ErrMissingNonWitnessUtxo = errors.New("legacy input is missing its " +
+		"non-witness UTXO")
+
+	// ErrNonWitnessUtxoMismatch is returned when the non-witness UTXO of
+	// a PSBT input isn't the transaction the input spends from.
+	ErrNonWitnessUtxoMismatch = errors.New("non-witness UTXO doesn't " +
+		"match the input's outpoint")
+)
+
 // FundPsbt creates a fully populated PSBT packet that contains enough inputs to
 // fund the outputs specified in the passed in packet with the specified fee
 // rate. If there is change left, a change output from the wallet is added and
@@ -36,13 +51,25 @@ import (
 // inputs aren't enough to fund the outputs with the given fee rate, an error is
 // returned.
 //
+// The options are applied as they are to the transactions created by
+// SendOutputs. With WithEphemeralAnchor, the anchor is added to the packet's
+// outputs, and the fee rate is checked against the wallet's bounds either way.
+//
 // NOTE: A caller of the method should hold the global coin selection lock of
-// the wallet. However, no UTXO specific lock lease is acquired for any of the
-// selected/validated inputs by this method. It is in the caller's
-// responsibility to lock the inputs before handing the partial transaction out.
+// the wallet. However, unless requested through WithFundingLease, no UTXO
+// specific lock lease is acquired for any of the selected/validated inputs by
+// this method. It is otherwise in the caller's responsibility to lock the
+// inputs before handing the partial transaction out. The version of the
+// funded transaction is always the packet's, regardless of WithTxVersion.
 func (w *Wallet) FundPsbt(packet *psbt.Packet, keyScope *waddrmgr.KeyScope,
 	minConfs int32, account uint32, feeSatPerKB btcutil.Amount,
-	coinSelectionStrategy CoinSelectionStrategy) (int32, error) {
+	coinSelectionStrategy CoinSelectionStrategy,
+	optFuncs ...TxCreateOption) (int32, error) {
+
+	opts := defaultTxCreateOptions()
+	for _, optFunc := range optFuncs {
+		optFunc(opts)
+	}
 
 	// Make sure the packet is well formed. We only require there to be at
 	// least one input or output.
@@ -59,6 +86,16 @@ func (w *Wallet) FundPsbt(packet *psbt.Packet, keyScope *waddrmgr.KeyScope,
 	txOut := packet.UnsignedTx.TxOut
 	txIn := packet.UnsignedTx.TxIn
 
+	// The packet's version is the version of the funded transaction, which
+	// is validated like the ones requested through WithTxVersion. Packets
+	// without a version are funded with the default one, or as TRUC
+	// transactions if they're given an ephemeral anchor.
+	if packet.UnsignedTx.Version == 0 {
+		packet.UnsignedTx.Version = opts.txVersion
+	}
+	txVersion := packet.UnsignedTx.Version
+	optFuncs = append(optFuncs, WithTxVersion(txVersion))
+
 	// Make sure none of the outputs are dust.
 	for _, output := range txOut {
 		// When checking an output for things like dusty-ness, we'll
@@ -104,9 +141,11 @@ func (w *Wallet) FundPsbt(packet *psbt.Packet, keyScope *waddrmgr.KeyScope,
 			}
 			packet.Inputs[idx].SighashType = txscript.SigHashAll
 
-			// Include the derivation path for each input.
-			packet.Inputs[idx].Bip32Derivation = []*psbt.Bip32Derivation{
-				derivationPath,
+			// Include the derivation path for each input, if it's
+			// known.
+			if derivationPath != nil {
+				packet.Inputs[idx].Bip32Derivation =
+					[]*psbt.Bip32Derivation{derivationPath}
 			}
 
 			// We don't want to include the witness or any script
@@ -139,17 +178,20 @@ func (w *Wallet) FundPsbt(packet *psbt.Packet, keyScope *waddrmgr.KeyScope,
 		// change address creation.
 		tx, err = w.CreateSimpleTx(
 			keyScope, account, packet.UnsignedTx.TxOut, minConfs,
-			feeSatPerKB, coinSelectionStrategy, false,
+			feeSatPerKB, coinSelectionStrategy, false, optFuncs...,
 		)
 		if err != nil {
-			return 0, fmt.Errorf("error creating funding TX: %v",
+			return 0, fmt.Errorf("error creating funding TX: %w",
 				err)
 		}
 
 		// Copy over the inputs now then collect all UTXO information
 		// that we can and attach them to the PSBT as well. We don't
 		// include the witness as the resulting PSBT isn't expected not
-		// should be signed yet.
+		// should be signed yet. The version may have been raised for
+		// the relative lock-times of the selected inputs to be
+		// enforced, so it's copied over as well.
+		packet.UnsignedTx.Version = tx.Tx.Version
 		packet.UnsignedTx.TxIn = tx.Tx.TxIn
 		err = addInputInfo(tx.Tx.TxIn)
 		if err != nil {
@@ -159,6 +201,20 @@ func (w *Wallet) FundPsbt(packet *psbt.Packet, keyScope *waddrmgr.KeyScope,
 	// If there are inputs, we need to check if they're sufficient and add
 	// a change output if necessary.
 	default:
+		// The fee rate is checked against the wallet's bounds, as it
+		// is when funding through coin selection.
+		if err := w.checkTxFeeRate(feeSatPerKB, opts); err != nil {
+			return 0, err
+		}
+
+		fundedOutputs := txOut
+		if opts.ephemeralAnchor {
+			fundedOutputs, err = withEphemeralAnchor(txOut)
+			if err != nil {
+				return 0, err
+			}
+		}
+
 		// Make sure all inputs provided are actually ours.
 		err = addInputInfo(txIn)
 		if err != nil {
@@ -199,21 +255,68 @@ func (w *Wallet) FundPsbt(packet *psbt.Packet, keyScope *waddrmgr.KeyScope,
 			// selected coins. This will perform fee estimation and
 			// add a change output if necessary.
 			tx, err = txauthor.NewUnsignedTransaction(
-				txOut, feeSatPerKB, inputSource, changeSource,
+				fundedOutputs, feeSatPerKB, inputSource,
+				changeSource,
 			)
 			if err != nil {
 				return fmt.Errorf("fee estimation not "+
 					"successful: %v", err)
 			}
 
-			return nil
+			// The inputs of the authored transaction don't carry
+			// the sequences of the packet's inputs, which may have
+			// relative lock-times, so we'll validate the version
+			// against the packet's inputs instead.
+			tx.Tx.Version = txVersion
+			fundedTx := &wire.MsgTx{
+				Version: txVersion,
+				TxIn:    txIn,
+				TxOut:   tx.Tx.TxOut,
+			}
+			if opts.ephemeralAnchor {
+				_, err := ephemeralAnchorIndex(fundedTx)
+				if err != nil {
+					return err
+				}
+			}
+			err = w.checkTxVersion(
+				dbtx.ReadBucket(wtxmgrNamespaceKey), fundedTx,
+				tx.PrevScripts,
+			)
+			if err != nil {
+				return err
+			}
+
+			// Lease the packet's inputs if requested, such that
+			// they're only committed along with the change address
+			// reserved for the transaction.
+			if opts.fundingLease == nil {
+				return nil
+			}
+			return w.leaseInputs(
+				dbtx.ReadWriteBucket(wtxmgrNamespaceKey),
+				fundedTx, opts.fundingLease,
+			)
 		})
 		if err != nil {
 			return 0, fmt.Errorf("could not add change address to "+
-				"database: %v", err)
+				"database: %w", err)
 		}
 	}
 
+	// The ephemeral anchor added to the funded transaction is copied over
+	// to the PSBT as well.
+	if opts.ephemeralAnchor {
+		anchorIdx, err := ephemeralAnchorIndex(tx.Tx)
+		if err != nil {
+			return 0, err
+		}
+		packet.UnsignedTx.TxOut = append(
+			packet.UnsignedTx.TxOut, tx.Tx.TxOut[anchorIdx],
+		)
+		packet.Outputs = append(packet.Outputs, psbt.POutput{})
+	}
+
 	// If there is a change output, we need to copy it over to the PSBT now.
 	var changeTxOut *wire.TxOut
 	if tx.ChangeIndex >= 0 {
@@ -255,6 +358,11 @@ func (w *Wallet) FundPsbt(packet *psbt.Packet, keyScope *waddrmgr.KeyScope,
 // will fail. If no error is returned, the PSBT is ready to be extracted and the
 // final TX within to be broadcast.
 //
+// The wallet's legacy inputs are signed as well, as long as the previous
+// transaction is attached to them as their non-witness UTXO. Otherwise,
+// ErrMissingNonWitnessUtxo is returned. If the non-witness UTXO of an input
+// isn't the transaction it spends from, ErrNonWitnessUtxoMismatch is returned.
+//
 // NOTE: This method does NOT publish the transaction after it's been finalized
 // successfully.
 func (w *Wallet) FinalizePsbt(keyScope *waddrmgr.KeyScope, account uint32,
@@ -276,13 +384,6 @@ func (w *Wallet) FinalizePsbt(keyScope *waddrmgr.KeyScope, account uint32,
 	for idx, txIn := range tx.TxIn {
 		in := packet.Inputs[idx]
 
-		// We can only sign if we have UTXO information available. We
-		// can just continue here as a later step will fail with a more
-		// precise error message.
-		if in.WitnessUtxo == nil && in.NonWitnessUtxo == nil {
-			continue
-		}
-
 		// Skip this input if it's got final witness data attached.
 		if len(in.FinalScriptWitness) > 0 {
 			continue
@@ -298,12 +399,71 @@ func (w *Wallet) FinalizePsbt(keyScope *waddrmgr.KeyScope, account uint32,
 			continue
 		}
 
+		// We can't sign the inputs of a watch-only account, so they're
+		// left for another signer before checking whether they could
+		// be signed at all.
+		watchOnly := false
+		err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+			ns := tx.ReadBucket(waddrmgrNamespaceKey)
+			var err error
+			if keyScope == nil {
+				// If a key scope wasn't specified, then coin
+				// selection was performed from the default
+				// wallet accounts (NP2WKH, P2WKH), so any key
+				// scope provided doesn't impact the result of
+				// this call.
+				watchOnly, err = w.Manager.IsWatchOnlyAccount(
+					ns, waddrmgr.KeyScopeBIP0084, account,
+				)
+			} else {
+				watchOnly, err = w.Manager.IsWatchOnlyAccount(
+					ns, *keyScope, account,
+				)
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("unable to determine if account is "+
+				"watch-only: %v", err)
+		}
+		if watchOnly {
+			continue
+		}
+
+		// Legacy inputs commit to the amount they spend through the
+		// previous transaction alone, so it must be provided in full
+		// for the input to be signed.
+		legacy := txscript.GetScriptClass(txOut.PkScript) ==
+			txscript.PubKeyHashTy
+		switch {
+		case legacy && len(in.FinalScriptSig) > 0:
+			continue
+
+		case legacy && in.NonWitnessUtxo == nil:
+			return fmt.Errorf("%w: input %d",
+				ErrMissingNonWitnessUtxo, idx)
+		}
+
+		// We can only sign if we have UTXO information available. We
+		// can just continue here as a later step will fail with a more
+		// precise error message.
+		if in.WitnessUtxo == nil && in.NonWitnessUtxo == nil {
+			continue
+		}
+
 		// Find out what UTXO we are signing. Wallets _should_ always
 		// provide the full non-witness UTXO for segwit v0.
 		var signOutput *wire.TxOut
 		if in.NonWitnessUtxo != nil {
-			prevIndex := txIn.PreviousOutPoint.Index
-			signOutput = in.NonWitnessUtxo.TxOut[prevIndex]
+			prevTx := in.NonWitnessUtxo
+			prevOut := txIn.PreviousOutPoint
+			if prevTx.TxHash() != prevOut.Hash ||
+				int(prevOut.Index) >= len(prevTx.TxOut) {
+
+				return fmt.Errorf("%w: input %d spends %v",
+					ErrNonWitnessUtxoMismatch, idx, prevOut)
+			}
+			signOutput = prevTx.TxOut[prevOut.Index]
 
 			if !psbt.TxOutsEqual(txOut, signOutput) {
 				return fmt.Errorf("found UTXO %#v but it "+
@@ -320,7 +480,7 @@ func (w *Wallet) FinalizePsbt(keyScope *waddrmgr.KeyScope, account uint32,
 		}
 
 		// Fall back to witness UTXO only for older wallets.
-		if in.WitnessUtxo != nil {
+		if in.WitnessUtxo != nil && !legacy {
 			signOutput = in.WitnessUtxo
 
 			if !psbt.TxOutsEqual(txOut, signOutput) {
@@ -330,37 +490,8 @@ func (w *Wallet) FinalizePsbt(keyScope *waddrmgr.KeyScope, account uint32,
 			}
 		}
 
-		// Finally, if the input doesn't belong to a watch-only account,
-		// then we'll sign it as is, and populate the input with the
+		// Finally, we'll sign the input as is, and populate it with the
 		// witness and sigScript (if needed).
-		watchOnly := false
-		err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
-			ns := tx.ReadBucket(waddrmgrNamespaceKey)
-			var err error
-			if keyScope == nil {
-				// If a key scope wasn't specified, then coin
-				// selection was performed from the default
-				// wallet accounts (NP2WKH, P2WKH), so any key
-				// scope provided doesn't impact the result of
-				// this call.
-				watchOnly, err = w.Manager.IsWatchOnlyAccount(
-					ns, waddrmgr.KeyScopeBIP0084, account,
-				)
-			} else {
-				watchOnly, err = w.Manager.IsWatchOnlyAccount(
-					ns, *keyScope, account,
-				)
-			}
-			return err
-		})
-		if err != nil {
-			return fmt.Errorf("unable to determine if account is "+
-				"watch-only: %v", err)
-		}
-		if watchOnly {
-			continue
-		}
-
 		witness, sigScript, err := w.ComputeInputScript(
 			tx, signOutput, idx, sigHashes, in.SighashType, nil,
 		)
@@ -370,14 +501,18 @@ func (w *Wallet) FinalizePsbt(keyScope *waddrmgr.KeyScope, account uint32,
 		}
 
 		// Serialize the witness format from the stack representation to
-		// the wire representation.
+		// the wire representation. Legacy inputs only have a signature
+		// script.
+		packet.Inputs[idx].FinalScriptSig = sigScript
+		if legacy {
+			continue
+		}
 		var witnessBytes bytes.Buffer
 		err = psbt.WriteTxWitness(&witnessBytes, witness)
 		if err != nil {
 			return fmt.Errorf("error serializing witness: %v", err)
 		}
 		packet.Inputs[idx].FinalScriptWitness = witnessBytes.Bytes()
-		packet.Inputs[idx].FinalScriptSig = sigScript
 	}
 
 	// Make sure the PSBT itself thinks it's finalized and ready to be
@@ -390,6 +525,135 @@ func (w *Wallet) FinalizePsbt(keyScope *waddrmgr.KeyScope, account uint32,
 	return nil
 }
 
+// TxToPsbt reconstructs the PSBT of a transaction stored within the wallet,
+// such as one created and published by the wallet itself, for record-keeping
+// or to be handed to co-signers.
+//
+// The UTXO information of the inputs spending outputs known to the wallet is
+// included, and the BIP32 derivation of the keys is included for the inputs and
+// outputs belonging to the wallet. The signatures of the transaction are
+// included as the final scripts of their inputs, such that the PSBT of a fully
+// signed transaction, confirmed or not, is finalized and extracts to the stored
+// transaction. ErrTxNotFound is returned if the transaction isn't known to the
+// wallet.
+func (w *Wallet) TxToPsbt(txHash chainhash.Hash) (*psbt.Packet, error) {
+	details, err := UnstableAPI(w).TxDetails(&txHash)
+	if err != nil {
+		return nil, err
+	}
+	if details == nil {
+		return nil, fmt.Errorf("%w: %v", ErrTxNotFound, txHash)
+	}
+	signedTx := &details.MsgTx
+
+	// The PSBT is created from a copy of the transaction stripped of its
+	// signatures, which are added back as final scripts.
+	unsignedTx := signedTx.Copy()
+	for _, txIn := range unsignedTx.TxIn {
+		txIn.SignatureScript = nil
+		txIn.Witness = nil
+	}
+	packet, err := psbt.NewFromUnsignedTx(unsignedTx)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, txIn := range signedTx.TxIn {
+		in := &packet.Inputs[idx]
+
+		if err := w.addPsbtInputInfo(in, txIn.PreviousOutPoint); err != nil {
+			return nil, err
+		}
+
+		if len(txIn.Witness) > 0 {
+			var witness bytes.Buffer
+			err := psbt.WriteTxWitness(&witness, txIn.Witness)
+			if err != nil {
+				return nil, fmt.Errorf("error serializing "+
+					"witness: %v", err)
+			}
+			in.FinalScriptWitness = witness.Bytes()
+		}
+		if len(txIn.SignatureScript) > 0 {
+			in.FinalScriptSig = txIn.SignatureScript
+		}
+	}
+
+	for idx, txOut := range signedTx.TxOut {
+		addr, err := w.fetchOutputAddr(txOut.PkScript)
+		if err != nil {
+			continue
+		}
+		pubKeyAddr, ok := addr.(waddrmgr.ManagedPubKeyAddress)
+		if !ok {
+			continue
+		}
+
+		out := &packet.Outputs[idx]
+		out.Bip32Derivation = bip32Derivations(pubKeyAddr)
+		if pubKeyAddr.AddrType() == waddrmgr.NestedWitnessPubKey {
+			_, witnessProgram, _, err := w.scriptForOutput(txOut)
+			if err != nil {
+				return nil, fmt.Errorf("error fetching output "+
+					"script: %v", err)
+			}
+			out.RedeemScript = witnessProgram
+		}
+	}
+
+	return packet, nil
+}
+
+// addPsbtInputInfo attaches the information known to the wallet about the
+// output spent by a PSBT input. Nothing is attached for outputs of transactions
+// unknown to the wallet, and only the UTXO information is attached for outputs
+// not belonging to it.
+func (w *Wallet) addPsbtInputInfo(in *psbt.PInput,
+	prevOut wire.OutPoint) error {
+
+	prevDetails, err := UnstableAPI(w).TxDetails(&prevOut.Hash)
+	if err != nil {
+		return err
+	}
+	if prevDetails == nil ||
+		int(prevOut.Index) >= len(prevDetails.MsgTx.TxOut) {
+
+		return nil
+	}
+	utxo := prevDetails.MsgTx.TxOut[prevOut.Index]
+
+	// As with FundPsbt, the full non-witness UTXO is always included as a
+	// fix for CVE-2020-14199.
+	in.NonWitnessUtxo = &prevDetails.MsgTx
+
+	addr, err := w.fetchOutputAddr(utxo.PkScript)
+	if err != nil {
+		return nil
+	}
+	pubKeyAddr, ok := addr.(waddrmgr.ManagedPubKeyAddress)
+	if !ok {
+		return nil
+	}
+
+	switch pubKeyAddr.AddrType() {
+	case waddrmgr.WitnessPubKey:
+		in.WitnessUtxo = utxo
+
+	case waddrmgr.NestedWitnessPubKey:
+		in.WitnessUtxo = utxo
+
+		_, witnessProgram, _, err := w.scriptForOutput(utxo)
+		if err != nil {
+			return fmt.Errorf("error fetching UTXO script: %v", err)
+		}
+		in.RedeemScript = witnessProgram
+	}
+	in.SighashType = txscript.SigHashAll
+	in.Bip32Derivation = bip32Derivations(pubKeyAddr)
+
+	return nil
+}
+
 // constantInputSource creates an input source function that always returns the
 // static set of user-selected UTXOs.
 func constantInputSource(eligible []wtxmgr.Credit) txauthor.InputSource {
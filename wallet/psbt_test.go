@@ -7,14 +7,21 @@ package wallet
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
 	"github.com/btcsuite/btcutil/psbt"
 	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
 )
 
 var (
@@ -77,6 +84,7 @@ func TestFundPsbt(t *testing.T) {
 		name             string
 		packet           *psbt.Packet
 		feeRateSatPerKB  btcutil.Amount
+		opts             []TxCreateOption
 		expectedErr      string
 		validatePackage  bool
 		expectedFee      int64
@@ -105,6 +113,93 @@ func TestFundPsbt(t *testing.T) {
 		expectedInputs:  []wire.OutPoint{utxo1},
 		expectedFee:     2200,
 		expectedChange:  997800,
+	}, {
+		name: "single input, fee rate out of bounds",
+		packet: &psbt.Packet{
+			UnsignedTx: &wire.MsgTx{
+				TxIn: []*wire.TxIn{{
+					PreviousOutPoint: utxo1,
+				}},
+			},
+			Inputs: []psbt.PInput{{}},
+		},
+		feeRateSatPerKB: DefaultFeeRateBounds.Max + 1,
+		expectedErr:     ErrFeeRateOutOfBounds.Error(),
+	}, {
+		name: "single input, fee rate bounds overridden",
+		packet: &psbt.Packet{
+			UnsignedTx: &wire.MsgTx{
+				TxIn: []*wire.TxIn{{
+					PreviousOutPoint: utxo1,
+				}},
+			},
+			Inputs: []psbt.PInput{{}},
+		},
+		feeRateSatPerKB: DefaultFeeRateBounds.Max + 1,
+		opts:            []TxCreateOption{WithFeeRateBoundsOverride()},
+		validatePackage: true,
+		expectedInputs:  []wire.OutPoint{utxo1},
+		expectedFee:     110000,
+		expectedChange:  890000,
+	}, {
+		name: "single input, ephemeral anchor",
+		packet: &psbt.Packet{
+			UnsignedTx: &wire.MsgTx{
+				TxIn: []*wire.TxIn{{
+					PreviousOutPoint: utxo1,
+				}},
+			},
+			Inputs: []psbt.PInput{{}},
+		},
+		feeRateSatPerKB: 0,
+		opts:            []TxCreateOption{WithEphemeralAnchor()},
+		validatePackage: true,
+		expectedInputs:  []wire.OutPoint{utxo1},
+		expectedFee:     0,
+		expectedChange:  1000000,
+		additionalChecks: func(t *testing.T, packet *psbt.Packet,
+			changeIndex int32) {
+
+			_, err := ephemeralAnchorIndex(packet.UnsignedTx)
+			if err != nil {
+				t.Fatalf("unexpected ephemeral anchor: %v",
+					err)
+			}
+			if len(packet.Outputs) != 2 {
+				t.Fatalf("unexpected outputs, got %d wanted "+
+					"2", len(packet.Outputs))
+			}
+		},
+	}, {
+		name: "two outputs, no inputs, ephemeral anchor",
+		packet: &psbt.Packet{
+			UnsignedTx: &wire.MsgTx{
+				TxOut: []*wire.TxOut{{
+					PkScript: testScriptP2WSH,
+					Value:    100000,
+				}},
+			},
+			Outputs: []psbt.POutput{{}},
+		},
+		feeRateSatPerKB: 0,
+		opts:            []TxCreateOption{WithEphemeralAnchor()},
+		validatePackage: true,
+		expectedInputs:  []wire.OutPoint{utxo1},
+		expectedFee:     0,
+		expectedChange:  1000000 - 100000,
+		additionalChecks: func(t *testing.T, packet *psbt.Packet,
+			changeIndex int32) {
+
+			_, err := ephemeralAnchorIndex(packet.UnsignedTx)
+			if err != nil {
+				t.Fatalf("unexpected ephemeral anchor: %v",
+					err)
+			}
+			if len(packet.Outputs) != 3 {
+				t.Fatalf("unexpected outputs, got %d wanted "+
+					"3", len(packet.Outputs))
+			}
+		},
 	}, {
 		name: "no dust outputs",
 		packet: &psbt.Packet{
@@ -235,7 +330,7 @@ func TestFundPsbt(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			changeIndex, err := w.FundPsbt(
 				tc.packet, nil, 1, 0, tc.feeRateSatPerKB,
-				CoinSelectionLargest,
+				CoinSelectionLargest, tc.opts...,
 			)
 
 			// In any case, unlock the UTXO before continuing, we
@@ -425,3 +520,347 @@ func TestFinalizePsbt(t *testing.T) {
 		t.Fatalf("error validating tx: %v", err)
 	}
 }
+
+// TestFinalizePsbtLegacyInput tests that a legacy input of the wallet is only
+// signed if the PSBT carries the previous transaction it spends from as its
+// non-witness UTXO.
+func TestFinalizePsbtLegacyInput(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0044)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", err)
+	}
+	p2pkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2pkh: %v", err)
+	}
+
+	utxOutP2PKH := wire.NewTxOut(1000000, p2pkhAddr)
+	incomingTx := &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{utxOutP2PKH},
+	}
+	addUtxo(t, w, incomingTx)
+
+	// newPacket returns a packet spending the legacy output with the
+	// given UTXO information attached.
+	newPacket := func(in psbt.PInput) *psbt.Packet {
+		in.SighashType = txscript.SigHashAll
+		return &psbt.Packet{
+			UnsignedTx: &wire.MsgTx{
+				Version: 2,
+				TxIn: []*wire.TxIn{{
+					PreviousOutPoint: wire.OutPoint{
+						Hash: incomingTx.TxHash(),
+					},
+				}},
+				TxOut: []*wire.TxOut{{
+					PkScript: testScriptP2WKH,
+					Value:    990000,
+				}},
+			},
+			Inputs:  []psbt.PInput{in},
+			Outputs: []psbt.POutput{{}},
+		}
+	}
+
+	// The input can't be signed with its witness UTXO alone.
+	packet := newPacket(psbt.PInput{WitnessUtxo: utxOutP2PKH})
+	err = w.FinalizePsbt(nil, 0, packet)
+	if !errors.Is(err, ErrMissingNonWitnessUtxo) {
+		t.Fatalf("expected ErrMissingNonWitnessUtxo, got %v", err)
+	}
+
+	// Nor with a non-witness UTXO other than the transaction it spends
+	// from.
+	otherTx := incomingTx.Copy()
+	otherTx.LockTime++
+	packet = newPacket(psbt.PInput{NonWitnessUtxo: otherTx})
+	err = w.FinalizePsbt(nil, 0, packet)
+	if !errors.Is(err, ErrNonWitnessUtxoMismatch) {
+		t.Fatalf("expected ErrNonWitnessUtxoMismatch, got %v", err)
+	}
+
+	// With the full previous transaction, the input is signed through its
+	// signature script alone.
+	packet = newPacket(psbt.PInput{NonWitnessUtxo: incomingTx})
+	if err := w.FinalizePsbt(nil, 0, packet); err != nil {
+		t.Fatalf("error finalizing PSBT packet: %v", err)
+	}
+	if len(packet.Inputs[0].FinalScriptWitness) != 0 {
+		t.Fatalf("legacy input has witness data")
+	}
+	finalTx, err := psbt.Extract(packet)
+	if err != nil {
+		t.Fatalf("error extracting final TX from PSBT: %v", err)
+	}
+
+	err = validateMsgTx(
+		finalTx, [][]byte{utxOutP2PKH.PkScript},
+		[]btcutil.Amount{1000000},
+	)
+	if err != nil {
+		t.Fatalf("error validating tx: %v", err)
+	}
+
+	// The legacy inputs signed for a watch-only account are left for
+	// another signer, even without their non-witness UTXO.
+	seed, err := hdkeychain.GenerateSeed(hdkeychain.MinSeedBytes)
+	if err != nil {
+		t.Fatalf("unable to generate seed: %v", err)
+	}
+	root, err := hdkeychain.NewMaster(seed, w.chainParams)
+	if err != nil {
+		t.Fatalf("unable to create master key: %v", err)
+	}
+	addrType := waddrmgr.WitnessPubKey
+	acctPub := deriveAcctPubKey(
+		t, root, waddrmgr.KeyScopeBIP0084, hardenedKey(0),
+	)
+	acct, err := w.ImportAccount(
+		"watch-only", acctPub, root.ParentFingerprint(), &addrType,
+	)
+	if err != nil {
+		t.Fatalf("unable to import account: %v", err)
+	}
+
+	packet = newPacket(psbt.PInput{WitnessUtxo: utxOutP2PKH})
+	err = w.FinalizePsbt(
+		&waddrmgr.KeyScopeBIP0084, acct.AccountNumber, packet,
+	)
+	if errors.Is(err, ErrMissingNonWitnessUtxo) {
+		t.Fatalf("expected watch-only input to be skipped, got %v", err)
+	}
+	if len(packet.Inputs[0].FinalScriptSig) != 0 {
+		t.Fatalf("watch-only input was signed")
+	}
+}
+
+// TestTxToPsbt ensures that the PSBT reconstructed from a transaction created
+// by the wallet contains the information known to the wallet, and extracts to
+// the original transaction whether it's confirmed or not.
+func TestTxToPsbt(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	// Fund the wallet with a P2WKH and a nested P2WKH output, both of
+	// which will be spent.
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", err)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addr, err = w.CurrentAddress(0, waddrmgr.KeyScopeBIP0049Plus)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", err)
+	}
+	np2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to np2wkh: %v", err)
+	}
+	incomingTx := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{
+			wire.NewTxOut(1000000, p2wkhAddr),
+			wire.NewTxOut(1000000, np2wkhAddr),
+		},
+	}
+	addUtxo(t, w, incomingTx)
+
+	// Unknown transactions can't be converted.
+	_, err = w.TxToPsbt(chainhash.Hash{0x01})
+	if !errors.Is(err, ErrTxNotFound) {
+		t.Fatalf("expected ErrTxNotFound, got %v", err)
+	}
+
+	tx, err := w.SendOutputs(
+		[]*wire.TxOut{wire.NewTxOut(1500000, testScriptP2WKH)}, nil, 0,
+		1, 1000, CoinSelectionLargest, "",
+	)
+	if err != nil {
+		t.Fatalf("unable to send outputs: %v", err)
+	}
+	if len(tx.TxIn) != 2 {
+		t.Fatalf("expected both outputs to be spent, got %d inputs",
+			len(tx.TxIn))
+	}
+
+	checkPsbt := func(packet *psbt.Packet) {
+		t.Helper()
+
+		// Every input spends an output of the wallet, so all of them
+		// should carry the spent output and the key's derivation.
+		for idx, txIn := range packet.UnsignedTx.TxIn {
+			in := packet.Inputs[idx]
+			prevOut := incomingTx.TxOut[txIn.PreviousOutPoint.Index]
+			if in.NonWitnessUtxo == nil ||
+				in.NonWitnessUtxo.TxHash() != incomingTx.TxHash() {
+
+				t.Fatalf("input %d: missing non-witness UTXO",
+					idx)
+			}
+			if in.WitnessUtxo == nil ||
+				!psbt.TxOutsEqual(in.WitnessUtxo, prevOut) {
+
+				t.Fatalf("input %d: expected witness UTXO %v, "+
+					"got %v", idx, prevOut, in.WitnessUtxo)
+			}
+			if len(in.Bip32Derivation) != 1 {
+				t.Fatalf("input %d: missing BIP32 derivation",
+					idx)
+			}
+			nested := bytes.Equal(prevOut.PkScript, np2wkhAddr)
+			if nested != (len(in.RedeemScript) > 0) {
+				t.Fatalf("input %d: expected redeem script: "+
+					"%v, got %x", idx, nested,
+					in.RedeemScript)
+			}
+		}
+
+		// Only the change output belongs to the wallet.
+		for idx, txOut := range packet.UnsignedTx.TxOut {
+			isChange := !bytes.Equal(txOut.PkScript, testScriptP2WKH)
+			out := packet.Outputs[idx]
+			if isChange != (len(out.Bip32Derivation) == 1) {
+				t.Fatalf("output %d: expected BIP32 derivation: "+
+					"%v, got %v", idx, isChange,
+					out.Bip32Derivation)
+			}
+		}
+
+		// The PSBT should survive serialization, and extract to the
+		// signed transaction.
+		encoded, err := packet.B64Encode()
+		if err != nil {
+			t.Fatalf("unable to encode PSBT: %v", err)
+		}
+		decoded, err := psbt.NewFromRawBytes(
+			strings.NewReader(encoded), true,
+		)
+		if err != nil {
+			t.Fatalf("unable to decode PSBT: %v", err)
+		}
+		if !decoded.IsComplete() {
+			t.Fatal("expected PSBT to be finalized")
+		}
+		extracted, err := psbt.Extract(decoded)
+		if err != nil {
+			t.Fatalf("unable to extract transaction: %v", err)
+		}
+
+		var expected, got bytes.Buffer
+		if err := tx.Serialize(&expected); err != nil {
+			t.Fatalf("unable to serialize transaction: %v", err)
+		}
+		if err := extracted.Serialize(&got); err != nil {
+			t.Fatalf("unable to serialize transaction: %v", err)
+		}
+		if !bytes.Equal(expected.Bytes(), got.Bytes()) {
+			t.Fatalf("expected extracted transaction %x, got %x",
+				expected.Bytes(), got.Bytes())
+		}
+	}
+
+	packet, err := w.TxToPsbt(tx.TxHash())
+	if err != nil {
+		t.Fatalf("unable to convert transaction to PSBT: %v", err)
+	}
+	checkPsbt(packet)
+
+	// Once confirmed, the transaction should still convert to a finalized
+	// PSBT.
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+	if err != nil {
+		t.Fatalf("unable to create tx record: %v", err)
+	}
+	block := &wtxmgr.BlockMeta{
+		Block: wtxmgr.Block{
+			Hash:   chainhash.Hash{0x02},
+			Height: testBlockHeight + 1,
+		},
+		Time: time.Now(),
+	}
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		ns := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+		return w.TxStore.InsertTx(ns, rec, block)
+	})
+	if err != nil {
+		t.Fatalf("unable to confirm transaction: %v", err)
+	}
+
+	packet, err = w.TxToPsbt(tx.TxHash())
+	if err != nil {
+		t.Fatalf("unable to convert transaction to PSBT: %v", err)
+	}
+	checkPsbt(packet)
+}
+
+// TestTxToPsbtImportedOutput ensures that outputs paying to imported keys,
+// whose derivation isn't known, carry no BIP32 derivation in the reconstructed
+// PSBT.
+func TestTxToPsbtImportedOutput(t *testing.T) {
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.CurrentAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to get current address: %v", err)
+	}
+	p2wkhAddr, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to convert wallet address to p2wkh: %v", err)
+	}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(1000000, p2wkhAddr)},
+	})
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("unable to create key: %v", err)
+	}
+	err = w.ImportPublicKey(privKey.PubKey(), waddrmgr.WitnessPubKey)
+	if err != nil {
+		t.Fatalf("unable to import public key: %v", err)
+	}
+	importedAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		btcutil.Hash160(privKey.PubKey().SerializeCompressed()),
+		w.chainParams,
+	)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	importedScript, err := txscript.PayToAddrScript(importedAddr)
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+
+	tx, err := w.SendOutputs(
+		[]*wire.TxOut{wire.NewTxOut(500000, importedScript)}, nil, 0,
+		1, 1000, CoinSelectionLargest, "",
+	)
+	if err != nil {
+		t.Fatalf("unable to send outputs: %v", err)
+	}
+
+	packet, err := w.TxToPsbt(tx.TxHash())
+	if err != nil {
+		t.Fatalf("unable to convert transaction to PSBT: %v", err)
+	}
+	for idx, txOut := range packet.UnsignedTx.TxOut {
+		imported := bytes.Equal(txOut.PkScript, importedScript)
+		out := packet.Outputs[idx]
+		if imported == (len(out.Bip32Derivation) == 1) {
+			t.Fatalf("output %d: unexpected BIP32 derivation %v",
+				idx, out.Bip32Derivation)
+		}
+	}
+	if _, err := packet.B64Encode(); err != nil {
+		t.Fatalf("unable to encode PSBT: %v", err)
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// ErrMempoolUnavailable is returned when attempting to reconcile the wallet's
+// unconfirmed transactions with a chain backend that has no mempool.
+var ErrMempoolUnavailable = errors.New("chain backend has no mempool")
+
+// mempoolSource is implemented by chain backends able to list the hashes of
+// the transactions within their mempool.
+type mempoolSource interface {
+	GetRawMempool() ([]*chainhash.Hash, error)
+}
+
+// mempoolSpenderSource is implemented by chain backends able to look up the
+// transaction within their mempool spending an outpoint.
+type mempoolSpenderSource interface {
+	GetMempoolSpender(op wire.OutPoint) (*wire.MsgTx, error)
+}
+
+// SkipMempoolReconciliation configures the wallet to not reconcile its
+// unconfirmed transactions against the chain backend's mempool once synced at
+// startup. Reconciliation is always skipped for backends without a mempool,
+// such as neutrino.
+//
+// NOTE: This must be called before the wallet is started.
+func (w *Wallet) SkipMempoolReconciliation() {
+	w.skipMempoolReconciliation = true
+}
+
+// ReconcileMempool abandons the wallet's unconfirmed transactions that aren't
+// within the chain backend's mempool, such as those evicted while the wallet
+// was down, along with any transactions spending them. The inputs of the
+// abandoned transactions are freed, and their outputs no longer count towards
+// the wallet's unconfirmed balance. The hashes of the abandoned transactions
+// are returned.
+//
+// If the backend is able to look up the spenders of outpoints within its
+// mempool, transactions replaced by another spending some of the same inputs
+// are told apart from evicted ones. The replacement is added to the wallet in
+// place of the replaced transaction, the replacement is recorded such that it
+// can be looked up through TxReplacement, and a TxReplaced notification is
+// sent. Replaced transactions aren't part of the returned hashes.
+//
+// This should only be called once the wallet is synced to the chain, as
+// otherwise unconfirmed transactions that have since confirmed would be
+// abandoned as well.
+func (w *Wallet) ReconcileMempool() ([]chainhash.Hash, error) {
+	chainClient, done, err := w.useChainClient()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	source, ok := chainClient.(mempoolSource)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrMempoolUnavailable,
+			chainClient.BackEnd())
+	}
+
+	// We'll fetch the unconfirmed transactions before the mempool, such
+	// that any transaction published in the meantime is already known to
+	// the backend.
+	var txs []*wire.MsgTx
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		var err error
+		txs, err = w.TxStore.UnminedTxs(txmgrNs)
+		return err
+	})
+	if err != nil || len(txs) == 0 {
+		return nil, err
+	}
+
+	mempoolHashes, err := source.GetRawMempool()
+	if err != nil {
+		return nil, err
+	}
+	mempool := make(map[chainhash.Hash]struct{}, len(mempoolHashes))
+	for _, hash := range mempoolHashes {
+		mempool[*hash] = struct{}{}
+	}
+
+	replacements, err := findMempoolReplacements(chainClient, txs, mempool)
+	if err != nil {
+		return nil, err
+	}
+
+	var abandoned, replaced []*wire.MsgTx
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		txmgrNs := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+
+		for _, tx := range txs {
+			txHash := tx.TxHash()
+			if _, ok := mempool[txHash]; ok {
+				continue
+			}
+
+			// The transaction may have already been removed as a
+			// descendant of another, or confirmed in the meantime.
+			details, err := w.TxStore.TxDetails(txmgrNs, &txHash)
+			if err != nil {
+				return err
+			}
+			if details == nil || details.Block.Height != -1 {
+				continue
+			}
+
+			err = w.TxStore.DiscardUnminedTx(
+				txmgrNs, &details.TxRecord,
+			)
+			if err != nil {
+				return err
+			}
+
+			replacement, ok := replacements[txHash]
+			if !ok {
+				abandoned = append(abandoned, tx)
+				continue
+			}
+
+			// The replacement may have already been added along
+			// with another transaction it replaced.
+			replacementHash := replacement.TxHash()
+			err = w.TxStore.PutTxReplacement(
+				txmgrNs, txHash, replacementHash,
+			)
+			if err != nil {
+				return err
+			}
+			replaced = append(replaced, tx)
+
+			existing, err := w.TxStore.TxDetails(
+				txmgrNs, &replacementHash,
+			)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				continue
+			}
+			rec, err := wtxmgr.NewTxRecordFromMsgTx(
+				replacement, time.Now(),
+			)
+			if err != nil {
+				return err
+			}
+			if err := w.addRelevantTx(dbtx, rec, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]chainhash.Hash, 0, len(abandoned))
+	for _, tx := range abandoned {
+		w.releaseInputs(tx)
+		hashes = append(hashes, tx.TxHash())
+
+		log.Infof("Abandoned unconfirmed transaction %v missing from "+
+			"the mempool", tx.TxHash())
+	}
+	for _, tx := range replaced {
+		w.releaseInputs(tx)
+
+		txHash := tx.TxHash()
+		w.notifyTxReplaced(txHash, replacements[txHash].TxHash())
+	}
+
+	return hashes, nil
+}
+
+// findMempoolReplacements returns the transactions within the mempool that
+// replaced the given unconfirmed transactions missing from it, keyed by the
+// hash of the transaction they replaced. No replacements are returned if the
+// chain backend is unable to look up the spenders of outpoints within its
+// mempool, in which case the missing transactions are considered evicted.
+func findMempoolReplacements(chainClient chain.Interface, txs []*wire.MsgTx,
+	mempool map[chainhash.Hash]struct{}) (map[chainhash.Hash]*wire.MsgTx,
+	error) {
+
+	replacements := make(map[chainhash.Hash]*wire.MsgTx)
+
+	source, ok := chainClient.(mempoolSpenderSource)
+	if !ok {
+		return replacements, nil
+	}
+
+	for _, tx := range txs {
+		txHash := tx.TxHash()
+		if _, ok := mempool[txHash]; ok {
+			continue
+		}
+
+		for _, txIn := range tx.TxIn {
+			spender, err := source.GetMempoolSpender(
+				txIn.PreviousOutPoint,
+			)
+			if err != nil {
+				log.Debugf("Unable to look up mempool spender "+
+					"of %v, considering missing "+
+					"transactions evicted: %v",
+					txIn.PreviousOutPoint, err)
+				return make(map[chainhash.Hash]*wire.MsgTx), nil
+			}
+			if spender == nil || spender.TxHash() == txHash {
+				continue
+			}
+
+			replacements[txHash] = spender
+			break
+		}
+	}
+
+	return replacements, nil
+}
+
+// recordTxReplacements records the transaction notified by the chain backend,
+// if it's unconfirmed and thus from its mempool, as the replacement of the
+// wallet's unconfirmed transactions spending some of the same inputs, as it
+// could only have entered the mempool by replacing them. The hashes of the
+// replaced transactions are returned, none being returned if the transaction
+// is already known to the wallet.
+func (w *Wallet) recordTxReplacements(dbtx walletdb.ReadWriteTx,
+	rec *wtxmgr.TxRecord, block *wtxmgr.BlockMeta) ([]chainhash.Hash,
+	error) {
+
+	if block != nil {
+		return nil, nil
+	}
+
+	txmgrNs := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+
+	details, err := w.TxStore.TxDetails(txmgrNs, &rec.Hash)
+	if err != nil || details != nil {
+		return nil, err
+	}
+
+	replaced := w.TxStore.UnminedConflicts(txmgrNs, rec)
+	for _, txHash := range replaced {
+		err := w.TxStore.PutTxReplacement(txmgrNs, txHash, rec.Hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return replaced, nil
+}
+
+// notifyTxReplaced sends a TxReplaced notification for the wallet's
+// unconfirmed transaction replaced by another.
+func (w *Wallet) notifyTxReplaced(txHash, replacementHash chainhash.Hash) {
+	log.Infof("Unconfirmed transaction %v was replaced by %v", txHash,
+		replacementHash)
+
+	w.NtfnServer.notifyTxReplaced(&TxReplaced{
+		OldTxHash: txHash,
+		NewTxHash: replacementHash,
+	})
+}
+
+// TxReplacement returns the hash of the transaction that replaced the wallet's
+// unconfirmed transaction with the given hash, as found when reconciling the
+// wallet's unconfirmed transactions against the mempool, bumping its fee, or
+// receiving a conflicting transaction from the mempool, or nil if it's not
+// known to have been replaced.
+func (w *Wallet) TxReplacement(txHash chainhash.Hash) (*chainhash.Hash,
+	error) {
+
+	var replacement *chainhash.Hash
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		var err error
+		replacement, err = w.TxStore.TxReplacement(txmgrNs, txHash)
+		return err
+	})
+	return replacement, err
+}
+
+// reconcileMempoolAtStartup reconciles the wallet's unconfirmed transactions
+// against the chain backend's mempool, unless configured not to or the
+// backend has no mempool.
+func (w *Wallet) reconcileMempoolAtStartup() {
+	if w.skipMempoolReconciliation {
+		return
+	}
+
+	_, err := w.ReconcileMempool()
+	switch {
+	case errors.Is(err, ErrMempoolUnavailable):
+		log.Debugf("Skipping mempool reconciliation: %v", err)
+
+	case err != nil:
+		log.Errorf("Unable to reconcile unconfirmed transactions with "+
+			"the mempool: %v", err)
+	}
+}
@@ -0,0 +1,418 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// mempoolChainClient is a mock chain client keeping the transactions sent
+// through it within its mempool.
+type mempoolChainClient struct {
+	mockChainClient
+
+	mempool map[chainhash.Hash]struct{}
+}
+
+func (c *mempoolChainClient) SendRawTransaction(tx *wire.MsgTx,
+	_ bool) (*chainhash.Hash, error) {
+
+	txHash := tx.TxHash()
+	c.mempool[txHash] = struct{}{}
+	return &txHash, nil
+}
+
+func (c *mempoolChainClient) GetRawMempool() ([]*chainhash.Hash, error) {
+	hashes := make([]*chainhash.Hash, 0, len(c.mempool))
+	for hash := range c.mempool {
+		hash := hash
+		hashes = append(hashes, &hash)
+	}
+	return hashes, nil
+}
+
+// TestReconcileMempool ensures that unconfirmed transactions missing from the
+// chain backend's mempool are abandoned, correcting the wallet's unconfirmed
+// balance, while those within it are kept.
+func TestReconcileMempool(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	// Reconciling with a backend without a mempool isn't possible.
+	_, err := w.ReconcileMempool()
+	if !errors.Is(err, ErrMempoolUnavailable) {
+		t.Fatalf("expected ErrMempoolUnavailable, got %v", err)
+	}
+
+	chainClient := &mempoolChainClient{
+		mempool: make(map[chainhash.Hash]struct{}),
+	}
+	w.chainClient = chainClient
+
+	// Fund the wallet with two confirmed outputs, such that two
+	// independent unconfirmed transactions can be created.
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	fundingTx := wire.NewMsgTx(wire.TxVersion)
+	fundingTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	fundingTx.AddTxOut(wire.NewTxOut(btcutil.SatoshiPerBitcoin, pkScript))
+	fundingTx.AddTxOut(wire.NewTxOut(btcutil.SatoshiPerBitcoin, pkScript))
+	addUtxo(t, w, fundingTx)
+	setSyncedHeight(t, w, testBlockHeight)
+
+	destAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), w.chainParams,
+	)
+	if err != nil {
+		t.Fatalf("unable to create destination address: %v", err)
+	}
+	destScript, err := txscript.PayToAddrScript(destAddr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	send := func() *wire.MsgTx {
+		t.Helper()
+
+		tx, err := w.SendOutputs(
+			[]*wire.TxOut{wire.NewTxOut(10000000, destScript)},
+			&waddrmgr.KeyScopeBIP0084, 0, 1, 1000,
+			CoinSelectionLargest, "",
+		)
+		if err != nil {
+			t.Fatalf("unable to send outputs: %v", err)
+		}
+		return tx
+	}
+	keptTx := send()
+	phantomTx := send()
+
+	// We'll simulate the phantom transaction being evicted from the
+	// mempool while the wallet was down.
+	delete(chainClient.mempool, phantomTx.TxHash())
+
+	balanceBefore, err := w.CalculateBalance(0)
+	if err != nil {
+		t.Fatalf("unable to calculate balance: %v", err)
+	}
+
+	abandoned, err := w.ReconcileMempool()
+	if err != nil {
+		t.Fatalf("unable to reconcile mempool: %v", err)
+	}
+	if len(abandoned) != 1 || abandoned[0] != phantomTx.TxHash() {
+		t.Fatalf("expected %v to be abandoned, got %v",
+			phantomTx.TxHash(), abandoned)
+	}
+
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		for _, tx := range []*wire.MsgTx{keptTx, phantomTx} {
+			txHash := tx.TxHash()
+			details, err := w.TxStore.TxDetails(ns, &txHash)
+			if err != nil {
+				return err
+			}
+			kept := txHash == keptTx.TxHash()
+			if kept != (details != nil) {
+				t.Fatalf("expected %v to be kept: %v, got %v",
+					txHash, kept, details != nil)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The output spent by the phantom transaction should count towards
+	// the balance again, in place of the phantom's change.
+	var phantomChange btcutil.Amount
+	for _, txOut := range phantomTx.TxOut {
+		if !bytes.Equal(txOut.PkScript, destScript) {
+			phantomChange += btcutil.Amount(txOut.Value)
+		}
+	}
+	balanceAfter, err := w.CalculateBalance(0)
+	if err != nil {
+		t.Fatalf("unable to calculate balance: %v", err)
+	}
+	expected := balanceBefore - phantomChange + btcutil.SatoshiPerBitcoin
+	if balanceAfter != expected {
+		t.Fatalf("expected balance of %v after reconciling, got %v",
+			expected, balanceAfter)
+	}
+
+	// Reconciling again should leave the remaining transaction untouched.
+	abandoned, err = w.ReconcileMempool()
+	if err != nil {
+		t.Fatalf("unable to reconcile mempool: %v", err)
+	}
+	if len(abandoned) != 0 {
+		t.Fatalf("expected no transactions to be abandoned, got %v",
+			abandoned)
+	}
+}
+
+// spenderChainClient is a mock chain client able to look up the spenders of
+// outpoints within its mempool.
+type spenderChainClient struct {
+	mempoolChainClient
+
+	txs map[chainhash.Hash]*wire.MsgTx
+}
+
+func (c *spenderChainClient) SendRawTransaction(tx *wire.MsgTx,
+	allowHighFees bool) (*chainhash.Hash, error) {
+
+	c.txs[tx.TxHash()] = tx
+	return c.mempoolChainClient.SendRawTransaction(tx, allowHighFees)
+}
+
+func (c *spenderChainClient) GetMempoolSpender(
+	op wire.OutPoint) (*wire.MsgTx, error) {
+
+	for hash := range c.mempool {
+		tx := c.txs[hash]
+		for _, txIn := range tx.TxIn {
+			if txIn.PreviousOutPoint == op {
+				return tx, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// TestReconcileMempoolReplacement ensures that unconfirmed transactions
+// replaced within the mempool are told apart from evicted ones, with their
+// replacement being added to the wallet, recorded, and notified.
+func TestReconcileMempoolReplacement(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	chainClient := &spenderChainClient{
+		mempoolChainClient: mempoolChainClient{
+			mempool: make(map[chainhash.Hash]struct{}),
+		},
+		txs: make(map[chainhash.Hash]*wire.MsgTx),
+	}
+	w.chainClient = chainClient
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	fundingTx := wire.NewMsgTx(wire.TxVersion)
+	fundingTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	fundingTx.AddTxOut(wire.NewTxOut(btcutil.SatoshiPerBitcoin, pkScript))
+	fundingTx.AddTxOut(wire.NewTxOut(btcutil.SatoshiPerBitcoin, pkScript))
+	addUtxo(t, w, fundingTx)
+	setSyncedHeight(t, w, testBlockHeight)
+
+	send := func() *wire.MsgTx {
+		t.Helper()
+
+		tx, err := w.SendOutputs(
+			[]*wire.TxOut{wire.NewTxOut(10000000, testScriptP2WKH)},
+			&waddrmgr.KeyScopeBIP0084, 0, 1, 1000,
+			CoinSelectionLargest, "",
+		)
+		if err != nil {
+			t.Fatalf("unable to send outputs: %v", err)
+		}
+		return tx
+	}
+	replacedTx := send()
+	evictedTx := send()
+
+	// We'll simulate the first transaction being replaced by another
+	// spending the same input, and the second being evicted.
+	replacementTx := wire.NewMsgTx(wire.TxVersion)
+	replacementTx.AddTxIn(wire.NewTxIn(
+		&replacedTx.TxIn[0].PreviousOutPoint, nil, nil,
+	))
+	replacementTx.AddTxOut(wire.NewTxOut(90000000, testScriptP2WKH))
+	chainClient.txs[replacementTx.TxHash()] = replacementTx
+	chainClient.mempool[replacementTx.TxHash()] = struct{}{}
+	delete(chainClient.mempool, replacedTx.TxHash())
+	delete(chainClient.mempool, evictedTx.TxHash())
+
+	ntfns := w.NtfnServer.TxReplacedNotifications()
+	defer ntfns.Done()
+
+	type result struct {
+		abandoned []chainhash.Hash
+		err       error
+	}
+	resultChan := make(chan result, 1)
+	go func() {
+		abandoned, err := w.ReconcileMempool()
+		resultChan <- result{abandoned, err}
+	}()
+
+	select {
+	case ntfn := <-ntfns.C:
+		if ntfn.OldTxHash != replacedTx.TxHash() ||
+			ntfn.NewTxHash != replacementTx.TxHash() {
+
+			t.Fatalf("expected replacement of %v by %v, got %v "+
+				"by %v", replacedTx.TxHash(),
+				replacementTx.TxHash(), ntfn.OldTxHash,
+				ntfn.NewTxHash)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected TxReplaced notification")
+	}
+
+	res := <-resultChan
+	if res.err != nil {
+		t.Fatalf("unable to reconcile mempool: %v", res.err)
+	}
+	if len(res.abandoned) != 1 || res.abandoned[0] != evictedTx.TxHash() {
+		t.Fatalf("expected only %v to be abandoned, got %v",
+			evictedTx.TxHash(), res.abandoned)
+	}
+
+	// The replacement should be recorded for the replaced transaction
+	// only.
+	replacement, err := w.TxReplacement(replacedTx.TxHash())
+	if err != nil {
+		t.Fatalf("unable to fetch replacement: %v", err)
+	}
+	if replacement == nil || *replacement != replacementTx.TxHash() {
+		t.Fatalf("expected replacement %v, got %v",
+			replacementTx.TxHash(), replacement)
+	}
+	replacement, err = w.TxReplacement(evictedTx.TxHash())
+	if err != nil {
+		t.Fatalf("unable to fetch replacement: %v", err)
+	}
+	if replacement != nil {
+		t.Fatalf("expected no replacement of evicted transaction, "+
+			"got %v", replacement)
+	}
+
+	// Only the replacement should be left unconfirmed.
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		unmined, err := w.TxStore.UnminedTxs(ns)
+		if err != nil {
+			return err
+		}
+		if len(unmined) != 1 ||
+			unmined[0].TxHash() != replacementTx.TxHash() {
+
+			t.Fatalf("expected only %v to be unconfirmed, got %d "+
+				"transactions", replacementTx.TxHash(),
+				len(unmined))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestMempoolConflictReplacement ensures that an unconfirmed transaction
+// received from the mempool is recorded and notified as the replacement of the
+// wallet's unconfirmed transactions spending some of the same inputs.
+func TestMempoolConflictReplacement(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	fundingTx := wire.NewMsgTx(wire.TxVersion)
+	fundingTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	fundingTx.AddTxOut(
+		wire.NewTxOut(btcutil.SatoshiPerBitcoin, testScriptP2WKH),
+	)
+	addUtxo(t, w, fundingTx)
+
+	spend := func(value int64) *wire.MsgTx {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(
+			&wire.OutPoint{Hash: fundingTx.TxHash()}, nil, nil,
+		))
+		tx.AddTxOut(wire.NewTxOut(value, testScriptP2WKH))
+		return tx
+	}
+	replacedTx := spend(90000000)
+	addUnminedTx(t, w, replacedTx)
+
+	chainClient := &notifyingChainClient{
+		notifications: make(chan interface{}),
+	}
+	w.chainClient = chainClient
+	w.wg.Add(1)
+	go w.handleChainNotifications()
+	defer close(w.quit)
+
+	ntfns := w.NtfnServer.TxReplacedNotifications()
+	defer ntfns.Done()
+
+	replacementTx := spend(80000000)
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(replacementTx, time.Now())
+	if err != nil {
+		t.Fatalf("unable to create tx record: %v", err)
+	}
+	chainClient.notifications <- chain.RelevantTx{TxRecord: rec}
+
+	select {
+	case ntfn := <-ntfns.C:
+		if ntfn.OldTxHash != replacedTx.TxHash() ||
+			ntfn.NewTxHash != replacementTx.TxHash() {
+
+			t.Fatalf("expected replacement of %v by %v, got %v "+
+				"by %v", replacedTx.TxHash(),
+				replacementTx.TxHash(), ntfn.OldTxHash,
+				ntfn.NewTxHash)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected TxReplaced notification")
+	}
+
+	replacement, err := w.TxReplacement(replacedTx.TxHash())
+	if err != nil {
+		t.Fatalf("unable to fetch replacement: %v", err)
+	}
+	if replacement == nil || *replacement != replacementTx.TxHash() {
+		t.Fatalf("expected replacement %v, got %v",
+			replacementTx.TxHash(), replacement)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// ErrInvalidRelativeLock is returned when attempting to record a relative
+// lock-time that isn't a block-based BIP 68 relative lock-time.
+var ErrInvalidRelativeLock = errors.New("invalid relative lock-time")
+
+// SetOutputRelativeLock records the BIP 68 relative lock-time encumbering the
+// given wallet output, such as the CSV delay of the script it pays to. The
+// output is excluded from coin selection until it has as many confirmations
+// as the lock-time requires, after which it's spent with the lock-time as its
+// input's sequence. Only block-based relative lock-times are supported.
+func (w *Wallet) SetOutputRelativeLock(op wire.OutPoint,
+	sequence uint32) error {
+
+	switch {
+	case sequence&wire.SequenceLockTimeDisabled != 0:
+		return fmt.Errorf("%w: relative lock-time of sequence %d is "+
+			"disabled", ErrInvalidRelativeLock, sequence)
+
+	case sequence&wire.SequenceLockTimeIsSeconds != 0:
+		return fmt.Errorf("%w: time-based relative lock-time of "+
+			"sequence %d is not supported", ErrInvalidRelativeLock,
+			sequence)
+
+	case sequence&wire.SequenceLockTimeMask == 0:
+		return fmt.Errorf("%w: sequence %d has no relative lock-time",
+			ErrInvalidRelativeLock, sequence)
+	}
+
+	return walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		txmgrNs := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+		return w.TxStore.PutRelativeLock(txmgrNs, op, sequence)
+	})
+}
+
+// relativeLockMature returns whether the relative lock-time of the output, if
+// any, allows it to be spent in the block following the given chain height.
+func relativeLockMature(output *wtxmgr.Credit, curHeight int32) bool {
+	if output.RelativeLock == 0 {
+		return true
+	}
+
+	target := int32(output.RelativeLock & wire.SequenceLockTimeMask)
+	return confirmed(target, output.Height, curHeight)
+}
+
+// setRelativeLockSequences sets the sequence of the transaction's inputs
+// spending outputs with a relative lock-time to that lock-time, raising the
+// transaction's version if required for it to be enforced.
+func setRelativeLockSequences(tx *wire.MsgTx, credits []wtxmgr.Credit) {
+	locks := make(map[wire.OutPoint]uint32)
+	for _, credit := range credits {
+		if credit.RelativeLock != 0 {
+			locks[credit.OutPoint] = credit.RelativeLock
+		}
+	}
+	if len(locks) == 0 {
+		return
+	}
+
+	for _, txIn := range tx.TxIn {
+		lock, ok := locks[txIn.PreviousOutPoint]
+		if !ok {
+			continue
+		}
+
+		txIn.Sequence = lock
+		if tx.Version < relativeLockTimeTxVersion {
+			tx.Version = relativeLockTimeTxVersion
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/stretchr/testify/require"
+)
+
+// blockStampChainClient is a mock chain client whose best block is at a
+// configurable height.
+type blockStampChainClient struct {
+	mockChainClient
+
+	height int32
+}
+
+func (c *blockStampChainClient) BlockStamp() (*waddrmgr.BlockStamp, error) {
+	bs, err := c.mockChainClient.BlockStamp()
+	if err != nil {
+		return nil, err
+	}
+	bs.Height = c.height
+	return bs, nil
+}
+
+// TestRelativeLockCoinSelection ensures that outputs with a relative
+// lock-time are only selected once they have matured, and are then spent with
+// the lock-time as their input's sequence.
+func TestRelativeLockCoinSelection(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+	chainClient := &blockStampChainClient{}
+	w.chainClient = chainClient
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	// Import an output encumbered by a CSV delay of 10 blocks.
+	const (
+		height   = 1000
+		csvDelay = 10
+	)
+	fundingTx := wire.NewMsgTx(wire.TxVersion)
+	fundingTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	fundingTx.AddTxOut(wire.NewTxOut(1000000, pkScript))
+	addMinedTx(t, w, fundingTx, height, 0)
+
+	outPoint := wire.OutPoint{Hash: fundingTx.TxHash()}
+	require.NoError(t, w.SetOutputRelativeLock(outPoint, csvDelay))
+
+	// Disabled and time-based relative lock-times aren't supported.
+	invalid := []uint32{
+		0,
+		wire.SequenceLockTimeDisabled | csvDelay,
+		wire.SequenceLockTimeIsSeconds | csvDelay,
+	}
+	for _, sequence := range invalid {
+		err := w.SetOutputRelativeLock(outPoint, sequence)
+		require.True(t, errors.Is(err, ErrInvalidRelativeLock), err)
+	}
+
+	outputs := []*wire.TxOut{wire.NewTxOut(100000, testScriptP2WKH)}
+	createTx := func() (*txauthor.AuthoredTx, error) {
+		return w.CreateSimpleTx(
+			nil, 0, outputs, 1, 1000, CoinSelectionLargest, true,
+		)
+	}
+
+	// With one confirmation short of the delay, the output can't be spent
+	// in the next block, so it shouldn't be selected.
+	chainClient.height = height + csvDelay - 2
+	_, err = createTx()
+	_, ok := err.(txauthor.InputSourceError)
+	require.True(t, ok, "expected InputSourceError, got %v", err)
+
+	// Once matured, it should be spent with the delay as its sequence, in
+	// a transaction with a version enforcing it.
+	chainClient.height = height + csvDelay - 1
+	tx, err := createTx()
+	require.NoError(t, err)
+	require.Len(t, tx.Tx.TxIn, 1)
+	require.Equal(t, outPoint, tx.Tx.TxIn[0].PreviousOutPoint)
+	require.EqualValues(t, csvDelay, tx.Tx.TxIn[0].Sequence)
+	require.EqualValues(t, relativeLockTimeTxVersion, tx.Tx.Version)
+}
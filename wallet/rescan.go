@@ -5,6 +5,8 @@
 package wallet
 
 import (
+	"errors"
+
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
@@ -31,13 +33,19 @@ type RescanFinishedMsg struct {
 // a set of wallet addresses, a starting height to begin the rescan, and
 // outpoints spendable by the addresses thought to be unspent.  After the
 // rescan completes, the error result of the rescan RPC is sent on the Err
-// channel.
+// channel.  If set, TxHook is invoked for every relevant transaction recorded
+// by the wallet while the rescan is in progress.
 type RescanJob struct {
 	InitialSync bool
 	Addrs       []btcutil.Address
 	OutPoints   map[wire.OutPoint]btcutil.Address
 	BlockStamp  waddrmgr.BlockStamp
+	TxHook      RescanTxHook
 	err         chan error
+
+	// finished, if set, is closed once the wallet has processed the
+	// notifications of the rescan performing the job.
+	finished chan struct{}
 }
 
 // rescanBatch is a collection of one or more RescanJobs that were merged
@@ -48,6 +56,14 @@ type rescanBatch struct {
 	outpoints   map[wire.OutPoint]btcutil.Address
 	bs          waddrmgr.BlockStamp
 	errChans    []chan error
+	finished    []chan struct{}
+	txHooks     []RescanTxHook
+
+	// chainClient is the chain client performing the batch, once it has
+	// been dispatched to one.
+	//
+	// NOTE: This must be accessed with the wallet's chainClientLock held.
+	chainClient chain.Interface
 }
 
 // SubmitRescan submits a RescanJob to the RescanManager.  A channel is
@@ -66,13 +82,20 @@ func (w *Wallet) SubmitRescan(job *RescanJob) <-chan error {
 
 // batch creates the rescanBatch for a single rescan job.
 func (job *RescanJob) batch() *rescanBatch {
-	return &rescanBatch{
+	b := &rescanBatch{
 		initialSync: job.InitialSync,
 		addrs:       job.Addrs,
 		outpoints:   job.OutPoints,
 		bs:          job.BlockStamp,
 		errChans:    []chan error{job.err},
 	}
+	if job.finished != nil {
+		b.finished = []chan struct{}{job.finished}
+	}
+	if job.TxHook != nil {
+		b.txHooks = []RescanTxHook{job.TxHook}
+	}
+	return b
 }
 
 // merge merges the work from k into j, setting the starting height to
@@ -92,15 +115,31 @@ func (b *rescanBatch) merge(job *RescanJob) {
 		b.bs = job.BlockStamp
 	}
 	b.errChans = append(b.errChans, job.err)
+	if job.finished != nil {
+		b.finished = append(b.finished, job.finished)
+	}
+	if job.TxHook != nil {
+		b.txHooks = append(b.txHooks, job.TxHook)
+	}
 }
 
 // done iterates through all error channels, duplicating sending the error
 // to inform callers that the rescan finished (or could not complete due
-// to an error).
+// to an error). The callers are only informed once, even if the batch is
+// resubmitted to another chain client afterwards.
 func (b *rescanBatch) done(err error) {
 	for _, c := range b.errChans {
 		c <- err
 	}
+	b.errChans = nil
+}
+
+// finish informs the callers waiting on the rescan's notifications that they
+// have all been processed by the wallet.
+func (b *rescanBatch) finish() {
+	for _, c := range b.finished {
+		close(c)
+	}
 }
 
 // rescanBatchHandler handles incoming rescan request, serializing rescan
@@ -119,6 +158,7 @@ func (w *Wallet) rescanBatchHandler() {
 				// Set current batch as this job and send
 				// request.
 				curBatch = job.batch()
+				w.setRescanTxHooks(curBatch)
 				select {
 				case w.rescanBatch <- curBatch:
 				case <-quit:
@@ -135,6 +175,20 @@ func (w *Wallet) rescanBatchHandler() {
 				}
 			}
 
+		case <-w.rescanResubmit:
+			// The chain client was switched while the current
+			// batch was in progress, so its notifications will
+			// never be received and it must be performed again by
+			// the new one.
+			if curBatch == nil || !w.rescanBatchStale(curBatch) {
+				continue
+			}
+			select {
+			case w.rescanBatch <- curBatch:
+			case <-quit:
+				return
+			}
+
 		case n := <-w.rescanNotifications:
 			switch n := n.(type) {
 			case *chain.RescanProgress:
@@ -175,6 +229,8 @@ func (w *Wallet) rescanBatchHandler() {
 					return
 				}
 
+				w.setRescanTxHooks(nextBatch)
+				curBatch.finish()
 				curBatch, nextBatch = nextBatch, nil
 
 				if curBatch != nil {
@@ -222,7 +278,7 @@ out:
 				"%s, height %d)", len(addrs), noun, n.Hash,
 				n.Height)
 
-			go w.resendUnminedTxs()
+			w.triggerRebroadcast()
 
 		case <-quit:
 			break out
@@ -235,8 +291,7 @@ out:
 // RPC requests to perform a rescan.  New jobs are not read until a rescan
 // finishes.
 func (w *Wallet) rescanRPCHandler() {
-	chainClient, err := w.requireChainClient()
-	if err != nil {
+	if _, err := w.requireChainClient(); err != nil {
 		log.Errorf("rescanRPCHandler called without an RPC client")
 		w.wg.Done()
 		return
@@ -254,8 +309,25 @@ out:
 			log.Infof("Started rescan from block %v (height %d) for %d %s",
 				batch.bs.Hash, batch.bs.Height, numAddrs, noun)
 
-			err := chainClient.Rescan(&batch.bs.Hash, batch.addrs,
+			// The chain client is fetched for every batch, as it
+			// may have been switched since the previous one.
+			chainClient, err := w.dispatchRescanBatch(batch)
+			if err != nil {
+				batch.done(err)
+				continue
+			}
+			err = chainClient.Rescan(&batch.bs.Hash, batch.addrs,
 				batch.outpoints)
+
+			// A rescan interrupted by switching the chain client
+			// is resubmitted to the new one, so its callers are
+			// only informed of the outcome of the latter.
+			if err != nil && w.ChainClient() != chainClient {
+				log.Infof("Rescan for %d %s interrupted by "+
+					"chain client switch: %v", numAddrs,
+					noun, err)
+				continue
+			}
 			if err != nil {
 				log.Errorf("Rescan for %d %s failed: %v", numAddrs,
 					noun, err)
@@ -269,6 +341,33 @@ out:
 	w.wg.Done()
 }
 
+// dispatchRescanBatch returns the chain client the batch is performed by,
+// recording it within the batch.
+func (w *Wallet) dispatchRescanBatch(batch *rescanBatch) (chain.Interface,
+	error) {
+
+	w.chainSwitchMtx.RLock()
+	defer w.chainSwitchMtx.RUnlock()
+
+	w.chainClientLock.Lock()
+	defer w.chainClientLock.Unlock()
+
+	if w.chainClient == nil {
+		return nil, errors.New("blockchain RPC is inactive")
+	}
+	batch.chainClient = w.chainClient
+	return w.chainClient, nil
+}
+
+// rescanBatchStale returns whether the batch was dispatched to a chain client
+// that has since been switched for another one.
+func (w *Wallet) rescanBatchStale(batch *rescanBatch) bool {
+	w.chainClientLock.Lock()
+	defer w.chainClientLock.Unlock()
+
+	return batch.chainClient != nil && batch.chainClient != w.chainClient
+}
+
 // Rescan begins a rescan for all active addresses and unspent outputs of
 // a wallet.  This is intended to be used to sync a wallet back up to the
 // current best block in the main chain, and is considered an initial sync
@@ -282,13 +381,62 @@ func (w *Wallet) Rescan(addrs []btcutil.Address, unspent []wtxmgr.Credit) error
 func (w *Wallet) rescanWithTarget(addrs []btcutil.Address,
 	unspent []wtxmgr.Credit, startStamp *waddrmgr.BlockStamp) error {
 
+	job, err := w.newRescanJob(addrs, unspent, startStamp)
+	if err != nil {
+		return err
+	}
+
+	// Submit merged job and block until rescan completes.
+	select {
+	case err := <-w.SubmitRescan(job):
+		return err
+	case <-w.quitChan():
+		return ErrWalletShuttingDown
+	}
+}
+
+// rescanAndWait is the same as rescanWithTarget, but only returns once the
+// wallet has processed the notifications of the rescan, such that all of the
+// transactions it found are reflected in the wallet.
+func (w *Wallet) rescanAndWait(addrs []btcutil.Address,
+	unspent []wtxmgr.Credit, startStamp *waddrmgr.BlockStamp) error {
+
+	job, err := w.newRescanJob(addrs, unspent, startStamp)
+	if err != nil {
+		return err
+	}
+	job.finished = make(chan struct{})
+
+	select {
+	case err := <-w.SubmitRescan(job):
+		if err != nil {
+			return err
+		}
+	case <-w.quitChan():
+		return ErrWalletShuttingDown
+	}
+
+	select {
+	case <-job.finished:
+		return nil
+	case <-w.quitChan():
+		return ErrWalletShuttingDown
+	}
+}
+
+// newRescanJob creates an initial sync rescan job for the given addresses and
+// unspent outputs, starting at the optional startStamp. If none is provided,
+// the rescan will begin from the manager's sync tip.
+func (w *Wallet) newRescanJob(addrs []btcutil.Address, unspent []wtxmgr.Credit,
+	startStamp *waddrmgr.BlockStamp) (*RescanJob, error) {
+
 	outpoints := make(map[wire.OutPoint]btcutil.Address, len(unspent))
 	for _, output := range unspent {
 		_, outputAddrs, _, err := txscript.ExtractPkScriptAddrs(
 			output.PkScript, w.chainParams,
 		)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		outpoints[output.OutPoint] = outputAddrs[0]
@@ -301,18 +449,10 @@ func (w *Wallet) rescanWithTarget(addrs []btcutil.Address,
 		*startStamp = w.Manager.SyncedTo()
 	}
 
-	job := &RescanJob{
+	return &RescanJob{
 		InitialSync: true,
 		Addrs:       addrs,
 		OutPoints:   outpoints,
 		BlockStamp:  *startStamp,
-	}
-
-	// Submit merged job and block until rescan completes.
-	select {
-	case err := <-w.SubmitRescan(job):
-		return err
-	case <-w.quitChan():
-		return ErrWalletShuttingDown
-	}
+	}, nil
 }
@@ -0,0 +1,68 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// RescanTxHook is invoked for every relevant transaction notified by the chain
+// backend and recorded by the wallet while a rescan is in progress, e.g. to
+// reapply labels or comments to the transactions discovered when reprocessing
+// the history of imported keys. The hook is invoked within the database
+// transaction recording the transaction and its credits, which it may use to
+// attach metadata to it. An error returned by the hook aborts the database
+// transaction, such that the transaction isn't recorded either.
+type RescanTxHook func(dbtx walletdb.ReadWriteTx,
+	details *wtxmgr.TxDetails) error
+
+// setRescanTxHooks sets the hooks of the given rescan batch as the ones of the
+// rescan in progress, clearing them if there is none.
+func (w *Wallet) setRescanTxHooks(batch *rescanBatch) {
+	var hooks []RescanTxHook
+	if batch != nil {
+		hooks = batch.txHooks
+	}
+
+	w.rescanTxHooksMtx.Lock()
+	w.rescanTxHooks = hooks
+	w.rescanTxHooksMtx.Unlock()
+}
+
+// runRescanTxHooks invokes the hooks of the rescan in progress, if any, for
+// the newly recorded transaction.
+func (w *Wallet) runRescanTxHooks(dbtx walletdb.ReadWriteTx,
+	rec *wtxmgr.TxRecord, block *wtxmgr.BlockMeta) error {
+
+	w.rescanTxHooksMtx.Lock()
+	hooks := w.rescanTxHooks
+	w.rescanTxHooksMtx.Unlock()
+
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	var recBlock *wtxmgr.Block
+	if block != nil {
+		recBlock = &block.Block
+	}
+	txmgrNs := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+	details, err := w.TxStore.UniqueTxDetails(txmgrNs, &rec.Hash, recBlock)
+	if err != nil {
+		return err
+	}
+	if details == nil {
+		return nil
+	}
+
+	for _, hook := range hooks {
+		if err := hook(dbtx, details); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
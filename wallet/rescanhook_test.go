@@ -0,0 +1,149 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRescanTxHook ensures that the hook of a rescan is invoked for every
+// relevant transaction discovered by the rescan, within the database
+// transaction recording it.
+func TestRescanTxHook(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	var (
+		addrs []btcutil.Address
+		txs   []*wire.MsgTx
+	)
+	for i := 0; i < 2; i++ {
+		addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+		require.NoError(t, err)
+		pkScript, err := txscript.PayToAddrScript(addr)
+		require.NoError(t, err)
+		addrs = append(addrs, addr)
+
+		tx := wire.NewMsgTx(2)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Index: uint32(i)},
+		})
+		tx.AddTxOut(wire.NewTxOut(int64(i+1)*100000, pkScript))
+		txs = append(txs, tx)
+	}
+
+	// A transaction paying to another address isn't discovered.
+	unrelated := wire.NewMsgTx(2)
+	unrelated.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: 2},
+	})
+	unrelated.AddTxOut(wire.NewTxOut(100000, testScriptP2WKH))
+	txs = append(txs, unrelated)
+
+	block := wtxmgr.BlockMeta{
+		Block: wtxmgr.Block{
+			Hash:   chainhash.Hash{1},
+			Height: 100,
+		},
+		Time: time.Now(),
+	}
+	w.chainClient = &rescanningChainClient{
+		notifyingChainClient: notifyingChainClient{
+			notifications: make(chan interface{}),
+		},
+		txs:   txs,
+		block: block,
+		tip:   w.Manager.SyncedTo(),
+	}
+
+	w.wg.Add(4)
+	go w.handleChainNotifications()
+	go w.rescanBatchHandler()
+	go w.rescanProgressHandler()
+	go w.rescanRPCHandler()
+
+	// The hook labels every transaction it's invoked for.
+	var discovered []wtxmgr.TxDetails
+	hook := func(dbtx walletdb.ReadWriteTx,
+		details *wtxmgr.TxDetails) error {
+
+		discovered = append(discovered, *details)
+		ns := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+		return w.TxStore.PutTxLabel(ns, details.Hash, "rescanned")
+	}
+
+	job, err := w.newRescanJob(addrs, nil, nil)
+	require.NoError(t, err)
+	job.TxHook = hook
+	job.finished = make(chan struct{})
+	require.NoError(t, <-w.SubmitRescan(job))
+	select {
+	case <-job.finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rescan notifications not processed")
+	}
+
+	// The hook was invoked once for each of the discovered transactions,
+	// with their credits already recorded.
+	require.Len(t, discovered, 2)
+	for i, details := range discovered {
+		require.Equal(t, txs[i].TxHash(), details.Hash)
+		require.Equal(t, block.Block, details.Block.Block)
+		require.Len(t, details.Credits, 1)
+		require.Equal(
+			t, btcutil.Amount(txs[i].TxOut[0].Value),
+			details.Credits[0].Amount,
+		)
+
+		err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+			ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+			label, err := wtxmgr.FetchTxLabel(ns, details.Hash)
+			if err != nil {
+				return err
+			}
+			require.Equal(t, "rescanned", label)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	// Once the rescan has finished, newly recorded transactions no longer
+	// invoke the hook.
+	w.rescanTxHooksMtx.Lock()
+	require.Empty(t, w.rescanTxHooks)
+	w.rescanTxHooksMtx.Unlock()
+
+	// Even while a rescan is in progress, transactions recorded by the
+	// wallet other than through chain notifications don't reach the hook.
+	w.setRescanTxHooks(&rescanBatch{txHooks: []RescanTxHook{hook}})
+	defer w.setRescanTxHooks(nil)
+
+	pkScript, err := txscript.PayToAddrScript(addrs[0])
+	require.NoError(t, err)
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: 3},
+	})
+	tx.AddTxOut(wire.NewTxOut(100000, pkScript))
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+	require.NoError(t, err)
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		return w.addRelevantTx(dbtx, rec, nil)
+	})
+	require.NoError(t, err)
+	require.Len(t, discovered, 2)
+}
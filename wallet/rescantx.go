@@ -0,0 +1,192 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// rawTxVerboseSource is implemented by chain backends able to look up any
+// transaction by its hash, along with the block it's confirmed in.
+type rawTxVerboseSource interface {
+	GetRawTransactionVerbose(*chainhash.Hash) (*btcjson.TxRawResult, error)
+}
+
+// RescanTransaction reprocesses a single transaction fetched from the chain
+// backend, recording its effect on the current set of wallet scripts at the
+// height of the block confirming it, or as unmined if it has yet to confirm.
+// This allows recovering a transaction the wallet missed, such as one paying
+// to a key imported after the fact, without a full rescan. Reprocessing an
+// already recorded transaction only adds the credits it's missing, if any.
+//
+// ErrTxNotFound is returned if the chain backend is unable to produce the
+// transaction, and ErrNotMine if it neither pays to nor spends from the
+// wallet.
+func (w *Wallet) RescanTransaction(txHash chainhash.Hash) error {
+	chainClient, done, err := w.useChainClient()
+	if err != nil {
+		return err
+	}
+	defer done()
+	source, ok := chainClient.(rawTxVerboseSource)
+	if !ok {
+		return fmt.Errorf("unable to fetch transactions from %v "+
+			"backend", chainClient.BackEnd())
+	}
+
+	result, err := source.GetRawTransactionVerbose(&txHash)
+	if err != nil {
+		return fmt.Errorf("%w: %v: %v", ErrTxNotFound, txHash, err)
+	}
+	serializedTx, err := hex.DecodeString(result.Hex)
+	if err != nil {
+		return err
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(serializedTx)); err != nil {
+		return err
+	}
+	if tx.TxHash() != txHash {
+		return fmt.Errorf("backend returned transaction %v instead of "+
+			"%v", tx.TxHash(), txHash)
+	}
+
+	// A confirmed transaction is recorded at the height of its block,
+	// which must be part of the main chain.
+	var block *wtxmgr.BlockMeta
+	if result.BlockHash != "" {
+		blockHash, err := chainhash.NewHashFromStr(result.BlockHash)
+		if err != nil {
+			return err
+		}
+		height, err := fetchBlockHeight(chainClient, blockHash)
+		if err != nil {
+			return err
+		}
+		mainChainHash, err := chainClient.GetBlockHash(int64(height))
+		if err != nil {
+			return err
+		}
+		if *mainChainHash != *blockHash {
+			return fmt.Errorf("block %v of transaction %v is not "+
+				"part of the main chain", blockHash, txHash)
+		}
+		header, err := chainClient.GetBlockHeader(blockHash)
+		if err != nil {
+			return err
+		}
+
+		block = &wtxmgr.BlockMeta{
+			Block: wtxmgr.Block{
+				Hash:   *blockHash,
+				Height: height,
+			},
+			Time: header.Timestamp,
+		}
+	}
+
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(&tx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var exists bool
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		addrmgrNs := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
+		txmgrNs := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+
+		spends, err := w.spendsWalletOutput(txmgrNs, &tx)
+		if err != nil {
+			return err
+		}
+		if !spends && !w.paysToWallet(addrmgrNs, &tx) {
+			return fmt.Errorf("%w: transaction %v neither pays to "+
+				"nor spends from the wallet", ErrNotMine, txHash)
+		}
+
+		details, err := w.TxStore.TxDetails(txmgrNs, &txHash)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		// A transaction not yet recorded, or only recorded as unmined
+		// while it has since confirmed, is recorded as any other
+		// relevant transaction.
+		case details == nil:
+			return w.addRelevantTx(dbtx, rec, block)
+		case details.Block.Height == -1 && block != nil:
+			return w.addRelevantTx(dbtx, rec, block)
+
+		// The transaction was already recorded, possibly before some
+		// of the keys it pays to were imported, so only its missing
+		// credits are added.
+		case details.Block.Height == blockHeight(block):
+			exists = true
+			return w.addWalletCredits(
+				addrmgrNs, txmgrNs, rec, block,
+			)
+
+		default:
+			return fmt.Errorf("transaction %v is recorded at height "+
+				"%d instead of %d", txHash,
+				details.Block.Height, blockHeight(block))
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		log.Infof("Reprocessed recorded transaction %v", txHash)
+	} else {
+		log.Infof("Recorded rescanned transaction %v at height %d",
+			txHash, blockHeight(block))
+	}
+
+	return nil
+}
+
+// spendsWalletOutput returns whether any of the transaction's inputs spend an
+// output recorded as a wallet credit.
+func (w *Wallet) spendsWalletOutput(txmgrNs walletdb.ReadBucket,
+	tx *wire.MsgTx) (bool, error) {
+
+	for _, txIn := range tx.TxIn {
+		prevOut := txIn.PreviousOutPoint
+		details, err := w.TxStore.TxDetails(txmgrNs, &prevOut.Hash)
+		if err != nil {
+			return false, err
+		}
+		if details == nil {
+			continue
+		}
+		for _, credit := range details.Credits {
+			if credit.Index == prevOut.Index {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// blockHeight returns the height of the block, or -1 if it's nil, matching the
+// height at which unmined transactions are recorded.
+func blockHeight(block *wtxmgr.BlockMeta) int32 {
+	if block == nil {
+		return -1
+	}
+	return block.Height
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/stretchr/testify/require"
+)
+
+// rawTxChainClient is a mock chain client serving a single transaction
+// confirmed in the block known to its heightChainClient.
+type rawTxChainClient struct {
+	heightChainClient
+
+	tx     *wire.MsgTx
+	header wire.BlockHeader
+}
+
+func (c *rawTxChainClient) GetRawTransactionVerbose(
+	hash *chainhash.Hash) (*btcjson.TxRawResult, error) {
+
+	if *hash != c.tx.TxHash() {
+		return nil, errors.New("transaction not found")
+	}
+
+	var b bytes.Buffer
+	if err := c.tx.Serialize(&b); err != nil {
+		return nil, err
+	}
+	return &btcjson.TxRawResult{
+		Hex:       hex.EncodeToString(b.Bytes()),
+		Txid:      hash.String(),
+		BlockHash: c.hash.String(),
+	}, nil
+}
+
+func (c *rawTxChainClient) GetBlockHeader(
+	hash *chainhash.Hash) (*wire.BlockHeader, error) {
+
+	if *hash != c.hash {
+		return nil, errors.New("block not found")
+	}
+	return &c.header, nil
+}
+
+// TestRescanTransaction ensures that a past transaction paying to a key
+// imported after the fact is recorded at the height of its block once
+// reprocessed, and that reprocessing it again has no further effect.
+func TestRescanTransaction(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	pubKey := privKey.PubKey()
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		btcutil.Hash160(pubKey.SerializeCompressed()), w.chainParams,
+	)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	const (
+		height = 1000
+		amount = 100000
+	)
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(amount, pkScript))
+
+	header := wire.BlockHeader{
+		Version:   1,
+		Timestamp: time.Unix(1600000000, 0),
+	}
+	blockHash := header.BlockHash()
+	w.chainClient = &rawTxChainClient{
+		heightChainClient: heightChainClient{
+			hash:          blockHash,
+			height:        height,
+			mainChainHash: blockHash,
+		},
+		tx:     tx,
+		header: header,
+	}
+
+	// A transaction unknown to the backend can't be reprocessed.
+	err = w.RescanTransaction(chainhash.Hash{0x01})
+	require.True(t, errors.Is(err, ErrTxNotFound))
+
+	// Before its key is imported, the transaction isn't relevant.
+	err = w.RescanTransaction(tx.TxHash())
+	require.True(t, errors.Is(err, ErrNotMine))
+
+	err = w.ImportPublicKey(pubKey, waddrmgr.WitnessPubKey)
+	require.NoError(t, err)
+
+	// Once imported, reprocessing the transaction should credit its
+	// output at the height of its block, and doing so again should leave
+	// the wallet unchanged.
+	for i := 0; i < 2; i++ {
+		require.NoError(t, w.RescanTransaction(tx.TxHash()))
+
+		err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+			ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+			unspent, err := w.TxStore.UnspentOutputs(ns)
+			require.NoError(t, err)
+			require.Len(t, unspent, 1)
+			require.Equal(t, tx.TxHash(), unspent[0].Hash)
+			require.EqualValues(t, height, unspent[0].Height)
+			require.EqualValues(t, amount, unspent[0].Amount)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+}
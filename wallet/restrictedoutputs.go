@@ -0,0 +1,159 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// RestrictionReason describes why an output is excluded from coin selection.
+type RestrictionReason uint8
+
+const (
+	// RestrictionLeased indicates the output is leased through
+	// LeaseOutput.
+	RestrictionLeased RestrictionReason = iota
+
+	// RestrictionFrozen indicates the output is frozen through
+	// FreezeOutput.
+	RestrictionFrozen
+
+	// RestrictionDenylisted indicates the output, or the script it pays
+	// to, is on the selection denylist.
+	RestrictionDenylisted
+)
+
+// String returns the string representation of the restriction reason.
+func (r RestrictionReason) String() string {
+	switch r {
+	case RestrictionLeased:
+		return "leased"
+	case RestrictionFrozen:
+		return "frozen"
+	case RestrictionDenylisted:
+		return "denylisted"
+	default:
+		return "unknown"
+	}
+}
+
+// RestrictedOutput is an output excluded from coin selection, along with the
+// reason it's excluded.
+type RestrictedOutput struct {
+	// OutPoint is the outpoint of the output.
+	OutPoint wire.OutPoint
+
+	// Reason is the reason the output is excluded from coin selection.
+	Reason RestrictionReason
+
+	// LockID is the ID of the lease of a leased output.
+	LockID wtxmgr.LockID
+
+	// Expiration is the time at which the lease of a leased output
+	// expires.
+	Expiration time.Time
+
+	// PkScript is the denylisted script an output denylisted by its
+	// script pays to. It's nil for outputs denylisted by their outpoint.
+	PkScript []byte
+}
+
+// ListRestrictedOutputs returns all outputs excluded from coin selection:
+// those currently leased, frozen, or matching an entry of the selection
+// denylist. Outputs denylisted by their script are reported for each unspent
+// wallet output paying to it. An output excluded for several reasons is
+// reported once for each of them.
+func (w *Wallet) ListRestrictedOutputs() ([]RestrictedOutput, error) {
+	var restricted []RestrictedOutput
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(wtxmgrNamespaceKey)
+
+		leased, err := w.TxStore.ListLockedOutputs(ns)
+		if err != nil {
+			return err
+		}
+		for _, output := range leased {
+			restricted = append(restricted, RestrictedOutput{
+				OutPoint:   output.Outpoint,
+				Reason:     RestrictionLeased,
+				LockID:     output.LockID,
+				Expiration: output.Expiration,
+			})
+		}
+
+		frozen, err := w.TxStore.ListFrozenOutputs(ns)
+		if err != nil {
+			return err
+		}
+		for _, op := range frozen {
+			restricted = append(restricted, RestrictedOutput{
+				OutPoint: op,
+				Reason:   RestrictionFrozen,
+			})
+		}
+
+		entries, err := w.TxStore.SelectionDenylist(ns)
+		if err != nil {
+			return err
+		}
+		denylisted, err := w.denylistedOutputs(ns, entries)
+		if err != nil {
+			return err
+		}
+		restricted = append(restricted, denylisted...)
+
+		return nil
+	})
+	return restricted, err
+}
+
+// denylistedOutputs returns the outputs matching the selection denylist
+// entries. The wallet's unspent outputs are only looked up if any of the
+// entries is a script.
+func (w *Wallet) denylistedOutputs(ns walletdb.ReadBucket,
+	entries []wtxmgr.DenylistEntry) ([]RestrictedOutput, error) {
+
+	var (
+		outputs []RestrictedOutput
+		scripts [][]byte
+	)
+	for _, entry := range entries {
+		if entry.OutPoint != nil {
+			outputs = append(outputs, RestrictedOutput{
+				OutPoint: *entry.OutPoint,
+				Reason:   RestrictionDenylisted,
+			})
+			continue
+		}
+		scripts = append(scripts, entry.PkScript)
+	}
+	if len(scripts) == 0 {
+		return outputs, nil
+	}
+
+	unspent, err := w.TxStore.UnspentOutputs(ns)
+	if err != nil {
+		return nil, err
+	}
+	for _, script := range scripts {
+		for _, credit := range unspent {
+			if !bytes.Equal(credit.PkScript, script) {
+				continue
+			}
+			outputs = append(outputs, RestrictedOutput{
+				OutPoint: credit.OutPoint,
+				Reason:   RestrictionDenylisted,
+				PkScript: script,
+			})
+		}
+	}
+
+	return outputs, nil
+}
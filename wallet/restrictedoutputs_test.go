@@ -0,0 +1,74 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestListRestrictedOutputs ensures that leased, frozen and denylisted outputs
+// are all listed as restricted, each with the reason it's restricted.
+func TestListRestrictedOutputs(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	// Nothing is restricted until the outputs are.
+	restricted, err := w.ListRestrictedOutputs()
+	require.NoError(t, err)
+	require.Empty(t, restricted)
+
+	outPoint := func(tx *wire.MsgTx) wire.OutPoint {
+		return wire.OutPoint{Hash: tx.TxHash()}
+	}
+	leased := outPoint(fundWallet(t, w, 100000))
+	frozen := outPoint(fundWallet(t, w, 200000))
+	denylisted := outPoint(fundWallet(t, w, 300000))
+	scriptTx := fundWallet(t, w, 400000)
+	fundWallet(t, w, 500000)
+
+	lockID := wtxmgr.LockID{1}
+	expiry, err := w.LeaseOutput(lockID, leased, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, w.FreezeOutput(frozen))
+	require.NoError(t, w.AddToSelectionDenylist(wtxmgr.DenylistEntry{
+		OutPoint: &denylisted,
+	}))
+	pkScript := scriptTx.TxOut[0].PkScript
+	require.NoError(t, w.AddToSelectionDenylist(wtxmgr.DenylistEntry{
+		PkScript: pkScript,
+	}))
+
+	// Lease expiries are persisted with a precision of a second.
+	restricted, err = w.ListRestrictedOutputs()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []RestrictedOutput{
+		{
+			OutPoint:   leased,
+			Reason:     RestrictionLeased,
+			LockID:     lockID,
+			Expiration: time.Unix(expiry.Unix(), 0),
+		},
+		{
+			OutPoint: frozen,
+			Reason:   RestrictionFrozen,
+		},
+		{
+			OutPoint: denylisted,
+			Reason:   RestrictionDenylisted,
+		},
+		{
+			OutPoint: outPoint(scriptTx),
+			Reason:   RestrictionDenylisted,
+			PkScript: pkScript,
+		},
+	}, restricted)
+}
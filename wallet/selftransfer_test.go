@@ -0,0 +1,113 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSelfTransfer ensures that an output sent from one of the wallet's
+// accounts to another is recorded as a self-transfer, distinctly from the
+// change of the transaction, with the balances of the accounts netting to the
+// fee paid.
+func TestSelfTransfer(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	const (
+		funds  = btcutil.SatoshiPerBitcoin
+		amount = btcutil.SatoshiPerBitcoin / 10
+	)
+	fundWallet(t, w, funds)
+
+	account, err := w.NextAccount(waddrmgr.KeyScopeBIP0084, "savings")
+	require.NoError(t, err)
+	addr, err := w.NewAddress(account, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	tx, err := w.SendOutputs(
+		[]*wire.TxOut{wire.NewTxOut(amount, pkScript)},
+		&waddrmgr.KeyScopeBIP0084, 0, 1, 1000, CoinSelectionLargest,
+		"",
+	)
+	require.NoError(t, err)
+	require.Len(t, tx.TxOut, 2)
+
+	fee := btcutil.Amount(funds)
+	for _, txOut := range tx.TxOut {
+		fee -= btcutil.Amount(txOut.Value)
+	}
+	require.True(t, fee > 0)
+
+	// The wallet's balance is only reduced by the fee, with the amount
+	// sent moving from the spending account to the receiving one.
+	balance, err := w.CalculateBalance(0)
+	require.NoError(t, err)
+	require.Equal(t, funds-fee, balance)
+
+	balances, err := w.CalculateAccountBalances(0, 0)
+	require.NoError(t, err)
+	require.Equal(t, funds-amount-fee, balances.Total)
+
+	balances, err = w.CalculateAccountBalances(account, 0)
+	require.NoError(t, err)
+	require.Equal(t, btcutil.Amount(amount), balances.Total)
+
+	// The output paying to the receiving account is a self-transfer,
+	// while the other one remains change.
+	var details *wtxmgr.TxDetails
+	txHash := tx.TxHash()
+	view := func(dbtx walletdb.ReadTx) error {
+		ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		details, err = w.TxStore.TxDetails(ns, &txHash)
+		return err
+	}
+	require.NoError(t, walletdb.View(w.db, view))
+	require.Len(t, details.Credits, 2)
+	for _, cred := range details.Credits {
+		if tx.TxOut[cred.Index].Value == amount {
+			require.True(t, cred.SelfTransfer)
+			require.False(t, cred.Change)
+		} else {
+			require.False(t, cred.SelfTransfer)
+			require.True(t, cred.Change)
+		}
+	}
+
+	// The history includes the self-transfer once, under its own
+	// category, rather than as both a send and a receive. Its amount is
+	// zero, such that the history nets to the fee paid.
+	results, err := w.ListTransactions(0, 10)
+	require.NoError(t, err)
+
+	var (
+		categories []string
+		net        float64
+	)
+	for _, result := range results {
+		if result.TxID != txHash.String() {
+			continue
+		}
+		categories = append(categories, result.Category)
+		require.Equal(t, "savings", result.Account)
+		require.Zero(t, result.Amount)
+		require.Equal(t, -fee.ToBTC(), *result.Fee)
+		net += result.Amount + *result.Fee
+	}
+	require.Equal(t, []string{"self-transfer"}, categories)
+	require.Equal(t, -fee.ToBTC(), net)
+}
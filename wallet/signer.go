@@ -86,7 +86,8 @@ type PrivKeyTweaker func(*btcec.PrivateKey) (*btcec.PrivateKey, error)
 // ComputeInputScript generates a complete InputScript for the passed
 // transaction with the signature as defined within the passed SignDescriptor.
 // This method is capable of generating the proper input script for both
-// regular p2wkh output and p2wkh outputs nested within a regular p2sh output.
+// regular p2wkh output and p2wkh outputs nested within a regular p2sh output,
+// as well as legacy p2pkh outputs, for which only a sigScript is returned.
 func (w *Wallet) ComputeInputScript(tx *wire.MsgTx, output *wire.TxOut,
 	inputIndex int, sigHashes *txscript.TxSigHashes,
 	hashType txscript.SigHashType, tweaker PrivKeyTweaker) (wire.TxWitness,
@@ -110,6 +111,20 @@ func (w *Wallet) ComputeInputScript(tx *wire.MsgTx, output *wire.TxOut,
 		}
 	}
 
+	// Legacy inputs are signed through their sigScript alone, with the
+	// signature committing to the output script being spent.
+	if walletAddr.AddrType() == waddrmgr.PubKeyHash {
+		sigScript, err := txscript.SignatureScript(
+			tx, inputIndex, output.PkScript, hashType, privKey,
+			walletAddr.Compressed(),
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return nil, sigScript, nil
+	}
+
 	// Generate a valid witness stack for the input.
 	witnessScript, err := txscript.WitnessSignature(
 		tx, sigHashes, inputIndex, output.Value, witnessProgram,
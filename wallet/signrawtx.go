@@ -0,0 +1,323 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+var (
+	// ErrPrevOutNotFound is returned for an input spending an output that
+	// is neither known to the wallet nor described by the caller.
+	ErrPrevOutNotFound = errors.New("previous output not found")
+
+	// ErrPrevOutMismatch is returned for an input spending an output known
+	// to the wallet that was described with a different script or amount
+	// by the caller.
+	ErrPrevOutMismatch = errors.New("previous output does not match the " +
+		"one known to the wallet")
+
+	// ErrPrevOutAmountUnknown is returned for an input spending a witness
+	// output of unknown amount, which its signature must commit to.
+	ErrPrevOutAmountUnknown = errors.New("amount of witness previous " +
+		"output is unknown")
+)
+
+// RawTxPrevOut describes an output spent by a transaction passed to
+// SignRawTransaction, for outputs which may not be tracked by the wallet.
+type RawTxPrevOut struct {
+	// PkScript is the script of the output.
+	PkScript []byte
+
+	// Amount is the value of the output. It must be set when spending a
+	// witness output, as the signature commits to it.
+	Amount btcutil.Amount
+
+	// RedeemScript is the redeem script of a P2SH output, used if it isn't
+	// known to the wallet.
+	RedeemScript []byte
+}
+
+// rawTxInput holds the previous output details needed to sign and verify an
+// input of a raw transaction.
+type rawTxInput struct {
+	pkScript     []byte
+	amount       btcutil.Amount
+	redeemScript []byte
+
+	// witness is set if the input spends a witness output, either natively
+	// or nested within P2SH.
+	witness bool
+
+	// err records why the input can't be signed.
+	err error
+}
+
+// SignRawTransaction signs every input of the transaction that the wallet has
+// the keys for, mirroring bitcoind's signrawtransactionwithwallet. Inputs that
+// are already signed, fully or partially, are kept as is, or combined with
+// the wallet's signatures in the case of P2SH multisig inputs.
+//
+// The previous outputs spent by the transaction are looked up in the wallet,
+// and any that aren't known to it must be described by prevOuts. To prevent
+// the wallet from being tricked into signing over the wrong amount, which a
+// witness signature commits to, previous outputs known to the wallet must
+// match their description, and witness outputs unknown to it must be
+// described with their amount.
+//
+// Every input which isn't fully signed afterwards has a SignatureError in the
+// returns, so the transaction is complete if there are none. The final error
+// return is reserved for unexpected or fatal errors.
+//
+// The transaction pointed to by tx is modified by this function.
+func (w *Wallet) SignRawTransaction(tx *wire.MsgTx,
+	hashType txscript.SigHashType,
+	prevOuts map[wire.OutPoint]*RawTxPrevOut) ([]SignatureError, error) {
+
+	inputs, err := w.rawTxInputs(tx, prevOuts)
+	if err != nil {
+		return nil, err
+	}
+
+	var signErrors []SignatureError
+	sigHashes := txscript.NewTxSigHashes(tx)
+	for i := range tx.TxIn {
+		input := &inputs[i]
+
+		// SigHashSingle inputs can only be signed if there's a
+		// corresponding output. However this could be already signed,
+		// so we always verify the input.
+		if input.err == nil && ((hashType&txscript.SigHashSingle) !=
+			txscript.SigHashSingle || i < len(tx.TxOut)) {
+
+			input.err = w.signRawTxInput(
+				tx, i, input, sigHashes, hashType,
+			)
+		}
+
+		// Either it was already signed or we just signed it. Find out
+		// if it is completely satisfied or still needs more.
+		if input.err == nil {
+			var vm *txscript.Engine
+			vm, input.err = txscript.NewEngine(
+				input.pkScript, tx, i,
+				txscript.StandardVerifyFlags, nil, sigHashes,
+				int64(input.amount),
+			)
+			if input.err == nil {
+				input.err = vm.Execute()
+			}
+		}
+
+		if input.err != nil {
+			signErrors = append(signErrors, SignatureError{
+				InputIndex: uint32(i),
+				Error:      input.err,
+			})
+		}
+	}
+
+	return signErrors, nil
+}
+
+// rawTxInputs looks up the previous outputs spent by each input of the
+// transaction, either from the wallet or from their description in prevOuts.
+func (w *Wallet) rawTxInputs(tx *wire.MsgTx,
+	prevOuts map[wire.OutPoint]*RawTxPrevOut) ([]rawTxInput, error) {
+
+	inputs := make([]rawTxInput, len(tx.TxIn))
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		for i, txIn := range tx.TxIn {
+			input := &inputs[i]
+			prevOutPoint := txIn.PreviousOutPoint
+			prevOut := prevOuts[prevOutPoint]
+
+			txDetails, err := w.TxStore.TxDetails(
+				txmgrNs, &prevOutPoint.Hash,
+			)
+			if err != nil {
+				return fmt.Errorf("cannot query previous "+
+					"transaction details for %v: %v",
+					prevOutPoint, err)
+			}
+
+			var known *wire.TxOut
+			if txDetails != nil && int(prevOutPoint.Index) <
+				len(txDetails.MsgTx.TxOut) {
+
+				known = txDetails.MsgTx.TxOut[prevOutPoint.Index]
+			}
+
+			switch {
+			case known != nil:
+				input.pkScript = known.PkScript
+				input.amount = btcutil.Amount(known.Value)
+
+				if prevOut != nil && (prevOut.Amount != input.amount ||
+					!bytes.Equal(prevOut.PkScript, known.PkScript)) {
+
+					input.err = fmt.Errorf("%w: %v",
+						ErrPrevOutMismatch, prevOutPoint)
+					continue
+				}
+
+			case prevOut != nil:
+				input.pkScript = prevOut.PkScript
+				input.amount = prevOut.Amount
+
+			default:
+				input.err = fmt.Errorf("%w: %v",
+					ErrPrevOutNotFound, prevOutPoint)
+				continue
+			}
+			if prevOut != nil {
+				input.redeemScript = prevOut.RedeemScript
+			}
+
+			input.witness, err = w.isWitnessPrevOut(
+				addrmgrNs, input.pkScript, txIn,
+			)
+			if err != nil {
+				return err
+			}
+			if input.witness && input.amount <= 0 {
+				input.err = fmt.Errorf("%w: %v",
+					ErrPrevOutAmountUnknown, prevOutPoint)
+			}
+		}
+
+		return nil
+	})
+	return inputs, err
+}
+
+// isWitnessPrevOut returns whether the output script is a witness program, or
+// a P2SH script nesting one. Nested witness programs are detected from the
+// wallet's address type, or from the input's witness if already signed.
+func (w *Wallet) isWitnessPrevOut(addrmgrNs walletdb.ReadBucket,
+	pkScript []byte, txIn *wire.TxIn) (bool, error) {
+
+	switch {
+	case txscript.IsWitnessProgram(pkScript):
+		return true, nil
+
+	case !txscript.IsPayToScriptHash(pkScript):
+		return false, nil
+
+	case len(txIn.Witness) != 0:
+		return true, nil
+	}
+
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+		pkScript, w.chainParams,
+	)
+	if err != nil || len(addrs) == 0 {
+		return false, nil
+	}
+	addr, err := w.Manager.Address(addrmgrNs, addrs[0])
+	switch {
+	case waddrmgr.IsError(err, waddrmgr.ErrAddressNotFound):
+		return false, nil
+
+	case err != nil:
+		return false, err
+	}
+
+	return addr.AddrType() == waddrmgr.NestedWitnessPubKey, nil
+}
+
+// signRawTxInput signs the input at the given index with the wallet's keys.
+// Witness inputs which are already signed are left untouched.
+func (w *Wallet) signRawTxInput(tx *wire.MsgTx, i int, input *rawTxInput,
+	sigHashes *txscript.TxSigHashes, hashType txscript.SigHashType) error {
+
+	txIn := tx.TxIn[i]
+
+	if input.witness {
+		if len(txIn.Witness) != 0 {
+			return nil
+		}
+
+		output := wire.NewTxOut(int64(input.amount), input.pkScript)
+		witness, sigScript, err := w.ComputeInputScript(
+			tx, output, i, sigHashes, hashType, nil,
+		)
+		if err != nil {
+			return err
+		}
+		txIn.Witness = witness
+		txIn.SignatureScript = sigScript
+		return nil
+	}
+
+	return walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+
+		getKey := txscript.KeyClosure(func(addr btcutil.Address) (
+			*btcec.PrivateKey, bool, error) {
+
+			address, err := w.Manager.Address(addrmgrNs, addr)
+			if err != nil {
+				return nil, false, err
+			}
+			pka, ok := address.(waddrmgr.ManagedPubKeyAddress)
+			if !ok {
+				return nil, false, fmt.Errorf("address %v is "+
+					"not a pubkey address", addr)
+			}
+			key, err := pka.PrivKey()
+			if err != nil {
+				return nil, false, err
+			}
+			return key, pka.Compressed(), nil
+		})
+		getScript := txscript.ScriptClosure(func(addr btcutil.Address) (
+			[]byte, error) {
+
+			// Fall back to the redeem script the caller described
+			// the output with if the wallet doesn't know of it.
+			address, err := w.Manager.Address(addrmgrNs, addr)
+			if waddrmgr.IsError(err, waddrmgr.ErrAddressNotFound) &&
+				bytes.Equal(
+					btcutil.Hash160(input.redeemScript),
+					addr.ScriptAddress(),
+				) {
+
+				return input.redeemScript, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			sa, ok := address.(waddrmgr.ManagedScriptAddress)
+			if !ok {
+				return nil, errors.New("address is not a " +
+					"script address")
+			}
+			return sa.Script()
+		})
+
+		script, err := txscript.SignTxOutput(
+			w.chainParams, tx, i, input.pkScript, hashType, getKey,
+			getScript, txIn.SignatureScript,
+		)
+		if err != nil {
+			return err
+		}
+		txIn.SignatureScript = script
+		return nil
+	})
+}
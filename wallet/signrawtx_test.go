@@ -0,0 +1,203 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+)
+
+// roundTripRawTx serializes the transaction to hex and back, as it would be
+// passed through the RPC server.
+func roundTripRawTx(t *testing.T, tx *wire.MsgTx) *wire.MsgTx {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatalf("unable to serialize tx: %v", err)
+	}
+	rawTx, err := hex.DecodeString(hex.EncodeToString(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unable to decode tx hex: %v", err)
+	}
+
+	var decoded wire.MsgTx
+	if err := decoded.Deserialize(bytes.NewReader(rawTx)); err != nil {
+		t.Fatalf("unable to deserialize tx: %v", err)
+	}
+	return &decoded
+}
+
+// TestSignRawTransaction ensures that SignRawTransaction signs every input of
+// a raw transaction it can, and reports those it can't.
+func TestSignRawTransaction(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	// We'll start by adding an output paying to each of the wallet's
+	// default key scopes.
+	scopes := []waddrmgr.KeyScope{
+		waddrmgr.KeyScopeBIP0084,
+		waddrmgr.KeyScopeBIP0049Plus,
+		waddrmgr.KeyScopeBIP0044,
+	}
+	incomingTx := &wire.MsgTx{TxIn: []*wire.TxIn{{}}}
+	for _, scope := range scopes {
+		addr, err := w.NewAddress(0, scope)
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("unable to create pkScript: %v", err)
+		}
+		incomingTx.AddTxOut(wire.NewTxOut(100000, pkScript))
+	}
+	addUtxo(t, w, incomingTx)
+
+	walletOutPoint := func(index uint32) wire.OutPoint {
+		return wire.OutPoint{Hash: incomingTx.TxHash(), Index: index}
+	}
+
+	// We'll also need an output the wallet can't sign for.
+	foreignAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), w.chainParams,
+	)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	foreignPkScript, err := txscript.PayToAddrScript(foreignAddr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+	foreignOutPoint := wire.OutPoint{Hash: chainhash.Hash{1}}
+
+	newTx := func(outPoints ...wire.OutPoint) *wire.MsgTx {
+		tx := wire.NewMsgTx(2)
+		for i := range outPoints {
+			tx.AddTxIn(wire.NewTxIn(&outPoints[i], nil, nil))
+		}
+		tx.AddTxOut(wire.NewTxOut(50000, foreignPkScript))
+		return tx
+	}
+
+	// A transaction spending only the wallet's outputs should be fully
+	// signed.
+	tx := roundTripRawTx(t, newTx(
+		walletOutPoint(0), walletOutPoint(1), walletOutPoint(2),
+	))
+	signErrs, err := w.SignRawTransaction(tx, txscript.SigHashAll, nil)
+	if err != nil {
+		t.Fatalf("unable to sign transaction: %v", err)
+	}
+	if len(signErrs) != 0 {
+		t.Fatalf("expected fully signed transaction, got errors %v",
+			signErrs)
+	}
+
+	signedTx := roundTripRawTx(t, tx)
+	sigHashes := txscript.NewTxSigHashes(signedTx)
+	for i, txIn := range signedTx.TxIn {
+		prevOut := incomingTx.TxOut[txIn.PreviousOutPoint.Index]
+		vm, err := txscript.NewEngine(
+			prevOut.PkScript, signedTx, i,
+			txscript.StandardVerifyFlags, nil, sigHashes,
+			prevOut.Value,
+		)
+		if err != nil {
+			t.Fatalf("unable to create engine: %v", err)
+		}
+		if err := vm.Execute(); err != nil {
+			t.Fatalf("invalid signature for input %d: %v", i, err)
+		}
+	}
+
+	// A transaction also spending an output it can't sign for should only
+	// be partially signed.
+	prevOuts := map[wire.OutPoint]*RawTxPrevOut{
+		foreignOutPoint: {
+			PkScript: foreignPkScript,
+			Amount:   100000,
+		},
+	}
+	tx = roundTripRawTx(t, newTx(walletOutPoint(0), foreignOutPoint))
+	signErrs, err = w.SignRawTransaction(tx, txscript.SigHashAll, prevOuts)
+	if err != nil {
+		t.Fatalf("unable to sign transaction: %v", err)
+	}
+	if len(signErrs) != 1 || signErrs[0].InputIndex != 1 {
+		t.Fatalf("expected error for second input only, got %v",
+			signErrs)
+	}
+	tx = roundTripRawTx(t, tx)
+	if len(tx.TxIn[0].Witness) == 0 {
+		t.Fatalf("expected wallet input to be signed")
+	}
+	if len(tx.TxIn[1].Witness) != 0 {
+		t.Fatalf("expected foreign input to be left unsigned")
+	}
+
+	// Inputs whose previous outputs can't be safely determined should not
+	// be signed.
+	tests := []struct {
+		name     string
+		prevOuts map[wire.OutPoint]*RawTxPrevOut
+		outPoint wire.OutPoint
+		err      error
+	}{
+		{
+			name:     "unknown previous output",
+			outPoint: foreignOutPoint,
+			err:      ErrPrevOutNotFound,
+		},
+		{
+			name: "mismatched previous output amount",
+			prevOuts: map[wire.OutPoint]*RawTxPrevOut{
+				walletOutPoint(0): {
+					PkScript: incomingTx.TxOut[0].PkScript,
+					Amount:   200000,
+				},
+			},
+			outPoint: walletOutPoint(0),
+			err:      ErrPrevOutMismatch,
+		},
+		{
+			name: "unknown witness previous output amount",
+			prevOuts: map[wire.OutPoint]*RawTxPrevOut{
+				foreignOutPoint: {PkScript: foreignPkScript},
+			},
+			outPoint: foreignOutPoint,
+			err:      ErrPrevOutAmountUnknown,
+		},
+	}
+	for _, test := range tests {
+		tx := newTx(test.outPoint)
+		signErrs, err := w.SignRawTransaction(
+			tx, txscript.SigHashAll, test.prevOuts,
+		)
+		if err != nil {
+			t.Fatalf("%s: unable to sign transaction: %v",
+				test.name, err)
+		}
+		if len(signErrs) != 1 || !errors.Is(signErrs[0].Error, test.err) {
+			t.Fatalf("%s: expected %v, got %v", test.name,
+				test.err, signErrs)
+		}
+		if len(tx.TxIn[0].Witness) != 0 {
+			t.Fatalf("%s: expected input to be left unsigned",
+				test.name)
+		}
+	}
+}
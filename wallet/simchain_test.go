@@ -0,0 +1,104 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSimChainReorg ensures that a wallet synced to a SimClient records a
+// transaction paying to it once it enters the mempool, then once confirmed,
+// and moves it to the block confirming it again after a reorg.
+func TestSimChainReorg(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+	w.recoveryWindow = 0
+
+	params := &chaincfg.TestNet3Params
+	err := walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		return w.Manager.SetBirthdayBlock(ns, waddrmgr.BlockStamp{
+			Hash:      *params.GenesisHash,
+			Timestamp: params.GenesisBlock.Header.Timestamp,
+		}, true)
+	})
+	require.NoError(t, err)
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	sim := chain.NewSimClient(params)
+	w.chainClient = nil
+	w.SynchronizeRPC(sim)
+	defer func() {
+		w.Stop()
+		w.WaitForShutdown()
+	}()
+	require.NoError(t, sim.Start())
+
+	require.Eventually(t, func() bool {
+		return w.ChainSynced()
+	}, 10*time.Second, 10*time.Millisecond)
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(&wire.TxIn{})
+	tx.AddTxOut(wire.NewTxOut(100000, pkScript))
+	txHash := tx.TxHash()
+
+	// txHeight returns the height of the block the wallet recorded the
+	// transaction as confirmed in, which is -1 if unconfirmed, or -2 if
+	// the wallet doesn't know the transaction.
+	txHeight := func() int32 {
+		var height int32 = -2
+		err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+			ns := dbtx.ReadBucket(wtxmgrNamespaceKey)
+			details, err := w.TxStore.TxDetails(ns, &txHash)
+			if err != nil || details == nil {
+				return err
+			}
+			height = details.Block.Height
+			return nil
+		})
+		require.NoError(t, err)
+		return height
+	}
+	// recorded waits for the wallet to be synced to the tip of the chain
+	// and to record the transaction at the given height.
+	recorded := func(height int32) {
+		t.Helper()
+
+		_, bestHeight, err := sim.GetBestBlock()
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			return txHeight() == height &&
+				w.Manager.SyncedTo().Height == bestHeight
+		}, 10*time.Second, 10*time.Millisecond)
+	}
+
+	sim.AddMempoolTx(tx)
+	recorded(-1)
+
+	sim.ConnectBlock(tx)
+	recorded(1)
+
+	// Once the block confirming the transaction is reorged out in favor
+	// of a chain confirming it one block later, the wallet should follow.
+	_, err = sim.Reorg(1, nil, []*wire.MsgTx{tx})
+	require.NoError(t, err)
+	recorded(2)
+}
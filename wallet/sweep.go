@@ -0,0 +1,197 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"github.com/btcsuite/btcwallet/wallet/txsizes"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+var (
+	// ErrOutputUnavailable is returned when attempting to sweep an output
+	// owned by the wallet that is either spent, leased, or an immature
+	// coinbase output.
+	ErrOutputUnavailable = errors.New("output is unavailable for spending")
+)
+
+// SweepOutputs creates a signed transaction spending all of the given outputs
+// to destAddr at the given fee rate, in satoshis per kB. The transaction has
+// no change output, as the fee is subtracted from the swept amount. Each output
+// must be owned by the wallet and be available for spending, otherwise
+// ErrNotMine or ErrOutputUnavailable is returned respectively.
+//
+// NOTE: The transaction is not published, and its inputs are not locked.
+func (w *Wallet) SweepOutputs(outpoints []wire.OutPoint,
+	destAddr btcutil.Address, feeRate btcutil.Amount) (*wire.MsgTx, error) {
+
+	if len(outpoints) == 0 {
+		return nil, errors.New("no outputs to sweep")
+	}
+
+	pkScript, err := txscript.PayToAddrScript(destAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	credits, err := w.sweepableCredits(outpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, _, err := w.createSweepTx(credits, pkScript, feeRate)
+	return tx, err
+}
+
+// sweepableCredits returns the credits for the given outpoints, ensuring each
+// is owned by the wallet and available for spending.
+func (w *Wallet) sweepableCredits(
+	outpoints []wire.OutPoint) ([]wtxmgr.Credit, error) {
+
+	credits := make([]wtxmgr.Credit, 0, len(outpoints))
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		unspent, err := w.TxStore.UnspentOutputs(txmgrNs)
+		if err != nil {
+			return err
+		}
+		available := make(map[wire.OutPoint]wtxmgr.Credit, len(unspent))
+		for _, credit := range unspent {
+			available[credit.OutPoint] = credit
+		}
+
+		syncedHeight := w.Manager.SyncedTo().Height
+		maturity := int32(w.chainParams.CoinbaseMaturity)
+		seen := make(map[wire.OutPoint]struct{}, len(outpoints))
+		for _, op := range outpoints {
+			if _, ok := seen[op]; ok {
+				return fmt.Errorf("duplicate output %v", op)
+			}
+			seen[op] = struct{}{}
+
+			credit, ok := available[op]
+			if !ok {
+				return w.unavailableOutputError(txmgrNs, op)
+			}
+			if w.LockedOutpoint(op) {
+				return fmt.Errorf("%w: %v is locked",
+					ErrOutputUnavailable, op)
+			}
+			if credit.FromCoinBase &&
+				!confirmed(maturity, credit.Height, syncedHeight) {
+
+				return fmt.Errorf("%w: %v is an immature "+
+					"coinbase output", ErrOutputUnavailable,
+					op)
+			}
+
+			credits = append(credits, credit)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+// unavailableOutputError determines why an output is not among the wallet's
+// unspent outputs. ErrNotMine is returned if the output isn't owned by the
+// wallet, and ErrOutputUnavailable otherwise.
+func (w *Wallet) unavailableOutputError(txmgrNs walletdb.ReadBucket,
+	op wire.OutPoint) error {
+
+	details, err := w.TxStore.TxDetails(txmgrNs, &op.Hash)
+	if err != nil {
+		return err
+	}
+	if details == nil {
+		return fmt.Errorf("%w: %v", ErrNotMine, op)
+	}
+	for _, credit := range details.Credits {
+		if credit.Index == op.Index {
+			return fmt.Errorf("%w: %v is spent or leased",
+				ErrOutputUnavailable, op)
+		}
+	}
+
+	return fmt.Errorf("%w: %v", ErrNotMine, op)
+}
+
+// createSweepTx creates a signed transaction spending all of the given credits
+// to a single output with the given script, paying the given fee rate in
+// satoshis per kB from the swept amount. The inputs may be of any script type
+// supported by the wallet, as each is signed according to its own previous
+// output. The fee paid is returned along with the transaction.
+func (w *Wallet) createSweepTx(credits []wtxmgr.Credit, pkScript []byte,
+	feeRate btcutil.Amount) (*wire.MsgTx, btcutil.Amount, error) {
+
+	var (
+		tx                    = wire.NewMsgTx(wire.TxVersion)
+		prevScripts           = make([][]byte, 0, len(credits))
+		prevValues            = make([]btcutil.Amount, 0, len(credits))
+		total                 btcutil.Amount
+		p2pkh, p2wpkh, nested int
+	)
+	for _, credit := range credits {
+		switch {
+		case txscript.IsPayToScriptHash(credit.PkScript):
+			nested++
+		case txscript.IsPayToWitnessPubKeyHash(credit.PkScript):
+			p2wpkh++
+		default:
+			p2pkh++
+		}
+
+		outPoint := credit.OutPoint
+		tx.AddTxIn(wire.NewTxIn(&outPoint, nil, nil))
+		prevScripts = append(prevScripts, credit.PkScript)
+		prevValues = append(prevValues, credit.Amount)
+		total += credit.Amount
+	}
+
+	output := wire.NewTxOut(0, pkScript)
+	size := txsizes.EstimateVirtualSize(
+		p2pkh, p2wpkh, nested, []*wire.TxOut{output}, 0,
+	)
+	fee := txrules.FeeForSerializeSize(feeRate, size)
+	output.Value = int64(total - fee)
+	if output.Value <= 0 ||
+		txrules.IsDustOutput(output, txrules.DefaultRelayFeePerKb) {
+
+		return nil, 0, fmt.Errorf("outputs totaling %v are unable to "+
+			"cover the sweep fee of %v", total, fee)
+	}
+	tx.AddTxOut(output)
+
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+		err := txauthor.AddAllInputScripts(
+			tx, prevScripts, prevValues,
+			secretSource{w.Manager, addrmgrNs},
+		)
+		if err != nil {
+			return err
+		}
+
+		return validateMsgTx(tx, prevScripts, prevValues)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return tx, fee, nil
+}
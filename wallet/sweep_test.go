@@ -0,0 +1,117 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+)
+
+// TestSweepOutputs ensures that a set of outputs of different script types can
+// be swept together into a single transaction, and that outputs not owned by
+// the wallet are rejected.
+func TestSweepOutputs(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	// Fund the wallet with a p2wpkh and a p2pkh output.
+	var pkScripts [][]byte
+	for _, scope := range []waddrmgr.KeyScope{
+		waddrmgr.KeyScopeBIP0084, waddrmgr.KeyScopeBIP0044,
+	} {
+		addr, err := w.NewAddress(0, scope)
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("unable to create pkScript: %v", err)
+		}
+		pkScripts = append(pkScripts, pkScript)
+	}
+
+	const value = 100000
+	incomingTx := wire.NewMsgTx(wire.TxVersion)
+	incomingTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	for _, pkScript := range pkScripts {
+		incomingTx.AddTxOut(wire.NewTxOut(value, pkScript))
+	}
+	addUtxo(t, w, incomingTx)
+
+	incomingHash := incomingTx.TxHash()
+	outpoints := []wire.OutPoint{
+		{Hash: incomingHash, Index: 0},
+		{Hash: incomingHash, Index: 1},
+	}
+
+	destAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		make([]byte, 20), w.chainParams,
+	)
+	if err != nil {
+		t.Fatalf("unable to create destination address: %v", err)
+	}
+	destScript, err := txscript.PayToAddrScript(destAddr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+
+	// Sweeping both outputs should result in a single output paying to
+	// the destination, with each input signed according to its type.
+	const feeRate = btcutil.Amount(5000)
+	sweepTx, err := w.SweepOutputs(outpoints, destAddr, feeRate)
+	if err != nil {
+		t.Fatalf("unable to sweep outputs: %v", err)
+	}
+	if len(sweepTx.TxIn) != len(outpoints) {
+		t.Fatalf("expected %d inputs, got %d", len(outpoints),
+			len(sweepTx.TxIn))
+	}
+	for i, txIn := range sweepTx.TxIn {
+		if txIn.PreviousOutPoint != outpoints[i] {
+			t.Fatalf("expected input %d to spend %v, got %v", i,
+				outpoints[i], txIn.PreviousOutPoint)
+		}
+	}
+	if len(sweepTx.TxIn[0].Witness) == 0 ||
+		len(sweepTx.TxIn[0].SignatureScript) != 0 {
+
+		t.Fatalf("expected p2wpkh input to only have a witness")
+	}
+	if len(sweepTx.TxIn[1].Witness) != 0 ||
+		len(sweepTx.TxIn[1].SignatureScript) == 0 {
+
+		t.Fatalf("expected p2pkh input to only have a sigScript")
+	}
+	if len(sweepTx.TxOut) != 1 ||
+		!bytes.Equal(sweepTx.TxOut[0].PkScript, destScript) {
+
+		t.Fatalf("expected a single output to the destination")
+	}
+	sweptValue := sweepTx.TxOut[0].Value
+	if sweptValue >= 2*value || sweptValue <= 0 {
+		t.Fatalf("expected swept value below %d, got %d", 2*value,
+			sweptValue)
+	}
+
+	// An output that isn't owned by the wallet should be rejected.
+	foreign := wire.OutPoint{Hash: chainhash.Hash{0x02}}
+	_, err = w.SweepOutputs(
+		[]wire.OutPoint{outpoints[0], foreign}, destAddr, feeRate,
+	)
+	if !errors.Is(err, ErrNotMine) {
+		t.Fatalf("expected ErrNotMine, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// maxTargetChangeCandidates is the maximum number of alternative input
+// selections considered when targeting a change amount.
+const maxTargetChangeCandidates = 100
+
+// targetChange is the change amount targeted by the input selection of a
+// transaction, as set through WithTargetChangeAmount.
+type targetChange struct {
+	amount       btcutil.Amount
+	feeTolerance btcutil.Amount
+}
+
+// WithTargetChangeAmount sets a change amount the input selection of the
+// created transaction aims for, which keeps the wallet from accumulating tiny
+// change outputs that need to be consolidated later. Among the selection of
+// the coin selection strategy and alternative ones, the selection whose change
+// is the closest to the target is picked, as long as its fee doesn't exceed
+// the one of the strategy's selection by more than the fee tolerance.
+//
+// By default, no change amount is targeted, and the inputs are selected
+// according to the coin selection strategy alone.
+func WithTargetChangeAmount(amount,
+	feeTolerance btcutil.Amount) TxCreateOption {
+
+	return func(opts *txCreateOptions) {
+		opts.targetChange = &targetChange{
+			amount:       amount,
+			feeTolerance: feeTolerance,
+		}
+	}
+}
+
+// authorWithTargetChange creates an unsigned transaction paying to the
+// outputs, selecting its inputs among the given credits, in order, according
+// to the coinbase preference. If a change amount is targeted, alternative
+// orders of the credits are tried as well, picking the transaction whose
+// change is the closest to the target within the fee tolerance.
+func authorWithTargetChange(outputs []*wire.TxOut,
+	feeSatPerKb btcutil.Amount, credits []wtxmgr.Credit,
+	changeSource *txauthor.ChangeSource, pref CoinbasePreference,
+	target *targetChange) (*txauthor.AuthoredTx, error) {
+
+	if target == nil {
+		return authorWithCoinbasePreference(
+			outputs, feeSatPerKb, credits, changeSource, pref,
+		)
+	}
+
+	// Every successful attempt invokes the change source, so the script it
+	// produces is reused rather than deriving an address for each.
+	changeSource = reuseChangeScript(changeSource)
+	author := func(credits []wtxmgr.Credit) (*txauthor.AuthoredTx, error) {
+		return authorWithCoinbasePreference(
+			outputs, feeSatPerKb, credits, changeSource, pref,
+		)
+	}
+
+	best, err := author(credits)
+	if err != nil {
+		return nil, err
+	}
+	maxFee := authoredTxFee(best) + target.feeTolerance
+	bestDistance := changeDistance(best, target.amount)
+
+	for _, candidate := range targetChangeCandidates(credits) {
+		tx, err := author(candidate)
+		if _, ok := err.(txauthor.InputSourceError); ok {
+			continue
+		}
+		if errors.Is(err, txauthor.ErrDustChange) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		fee := authoredTxFee(tx)
+		if fee > maxFee {
+			continue
+		}
+
+		distance := changeDistance(tx, target.amount)
+		if distance < bestDistance || (distance == bestDistance &&
+			fee < authoredTxFee(best)) {
+
+			best, bestDistance = tx, distance
+		}
+	}
+
+	return best, nil
+}
+
+// targetChangeCandidates returns the alternative orders in which the credits,
+// ordered by the coin selection strategy, are tried when targeting a change
+// amount: from the smallest to the largest credit, and with each of the first
+// credits selected before the others.
+func targetChangeCandidates(credits []wtxmgr.Credit) [][]wtxmgr.Credit {
+	ascending := append([]wtxmgr.Credit(nil), credits...)
+	sort.Stable(byAmount(ascending))
+	candidates := [][]wtxmgr.Credit{ascending}
+
+	for i := 1; i < len(credits) && i <= maxTargetChangeCandidates; i++ {
+		candidate := make([]wtxmgr.Credit, 0, len(credits))
+		candidate = append(candidate, credits[i])
+		candidate = append(candidate, credits[:i]...)
+		candidate = append(candidate, credits[i+1:]...)
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates
+}
+
+// reuseChangeScript returns a change source producing the script of the given
+// one upon its first invocation, and the same script for every following one.
+func reuseChangeScript(
+	changeSource *txauthor.ChangeSource) *txauthor.ChangeSource {
+
+	var script []byte
+	reusing := *changeSource
+	reusing.NewScript = func() ([]byte, error) {
+		if script != nil {
+			return script, nil
+		}
+
+		var err error
+		script, err = changeSource.NewScript()
+		return script, err
+	}
+
+	return &reusing
+}
+
+// authoredTxFee returns the fee paid by the transaction.
+func authoredTxFee(tx *txauthor.AuthoredTx) btcutil.Amount {
+	fee := tx.TotalInput
+	for _, txOut := range tx.Tx.TxOut {
+		fee -= btcutil.Amount(txOut.Value)
+	}
+	return fee
+}
+
+// changeDistance returns how far the change of the transaction is from the
+// target amount. A transaction without change is as far from it as the target
+// amount itself.
+func changeDistance(tx *txauthor.AuthoredTx,
+	target btcutil.Amount) btcutil.Amount {
+
+	var change btcutil.Amount
+	if tx.ChangeIndex >= 0 {
+		change = btcutil.Amount(tx.Tx.TxOut[tx.ChangeIndex].Value)
+	}
+
+	if change > target {
+		return change - target
+	}
+	return target - change
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTargetChangeAmount ensures that targeting a change amount makes coin
+// selection prefer the selection whose change is the closest to the target,
+// within the fee tolerance.
+func TestTargetChangeAmount(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	fundWallet(t, w, 1000000)
+	fundWallet(t, w, 350000)
+	fundWallet(t, w, 300000)
+
+	tests := []struct {
+		name         string
+		opts         []TxCreateOption
+		inputs       []btcutil.Amount
+		targetChange btcutil.Amount
+	}{
+		{
+			name:   "no target",
+			inputs: []btcutil.Amount{1000000},
+		},
+		{
+			// Only the largest output is selected, as the
+			// selection of the two smaller ones producing change
+			// near the target requires a higher fee.
+			name: "target beyond fee tolerance",
+			opts: []TxCreateOption{
+				WithTargetChangeAmount(150000, 0),
+			},
+			inputs: []btcutil.Amount{1000000},
+		},
+		{
+			name: "target within fee tolerance",
+			opts: []TxCreateOption{
+				WithTargetChangeAmount(150000, 1000),
+			},
+			inputs:       []btcutil.Amount{300000, 350000},
+			targetChange: 150000,
+		},
+		{
+			name: "target above largest output",
+			opts: []TxCreateOption{
+				WithTargetChangeAmount(800000, 1000),
+			},
+			inputs:       []btcutil.Amount{300000, 1000000},
+			targetChange: 800000,
+		},
+	}
+
+	for _, test := range tests {
+		tx, err := w.CreateSimpleTx(
+			nil, 0, []*wire.TxOut{
+				wire.NewTxOut(500000, testScriptP2WKH),
+			}, 1, 1000, CoinSelectionLargest, true, test.opts...,
+		)
+		require.NoError(t, err, test.name)
+		require.ElementsMatch(
+			t, test.inputs, tx.PrevInputValues, test.name,
+		)
+		require.GreaterOrEqual(t, tx.ChangeIndex, 0, test.name)
+
+		// The change only misses the target by the fee paid.
+		if test.targetChange != 0 {
+			change := tx.Tx.TxOut[tx.ChangeIndex].Value
+			require.InDelta(
+				t, int64(test.targetChange), change, 1000,
+				test.name,
+			)
+		}
+	}
+}
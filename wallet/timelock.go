@@ -0,0 +1,518 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet/txrules"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+var (
+	// ErrInvalidTimeLock is returned when attempting to create a deposit
+	// to a time-locked script with a lock-time that isn't a block height.
+	ErrInvalidTimeLock = errors.New("invalid time lock")
+
+	// ErrTimeLockImmature is returned when attempting to spend an output
+	// paying to a time-locked script before its lock-time expires.
+	ErrTimeLockImmature = errors.New("time lock not expired")
+
+	// ErrNotTimeLocked is returned when attempting to spend an output that
+	// isn't a wallet output paying to a time-locked script.
+	ErrNotTimeLocked = errors.New("output not time-locked")
+)
+
+// TimeLockedOutput is a wallet output paying to a time-locked script, as
+// created by CreateTimeLockedDeposit.
+type TimeLockedOutput struct {
+	// OutPoint is the outpoint of the output.
+	OutPoint wire.OutPoint
+
+	// Amount is the amount of the output.
+	Amount btcutil.Amount
+
+	// LockTime is the block height after which the output can be spent.
+	LockTime uint32
+
+	// WitnessScript is the time-locked script the output pays to through
+	// P2WSH.
+	WitnessScript []byte
+}
+
+// timeLockScript returns the script only spendable by the given key once the
+// block height reaches the lock-time:
+//
+//	<lockTime> OP_CHECKLOCKTIMEVERIFY OP_DROP <pubKey> OP_CHECKSIG
+func timeLockScript(pubKey []byte, lockTime uint32) ([]byte, error) {
+	builder := txscript.NewScriptBuilder()
+	builder.AddInt64(int64(lockTime))
+	builder.AddOp(txscript.OP_CHECKLOCKTIMEVERIFY)
+	builder.AddOp(txscript.OP_DROP)
+	builder.AddData(pubKey)
+	builder.AddOp(txscript.OP_CHECKSIG)
+	return builder.Script()
+}
+
+// CreateTimeLockedDeposit creates and sends a transaction depositing the
+// amount to a script only spendable by the wallet once the block height
+// reaches the lock-time, through CLTV. The script commits to a new change key
+// of the account of the BIP 84 key scope, from which the deposit is funded.
+// The script is recorded such that the deposit, and any other output paying to
+// it, is recognized as a wallet output of the account, and is removed again if
+// the deposit can't be sent. Such outputs are
+// excluded from coin selection and reported by CalculateTimeLockedBalance
+// rather than CalculateBalance until the lock-time expires, after which
+// they're selected as any other output of the account, or spent explicitly
+// through SpendTimeLockedOutput. Only block-based lock-times are supported.
+func (w *Wallet) CreateTimeLockedDeposit(account uint32,
+	amount btcutil.Amount, lockTime uint32, minconf int32,
+	satPerKb btcutil.Amount, label string) (*wire.MsgTx,
+	*TimeLockedOutput, error) {
+
+	if lockTime == 0 || lockTime >= txscript.LockTimeThreshold {
+		return nil, nil, fmt.Errorf("%w: lock-time %d is not a block "+
+			"height", ErrInvalidTimeLock, lockTime)
+	}
+
+	chainClient, done, err := w.useChainClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer done()
+
+	var (
+		witnessScript []byte
+		pkScript      []byte
+		scriptAddr    btcutil.Address
+	)
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		addrmgrNs := dbtx.ReadWriteBucket(waddrmgrNamespaceKey)
+		txmgrNs := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+
+		addr, err := w.newChangeAddress(
+			addrmgrNs, account, waddrmgr.KeyScopeBIP0084,
+		)
+		if err != nil {
+			return err
+		}
+		managedAddr, err := w.Manager.Address(addrmgrNs, addr)
+		if err != nil {
+			return err
+		}
+		pubKeyAddr, ok := managedAddr.(waddrmgr.ManagedPubKeyAddress)
+		if !ok {
+			return fmt.Errorf("address %v has no public key", addr)
+		}
+
+		witnessScript, err = timeLockScript(
+			pubKeyAddr.PubKey().SerializeCompressed(), lockTime,
+		)
+		if err != nil {
+			return err
+		}
+		scriptHash := sha256.Sum256(witnessScript)
+		scriptAddr, err = btcutil.NewAddressWitnessScriptHash(
+			scriptHash[:], w.chainParams,
+		)
+		if err != nil {
+			return err
+		}
+		pkScript, err = txscript.PayToAddrScript(scriptAddr)
+		if err != nil {
+			return err
+		}
+
+		return w.TxStore.PutTimeLockedScript(
+			txmgrNs, pkScript, witnessScript, lockTime,
+		)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The script must be recorded before the deposit is sent for its
+	// output to be recognized, so it's removed if the deposit fails.
+	forgetScript := func(err error) error {
+		if delErr := w.forgetTimeLockedScript(pkScript); delErr != nil {
+			log.Errorf("Unable to remove time-locked script %x: %v",
+				pkScript, delErr)
+		}
+		return err
+	}
+
+	// The backend is asked to notify us of the outputs paying to the
+	// script, as for any other wallet address.
+	err = chainClient.NotifyReceived([]btcutil.Address{scriptAddr})
+	if err != nil {
+		return nil, nil, forgetScript(err)
+	}
+
+	tx, err := w.SendOutputs(
+		[]*wire.TxOut{wire.NewTxOut(int64(amount), pkScript)},
+		&waddrmgr.KeyScopeBIP0084, account, minconf, satPerKb,
+		CoinSelectionLargest, label,
+	)
+	if err != nil {
+		return nil, nil, forgetScript(err)
+	}
+
+	for i, txOut := range tx.TxOut {
+		if !bytes.Equal(txOut.PkScript, pkScript) {
+			continue
+		}
+
+		return tx, &TimeLockedOutput{
+			OutPoint: wire.OutPoint{
+				Hash:  tx.TxHash(),
+				Index: uint32(i),
+			},
+			Amount:        amount,
+			LockTime:      lockTime,
+			WitnessScript: witnessScript,
+		}, nil
+	}
+
+	return nil, nil, fmt.Errorf("deposit output missing from "+
+		"transaction %v", tx.TxHash())
+}
+
+// forgetTimeLockedScript removes the time-locked script the output script pays
+// to, unless an unmined transaction of the wallet pays to it, such as a deposit
+// that failed to be published but is kept to be rebroadcast.
+func (w *Wallet) forgetTimeLockedScript(pkScript []byte) error {
+	return walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		txmgrNs := dbtx.ReadWriteBucket(wtxmgrNamespaceKey)
+
+		unmined, err := w.TxStore.UnminedTxs(txmgrNs)
+		if err != nil {
+			return err
+		}
+		for _, tx := range unmined {
+			for _, txOut := range tx.TxOut {
+				if bytes.Equal(txOut.PkScript, pkScript) {
+					return nil
+				}
+			}
+		}
+
+		return w.TxStore.DeleteTimeLockedScript(txmgrNs, pkScript)
+	})
+}
+
+// SpendTimeLockedOutput creates and sends a transaction spending the wallet
+// output paying to a time-locked script to the given address, at the given fee
+// rate. The transaction's lock-time is set to that of the script, with the
+// signature and the script itself provided in the input's witness, such that
+// it can be mined in the block following the one at the lock-time's height.
+// ErrTimeLockImmature is returned if that block isn't the next one.
+func (w *Wallet) SpendTimeLockedOutput(op wire.OutPoint,
+	destination btcutil.Address, satPerKb btcutil.Amount,
+	label string) (*wire.MsgTx, error) {
+
+	if err := w.feeRateBounds.check(satPerKb); err != nil {
+		return nil, err
+	}
+
+	chainClient, done, err := w.useChainClient()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	bs, err := chainClient.BlockStamp()
+	if err != nil {
+		return nil, err
+	}
+
+	destScript, err := txscript.PayToAddrScript(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx *wire.MsgTx
+	err = walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		details, err := w.TxStore.TxDetails(txmgrNs, &op.Hash)
+		if err != nil {
+			return err
+		}
+		if details == nil {
+			return fmt.Errorf("%w: no wallet output %v",
+				ErrNotTimeLocked, op)
+		}
+		var credit *wtxmgr.CreditRecord
+		for i := range details.Credits {
+			if details.Credits[i].Index == op.Index {
+				credit = &details.Credits[i]
+			}
+		}
+		if credit == nil || credit.Spent {
+			return fmt.Errorf("%w: no unspent wallet output %v",
+				ErrNotTimeLocked, op)
+		}
+
+		prevOut := details.MsgTx.TxOut[op.Index]
+		witnessScript, lockTime, ok := w.TxStore.TimeLockedScript(
+			txmgrNs, prevOut.PkScript,
+		)
+		if !ok {
+			return fmt.Errorf("%w: output %v", ErrNotTimeLocked, op)
+		}
+		if int64(lockTime) > int64(bs.Height) {
+			return fmt.Errorf("%w: output %v is locked until "+
+				"height %d, current height is %d",
+				ErrTimeLockImmature, op, lockTime, bs.Height)
+		}
+
+		tx, err = w.timeLockedSpend(
+			addrmgrNs, op, prevOut, witnessScript, lockTime,
+			destScript, satPerKb,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.reliablyPublishTransaction(tx, label, nil); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// timeLockedSpend creates the signed transaction spending the output paying
+// to the time-locked script to the destination script.
+func (w *Wallet) timeLockedSpend(addrmgrNs walletdb.ReadBucket,
+	op wire.OutPoint, prevOut *wire.TxOut, witnessScript []byte,
+	lockTime uint32, destScript []byte,
+	satPerKb btcutil.Amount) (*wire.MsgTx, error) {
+
+	// The script commits to the key of a BIP 84 change address, whose
+	// private key signs the spend.
+	keyAddr, err := w.timeLockedKeyAddress(witnessScript)
+	if err != nil {
+		return nil, err
+	}
+	managedAddr, err := w.Manager.Address(addrmgrNs, keyAddr)
+	if err != nil {
+		return nil, err
+	}
+	pubKeyAddr, ok := managedAddr.(waddrmgr.ManagedPubKeyAddress)
+	if !ok {
+		return nil, fmt.Errorf("address %v has no public key", keyAddr)
+	}
+	privKey, err := pubKeyAddr.PrivKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// The input's sequence must be non-final for the lock-time to be
+	// enforced.
+	tx := wire.NewMsgTx(relativeLockTimeTxVersion)
+	tx.LockTime = lockTime
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: op,
+		Sequence:         wire.MaxTxInSequenceNum - 1,
+	})
+	tx.AddTxOut(wire.NewTxOut(prevOut.Value, destScript))
+
+	// The fee is computed from the size of the transaction with a
+	// signature of maximum size.
+	tx.TxIn[0].Witness = wire.TxWitness{
+		make([]byte, 73), witnessScript,
+	}
+	fee := txrules.FeeForSerializeSize(satPerKb, txVirtualSize(tx))
+	tx.TxOut[0].Value -= int64(fee)
+	err = txrules.CheckOutput(tx.TxOut[0], txrules.DefaultRelayFeePerKb)
+	if err != nil {
+		return nil, err
+	}
+
+	sigHashes := txscript.NewTxSigHashes(tx)
+	sig, err := txscript.RawTxInWitnessSignature(
+		tx, sigHashes, 0, prevOut.Value, witnessScript,
+		txscript.SigHashAll, privKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	tx.TxIn[0].Witness = wire.TxWitness{sig, witnessScript}
+
+	err = validateMsgTx(
+		tx, [][]byte{prevOut.PkScript},
+		[]btcutil.Amount{btcutil.Amount(prevOut.Value)},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// timeLockedKeyAddress returns the P2WKH address of the key the time-locked
+// script commits to.
+func (w *Wallet) timeLockedKeyAddress(
+	witnessScript []byte) (btcutil.Address, error) {
+
+	pushes, err := txscript.PushedData(witnessScript)
+	if err != nil {
+		return nil, err
+	}
+	if len(pushes) == 0 {
+		return nil, fmt.Errorf("%w: malformed script %x",
+			ErrNotTimeLocked, witnessScript)
+	}
+
+	return btcutil.NewAddressWitnessPubKeyHash(
+		btcutil.Hash160(pushes[len(pushes)-1]), w.chainParams,
+	)
+}
+
+// timeLockedAddrs returns the P2WSH addresses of the wallet's time-locked
+// scripts, such that the outputs paying to them are notified by the backend.
+func (w *Wallet) timeLockedAddrs(
+	txmgrNs walletdb.ReadBucket) ([]btcutil.Address, error) {
+
+	pkScripts, err := w.TxStore.TimeLockedScripts(txmgrNs)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]btcutil.Address, 0, len(pkScripts))
+	for _, pkScript := range pkScripts {
+		_, scriptAddrs, _, err := txscript.ExtractPkScriptAddrs(
+			pkScript, w.chainParams,
+		)
+		if err != nil || len(scriptAddrs) != 1 {
+			continue
+		}
+		addrs = append(addrs, scriptAddrs[0])
+	}
+
+	return addrs, nil
+}
+
+// timeLockMature returns whether the credit can be spent by a transaction
+// mined in the block following the one at the given height, as its absolute
+// lock-time, if any, has expired.
+func timeLockMature(credit *wtxmgr.Credit, height int32) bool {
+	return int64(credit.LockTime) <= int64(height)
+}
+
+// setTimeLocks sets the lock-time of the transaction to the latest absolute
+// lock-time of the credits it spends, if it isn't later already, making the
+// sequences of its inputs non-final such that the lock-time is enforced.
+func setTimeLocks(tx *wire.MsgTx, credits []wtxmgr.Credit) {
+	locks := make(map[wire.OutPoint]uint32)
+	for _, credit := range credits {
+		if credit.LockTime != 0 {
+			locks[credit.OutPoint] = credit.LockTime
+		}
+	}
+	if len(locks) == 0 {
+		return
+	}
+
+	for _, txIn := range tx.TxIn {
+		lockTime, ok := locks[txIn.PreviousOutPoint]
+		if !ok {
+			continue
+		}
+
+		if tx.LockTime < lockTime {
+			tx.LockTime = lockTime
+		}
+		if txIn.Sequence == wire.MaxTxInSequenceNum {
+			txIn.Sequence = wire.MaxTxInSequenceNum - 1
+		}
+	}
+}
+
+// timeLockedSecretSource is a secretSource that also provides the witness
+// scripts of the wallet's time-locked scripts, such that the outputs paying to
+// them can be signed for once selected.
+type timeLockedSecretSource struct {
+	secretSource
+	txStore *wtxmgr.Store
+	txmgrNs walletdb.ReadBucket
+}
+
+// GetScript returns the time-locked script the P2WSH address pays to, if any,
+// or the script known to the address manager otherwise.
+func (s timeLockedSecretSource) GetScript(addr btcutil.Address) ([]byte,
+	error) {
+
+	if _, ok := addr.(*btcutil.AddressWitnessScriptHash); ok {
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		witnessScript, _, ok := s.txStore.TimeLockedScript(
+			s.txmgrNs, pkScript,
+		)
+		if ok {
+			return witnessScript, nil
+		}
+	}
+
+	return s.secretSource.GetScript(addr)
+}
+
+// CalculateTimeLockedBalance sums the amounts of the unspent outputs paying to
+// the wallet's time-locked scripts whose lock-time hasn't expired yet, which
+// are excluded from the balance returned by CalculateBalance. The confirmations
+// are interpreted as by CalculateBalance.
+func (w *Wallet) CalculateTimeLockedBalance(
+	confirms int32) (btcutil.Amount, error) {
+
+	var balance btcutil.Amount
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		txmgrNs := tx.ReadBucket(wtxmgrNamespaceKey)
+		var err error
+		blk := w.Manager.SyncedTo()
+		balance, err = w.timeLockedBalance(
+			txmgrNs, confirms, blk.Height,
+		)
+		return err
+	})
+	return balance, err
+}
+
+// timeLockedBalance sums the amounts of the unspent outputs with the given
+// number of confirmations paying to time-locked scripts whose lock-time hasn't
+// expired at the given height.
+func (w *Wallet) timeLockedBalance(txmgrNs walletdb.ReadBucket,
+	confirms, height int32) (btcutil.Amount, error) {
+
+	unspent, err := w.TxStore.UnspentOutputs(txmgrNs)
+	if err != nil {
+		return 0, err
+	}
+
+	var balance btcutil.Amount
+	for i := range unspent {
+		output := &unspent[i]
+		if output.LockTime == 0 || timeLockMature(output, height) {
+			continue
+		}
+		if !confirmed(confirms, output.Height, height) {
+			continue
+		}
+		balance += output.Amount
+	}
+
+	return balance, nil
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTimeLockedDeposit ensures that deposits to a time-locked script are
+// recognized as wallet outputs excluded from coin selection until their
+// lock-time expires, which can only be spent once the current block height
+// reaches their lock-time, through a transaction with that lock-time providing
+// the script in its witness.
+func TestTimeLockedDeposit(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	fundWallet(t, w, 1000000)
+
+	bs, err := w.chainClient.BlockStamp()
+	require.NoError(t, err)
+	destAddr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+
+	// Lock-times must be block heights.
+	_, _, err = w.CreateTimeLockedDeposit(
+		0, 100000, txscript.LockTimeThreshold, 1, 1000, "",
+	)
+	require.True(t, errors.Is(err, ErrInvalidTimeLock))
+
+	// The script of a deposit that can't be funded isn't kept.
+	_, _, err = w.CreateTimeLockedDeposit(
+		0, 2000000, uint32(bs.Height), 1, 1000, "",
+	)
+	var inputErr txauthor.InputSourceError
+	require.True(t, errors.As(err, &inputErr))
+	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(wtxmgrNamespaceKey)
+		scripts, err := w.TxStore.TimeLockedScripts(ns)
+		require.NoError(t, err)
+		require.Empty(t, scripts)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// A deposit locked until after the current height can't be spent yet.
+	lockedHeight := uint32(bs.Height + 10)
+	_, locked, err := w.CreateTimeLockedDeposit(
+		0, 500000, lockedHeight, 1, 1000, "",
+	)
+	require.NoError(t, err)
+	require.Equal(t, lockedHeight, locked.LockTime)
+
+	_, err = w.SpendTimeLockedOutput(locked.OutPoint, destAddr, 1000, "")
+	require.True(t, errors.Is(err, ErrTimeLockImmature))
+
+	// The deposit is a wallet output, but isn't selected to fund other
+	// transactions, which only the change of the deposit can't.
+	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(wtxmgrNamespaceKey)
+		unspent, err := w.TxStore.UnspentOutputs(ns)
+		require.NoError(t, err)
+
+		var found bool
+		for _, credit := range unspent {
+			if credit.OutPoint == locked.OutPoint {
+				found = true
+				require.Equal(t, lockedHeight, credit.LockTime)
+			}
+		}
+		require.True(t, found)
+		return nil
+	})
+	require.NoError(t, err)
+
+	outputs := []*wire.TxOut{wire.NewTxOut(600000, testScriptP2WKH)}
+	_, err = w.CreateSimpleTx(
+		nil, 0, outputs, 0, 1000, CoinSelectionLargest, true,
+	)
+	require.True(t, errors.As(err, &inputErr))
+
+	// A deposit locked until the current height can be spent in the next
+	// block.
+	_, spendable, err := w.CreateTimeLockedDeposit(
+		0, 200000, uint32(bs.Height), 0, 1000, "",
+	)
+	require.NoError(t, err)
+
+	spendTx, err := w.SpendTimeLockedOutput(
+		spendable.OutPoint, destAddr, 1000, "",
+	)
+	require.NoError(t, err)
+	require.Equal(t, uint32(bs.Height), spendTx.LockTime)
+	require.Len(t, spendTx.TxIn, 1)
+	require.Equal(t, spendable.OutPoint, spendTx.TxIn[0].PreviousOutPoint)
+	require.EqualValues(
+		t, wire.MaxTxInSequenceNum-1, spendTx.TxIn[0].Sequence,
+	)
+	require.Len(t, spendTx.TxIn[0].Witness, 2)
+	require.Equal(t, spendable.WitnessScript, spendTx.TxIn[0].Witness[1])
+	require.Len(t, spendTx.TxOut, 1)
+	require.Less(t, spendTx.TxOut[0].Value, int64(spendable.Amount))
+
+	// Once spent, the deposit can't be spent again.
+	_, err = w.SpendTimeLockedOutput(
+		spendable.OutPoint, destAddr, 1000, "",
+	)
+	require.True(t, errors.Is(err, ErrNotTimeLocked))
+}
+
+// TestTimeLockedDepositSelection ensures that deposits to a time-locked script
+// are watched along with the wallet's addresses and reported apart from its
+// balance until their lock-time expires, after which they're selected and
+// signed for as any other wallet output.
+func TestTimeLockedDepositSelection(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	fundWallet(t, w, 1000000)
+
+	// The balance is calculated at the synced height, which is kept at
+	// the backend's height.
+	bs, err := w.chainClient.BlockStamp()
+	require.NoError(t, err)
+	setSyncedHeight(t, w, bs.Height)
+
+	_, locked, err := w.CreateTimeLockedDeposit(
+		0, 500000, uint32(bs.Height+10), 1, 1000, "",
+	)
+	require.NoError(t, err)
+	_, mature, err := w.CreateTimeLockedDeposit(
+		0, 200000, uint32(bs.Height), 0, 1000, "",
+	)
+	require.NoError(t, err)
+
+	// Both deposits are watched through the address of their script.
+	err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		addrs, _, err := w.activeData(tx)
+		require.NoError(t, err)
+
+		watched := make(map[string]struct{}, len(addrs))
+		for _, addr := range addrs {
+			watched[addr.String()] = struct{}{}
+		}
+		for _, deposit := range []*TimeLockedOutput{locked, mature} {
+			scriptHash := sha256.Sum256(deposit.WitnessScript)
+			addr, err := btcutil.NewAddressWitnessScriptHash(
+				scriptHash[:], w.chainParams,
+			)
+			require.NoError(t, err)
+			require.Contains(t, watched, addr.String())
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Only the deposit whose lock-time hasn't expired is excluded from the
+	// balance.
+	lockedBalance, err := w.CalculateTimeLockedBalance(0)
+	require.NoError(t, err)
+	require.Equal(t, locked.Amount, lockedBalance)
+
+	balance, err := w.CalculateBalance(0)
+	require.NoError(t, err)
+	var total btcutil.Amount
+	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(wtxmgrNamespaceKey)
+		unspent, err := w.TxStore.UnspentOutputs(ns)
+		require.NoError(t, err)
+		for _, credit := range unspent {
+			total += credit.Amount
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, total-locked.Amount, balance)
+
+	// The deposits belong to the account, though only the matured one is
+	// spendable.
+	bals, err := w.CalculateAccountBalances(0, 0)
+	require.NoError(t, err)
+	require.Equal(t, total, bals.Total)
+	require.Equal(t, total-locked.Amount, bals.Spendable)
+
+	// Spending more than the change of the deposits requires selecting
+	// the matured deposit, which is signed for through its script with the
+	// transaction's lock-time set to that of the script.
+	outputs := []*wire.TxOut{wire.NewTxOut(400000, testScriptP2WKH)}
+	tx, err := w.CreateSimpleTx(
+		nil, 0, outputs, 0, 1000, CoinSelectionLargest, false,
+	)
+	require.NoError(t, err)
+
+	var spendsMature bool
+	for _, txIn := range tx.Tx.TxIn {
+		require.NotEqual(t, locked.OutPoint, txIn.PreviousOutPoint)
+		if txIn.PreviousOutPoint != mature.OutPoint {
+			continue
+		}
+		spendsMature = true
+		require.Less(t, txIn.Sequence, uint32(wire.MaxTxInSequenceNum))
+		require.Equal(t, mature.WitnessScript, txIn.Witness[1])
+	}
+	require.True(t, spendsMature)
+	require.GreaterOrEqual(t, tx.Tx.LockTime, mature.LockTime)
+}
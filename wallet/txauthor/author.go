@@ -12,6 +12,7 @@ import (
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/txsort"
 	"github.com/btcsuite/btcwallet/wallet/txrules"
 	"github.com/btcsuite/btcwallet/wallet/txsizes"
 )
@@ -60,6 +61,26 @@ type AuthoredTx struct {
 	ChangeIndex     int // negative if no change
 }
 
+// ErrDustChange is returned by NewUnsignedTransaction when the change output
+// of a transaction would be dust and the DustChangeError policy is in use.
+var ErrDustChange = errors.New("change output would be dust")
+
+// DustChangePolicy determines what happens to the change of a transaction when
+// a change output for it would be dust.
+type DustChangePolicy uint8
+
+const (
+	// DustChangeToFee adds the change to the transaction fee.
+	DustChangeToFee DustChangePolicy = iota
+
+	// DustChangeToRecipient adds the change to the largest non-change
+	// output of the transaction.
+	DustChangeToRecipient
+
+	// DustChangeError fails the transaction creation with ErrDustChange.
+	DustChangeError
+)
+
 // ChangeSource provides change output scripts for transaction creation.
 type ChangeSource struct {
 	// NewScript is a closure that produces unique change output scripts per
@@ -68,6 +89,10 @@ type ChangeSource struct {
 
 	// ScriptSize is the size in bytes of scripts produced by `NewScript`.
 	ScriptSize int
+
+	// DustPolicy determines what happens to the change when a change
+	// output would be dust. By default, it's added to the fee.
+	DustPolicy DustChangePolicy
 }
 
 // NewUnsignedTransaction creates an unsigned transaction paying to one or more
@@ -82,7 +107,8 @@ type ChangeSource struct {
 // appended to the transaction outputs.  Since the change output may not be
 // necessary, fetchChange is called zero or one times to generate this script.
 // This function must return a P2WPKH script or smaller, otherwise fee estimation
-// will be incorrect.
+// will be incorrect.  If the change output would be dust, the remaining value
+// is handled according to the change source's DustPolicy.
 //
 // If successful, the transaction, total input value spent, and all previous
 // output scripts are returned.  If the input source was unable to provide
@@ -148,12 +174,29 @@ func NewUnsignedTransaction(outputs []*wire.TxOut, feeRatePerKb btcutil.Amount,
 			return nil, err
 		}
 		change := wire.NewTxOut(int64(changeAmount), changeScript)
-		if changeAmount != 0 && !txrules.IsDustOutput(change,
-			txrules.DefaultRelayFeePerKb) {
+		isDust := txrules.IsDustOutput(change, txrules.DefaultRelayFeePerKb)
+		switch {
+		// There's no change left, so no change output is needed.
+		case changeAmount == 0:
 
+		// The change is returned to the wallet through a change output.
+		case !isDust:
 			l := len(outputs)
 			unsignedTransaction.TxOut = append(outputs[:l:l], change)
 			changeIndex = l
+
+		// The change would be dust, so it's either added to one of the
+		// outputs, added to the fee, or rejected depending on the
+		// policy.
+		case changeSource.DustPolicy == DustChangeToRecipient &&
+			len(outputs) > 0:
+
+			unsignedTransaction.TxOut = addToLargestOutput(
+				outputs, changeAmount,
+			)
+
+		case changeSource.DustPolicy == DustChangeError:
+			return nil, ErrDustChange
 		}
 
 		return &AuthoredTx{
@@ -166,6 +209,27 @@ func NewUnsignedTransaction(outputs []*wire.TxOut, feeRatePerKb btcutil.Amount,
 	}
 }
 
+// addToLargestOutput returns a copy of outputs with the amount added to the
+// output of the largest value. The outputs passed in are not modified.
+func addToLargestOutput(outputs []*wire.TxOut,
+	amount btcutil.Amount) []*wire.TxOut {
+
+	largest := 0
+	for i, output := range outputs {
+		if output.Value > outputs[largest].Value {
+			largest = i
+		}
+	}
+
+	newOutputs := make([]*wire.TxOut, len(outputs))
+	copy(newOutputs, outputs)
+	newOutputs[largest] = wire.NewTxOut(
+		outputs[largest].Value+int64(amount), outputs[largest].PkScript,
+	)
+
+	return newOutputs
+}
+
 // RandomizeOutputPosition randomizes the position of a transaction's output by
 // swapping it with a random output.  The new index is returned.  This should be
 // done before signing.
@@ -181,6 +245,38 @@ func (tx *AuthoredTx) RandomizeChangePosition() {
 	tx.ChangeIndex = RandomizeOutputPosition(tx.Tx.TxOut, tx.ChangeIndex)
 }
 
+// SortBIP69 sorts the inputs and outputs of an authored transaction according
+// to BIP 69, keeping the previous output scripts and values of the inputs, as
+// well as the change index, in line with them.  This should be done before
+// signing.
+func (tx *AuthoredTx) SortBIP69() {
+	prevIndexes := make(map[*wire.TxIn]int, len(tx.Tx.TxIn))
+	for i, txIn := range tx.Tx.TxIn {
+		prevIndexes[txIn] = i
+	}
+	var change *wire.TxOut
+	if tx.ChangeIndex >= 0 {
+		change = tx.Tx.TxOut[tx.ChangeIndex]
+	}
+
+	txsort.InPlaceSort(tx.Tx)
+
+	prevScripts := make([][]byte, len(tx.PrevScripts))
+	prevInputValues := make([]btcutil.Amount, len(tx.PrevInputValues))
+	for i, txIn := range tx.Tx.TxIn {
+		prevScripts[i] = tx.PrevScripts[prevIndexes[txIn]]
+		prevInputValues[i] = tx.PrevInputValues[prevIndexes[txIn]]
+	}
+	tx.PrevScripts = prevScripts
+	tx.PrevInputValues = prevInputValues
+
+	for i, txOut := range tx.Tx.TxOut {
+		if txOut == change {
+			tx.ChangeIndex = i
+		}
+	}
+}
+
 // SecretsSource provides private keys and redeem scripts necessary for
 // constructing transaction input signatures.  Secrets are looked up by the
 // corresponding Address for the previous output script.  Addresses for lookup
@@ -237,6 +333,13 @@ func AddAllInputScripts(tx *wire.MsgTx, prevPkScripts [][]byte, inputValues []bt
 			if err != nil {
 				return err
 			}
+		case txscript.IsPayToWitnessScriptHash(pkScript):
+			err := spendWitnessScriptHash(inputs[i], pkScript,
+				int64(inputValues[i]), chainParams, secrets,
+				tx, hashCache, i)
+			if err != nil {
+				return err
+			}
 		default:
 			sigScript := inputs[i].SignatureScript
 			script, err := txscript.SignTxOutput(chainParams, tx, i,
@@ -304,6 +407,66 @@ func spendWitnessKeyHash(txIn *wire.TxIn, pkScript []byte,
 	return nil
 }
 
+// spendWitnessScriptHash generates, and sets a valid witness for spending the
+// passed p2wsh pkScript with the specified input amount. The witness script is
+// looked up through the secrets source, and must be satisfied by a signature
+// of the key it ends with, such as a script encumbering the key with an
+// absolute lock-time:
+//
+//	<lockTime> OP_CHECKLOCKTIMEVERIFY OP_DROP <pubKey> OP_CHECKSIG
+//
+// The transaction's lock-time and the input's sequence must already satisfy
+// any lock-time the script is encumbered by.
+func spendWitnessScriptHash(txIn *wire.TxIn, pkScript []byte,
+	inputValue int64, chainParams *chaincfg.Params, secrets SecretsSource,
+	tx *wire.MsgTx, hashCache *txscript.TxSigHashes, idx int) error {
+
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript,
+		chainParams)
+	if err != nil {
+		return err
+	}
+	witnessScript, err := secrets.GetScript(addrs[0])
+	if err != nil {
+		return err
+	}
+
+	// The key is the last push of the script, which must be followed by
+	// the signature check.
+	if len(witnessScript) == 0 ||
+		witnessScript[len(witnessScript)-1] != txscript.OP_CHECKSIG {
+
+		return errors.New("unsupported witness script")
+	}
+	pushes, err := txscript.PushedData(witnessScript)
+	if err != nil {
+		return err
+	}
+	if len(pushes) == 0 {
+		return errors.New("unsupported witness script")
+	}
+	pubKeyAddr, err := btcutil.NewAddressPubKey(
+		pushes[len(pushes)-1], chainParams,
+	)
+	if err != nil {
+		return err
+	}
+	privKey, _, err := secrets.GetKey(pubKeyAddr)
+	if err != nil {
+		return err
+	}
+
+	sig, err := txscript.RawTxInWitnessSignature(tx, hashCache, idx,
+		inputValue, witnessScript, txscript.SigHashAll, privKey)
+	if err != nil {
+		return err
+	}
+
+	txIn.Witness = wire.TxWitness{sig, witnessScript}
+
+	return nil
+}
+
 // spendNestedWitnessPubKey generates both a sigScript, and valid witness for
 // spending the passed pkScript with the specified input amount. The generated
 // sigScript is the version 0 p2wkh witness program corresponding to the queried
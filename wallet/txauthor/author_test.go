@@ -220,3 +220,81 @@ func TestNewUnsignedTransaction(t *testing.T) {
 		}
 	}
 }
+
+// TestNewUnsignedTransactionDustChangePolicy ensures dust change is handled
+// according to the change source's dust policy.
+func TestNewUnsignedTransactionDustChangePolicy(t *testing.T) {
+	const (
+		relayFee   = btcutil.Amount(1e3)
+		dustChange = btcutil.Amount(100)
+	)
+	fee := txrules.FeeForSerializeSize(relayFee, txsizes.EstimateVirtualSize(
+		1, 0, 0, p2pkhOutputs(0, 0), txsizes.P2WPKHPkScriptSize,
+	))
+	outputs := p2pkhOutputs(1e6, 1e8-1e6-fee-dustChange)
+
+	tests := []struct {
+		name         string
+		policy       DustChangePolicy
+		outputValues []btcutil.Amount
+		err          error
+	}{
+		{
+			name:         "dust change to fee",
+			policy:       DustChangeToFee,
+			outputValues: []btcutil.Amount{1e6, 1e8 - 1e6 - fee - dustChange},
+		},
+		{
+			name:         "dust change to recipient",
+			policy:       DustChangeToRecipient,
+			outputValues: []btcutil.Amount{1e6, 1e8 - 1e6 - fee},
+		},
+		{
+			name:   "dust change error",
+			policy: DustChangeError,
+			err:    ErrDustChange,
+		},
+	}
+
+	for _, test := range tests {
+		changeSource := &ChangeSource{
+			NewScript: func() ([]byte, error) {
+				return make([]byte, txsizes.P2WPKHPkScriptSize), nil
+			},
+			ScriptSize: txsizes.P2WPKHPkScriptSize,
+			DustPolicy: test.policy,
+		}
+
+		inputSource := makeInputSource(p2pkhOutputs(1e8))
+		tx, err := NewUnsignedTransaction(
+			outputs, relayFee, inputSource, changeSource,
+		)
+		if err != test.err {
+			t.Fatalf("%s: expected error %v, got %v", test.name,
+				test.err, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		if tx.ChangeIndex >= 0 {
+			t.Fatalf("%s: expected no change output", test.name)
+		}
+		if len(tx.Tx.TxOut) != len(test.outputValues) {
+			t.Fatalf("%s: expected %d outputs, got %d", test.name,
+				len(test.outputValues), len(tx.Tx.TxOut))
+		}
+		for i, value := range test.outputValues {
+			got := btcutil.Amount(tx.Tx.TxOut[i].Value)
+			if got != value {
+				t.Fatalf("%s: expected output %d to be %v, "+
+					"got %v", test.name, i, value, got)
+			}
+		}
+
+		// The outputs provided by the caller must not be modified.
+		if outputs[1].Value != int64(1e8-1e6-fee-dustChange) {
+			t.Fatalf("%s: caller outputs were modified", test.name)
+		}
+	}
+}
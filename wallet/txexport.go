@@ -0,0 +1,198 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// ErrUnknownExportFormat is returned when exporting the transaction history in
+// an unsupported format.
+var ErrUnknownExportFormat = errors.New("unknown export format")
+
+// ExportFormat is the format in which ExportTransactions writes the
+// transaction history.
+type ExportFormat uint8
+
+const (
+	// ExportCSV writes the transaction history as CSV, with a header row
+	// followed by a row per transaction.
+	ExportCSV ExportFormat = iota
+
+	// ExportJSON writes the transaction history as newline-delimited JSON,
+	// with an object per transaction.
+	ExportJSON
+)
+
+// String returns the string representation of the export format.
+func (f ExportFormat) String() string {
+	switch f {
+	case ExportCSV:
+		return "csv"
+	case ExportJSON:
+		return "json"
+	default:
+		return "unknown"
+	}
+}
+
+// exportCSVHeader is the header row of the CSV transaction history.
+var exportCSVHeader = []string{
+	"txid", "time", "block_height", "amount", "fee", "label", "category",
+}
+
+// ExportedTransaction is a row of the transaction history written by
+// ExportTransactions. Amounts are in satoshis, with Amount being the net
+// amount the transaction credits to the wallet, which is negative for sends.
+// Fee is only known for transactions funded entirely by the wallet, and is
+// nil otherwise. The block height of unmined transactions is -1.
+type ExportedTransaction struct {
+	TxID        string          `json:"txid"`
+	Time        time.Time       `json:"time"`
+	BlockHeight int32           `json:"block_height"`
+	Amount      btcutil.Amount  `json:"amount"`
+	Fee         *btcutil.Amount `json:"fee,omitempty"`
+	Label       string          `json:"label"`
+	Category    string          `json:"category"`
+}
+
+// csvRecord returns the CSV record of the exported transaction.
+func (t *ExportedTransaction) csvRecord() []string {
+	var fee string
+	if t.Fee != nil {
+		fee = strconv.FormatInt(int64(*t.Fee), 10)
+	}
+
+	return []string{
+		t.TxID,
+		t.Time.UTC().Format(time.RFC3339),
+		strconv.FormatInt(int64(t.BlockHeight), 10),
+		strconv.FormatInt(int64(t.Amount), 10),
+		fee,
+		t.Label,
+		t.Category,
+	}
+}
+
+// ExportTransactions writes a row per wallet transaction mined between the
+// start and end heights, inclusive, to out in the given format, for
+// accounting purposes. As with GetTransactions, an end height of -1 includes
+// unmined transactions as well. The rows are written as the history is read,
+// rather than buffering it in memory.
+func (w *Wallet) ExportTransactions(out io.Writer, format ExportFormat,
+	startHeight, endHeight int32) error {
+
+	var writeRow func(*ExportedTransaction) error
+	var flush func() error
+	switch format {
+	case ExportCSV:
+		csvWriter := csv.NewWriter(out)
+		if err := csvWriter.Write(exportCSVHeader); err != nil {
+			return err
+		}
+		writeRow = func(t *ExportedTransaction) error {
+			return csvWriter.Write(t.csvRecord())
+		}
+		flush = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+
+	case ExportJSON:
+		encoder := json.NewEncoder(out)
+		writeRow = func(t *ExportedTransaction) error {
+			return encoder.Encode(t)
+		}
+		flush = func() error {
+			return nil
+		}
+
+	default:
+		return fmt.Errorf("%w: %v", ErrUnknownExportFormat, format)
+	}
+
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+		syncHeight := w.Manager.SyncedTo().Height
+
+		rangeFn := func(details []wtxmgr.TxDetails) (bool, error) {
+			for i := range details {
+				row := w.exportedTransaction(
+					&details[i], syncHeight,
+				)
+				if err := writeRow(row); err != nil {
+					return false, err
+				}
+			}
+			return false, nil
+		}
+
+		return w.TxStore.RangeTransactions(
+			txmgrNs, startHeight, endHeight, rangeFn,
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+// exportedTransaction returns the exported row of the wallet transaction,
+// given the height the wallet is synced to.
+func (w *Wallet) exportedTransaction(details *wtxmgr.TxDetails,
+	syncHeight int32) *ExportedTransaction {
+
+	var debited, credited btcutil.Amount
+	for _, deb := range details.Debits {
+		debited += deb.Amount
+	}
+	for _, cred := range details.Credits {
+		credited += cred.Amount
+	}
+
+	row := &ExportedTransaction{
+		TxID:        details.Hash.String(),
+		Time:        details.Received,
+		BlockHeight: details.Block.Height,
+		Amount:      credited - debited,
+		Label:       details.Label,
+	}
+
+	if fee, ok := txFee(details); ok {
+		row.Fee = &fee
+	}
+
+	switch {
+	case blockchain.IsCoinBaseTx(&details.MsgTx):
+		row.Category = RecvCategory(
+			details, syncHeight, w.chainParams,
+		).String()
+
+	case len(details.Debits) == 0:
+		row.Category = "receive"
+
+	// A transaction spending the wallet's outputs to the wallet alone
+	// only moves funds between its addresses.
+	case len(details.Credits) == len(details.MsgTx.TxOut):
+		row.Category = "self-transfer"
+
+	default:
+		row.Category = "send"
+	}
+
+	return row
+}
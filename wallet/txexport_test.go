@@ -0,0 +1,139 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExportTransactions ensures that the transaction history is exported as
+// CSV and newline-delimited JSON with a row per wallet transaction matching
+// the stored transactions.
+func TestExportTransactions(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	const (
+		funds  = 1000000
+		amount = 100000
+		label  = "rent, march"
+	)
+	fundingTx := fundWallet(t, w, funds)
+	sendTx, err := w.SendOutputs(
+		[]*wire.TxOut{wire.NewTxOut(amount, testScriptP2WKH)},
+		&waddrmgr.KeyScopeBIP0084, 0, 1, 1000, CoinSelectionLargest,
+		label,
+	)
+	require.NoError(t, err)
+
+	fee := btcutil.Amount(funds)
+	for _, txOut := range sendTx.TxOut {
+		fee -= btcutil.Amount(txOut.Value)
+	}
+
+	// The funding transaction was received in a block, while the send
+	// remains unmined.
+	expected := []ExportedTransaction{{
+		TxID:        fundingTx.TxHash().String(),
+		BlockHeight: testBlockHeight,
+		Amount:      funds,
+		Category:    "receive",
+	}, {
+		TxID:        sendTx.TxHash().String(),
+		BlockHeight: -1,
+		Amount:      -(amount + fee),
+		Fee:         &fee,
+		Label:       label,
+		Category:    "send",
+	}}
+
+	// assertRows asserts that the exported rows match the expected ones,
+	// ignoring the times at which the transactions were received.
+	assertRows := func(rows []ExportedTransaction) {
+		t.Helper()
+
+		require.Len(t, rows, len(expected))
+		for i := range rows {
+			require.False(t, rows[i].Time.IsZero())
+			rows[i].Time = time.Time{}
+		}
+		require.Equal(t, expected, rows)
+	}
+
+	var buf bytes.Buffer
+	err = w.ExportTransactions(&buf, ExportJSON, 0, -1)
+	require.NoError(t, err)
+
+	var rows []ExportedTransaction
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var row ExportedTransaction
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+		rows = append(rows, row)
+	}
+	require.NoError(t, scanner.Err())
+	assertRows(rows)
+
+	buf.Reset()
+	err = w.ExportTransactions(&buf, ExportCSV, 0, -1)
+	require.NoError(t, err)
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, exportCSVHeader, records[0])
+
+	rows = rows[:0]
+	for _, record := range records[1:] {
+		parsedTime, err := time.Parse(time.RFC3339, record[1])
+		require.NoError(t, err)
+		height, err := strconv.ParseInt(record[2], 10, 32)
+		require.NoError(t, err)
+		amount, err := strconv.ParseInt(record[3], 10, 64)
+		require.NoError(t, err)
+
+		row := ExportedTransaction{
+			TxID:        record[0],
+			Time:        parsedTime,
+			BlockHeight: int32(height),
+			Amount:      btcutil.Amount(amount),
+			Label:       record[5],
+			Category:    record[6],
+		}
+		if record[4] != "" {
+			fee, err := strconv.ParseInt(record[4], 10, 64)
+			require.NoError(t, err)
+			rowFee := btcutil.Amount(fee)
+			row.Fee = &rowFee
+		}
+		rows = append(rows, row)
+	}
+	assertRows(rows)
+
+	// Only the mined transaction is exported when excluding unmined ones.
+	buf.Reset()
+	err = w.ExportTransactions(&buf, ExportCSV, 0, testBlockHeight)
+	require.NoError(t, err)
+	records, err = csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, fundingTx.TxHash().String(), records[1][0])
+
+	err = w.ExportTransactions(&buf, ExportFormat(2), 0, -1)
+	require.True(t, errors.Is(err, ErrUnknownExportFormat), err)
+}
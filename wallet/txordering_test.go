@@ -0,0 +1,65 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil/txsort"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTxOrderingPolicyBIP69 ensures that the inputs and outputs of created
+// transactions are sorted as per BIP 69 under BIP69Ordering, with the change
+// index and previous outputs of the inputs reported accordingly.
+func TestTxOrderingPolicyBIP69(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+	w.SetTxOrderingPolicy(BIP69Ordering)
+
+	prevScripts := make(map[wire.OutPoint][]byte)
+	for i := 0; i < 3; i++ {
+		fundingTx := fundWallet(t, w, 100000)
+		prevScripts[wire.OutPoint{Hash: fundingTx.TxHash()}] =
+			fundingTx.TxOut[0].PkScript
+	}
+
+	// The outputs require all of the wallet's outputs to be spent, with
+	// change left over.
+	outputs := []*wire.TxOut{
+		wire.NewTxOut(150000, testScriptP2WKH),
+		wire.NewTxOut(30000, testScriptP2WKH),
+		wire.NewTxOut(70000, testScriptP2WKH),
+	}
+	tx, err := w.CreateSimpleTx(
+		nil, 0, outputs, 1, 1000, CoinSelectionLargest, false,
+	)
+	require.NoError(t, err)
+	require.Len(t, tx.Tx.TxIn, 3)
+	require.Len(t, tx.Tx.TxOut, 4)
+	require.True(t, txsort.IsSorted(tx.Tx))
+
+	// The previous outputs of the inputs should follow them.
+	for i, txIn := range tx.Tx.TxIn {
+		prevScript, ok := prevScripts[txIn.PreviousOutPoint]
+		require.True(t, ok)
+		require.Equal(t, prevScript, tx.PrevScripts[i])
+		require.EqualValues(t, 100000, tx.PrevInputValues[i])
+	}
+
+	// The change index should point to the output paying to the wallet.
+	require.GreaterOrEqual(t, tx.ChangeIndex, 0)
+	for i, txOut := range tx.Tx.TxOut {
+		_, err := w.fetchOutputAddr(txOut.PkScript)
+		if i == tx.ChangeIndex {
+			require.NoError(t, err)
+		} else {
+			require.Error(t, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet/txsizes"
+)
+
+// MaxStandardTxVirtualSize is the largest virtual size of a transaction
+// relayed by nodes under the default standardness rules, corresponding to a
+// weight of 400000.
+const MaxStandardTxVirtualSize = 100000
+
+var (
+	// ErrTxTooLarge is returned when attempting to create a transaction
+	// exceeding the wallet's transaction size limits.
+	ErrTxTooLarge = errors.New("transaction too large")
+
+	// ErrInvalidTxSizeLimits is returned when attempting to set invalid
+	// transaction size limits.
+	ErrInvalidTxSizeLimits = errors.New("invalid transaction size limits")
+)
+
+// DefaultTxSizeLimits are the transaction size limits used by the wallet
+// unless configured otherwise through SetTxSizeLimits: transactions are only
+// limited to the standard virtual size, whatever their number of outputs.
+var DefaultTxSizeLimits = TxSizeLimits{
+	MaxVirtualSize: MaxStandardTxVirtualSize,
+}
+
+// TxSizeLimits are the limits on the transactions created by the wallet,
+// guarding against creating transactions the network won't relay, such as
+// very large batch payments.
+type TxSizeLimits struct {
+	// MaxOutputs is the highest number of outputs of a transaction,
+	// including any change output. A value of zero disables the limit.
+	MaxOutputs int
+
+	// MaxVirtualSize is the highest estimated virtual size of a
+	// transaction once signed. A value of zero disables the limit.
+	MaxVirtualSize int
+}
+
+// checkOutputs returns an error wrapping ErrTxTooLarge if the transaction
+// paying to the outputs is bound to exceed the limits, whatever the inputs
+// selected to fund it.
+func (l *TxSizeLimits) checkOutputs(outputs []*wire.TxOut) error {
+	if l.MaxOutputs > 0 && len(outputs) > l.MaxOutputs {
+		return fmt.Errorf("%w: %d outputs exceed the maximum of %d",
+			ErrTxTooLarge, len(outputs), l.MaxOutputs)
+	}
+
+	// The size of the transaction without any input is the smallest it
+	// can be.
+	size := txsizes.EstimateVirtualSize(0, 0, 0, outputs, 0)
+	if l.MaxVirtualSize > 0 && size > l.MaxVirtualSize {
+		return fmt.Errorf("%w: outputs alone have a virtual size of "+
+			"%d vbytes, exceeding the maximum of %d", ErrTxTooLarge,
+			size, l.MaxVirtualSize)
+	}
+
+	return nil
+}
+
+// checkTx returns an error wrapping ErrTxTooLarge if the transaction, whose
+// inputs spend the given previous output scripts, exceeds the limits once
+// signed.
+func (l *TxSizeLimits) checkTx(tx *wire.MsgTx, prevScripts [][]byte) error {
+	if l.MaxOutputs > 0 && len(tx.TxOut) > l.MaxOutputs {
+		return fmt.Errorf("%w: %d outputs exceed the maximum of %d",
+			ErrTxTooLarge, len(tx.TxOut), l.MaxOutputs)
+	}
+
+	size := estimateVirtualSize(tx, prevScripts)
+	if l.MaxVirtualSize > 0 && size > l.MaxVirtualSize {
+		return fmt.Errorf("%w: virtual size of %d vbytes exceeds the "+
+			"maximum of %d", ErrTxTooLarge, size, l.MaxVirtualSize)
+	}
+
+	return nil
+}
+
+// SetTxSizeLimits sets the limits on the number of outputs and the virtual
+// size of the transactions sent by the wallet. Payments to more outputs than
+// allowed fail with an error wrapping ErrTxTooLarge before any coins are
+// selected, as do those whose transaction would be too large once signed
+// before it is signed. By default, this is DefaultTxSizeLimits.
+//
+// NOTE: This should be called before the wallet is used to create any
+// transactions.
+func (w *Wallet) SetTxSizeLimits(limits TxSizeLimits) error {
+	if limits.MaxOutputs < 0 || limits.MaxVirtualSize < 0 {
+		return fmt.Errorf("%w: limits must not be negative",
+			ErrInvalidTxSizeLimits)
+	}
+
+	w.txSizeLimits = limits
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTxSizeLimits ensures that transactions exceeding the wallet's size
+// limits are rejected before being signed, with payments to too many outputs
+// being rejected before any coins are selected.
+func TestTxSizeLimits(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	newOutputs := func(n int) []*wire.TxOut {
+		outputs := make([]*wire.TxOut, n)
+		for i := range outputs {
+			outputs[i] = wire.NewTxOut(1000, testScriptP2WKH)
+		}
+		return outputs
+	}
+	createTx := func(outputs []*wire.TxOut) error {
+		_, err := w.CreateSimpleTx(
+			nil, 0, outputs, 1, 1000, CoinSelectionLargest, true,
+		)
+		return err
+	}
+
+	// By default, a batch payment whose outputs alone exceed the standard
+	// size is rejected, even though the wallet has no funds to select.
+	outputSize := wire.NewTxOut(0, testScriptP2WKH).SerializeSize()
+	numOutputs := MaxStandardTxVirtualSize/outputSize + 1
+	err := createTx(newOutputs(numOutputs))
+	require.True(t, errors.Is(err, ErrTxTooLarge))
+
+	fundWallet(t, w, 1000000)
+	require.NoError(t, createTx(newOutputs(10)))
+
+	require.True(t, errors.Is(
+		w.SetTxSizeLimits(TxSizeLimits{MaxOutputs: -1}),
+		ErrInvalidTxSizeLimits,
+	))
+
+	// With the number of outputs limited, a payment to too many outputs is
+	// rejected, as is one whose change output exceeds the limit.
+	err = w.SetTxSizeLimits(TxSizeLimits{
+		MaxOutputs:     10,
+		MaxVirtualSize: MaxStandardTxVirtualSize,
+	})
+	require.NoError(t, err)
+	require.True(t, errors.Is(createTx(newOutputs(11)), ErrTxTooLarge))
+	require.True(t, errors.Is(createTx(newOutputs(10)), ErrTxTooLarge))
+	require.NoError(t, createTx(newOutputs(9)))
+
+	// With the virtual size limited, a payment whose outputs fit is still
+	// rejected once its inputs and change are accounted for.
+	err = w.SetTxSizeLimits(TxSizeLimits{MaxVirtualSize: 100})
+	require.NoError(t, err)
+	require.True(t, errors.Is(createTx(newOutputs(1)), ErrTxTooLarge))
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// TxSummary is a detailed view of a wallet transaction, describing each of its
+// inputs and outputs along with whether the wallet owns it.
+type TxSummary struct {
+	// Hash is the hash of the transaction.
+	Hash chainhash.Hash
+
+	// Tx is the transaction itself.
+	Tx *wire.MsgTx
+
+	// BlockHeight is the height of the block the transaction was mined in,
+	// or -1 if it's unmined.
+	BlockHeight int32
+
+	// Fee is the fee paid by the transaction, which is only known if every
+	// one of its inputs is owned by the wallet, and is nil otherwise.
+	Fee *btcutil.Amount
+
+	// Label is the label of the transaction, if any.
+	Label string
+
+	// Inputs describes each of the transaction's inputs, in order.
+	Inputs []TxSummaryInput
+
+	// Outputs describes each of the transaction's outputs, in order.
+	Outputs []TxSummaryOutput
+}
+
+// TxSummaryOwner describes the wallet address an input or output of a
+// transaction summary belongs to.
+type TxSummaryOwner struct {
+	// KeyScope is the key scope of the manager that owns the address.
+	KeyScope waddrmgr.KeyScope
+
+	// Account is the internal account number the address belongs to.
+	Account uint32
+
+	// Internal indicates whether the address was derived for internal use,
+	// such as change.
+	Internal bool
+
+	// DerivationPath is the derivation path of the address' key from the
+	// wallet's root key, or nil if it's unknown, as for script addresses
+	// and imported keys whose origin was not provided.
+	DerivationPath *waddrmgr.DerivationPath
+}
+
+// TxSummaryInput describes an input of a transaction summary. The address and
+// amount of the inputs owned by the wallet are those of the outputs they
+// spend. For external inputs, the address is decoded from the input's
+// signature script and witness, and is nil if it can't be, while their amount
+// is unknown and left as zero.
+type TxSummaryInput struct {
+	// Index is the index of the input within the transaction.
+	Index uint32
+
+	// PreviousOutPoint is the outpoint spent by the input.
+	PreviousOutPoint wire.OutPoint
+
+	// Owned indicates whether the input spends an output of the wallet.
+	Owned bool
+
+	// Address is the address of the output spent by the input.
+	Address btcutil.Address
+
+	// Amount is the amount of the output spent by the input.
+	Amount btcutil.Amount
+
+	// Owner describes the wallet address of the output spent by the input.
+	//
+	// NOTE: This is only set if Owned is true.
+	Owner *TxSummaryOwner
+}
+
+// TxSummaryOutput describes an output of a transaction summary. The address of
+// outputs whose script doesn't pay to a single address is nil.
+type TxSummaryOutput struct {
+	// Index is the index of the output within the transaction.
+	Index uint32
+
+	// Owned indicates whether the output pays to the wallet.
+	Owned bool
+
+	// Address is the address the output pays to.
+	Address btcutil.Address
+
+	// Amount is the amount of the output.
+	Amount btcutil.Amount
+
+	// Label is the label of the output, if any.
+	Label string
+
+	// Owner describes the wallet address the output pays to.
+	//
+	// NOTE: This is only set if Owned is true.
+	Owner *TxSummaryOwner
+}
+
+// TransactionSummary returns a summary of the wallet transaction with the
+// given hash, reporting for each of its inputs and outputs whether the wallet
+// owns it, along with its address and amount, and, for owned ones, the
+// derivation path of their address. ErrTxNotFound is returned if the
+// transaction isn't known to the wallet.
+func (w *Wallet) TransactionSummary(txHash chainhash.Hash) (*TxSummary,
+	error) {
+
+	var summary *TxSummary
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		details, err := w.TxStore.TxDetails(txmgrNs, &txHash)
+		if err != nil {
+			return err
+		}
+		if details == nil {
+			return ErrTxNotFound
+		}
+
+		summary, err = w.txSummary(dbtx, details)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// txSummary assembles the summary of the wallet transaction from its credits
+// and debits.
+func (w *Wallet) txSummary(dbtx walletdb.ReadTx,
+	details *wtxmgr.TxDetails) (*TxSummary, error) {
+
+	txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+	tx := &details.MsgTx
+	summary := &TxSummary{
+		Hash:        details.Hash,
+		Tx:          tx,
+		BlockHeight: details.Block.Height,
+		Label:       details.Label,
+		Inputs:      make([]TxSummaryInput, len(tx.TxIn)),
+		Outputs:     make([]TxSummaryOutput, len(tx.TxOut)),
+	}
+
+	for i, txIn := range tx.TxIn {
+		summary.Inputs[i] = TxSummaryInput{
+			Index:            uint32(i),
+			PreviousOutPoint: txIn.PreviousOutPoint,
+		}
+
+		// The scripts of external inputs are only known as far as
+		// they can be computed from the input itself.
+		pkScript, err := txscript.ComputePkScript(
+			txIn.SignatureScript, txIn.Witness,
+		)
+		if err != nil {
+			continue
+		}
+		addr, err := pkScript.Address(w.chainParams)
+		if err == nil {
+			summary.Inputs[i].Address = addr
+		}
+	}
+	for _, deb := range details.Debits {
+		input := &summary.Inputs[deb.Index]
+		input.Owned = true
+		input.Amount = deb.Amount
+
+		prevOut := input.PreviousOutPoint
+		prev, err := w.TxStore.TxDetails(txmgrNs, &prevOut.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if prev == nil || int(prevOut.Index) >= len(prev.MsgTx.TxOut) {
+			log.Errorf("Missing previous output %v", prevOut)
+			continue
+		}
+
+		pkScript := prev.MsgTx.TxOut[prevOut.Index].PkScript
+		input.Address, input.Owner = w.txSummaryOwner(dbtx, pkScript)
+	}
+
+	for _, cred := range details.Credits {
+		summary.Outputs[cred.Index].Owned = true
+	}
+	for i, txOut := range tx.TxOut {
+		output := &summary.Outputs[i]
+		output.Index = uint32(i)
+		output.Amount = btcutil.Amount(txOut.Value)
+		output.Label = w.TxStore.OutputLabel(txmgrNs, wire.OutPoint{
+			Hash:  details.Hash,
+			Index: uint32(i),
+		})
+
+		addr, owner := w.txSummaryOwner(dbtx, txOut.PkScript)
+		output.Address = addr
+		if output.Owned {
+			output.Owner = owner
+		}
+	}
+
+	if fee, ok := txFee(details); ok {
+		summary.Fee = &fee
+	}
+
+	return summary, nil
+}
+
+// txSummaryOwner returns the address the output script pays to, if it pays to
+// a single one, along with the description of the wallet address if the
+// wallet owns it.
+func (w *Wallet) txSummaryOwner(dbtx walletdb.ReadTx,
+	pkScript []byte) (btcutil.Address, *TxSummaryOwner) {
+
+	addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+		pkScript, w.chainParams,
+	)
+	if err != nil || len(addrs) != 1 {
+		return nil, nil
+	}
+	addr := addrs[0]
+
+	scopedMgr, account, err := w.Manager.AddrAccount(addrmgrNs, addr)
+	if err != nil {
+		return addr, nil
+	}
+	managedAddr, err := scopedMgr.Address(addrmgrNs, addr)
+	if err != nil {
+		return addr, nil
+	}
+
+	owner := &TxSummaryOwner{
+		KeyScope: scopedMgr.Scope(),
+		Account:  account,
+		Internal: managedAddr.Internal(),
+	}
+	pubKeyAddr, ok := managedAddr.(waddrmgr.ManagedPubKeyAddress)
+	if ok {
+		_, path, ok := pubKeyAddr.DerivationInfo()
+		if ok {
+			owner.DerivationPath = &path
+		}
+	}
+
+	return addr, owner
+}
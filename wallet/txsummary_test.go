@@ -0,0 +1,141 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTransactionSummary ensures that the summary of a transaction with both
+// owned and external inputs and outputs reports the ownership, address and
+// amount of each of them, along with the derivation path of the owned ones.
+func TestTransactionSummary(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	fundingTx := fundWallet(t, w, 1000000)
+	_, fundingAddrs, _, err := txscript.ExtractPkScriptAddrs(
+		fundingTx.TxOut[0].PkScript, w.chainParams,
+	)
+	require.NoError(t, err)
+
+	// The transaction spends the wallet's output along with an external
+	// P2WPKH one, and pays to both the wallet and an external address.
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	pubKey := privKey.PubKey().SerializeCompressed()
+	externalInputAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		btcutil.Hash160(pubKey), w.chainParams,
+	)
+	require.NoError(t, err)
+
+	ownedOutputAddr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	ownedPkScript, err := txscript.PayToAddrScript(ownedOutputAddr)
+	require.NoError(t, err)
+	_, externalOutputAddrs, _, err := txscript.ExtractPkScriptAddrs(
+		testScriptP2WKH, w.chainParams,
+	)
+	require.NoError(t, err)
+
+	externalOutPoint := wire.OutPoint{Hash: chainhash.Hash{1}}
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: fundingTx.TxHash()},
+	})
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: externalOutPoint,
+		Witness:          wire.TxWitness{make([]byte, 72), pubKey},
+	})
+	tx.AddTxOut(wire.NewTxOut(300000, testScriptP2WKH))
+	tx.AddTxOut(wire.NewTxOut(900000, ownedPkScript))
+
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+	require.NoError(t, err)
+	err = walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		return w.addRelevantTx(dbtx, rec, nil)
+	})
+	require.NoError(t, err)
+
+	summary, err := w.TransactionSummary(tx.TxHash())
+	require.NoError(t, err)
+	require.Equal(t, tx.TxHash(), summary.Hash)
+	require.EqualValues(t, -1, summary.BlockHeight)
+
+	// The fee can't be known, as one of the inputs is external.
+	require.Nil(t, summary.Fee)
+
+	// ownerPath returns the derivation path of the wallet address.
+	ownerPath := func(addr btcutil.Address) *waddrmgr.DerivationPath {
+		t.Helper()
+
+		info, err := w.AddressInfo(addr)
+		require.NoError(t, err)
+		require.True(t, info.OriginKnown)
+		return &info.DerivationPath
+	}
+
+	require.Len(t, summary.Inputs, 2)
+	ownedInput := summary.Inputs[0]
+	require.True(t, ownedInput.Owned)
+	require.Equal(t, fundingAddrs[0].String(), ownedInput.Address.String())
+	require.EqualValues(t, 1000000, ownedInput.Amount)
+	require.NotNil(t, ownedInput.Owner)
+	require.Equal(t, waddrmgr.KeyScopeBIP0084, ownedInput.Owner.KeyScope)
+	require.Zero(t, ownedInput.Owner.Account)
+	require.Equal(
+		t, ownerPath(fundingAddrs[0]), ownedInput.Owner.DerivationPath,
+	)
+
+	externalInput := summary.Inputs[1]
+	require.EqualValues(t, 1, externalInput.Index)
+	require.Equal(t, externalOutPoint, externalInput.PreviousOutPoint)
+	require.False(t, externalInput.Owned)
+	require.Equal(
+		t, externalInputAddr.String(), externalInput.Address.String(),
+	)
+	require.Zero(t, externalInput.Amount)
+	require.Nil(t, externalInput.Owner)
+
+	require.Len(t, summary.Outputs, 2)
+	externalOutput := summary.Outputs[0]
+	require.False(t, externalOutput.Owned)
+	require.Equal(
+		t, externalOutputAddrs[0].String(),
+		externalOutput.Address.String(),
+	)
+	require.EqualValues(t, 300000, externalOutput.Amount)
+	require.Nil(t, externalOutput.Owner)
+
+	ownedOutput := summary.Outputs[1]
+	require.EqualValues(t, 1, ownedOutput.Index)
+	require.True(t, ownedOutput.Owned)
+	require.Equal(
+		t, ownedOutputAddr.String(), ownedOutput.Address.String(),
+	)
+	require.EqualValues(t, 900000, ownedOutput.Amount)
+	require.NotNil(t, ownedOutput.Owner)
+	require.False(t, ownedOutput.Owner.Internal)
+	require.Equal(
+		t, ownerPath(ownedOutputAddr), ownedOutput.Owner.DerivationPath,
+	)
+
+	// Transactions unknown to the wallet can't be summarized.
+	_, err = w.TransactionSummary(chainhash.Hash{2})
+	require.Equal(t, ErrTxNotFound, err)
+}
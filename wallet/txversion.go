@@ -0,0 +1,313 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet/txsizes"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+const (
+	// MinTxVersion is the lowest version of the transactions created by
+	// the wallet.
+	MinTxVersion = 1
+
+	// MaxTxVersion is the highest version of the transactions created by
+	// the wallet.
+	MaxTxVersion = 3
+
+	// relativeLockTimeTxVersion is the lowest transaction version for
+	// which the relative lock-times of inputs are enforced, as per BIP 68.
+	relativeLockTimeTxVersion = 2
+
+	// trucTxVersion is the version of the transactions subject to the
+	// topologically restricted until confirmation (TRUC) policy, as per
+	// BIP 431.
+	trucTxVersion = 3
+
+	// maxTRUCVirtualSize is the maximum virtual size of a TRUC
+	// transaction.
+	maxTRUCVirtualSize = 10000
+
+	// maxTRUCChildVirtualSize is the maximum virtual size of a TRUC
+	// transaction spending an unconfirmed TRUC transaction.
+	maxTRUCChildVirtualSize = 1000
+)
+
+var (
+	// ErrUnsupportedTxVersion is returned when attempting to create a
+	// transaction with a version outside of the range supported by the
+	// wallet.
+	ErrUnsupportedTxVersion = errors.New("unsupported transaction version")
+
+	// ErrRelativeLockTimeVersion is returned when attempting to create a
+	// transaction with inputs having a relative lock-time with a version
+	// for which they aren't enforced.
+	ErrRelativeLockTimeVersion = errors.New("inputs with a relative " +
+		"lock-time require a transaction version of at least 2")
+
+	// ErrTRUCViolation is returned when attempting to create a transaction
+	// that would violate the TRUC policy.
+	ErrTRUCViolation = errors.New("transaction violates TRUC policy")
+)
+
+// TxCreateOption is a functional option modifying the transactions created by
+// the wallet.
+type TxCreateOption func(*txCreateOptions)
+
+// txCreateOptions contains the parameters of the transactions created by the
+// wallet.
+type txCreateOptions struct {
+	txVersion             int32
+	coinbasePreference    CoinbasePreference
+	ephemeralAnchor       bool
+	fundingLease          *fundingLease
+	targetChange          *targetChange
+	overrideFeeRateBounds bool
+	changeAddress         btcutil.Address
+	changeAddrType        *waddrmgr.AddressType
+	conflictingInputs     map[wire.OutPoint]struct{}
+}
+
+// defaultTxCreateOptions returns the default parameters of the transactions
+// created by the wallet.
+func defaultTxCreateOptions() *txCreateOptions {
+	return &txCreateOptions{
+		txVersion: wire.TxVersion,
+	}
+}
+
+// WithTxVersion sets the version of the created transaction, which defaults to
+// wire.TxVersion. Version 2 is required for transactions spending inputs with
+// a relative lock-time, and version 3 transactions are created according to
+// the TRUC policy of BIP 431.
+func WithTxVersion(version int32) TxCreateOption {
+	return func(opts *txCreateOptions) {
+		opts.txVersion = version
+	}
+}
+
+// checkTxVersion ensures the transaction's version is supported and suitable
+// for its inputs, and that version 3 transactions comply with the TRUC policy
+// given the unconfirmed transactions known to the wallet. The previous output
+// scripts of the inputs are used to estimate the transaction's signed size.
+func (w *Wallet) checkTxVersion(txmgrNs walletdb.ReadBucket, tx *wire.MsgTx,
+	prevScripts [][]byte) error {
+
+	if tx.Version < MinTxVersion || tx.Version > MaxTxVersion {
+		return fmt.Errorf("%w: version %d is not within [%d, %d]",
+			ErrUnsupportedTxVersion, tx.Version, MinTxVersion,
+			MaxTxVersion)
+	}
+
+	if tx.Version < relativeLockTimeTxVersion {
+		// A relative lock-time of zero doesn't constrain the input, so
+		// it doesn't require the version to be enforced.
+		for idx, txIn := range tx.TxIn {
+			if txIn.Sequence&wire.SequenceLockTimeDisabled != 0 ||
+				txIn.Sequence&wire.SequenceLockTimeMask == 0 {
+
+				continue
+			}
+			return fmt.Errorf("%w: input %d has sequence %d",
+				ErrRelativeLockTimeVersion, idx, txIn.Sequence)
+		}
+	}
+
+	return w.checkTRUC(txmgrNs, tx, prevScripts)
+}
+
+// checkTRUC ensures the transaction complies with the TRUC policy: unconfirmed
+// TRUC transactions may only be spent by TRUC transactions, which can have at
+// most one unconfirmed ancestor and are limited in size. An unconfirmed TRUC
+// transaction may also only have a single unconfirmed child.
+func (w *Wallet) checkTRUC(txmgrNs walletdb.ReadBucket, tx *wire.MsgTx,
+	prevScripts [][]byte) error {
+
+	// We'll gather the unconfirmed transactions spent by the inputs.
+	parents := make(map[chainhash.Hash]*wtxmgr.TxDetails)
+	for _, txIn := range tx.TxIn {
+		parentHash := txIn.PreviousOutPoint.Hash
+		if _, ok := parents[parentHash]; ok {
+			continue
+		}
+
+		details, err := w.TxStore.TxDetails(txmgrNs, &parentHash)
+		if err != nil {
+			return err
+		}
+		if details == nil || details.Block.Height != -1 {
+			continue
+		}
+		if details.MsgTx.Version == trucTxVersion &&
+			tx.Version != trucTxVersion {
+
+			return fmt.Errorf("%w: version %d transaction spends "+
+				"unconfirmed TRUC transaction %v",
+				ErrTRUCViolation, tx.Version, parentHash)
+		}
+		parents[parentHash] = details
+	}
+
+	if tx.Version != trucTxVersion {
+		return nil
+	}
+
+	vsize := estimateVirtualSize(tx, prevScripts)
+	if vsize > maxTRUCVirtualSize {
+		return fmt.Errorf("%w: virtual size of %d exceeds %d",
+			ErrTRUCViolation, vsize, maxTRUCVirtualSize)
+	}
+	if len(parents) == 0 {
+		return nil
+	}
+	if len(parents) > 1 {
+		return fmt.Errorf("%w: %d unconfirmed ancestors exceed 1",
+			ErrTRUCViolation, len(parents))
+	}
+
+	for parentHash, parent := range parents {
+		if parent.MsgTx.Version != trucTxVersion {
+			return fmt.Errorf("%w: TRUC transaction spends "+
+				"unconfirmed version %d transaction %v",
+				ErrTRUCViolation, parent.MsgTx.Version,
+				parentHash)
+		}
+		if vsize > maxTRUCChildVirtualSize {
+			return fmt.Errorf("%w: virtual size of %d exceeds %d "+
+				"for child of unconfirmed transaction",
+				ErrTRUCViolation, vsize,
+				maxTRUCChildVirtualSize)
+		}
+
+		// The parent must not have unconfirmed ancestors of its own,
+		// nor any other unconfirmed child.
+		for _, txIn := range parent.MsgTx.TxIn {
+			grandparentHash := txIn.PreviousOutPoint.Hash
+			details, err := w.TxStore.TxDetails(
+				txmgrNs, &grandparentHash,
+			)
+			if err != nil {
+				return err
+			}
+			if details != nil && details.Block.Height == -1 {
+				return fmt.Errorf("%w: unconfirmed "+
+					"transaction %v has unconfirmed "+
+					"ancestor %v",
+					ErrTRUCViolation, parentHash,
+					grandparentHash)
+			}
+		}
+
+		unmined, err := w.TxStore.UnminedTxs(txmgrNs)
+		if err != nil {
+			return err
+		}
+		for _, sibling := range unmined {
+			for _, txIn := range sibling.TxIn {
+				if txIn.PreviousOutPoint.Hash != parentHash {
+					continue
+				}
+				return fmt.Errorf("%w: unconfirmed "+
+					"transaction %v already has child %v",
+					ErrTRUCViolation, parentHash,
+					sibling.TxHash())
+			}
+		}
+	}
+
+	return nil
+}
+
+const (
+	// redeemTimeLockWitnessWeight is the worst case weight of a witness
+	// spending a P2WSH output paying to a time-locked script, as created
+	// by timeLockScript. It is calculated as:
+	//
+	//   - 1 wu compact int encoding value 2 (number of items)
+	//   - 1 wu compact int encoding value 73
+	//   - 72 wu DER signature + 1 wu sighash
+	//   - 1 wu compact int encoding value 42
+	//   - 5 wu lock-time push
+	//   - 1 wu OP_CHECKLOCKTIMEVERIFY + 1 wu OP_DROP
+	//   - 1 wu OP_DATA_33 + 33 wu serialized compressed pubkey
+	//   - 1 wu OP_CHECKSIG
+	redeemTimeLockWitnessWeight = 1 + 1 + 73 + 1 + 5 + 1 + 1 + 1 + 33 + 1
+
+	// redeemP2TRWitnessWeight is the worst case weight of a witness
+	// spending a P2TR output through its key path. It is calculated as:
+	//
+	//   - 1 wu compact int encoding value 1 (number of items)
+	//   - 1 wu compact int encoding value 65
+	//   - 64 wu schnorr signature + 1 wu sighash
+	redeemP2TRWitnessWeight = 1 + 1 + 65
+)
+
+// isPayToTaproot returns whether the script is a version 1 witness program
+// paying to a taproot output key, as per BIP 341.
+func isPayToTaproot(pkScript []byte) bool {
+	return len(pkScript) == 34 && pkScript[0] == txscript.OP_1 &&
+		pkScript[1] == txscript.OP_DATA_32
+}
+
+// estimateVirtualSize returns a worst case estimate of the virtual size of the
+// transaction once its inputs, spending the given previous output scripts, are
+// signed. Inputs spending P2WSH outputs are estimated as spending time-locked
+// scripts, the only witness scripts the wallet signs, and those spending P2TR
+// outputs as being signed through their key path.
+func estimateVirtualSize(tx *wire.MsgTx, prevScripts [][]byte) int {
+	baseSize := 8 + wire.VarIntSerializeSize(uint64(len(tx.TxIn))) +
+		wire.VarIntSerializeSize(uint64(len(tx.TxOut))) +
+		txsizes.SumOutputSerializeSizes(tx.TxOut)
+
+	// Witness inputs have empty signature scripts, such that those not
+	// nested within P2SH have the same base size as P2WPKH inputs.
+	var witnessWeight, numWitness int
+	for _, pkScript := range prevScripts {
+		switch {
+		case txscript.IsPayToScriptHash(pkScript):
+			baseSize += txsizes.RedeemNestedP2WPKHInputSize
+			witnessWeight += txsizes.RedeemP2WPKHInputWitnessWeight
+			numWitness++
+
+		case txscript.IsPayToWitnessPubKeyHash(pkScript):
+			baseSize += txsizes.RedeemP2WPKHInputSize
+			witnessWeight += txsizes.RedeemP2WPKHInputWitnessWeight
+			numWitness++
+
+		case txscript.IsPayToWitnessScriptHash(pkScript):
+			baseSize += txsizes.RedeemP2WPKHInputSize
+			witnessWeight += redeemTimeLockWitnessWeight
+			numWitness++
+
+		case isPayToTaproot(pkScript):
+			baseSize += txsizes.RedeemP2WPKHInputSize
+			witnessWeight += redeemP2TRWitnessWeight
+			numWitness++
+
+		default:
+			baseSize += txsizes.RedeemP2PKHInputSize
+		}
+	}
+
+	// If any input has a witness, the segwit marker and flag are added,
+	// along with an empty witness for every other input.
+	if numWitness > 0 {
+		witnessWeight += 2 + len(prevScripts) - numWitness
+	}
+
+	return baseSize + (witnessWeight+blockchain.WitnessScaleFactor-1)/
+		blockchain.WitnessScaleFactor
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil/psbt"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// fundWallet adds a confirmed output of the given value paying to a new
+// address of the default account to the wallet.
+func fundWallet(t *testing.T, w *Wallet, value int64) *wire.MsgTx {
+	t.Helper()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	tx := &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{wire.NewTxOut(value, pkScript)},
+	}
+	addUtxo(t, w, tx)
+
+	return tx
+}
+
+// TestTxVersion ensures that transactions are created with the requested
+// version, that unsupported versions are rejected, and that inputs with a
+// relative lock-time require a version of at least 2.
+func TestTxVersion(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	fundingTx := fundWallet(t, w, 1000000)
+	outputs := []*wire.TxOut{wire.NewTxOut(100000, testScriptP2WKH)}
+
+	// Transactions are created with the default version unless another
+	// one is requested.
+	tx, err := w.CreateSimpleTx(
+		nil, 0, outputs, 1, 1000, CoinSelectionLargest, true,
+	)
+	require.NoError(t, err)
+	require.EqualValues(t, wire.TxVersion, tx.Tx.Version)
+
+	tx, err = w.CreateSimpleTx(
+		nil, 0, outputs, 1, 1000, CoinSelectionLargest, true,
+		WithTxVersion(2),
+	)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, tx.Tx.Version)
+
+	for _, version := range []int32{-1, 0, MaxTxVersion + 1} {
+		_, err := w.CreateSimpleTx(
+			nil, 0, outputs, 1, 1000, CoinSelectionLargest, true,
+			WithTxVersion(version),
+		)
+		require.True(t, errors.Is(err, ErrUnsupportedTxVersion), err)
+	}
+
+	// Spending an input with a relative lock-time requires the version to
+	// be at least 2 for it to be enforced.
+	fundCSV := func(version int32) (*psbt.Packet, error) {
+		packet, err := psbt.New(
+			[]*wire.OutPoint{{Hash: fundingTx.TxHash(), Index: 0}},
+			outputs, version, 0, []uint32{10},
+		)
+		require.NoError(t, err)
+
+		_, err = w.FundPsbt(packet, nil, 1, 0, 1000,
+			CoinSelectionLargest)
+		return packet, err
+	}
+
+	_, err = fundCSV(1)
+	require.True(t, errors.Is(err, ErrRelativeLockTimeVersion), err)
+
+	packet, err := fundCSV(2)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, packet.UnsignedTx.Version)
+	require.Len(t, packet.UnsignedTx.TxIn, 1)
+	require.EqualValues(t, 10, packet.UnsignedTx.TxIn[0].Sequence)
+}
+
+// TestTxVersionTRUC ensures that the TRUC policy is enforced when creating
+// version 3 transactions, and when spending unconfirmed ones.
+func TestTxVersionTRUC(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	fundWallet(t, w, 100000000)
+
+	payTo := func(n int, value int64) []*wire.TxOut {
+		outputs := make([]*wire.TxOut, n)
+		for i := range outputs {
+			outputs[i] = wire.NewTxOut(value, testScriptP2WKH)
+		}
+		return outputs
+	}
+	create := func(outputs []*wire.TxOut, minconf, version int32) error {
+		_, err := w.CreateSimpleTx(
+			nil, 0, outputs, minconf, 1000, CoinSelectionLargest,
+			true, WithTxVersion(version),
+		)
+		return err
+	}
+
+	// A TRUC transaction can't exceed the maximum virtual size.
+	err := create(payTo(350, 10000), 1, trucTxVersion)
+	require.True(t, errors.Is(err, ErrTRUCViolation), err)
+	require.NoError(t, create(payTo(350, 10000), 1, wire.TxVersion))
+
+	// A TRUC transaction can't spend an unconfirmed non-TRUC one.
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	require.NoError(t, err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+	addUnminedTx(t, w, &wire.MsgTx{
+		Version: 1,
+		TxIn:    []*wire.TxIn{{}},
+		TxOut:   []*wire.TxOut{wire.NewTxOut(200000000, pkScript)},
+	}, 0)
+
+	err = create(payTo(1, 100000), 0, trucTxVersion)
+	require.True(t, errors.Is(err, ErrTRUCViolation), err)
+
+	// Publish a TRUC parent paying to the wallet twice, once through its
+	// change output.
+	parent, err := w.SendOutputs(
+		[]*wire.TxOut{wire.NewTxOut(1000000, pkScript)}, nil, 0, 1,
+		1000, CoinSelectionLargest, "", WithTxVersion(trucTxVersion),
+	)
+	require.NoError(t, err)
+	require.EqualValues(t, trucTxVersion, parent.Version)
+
+	fundChild := func(index uint32, outputs []*wire.TxOut,
+		version int32) error {
+
+		packet, err := psbt.New(
+			[]*wire.OutPoint{{Hash: parent.TxHash(), Index: index}},
+			outputs, version, 0,
+			[]uint32{wire.MaxTxInSequenceNum},
+		)
+		require.NoError(t, err)
+
+		_, err = w.FundPsbt(packet, nil, 0, 0, 1000,
+			CoinSelectionLargest)
+		return err
+	}
+	var paymentIdx, changeIdx uint32
+	for i, txOut := range parent.TxOut {
+		if txOut.Value == 1000000 {
+			paymentIdx = uint32(i)
+		} else {
+			changeIdx = uint32(i)
+		}
+	}
+
+	// The unconfirmed TRUC parent can only be spent by a TRUC child, which
+	// is limited to a smaller size.
+	err = fundChild(changeIdx, payTo(1, 100000), wire.TxVersion)
+	require.True(t, errors.Is(err, ErrTRUCViolation), err)
+
+	err = fundChild(changeIdx, payTo(40, 10000), trucTxVersion)
+	require.True(t, errors.Is(err, ErrTRUCViolation), err)
+
+	// A small TRUC child is allowed, but only a single one.
+	packet, err := psbt.New(
+		[]*wire.OutPoint{{Hash: parent.TxHash(), Index: changeIdx}},
+		payTo(1, 100000), trucTxVersion, 0,
+		[]uint32{wire.MaxTxInSequenceNum},
+	)
+	require.NoError(t, err)
+	_, err = w.FundPsbt(packet, nil, 0, 0, 1000, CoinSelectionLargest)
+	require.NoError(t, err)
+
+	err = w.FinalizePsbt(nil, 0, packet)
+	require.NoError(t, err)
+	childTx, err := psbt.Extract(packet)
+	require.NoError(t, err)
+	require.NoError(t, w.PublishTransaction(childTx, ""))
+
+	err = fundChild(paymentIdx, payTo(1, 100000), trucTxVersion)
+	require.True(t, errors.Is(err, ErrTRUCViolation), err)
+}
+
+// TestEstimateVirtualSize ensures that the virtual size of transactions is
+// estimated as that of their inputs being signed with the largest signature
+// scripts and witnesses the wallet produces for each type of input.
+func TestEstimateVirtualSize(t *testing.T) {
+	t.Parallel()
+
+	sig := make([]byte, 73)
+	pubKey := make([]byte, 33)
+	timeLock, err := timeLockScript(pubKey, txscript.LockTimeThreshold-1)
+	require.NoError(t, err)
+
+	p2pkhScript := append([]byte{
+		txscript.OP_DUP, txscript.OP_HASH160, txscript.OP_DATA_20,
+	}, make([]byte, 22)...)
+	p2pkhScript[23] = txscript.OP_EQUALVERIFY
+	p2pkhScript[24] = txscript.OP_CHECKSIG
+	p2shScript := append([]byte{
+		txscript.OP_HASH160, txscript.OP_DATA_20,
+	}, make([]byte, 21)...)
+	p2shScript[22] = txscript.OP_EQUAL
+	p2wshScript := append(
+		[]byte{txscript.OP_0, txscript.OP_DATA_32}, make([]byte, 32)...,
+	)
+	p2trScript := append(
+		[]byte{txscript.OP_1, txscript.OP_DATA_32}, make([]byte, 32)...,
+	)
+
+	// The signature script of P2PKH inputs pushes the signature and key,
+	// while that of nested P2WPKH inputs pushes the witness program.
+	p2pkhSigScript := append(append([]byte{txscript.OP_DATA_73}, sig...),
+		append([]byte{txscript.OP_DATA_33}, pubKey...)...)
+	nestedSigScript := append(
+		[]byte{txscript.OP_DATA_22, txscript.OP_0, txscript.OP_DATA_20},
+		make([]byte, 20)...,
+	)
+
+	type input struct {
+		pkScript  []byte
+		sigScript []byte
+		witness   wire.TxWitness
+	}
+	var (
+		p2pkh  = input{p2pkhScript, p2pkhSigScript, nil}
+		nested = input{
+			p2shScript, nestedSigScript,
+			wire.TxWitness{sig, pubKey},
+		}
+		p2wpkh = input{
+			testScriptP2WKH, nil, wire.TxWitness{sig, pubKey},
+		}
+		p2wsh = input{
+			p2wshScript, nil, wire.TxWitness{sig, timeLock},
+		}
+		p2tr = input{p2trScript, nil, wire.TxWitness{sig[:65]}}
+	)
+
+	tests := []struct {
+		name   string
+		inputs []input
+	}{
+		{name: "p2pkh", inputs: []input{p2pkh}},
+		{name: "nested p2wpkh", inputs: []input{nested}},
+		{name: "p2wpkh", inputs: []input{p2wpkh}},
+		{name: "time-locked p2wsh", inputs: []input{p2wsh}},
+		{name: "p2tr", inputs: []input{p2tr}},
+		{
+			name: "mixed",
+			inputs: []input{
+				p2pkh, nested, p2wpkh, p2wsh, p2tr, p2pkh,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxOut(wire.NewTxOut(1000, testScriptP2WKH))
+		prevScripts := make([][]byte, 0, len(test.inputs))
+		for _, in := range test.inputs {
+			tx.AddTxIn(&wire.TxIn{
+				SignatureScript: in.sigScript,
+				Witness:         in.witness,
+			})
+			prevScripts = append(prevScripts, in.pkScript)
+		}
+
+		require.Equal(
+			t, txVirtualSize(tx),
+			estimateVirtualSize(tx, prevScripts), test.name,
+		)
+	}
+}
@@ -104,8 +104,9 @@ func (w *Wallet) UnspentOutputs(policy OutputSelectionPolicy) ([]*TransactionOut
 
 // FetchInputInfo queries for the wallet's knowledge of the passed outpoint. If
 // the wallet determines this output is under its control, then the original
-// full transaction, the target txout and the number of confirmations are
-// returned. Otherwise, a non-nil error value of ErrNotMine is returned instead.
+// full transaction, the target txout, the BIP32 derivation of its key, if
+// known, and the number of confirmations are returned. Otherwise, a non-nil
+// error value of ErrNotMine is returned instead.
 func (w *Wallet) FetchInputInfo(prevOut *wire.OutPoint) (*wire.MsgTx,
 	*wire.TxOut, *psbt.Bip32Derivation, int64, error) {
 
@@ -137,7 +138,6 @@ func (w *Wallet) FetchInputInfo(prevOut *wire.OutPoint) (*wire.MsgTx,
 	if !ok {
 		return nil, nil, nil, 0, err
 	}
-	keyScope, derivationPath, _ := pubKeyAddr.DerivationInfo()
 
 	// Determine the number of confirmations the output currently has.
 	_, currentHeight, err := w.chainClient.GetBestBlock()
@@ -153,17 +153,40 @@ func (w *Wallet) FetchInputInfo(prevOut *wire.OutPoint) (*wire.MsgTx,
 	return &txDetail.TxRecord.MsgTx, &wire.TxOut{
 			Value:    txDetail.TxRecord.MsgTx.TxOut[prevOut.Index].Value,
 			PkScript: pkScript,
-		}, &psbt.Bip32Derivation{
-			PubKey:               pubKeyAddr.PubKey().SerializeCompressed(),
-			MasterKeyFingerprint: derivationPath.MasterKeyFingerprint,
-			Bip32Path: []uint32{
-				keyScope.Purpose + hdkeychain.HardenedKeyStart,
-				keyScope.Coin + hdkeychain.HardenedKeyStart,
-				derivationPath.Account,
-				derivationPath.Branch,
-				derivationPath.Index,
-			},
-		}, confs, nil
+		}, bip32Derivation(pubKeyAddr), confs, nil
+}
+
+// bip32Derivation returns the BIP32 derivation of the address's public key,
+// suitable for a PSBT, or nil if its derivation isn't known, as for imported
+// keys.
+func bip32Derivation(addr waddrmgr.ManagedPubKeyAddress) *psbt.Bip32Derivation {
+	keyScope, derivationPath, ok := addr.DerivationInfo()
+	if !ok {
+		return nil
+	}
+	return &psbt.Bip32Derivation{
+		PubKey:               addr.PubKey().SerializeCompressed(),
+		MasterKeyFingerprint: derivationPath.MasterKeyFingerprint,
+		Bip32Path: []uint32{
+			keyScope.Purpose + hdkeychain.HardenedKeyStart,
+			keyScope.Coin + hdkeychain.HardenedKeyStart,
+			derivationPath.Account,
+			derivationPath.Branch,
+			derivationPath.Index,
+		},
+	}
+}
+
+// bip32Derivations returns the BIP32 derivations of a PSBT input or output
+// paying to the address, which are empty if its derivation isn't known.
+func bip32Derivations(
+	addr waddrmgr.ManagedPubKeyAddress) []*psbt.Bip32Derivation {
+
+	derivation := bip32Derivation(addr)
+	if derivation == nil {
+		return nil
+	}
+	return []*psbt.Bip32Derivation{derivation}
 }
 
 // fetchOutputAddr attempts to fetch the managed address corresponding to the
@@ -179,9 +202,9 @@ func (w *Wallet) fetchOutputAddr(script []byte) (waddrmgr.ManagedAddress, error)
 	// Therefore, we simply select the key for the first address we know
 	// of.
 	for _, addr := range addrs {
-		addr, err := w.AddressInfo(addr)
+		info, err := w.AddressInfo(addr)
 		if err == nil {
-			return addr, nil
+			return info.Address, nil
 		}
 	}
 
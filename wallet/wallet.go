@@ -76,6 +76,13 @@ var (
 	// watch-only mode where we can select coins but not sign any inputs.
 	ErrTxUnsigned = errors.New("watch-only wallet, transaction not signed")
 
+	// ErrReorgTooDeep is returned when the chain backend notifies the
+	// wallet of a reorg deeper than its maximum reorg depth. The wallet
+	// halts processing chain notifications when this happens, as rolling
+	// back that much history requires operator intervention, and returns
+	// it when creating or publishing transactions from then on.
+	ErrReorgTooDeep = errors.New("reorg exceeds maximum reorg depth")
+
 	// Namespace bucket keys.
 	waddrmgrNamespaceKey = []byte("waddrmgr")
 	wtxmgrNamespaceKey   = []byte("wtxmgr")
@@ -94,6 +101,23 @@ const (
 	CoinSelectionRandom
 )
 
+// NonStandardScriptPolicy determines whether outputs to non-standard scripts
+// the wallet controls, such as bare multisig scripts, are recorded as credits.
+type NonStandardScriptPolicy uint8
+
+const (
+	// SkipNonStandardScripts ignores non-standard outputs, only recording
+	// outputs to standard scripts paying to a single wallet address. This
+	// is the default policy.
+	SkipNonStandardScripts NonStandardScriptPolicy = iota
+
+	// RecordNonStandardScripts records non-standard outputs the wallet
+	// controls as credits, flagging them as non-standard. As the wallet
+	// may not hold every key required to spend them, they're excluded from
+	// its balance and never selected to fund transactions.
+	RecordNonStandardScripts
+)
+
 // Wallet is a structure containing all the components for a
 // complete wallet.  It contains the Armory-style key store
 // addresses and keys),
@@ -110,11 +134,129 @@ type Wallet struct {
 	chainClientSynced  bool
 	chainClientSyncMtx sync.Mutex
 
+	// reorgHaltErr is the error chain notification processing was halted
+	// with after the backend notified a reorg deeper than maxReorgDepth.
+	// It's protected by chainClientSyncMtx.
+	reorgHaltErr error
+
+	// chainNtfnsQuit is closed to stop handling the notifications of the
+	// current chain client once it's switched for another one.
+	chainNtfnsQuit chan struct{}
+
+	// chainNtfnsWg is done once the goroutine handling the notifications
+	// of the current chain client exits.
+	chainNtfnsWg *sync.WaitGroup
+
+	// chainClientUsers tracks the operations using the current chain
+	// client, obtained through useChainClient, such that it isn't stopped
+	// while in use when switched.
+	chainClientUsers *sync.WaitGroup
+
+	// chainSwitchMtx is held for writes while the chain client is being
+	// switched, pausing the operations requiring one until it's done.
+	chainSwitchMtx sync.RWMutex
+
 	lockedOutpoints    map[wire.OutPoint]struct{}
 	lockedOutpointsMtx sync.Mutex
 
 	recoveryWindow uint32
 
+	// skipHistoricalScan indicates whether the wallet should start syncing
+	// from the chain tip, rather than its birthday block, the first time
+	// it's synchronized.
+	skipHistoricalScan bool
+
+	// gapLookAhead and gapScanDepth are the number of addresses past the
+	// last derived ones of every account branch, and the number of most
+	// recent blocks, scanned for activity when the wallet is synchronized.
+	gapLookAhead uint32
+	gapScanDepth uint32
+
+	// dustChangePolicy determines what happens to the change of
+	// transactions created by the wallet when it would be dust.
+	dustChangePolicy txauthor.DustChangePolicy
+
+	// txOrderingPolicy determines how the inputs and outputs of
+	// transactions created by the wallet are ordered.
+	txOrderingPolicy TxOrderingPolicy
+
+	// antiFeeSniping indicates whether the lock-time of transactions
+	// created by the wallet is set to the current block height.
+	antiFeeSniping bool
+
+	// consolidationFeeCeiling is the highest fee rate, in satoshis per
+	// kB, at which the wallet consolidates its outputs.
+	consolidationFeeCeiling btcutil.Amount
+
+	// feeRateBounds are the lowest and highest fee rates at which the
+	// wallet creates transactions.
+	feeRateBounds FeeRateBounds
+
+	// txSizeLimits are the limits on the number of outputs and the
+	// virtual size of the transactions created by the wallet.
+	txSizeLimits TxSizeLimits
+
+	// maxReorgDepth is the maximum number of blocks the wallet will roll
+	// back when handling a reorg before refusing to do so.
+	maxReorgDepth uint32
+
+	// reorgDepth is the number of blocks rolled back since the last block
+	// was connected, which is compared against maxReorgDepth. It's only
+	// accessed by the chain notification handler.
+	reorgDepth uint32
+
+	// finalityDepth is the number of confirmations at which a
+	// transaction is considered final.
+	finalityDepth uint32
+
+	// maxAncestorChainLength is the maximum number of unconfirmed
+	// ancestors, including itself, of a transaction created by the
+	// wallet, or zero if unlimited.
+	maxAncestorChainLength uint32
+
+	// confirmationReference determines the chain height the confirmation
+	// counts of query results are computed against.
+	confirmationReference ConfirmationReference
+
+	// nonStandardPolicy determines whether non-standard outputs the wallet
+	// controls are recorded as credits.
+	nonStandardPolicy NonStandardScriptPolicy
+
+	// skipMempoolReconciliation indicates whether the wallet should skip
+	// reconciling its unconfirmed transactions against the chain
+	// backend's mempool once synced at startup.
+	skipMempoolReconciliation bool
+
+	// coinbaseSweepCfg determines where and at which fee rate mature
+	// coinbase outputs are swept to. Sweeping is disabled if it's nil.
+	coinbaseSweepCfg     *CoinbaseSweepConfig
+	coinbaseSweepMtx     sync.Mutex
+	coinbaseSweepTrigger chan struct{}
+
+	// feeEscalationPolicy determines how the fees of unconfirmed
+	// transactions are escalated as they're rebroadcast. Escalation is
+	// disabled if it's nil. unconfirmedSince tracks the height from which
+	// each unconfirmed transaction was first seen as such. feeCeiling
+	// caps the fees of both escalated and explicitly bumped transactions.
+	feeEscalationPolicy *FeeEscalationPolicy
+	unconfirmedSince    map[chainhash.Hash]int32
+	feeCeiling          FeeCeiling
+	feeEscalationMtx    sync.Mutex
+	rebroadcastTrigger  chan struct{}
+
+	// eventBus delivers the high-level events of the wallet to its
+	// subscriptions.
+	eventBus *eventBus
+
+	// broadcastRetryPolicy determines how broadcasts failing due to
+	// transient connection errors are retried.
+	broadcastRetryPolicy BroadcastRetryPolicy
+
+	// externalSigner signs the transactions spending from watch-only
+	// accounts sent through SendOutputsWithExternalSigner.
+	externalSigner    ExternalSigner
+	externalSignerMtx sync.Mutex
+
 	// Channels for rescan processing.  Requests are added and merged with
 	// any waiting requests, before being sent to another goroutine to
 	// call the rescan RPC.
@@ -124,6 +266,15 @@ type Wallet struct {
 	rescanProgress      chan *RescanProgressMsg
 	rescanFinished      chan *RescanFinishedMsg
 
+	// rescanResubmit is signaled to resubmit the rescan in progress to
+	// the chain client it was switched for.
+	rescanResubmit chan struct{}
+
+	// rescanTxHooks are the hooks of the rescan in progress, invoked for
+	// every relevant transaction the wallet records until it finishes.
+	rescanTxHooks    []RescanTxHook
+	rescanTxHooksMtx sync.Mutex
+
 	// Channel for transaction creation requests.
 	createTxRequests chan createTxRequest
 
@@ -163,9 +314,11 @@ func (w *Wallet) Start() {
 	}
 	w.quitMu.Unlock()
 
-	w.wg.Add(2)
+	w.wg.Add(4)
 	go w.txCreator()
 	go w.walletLocker()
+	go w.coinbaseSweeper()
+	go w.rebroadcaster()
 }
 
 // SynchronizeRPC associates the wallet with the consensus RPC client,
@@ -192,15 +345,11 @@ func (w *Wallet) SynchronizeRPC(chainClient chain.Interface) {
 		return
 	}
 	w.chainClient = chainClient
-
-	// If the chain client is a NeutrinoClient instance, set a birthday so
-	// we don't download all the filters as we go.
-	switch cc := chainClient.(type) {
-	case *chain.NeutrinoClient:
-		cc.SetStartTime(w.Manager.Birthday())
-	case *chain.BitcoindClient:
-		cc.SetBirthday(w.Manager.Birthday())
-	}
+	w.chainNtfnsQuit = make(chan struct{})
+	w.chainNtfnsWg = &sync.WaitGroup{}
+	w.chainNtfnsWg.Add(1)
+	w.chainClientUsers = &sync.WaitGroup{}
+	w.setChainClientBirthday(chainClient)
 	w.chainClientLock.Unlock()
 
 	// TODO: It would be preferable to either run these goroutines
@@ -214,11 +363,27 @@ func (w *Wallet) SynchronizeRPC(chainClient chain.Interface) {
 	go w.rescanRPCHandler()
 }
 
+// setChainClientBirthday sets the wallet's birthday as the one of the chain
+// client, if it supports one.
+func (w *Wallet) setChainClientBirthday(chainClient chain.Interface) {
+	// If the chain client is a NeutrinoClient instance, set a birthday so
+	// we don't download all the filters as we go.
+	switch cc := chainClient.(type) {
+	case *chain.NeutrinoClient:
+		cc.SetStartTime(w.Manager.Birthday())
+	case *chain.BitcoindClient:
+		cc.SetBirthday(w.Manager.Birthday())
+	}
+}
+
 // requireChainClient marks that a wallet method can only be completed when the
 // consensus RPC server is set.  This function and all functions that call it
 // are unstable and will need to be moved when the syncing code is moved out of
 // the wallet.
 func (w *Wallet) requireChainClient() (chain.Interface, error) {
+	w.chainSwitchMtx.RLock()
+	defer w.chainSwitchMtx.RUnlock()
+
 	w.chainClientLock.Lock()
 	chainClient := w.chainClient
 	w.chainClientLock.Unlock()
@@ -228,12 +393,45 @@ func (w *Wallet) requireChainClient() (chain.Interface, error) {
 	return chainClient, nil
 }
 
+// useChainClient returns the chain client the operation being performed is
+// completed with, along with a function to call once the operation is done
+// with it, which may be called more than once. SwitchChainBackend doesn't stop
+// the client until every operation using it is done, so operations waiting on
+// the wallet's notification handler, such as rescans, must be done with it
+// before waiting.
+func (w *Wallet) useChainClient() (chain.Interface, func(), error) {
+	w.chainSwitchMtx.RLock()
+	defer w.chainSwitchMtx.RUnlock()
+
+	w.chainClientLock.Lock()
+	defer w.chainClientLock.Unlock()
+
+	if w.chainClient == nil {
+		return nil, nil, errors.New("blockchain RPC is inactive")
+	}
+	if w.chainClientUsers == nil {
+		w.chainClientUsers = &sync.WaitGroup{}
+	}
+	users := w.chainClientUsers
+	users.Add(1)
+
+	var once sync.Once
+	done := func() {
+		once.Do(users.Done)
+	}
+
+	return w.chainClient, done, nil
+}
+
 // ChainClient returns the optional consensus RPC client associated with the
 // wallet.
 //
 // This function is unstable and will be removed once sync logic is moved out of
 // the wallet.
 func (w *Wallet) ChainClient() chain.Interface {
+	w.chainSwitchMtx.RLock()
+	defer w.chainSwitchMtx.RUnlock()
+
 	w.chainClientLock.Lock()
 	chainClient := w.chainClient
 	w.chainClientLock.Unlock()
@@ -323,6 +521,24 @@ func (w *Wallet) SetChainSynced(synced bool) {
 	w.chainClientSyncMtx.Unlock()
 }
 
+// ReorgHalted returns the ErrReorgTooDeep error chain notification processing
+// was halted with, or nil if it wasn't. Once halted, the wallet refuses to
+// create or publish transactions until it's restarted.
+func (w *Wallet) ReorgHalted() error {
+	w.chainClientSyncMtx.Lock()
+	defer w.chainClientSyncMtx.Unlock()
+
+	return w.reorgHaltErr
+}
+
+// haltOnReorg records the error chain notification processing was halted
+// with.
+func (w *Wallet) haltOnReorg(err error) {
+	w.chainClientSyncMtx.Lock()
+	w.reorgHaltErr = err
+	w.chainClientSyncMtx.Unlock()
+}
+
 // activeData returns the currently-active receiving addresses and all unspent
 // outputs.  This is primarely intended to provide the parameters for a
 // rescan request.
@@ -341,6 +557,14 @@ func (w *Wallet) activeData(dbtx walletdb.ReadWriteTx) ([]btcutil.Address, []wtx
 		return nil, nil, err
 	}
 
+	// The outputs paying to the wallet's time-locked scripts are watched
+	// as well, through their P2WSH addresses.
+	timeLockedAddrs, err := w.timeLockedAddrs(txmgrNs)
+	if err != nil {
+		return nil, nil, err
+	}
+	addrs = append(addrs, timeLockedAddrs...)
+
 	// Before requesting the list of spendable UTXOs, we'll delete any
 	// expired output locks.
 	err = w.TxStore.DeleteExpiredLockedOutputs(
@@ -386,6 +610,17 @@ func (w *Wallet) syncWithChain(birthdayStamp *waddrmgr.BlockStamp) error {
 		log.Debug("Chain backend synced to tip!")
 	}
 
+	// If the wallet should skip scanning historical blocks and has yet to
+	// be synced for the first time, we'll start from the chain tip rather
+	// than locating our birthday block.
+	if birthdayStamp == nil && w.skipHistoricalScan {
+		birthdayStamp, err = w.syncFromChainTip(chainClient)
+		if err != nil {
+			return fmt.Errorf("unable to sync from chain tip: %v",
+				err)
+		}
+	}
+
 	// If we've yet to find our birthday block, we'll do so now.
 	if birthdayStamp == nil {
 		var err error
@@ -442,6 +677,23 @@ func (w *Wallet) syncWithChain(birthdayStamp *waddrmgr.BlockStamp) error {
 		}
 	}
 
+	// If requested, we'll look for funds sent to addresses beyond the
+	// wallet's gap limit within the most recent blocks.
+	if w.gapLookAhead > 0 && w.gapScanDepth > 0 {
+		err := w.recoverGapAddresses(chainClient, birthdayStamp)
+		if err != nil {
+			return fmt.Errorf("unable to recover addresses beyond "+
+				"the gap limit: %v", err)
+		}
+	}
+
+	// If the wallet was shut down while processing a block's notification,
+	// we'll resume from the block following the last one it processed.
+	if err := w.replayNotificationJournal(chainClient); err != nil {
+		return fmt.Errorf("unable to replay notification journal: %v",
+			err)
+	}
+
 	// Compare previously-seen blocks against the current chain. If any of
 	// these blocks no longer exist, rollback all of the missing blocks
 	// before catching up with the rescan.
@@ -504,7 +756,11 @@ func (w *Wallet) syncWithChain(birthdayStamp *waddrmgr.BlockStamp) error {
 		// stale state. `Rollback` unconfirms transactions at and beyond
 		// the passed height, so add one to the new synced-to height to
 		// prevent unconfirming transactions in the synced-to block.
-		return w.TxStore.Rollback(txmgrNs, rollbackStamp.Height+1)
+		err = w.TxStore.Rollback(txmgrNs, rollbackStamp.Height+1)
+		if err != nil {
+			return err
+		}
+		return w.rollbackUsedIndexes(tx, rollbackStamp.Height+1)
 	})
 	if err != nil {
 		return err
@@ -540,6 +796,132 @@ func (w *Wallet) syncWithChain(birthdayStamp *waddrmgr.BlockStamp) error {
 	return w.rescanWithTarget(addrs, unspent, nil)
 }
 
+// SkipHistoricalScan configures the wallet to start syncing from the current
+// chain tip the first time it's synchronized, rather than from its birthday
+// block. All wallet addresses are still watched for future events, but no
+// historical blocks are scanned, which is recorded within the wallet so that
+// HistoricalScanSkipped can report it. Historical funds will only be found
+// after an explicit call to RescanHistory.
+//
+// NOTE: This must be called before the wallet is synchronized with a chain
+// backend, and has no effect for wallets that have already been synced.
+func (w *Wallet) SkipHistoricalScan() {
+	w.skipHistoricalScan = true
+}
+
+// SetMaxReorgDepth sets the maximum number of blocks the wallet will roll back
+// when handling a reorg. If the chain backend notifies the wallet of a deeper
+// reorg, the wallet halts processing chain notifications with ErrReorgTooDeep
+// rather than rolling back its history, and refuses to create or publish
+// transactions until it's restarted. By default, this is
+// waddrmgr.MaxReorgDepth.
+//
+// NOTE: This must be called before the wallet is synchronized with a chain
+// backend.
+func (w *Wallet) SetMaxReorgDepth(depth uint32) {
+	w.maxReorgDepth = depth
+}
+
+// SetNonStandardScriptPolicy sets whether outputs to non-standard scripts the
+// wallet controls, such as bare multisig scripts it holds a key for or bare
+// scripts imported into it, are recorded as credits when found. Recorded
+// credits are flagged as non-standard. By default, they are skipped.
+//
+// NOTE: This must be called before the wallet is synchronized with a chain
+// backend.
+func (w *Wallet) SetNonStandardScriptPolicy(policy NonStandardScriptPolicy) {
+	w.nonStandardPolicy = policy
+}
+
+// HistoricalScanSkipped returns whether the wallet started syncing from the
+// chain tip without scanning any historical blocks for funds.
+func (w *Wallet) HistoricalScanSkipped() (bool, error) {
+	var skipped bool
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(waddrmgrNamespaceKey)
+		skipped = w.Manager.HistoricalScanSkipped(ns)
+		return nil
+	})
+	return skipped, err
+}
+
+// RescanHistory rescans the chain from the given block for all wallet
+// addresses and unspent outputs. This is required to find historical funds of
+// a wallet that skipped its historical scan. Once the rescan completes, the
+// block is set as the wallet's birthday block.
+func (w *Wallet) RescanHistory(startStamp *waddrmgr.BlockStamp) error {
+	if startStamp == nil {
+		return errors.New("history rescan requires a start block")
+	}
+
+	var (
+		addrs   []btcutil.Address
+		unspent []wtxmgr.Credit
+	)
+	err := walletdb.Update(w.db, func(dbtx walletdb.ReadWriteTx) error {
+		var err error
+		addrs, unspent, err = w.activeData(dbtx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := w.rescanWithTarget(addrs, unspent, startStamp); err != nil {
+		return err
+	}
+
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		err := w.Manager.SetBirthdayBlock(ns, *startStamp, true)
+		if err != nil {
+			return err
+		}
+		return w.Manager.SetHistoricalScanSkipped(ns, false)
+	})
+}
+
+// syncFromChainTip sets the current chain tip as both the wallet's synced tip
+// and birthday block, such that no historical blocks are scanned. The wallet
+// is also marked as having skipped its historical scan.
+func (w *Wallet) syncFromChainTip(
+	chainClient chainConn) (*waddrmgr.BlockStamp, error) {
+
+	tipHash, tipHeight, err := chainClient.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+	tipHeader, err := chainClient.GetBlockHeader(tipHash)
+	if err != nil {
+		return nil, err
+	}
+
+	tip := &waddrmgr.BlockStamp{
+		Hash:      *tipHash,
+		Height:    tipHeight,
+		Timestamp: tipHeader.Timestamp,
+	}
+
+	log.Infof("Skipping historical scan, syncing from chain tip "+
+		"height=%d, hash=%v", tip.Height, tip.Hash)
+
+	err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		if err := w.Manager.SetSyncedTo(ns, tip); err != nil {
+			return err
+		}
+		if err := w.Manager.SetBirthdayBlock(ns, *tip, true); err != nil {
+			return err
+		}
+		return w.Manager.SetHistoricalScanSkipped(ns, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tip, nil
+}
+
 // isDevEnv determines whether the wallet is currently under a local developer
 // environment, e.g. simnet or regtest.
 func (w *Wallet) isDevEnv() bool {
@@ -1149,6 +1531,7 @@ type (
 		feeSatPerKB           btcutil.Amount
 		coinSelectionStrategy CoinSelectionStrategy
 		dryRun                bool
+		opts                  *txCreateOptions
 		resp                  chan createTxResponse
 	}
 	createTxResponse struct {
@@ -1190,7 +1573,7 @@ out:
 			tx, err := w.txToOutputs(
 				txr.outputs, txr.keyScope, txr.account,
 				txr.minconf, txr.feeSatPerKB,
-				txr.coinSelectionStrategy, txr.dryRun,
+				txr.coinSelectionStrategy, txr.dryRun, txr.opts,
 			)
 
 			release()
@@ -1211,14 +1594,21 @@ out:
 // with inputs regardless of their type (NP2WKH, P2WKH, etc.). Change and an
 // appropriate transaction fee are automatically included, if necessary. All
 // transaction creation through this function is serialized to prevent the
-// creation of many transactions which spend the same outputs.
+// creation of many transactions which spend the same outputs. The created
+// transaction can be further modified through the given options, such as
+// WithTxVersion.
 //
 // NOTE: The dryRun argument can be set true to create a tx that doesn't alter
 // the database. A tx created with this set to true SHOULD NOT be broadcasted.
 func (w *Wallet) CreateSimpleTx(keyScope *waddrmgr.KeyScope, account uint32,
 	outputs []*wire.TxOut, minconf int32, satPerKb btcutil.Amount,
-	coinSelectionStrategy CoinSelectionStrategy, dryRun bool) (
-	*txauthor.AuthoredTx, error) {
+	coinSelectionStrategy CoinSelectionStrategy, dryRun bool,
+	optFuncs ...TxCreateOption) (*txauthor.AuthoredTx, error) {
+
+	opts := defaultTxCreateOptions()
+	for _, optFunc := range optFuncs {
+		optFunc(opts)
+	}
 
 	req := createTxRequest{
 		keyScope:              keyScope,
@@ -1228,6 +1618,7 @@ func (w *Wallet) CreateSimpleTx(keyScope *waddrmgr.KeyScope, account uint32,
 		feeSatPerKB:           satPerKb,
 		coinSelectionStrategy: coinSelectionStrategy,
 		dryRun:                dryRun,
+		opts:                  opts,
 		resp:                  make(chan createTxResponse),
 	}
 	w.createTxRequests <- req
@@ -1362,6 +1753,15 @@ out:
 	w.wg.Done()
 }
 
+// CheckPassphrase verifies that the passphrase is the wallet's private
+// passphrase, without unlocking the wallet. Neither the wallet's lock state
+// nor the timeout after which an unlocked wallet is relocked are affected. An
+// incorrect passphrase returns an error with the waddrmgr.ErrWrongPassphrase
+// code.
+func (w *Wallet) CheckPassphrase(passphrase []byte) error {
+	return w.Manager.CheckPassphrase(passphrase)
+}
+
 // Unlock unlocks the wallet's address manager and relocks it after timeout has
 // expired.  If the wallet is already unlocked and the new passphrase is
 // correct, the current timeout is replaced with the new one.  The wallet will
@@ -1479,6 +1879,11 @@ func (w *Wallet) AccountAddresses(account uint32) (addrs []btcutil.Address, err
 // a UTXO must be in a block.  If confirmations is 1 or greater,
 // the balance will be calculated based on how many how many blocks
 // include a UTXO.
+//
+// Outputs paying to time-locked scripts whose lock-time hasn't expired are
+// excluded, and reported by CalculateTimeLockedBalance instead. Outputs to
+// non-standard scripts recorded under RecordNonStandardScripts are excluded as
+// well.
 func (w *Wallet) CalculateBalance(confirms int32) (btcutil.Amount, error) {
 	var balance btcutil.Amount
 	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
@@ -1486,6 +1891,19 @@ func (w *Wallet) CalculateBalance(confirms int32) (btcutil.Amount, error) {
 		var err error
 		blk := w.Manager.SyncedTo()
 		balance, err = w.TxStore.Balance(txmgrNs, confirms, blk.Height)
+		if err != nil {
+			return err
+		}
+		locked, err := w.timeLockedBalance(
+			txmgrNs, confirms, blk.Height,
+		)
+		if err != nil {
+			return err
+		}
+		nonStandard, err := w.nonStandardBalance(
+			txmgrNs, confirms, blk.Height,
+		)
+		balance -= locked + nonStandard
 		return err
 	})
 	return balance, err
@@ -1500,7 +1918,9 @@ type Balances struct {
 }
 
 // CalculateAccountBalances sums the amounts of all unspent transaction
-// outputs to the given account of a wallet and returns the balance.
+// outputs to the given account of a wallet and returns the balance. Outputs
+// paying to time-locked scripts belong to the account of the key the script
+// commits to, and are only spendable once their lock-time expires.
 //
 // This function is much slower than it needs to be since transactions outputs
 // are not indexed by the accounts they credit to, and all unspent transaction
@@ -1525,6 +1945,13 @@ func (w *Wallet) CalculateAccountBalances(account uint32, confirms int32) (Balan
 			var outputAcct uint32
 			_, addrs, _, err := txscript.ExtractPkScriptAddrs(
 				output.PkScript, w.chainParams)
+			timeLocked := output.LockTime != 0
+			if err == nil && len(addrs) > 0 && timeLocked {
+				script, _, _ := w.TxStore.TimeLockedScript(
+					txmgrNs, output.PkScript,
+				)
+				addrs[0], err = w.timeLockedKeyAddress(script)
+			}
 			if err == nil && len(addrs) > 0 {
 				_, outputAcct, err = w.Manager.AddrAccount(addrmgrNs, addrs[0])
 			}
@@ -1536,7 +1963,11 @@ func (w *Wallet) CalculateAccountBalances(account uint32, confirms int32) (Balan
 			if output.FromCoinBase && !confirmed(int32(w.chainParams.CoinbaseMaturity),
 				output.Height, syncBlock.Height) {
 				bals.ImmatureReward += output.Amount
-			} else if confirmed(confirms, output.Height, syncBlock.Height) {
+			} else if !output.NonStandard &&
+				timeLockMature(output, syncBlock.Height) &&
+				confirmed(confirms, output.Height,
+					syncBlock.Height) {
+
 				bals.Spendable += output.Amount
 			}
 		}
@@ -1550,10 +1981,11 @@ func (w *Wallet) CalculateAccountBalances(account uint32, confirms int32) (Balan
 // been used (there is at least one transaction spending to it in the
 // blockchain or btcd mempool), the next chained address is returned.
 func (w *Wallet) CurrentAddress(account uint32, scope waddrmgr.KeyScope) (btcutil.Address, error) {
-	chainClient, err := w.requireChainClient()
+	chainClient, done, err := w.useChainClient()
 	if err != nil {
 		return nil, err
 	}
+	defer done()
 
 	manager, err := w.Manager.FetchScopedKeyManager(scope)
 	if err != nil {
@@ -1679,6 +2111,22 @@ func (w *Wallet) LabelTransaction(hash chainhash.Hash, label string,
 	})
 }
 
+// SetTxComment sets the free-text comment of the transaction with the hash
+// provided, replacing any existing one, or removes it if the comment is empty.
+// The call will fail if the comment is too long, or if the transaction is
+// unknown to the wallet.
+func (w *Wallet) SetTxComment(hash chainhash.Hash, comment string) error {
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		txmgrNs := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+
+		err := w.TxStore.SetTxComment(txmgrNs, hash, comment)
+		if wtxmgr.IsNoExists(err) {
+			return ErrUnknownTransaction
+		}
+		return err
+	})
+}
+
 // PrivKeyForAddress looks up the associated private key for a P2PKH or P2PK
 // address.
 func (w *Wallet) PrivKeyForAddress(a btcutil.Address) (*btcec.PrivateKey, error) {
@@ -1727,18 +2175,6 @@ func (w *Wallet) AccountOfAddress(a btcutil.Address) (uint32, error) {
 	return account, err
 }
 
-// AddressInfo returns detailed information regarding a wallet address.
-func (w *Wallet) AddressInfo(a btcutil.Address) (waddrmgr.ManagedAddress, error) {
-	var managedAddress waddrmgr.ManagedAddress
-	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
-		addrmgrNs := tx.ReadBucket(waddrmgrNamespaceKey)
-		var err error
-		managedAddress, err = w.Manager.Address(addrmgrNs, a)
-		return err
-	})
-	return managedAddress, err
-}
-
 // AccountNumber returns the account number for an account name under a
 // particular key scope.
 func (w *Wallet) AccountNumber(scope waddrmgr.KeyScope, accountName string) (uint32, error) {
@@ -1856,6 +2292,35 @@ func (w *Wallet) RenameAccount(scope waddrmgr.KeyScope, account uint32, newName
 	return err
 }
 
+// SetAccountDisabled disables or re-enables an account. A disabled account no
+// longer issues new addresses through NewAddress, and its outputs are excluded
+// from coin selection, but can still be spent explicitly, such as through
+// FundPsbt with specified inputs or SweepOutputs.
+func (w *Wallet) SetAccountDisabled(scope waddrmgr.KeyScope, account uint32,
+	disabled bool) error {
+
+	var props *waddrmgr.AccountProperties
+	err := walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		addrmgrNs := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		err := w.Manager.SetAccountDisabled(
+			addrmgrNs, scope, account, disabled,
+		)
+		if err != nil {
+			return err
+		}
+		manager, err := w.Manager.FetchScopedKeyManager(scope)
+		if err != nil {
+			return err
+		}
+		props, err = manager.AccountProperties(addrmgrNs, account)
+		return err
+	})
+	if err == nil {
+		w.NtfnServer.notifyAccountProperties(props)
+	}
+	return err
+}
+
 // NextAccount creates the next account and returns its account number.  The
 // name must be unique to the account.  In order to support automatic seed
 // restoring, new accounts may not be created when all of the previous 100
@@ -1902,6 +2367,7 @@ const (
 	CreditReceive CreditCategory = iota
 	CreditGenerate
 	CreditImmature
+	CreditSelfTransfer
 )
 
 // String returns the category as a string.  This string may be used as the
@@ -1915,6 +2381,8 @@ func (c CreditCategory) String() string {
 		return "generate"
 	case CreditImmature:
 		return "immature"
+	case CreditSelfTransfer:
+		return "self-transfer"
 	default:
 		return "unknown"
 	}
@@ -1988,6 +2456,7 @@ outputs:
 		// its spentness.
 		var isCredit bool
 		var spentCredit bool
+		var selfTransfer bool
 		for _, cred := range details.Credits {
 			if cred.Index == uint32(i) {
 				// Change outputs are ignored.
@@ -1997,6 +2466,7 @@ outputs:
 
 				isCredit = true
 				spentCredit = cred.Spent
+				selfTransfer = cred.SelfTransfer
 				break
 			}
 		}
@@ -2037,6 +2507,7 @@ outputs:
 			WalletConflicts: []string{},
 			Time:            received,
 			TimeReceived:    received,
+			Comment:         details.Comment,
 		}
 
 		// Add a received/generated/immature result if this is a credit.
@@ -2048,6 +2519,19 @@ outputs:
 		// Since credits are not saved for outputs that are not
 		// controlled by this wallet, all non-credits from transactions
 		// with debits are grouped under the send category.
+		//
+		// Self-transfer credits instead only move funds within the
+		// wallet, so they're included once under the self-transfer
+		// category with a zero amount, such that the history nets to
+		// the fee paid, as does the wallet's balance.
+		if selfTransfer {
+			result.Account = accountName
+			result.Category = CreditSelfTransfer.String()
+			result.Amount = 0
+			result.Fee = &feeF64
+			results = append(results, result)
+			continue
+		}
 
 		if send || spentCredit {
 			result.Category = "send"
@@ -2098,13 +2582,14 @@ func (w *Wallet) ListSinceBlock(start, end, syncHeight int32) ([]btcjson.ListTra
 func (w *Wallet) ListTransactions(from, count int) ([]btcjson.ListTransactionsResult, error) {
 	txList := []btcjson.ListTransactionsResult{}
 
+	// Get current block.  The block height used for calculating the
+	// number of tx confirmations. This is fetched before the database
+	// transaction is opened, as it may require querying the backend.
+	confHeight := w.confirmationHeight()
+
 	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
 		txmgrNs := tx.ReadBucket(wtxmgrNamespaceKey)
 
-		// Get current block.  The block height used for calculating
-		// the number of tx confirmations.
-		syncBlock := w.Manager.SyncedTo()
-
 		// Need to skip the first from transactions, and after those, only
 		// include the next count transactions.
 		skipped := 0
@@ -2127,7 +2612,7 @@ func (w *Wallet) ListTransactions(from, count int) ([]btcjson.ListTransactionsRe
 				}
 
 				jsonResults := listTransactions(tx, &details[i],
-					w.Manager, syncBlock.Height, w.chainParams)
+					w.Manager, confHeight, w.chainParams)
 				txList = append(txList, jsonResults...)
 
 				if len(jsonResults) > 0 {
@@ -2150,12 +2635,15 @@ func (w *Wallet) ListTransactions(from, count int) ([]btcjson.ListTransactionsRe
 // intended to be used for listaddresstransactions RPC replies.
 func (w *Wallet) ListAddressTransactions(pkHashes map[string]struct{}) ([]btcjson.ListTransactionsResult, error) {
 	txList := []btcjson.ListTransactionsResult{}
+
+	// Get current block.  The block height used for calculating the
+	// number of tx confirmations. This is fetched before the database
+	// transaction is opened, as it may require querying the backend.
+	confHeight := w.confirmationHeight()
+
 	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
 		txmgrNs := tx.ReadBucket(wtxmgrNamespaceKey)
 
-		// Get current block.  The block height used for calculating
-		// the number of tx confirmations.
-		syncBlock := w.Manager.SyncedTo()
 		rangeFn := func(details []wtxmgr.TxDetails) (bool, error) {
 		loopDetails:
 			for i := range details {
@@ -2178,7 +2666,7 @@ func (w *Wallet) ListAddressTransactions(pkHashes map[string]struct{}) ([]btcjso
 					}
 
 					jsonResults := listTransactions(tx, detail,
-						w.Manager, syncBlock.Height, w.chainParams)
+						w.Manager, confHeight, w.chainParams)
 					txList = append(txList, jsonResults...)
 					continue loopDetails
 				}
@@ -2196,13 +2684,15 @@ func (w *Wallet) ListAddressTransactions(pkHashes map[string]struct{}) ([]btcjso
 // replies.
 func (w *Wallet) ListAllTransactions() ([]btcjson.ListTransactionsResult, error) {
 	txList := []btcjson.ListTransactionsResult{}
+
+	// Get current block.  The block height used for calculating the
+	// number of tx confirmations. This is fetched before the database
+	// transaction is opened, as it may require querying the backend.
+	confHeight := w.confirmationHeight()
+
 	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
 		txmgrNs := tx.ReadBucket(wtxmgrNamespaceKey)
 
-		// Get current block.  The block height used for calculating
-		// the number of tx confirmations.
-		syncBlock := w.Manager.SyncedTo()
-
 		rangeFn := func(details []wtxmgr.TxDetails) (bool, error) {
 			// Iterate over transactions at this height in reverse order.
 			// This does nothing for unmined transactions, which are
@@ -2210,7 +2700,7 @@ func (w *Wallet) ListAllTransactions() ([]btcjson.ListTransactionsResult, error)
 			// reverse order they were marked mined.
 			for i := len(details) - 1; i >= 0; i-- {
 				jsonResults := listTransactions(tx, &details[i], w.Manager,
-					syncBlock.Height, w.chainParams)
+					confHeight, w.chainParams)
 				txList = append(txList, jsonResults...)
 			}
 			return false, nil
@@ -2579,7 +3069,8 @@ func (s creditSlice) Swap(i, j int) {
 // transactions fitting the given criteria. The confirmations will be more than
 // minconf, less than maxconf and if addresses is populated only the addresses
 // contained within it will be considered.  If we know nothing about a
-// transaction an empty array will be returned.
+// transaction an empty array will be returned. Frozen and denylisted outputs
+// are included, as they remain spendable when explicitly selected.
 func (w *Wallet) ListUnspent(minconf, maxconf int32,
 	accountName string) ([]*btcjson.ListUnspentResult, error) {
 
@@ -2588,7 +3079,10 @@ func (w *Wallet) ListUnspent(minconf, maxconf int32,
 		addrmgrNs := tx.ReadBucket(waddrmgrNamespaceKey)
 		txmgrNs := tx.ReadBucket(wtxmgrNamespaceKey)
 
-		syncBlock := w.Manager.SyncedTo()
+		// The outputs are listed for spending, so their confirmations
+		// are computed against the last block processed by the wallet,
+		// as for coin selection.
+		confHeight := w.Manager.SyncedTo().Height
 
 		filter := accountName != ""
 		unspent, err := w.TxStore.UnspentOutputs(txmgrNs)
@@ -2605,7 +3099,7 @@ func (w *Wallet) ListUnspent(minconf, maxconf int32,
 
 			// Outputs with fewer confirmations than the minimum or more
 			// confs than the maximum are excluded.
-			confs := confirms(output.Height, syncBlock.Height)
+			confs := confirms(output.Height, confHeight)
 			if confs < minconf || confs > maxconf {
 				continue
 			}
@@ -2613,7 +3107,7 @@ func (w *Wallet) ListUnspent(minconf, maxconf int32,
 			// Only mature coinbase outputs are included.
 			if output.FromCoinBase {
 				target := int32(w.ChainParams().CoinbaseMaturity)
-				if !confirmed(target, output.Height, syncBlock.Height) {
+				if !confirmed(target, output.Height, confHeight) {
 					continue
 				}
 			}
@@ -2877,15 +3371,98 @@ func (w *Wallet) ReleaseOutput(id wtxmgr.LockID, op wire.OutPoint) error {
 	})
 }
 
+// FreezeOutput freezes an output, preventing it from being available for coin
+// selection until it's unfrozen through UnfreezeOutput. Frozen outputs are
+// still reported by ListUnspent, and can only be spent by explicitly selecting
+// them, e.g. through SweepOutputs.
+//
+// If the output is not known, ErrUnknownOutput is returned.
+//
+// NOTE: This differs from LeaseOutput in that freezes don't expire, and are
+// only removed explicitly or once the output is spent.
+func (w *Wallet) FreezeOutput(op wire.OutPoint) error {
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		return w.TxStore.FreezeOutput(ns, op)
+	})
+}
+
+// UnfreezeOutput unfreezes an output, allowing it to be available for coin
+// selection if it remains unspent and unlocked.
+func (w *Wallet) UnfreezeOutput(op wire.OutPoint) error {
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		return w.TxStore.UnfreezeOutput(ns, op)
+	})
+}
+
+// FrozenOutputs returns the outpoints of all frozen outputs.
+func (w *Wallet) FrozenOutputs() ([]wire.OutPoint, error) {
+	var outputs []wire.OutPoint
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(wtxmgrNamespaceKey)
+		var err error
+		outputs, err = w.TxStore.ListFrozenOutputs(ns)
+		return err
+	})
+	return outputs, err
+}
+
+// AddToSelectionDenylist adds an outpoint or output script to the selection
+// denylist, such that the matching outputs are never selected automatically,
+// e.g. to avoid spending tainted coins. Denylisted outputs still count towards
+// the wallet's balance and are reported by ListUnspent, and can only be spent
+// by explicitly selecting them, e.g. through SweepOutputs.
+//
+// NOTE: Unlike FreezeOutput, the entry need not match a known output, and
+// isn't removed once matching outputs are spent.
+func (w *Wallet) AddToSelectionDenylist(entry wtxmgr.DenylistEntry) error {
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		return w.TxStore.AddToSelectionDenylist(ns, entry)
+	})
+}
+
+// RemoveFromSelectionDenylist removes an outpoint or output script from the
+// selection denylist, allowing the matching outputs to be available for coin
+// selection if they aren't otherwise excluded.
+func (w *Wallet) RemoveFromSelectionDenylist(entry wtxmgr.DenylistEntry) error {
+	return walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		return w.TxStore.RemoveFromSelectionDenylist(ns, entry)
+	})
+}
+
+// SelectionDenylist returns all entries of the selection denylist.
+func (w *Wallet) SelectionDenylist() ([]wtxmgr.DenylistEntry, error) {
+	var entries []wtxmgr.DenylistEntry
+	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(wtxmgrNamespaceKey)
+		var err error
+		entries, err = w.TxStore.SelectionDenylist(ns)
+		return err
+	})
+	return entries, err
+}
+
 // resendUnminedTxs iterates through all transactions that spend from wallet
 // credits that are not known to have been mined into a block, and attempts
 // to send each to the chain server for relay.
+//
+// If fee escalation is enabled, transactions signaling replaceability that
+// have remained unconfirmed for long enough are replaced by ones paying the
+// estimated fee rate instead. Transactions which can't be replaced are resent
+// unchanged.
 func (w *Wallet) resendUnminedTxs() {
-	var txs []*wire.MsgTx
+	var (
+		txs          []*wire.MsgTx
+		syncedHeight int32
+	)
 	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
 		txmgrNs := tx.ReadBucket(wtxmgrNamespaceKey)
 		var err error
 		txs, err = w.TxStore.UnminedTxs(txmgrNs)
+		syncedHeight = w.Manager.SyncedTo().Height
 		return err
 	})
 	if err != nil {
@@ -2894,11 +3471,63 @@ func (w *Wallet) resendUnminedTxs() {
 		return
 	}
 
+	policy := w.currentFeeEscalationPolicy()
+	var unconfirmedBlocks map[chainhash.Hash]int32
+	if policy != nil {
+		unconfirmedBlocks = w.unconfirmedBlocks(txs, syncedHeight)
+	}
+
+	// Replacing a transaction evicts it along with its descendants, so
+	// we'll keep track of them to avoid resending them. As the
+	// transactions are sorted by their dependency order, their
+	// descendants always follow them.
+	evicted := make(map[chainhash.Hash]struct{})
 	for _, tx := range txs {
-		txHash, err := w.publishTransaction(tx)
+		txHash := tx.TxHash()
+		if _, ok := evicted[txHash]; ok {
+			continue
+		}
+		var spendsEvicted bool
+		for _, txIn := range tx.TxIn {
+			_, ok := evicted[txIn.PreviousOutPoint.Hash]
+			if ok {
+				spendsEvicted = true
+				break
+			}
+		}
+		if spendsEvicted {
+			evicted[txHash] = struct{}{}
+			continue
+		}
+
+		if policy != nil && signalsReplacement(tx) &&
+			unconfirmedBlocks[txHash] >= int32(policy.UnconfirmedBlocks) {
+
+			result, err := w.escalateFee(tx, policy)
+			if err == nil {
+				for _, replaced := range result.Replaced {
+					evicted[replaced] = struct{}{}
+				}
+				for _, published := range result.Published {
+					w.markUnconfirmedSince(
+						published.TxHash(), syncedHeight,
+					)
+				}
+
+				log.Infof("Escalated fee of unconfirmed "+
+					"transaction %v to %v, replaced by %v",
+					txHash, result.Fee, result.Tx.TxHash())
+				continue
+			}
+
+			log.Debugf("Unable to escalate fee of transaction %v, "+
+				"resending unchanged: %v", txHash, err)
+		}
+
+		_, err := w.publishTransaction(tx)
 		if err != nil {
 			log.Debugf("Unable to rebroadcast transaction %v: %v",
-				tx.TxHash(), err)
+				txHash, err)
 			continue
 		}
 
@@ -2930,10 +3559,11 @@ func (w *Wallet) SortedActivePaymentAddresses() ([]string, error) {
 func (w *Wallet) NewAddress(account uint32,
 	scope waddrmgr.KeyScope) (btcutil.Address, error) {
 
-	chainClient, err := w.requireChainClient()
+	chainClient, done, err := w.useChainClient()
 	if err != nil {
 		return nil, err
 	}
+	defer done()
 
 	var (
 		addr  btcutil.Address
@@ -2988,10 +3618,11 @@ func (w *Wallet) newAddress(addrmgrNs walletdb.ReadWriteBucket, account uint32,
 func (w *Wallet) NewChangeAddress(account uint32,
 	scope waddrmgr.KeyScope) (btcutil.Address, error) {
 
-	chainClient, err := w.requireChainClient()
+	chainClient, done, err := w.useChainClient()
 	if err != nil {
 		return nil, err
 	}
+	defer done()
 
 	var addr btcutil.Address
 	err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
@@ -3035,6 +3666,76 @@ func (w *Wallet) newChangeAddress(addrmgrNs walletdb.ReadWriteBucket,
 	return addrs[0].Address(), nil
 }
 
+// ExtendAddresses derives count additional addresses on the external or
+// internal branch of the account, advancing the branch's last derived index
+// past them, and registers them for notifications with the chain backend.
+// This allows extending the window of watched addresses ahead of an expected
+// burst of incoming payments, such that no payment lands beyond the gap
+// limit.
+func (w *Wallet) ExtendAddresses(account uint32, scope waddrmgr.KeyScope,
+	external bool, count uint32) ([]btcutil.Address, error) {
+
+	if count == 0 {
+		return nil, nil
+	}
+
+	chainClient, done, err := w.useChainClient()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	manager, err := w.Manager.FetchScopedKeyManager(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		addrs []btcutil.Address
+		props *waddrmgr.AccountProperties
+	)
+	err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		addrmgrNs := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+
+		var (
+			managedAddrs []waddrmgr.ManagedAddress
+			err          error
+		)
+		if external {
+			managedAddrs, err = manager.NextExternalAddresses(
+				addrmgrNs, account, count,
+			)
+		} else {
+			managedAddrs, err = manager.NextInternalAddresses(
+				addrmgrNs, account, count,
+			)
+		}
+		if err != nil {
+			return err
+		}
+
+		addrs = make([]btcutil.Address, 0, len(managedAddrs))
+		for _, managedAddr := range managedAddrs {
+			addrs = append(addrs, managedAddr.Address())
+		}
+
+		props, err = manager.AccountProperties(addrmgrNs, account)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Notify the rpc server about the newly created addresses.
+	if err := chainClient.NotifyReceived(addrs); err != nil {
+		return nil, err
+	}
+
+	w.NtfnServer.notifyAccountProperties(props)
+
+	return addrs, nil
+}
+
 // confirmed checks whether a transaction at height txHeight has met minconf
 // confirmations for a blockchain at height curHeight.
 func confirmed(minconf, txHeight, curHeight int32) bool {
@@ -3072,13 +3773,15 @@ func (w *Wallet) TotalReceivedForAccounts(scope waddrmgr.KeyScope,
 		return nil, err
 	}
 
+	// The confirmation height may require querying the backend, so it's
+	// fetched before the database transaction is opened.
+	confHeight := w.confirmationHeight()
+
 	var results []AccountTotalReceivedResult
 	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
 		addrmgrNs := tx.ReadBucket(waddrmgrNamespaceKey)
 		txmgrNs := tx.ReadBucket(wtxmgrNamespaceKey)
 
-		syncBlock := w.Manager.SyncedTo()
-
 		err := manager.ForEachAccount(addrmgrNs, func(account uint32) error {
 			accountName, err := manager.AccountName(addrmgrNs, account)
 			if err != nil {
@@ -3097,7 +3800,7 @@ func (w *Wallet) TotalReceivedForAccounts(scope waddrmgr.KeyScope,
 		var stopHeight int32
 
 		if minConf > 0 {
-			stopHeight = syncBlock.Height - minConf + 1
+			stopHeight = confHeight - minConf + 1
 		} else {
 			stopHeight = -1
 		}
@@ -3120,7 +3823,7 @@ func (w *Wallet) TotalReceivedForAccounts(scope waddrmgr.KeyScope,
 						res := &results[acctIndex]
 						res.TotalReceived += cred.Amount
 						res.LastConfirmation = confirms(
-							detail.Block.Height, syncBlock.Height)
+							detail.Block.Height, confHeight)
 					}
 				}
 			}
@@ -3135,19 +3838,21 @@ func (w *Wallet) TotalReceivedForAccounts(scope waddrmgr.KeyScope,
 // returning the total amount of bitcoins received for a single wallet
 // address.
 func (w *Wallet) TotalReceivedForAddr(addr btcutil.Address, minConf int32) (btcutil.Amount, error) {
+	// The confirmation height may require querying the backend, so it's
+	// fetched before the database transaction is opened.
+	confHeight := w.confirmationHeight()
+
 	var amount btcutil.Amount
 	err := walletdb.View(w.db, func(tx walletdb.ReadTx) error {
 		txmgrNs := tx.ReadBucket(wtxmgrNamespaceKey)
 
-		syncBlock := w.Manager.SyncedTo()
-
 		var (
 			addrStr    = addr.EncodeAddress()
 			stopHeight int32
 		)
 
 		if minConf > 0 {
-			stopHeight = syncBlock.Height - minConf + 1
+			stopHeight = confHeight - minConf + 1
 		} else {
 			stopHeight = -1
 		}
@@ -3184,11 +3889,27 @@ func (w *Wallet) TotalReceivedForAddr(addr btcutil.Address, minConf int32) (btcu
 // accounts matching the account number provided across all key scopes may be
 // selected. This is done to handle the default account case, where a user wants
 // to fund a PSBT with inputs regardless of their type (NP2WKH, P2WKH, etc.). It
-// returns the transaction upon success.
+// returns the transaction upon success. The transaction can be further modified
+// through the given options, such as WithTxVersion.
 func (w *Wallet) SendOutputs(outputs []*wire.TxOut, keyScope *waddrmgr.KeyScope,
 	account uint32, minconf int32, satPerKb btcutil.Amount,
-	coinSelectionStrategy CoinSelectionStrategy, label string) (
-	*wire.MsgTx, error) {
+	coinSelectionStrategy CoinSelectionStrategy, label string,
+	optFuncs ...TxCreateOption) (*wire.MsgTx, error) {
+
+	return w.sendOutputs(
+		outputs, nil, keyScope, account, minconf, satPerKb,
+		coinSelectionStrategy, label, optFuncs...,
+	)
+}
+
+// sendOutputs creates and sends a payment transaction like SendOutputs, storing
+// the given labels of the outputs, if any, against the transaction outputs
+// paying them. Labels are matched to outputs by index, with empty ones being
+// skipped.
+func (w *Wallet) sendOutputs(outputs []*wire.TxOut, outputLabels []string,
+	keyScope *waddrmgr.KeyScope, account uint32, minconf int32,
+	satPerKb btcutil.Amount, coinSelectionStrategy CoinSelectionStrategy,
+	label string, optFuncs ...TxCreateOption) (*wire.MsgTx, error) {
 
 	// Ensure the outputs to be created adhere to the network's consensus
 	// rules.
@@ -3207,7 +3928,7 @@ func (w *Wallet) SendOutputs(outputs []*wire.TxOut, keyScope *waddrmgr.KeyScope,
 	// been confirmed.
 	createdTx, err := w.CreateSimpleTx(
 		keyScope, account, outputs, minconf, satPerKb,
-		coinSelectionStrategy, false,
+		coinSelectionStrategy, false, optFuncs...,
 	)
 	if err != nil {
 		return nil, err
@@ -3220,13 +3941,16 @@ func (w *Wallet) SendOutputs(outputs []*wire.TxOut, keyScope *waddrmgr.KeyScope,
 		return createdTx.Tx, ErrTxUnsigned
 	}
 
-	txHash, err := w.reliablyPublishTransaction(createdTx.Tx, label)
+	txOutputLabels := outputLabelIndexes(createdTx, outputs, outputLabels)
+	result, err := w.reliablyPublishTransaction(
+		createdTx.Tx, label, txOutputLabels,
+	)
 	if err != nil {
 		return nil, err
 	}
 
 	// Sanity check on the returned tx hash.
-	if *txHash != createdTx.Tx.TxHash() {
+	if result.Txid != createdTx.Tx.TxHash() {
 		return nil, errors.New("tx hash mismatch")
 	}
 
@@ -3408,28 +4132,61 @@ func (e *ErrReplacement) Unwrap() error {
 	return e.backendError
 }
 
+// ErrNetworkMismatch is an error returned from Open in case the wallet is
+// opened with chain parameters for a different network than the one it was
+// created for.
+type ErrNetworkMismatch struct {
+	// Expected is the network the wallet was created for.
+	Expected wire.BitcoinNet
+
+	// Actual is the network of the chain parameters the wallet was opened
+	// with.
+	Actual wire.BitcoinNet
+}
+
+// Error returns the string representation of ErrNetworkMismatch.
+//
+// NOTE: Satisfies the error interface.
+func (e *ErrNetworkMismatch) Error() string {
+	return fmt.Sprintf("network mismatch: wallet was created for %v, "+
+		"but opened for %v", e.Expected, e.Actual)
+}
+
 // PublishTransaction sends the transaction to the consensus RPC server so it
 // can be propagated to other nodes and eventually mined.
 //
 // This function is unstable and will be removed once syncing code is moved out
 // of the wallet.
 func (w *Wallet) PublishTransaction(tx *wire.MsgTx, label string) error {
-	_, err := w.reliablyPublishTransaction(tx, label)
+	_, err := w.reliablyPublishTransaction(tx, label, nil)
 	return err
 }
 
+// PublishTransactionWithResult publishes the transaction like
+// PublishTransaction, additionally reporting whether the transaction was newly
+// accepted by the backend, or already within its mempool or the chain, along
+// with the number of broadcast attempts made under the wallet's broadcast
+// retry policy.
+func (w *Wallet) PublishTransactionWithResult(tx *wire.MsgTx,
+	label string) (*BroadcastResult, error) {
+
+	return w.reliablyPublishTransaction(tx, label, nil)
+}
+
 // reliablyPublishTransaction is a superset of publishTransaction which contains
 // the primary logic required for publishing a transaction, updating the
 // relevant database state, and finally possible removing the transaction from
 // the database (along with cleaning up all inputs used, and outputs created) if
-// the transaction is rejected by the backend.
-func (w *Wallet) reliablyPublishTransaction(tx *wire.MsgTx,
-	label string) (*chainhash.Hash, error) {
+// the transaction is rejected by the backend. The given labels of the
+// transaction's outputs, keyed by their index, are stored along with it.
+func (w *Wallet) reliablyPublishTransaction(tx *wire.MsgTx, label string,
+	outputLabels map[uint32]string) (*BroadcastResult, error) {
 
-	chainClient, err := w.requireChainClient()
+	chainClient, done, err := w.useChainClient()
 	if err != nil {
 		return nil, err
 	}
+	defer done()
 
 	// As we aim for this to be general reliable transaction broadcast API,
 	// we'll write this tx to disk as an unconfirmed transaction. This way,
@@ -3441,9 +4198,12 @@ func (w *Wallet) reliablyPublishTransaction(tx *wire.MsgTx,
 	}
 
 	// Along the way, we'll extract our relevant destination addresses from
-	// the transaction.
+	// the transaction. The wallet's subscribers are notified of the
+	// transaction being added, along with the balance changes it causes.
 	var ourAddrs []btcutil.Address
-	err = walletdb.Update(w.db, func(dbTx walletdb.ReadWriteTx) error {
+	err = w.updateWithEvents(func(dbTx walletdb.ReadWriteTx,
+		events *eventBatch) error {
+
 		addrmgrNs := dbTx.ReadWriteBucket(waddrmgrNamespaceKey)
 		for _, txOut := range tx.TxOut {
 			_, addrs, _, err := txscript.ExtractPkScriptAddrs(
@@ -3468,18 +4228,31 @@ func (w *Wallet) reliablyPublishTransaction(tx *wire.MsgTx,
 			}
 		}
 
+		if err := events.watchTxs(dbTx, txRec); err != nil {
+			return err
+		}
 		if err := w.addRelevantTx(dbTx, txRec, nil); err != nil {
 			return err
 		}
 
+		txmgrNs := dbTx.ReadWriteBucket(wtxmgrNamespaceKey)
+		for index, outputLabel := range outputLabels {
+			op := wire.OutPoint{Hash: tx.TxHash(), Index: index}
+			err := w.TxStore.SetOutputLabel(
+				txmgrNs, op, outputLabel,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
 		// If the tx label is empty, we can return early.
 		if len(label) == 0 {
 			return nil
 		}
 
-		// If there is a label we should write, get the namespace key
-		// and record it in the tx store.
-		txmgrNs := dbTx.ReadWriteBucket(wtxmgrNamespaceKey)
+		// If there is a label we should write, record it in the tx
+		// store.
 		return w.TxStore.PutTxLabel(txmgrNs, tx.TxHash(), label)
 	})
 	if err != nil {
@@ -3499,12 +4272,20 @@ func (w *Wallet) reliablyPublishTransaction(tx *wire.MsgTx,
 // publishTransaction attempts to send an unconfirmed transaction to the
 // wallet's current backend. In the event that sending the transaction fails for
 // whatever reason, it will be removed from the wallet's unconfirmed transaction
-// store.
-func (w *Wallet) publishTransaction(tx *wire.MsgTx) (*chainhash.Hash, error) {
-	chainClient, err := w.requireChainClient()
+// store. Transient connection errors are retried according to the wallet's
+// broadcast retry policy. If one is set, they're the only failures keeping the
+// transaction once retries are exhausted, as the backend may have accepted it
+// regardless.
+func (w *Wallet) publishTransaction(tx *wire.MsgTx) (*BroadcastResult, error) {
+	if err := w.ReorgHalted(); err != nil {
+		return nil, err
+	}
+
+	chainClient, done, err := w.useChainClient()
 	if err != nil {
 		return nil, err
 	}
+	defer done()
 
 	// match is a helper method to easily string match on the error
 	// message.
@@ -3512,23 +4293,42 @@ func (w *Wallet) publishTransaction(tx *wire.MsgTx) (*chainhash.Hash, error) {
 		return strings.Contains(strings.ToLower(err.Error()), s)
 	}
 
-	_, err = chainClient.SendRawTransaction(tx, false)
+	attempts, err := w.sendRawTransaction(chainClient, tx)
 
 	// Determine if this was an RPC error thrown due to the transaction
-	// already confirming.
-	var rpcTxConfirmed bool
-	if rpcErr, ok := err.(*btcjson.RPCError); ok {
+	// already confirming. The error may be wrapped if the transaction was
+	// also rejected by additional broadcasters of the backend.
+	var (
+		rpcTxConfirmed bool
+		rpcErr         *btcjson.RPCError
+	)
+	if errors.As(err, &rpcErr) {
 		rpcTxConfirmed = rpcErr.Code == btcjson.ErrRPCTxAlreadyInChain
 	}
 
 	var (
-		txid      = tx.TxHash()
+		result = &BroadcastResult{
+			Txid:     tx.TxHash(),
+			Attempts: attempts,
+		}
 		returnErr error
 	)
 
 	switch {
 	case err == nil:
-		return &txid, nil
+		result.Status = BroadcastAccepted
+		return result, nil
+
+	// If the outcome of the broadcast is unknown, as the connection to the
+	// backend failed, the transaction may have been accepted regardless,
+	// so we'll keep it to be rebroadcast rather than removing it. This is
+	// only done for callers that opted into retries, as others expect
+	// failed broadcasts to be removed.
+	case w.broadcastRetryPolicy.MaxRetries > 0 &&
+		isTransientBroadcastErr(err):
+
+		return nil, fmt.Errorf("%w: %v", ErrBroadcastOutcomeUnknown,
+			err)
 
 	// Since we have different backends that can be used with the wallet,
 	// we'll need to check specific errors for each one.
@@ -3545,7 +4345,8 @@ func (w *Wallet) publishTransaction(tx *wire.MsgTx) (*chainhash.Hash, error) {
 	// node that already has it in their mempool.
 	// https://github.com/bitcoin/bitcoin/blob/9bf5768dd628b3a7c30dd42b5ed477a92c4d3540/src/validation.cpp#L590
 	case match(err, "txn-already-in-mempool"):
-		return &txid, nil
+		result.Status = BroadcastInMempool
+		return result, nil
 
 	// If the transaction has already confirmed, we can safely remove it
 	// from the unconfirmed store as it should already exist within the
@@ -3582,7 +4383,8 @@ func (w *Wallet) publishTransaction(tx *wire.MsgTx) (*chainhash.Hash, error) {
 				"from unconfirmed store: %v", tx.TxHash(), dbErr)
 		}
 
-		return &txid, nil
+		result.Status = BroadcastInChain
+		return result, nil
 
 	// If the transactions is invalid since it attempts to double spend a
 	// transaction already in the mempool or in the chain, we'll remove it
@@ -3666,20 +4468,20 @@ func (w *Wallet) publishTransaction(tx *wire.MsgTx) (*chainhash.Hash, error) {
 
 	// We received an error not matching any of the above cases.
 	default:
-		returnErr = fmt.Errorf("unmatched backend error: %v", err)
+		returnErr = fmt.Errorf("unmatched backend error: %w", err)
 	}
 
 	// If the transaction was rejected for whatever other reason, then
-	// we'll remove it from the transaction store, as otherwise, we'll
-	// attempt to continually re-broadcast it, and the UTXO state of the
-	// wallet won't be accurate.
+	// we'll remove it from the transaction store, along with the labels of
+	// its outputs, as otherwise, we'll attempt to continually re-broadcast
+	// it, and the UTXO state of the wallet won't be accurate.
 	dbErr := walletdb.Update(w.db, func(dbTx walletdb.ReadWriteTx) error {
 		txmgrNs := dbTx.ReadWriteBucket(wtxmgrNamespaceKey)
 		txRec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
 		if err != nil {
 			return err
 		}
-		return w.TxStore.RemoveUnminedTx(txmgrNs, txRec)
+		return w.TxStore.DiscardUnminedTx(txmgrNs, txRec)
 	})
 	if dbErr != nil {
 		log.Warnf("Unable to remove invalid transaction %v: %v",
@@ -3712,7 +4514,8 @@ func CreateWithCallback(db walletdb.DB, pubPass, privPass []byte,
 	birthday time.Time, cb func(walletdb.ReadWriteTx) error) error {
 
 	return create(
-		db, pubPass, privPass, rootKey, params, birthday, false, cb,
+		db, pubPass, privPass, rootKey, params, birthday, false,
+		waddrmgr.DefaultKeyScopes, cb,
 	)
 }
 
@@ -3724,7 +4527,7 @@ func CreateWatchingOnlyWithCallback(db walletdb.DB, pubPass []byte,
 	cb func(walletdb.ReadWriteTx) error) error {
 
 	return create(
-		db, pubPass, nil, nil, params, birthday, true, cb,
+		db, pubPass, nil, nil, params, birthday, true, nil, cb,
 	)
 }
 
@@ -3736,7 +4539,22 @@ func Create(db walletdb.DB, pubPass, privPass []byte,
 	birthday time.Time) error {
 
 	return create(
-		db, pubPass, privPass, rootKey, params, birthday, false, nil,
+		db, pubPass, privPass, rootKey, params, birthday, false,
+		waddrmgr.DefaultKeyScopes, nil,
+	)
+}
+
+// CreateWithScopes is the same as Create, but only the given key scopes are
+// created along with their default account, rather than those of
+// waddrmgr.DefaultKeyScopes. Addresses can be derived from the default account
+// of each scope as soon as the wallet is opened.
+func CreateWithScopes(db walletdb.DB, pubPass, privPass []byte,
+	rootKey *hdkeychain.ExtendedKey, params *chaincfg.Params,
+	birthday time.Time, scopes []waddrmgr.KeyScope) error {
+
+	return create(
+		db, pubPass, privPass, rootKey, params, birthday, false, scopes,
+		nil,
 	)
 }
 
@@ -3748,13 +4566,13 @@ func CreateWatchingOnly(db walletdb.DB, pubPass []byte,
 	params *chaincfg.Params, birthday time.Time) error {
 
 	return create(
-		db, pubPass, nil, nil, params, birthday, true, nil,
+		db, pubPass, nil, nil, params, birthday, true, nil, nil,
 	)
 }
 
 func create(db walletdb.DB, pubPass, privPass []byte,
 	rootKey *hdkeychain.ExtendedKey, params *chaincfg.Params,
-	birthday time.Time, isWatchingOnly bool,
+	birthday time.Time, isWatchingOnly bool, scopes []waddrmgr.KeyScope,
 	cb func(walletdb.ReadWriteTx) error) error {
 
 	// If no root key was provided, we create one now from a random seed.
@@ -3792,9 +4610,9 @@ func create(db walletdb.DB, pubPass, privPass []byte,
 			return err
 		}
 
-		err = waddrmgr.Create(
+		err = waddrmgr.CreateWithScopes(
 			addrmgrNs, rootKey, pubPass, privPass, params, nil,
-			birthday,
+			birthday, scopes,
 		)
 		if err != nil {
 			return err
@@ -3843,6 +4661,27 @@ func Open(db walletdb.DB, pubPass []byte, cbs *waddrmgr.OpenCallbacks,
 			return err
 		}
 
+		// Ensure the wallet is being opened for the network it was
+		// created for. Wallets created before the network was stored
+		// will have it stored now.
+		net, err := waddrmgr.FetchNetwork(addrMgrBucket)
+		switch {
+		case waddrmgr.IsError(err, waddrmgr.ErrNoExist):
+			err = waddrmgr.PutNetwork(addrMgrBucket, params.Net)
+			if err != nil {
+				return err
+			}
+
+		case err != nil:
+			return err
+
+		case net != params.Net:
+			return &ErrNetworkMismatch{
+				Expected: net,
+				Actual:   params.Net,
+			}
+		}
+
 		addrMgr, err = waddrmgr.Open(addrMgrBucket, pubPass, params)
 		if err != nil {
 			return err
@@ -3861,26 +4700,38 @@ func Open(db walletdb.DB, pubPass []byte, cbs *waddrmgr.OpenCallbacks,
 	log.Infof("Opened wallet") // TODO: log balance? last sync height?
 
 	w := &Wallet{
-		publicPassphrase:    pubPass,
-		db:                  db,
-		Manager:             addrMgr,
-		TxStore:             txMgr,
-		lockedOutpoints:     map[wire.OutPoint]struct{}{},
-		recoveryWindow:      recoveryWindow,
-		rescanAddJob:        make(chan *RescanJob),
-		rescanBatch:         make(chan *rescanBatch),
-		rescanNotifications: make(chan interface{}),
-		rescanProgress:      make(chan *RescanProgressMsg),
-		rescanFinished:      make(chan *RescanFinishedMsg),
-		createTxRequests:    make(chan createTxRequest),
-		unlockRequests:      make(chan unlockRequest),
-		lockRequests:        make(chan struct{}),
-		holdUnlockRequests:  make(chan chan heldUnlock),
-		lockState:           make(chan bool),
-		changePassphrase:    make(chan changePassphraseRequest),
-		changePassphrases:   make(chan changePassphrasesRequest),
-		chainParams:         params,
-		quit:                make(chan struct{}),
+		publicPassphrase:        pubPass,
+		db:                      db,
+		Manager:                 addrMgr,
+		TxStore:                 txMgr,
+		lockedOutpoints:         map[wire.OutPoint]struct{}{},
+		recoveryWindow:          recoveryWindow,
+		maxReorgDepth:           waddrmgr.MaxReorgDepth,
+		finalityDepth:           DefaultFinalityDepth,
+		maxAncestorChainLength:  DefaultMaxAncestorChainLength,
+		rescanAddJob:            make(chan *RescanJob),
+		rescanBatch:             make(chan *rescanBatch),
+		rescanNotifications:     make(chan interface{}),
+		rescanProgress:          make(chan *RescanProgressMsg),
+		rescanFinished:          make(chan *RescanFinishedMsg),
+		rescanResubmit:          make(chan struct{}, 1),
+		createTxRequests:        make(chan createTxRequest),
+		unlockRequests:          make(chan unlockRequest),
+		lockRequests:            make(chan struct{}),
+		holdUnlockRequests:      make(chan chan heldUnlock),
+		lockState:               make(chan bool),
+		changePassphrase:        make(chan changePassphraseRequest),
+		changePassphrases:       make(chan changePassphrasesRequest),
+		coinbaseSweepTrigger:    make(chan struct{}, 1),
+		unconfirmedSince:        make(map[chainhash.Hash]int32),
+		feeCeiling:              DefaultFeeCeiling,
+		consolidationFeeCeiling: DefaultConsolidationFeeCeiling,
+		feeRateBounds:           DefaultFeeRateBounds,
+		txSizeLimits:            DefaultTxSizeLimits,
+		rebroadcastTrigger:      make(chan struct{}, 1),
+		eventBus:                newEventBus(),
+		chainParams:             params,
+		quit:                    make(chan struct{}),
 	}
 
 	w.NtfnServer = newNotificationServer(w)
@@ -3888,5 +4739,12 @@ func Open(db walletdb.DB, pubPass []byte, cbs *waddrmgr.OpenCallbacks,
 		w.NtfnServer.notifyUnspentOutput(0, hash, index)
 	}
 
+	// The addresses past the last used ones of each account branch are
+	// recovered up to the recovery window, which is therefore the gap
+	// limit in effect.
+	if recoveryWindow > 0 {
+		w.Manager.SetGapLimit(recoveryWindow)
+	}
+
 	return w, nil
 }
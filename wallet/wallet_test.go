@@ -2,9 +2,18 @@ package wallet
 
 import (
 	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/walletdb"
 	"github.com/btcsuite/btcwallet/wtxmgr"
 
@@ -203,3 +212,539 @@ func TestLabelTransaction(t *testing.T) {
 		})
 	}
 }
+
+// TestOpenNetworkMismatch ensures that a wallet can't be opened with chain
+// parameters for a different network than the one it was created for.
+func TestOpenNetworkMismatch(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "test_wallet_network")
+	if err != nil {
+		t.Fatalf("Failed to create db dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	seed, err := hdkeychain.GenerateSeed(hdkeychain.MinSeedBytes)
+	if err != nil {
+		t.Fatalf("unable to create seed: %v", err)
+	}
+
+	pubPass := []byte("hello")
+	privPass := []byte("world")
+
+	loader := NewLoader(
+		&chaincfg.RegressionNetParams, dir, true, defaultDBTimeout, 250,
+	)
+	_, err = loader.CreateNewWallet(pubPass, privPass, seed, time.Now())
+	if err != nil {
+		t.Fatalf("unable to create wallet: %v", err)
+	}
+	if err := loader.UnloadWallet(); err != nil {
+		t.Fatalf("unable to unload wallet: %v", err)
+	}
+
+	// Opening the wallet with mainnet parameters should fail, naming both
+	// networks involved.
+	loader = NewLoader(
+		&chaincfg.MainNetParams, dir, true, defaultDBTimeout, 250,
+	)
+	_, err = loader.OpenExistingWallet(pubPass, false)
+	var mismatchErr *ErrNetworkMismatch
+	if !errors.As(err, &mismatchErr) {
+		t.Fatalf("expected ErrNetworkMismatch, got: %v", err)
+	}
+	if mismatchErr.Expected != wire.TestNet {
+		t.Fatalf("expected network %v, got %v", wire.TestNet,
+			mismatchErr.Expected)
+	}
+	if mismatchErr.Actual != wire.MainNet {
+		t.Fatalf("expected network %v, got %v", wire.MainNet,
+			mismatchErr.Actual)
+	}
+
+	// The wallet should still open with the parameters it was created
+	// with.
+	loader = NewLoader(
+		&chaincfg.RegressionNetParams, dir, true, defaultDBTimeout, 250,
+	)
+	if _, err := loader.OpenExistingWallet(pubPass, false); err != nil {
+		t.Fatalf("unable to open wallet: %v", err)
+	}
+	if err := loader.UnloadWallet(); err != nil {
+		t.Fatalf("unable to unload wallet: %v", err)
+	}
+}
+
+// recordingChainConn is a chainConn that records the blocks requested from the
+// chain backing it.
+type recordingChainConn struct {
+	*mockChainConn
+
+	fetchedHeights []int64
+	fetchedHeaders []chainhash.Hash
+}
+
+// GetBlockHash returns the hash of the block with the given height.
+func (c *recordingChainConn) GetBlockHash(height int64) (*chainhash.Hash,
+	error) {
+
+	c.fetchedHeights = append(c.fetchedHeights, height)
+	return c.mockChainConn.GetBlockHash(height)
+}
+
+// GetBlockHeader returns the header for the block with the given hash.
+func (c *recordingChainConn) GetBlockHeader(
+	hash *chainhash.Hash) (*wire.BlockHeader, error) {
+
+	c.fetchedHeaders = append(c.fetchedHeaders, *hash)
+	return c.mockChainConn.GetBlockHeader(hash)
+}
+
+// TestSyncFromChainTip ensures that a wallet skipping its historical scan
+// starts syncing from the chain tip without fetching any prior blocks.
+func TestSyncFromChainTip(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	const chainTip = 1000
+	chainConn := &recordingChainConn{
+		mockChainConn: createMockChainConn(
+			chaincfg.MainNetParams.GenesisBlock, chainTip,
+			10*time.Minute,
+		),
+	}
+
+	skipped, err := w.HistoricalScanSkipped()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped {
+		t.Fatal("expected historical scan to not be skipped")
+	}
+
+	tip, err := w.syncFromChainTip(chainConn)
+	if err != nil {
+		t.Fatalf("unable to sync from chain tip: %v", err)
+	}
+	if tip.Height != chainTip {
+		t.Fatalf("expected tip height %d, got %d", chainTip, tip.Height)
+	}
+
+	// Only the header of the chain tip should have been fetched.
+	if len(chainConn.fetchedHeights) != 0 {
+		t.Fatalf("expected no block hashes to be fetched, got "+
+			"heights %v", chainConn.fetchedHeights)
+	}
+	tipHash := chainConn.blockHashes[chainTip]
+	if len(chainConn.fetchedHeaders) != 1 ||
+		chainConn.fetchedHeaders[0] != tipHash {
+
+		t.Fatalf("expected only tip header %v to be fetched, got %v",
+			tipHash, chainConn.fetchedHeaders)
+	}
+
+	// The wallet should now be synced to and born at the chain tip, and
+	// report that its historical scan was skipped.
+	if w.Manager.SyncedTo().Hash != tipHash {
+		t.Fatalf("expected wallet to be synced to %v, got %v",
+			tipHash, w.Manager.SyncedTo().Hash)
+	}
+	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(waddrmgrNamespaceKey)
+		birthdayBlock, verified, err := w.Manager.BirthdayBlock(ns)
+		if err != nil {
+			return err
+		}
+		if birthdayBlock.Hash != tipHash || !verified {
+			t.Fatalf("expected verified birthday block %v, got %v",
+				tipHash, birthdayBlock.Hash)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	skipped, err = w.HistoricalScanSkipped()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !skipped {
+		t.Fatal("expected historical scan to be skipped")
+	}
+
+	// Rescanning the skipped history requires the block to start from.
+	if err := w.RescanHistory(nil); err == nil {
+		t.Fatal("expected history rescan without start block to fail")
+	}
+}
+
+// TestFrozenOutputs ensures that frozen outputs are excluded from coin
+// selection until unfrozen, while still being listed as unspent and spendable
+// when explicitly selected.
+func TestFrozenOutputs(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+
+	// Fund the wallet with two outputs, freezing the first.
+	const value = 100000
+	incomingTx := wire.NewMsgTx(wire.TxVersion)
+	incomingTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	incomingTx.AddTxOut(wire.NewTxOut(value, pkScript))
+	incomingTx.AddTxOut(wire.NewTxOut(value, pkScript))
+	addUtxo(t, w, incomingTx)
+
+	frozenOp := wire.OutPoint{Hash: incomingTx.TxHash(), Index: 0}
+	availableOp := wire.OutPoint{Hash: incomingTx.TxHash(), Index: 1}
+
+	err = w.FreezeOutput(wire.OutPoint{Hash: chainhash.Hash{0x02}})
+	if err != wtxmgr.ErrUnknownOutput {
+		t.Fatalf("expected ErrUnknownOutput, got %v", err)
+	}
+	if err := w.FreezeOutput(frozenOp); err != nil {
+		t.Fatalf("unable to freeze output: %v", err)
+	}
+	frozen, err := w.FrozenOutputs()
+	if err != nil {
+		t.Fatalf("unable to list frozen outputs: %v", err)
+	}
+	if len(frozen) != 1 || frozen[0] != frozenOp {
+		t.Fatalf("expected frozen output %v, got %v", frozenOp, frozen)
+	}
+
+	// The frozen output should still be listed as unspent.
+	unspent, err := w.ListUnspent(0, 999999, "")
+	if err != nil {
+		t.Fatalf("unable to list unspent outputs: %v", err)
+	}
+	if len(unspent) != 2 {
+		t.Fatalf("expected 2 unspent outputs, got %d", len(unspent))
+	}
+
+	// Coin selection shouldn't select the frozen output, so paying more
+	// than the available output is unable to be funded.
+	txOuts := []*wire.TxOut{wire.NewTxOut(value/2, pkScript)}
+	tx, err := w.txToOutputs(
+		txOuts, nil, 0, 1, 1000, CoinSelectionLargest, true,
+		defaultTxCreateOptions(),
+	)
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if len(tx.Tx.TxIn) != 1 ||
+		tx.Tx.TxIn[0].PreviousOutPoint != availableOp {
+
+		t.Fatalf("expected only %v to be selected, got %v", availableOp,
+			tx.Tx.TxIn)
+	}
+
+	txOuts = []*wire.TxOut{wire.NewTxOut(value*3/2, pkScript)}
+	_, err = w.txToOutputs(
+		txOuts, nil, 0, 1, 1000, CoinSelectionLargest, true,
+		defaultTxCreateOptions(),
+	)
+	if err == nil {
+		t.Fatalf("expected frozen output to not be selected")
+	}
+
+	// It can still be spent by explicitly selecting it.
+	_, err = w.SweepOutputs([]wire.OutPoint{frozenOp}, addr, 1000)
+	if err != nil {
+		t.Fatalf("unable to sweep frozen output: %v", err)
+	}
+
+	// Once unfrozen, it should be selected once again.
+	if err := w.UnfreezeOutput(frozenOp); err != nil {
+		t.Fatalf("unable to unfreeze output: %v", err)
+	}
+	tx, err = w.txToOutputs(
+		txOuts, nil, 0, 1, 1000, CoinSelectionLargest, true,
+		defaultTxCreateOptions(),
+	)
+	if err != nil {
+		t.Fatalf("unable to author tx: %v", err)
+	}
+	if len(tx.Tx.TxIn) != 2 {
+		t.Fatalf("expected 2 inputs, got %d", len(tx.Tx.TxIn))
+	}
+}
+
+// TestSelectionDenylist ensures that denylisted outputs are never selected
+// automatically, while still counting towards the balance and being spendable
+// when explicitly selected.
+func TestSelectionDenylist(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	addr, err := w.NewAddress(0, waddrmgr.KeyScopeBIP0084)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create pkScript: %v", err)
+	}
+
+	// Fund the wallet with two outputs, denylisting the first by its
+	// outpoint.
+	const value = 100000
+	incomingTx := wire.NewMsgTx(wire.TxVersion)
+	incomingTx.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: chainhash.Hash{0x01}}, nil, nil,
+	))
+	incomingTx.AddTxOut(wire.NewTxOut(value, pkScript))
+	incomingTx.AddTxOut(wire.NewTxOut(value, pkScript))
+	addUtxo(t, w, incomingTx)
+
+	deniedOp := wire.OutPoint{Hash: incomingTx.TxHash(), Index: 0}
+	availableOp := wire.OutPoint{Hash: incomingTx.TxHash(), Index: 1}
+	deniedEntry := wtxmgr.DenylistEntry{OutPoint: &deniedOp}
+	if err := w.AddToSelectionDenylist(deniedEntry); err != nil {
+		t.Fatalf("unable to denylist output: %v", err)
+	}
+	denylist, err := w.SelectionDenylist()
+	if err != nil {
+		t.Fatalf("unable to fetch selection denylist: %v", err)
+	}
+	if len(denylist) != 1 || denylist[0].OutPoint == nil ||
+		*denylist[0].OutPoint != deniedOp {
+
+		t.Fatalf("expected denylisted output %v, got %v", deniedOp,
+			denylist)
+	}
+
+	// The denylisted output should still count towards the balance.
+	var balance btcutil.Amount
+	err = walletdb.View(w.db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(wtxmgrNamespaceKey)
+		var err error
+		balance, err = w.TxStore.Balance(ns, 1, testBlockHeight)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to calculate balance: %v", err)
+	}
+	if balance != 2*value {
+		t.Fatalf("expected balance of %v, got %v", 2*value, balance)
+	}
+
+	// Coin selection should never select the denylisted output,
+	// regardless of the strategy, so paying more than the available
+	// output is unable to be funded.
+	for _, strategy := range []CoinSelectionStrategy{
+		CoinSelectionLargest, CoinSelectionRandom,
+	} {
+		txOuts := []*wire.TxOut{wire.NewTxOut(value/2, pkScript)}
+		tx, err := w.txToOutputs(
+			txOuts, nil, 0, 1, 1000, strategy, true,
+			defaultTxCreateOptions(),
+		)
+		if err != nil {
+			t.Fatalf("unable to author tx: %v", err)
+		}
+		if len(tx.Tx.TxIn) != 1 ||
+			tx.Tx.TxIn[0].PreviousOutPoint != availableOp {
+
+			t.Fatalf("expected only %v to be selected, got %v",
+				availableOp, tx.Tx.TxIn)
+		}
+	}
+
+	txOuts := []*wire.TxOut{wire.NewTxOut(value*3/2, pkScript)}
+	_, err = w.txToOutputs(
+		txOuts, nil, 0, 1, 1000, CoinSelectionLargest, true,
+		defaultTxCreateOptions(),
+	)
+	if err == nil {
+		t.Fatalf("expected denylisted output to not be selected")
+	}
+
+	// Denylisting the outputs' script excludes the other output too.
+	scriptEntry := wtxmgr.DenylistEntry{PkScript: pkScript}
+	if err := w.AddToSelectionDenylist(scriptEntry); err != nil {
+		t.Fatalf("unable to denylist script: %v", err)
+	}
+	txOuts = []*wire.TxOut{wire.NewTxOut(value/2, pkScript)}
+	_, err = w.txToOutputs(
+		txOuts, nil, 0, 1, 1000, CoinSelectionLargest, true,
+		defaultTxCreateOptions(),
+	)
+	if err == nil {
+		t.Fatalf("expected denylisted script to not be selected")
+	}
+	if err := w.RemoveFromSelectionDenylist(scriptEntry); err != nil {
+		t.Fatalf("unable to remove script from denylist: %v", err)
+	}
+
+	// The denylisted output can still be spent by explicitly selecting
+	// it.
+	_, err = w.SweepOutputs([]wire.OutPoint{deniedOp}, addr, 1000)
+	if err != nil {
+		t.Fatalf("unable to sweep denylisted output: %v", err)
+	}
+}
+
+// watchingChainClient is a mock chain client recording the addresses it's
+// asked to watch.
+type watchingChainClient struct {
+	mockChainClient
+
+	watched map[string]struct{}
+}
+
+func (c *watchingChainClient) NotifyReceived(addrs []btcutil.Address) error {
+	for _, addr := range addrs {
+		c.watched[addr.EncodeAddress()] = struct{}{}
+	}
+	return nil
+}
+
+// TestExtendAddresses ensures that extending the addresses of an account
+// branch derives and watches the requested number of addresses, and advances
+// the branch's last derived index past them.
+func TestExtendAddresses(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	chainClient := &watchingChainClient{
+		watched: make(map[string]struct{}),
+	}
+	w.chainClient = chainClient
+
+	const count = 50
+	scope := waddrmgr.KeyScopeBIP0084
+	addrs, err := w.ExtendAddresses(0, scope, true, count)
+	if err != nil {
+		t.Fatalf("unable to extend addresses: %v", err)
+	}
+	if len(addrs) != count {
+		t.Fatalf("expected %d addresses, got %d", count, len(addrs))
+	}
+
+	// Each of the addresses should be a distinct external address of the
+	// account, watched by the chain client.
+	seen := make(map[string]struct{}, count)
+	for i, addr := range addrs {
+		if _, ok := chainClient.watched[addr.EncodeAddress()]; !ok {
+			t.Fatalf("expected address %d to be watched", i)
+		}
+		if _, ok := seen[addr.EncodeAddress()]; ok {
+			t.Fatalf("expected address %d to be distinct", i)
+		}
+		seen[addr.EncodeAddress()] = struct{}{}
+
+		info, err := w.AddressInfo(addr)
+		if err != nil {
+			t.Fatalf("unable to fetch address info: %v", err)
+		}
+		pubKeyAddr, ok := info.Address.(waddrmgr.ManagedPubKeyAddress)
+		if !ok {
+			t.Fatalf("expected a public key address, got %T",
+				info.Address)
+		}
+		_, path, _ := pubKeyAddr.DerivationInfo()
+		if path.Branch != waddrmgr.ExternalBranch ||
+			path.Index != uint32(i) {
+
+			t.Fatalf("expected address %d to be external index "+
+				"%d, got branch %d index %d", i, i, path.Branch,
+				path.Index)
+		}
+	}
+
+	// The stored last derived index should have advanced, such that the
+	// next address follows the extended ones, while the internal branch
+	// is left untouched.
+	props, err := w.AccountProperties(scope, 0)
+	if err != nil {
+		t.Fatalf("unable to fetch account properties: %v", err)
+	}
+	if props.ExternalKeyCount != count || props.InternalKeyCount != 0 {
+		t.Fatalf("expected %d external and 0 internal keys, got %d "+
+			"and %d", count, props.ExternalKeyCount,
+			props.InternalKeyCount)
+	}
+
+	next, err := w.NewAddress(0, scope)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	if _, ok := seen[next.EncodeAddress()]; ok {
+		t.Fatal("expected next address to follow the extended ones")
+	}
+
+	// Extending the internal branch should only derive change addresses.
+	changeAddrs, err := w.ExtendAddresses(0, scope, false, 2)
+	if err != nil {
+		t.Fatalf("unable to extend addresses: %v", err)
+	}
+	for _, addr := range changeAddrs {
+		info, err := w.AddressInfo(addr)
+		if err != nil {
+			t.Fatalf("unable to fetch address info: %v", err)
+		}
+		if !info.Internal {
+			t.Fatalf("expected %v to be a change address", addr)
+		}
+	}
+}
+
+// TestCheckPassphrase ensures that checking the wallet's passphrase reports
+// whether it's correct without changing the wallet's lock state or timeout.
+func TestCheckPassphrase(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	// We'll replace the wallet's lock timeout with one we control.
+	lockAfter := make(chan time.Time)
+	if err := w.Unlock([]byte("world"), lockAfter); err != nil {
+		t.Fatalf("unable to unlock wallet: %v", err)
+	}
+
+	checkPassphrase := func(locked bool) {
+		t.Helper()
+
+		if err := w.CheckPassphrase([]byte("world")); err != nil {
+			t.Fatalf("expected correct passphrase, got %v", err)
+		}
+		err := w.CheckPassphrase([]byte("wrong"))
+		if !waddrmgr.IsError(err, waddrmgr.ErrWrongPassphrase) {
+			t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+		}
+		if w.Locked() != locked {
+			t.Fatalf("expected locked wallet: %v, got %v", locked,
+				w.Locked())
+		}
+	}
+	checkPassphrase(false)
+
+	// The wallet should still be locked once its timeout expires.
+	lockAfter <- time.Now()
+	if !w.Locked() {
+		t.Fatal("expected wallet to be locked after timeout")
+	}
+
+	// Checking the passphrase of a locked wallet shouldn't unlock it.
+	checkPassphrase(true)
+}
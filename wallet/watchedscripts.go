@@ -0,0 +1,82 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// WatchedScripts returns every output script the wallet currently considers
+// relevant, such that an external indexer or filter service can watch the
+// chain on its behalf without deriving them itself. These are the scripts of
+// the active addresses of every key scope, including the imported ones, and of
+// the wallet's time-locked scripts, along with the scripts of the wallet's
+// unspent outputs, whose spends must be watched as well. This is the same set
+// the wallet registers with its own chain backend.
+//
+// The scripts are deduplicated and sorted, such that the result is stable
+// across calls as long as the wallet's addresses and outputs don't change.
+func (w *Wallet) WatchedScripts() ([][]byte, error) {
+	seen := make(map[string]struct{})
+	var scripts [][]byte
+	addScript := func(script []byte) {
+		if _, ok := seen[string(script)]; ok {
+			return
+		}
+		seen[string(script)] = struct{}{}
+		scripts = append(scripts, script)
+	}
+
+	err := walletdb.View(w.db, func(dbtx walletdb.ReadTx) error {
+		addrmgrNs := dbtx.ReadBucket(waddrmgrNamespaceKey)
+		txmgrNs := dbtx.ReadBucket(wtxmgrNamespaceKey)
+
+		err := w.Manager.ForEachRelevantActiveAddress(
+			addrmgrNs, func(addr btcutil.Address) error {
+				script, err := txscript.PayToAddrScript(addr)
+				if err != nil {
+					return err
+				}
+				addScript(script)
+				return nil
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		timeLocked, err := w.TxStore.TimeLockedScripts(txmgrNs)
+		if err != nil {
+			return err
+		}
+		for _, script := range timeLocked {
+			addScript(script)
+		}
+
+		unspent, err := w.TxStore.UnspentOutputs(txmgrNs)
+		if err != nil {
+			return err
+		}
+		for _, credit := range unspent {
+			addScript(credit.PkScript)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(scripts, func(i, j int) bool {
+		return bytes.Compare(scripts[i], scripts[j]) < 0
+	})
+
+	return scripts, nil
+}
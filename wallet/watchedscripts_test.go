@@ -0,0 +1,116 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wallet
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWatchedScripts ensures that the watched scripts of the wallet match the
+// scripts of its derived and imported addresses and its time-locked scripts,
+// along with those of its unspent outputs.
+func TestWatchedScripts(t *testing.T) {
+	t.Parallel()
+
+	w, cleanup := testWallet(t)
+	defer cleanup()
+
+	scripts, err := w.WatchedScripts()
+	require.NoError(t, err)
+	require.Empty(t, scripts)
+
+	var expected [][]byte
+	addAddr := func(addr btcutil.Address) {
+		t.Helper()
+
+		script, err := txscript.PayToAddrScript(addr)
+		require.NoError(t, err)
+		expected = append(expected, script)
+	}
+
+	// Derive external and internal addresses across scopes.
+	for _, scope := range waddrmgr.DefaultKeyScopes {
+		addr, err := w.NewAddress(0, scope)
+		require.NoError(t, err)
+		addAddr(addr)
+
+		addr, err = w.NewChangeAddress(0, scope)
+		require.NoError(t, err)
+		addAddr(addr)
+	}
+
+	// Import a public key and a script into the imported account.
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	err = w.ImportPublicKey(privKey.PubKey(), waddrmgr.WitnessPubKey)
+	require.NoError(t, err)
+	pubKeyAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+		btcutil.Hash160(privKey.PubKey().SerializeCompressed()),
+		w.chainParams,
+	)
+	require.NoError(t, err)
+	addAddr(pubKeyAddr)
+
+	pubKey, err := btcutil.NewAddressPubKey(
+		privKey.PubKey().SerializeCompressed(), w.chainParams,
+	)
+	require.NoError(t, err)
+	multiSigScript, err := txscript.MultiSigScript(
+		[]*btcutil.AddressPubKey{pubKey}, 1,
+	)
+	require.NoError(t, err)
+	scriptAddr, err := w.ImportP2SHRedeemScript(multiSigScript)
+	require.NoError(t, err)
+	addAddr(scriptAddr)
+
+	// Time-locked scripts are watched before any output pays to them.
+	timeLockedScript := append(
+		[]byte{txscript.OP_0, txscript.OP_DATA_32}, make([]byte, 32)...,
+	)
+	err = walletdb.Update(w.db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		return w.TxStore.PutTimeLockedScript(
+			ns, timeLockedScript, []byte{txscript.OP_TRUE}, 1,
+		)
+	})
+	require.NoError(t, err)
+	expected = append(expected, timeLockedScript)
+
+	// An unspent output paying to one of the wallet's addresses doesn't
+	// add a script, while one paying to a script not known to the address
+	// manager does.
+	foreignScript := []byte{txscript.OP_TRUE}
+	addUtxo(t, w, &wire.MsgTx{
+		TxIn: []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{
+			wire.NewTxOut(100000, expected[0]),
+			wire.NewTxOut(100000, foreignScript),
+		},
+	})
+	expected = append(expected, foreignScript)
+
+	sort.Slice(expected, func(i, j int) bool {
+		return bytes.Compare(expected[i], expected[j]) < 0
+	})
+
+	scripts, err = w.WatchedScripts()
+	require.NoError(t, err)
+	require.Equal(t, expected, scripts)
+
+	// The result is stable across calls.
+	scripts, err = w.WatchedScripts()
+	require.NoError(t, err)
+	require.Equal(t, expected, scripts)
+}
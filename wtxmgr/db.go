@@ -70,6 +70,14 @@ var (
 	bucketUnminedCredits = []byte("mc")
 	bucketUnminedInputs  = []byte("mi")
 	bucketLockedOutputs  = []byte("lo")
+	bucketFrozenOutputs  = []byte("fo")
+	bucketRelativeLocks  = []byte("rl")
+	bucketReplacements   = []byte("rp")
+	bucketScriptTxs      = []byte("st")
+	bucketTxComments     = []byte("tc")
+	bucketOutputLabels   = []byte("ol")
+	bucketTimeLocked     = []byte("tl")
+	bucketDenylist       = []byte("sd")
 )
 
 // Root (namespace) bucket keys
@@ -519,6 +527,7 @@ func latestTxRecord(ns walletdb.ReadBucket, txHash *chainhash.Hash) (k, v []byte
 //   [8]     Flags (1 byte)
 //             0x01: Spent
 //             0x02: Change
+//             0x04: Self-transfer
 //   [9:81]  OPTIONAL Debit bucket key (72 bytes)
 //             [9:41]  Spender transaction hash (32 bytes)
 //             [41:45] Spender block height (4 bytes)
@@ -546,6 +555,9 @@ func valueUnspentCredit(cred *credit) []byte {
 	if cred.change {
 		v[8] |= 1 << 1
 	}
+	if cred.selfTransfer {
+		v[8] |= 1 << 2
+	}
 	return v
 }
 
@@ -607,6 +619,27 @@ func fetchRawCreditAmountChange(v []byte) (btcutil.Amount, bool, error) {
 	return btcutil.Amount(byteOrder.Uint64(v)), v[8]&(1<<1) != 0, nil
 }
 
+// fetchRawCreditSelfTransfer returns whether the mined or unmined credit value
+// is marked as a self-transfer.
+func fetchRawCreditSelfTransfer(v []byte) bool {
+	return len(v) >= 9 && v[8]&(1<<2) != 0
+}
+
+// fetchRawCreditSpender returns the input spending the credit with the given
+// value, if it's spent by a mined transaction, and nil otherwise.
+func fetchRawCreditSpender(v []byte) *indexedIncidence {
+	if v[8]&(1<<0) == 0 || len(v) < 81 {
+		return nil
+	}
+
+	var spender indexedIncidence
+	copy(spender.txHash[:], v[9:41])
+	spender.block.Height = int32(byteOrder.Uint32(v[41:45]))
+	copy(spender.block.Hash[:], v[45:77])
+	spender.index = byteOrder.Uint32(v[77:81])
+	return &spender
+}
+
 // fetchRawCreditUnspentValue returns the unspent value for a raw credit key.
 // This may be used to mark a credit as unspent.
 func fetchRawCreditUnspentValue(k []byte) ([]byte, error) {
@@ -729,6 +762,7 @@ func (it *creditIterator) readElem() error {
 	it.elem.Amount = btcutil.Amount(byteOrder.Uint64(it.cv))
 	it.elem.Spent = it.cv[8]&(1<<0) != 0
 	it.elem.Change = it.cv[8]&(1<<1) != 0
+	it.elem.SelfTransfer = it.cv[8]&(1<<2) != 0
 	return nil
 }
 
@@ -1036,12 +1070,18 @@ func deleteRawUnmined(ns walletdb.ReadWriteBucket, k []byte) error {
 //   [0:8]   Amount (8 bytes)
 //   [8]     Flags (1 byte)
 //             0x02: Change
+//             0x04: Self-transfer
+
+func valueUnminedCredit(amount btcutil.Amount, change,
+	selfTransfer bool) []byte {
 
-func valueUnminedCredit(amount btcutil.Amount, change bool) []byte {
 	v := make([]byte, 9)
 	byteOrder.PutUint64(v, uint64(amount))
 	if change {
-		v[8] = 1 << 1
+		v[8] |= 1 << 1
+	}
+	if selfTransfer {
+		v[8] |= 1 << 2
 	}
 	return v
 }
@@ -1154,6 +1194,7 @@ func (it *unminedCreditIterator) readElem() error {
 	it.elem.Index = index
 	it.elem.Amount = amount
 	it.elem.Change = change
+	it.elem.SelfTransfer = fetchRawCreditSelfTransfer(it.cv)
 	// Spent intentionally not set
 
 	return nil
@@ -1405,6 +1446,682 @@ func forEachLockedOutput(ns walletdb.ReadBucket,
 	})
 }
 
+// The frozen outputs bucket maps the canonical outpoint of each frozen output
+// to a placeholder value:
+//
+//	[0] Placeholder (1 byte)
+//
+// Unlike the locked outputs bucket, it's not deleted along with the
+// transaction history, as outputs are only unfrozen explicitly.
+var frozenOutputValue = []byte{0}
+
+// isFrozenOutput determines whether an output has been frozen.
+func isFrozenOutput(ns walletdb.ReadBucket, op wire.OutPoint) bool {
+	// The bucket may not exist, indicating that no outputs have ever been
+	// frozen.
+	frozenOutputs := ns.NestedReadBucket(bucketFrozenOutputs)
+	if frozenOutputs == nil {
+		return false
+	}
+
+	k := canonicalOutPoint(&op.Hash, op.Index)
+	return frozenOutputs.Get(k) != nil
+}
+
+// freezeOutput freezes an output, preventing it from becoming eligible for
+// coin selection until it's unfrozen.
+func freezeOutput(ns walletdb.ReadWriteBucket, op wire.OutPoint) error {
+	// Create the corresponding bucket if necessary.
+	frozenOutputs, err := ns.CreateBucketIfNotExists(bucketFrozenOutputs)
+	if err != nil {
+		str := "failed to create frozen outputs bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	k := canonicalOutPoint(&op.Hash, op.Index)
+	if err := frozenOutputs.Put(k, frozenOutputValue); err != nil {
+		str := fmt.Sprintf("%s: put failed for %v", bucketFrozenOutputs,
+			op)
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// unfreezeOutput unfreezes an output, making it eligible for coin selection
+// if still unspent and unlocked.
+func unfreezeOutput(ns walletdb.ReadWriteBucket, op wire.OutPoint) error {
+	// The bucket may not exist, indicating that no outputs have ever been
+	// frozen, so we can just return now.
+	frozenOutputs := ns.NestedReadWriteBucket(bucketFrozenOutputs)
+	if frozenOutputs == nil {
+		return nil
+	}
+
+	k := canonicalOutPoint(&op.Hash, op.Index)
+	if err := frozenOutputs.Delete(k); err != nil {
+		str := fmt.Sprintf("%s: delete failed for %v",
+			bucketFrozenOutputs, op)
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// forEachFrozenOutput iterates over all frozen outputs and invokes the
+// callback `f` for each.
+func forEachFrozenOutput(ns walletdb.ReadBucket, f func(wire.OutPoint)) error {
+	// The bucket may not exist, indicating that no outputs have ever been
+	// frozen, so we can just return now.
+	frozenOutputs := ns.NestedReadBucket(bucketFrozenOutputs)
+	if frozenOutputs == nil {
+		return nil
+	}
+
+	return frozenOutputs.ForEach(func(k, _ []byte) error {
+		var op wire.OutPoint
+		if err := readCanonicalOutPoint(k, &op); err != nil {
+			return err
+		}
+
+		f(op)
+
+		return nil
+	})
+}
+
+// The relative locks bucket maps the canonical outpoint of each output
+// encumbered by a BIP 68 relative lock-time, such as through a CSV script, to
+// the lock-time, encoded as the sequence number of the input spending it:
+//
+//	[0:4] Sequence number (4 bytes)
+//
+// As with frozen outputs, entries are only removed once the output has a
+// confirmed spend.
+
+// fetchRelativeLock returns the relative lock-time of an output, if any.
+func fetchRelativeLock(ns walletdb.ReadBucket, op wire.OutPoint) (uint32,
+	bool) {
+
+	// The bucket may not exist, indicating that no relative lock-times
+	// have ever been recorded.
+	relativeLocks := ns.NestedReadBucket(bucketRelativeLocks)
+	if relativeLocks == nil {
+		return 0, false
+	}
+
+	k := canonicalOutPoint(&op.Hash, op.Index)
+	v := relativeLocks.Get(k)
+	if len(v) != 4 {
+		return 0, false
+	}
+
+	return byteOrder.Uint32(v), true
+}
+
+// putRelativeLock records the relative lock-time encumbering an output.
+func putRelativeLock(ns walletdb.ReadWriteBucket, op wire.OutPoint,
+	sequence uint32) error {
+
+	// Create the corresponding bucket if necessary.
+	relativeLocks, err := ns.CreateBucketIfNotExists(bucketRelativeLocks)
+	if err != nil {
+		str := "failed to create relative locks bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	k := canonicalOutPoint(&op.Hash, op.Index)
+	var v [4]byte
+	byteOrder.PutUint32(v[:], sequence)
+	if err := relativeLocks.Put(k, v[:]); err != nil {
+		str := fmt.Sprintf("%s: put failed for %v", bucketRelativeLocks,
+			op)
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// deleteRelativeLock removes the relative lock-time recorded for an output.
+func deleteRelativeLock(ns walletdb.ReadWriteBucket, op wire.OutPoint) error {
+	// The bucket may not exist, indicating that no relative lock-times
+	// have ever been recorded, so we can just return now.
+	relativeLocks := ns.NestedReadWriteBucket(bucketRelativeLocks)
+	if relativeLocks == nil {
+		return nil
+	}
+
+	k := canonicalOutPoint(&op.Hash, op.Index)
+	if err := relativeLocks.Delete(k); err != nil {
+		str := fmt.Sprintf("%s: delete failed for %v",
+			bucketRelativeLocks, op)
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// The time-locked scripts bucket maps each output script paying to a script
+// encumbered by an absolute lock-time, such as through CLTV, to the lock-time
+// and the witness script itself, such that the outputs paying to it can be
+// recognized and spent:
+//
+//	[0:4] Lock-time (4 bytes)
+//	[4:]  Witness script
+//
+// Entries are only removed for scripts that were never paid to, as the script
+// may be paid to again otherwise.
+
+// fetchTimeLockedScript returns the witness script and lock-time of an output
+// script paying to a time-locked script, if any.
+func fetchTimeLockedScript(ns walletdb.ReadBucket, pkScript []byte) ([]byte,
+	uint32, bool) {
+
+	// The bucket may not exist, indicating that no time-locked scripts
+	// have ever been recorded.
+	timeLocked := ns.NestedReadBucket(bucketTimeLocked)
+	if timeLocked == nil {
+		return nil, 0, false
+	}
+
+	v := timeLocked.Get(pkScript)
+	if len(v) < 4 {
+		return nil, 0, false
+	}
+
+	witnessScript := append([]byte(nil), v[4:]...)
+	return witnessScript, byteOrder.Uint32(v[:4]), true
+}
+
+// fetchTimeLockedScripts returns the output scripts paying to a time-locked
+// script.
+func fetchTimeLockedScripts(ns walletdb.ReadBucket) ([][]byte, error) {
+	timeLocked := ns.NestedReadBucket(bucketTimeLocked)
+	if timeLocked == nil {
+		return nil, nil
+	}
+
+	var pkScripts [][]byte
+	err := timeLocked.ForEach(func(k, _ []byte) error {
+		pkScripts = append(pkScripts, append([]byte(nil), k...))
+		return nil
+	})
+	if err != nil {
+		str := "failed iterating time-locked scripts bucket"
+		return nil, storeError(ErrDatabase, str, err)
+	}
+
+	return pkScripts, nil
+}
+
+// putTimeLockedScript records the witness script and lock-time of an output
+// script paying to a time-locked script.
+func putTimeLockedScript(ns walletdb.ReadWriteBucket, pkScript,
+	witnessScript []byte, lockTime uint32) error {
+
+	// Create the corresponding bucket if necessary.
+	timeLocked, err := ns.CreateBucketIfNotExists(bucketTimeLocked)
+	if err != nil {
+		str := "failed to create time-locked scripts bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	v := make([]byte, 4+len(witnessScript))
+	byteOrder.PutUint32(v[:4], lockTime)
+	copy(v[4:], witnessScript)
+	if err := timeLocked.Put(pkScript, v); err != nil {
+		str := fmt.Sprintf("%s: put failed for script %x",
+			bucketTimeLocked, pkScript)
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// deleteTimeLockedScript removes the record of an output script paying to a
+// time-locked script.
+func deleteTimeLockedScript(ns walletdb.ReadWriteBucket,
+	pkScript []byte) error {
+
+	timeLocked := ns.NestedReadWriteBucket(bucketTimeLocked)
+	if timeLocked == nil {
+		return nil
+	}
+
+	if err := timeLocked.Delete(pkScript); err != nil {
+		str := fmt.Sprintf("%s: delete failed for script %x",
+			bucketTimeLocked, pkScript)
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// putTxReplacement records that a transaction was replaced by another. The
+// replacements bucket maps the hash of each transaction replaced while
+// unconfirmed to the hash of the transaction that replaced it:
+//
+//	[0:32] Replacement transaction hash (32 bytes)
+func putTxReplacement(ns walletdb.ReadWriteBucket, replaced,
+	replacement *chainhash.Hash) error {
+
+	// Create the corresponding bucket if necessary.
+	replacements, err := ns.CreateBucketIfNotExists(bucketReplacements)
+	if err != nil {
+		str := "failed to create replacements bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	if err := replacements.Put(replaced[:], replacement[:]); err != nil {
+		str := fmt.Sprintf("%s: put failed for %v", bucketReplacements,
+			replaced)
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// fetchTxReplacement returns the hash of the transaction that replaced the
+// given one, or nil if it's not known to have been replaced.
+func fetchTxReplacement(ns walletdb.ReadBucket,
+	replaced *chainhash.Hash) (*chainhash.Hash, error) {
+
+	// The bucket may not exist, indicating that no transactions have ever
+	// been replaced.
+	replacements := ns.NestedReadBucket(bucketReplacements)
+	if replacements == nil {
+		return nil, nil
+	}
+
+	v := replacements.Get(replaced[:])
+	if v == nil {
+		return nil, nil
+	}
+	if len(v) != 32 {
+		str := fmt.Sprintf("%s: short read for %v (expected 32 "+
+			"bytes, read %d)", bucketReplacements, replaced, len(v))
+		return nil, storeError(ErrData, str, nil)
+	}
+
+	var replacement chainhash.Hash
+	copy(replacement[:], v)
+
+	return &replacement, nil
+}
+
+// putTxComment sets the comment of a transaction. The comments bucket maps
+// the hash of each commented transaction to its comment:
+//
+//	[0:len] Comment
+func putTxComment(ns walletdb.ReadWriteBucket, txHash *chainhash.Hash,
+	comment string) error {
+
+	// Create the corresponding bucket if necessary.
+	comments, err := ns.CreateBucketIfNotExists(bucketTxComments)
+	if err != nil {
+		str := "failed to create comments bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	if err := comments.Put(txHash[:], []byte(comment)); err != nil {
+		str := fmt.Sprintf("%s: put failed for %v", bucketTxComments,
+			txHash)
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// fetchTxComment returns the comment of a transaction, or an empty string if
+// it has none.
+func fetchTxComment(ns walletdb.ReadBucket, txHash *chainhash.Hash) string {
+	// The bucket may not exist, indicating that no transactions have ever
+	// been commented.
+	comments := ns.NestedReadBucket(bucketTxComments)
+	if comments == nil {
+		return ""
+	}
+
+	return string(comments.Get(txHash[:]))
+}
+
+// deleteTxComment removes the comment of a transaction, if it has one.
+func deleteTxComment(ns walletdb.ReadWriteBucket,
+	txHash *chainhash.Hash) error {
+
+	comments := ns.NestedReadWriteBucket(bucketTxComments)
+	if comments == nil {
+		return nil
+	}
+
+	if err := comments.Delete(txHash[:]); err != nil {
+		str := fmt.Sprintf("%s: delete failed for %v",
+			bucketTxComments, txHash)
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// putOutputLabel sets the label of a transaction output. The output labels
+// bucket maps the outpoint of each labeled output to its label, such that the
+// labels of a transaction's outputs can be found through a prefix scan of its
+// hash:
+//
+//	[0:32]  Transaction hash (32 bytes)
+//	[32:36] Output index (4 bytes)
+//
+// The label itself is stored as is:
+//
+//	[0:len] Label
+func putOutputLabel(ns walletdb.ReadWriteBucket, op *wire.OutPoint,
+	label string) error {
+
+	// Create the corresponding bucket if necessary.
+	labels, err := ns.CreateBucketIfNotExists(bucketOutputLabels)
+	if err != nil {
+		str := "failed to create output labels bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	k := canonicalOutPoint(&op.Hash, op.Index)
+	if err := labels.Put(k, []byte(label)); err != nil {
+		str := fmt.Sprintf("%s: put failed for %v", bucketOutputLabels,
+			op)
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// fetchOutputLabel returns the label of a transaction output, or an empty
+// string if it has none.
+func fetchOutputLabel(ns walletdb.ReadBucket, op *wire.OutPoint) string {
+	// The bucket may not exist, indicating that no outputs have ever been
+	// labeled.
+	labels := ns.NestedReadBucket(bucketOutputLabels)
+	if labels == nil {
+		return ""
+	}
+
+	return string(labels.Get(canonicalOutPoint(&op.Hash, op.Index)))
+}
+
+// deleteOutputLabel removes the label of a transaction output, if it has one.
+func deleteOutputLabel(ns walletdb.ReadWriteBucket, op *wire.OutPoint) error {
+	labels := ns.NestedReadWriteBucket(bucketOutputLabels)
+	if labels == nil {
+		return nil
+	}
+
+	k := canonicalOutPoint(&op.Hash, op.Index)
+	if err := labels.Delete(k); err != nil {
+		str := fmt.Sprintf("%s: delete failed for %v",
+			bucketOutputLabels, op)
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// deleteTxOutputLabels removes the labels of every output of a transaction.
+func deleteTxOutputLabels(ns walletdb.ReadWriteBucket,
+	txHash *chainhash.Hash) error {
+
+	labels := ns.NestedReadWriteBucket(bucketOutputLabels)
+	if labels == nil {
+		return nil
+	}
+
+	// The keys are collected before being deleted, as the bucket can't be
+	// modified while iterating over it.
+	var keys [][]byte
+	prefix := txHash[:]
+	c := labels.ReadCursor()
+	k, _ := c.Seek(prefix)
+	for bytes.HasPrefix(k, prefix) {
+		keys = append(keys, append([]byte(nil), k...))
+		k, _ = c.Next()
+	}
+
+	for _, k := range keys {
+		if err := labels.Delete(k); err != nil {
+			str := fmt.Sprintf("%s: delete failed for %v",
+				bucketOutputLabels, txHash)
+			return storeError(ErrDatabase, str, err)
+		}
+	}
+
+	return nil
+}
+
+// The script transactions bucket indexes the transactions crediting or
+// debiting each output script of the wallet's credits.  Keys are the SHA-256
+// hash of the output script followed by the transaction hash, such that the
+// transactions of a script can be found through a prefix scan, and values are
+// empty:
+//
+//	[0:32]  Output script SHA-256 hash (32 bytes)
+//	[32:64] Transaction hash (32 bytes)
+//
+// Entries aren't removed along with the transactions they refer to, so
+// transactions no longer in the store must be ignored when reading them.
+
+func keyScriptTx(pkScript []byte, txHash *chainhash.Hash) []byte {
+	k := make([]byte, 64)
+	scriptHash := chainhash.HashH(pkScript)
+	copy(k, scriptHash[:])
+	copy(k[32:64], txHash[:])
+	return k
+}
+
+// putScriptTx records that the transaction credits or debits the output
+// script.
+func putScriptTx(ns walletdb.ReadWriteBucket, pkScript []byte,
+	txHash *chainhash.Hash) error {
+
+	// Create the corresponding bucket if necessary.
+	scriptTxs, err := ns.CreateBucketIfNotExists(bucketScriptTxs)
+	if err != nil {
+		str := "failed to create script transactions bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	k := keyScriptTx(pkScript, txHash)
+	if err := scriptTxs.Put(k, nil); err != nil {
+		str := fmt.Sprintf("%s: put failed for %v", bucketScriptTxs,
+			txHash)
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// fetchScriptTxHashes returns the hashes of the transactions recorded as
+// crediting or debiting the output script.
+func fetchScriptTxHashes(ns walletdb.ReadBucket,
+	pkScript []byte) []chainhash.Hash {
+
+	// The bucket may not exist, indicating that no transactions have ever
+	// been recorded.
+	scriptTxs := ns.NestedReadBucket(bucketScriptTxs)
+	if scriptTxs == nil {
+		return nil
+	}
+
+	scriptHash := chainhash.HashH(pkScript)
+	prefix := scriptHash[:]
+
+	var txHashes []chainhash.Hash
+	c := scriptTxs.ReadCursor()
+	ck, _ := c.Seek(prefix)
+	for ; bytes.HasPrefix(ck, prefix); ck, _ = c.Next() {
+		var txHash chainhash.Hash
+		copy(txHash[:], ck[32:])
+		txHashes = append(txHashes, txHash)
+	}
+
+	return txHashes
+}
+
+// fetchRawCreditPkScript returns the output script of the credit with the
+// given key.
+func fetchRawCreditPkScript(ns walletdb.ReadBucket, credKey []byte) ([]byte,
+	error) {
+
+	recKey := extractRawCreditTxRecordKey(credKey)
+	recVal := existsRawTxRecord(ns, recKey)
+	if recVal == nil {
+		str := "missing transaction record for credit"
+		return nil, storeError(ErrData, str, nil)
+	}
+
+	return fetchRawTxRecordPkScript(
+		recKey, recVal, extractRawCreditIndex(credKey),
+	)
+}
+
+// fetchCreditPkScript returns the output script of the output if it's an
+// unspent credit, either mined or unmined, and nil otherwise.
+func fetchCreditPkScript(ns walletdb.ReadBucket, op *wire.OutPoint) ([]byte,
+	error) {
+
+	k := canonicalOutPoint(&op.Hash, op.Index)
+	if credKey := existsRawUnspent(ns, k); credKey != nil {
+		return fetchRawCreditPkScript(ns, credKey)
+	}
+	if existsRawUnminedCredit(ns, k) == nil {
+		return nil, nil
+	}
+
+	recVal := existsRawUnmined(ns, op.Hash[:])
+	if recVal == nil {
+		str := "missing unmined transaction record for credit"
+		return nil, storeError(ErrData, str, nil)
+	}
+	return fetchRawTxRecordPkScript(op.Hash[:], recVal, op.Index)
+}
+
+// The selection denylist bucket maps the key of each denylisted outpoint or
+// output script to a placeholder value. Keys are prefixed by their type:
+//
+//	[0]     Key type (1 byte), denylistOutPoint or denylistPkScript
+//	[1:]    Canonical outpoint (36 bytes) or output script
+//
+// Like the frozen outputs bucket, it's not deleted along with the transaction
+// history, and entries are only removed explicitly.
+const (
+	denylistOutPoint byte = iota
+	denylistPkScript
+)
+
+var denylistValue = []byte{0}
+
+// keyDenylistOutPoint returns the selection denylist key of an outpoint.
+func keyDenylistOutPoint(op *wire.OutPoint) []byte {
+	k := make([]byte, 37)
+	k[0] = denylistOutPoint
+	copy(k[1:], canonicalOutPoint(&op.Hash, op.Index))
+	return k
+}
+
+// keyDenylistPkScript returns the selection denylist key of an output script.
+func keyDenylistPkScript(pkScript []byte) []byte {
+	k := make([]byte, 1+len(pkScript))
+	k[0] = denylistPkScript
+	copy(k[1:], pkScript)
+	return k
+}
+
+// existsDenylistKey determines whether a key is in the selection denylist.
+func existsDenylistKey(ns walletdb.ReadBucket, k []byte) bool {
+	// The bucket may not exist, indicating that nothing has ever been
+	// denylisted.
+	denylist := ns.NestedReadBucket(bucketDenylist)
+	if denylist == nil {
+		return false
+	}
+
+	return denylist.Get(k) != nil
+}
+
+// putDenylistKey adds a key to the selection denylist.
+func putDenylistKey(ns walletdb.ReadWriteBucket, k []byte) error {
+	// Create the corresponding bucket if necessary.
+	denylist, err := ns.CreateBucketIfNotExists(bucketDenylist)
+	if err != nil {
+		str := "failed to create selection denylist bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	if err := denylist.Put(k, denylistValue); err != nil {
+		str := fmt.Sprintf("%s: put failed", bucketDenylist)
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// deleteDenylistKey removes a key from the selection denylist.
+func deleteDenylistKey(ns walletdb.ReadWriteBucket, k []byte) error {
+	// The bucket may not exist, indicating that nothing has ever been
+	// denylisted, so we can just return now.
+	denylist := ns.NestedReadWriteBucket(bucketDenylist)
+	if denylist == nil {
+		return nil
+	}
+
+	if err := denylist.Delete(k); err != nil {
+		str := fmt.Sprintf("%s: delete failed", bucketDenylist)
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
+// forEachDenylistEntry iterates over the selection denylist and invokes the
+// callback `f` for each of its entries.
+func forEachDenylistEntry(ns walletdb.ReadBucket,
+	f func(DenylistEntry)) error {
+
+	// The bucket may not exist, indicating that nothing has ever been
+	// denylisted, so we can just return now.
+	denylist := ns.NestedReadBucket(bucketDenylist)
+	if denylist == nil {
+		return nil
+	}
+
+	return denylist.ForEach(func(k, _ []byte) error {
+		if len(k) == 0 {
+			str := "empty selection denylist key"
+			return storeError(ErrData, str, nil)
+		}
+
+		var entry DenylistEntry
+		switch k[0] {
+		case denylistOutPoint:
+			var op wire.OutPoint
+			err := readCanonicalOutPoint(k[1:], &op)
+			if err != nil {
+				return err
+			}
+			entry.OutPoint = &op
+
+		case denylistPkScript:
+			entry.PkScript = append([]byte(nil), k[1:]...)
+
+		default:
+			str := fmt.Sprintf("unknown selection denylist key "+
+				"type %d", k[0])
+			return storeError(ErrData, str, nil)
+		}
+
+		f(entry)
+
+		return nil
+	})
+}
+
 // openStore opens an existing transaction store from the passed namespace.
 func openStore(ns walletdb.ReadBucket) error {
 	version, err := fetchVersion(ns)
@@ -1548,6 +2265,11 @@ func deleteBuckets(ns walletdb.ReadWriteBucket) error {
 		str := "failed to delete locked outputs bucket"
 		return storeError(ErrDatabase, str, err)
 	}
+	err = ns.DeleteNestedBucket(bucketScriptTxs)
+	if err != nil && err != walletdb.ErrBucketNotFound {
+		str := "failed to delete script transactions bucket"
+		return storeError(ErrDatabase, str, err)
+	}
 
 	return nil
 }
@@ -1,6 +1,7 @@
 package wtxmgr
 
 import (
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcwallet/walletdb"
 	"github.com/btcsuite/btcwallet/walletdb/migration"
 )
@@ -18,6 +19,10 @@ var versions = []migration.Version{
 		Number:    2,
 		Migration: dropTransactionHistory,
 	},
+	{
+		Number:    3,
+		Migration: indexScriptTransactions,
+	},
 }
 
 // getLatestVersion returns the version number of the latest database version.
@@ -108,3 +113,120 @@ func dropTransactionHistory(ns walletdb.ReadWriteBucket) error {
 	// Finally, we'll insert a 0 value for our mined balance.
 	return putMinedBalance(ns, 0)
 }
+
+// indexScriptTransactions is a migration that indexes the transactions already
+// in the store crediting or debiting each output script of the wallet's
+// credits.
+func indexScriptTransactions(ns walletdb.ReadWriteBucket) error {
+	log.Info("Indexing wallet transactions by output script")
+
+	// A script transaction entry to be recorded.
+	type scriptTx struct {
+		pkScript []byte
+		txHash   chainhash.Hash
+	}
+	var entries []scriptTx
+
+	// We'll start with the mined transactions, for which both credits and
+	// debits are recorded.
+	txRecords := ns.NestedReadBucket(bucketTxRecords)
+	err := txRecords.ForEach(func(k, v []byte) error {
+		var (
+			rec   TxRecord
+			block Block
+		)
+		copy(rec.Hash[:], k)
+		if err := readRawTxRecord(&rec.Hash, v, &rec); err != nil {
+			return err
+		}
+		if err := readRawTxRecordBlock(k, &block); err != nil {
+			return err
+		}
+
+		for i, output := range rec.MsgTx.TxOut {
+			_, v := existsCredit(ns, &rec.Hash, uint32(i), &block)
+			if v == nil {
+				continue
+			}
+			entries = append(entries, scriptTx{
+				pkScript: output.PkScript,
+				txHash:   rec.Hash,
+			})
+		}
+		for i := range rec.MsgTx.TxIn {
+			_, credKey, err := existsDebit(
+				ns, &rec.Hash, uint32(i), &block,
+			)
+			if err != nil {
+				return err
+			}
+			if credKey == nil {
+				continue
+			}
+			pkScript, err := fetchRawCreditPkScript(ns, credKey)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, scriptTx{
+				pkScript: pkScript,
+				txHash:   rec.Hash,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Then, we'll index the unmined transactions, for which the spent
+	// credits are determined through the outputs they spend.
+	unmined := ns.NestedReadBucket(bucketUnmined)
+	err = unmined.ForEach(func(k, v []byte) error {
+		var rec TxRecord
+		copy(rec.Hash[:], k)
+		if err := readRawTxRecord(&rec.Hash, v, &rec); err != nil {
+			return err
+		}
+
+		for i, output := range rec.MsgTx.TxOut {
+			k := canonicalOutPoint(&rec.Hash, uint32(i))
+			if existsRawUnminedCredit(ns, k) == nil {
+				continue
+			}
+			entries = append(entries, scriptTx{
+				pkScript: output.PkScript,
+				txHash:   rec.Hash,
+			})
+		}
+		for _, input := range rec.MsgTx.TxIn {
+			pkScript, err := fetchCreditPkScript(
+				ns, &input.PreviousOutPoint,
+			)
+			if err != nil {
+				return err
+			}
+			if pkScript == nil {
+				continue
+			}
+			entries = append(entries, scriptTx{
+				pkScript: pkScript,
+				txHash:   rec.Hash,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		err := putScriptTx(ns, entry.pkScript, &entry.txHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcwallet/walletdb"
 )
 
@@ -185,3 +186,32 @@ func TestMigrationDropTransactionHistory(t *testing.T) {
 		false,
 	)
 }
+
+// TestMigrationIndexScriptTransactions ensures that the transactions already
+// in the store are indexed by output script.
+func TestMigrationIndexScriptTransactions(t *testing.T) {
+	t.Parallel()
+
+	script := []byte("address output script")
+	var expected []chainhash.Hash
+
+	beforeMigration := func(ns walletdb.ReadWriteBucket, s *Store) error {
+		var err error
+		expected, err = insertAddressHistory(s, ns, script)
+		if err != nil {
+			return err
+		}
+
+		// Drop the index to mimic a store created before it existed.
+		return ns.DeleteNestedBucket(bucketScriptTxs)
+	}
+
+	afterMigration := func(ns walletdb.ReadWriteBucket, s *Store) error {
+		return assertAddressHistory(s, ns, script, expected)
+	}
+
+	applyMigration(
+		t, beforeMigration, afterMigration, indexScriptTransactions,
+		false,
+	)
+}
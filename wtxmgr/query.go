@@ -7,8 +7,10 @@ package wtxmgr
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcwallet/walletdb"
 )
@@ -21,11 +23,23 @@ type CreditRecord struct {
 	Index  uint32
 	Spent  bool
 	Change bool
+
+	// SelfTransfer indicates whether the credit is an output of a
+	// transaction spending the wallet's own outputs, paying to one of its
+	// non-change addresses.
+	SelfTransfer bool
+
+	// NonStandard indicates whether the credited output's script isn't of
+	// a standard type paying to a single address, such as a bare multisig
+	// script.
+	NonStandard bool
 }
 
 // DebitRecord contains metadata regarding a transaction debit for a known
 // transaction.  Further details may be looked up by indexing a wire.MsgTx.TxIn
-// with the Index field.
+// with the Index field.  As transactions are stored in their witness
+// serialization, this includes the signature script and witness of the spent
+// input, allowing the exact size of the transaction to be computed.
 type DebitRecord struct {
 	Amount btcutil.Amount
 	Index  uint32
@@ -40,6 +54,7 @@ type TxDetails struct {
 	Credits []CreditRecord
 	Debits  []DebitRecord
 	Label   string
+	Comment string
 }
 
 // minedTxDetails fetches the TxDetails for the mined transaction with hash
@@ -76,6 +91,9 @@ func (s *Store) minedTxDetails(ns walletdb.ReadBucket, txHash *chainhash.Hash, r
 			spent := existsRawUnminedInput(ns, k) != nil
 			credIter.elem.Spent = spent
 		}
+		credIter.elem.NonStandard = isNonStandardScript(
+			details.MsgTx.TxOut[credIter.elem.Index].PkScript,
+		)
 		details.Credits = append(details.Credits, credIter.elem)
 	}
 	if credIter.err != nil {
@@ -95,11 +113,12 @@ func (s *Store) minedTxDetails(ns walletdb.ReadBucket, txHash *chainhash.Hash, r
 		return nil, debIter.err
 	}
 
-	// Finally, we add the transaction label to details.
+	// Finally, we add the transaction label and comment to details.
 	details.Label, err = s.TxLabel(ns, *txHash)
 	if err != nil {
 		return nil, err
 	}
+	details.Comment = s.TxComment(ns, *txHash)
 
 	return &details, nil
 }
@@ -124,6 +143,9 @@ func (s *Store) unminedTxDetails(ns walletdb.ReadBucket, txHash *chainhash.Hash,
 
 		// Set the Spent field since this is not done by the iterator.
 		it.elem.Spent = existsRawUnminedInput(ns, it.ck) != nil
+		it.elem.NonStandard = isNonStandardScript(
+			details.MsgTx.TxOut[it.elem.Index].PkScript,
+		)
 		details.Credits = append(details.Credits, it.elem)
 	}
 	if it.err != nil {
@@ -169,11 +191,12 @@ func (s *Store) unminedTxDetails(ns walletdb.ReadBucket, txHash *chainhash.Hash,
 		})
 	}
 
-	// Finally, we add the transaction label to details.
+	// Finally, we add the transaction label and comment to details.
 	details.Label, err = s.TxLabel(ns, *txHash)
 	if err != nil {
 		return nil, err
 	}
+	details.Comment = s.TxComment(ns, *txHash)
 
 	return &details, nil
 }
@@ -250,6 +273,119 @@ func (s *Store) UniqueTxDetails(ns walletdb.ReadBucket, txHash *chainhash.Hash,
 	return s.minedTxDetails(ns, txHash, k, v)
 }
 
+// SpendingTx returns the details of the transaction spending the credit with
+// the given outpoint. The spender of a credit spent by a mined transaction is
+// recorded along with the credit, while unmined spenders are looked up through
+// the unmined inputs index, so blocks reorged out are reflected once rolled
+// back. If the credit is spent by several unmined transactions, the one
+// recorded first is returned.
+//
+// If the credit is unspent, ErrOutputUnspent is returned. If the output isn't
+// a credit, ErrUnknownOutput is returned.
+func (s *Store) SpendingTx(ns walletdb.ReadBucket,
+	op wire.OutPoint) (*TxDetails, error) {
+
+	// The credits of mined transactions are keyed by the transaction's
+	// record, whose most recent one is looked up.
+	var isCredit bool
+	recKey, _ := latestTxRecord(ns, &op.Hash)
+	if recKey != nil {
+		credKey := make([]byte, 72)
+		copy(credKey, recKey)
+		byteOrder.PutUint32(credKey[68:72], op.Index)
+
+		if v := existsRawCredit(ns, credKey); v != nil {
+			if spender := fetchRawCreditSpender(v); spender != nil {
+				return s.UniqueTxDetails(
+					ns, &spender.txHash, &spender.block,
+				)
+			}
+			isCredit = true
+		}
+	}
+
+	opKey := canonicalOutPoint(&op.Hash, op.Index)
+	if !isCredit && existsRawUnminedCredit(ns, opKey) == nil {
+		return nil, ErrUnknownOutput
+	}
+
+	spendTxHashes := fetchUnminedInputSpendTxHashes(ns, opKey)
+	if len(spendTxHashes) == 0 {
+		return nil, ErrOutputUnspent
+	}
+	return s.UniqueTxDetails(ns, &spendTxHashes[0], nil)
+}
+
+// TxsForAddress returns the details of all transactions with an output paying
+// to the output script that is a credit, or with an input spending such a
+// credit, in chain order.  Unmined transactions, flagged by a block height of
+// -1, follow the mined ones in the order in which they were received.
+func (s *Store) TxsForAddress(ns walletdb.ReadBucket,
+	script []byte) ([]TxDetails, error) {
+
+	var (
+		txs       []TxDetails
+		positions = make(map[chainhash.Hash]int)
+		blocks    = make(map[int32]*blockRecord)
+	)
+	for _, txHash := range fetchScriptTxHashes(ns, script) {
+		txHash := txHash
+		details, err := s.TxDetails(ns, &txHash)
+		if err != nil {
+			return nil, err
+		}
+
+		// The index isn't pruned along with the transactions removed
+		// from the store, so they're skipped.
+		if details == nil {
+			continue
+		}
+		txs = append(txs, *details)
+
+		height := details.Block.Height
+		if height == -1 {
+			continue
+		}
+
+		// Transactions within the same block are ordered by their
+		// position in its block record.
+		block, ok := blocks[height]
+		if !ok {
+			k, v := existsBlockRecord(ns, height)
+			block = new(blockRecord)
+			if err := readRawBlockRecord(k, v, block); err != nil {
+				return nil, err
+			}
+			blocks[height] = block
+		}
+		for i, blockTxHash := range block.transactions {
+			if blockTxHash == txHash {
+				positions[txHash] = i
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(txs, func(i, j int) bool {
+		a, b := &txs[i], &txs[j]
+		switch {
+		case a.Block.Height == -1 || b.Block.Height == -1:
+			if a.Block.Height != b.Block.Height {
+				return b.Block.Height == -1
+			}
+			return a.Received.Before(b.Received)
+
+		case a.Block.Height != b.Block.Height:
+			return a.Block.Height < b.Block.Height
+
+		default:
+			return positions[a.Hash] < positions[b.Hash]
+		}
+	})
+
+	return txs, nil
+}
+
 // rangeUnminedTransactions executes the function f with TxDetails for every
 // unmined transaction.  f is not executed if no unmined transactions exist.
 // Error returns from f (if any) are propigated to the caller.  Returns true
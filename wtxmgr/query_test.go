@@ -289,14 +289,16 @@ func TestStoreQueries(t *testing.T) {
 		state: newState,
 	})
 
-	// Add txA:0 as a change credit.
+	// Add txA:0 as a change credit. As the outputs of the test
+	// transactions have empty scripts, their credits are non-standard.
 	newState = lastState.deepCopy()
 	newState.blocks[0][0].Credits = []CreditRecord{
 		{
-			Index:  0,
-			Amount: btcutil.Amount(recA.MsgTx.TxOut[0].Value),
-			Spent:  false,
-			Change: true,
+			Index:       0,
+			Amount:      btcutil.Amount(recA.MsgTx.TxOut[0].Value),
+			Spent:       false,
+			Change:      true,
+			NonStandard: true,
 		},
 	}
 	newState.txDetails[recA.Hash][0].Credits = newState.blocks[0][0].Credits
@@ -341,10 +343,11 @@ func TestStoreQueries(t *testing.T) {
 	newState = lastState.deepCopy()
 	newState.blocks[0][1].Credits = []CreditRecord{
 		{
-			Index:  0,
-			Amount: btcutil.Amount(recB.MsgTx.TxOut[0].Value),
-			Spent:  false,
-			Change: false,
+			Index:       0,
+			Amount:      btcutil.Amount(recB.MsgTx.TxOut[0].Value),
+			Spent:       false,
+			Change:      false,
+			NonStandard: true,
 		},
 	}
 	newState.txDetails[recB.Hash][0].Credits = newState.blocks[0][1].Credits
@@ -736,3 +739,275 @@ func TestPreviousPkScripts(t *testing.T) {
 		t.Fatal("Failed after inserting tx D")
 	}
 }
+
+// insertAddressHistory inserts transactions crediting and debiting the given
+// output script into the store, along with an unrelated one, returning the
+// hashes of the relevant ones in chain order. The last of them is unmined.
+func insertAddressHistory(s *Store, ns walletdb.ReadWriteBucket,
+	script []byte) ([]chainhash.Hash, error) {
+
+	otherScript := []byte("other output script")
+	newTx := func(prevOut wire.OutPoint, scripts ...[]byte) *wire.MsgTx {
+		tx := &wire.MsgTx{
+			TxIn: []*wire.TxIn{{PreviousOutPoint: prevOut}},
+		}
+		for _, pkScript := range scripts {
+			tx.TxOut = append(tx.TxOut, &wire.TxOut{
+				Value:    1e6,
+				PkScript: pkScript,
+			})
+		}
+		return tx
+	}
+	insert := func(tx *wire.MsgTx, height int32,
+		credits ...uint32) (*chainhash.Hash, error) {
+
+		rec, err := NewTxRecordFromMsgTx(tx, timeNow())
+		if err != nil {
+			return nil, err
+		}
+		var block *BlockMeta
+		if height != -1 {
+			meta := makeBlockMeta(height)
+			block = &meta
+		}
+		if err := s.InsertTx(ns, rec, block); err != nil {
+			return nil, err
+		}
+		for _, idx := range credits {
+			err := s.AddCredit(ns, rec, block, idx, false)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &rec.Hash, nil
+	}
+
+	// Two transactions credit the script, the second one within the same
+	// block as an unrelated transaction.
+	creditA, err := insert(newTx(wire.OutPoint{}, otherScript, script),
+		100, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	_, err = insert(newTx(wire.OutPoint{Index: 1}, otherScript), 101, 0)
+	if err != nil {
+		return nil, err
+	}
+	creditB, err := insert(newTx(wire.OutPoint{Index: 2}, script), 101, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first credit is spent in a block, paying to another script of
+	// the wallet, and the second one is spent by an unmined transaction.
+	spendA, err := insert(
+		newTx(wire.OutPoint{Hash: *creditA, Index: 1}, otherScript),
+		102, 0,
+	)
+	if err != nil {
+		return nil, err
+	}
+	spendB, err := insert(
+		newTx(wire.OutPoint{Hash: *creditB}, otherScript), -1,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return []chainhash.Hash{*creditA, *creditB, *spendA, *spendB}, nil
+}
+
+// assertAddressHistory asserts that the transactions returned for the script
+// are the expected ones, in order, with the last one unmined.
+func assertAddressHistory(s *Store, ns walletdb.ReadBucket, script []byte,
+	expected []chainhash.Hash) error {
+
+	txs, err := s.TxsForAddress(ns, script)
+	if err != nil {
+		return err
+	}
+	if len(txs) != len(expected) {
+		return fmt.Errorf("expected %d transactions, got %d",
+			len(expected), len(txs))
+	}
+	for i, tx := range txs {
+		if tx.Hash != expected[i] {
+			return fmt.Errorf("expected transaction %d to be %v, "+
+				"got %v", i, expected[i], tx.Hash)
+		}
+		unmined := tx.Block.Height == -1
+		if unmined != (i == len(txs)-1) {
+			return fmt.Errorf("unexpected height %d of "+
+				"transaction %d", tx.Block.Height, i)
+		}
+	}
+
+	return nil
+}
+
+// TestTxsForAddress ensures that all transactions crediting an output script,
+// or debiting its credits, are returned in chain order.
+func TestTxsForAddress(t *testing.T) {
+	t.Parallel()
+
+	s, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	script := []byte("address output script")
+	commitDBTx(t, s, db, func(ns walletdb.ReadWriteBucket) {
+		txs, err := s.TxsForAddress(ns, script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(txs) != 0 {
+			t.Fatalf("expected no transactions, got %d", len(txs))
+		}
+
+		expected, err := insertAddressHistory(s, ns, script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = assertAddressHistory(s, ns, script, expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Once the unmined spend is removed, it shouldn't be returned
+		// anymore.
+		spend, err := s.TxDetails(ns, &expected[len(expected)-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RemoveUnminedTx(ns, &spend.TxRecord); err != nil {
+			t.Fatal(err)
+		}
+		txs, err = s.TxsForAddress(ns, script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(txs) != len(expected)-1 {
+			t.Fatalf("expected %d transactions, got %d",
+				len(expected)-1, len(txs))
+		}
+	})
+}
+
+// TestTxDetailsInputScripts ensures that the signature scripts and witnesses
+// of the wallet's spent inputs are retained by the store, such that the
+// signed transaction can be reconstructed byte for byte from them, whether it
+// is mined or not.
+func TestTxDetailsInputScripts(t *testing.T) {
+	t.Parallel()
+
+	s, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	// Fund the wallet with two outputs, spent by a transaction with a
+	// witness for one input, and a signature script for the other.
+	block := makeBlockMeta(100)
+	fundingTx := newCoinBase(1e8, 2e8)
+	insertConfirmedCredit(t, s, db, fundingTx, 0, &block)
+	insertConfirmedCredit(t, s, db, fundingTx, 1, &block)
+
+	fundingHash := fundingTx.TxHash()
+	spendTx := spendOutputs([]wire.OutPoint{
+		{Hash: fundingHash, Index: 0},
+		{Hash: fundingHash, Index: 1},
+	}, 2e8)
+	spendTx.TxIn[0].Witness = wire.TxWitness{
+		bytes.Repeat([]byte{0x30}, 71), bytes.Repeat([]byte{0x02}, 33),
+	}
+	spendTx.TxIn[1].SignatureScript = bytes.Repeat([]byte{0x16}, 23)
+
+	var serialized bytes.Buffer
+	if err := spendTx.Serialize(&serialized); err != nil {
+		t.Fatal(err)
+	}
+
+	// reconstruct rebuilds the transaction from the inputs of the wallet
+	// referenced by the debits of its details.
+	reconstruct := func(details *TxDetails) []byte {
+		t.Helper()
+
+		if len(details.Debits) != len(spendTx.TxIn) {
+			t.Fatalf("expected %d debits, got %d",
+				len(spendTx.TxIn), len(details.Debits))
+		}
+
+		tx := spendTx.Copy()
+		for _, txIn := range tx.TxIn {
+			txIn.SignatureScript = nil
+			txIn.Witness = nil
+		}
+		for _, debit := range details.Debits {
+			storedIn := details.MsgTx.TxIn[debit.Index]
+			tx.TxIn[debit.Index].SignatureScript =
+				storedIn.SignatureScript
+			tx.TxIn[debit.Index].Witness = storedIn.Witness
+		}
+
+		var buf bytes.Buffer
+		if err := tx.Serialize(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	spendHash := spendTx.TxHash()
+	checkDetails := func() {
+		t.Helper()
+
+		commitDBTx(t, s, db, func(ns walletdb.ReadWriteBucket) {
+			details, err := s.TxDetails(ns, &spendHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if details == nil {
+				t.Fatal("spending transaction not found")
+			}
+			var stored bytes.Buffer
+			if err := details.MsgTx.Serialize(&stored); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(stored.Bytes(), serialized.Bytes()) {
+				t.Fatal("stored transaction doesn't match")
+			}
+			rebuilt := reconstruct(details)
+			if !bytes.Equal(rebuilt, serialized.Bytes()) {
+				t.Fatal("reconstructed transaction doesn't " +
+					"match")
+			}
+		})
+	}
+
+	commitDBTx(t, s, db, func(ns walletdb.ReadWriteBucket) {
+		rec, err := NewTxRecordFromMsgTx(spendTx, timeNow())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.InsertTx(ns, rec, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	checkDetails()
+
+	// The input scripts should be retained once the spend confirms.
+	spendBlock := makeBlockMeta(101)
+	commitDBTx(t, s, db, func(ns walletdb.ReadWriteBucket) {
+		rec, err := NewTxRecordFromMsgTx(spendTx, timeNow())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.InsertTx(ns, rec, &spendBlock); err != nil {
+			t.Fatal(err)
+		}
+	})
+	checkDetails()
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcwallet/walletdb"
@@ -24,6 +25,10 @@ import (
 const (
 	// TxLabelLimit is the length limit we impose on transaction labels.
 	TxLabelLimit = 500
+
+	// TxCommentLimit is the length limit we impose on transaction
+	// comments.
+	TxCommentLimit = 1000
 )
 
 var (
@@ -44,6 +49,10 @@ var (
 	// transaction hash.
 	ErrTxLabelNotFound = errors.New("label for transaction not found")
 
+	// ErrCommentTooLong is returned when an attempt to write a comment
+	// that is too long is made.
+	ErrCommentTooLong = errors.New("transaction comment exceeds limit")
+
 	// ErrUnknownOutput is an error returned when an output not known to the
 	// wallet is attempted to be locked.
 	ErrUnknownOutput = errors.New("unknown output")
@@ -59,6 +68,10 @@ var (
 	// ErrDuplicateTx is returned when attempting to record a mined or
 	// unmined transaction that is already recorded.
 	ErrDuplicateTx = errors.New("transaction already exists")
+
+	// ErrOutputUnspent is returned when looking up the transaction
+	// spending a credit that is unspent.
+	ErrOutputUnspent = errors.New("output unspent")
 )
 
 // Block contains the minimum amount of data to uniquely identify any block on
@@ -116,6 +129,10 @@ type credit struct {
 	amount   btcutil.Amount
 	change   bool
 	spentBy  indexedIncidence // Index == ^uint32(0) if unspent
+
+	// selfTransfer is whether the credit is a self-transfer output, as
+	// described by CreditKindSelfTransfer.
+	selfTransfer bool
 }
 
 // TxRecord represents a transaction managed by the Store.
@@ -180,6 +197,21 @@ type Credit struct {
 	PkScript     []byte
 	Received     time.Time
 	FromCoinBase bool
+
+	// NonStandard indicates whether PkScript isn't of a standard type
+	// paying to a single address, such as a bare multisig script.
+	NonStandard bool
+
+	// RelativeLock is the BIP 68 relative lock-time encumbering the
+	// output, encoded as the sequence number of the input spending it, as
+	// recorded through PutRelativeLock. It's zero if the output isn't
+	// encumbered by a relative lock-time.
+	RelativeLock uint32
+
+	// LockTime is the absolute lock-time encumbering the output, as
+	// recorded for the script it pays to through PutTimeLockedScript. It's
+	// zero if the output isn't encumbered by an absolute lock-time.
+	LockTime uint32
 }
 
 // LockID represents a unique context-specific ID assigned to an output lock.
@@ -262,8 +294,16 @@ func (s *Store) updateMinedBalance(ns walletdb.ReadWriteBucket, rec *TxRecord,
 			continue
 		}
 
-		// If this output is relevant to us, we'll mark the it as spent
-		// and remove its amount from the store.
+		// If this output is relevant to us, we'll index the spend
+		// under its output script, mark it as spent and remove its
+		// amount from the store.
+		pkScript, err := fetchRawCreditPkScript(ns, credKey)
+		if err != nil {
+			return err
+		}
+		if err := putScriptTx(ns, pkScript, &rec.Hash); err != nil {
+			return err
+		}
 		spender.index = uint32(i)
 		amt, err := spendCredit(ns, credKey, &spender)
 		if err != nil {
@@ -312,6 +352,7 @@ func (s *Store) updateMinedBalance(ns walletdb.ReadWriteBucket, rec *TxRecord,
 		cred.outPoint.Index = index
 		cred.amount = amount
 		cred.change = change
+		cred.selfTransfer = fetchRawCreditSelfTransfer(it.cv)
 
 		if err := putUnspentCredit(ns, &cred); err != nil {
 			return err
@@ -390,12 +431,23 @@ func (s *Store) InsertTxCheckIfExists(ns walletdb.ReadWriteBucket,
 // that we attempt to rebroadcast, turns out to double spend one of our
 // existing inputs. This function we remove the conflicting transaction
 // identified by the tx record, and also recursively remove all transactions
-// that depend on it.
+// that depend on it. The labels of their outputs are kept, as they may be
+// recorded again, e.g. once confirmed, while DiscardUnminedTx deletes them.
 func (s *Store) RemoveUnminedTx(ns walletdb.ReadWriteBucket, rec *TxRecord) error {
 	// As we already have a tx record, we can directly call the
 	// removeConflict method. This will do the job of recursively removing
 	// this unmined transaction, and any transactions that depend on it.
-	return s.removeConflict(ns, rec)
+	return s.removeConflict(ns, rec, false)
+}
+
+// DiscardUnminedTx removes an unmined transaction and all transactions that
+// depend on it from the transaction store like RemoveUnminedTx, along with the
+// labels of their outputs, as they're no longer expected to confirm, e.g. once
+// abandoned, replaced or rejected by the backend.
+func (s *Store) DiscardUnminedTx(ns walletdb.ReadWriteBucket,
+	rec *TxRecord) error {
+
+	return s.removeConflict(ns, rec, true)
 }
 
 // insertMinedTx inserts a new transaction record for a mined transaction into
@@ -459,10 +511,11 @@ func (s *Store) insertMinedTx(ns walletdb.ReadWriteBucket, rec *TxRecord,
 		return err
 	}
 
-	// Clear any locked outputs since we now have a confirmed spend for
-	// them, making them not eligible for coin selection anyway.
+	// Clear any locked or frozen outputs, along with their relative
+	// lock-times, since we now have a confirmed spend for them, making
+	// them not eligible for coin selection anyway.
 	for _, txIn := range rec.MsgTx.TxIn {
-		if err := unlockOutput(ns, txIn.PreviousOutPoint); err != nil {
+		if err := releaseOutput(ns, txIn.PreviousOutPoint); err != nil {
 			return err
 		}
 	}
@@ -470,6 +523,39 @@ func (s *Store) insertMinedTx(ns walletdb.ReadWriteBucket, rec *TxRecord,
 	return nil
 }
 
+// releaseOutput clears the lock, freeze and relative lock-time of an output
+// which can no longer be selected, either because it has a confirmed spend or
+// because it no longer exists.
+func releaseOutput(ns walletdb.ReadWriteBucket, op wire.OutPoint) error {
+	if err := unlockOutput(ns, op); err != nil {
+		return err
+	}
+	if err := unfreezeOutput(ns, op); err != nil {
+		return err
+	}
+	return deleteRelativeLock(ns, op)
+}
+
+// CreditKind classifies a credit by how it relates to the transaction it's an
+// output of.
+type CreditKind uint8
+
+const (
+	// CreditKindReceive is an output received by the wallet, paying to one
+	// of its non-change addresses.
+	CreditKindReceive CreditKind = iota
+
+	// CreditKindChange is an output paying to one of the wallet's change
+	// addresses.
+	CreditKindChange
+
+	// CreditKindSelfTransfer is an output of a transaction spending the
+	// wallet's own outputs, paying to one of its non-change addresses.
+	// Such an output moves funds within the wallet rather than receiving
+	// them.
+	CreditKindSelfTransfer
+)
+
 // AddCredit marks a transaction record as containing a transaction output
 // spendable by wallet.  The output is added unspent, and is marked spent
 // when a new transaction spending the output is inserted into the store.
@@ -478,12 +564,25 @@ func (s *Store) insertMinedTx(ns walletdb.ReadWriteBucket, rec *TxRecord,
 // that are known to contain credits when a transaction or merkleblock is
 // inserted into the store.
 func (s *Store) AddCredit(ns walletdb.ReadWriteBucket, rec *TxRecord, block *BlockMeta, index uint32, change bool) error {
+	kind := CreditKindReceive
+	if change {
+		kind = CreditKindChange
+	}
+	return s.AddCreditOfKind(ns, rec, block, index, kind)
+}
+
+// AddCreditOfKind marks a transaction record as containing a transaction
+// output spendable by wallet, of the given kind. Besides recording the kind of
+// the credit, it behaves exactly as AddCredit.
+func (s *Store) AddCreditOfKind(ns walletdb.ReadWriteBucket, rec *TxRecord,
+	block *BlockMeta, index uint32, kind CreditKind) error {
+
 	if int(index) >= len(rec.MsgTx.TxOut) {
 		str := "transaction output does not exist"
 		return storeError(ErrInput, str, nil)
 	}
 
-	isNew, err := s.addCredit(ns, rec, block, index, change)
+	isNew, err := s.addCredit(ns, rec, block, index, kind)
 	if err == nil && isNew && s.NotifyUnspent != nil {
 		s.NotifyUnspent(&rec.Hash, index)
 	}
@@ -493,7 +592,9 @@ func (s *Store) AddCredit(ns walletdb.ReadWriteBucket, rec *TxRecord, block *Blo
 // addCredit is an AddCredit helper that runs in an update transaction.  The
 // bool return specifies whether the unspent output is newly added (true) or a
 // duplicate (false).
-func (s *Store) addCredit(ns walletdb.ReadWriteBucket, rec *TxRecord, block *BlockMeta, index uint32, change bool) (bool, error) {
+func (s *Store) addCredit(ns walletdb.ReadWriteBucket, rec *TxRecord, block *BlockMeta, index uint32, kind CreditKind) (bool, error) {
+	change := kind == CreditKindChange
+	selfTransfer := kind == CreditKindSelfTransfer
 	if block == nil {
 		// If the outpoint that we should mark as credit already exists
 		// within the store, either as unconfirmed or confirmed, then we
@@ -507,8 +608,15 @@ func (s *Store) addCredit(ns walletdb.ReadWriteBucket, rec *TxRecord, block *Blo
 				rec.Hash.String())
 			return false, nil
 		}
-		v := valueUnminedCredit(btcutil.Amount(rec.MsgTx.TxOut[index].Value), change)
-		return true, putRawUnminedCredit(ns, k, v)
+		v := valueUnminedCredit(
+			btcutil.Amount(rec.MsgTx.TxOut[index].Value), change,
+			selfTransfer,
+		)
+		if err := putRawUnminedCredit(ns, k, v); err != nil {
+			return false, err
+		}
+		pkScript := rec.MsgTx.TxOut[index].PkScript
+		return true, putScriptTx(ns, pkScript, &rec.Hash)
 	}
 
 	k, v := existsCredit(ns, &rec.Hash, index, &block.Block)
@@ -525,16 +633,21 @@ func (s *Store) addCredit(ns walletdb.ReadWriteBucket, rec *TxRecord, block *Blo
 			Hash:  rec.Hash,
 			Index: index,
 		},
-		block:   block.Block,
-		amount:  txOutAmt,
-		change:  change,
-		spentBy: indexedIncidence{index: ^uint32(0)},
+		block:        block.Block,
+		amount:       txOutAmt,
+		change:       change,
+		spentBy:      indexedIncidence{index: ^uint32(0)},
+		selfTransfer: selfTransfer,
 	}
 	v = valueUnspentCredit(&cred)
 	err := putRawCredit(ns, k, v)
 	if err != nil {
 		return false, err
 	}
+	err = putScriptTx(ns, rec.MsgTx.TxOut[index].PkScript, &rec.Hash)
+	if err != nil {
+		return false, err
+	}
 
 	minedBalance, err := fetchMinedBalance(ns)
 	if err != nil {
@@ -601,7 +714,9 @@ func (s *Store) rollback(ns walletdb.ReadWriteBucket, height int32) error {
 			// Handle coinbase transactions specially since they are
 			// not moved to the unconfirmed store.  A coinbase cannot
 			// contain any debits, but all credits should be removed
-			// and the mined balance decremented.
+			// and the mined balance decremented.  As the outputs
+			// no longer exist, whether they matured or not, any
+			// lock or freeze over them is cleared as well.
 			if blockchain.IsCoinBaseTx(&rec.MsgTx) {
 				op := wire.OutPoint{Hash: rec.Hash}
 				for i, output := range rec.MsgTx.TxOut {
@@ -626,6 +741,10 @@ func (s *Store) rollback(ns walletdb.ReadWriteBucket, height int32) error {
 					if err != nil {
 						return err
 					}
+					err = releaseOutput(ns, op)
+					if err != nil {
+						return err
+					}
 				}
 
 				continue
@@ -715,7 +834,10 @@ func (s *Store) rollback(ns walletdb.ReadWriteBucket, height int32) error {
 					return err
 				}
 				outPointKey := canonicalOutPoint(&rec.Hash, uint32(i))
-				unminedCredVal := valueUnminedCredit(amt, change)
+				selfTransfer := fetchRawCreditSelfTransfer(v)
+				unminedCredVal := valueUnminedCredit(
+					amt, change, selfTransfer,
+				)
 				err = putRawUnminedCredit(ns, outPointKey, unminedCredVal)
 				if err != nil {
 					return err
@@ -784,7 +906,7 @@ func (s *Store) rollback(ns walletdb.ReadWriteBucket, height int32) error {
 
 			log.Debugf("Transaction %v spends a removed coinbase "+
 				"output -- removing as well", unminedRec.Hash)
-			err = s.removeConflict(ns, &unminedRec)
+			err = s.removeConflict(ns, &unminedRec, true)
 			if err != nil {
 				return err
 			}
@@ -847,7 +969,10 @@ func (s *Store) UnspentOutputs(ns walletdb.ReadBucket) ([]Credit, error) {
 			PkScript:     txOut.PkScript,
 			Received:     rec.Received,
 			FromCoinBase: blockchain.IsCoinBaseTx(&rec.MsgTx),
+			NonStandard:  isNonStandardScript(txOut.PkScript),
 		}
+		cred.RelativeLock, _ = fetchRelativeLock(ns, op)
+		_, cred.LockTime, _ = fetchTimeLockedScript(ns, txOut.PkScript)
 		unspent = append(unspent, cred)
 		return nil
 	})
@@ -896,7 +1021,10 @@ func (s *Store) UnspentOutputs(ns walletdb.ReadBucket) ([]Credit, error) {
 			PkScript:     txOut.PkScript,
 			Received:     rec.Received,
 			FromCoinBase: blockchain.IsCoinBaseTx(&rec.MsgTx),
+			NonStandard:  isNonStandardScript(txOut.PkScript),
 		}
+		cred.RelativeLock, _ = fetchRelativeLock(ns, op)
+		_, cred.LockTime, _ = fetchTimeLockedScript(ns, txOut.PkScript)
 		unspent = append(unspent, cred)
 		return nil
 	})
@@ -1142,6 +1270,21 @@ func FetchTxLabel(ns walletdb.ReadBucket, txid chainhash.Hash) (string, error) {
 	return DeserializeLabel(v)
 }
 
+// deleteTxLabel removes the label for a transaction, if one exists.
+func deleteTxLabel(ns walletdb.ReadWriteBucket, txid *chainhash.Hash) error {
+	labelBucket := ns.NestedReadWriteBucket(bucketTxLabels)
+	if labelBucket == nil {
+		return nil
+	}
+
+	if err := labelBucket.Delete(txid[:]); err != nil {
+		str := "failed to delete transaction label"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
 // DeserializeLabel reads a deserializes a length-value encoded label from the
 // byte array provided.
 func DeserializeLabel(v []byte) (string, error) {
@@ -1156,6 +1299,82 @@ func DeserializeLabel(v []byte) (string, error) {
 	return label, nil
 }
 
+// SetTxComment sets the free-text comment of a transaction known to the store,
+// replacing any existing one, or removes it if the comment is empty. Comments
+// are keyed by the transaction hash, so they persist as the transaction is
+// confirmed or reorged out, and are only removed along with the transaction
+// when it's purged through PurgeConflicted.
+func (s *Store) SetTxComment(ns walletdb.ReadWriteBucket,
+	txHash chainhash.Hash, comment string) error {
+
+	if len(comment) > TxCommentLimit {
+		return ErrCommentTooLong
+	}
+
+	if existsRawUnmined(ns, txHash[:]) == nil {
+		if k, _ := latestTxRecord(ns, &txHash); k == nil {
+			str := fmt.Sprintf("transaction %v not found", txHash)
+			return storeError(ErrNoExists, str, nil)
+		}
+	}
+
+	if comment == "" {
+		return deleteTxComment(ns, &txHash)
+	}
+
+	return putTxComment(ns, &txHash, comment)
+}
+
+// TxComment returns the comment of a transaction, or an empty string if it has
+// none.
+func (s *Store) TxComment(ns walletdb.ReadBucket,
+	txHash chainhash.Hash) string {
+
+	return fetchTxComment(ns, &txHash)
+}
+
+// SetOutputLabel sets the label of an output of a transaction known to the
+// store, replacing any existing one, or removes it if the label is empty. Like
+// comments, output labels persist as their transaction is confirmed or reorged
+// out, and are only removed along with the transaction when it's purged
+// through PurgeConflicted, discarded through DiscardUnminedTx or replaced by a
+// double spend, or through DeleteOutputLabels.
+func (s *Store) SetOutputLabel(ns walletdb.ReadWriteBucket, op wire.OutPoint,
+	label string) error {
+
+	if len(label) > TxLabelLimit {
+		return ErrLabelTooLong
+	}
+
+	if existsRawUnmined(ns, op.Hash[:]) == nil {
+		if k, _ := latestTxRecord(ns, &op.Hash); k == nil {
+			str := fmt.Sprintf("transaction %v not found", op.Hash)
+			return storeError(ErrNoExists, str, nil)
+		}
+	}
+
+	if label == "" {
+		return deleteOutputLabel(ns, &op)
+	}
+
+	return putOutputLabel(ns, &op, label)
+}
+
+// OutputLabel returns the label of a transaction output, or an empty string if
+// it has none.
+func (s *Store) OutputLabel(ns walletdb.ReadBucket, op wire.OutPoint) string {
+	return fetchOutputLabel(ns, &op)
+}
+
+// DeleteOutputLabels removes the labels of every output of the transaction,
+// such as once it's removed from the store through RemoveUnminedTx and won't
+// be recorded again.
+func (s *Store) DeleteOutputLabels(ns walletdb.ReadWriteBucket,
+	txHash chainhash.Hash) error {
+
+	return deleteTxOutputLabels(ns, &txHash)
+}
+
 // isKnownOutput returns whether the output is known to the transaction store
 // either as confirmed or unconfirmed.
 func isKnownOutput(ns walletdb.ReadWriteBucket, op wire.OutPoint) bool {
@@ -1228,6 +1447,223 @@ func (s *Store) UnlockOutput(ns walletdb.ReadWriteBucket, id LockID,
 	return unlockOutput(ns, op)
 }
 
+// FreezeOutput freezes an output, preventing it from being available for coin
+// selection until it's unfrozen through UnfreezeOutput. Unlike locks, freezes
+// don't expire, and frozen outputs are still returned by UnspentOutputs, as
+// they remain spendable when explicitly selected.
+//
+// If the output is not known, ErrUnknownOutput is returned.
+func (s *Store) FreezeOutput(ns walletdb.ReadWriteBucket,
+	op wire.OutPoint) error {
+
+	// Make sure the output is known.
+	if !isKnownOutput(ns, op) {
+		return ErrUnknownOutput
+	}
+
+	return freezeOutput(ns, op)
+}
+
+// UnfreezeOutput unfreezes an output, allowing it to be available for coin
+// selection if it remains unspent and unlocked. Unfreezing an output that
+// isn't frozen has no effect.
+func (s *Store) UnfreezeOutput(ns walletdb.ReadWriteBucket,
+	op wire.OutPoint) error {
+
+	return unfreezeOutput(ns, op)
+}
+
+// IsFrozenOutput returns whether an output has been frozen.
+func (s *Store) IsFrozenOutput(ns walletdb.ReadBucket, op wire.OutPoint) bool {
+	return isFrozenOutput(ns, op)
+}
+
+// ListFrozenOutputs returns the outpoints of all frozen outputs.
+func (s *Store) ListFrozenOutputs(ns walletdb.ReadBucket) ([]wire.OutPoint,
+	error) {
+
+	var outputs []wire.OutPoint
+	err := forEachFrozenOutput(ns, func(op wire.OutPoint) {
+		outputs = append(outputs, op)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return outputs, nil
+}
+
+// DenylistEntry is an entry of the selection denylist, which excludes outputs
+// from automatic coin selection. Exactly one of its fields must be set.
+type DenylistEntry struct {
+	// OutPoint is the outpoint of a single denylisted output.
+	OutPoint *wire.OutPoint
+
+	// PkScript is an output script all outputs paying to which are
+	// denylisted.
+	PkScript []byte
+}
+
+// key returns the selection denylist key of the entry.
+func (e *DenylistEntry) key() ([]byte, error) {
+	switch {
+	case e.OutPoint != nil && len(e.PkScript) != 0:
+		str := "denylist entry may not have both an outpoint and " +
+			"an output script"
+		return nil, storeError(ErrInput, str, nil)
+
+	case e.OutPoint != nil:
+		return keyDenylistOutPoint(e.OutPoint), nil
+
+	case len(e.PkScript) != 0:
+		return keyDenylistPkScript(e.PkScript), nil
+
+	default:
+		str := "denylist entry must have an outpoint or an output " +
+			"script"
+		return nil, storeError(ErrInput, str, nil)
+	}
+}
+
+// AddToSelectionDenylist adds an outpoint or output script to the selection
+// denylist, preventing the matching outputs from being available for coin
+// selection until it's removed through RemoveFromSelectionDenylist.
+// Denylisted outputs are still returned by UnspentOutputs, as they remain
+// spendable when explicitly selected.
+//
+// Unlike FreezeOutput, entries need not match any known output, such that
+// outputs can be denylisted before they're received, and they aren't removed
+// once the matching outputs are spent.
+func (s *Store) AddToSelectionDenylist(ns walletdb.ReadWriteBucket,
+	entry DenylistEntry) error {
+
+	k, err := entry.key()
+	if err != nil {
+		return err
+	}
+	return putDenylistKey(ns, k)
+}
+
+// RemoveFromSelectionDenylist removes an outpoint or output script from the
+// selection denylist. Removing an entry that isn't denylisted has no effect.
+func (s *Store) RemoveFromSelectionDenylist(ns walletdb.ReadWriteBucket,
+	entry DenylistEntry) error {
+
+	k, err := entry.key()
+	if err != nil {
+		return err
+	}
+	return deleteDenylistKey(ns, k)
+}
+
+// IsSelectionDenylisted returns whether the output with the given outpoint and
+// output script is in the selection denylist, either by its outpoint or by its
+// output script.
+func (s *Store) IsSelectionDenylisted(ns walletdb.ReadBucket, op wire.OutPoint,
+	pkScript []byte) bool {
+
+	if existsDenylistKey(ns, keyDenylistOutPoint(&op)) {
+		return true
+	}
+	return len(pkScript) != 0 &&
+		existsDenylistKey(ns, keyDenylistPkScript(pkScript))
+}
+
+// SelectionDenylist returns all entries of the selection denylist.
+func (s *Store) SelectionDenylist(ns walletdb.ReadBucket) ([]DenylistEntry,
+	error) {
+
+	var entries []DenylistEntry
+	err := forEachDenylistEntry(ns, func(entry DenylistEntry) {
+		entries = append(entries, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// PutRelativeLock records that an output is encumbered by the given BIP 68
+// relative lock-time, encoded as the sequence number of the input spending it,
+// such as an output paying to a CSV script. It's reported through the
+// RelativeLock field of the output's Credit, and cleared once the output has a
+// confirmed spend. Recording a new lock-time for the same output overwrites
+// the previous one.
+//
+// If the output is not known, ErrUnknownOutput is returned.
+func (s *Store) PutRelativeLock(ns walletdb.ReadWriteBucket, op wire.OutPoint,
+	sequence uint32) error {
+
+	// Make sure the output is known.
+	if !isKnownOutput(ns, op) {
+		return ErrUnknownOutput
+	}
+
+	return putRelativeLock(ns, op, sequence)
+}
+
+// RelativeLock returns the relative lock-time recorded for an output through
+// PutRelativeLock, if any.
+func (s *Store) RelativeLock(ns walletdb.ReadBucket, op wire.OutPoint) (uint32,
+	bool) {
+
+	return fetchRelativeLock(ns, op)
+}
+
+// PutTimeLockedScript records that the output script pays to the given witness
+// script, encumbered by the given absolute lock-time, such as a P2WSH output
+// script paying to a CLTV script. The lock-time is reported through the
+// LockTime field of the Credits of the outputs paying to it. Recording a new
+// witness script for the same output script overwrites the previous one.
+func (s *Store) PutTimeLockedScript(ns walletdb.ReadWriteBucket, pkScript,
+	witnessScript []byte, lockTime uint32) error {
+
+	return putTimeLockedScript(ns, pkScript, witnessScript, lockTime)
+}
+
+// DeleteTimeLockedScript removes the witness script recorded for the output
+// script through PutTimeLockedScript, such that the outputs paying to it are no
+// longer recognized. It's meant for scripts that were never paid to.
+func (s *Store) DeleteTimeLockedScript(ns walletdb.ReadWriteBucket,
+	pkScript []byte) error {
+
+	return deleteTimeLockedScript(ns, pkScript)
+}
+
+// TimeLockedScript returns the witness script and absolute lock-time recorded
+// for the output script through PutTimeLockedScript, if any.
+func (s *Store) TimeLockedScript(ns walletdb.ReadBucket,
+	pkScript []byte) ([]byte, uint32, bool) {
+
+	return fetchTimeLockedScript(ns, pkScript)
+}
+
+// TimeLockedScripts returns the output scripts recorded through
+// PutTimeLockedScript.
+func (s *Store) TimeLockedScripts(ns walletdb.ReadBucket) ([][]byte, error) {
+	return fetchTimeLockedScripts(ns)
+}
+
+// PutTxReplacement records that the unconfirmed transaction with the hash
+// replaced was replaced by the one with the hash replacement, such as through
+// RBF. Recording a new replacement for the same transaction overwrites the
+// previous one.
+func (s *Store) PutTxReplacement(ns walletdb.ReadWriteBucket, replaced,
+	replacement chainhash.Hash) error {
+
+	return putTxReplacement(ns, &replaced, &replacement)
+}
+
+// TxReplacement returns the hash of the transaction recorded as having
+// replaced the one with the given hash, or nil if it's not known to have been
+// replaced.
+func (s *Store) TxReplacement(ns walletdb.ReadBucket,
+	txHash chainhash.Hash) (*chainhash.Hash, error) {
+
+	return fetchTxReplacement(ns, &txHash)
+}
+
 // DeleteExpiredLockedOutputs iterates through all existing locked outputs and
 // deletes those which have already expired.
 func (s *Store) DeleteExpiredLockedOutputs(ns walletdb.ReadWriteBucket) error {
@@ -1282,3 +1718,15 @@ func (s *Store) ListLockedOutputs(ns walletdb.ReadBucket) ([]*LockedOutput,
 
 	return outputs, nil
 }
+
+// isNonStandardScript returns whether the output script isn't of a standard
+// type paying to a single address, such as a bare multisig script or one that
+// isn't recognized at all.
+func isNonStandardScript(pkScript []byte) bool {
+	switch txscript.GetScriptClass(pkScript) {
+	case txscript.NonStandardTy, txscript.MultiSigTy:
+		return true
+	default:
+		return false
+	}
+}
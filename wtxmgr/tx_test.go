@@ -11,11 +11,14 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcwallet/walletdb"
@@ -2848,3 +2851,1128 @@ func TestOutputLocks(t *testing.T) {
 		})
 	}
 }
+
+// TestFrozenOutputs ensures that outputs can be frozen and unfrozen, that
+// frozen outputs remain unspent outputs, and that freezes are cleared once the
+// output has a confirmed spend.
+func TestFrozenOutputs(t *testing.T) {
+	t.Parallel()
+
+	store, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	block := &BlockMeta{
+		Block: Block{
+			Hash:   chainhash.Hash{1, 3, 3, 7},
+			Height: 1337,
+		},
+		Time: time.Now(),
+	}
+
+	coinbase := newCoinBase(btcutil.SatoshiPerBitcoin)
+	coinbaseHash := coinbase.TxHash()
+	confirmedTx := spendOutput(&coinbaseHash, 0, btcutil.SatoshiPerBitcoin)
+	confirmedOutPoint := wire.OutPoint{Hash: confirmedTx.TxHash()}
+	insertConfirmedCredit(t, store, db, confirmedTx, 0, block)
+
+	assertFrozen := func(ns walletdb.ReadBucket, frozen ...wire.OutPoint) {
+		t.Helper()
+
+		outputs, err := store.ListFrozenOutputs(ns)
+		if err != nil {
+			t.Fatalf("unable to list frozen outputs: %v", err)
+		}
+		if len(outputs) != len(frozen) {
+			t.Fatalf("expected %d frozen outputs, got %d",
+				len(frozen), len(outputs))
+		}
+		for i, op := range frozen {
+			if outputs[i] != op {
+				t.Fatalf("expected frozen output %v, got %v",
+					op, outputs[i])
+			}
+			if !store.IsFrozenOutput(ns, op) {
+				t.Fatalf("expected output %v to be frozen", op)
+			}
+		}
+	}
+
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		// Unknown outputs can't be frozen.
+		err := store.FreezeOutput(ns, wire.OutPoint{Index: 1})
+		if err != ErrUnknownOutput {
+			t.Fatalf("expected ErrUnknownOutput, got %v", err)
+		}
+
+		// A frozen output should still be an unspent output.
+		if err := store.FreezeOutput(ns, confirmedOutPoint); err != nil {
+			t.Fatalf("unable to freeze output: %v", err)
+		}
+		assertFrozen(ns, confirmedOutPoint)
+		assertUtxos(t, store, ns, []wire.OutPoint{confirmedOutPoint})
+
+		// Unfreezing it should remove the freeze.
+		if err := store.UnfreezeOutput(ns, confirmedOutPoint); err != nil {
+			t.Fatalf("unable to unfreeze output: %v", err)
+		}
+		assertFrozen(ns)
+		if store.IsFrozenOutput(ns, confirmedOutPoint) {
+			t.Fatalf("expected output to be unfrozen")
+		}
+
+		// Once a frozen output has a confirmed spend, its freeze
+		// should be cleared.
+		if err := store.FreezeOutput(ns, confirmedOutPoint); err != nil {
+			t.Fatalf("unable to freeze output: %v", err)
+		}
+		spendTx := spendOutput(&confirmedOutPoint.Hash, 0, 500)
+		spendRec, err := NewTxRecordFromMsgTx(spendTx, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.InsertTx(ns, spendRec, block); err != nil {
+			t.Fatal(err)
+		}
+		assertFrozen(ns)
+	})
+}
+
+// TestSelectionDenylist ensures that outpoints and output scripts can be added
+// to and removed from the selection denylist, regardless of whether they match
+// a known output, and that denylisted outputs remain unspent outputs.
+func TestSelectionDenylist(t *testing.T) {
+	t.Parallel()
+
+	store, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	block := &BlockMeta{
+		Block: Block{
+			Hash:   chainhash.Hash{1, 3, 3, 7},
+			Height: 1337,
+		},
+		Time: time.Now(),
+	}
+
+	coinbase := newCoinBase(btcutil.SatoshiPerBitcoin)
+	coinbaseHash := coinbase.TxHash()
+	confirmedTx := spendOutput(&coinbaseHash, 0, btcutil.SatoshiPerBitcoin)
+	pkScript := []byte{0x51}
+	confirmedTx.TxOut[0].PkScript = pkScript
+	confirmedOutPoint := wire.OutPoint{Hash: confirmedTx.TxHash()}
+	insertConfirmedCredit(t, store, db, confirmedTx, 0, block)
+
+	unknownOutPoint := wire.OutPoint{Hash: chainhash.Hash{1}, Index: 1}
+	otherScript := []byte{0x52}
+
+	sameEntry := func(a, b DenylistEntry) bool {
+		if (a.OutPoint == nil) != (b.OutPoint == nil) {
+			return false
+		}
+		if a.OutPoint != nil && *a.OutPoint != *b.OutPoint {
+			return false
+		}
+		return bytes.Equal(a.PkScript, b.PkScript)
+	}
+
+	assertDenylist := func(ns walletdb.ReadBucket, exp ...DenylistEntry) {
+		t.Helper()
+
+		entries, err := store.SelectionDenylist(ns)
+		if err != nil {
+			t.Fatalf("unable to fetch selection denylist: %v", err)
+		}
+		if len(entries) != len(exp) {
+			t.Fatalf("expected %d denylist entries, got %d",
+				len(exp), len(entries))
+		}
+		for i, entry := range exp {
+			if !sameEntry(entries[i], entry) {
+				t.Fatalf("expected denylist entry %v, got %v",
+					entry, entries[i])
+			}
+		}
+	}
+
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		// Entries must be either an outpoint or an output script.
+		for _, entry := range []DenylistEntry{
+			{},
+			{OutPoint: &confirmedOutPoint, PkScript: pkScript},
+		} {
+			err := store.AddToSelectionDenylist(ns, entry)
+			serr, ok := err.(Error)
+			if !ok || serr.Code != ErrInput {
+				t.Fatalf("expected ErrInput, got %v", err)
+			}
+		}
+
+		// Unknown outputs can be denylisted by outpoint, without
+		// affecting known ones.
+		unknownEntry := DenylistEntry{OutPoint: &unknownOutPoint}
+		err := store.AddToSelectionDenylist(ns, unknownEntry)
+		if err != nil {
+			t.Fatalf("unable to denylist outpoint: %v", err)
+		}
+		assertDenylist(ns, unknownEntry)
+		if !store.IsSelectionDenylisted(ns, unknownOutPoint, nil) {
+			t.Fatalf("expected outpoint to be denylisted")
+		}
+		if store.IsSelectionDenylisted(
+			ns, confirmedOutPoint, pkScript,
+		) {
+
+			t.Fatalf("expected output to not be denylisted")
+		}
+
+		// Denylisting its script denylists the known output, which
+		// should still be an unspent output.
+		scriptEntry := DenylistEntry{PkScript: pkScript}
+		err = store.AddToSelectionDenylist(ns, scriptEntry)
+		if err != nil {
+			t.Fatalf("unable to denylist script: %v", err)
+		}
+		assertDenylist(ns, unknownEntry, scriptEntry)
+		if !store.IsSelectionDenylisted(
+			ns, confirmedOutPoint, pkScript,
+		) {
+
+			t.Fatalf("expected output to be denylisted")
+		}
+		if store.IsSelectionDenylisted(
+			ns, confirmedOutPoint, otherScript,
+		) {
+
+			t.Fatalf("expected other script to not be denylisted")
+		}
+		assertUtxos(t, store, ns, []wire.OutPoint{confirmedOutPoint})
+
+		// Removing the entries should remove them from the denylist.
+		err = store.RemoveFromSelectionDenylist(ns, scriptEntry)
+		if err != nil {
+			t.Fatalf("unable to remove script from denylist: %v",
+				err)
+		}
+		err = store.RemoveFromSelectionDenylist(ns, unknownEntry)
+		if err != nil {
+			t.Fatalf("unable to remove outpoint from denylist: %v",
+				err)
+		}
+		assertDenylist(ns)
+		if store.IsSelectionDenylisted(
+			ns, confirmedOutPoint, pkScript,
+		) {
+
+			t.Fatalf("expected output to not be denylisted")
+		}
+	})
+}
+
+// TestRelativeLocks ensures that the relative lock-times recorded for outputs
+// are reported along with their credits, and cleared once the output has a
+// confirmed spend.
+func TestRelativeLocks(t *testing.T) {
+	t.Parallel()
+
+	store, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	block := &BlockMeta{
+		Block: Block{
+			Hash:   chainhash.Hash{1, 3, 3, 7},
+			Height: 1337,
+		},
+		Time: time.Now(),
+	}
+
+	coinbase := newCoinBase(btcutil.SatoshiPerBitcoin)
+	coinbaseHash := coinbase.TxHash()
+	confirmedTx := spendOutput(&coinbaseHash, 0, btcutil.SatoshiPerBitcoin)
+	confirmedOutPoint := wire.OutPoint{Hash: confirmedTx.TxHash()}
+	insertConfirmedCredit(t, store, db, confirmedTx, 0, block)
+
+	// assertRelativeLock asserts the relative lock-time of the output, as
+	// reported by both RelativeLock and UnspentOutputs.
+	assertRelativeLock := func(ns walletdb.ReadBucket, sequence uint32) {
+		t.Helper()
+
+		lock, ok := store.RelativeLock(ns, confirmedOutPoint)
+		if ok != (sequence != 0) || lock != sequence {
+			t.Fatalf("expected relative lock %d, got %d (%v)",
+				sequence, lock, ok)
+		}
+
+		utxos, err := store.UnspentOutputs(ns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, utxo := range utxos {
+			if utxo.OutPoint != confirmedOutPoint {
+				continue
+			}
+			if utxo.RelativeLock != sequence {
+				t.Fatalf("expected credit with relative "+
+					"lock %d, got %d", sequence,
+					utxo.RelativeLock)
+			}
+		}
+	}
+
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		// Relative lock-times can't be recorded for unknown outputs.
+		err := store.PutRelativeLock(ns, wire.OutPoint{Index: 1}, 144)
+		if err != ErrUnknownOutput {
+			t.Fatalf("expected ErrUnknownOutput, got %v", err)
+		}
+
+		assertRelativeLock(ns, 0)
+		err = store.PutRelativeLock(ns, confirmedOutPoint, 144)
+		if err != nil {
+			t.Fatalf("unable to put relative lock: %v", err)
+		}
+		assertRelativeLock(ns, 144)
+
+		// Once the output has a confirmed spend, its relative lock-time
+		// should be cleared.
+		spendTx := spendOutput(&confirmedOutPoint.Hash, 0, 500)
+		spendRec, err := NewTxRecordFromMsgTx(spendTx, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.InsertTx(ns, spendRec, block); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := store.RelativeLock(ns, confirmedOutPoint); ok {
+			t.Fatal("expected relative lock to be cleared")
+		}
+	})
+}
+
+// TestPurgeConflicted ensures that only unmined transactions older than the
+// cutoff which can no longer confirm are purged from the store, along with
+// their descendants and labels.
+func TestPurgeConflicted(t *testing.T) {
+	t.Parallel()
+
+	store, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+	cutoff := now.Add(-24 * time.Hour)
+
+	newRecord := func(tx *wire.MsgTx, received time.Time) *TxRecord {
+		rec, err := NewTxRecordFromMsgTx(tx, received)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	// We'll start by adding a confirmed transaction with four outputs
+	// belonging to the wallet.
+	b100 := BlockMeta{
+		Block: Block{Height: 100},
+		Time:  old,
+	}
+	cbRec := newRecord(newCoinBase(1e8, 1e8, 1e8, 1e8), old)
+
+	// The first output is spent by a confirmed transaction, and then by an
+	// unmined one that's seen afterwards. The unmined spend is old enough
+	// to be purged, so it should be removed along with its child.
+	minedSpend := newRecord(spendOutput(&cbRec.Hash, 0, 9e7), old)
+	staleSpend := newRecord(spendOutput(&cbRec.Hash, 0, 8e7), old)
+	staleChild := newRecord(spendOutput(&staleSpend.Hash, 0, 7e7), now)
+
+	// The second output is spent by an unmined transaction which was later
+	// replaced. Only the replaced transaction should be purged.
+	replaced := newRecord(spendOutput(&cbRec.Hash, 1, 9e7), old)
+	replacement := newRecord(spendOutput(&cbRec.Hash, 1, 8e7), now)
+
+	// The third output is spent by a confirmed transaction and a recent
+	// unmined double spend, which should be kept as it's not older than
+	// our cutoff.
+	recentSpend := newRecord(spendOutput(&cbRec.Hash, 2, 9e7), now)
+
+	// The fourth output is spent by an old unmined transaction that isn't
+	// in conflict with anything, so it should be kept.
+	pending := newRecord(spendOutput(&cbRec.Hash, 3, 9e7), old)
+
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		if err := store.InsertTx(ns, cbRec, &b100); err != nil {
+			t.Fatal(err)
+		}
+		for i := uint32(0); i < 4; i++ {
+			err := store.AddCredit(ns, cbRec, &b100, i, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		b101 := BlockMeta{
+			Block: Block{Height: 101},
+			Time:  old,
+		}
+		if err := store.InsertTx(ns, minedSpend, &b101); err != nil {
+			t.Fatal(err)
+		}
+		b102 := BlockMeta{
+			Block: Block{Height: 102},
+			Time:  now,
+		}
+		if err := store.InsertTx(ns, recentSpend, &b102); err != nil {
+			t.Fatal(err)
+		}
+
+		unminedRecs := []*TxRecord{
+			staleSpend, staleChild, replaced, replacement,
+			pending,
+		}
+		for _, rec := range unminedRecs {
+			if err := store.InsertTx(ns, rec, nil); err != nil {
+				t.Fatal(err)
+			}
+			err := store.AddCredit(ns, rec, nil, 0, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Insert an unmined double spend of the recently confirmed
+		// transaction, which is too recent to be purged.
+		recentConflict := newRecord(
+			spendOutput(&cbRec.Hash, 2, 8e7), now,
+		)
+		if err := store.InsertTx(ns, recentConflict, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		err := store.PutTxLabel(ns, staleSpend.Hash, "stale")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.PutTxLabel(ns, pending.Hash, "pending")
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		numPurged, err := store.PurgeConflicted(ns, cutoff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if numPurged != 3 {
+			t.Fatalf("expected 3 purged transactions, got %d",
+				numPurged)
+		}
+
+		purged := []*TxRecord{staleSpend, staleChild, replaced}
+		for _, rec := range purged {
+			if existsRawUnmined(ns, rec.Hash[:]) != nil {
+				t.Fatalf("expected transaction %v to be "+
+					"purged", rec.Hash)
+			}
+			k := canonicalOutPoint(&rec.Hash, 0)
+			if existsRawUnminedCredit(ns, k) != nil {
+				t.Fatalf("expected unmined credit of %v to "+
+					"be purged", rec.Hash)
+			}
+		}
+		kept := []*TxRecord{replacement, pending}
+		for _, rec := range kept {
+			if existsRawUnmined(ns, rec.Hash[:]) == nil {
+				t.Fatalf("expected transaction %v to be "+
+					"kept", rec.Hash)
+			}
+		}
+
+		_, err = FetchTxLabel(ns, staleSpend.Hash)
+		if err != ErrTxLabelNotFound {
+			t.Fatalf("expected: %v, got: %v", ErrTxLabelNotFound,
+				err)
+		}
+		label, err := FetchTxLabel(ns, pending.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if label != "pending" {
+			t.Fatalf("expected label pending, got %v", label)
+		}
+
+		// A second purge should not find anything else to remove.
+		numPurged, err = store.PurgeConflicted(ns, cutoff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if numPurged != 0 {
+			t.Fatalf("expected no purged transactions, got %d",
+				numPurged)
+		}
+	})
+}
+
+// TestTxReplacement ensures that the replacement of a transaction can be
+// recorded and looked up.
+func TestTxReplacement(t *testing.T) {
+	t.Parallel()
+
+	store, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	replaced := chainhash.Hash{1}
+	replacement := chainhash.Hash{2}
+
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		// No replacement should be found before one is recorded.
+		found, err := store.TxReplacement(ns, replaced)
+		if err != nil {
+			t.Fatalf("unable to fetch replacement: %v", err)
+		}
+		if found != nil {
+			t.Fatalf("expected no replacement, got %v", found)
+		}
+
+		err = store.PutTxReplacement(ns, replaced, replacement)
+		if err != nil {
+			t.Fatalf("unable to record replacement: %v", err)
+		}
+		found, err = store.TxReplacement(ns, replaced)
+		if err != nil {
+			t.Fatalf("unable to fetch replacement: %v", err)
+		}
+		if found == nil || *found != replacement {
+			t.Fatalf("expected replacement %v, got %v",
+				replacement, found)
+		}
+
+		// The replacement itself isn't replaced.
+		found, err = store.TxReplacement(ns, replacement)
+		if err != nil {
+			t.Fatalf("unable to fetch replacement: %v", err)
+		}
+		if found != nil {
+			t.Fatalf("expected no replacement, got %v", found)
+		}
+	})
+}
+
+// TestTxComment ensures that transaction comments can be set, retrieved and
+// removed, and that they persist as their transaction is confirmed and
+// reorged out.
+func TestTxComment(t *testing.T) {
+	t.Parallel()
+
+	store, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	tx := spendOutput(&chainhash.Hash{1}, 0, 1e8)
+	txRec, err := NewTxRecordFromMsgTx(tx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const comment = "rent for march"
+
+	// assertComment ensures the transaction's details contain the
+	// expected comment.
+	assertComment := func(ns walletdb.ReadWriteBucket, expected string) {
+		t.Helper()
+
+		details, err := store.TxDetails(ns, &txRec.Hash)
+		if err != nil {
+			t.Fatalf("unable to fetch details: %v", err)
+		}
+		if details.Comment != expected {
+			t.Fatalf("expected comment %q, got %q", expected,
+				details.Comment)
+		}
+		if c := store.TxComment(ns, txRec.Hash); c != expected {
+			t.Fatalf("expected comment %q, got %q", expected, c)
+		}
+	}
+
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		// Unknown transactions can't be commented.
+		err := store.SetTxComment(ns, txRec.Hash, comment)
+		if !IsNoExists(err) {
+			t.Fatalf("expected ErrNoExists, got %v", err)
+		}
+
+		if err := store.InsertTx(ns, txRec, nil); err != nil {
+			t.Fatal(err)
+		}
+		err = store.AddCredit(ns, txRec, nil, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertComment(ns, "")
+
+		err = store.SetTxComment(
+			ns, txRec.Hash, strings.Repeat("a", TxCommentLimit+1),
+		)
+		if err != ErrCommentTooLong {
+			t.Fatalf("expected ErrCommentTooLong, got %v", err)
+		}
+
+		err = store.SetTxComment(ns, txRec.Hash, comment)
+		if err != nil {
+			t.Fatalf("unable to set comment: %v", err)
+		}
+		assertComment(ns, comment)
+	})
+
+	// The comment persists once the transaction confirms.
+	block := &BlockMeta{
+		Block: Block{Height: 1337},
+		Time:  time.Now(),
+	}
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		if err := store.InsertTx(ns, txRec, block); err != nil {
+			t.Fatal(err)
+		}
+		err := store.AddCredit(ns, txRec, block, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertComment(ns, comment)
+	})
+
+	// And once its block is reorged out.
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		if err := store.Rollback(ns, block.Height); err != nil {
+			t.Fatal(err)
+		}
+		assertComment(ns, comment)
+	})
+
+	// Setting an empty comment removes it.
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		err := store.SetTxComment(ns, txRec.Hash, "")
+		if err != nil {
+			t.Fatalf("unable to remove comment: %v", err)
+		}
+		assertComment(ns, "")
+	})
+}
+
+// TestCreditKinds ensures the kind of each credit is recorded, and that it's
+// kept as its transaction confirms and is reorged out.
+func TestCreditKinds(t *testing.T) {
+	t.Parallel()
+
+	store, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	tx := spendOutput(&chainhash.Hash{1}, 0, 1e8, 2e8, 3e8)
+	txRec, err := NewTxRecordFromMsgTx(tx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := []CreditKind{
+		CreditKindReceive, CreditKindChange, CreditKindSelfTransfer,
+	}
+
+	// assertKinds ensures the transaction's credits are of the expected
+	// kinds.
+	assertKinds := func(ns walletdb.ReadWriteBucket) {
+		t.Helper()
+
+		details, err := store.TxDetails(ns, &txRec.Hash)
+		if err != nil {
+			t.Fatalf("unable to fetch details: %v", err)
+		}
+		if len(details.Credits) != len(kinds) {
+			t.Fatalf("expected %d credits, got %d", len(kinds),
+				len(details.Credits))
+		}
+		for _, cred := range details.Credits {
+			kind := kinds[cred.Index]
+			if cred.Change != (kind == CreditKindChange) {
+				t.Fatalf("credit %d: expected change=%v",
+					cred.Index, !cred.Change)
+			}
+			selfTransfer := kind == CreditKindSelfTransfer
+			if cred.SelfTransfer != selfTransfer {
+				t.Fatalf("credit %d: expected "+
+					"self-transfer=%v", cred.Index,
+					selfTransfer)
+			}
+		}
+	}
+
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		if err := store.InsertTx(ns, txRec, nil); err != nil {
+			t.Fatal(err)
+		}
+		for i, kind := range kinds {
+			err := store.AddCreditOfKind(
+				ns, txRec, nil, uint32(i), kind,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		assertKinds(ns)
+	})
+
+	// The kinds persist once the transaction confirms.
+	block := &BlockMeta{
+		Block: Block{Height: 1337},
+		Time:  time.Now(),
+	}
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		if err := store.InsertTx(ns, txRec, block); err != nil {
+			t.Fatal(err)
+		}
+		assertKinds(ns)
+	})
+
+	// And once its block is reorged out.
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		if err := store.Rollback(ns, block.Height); err != nil {
+			t.Fatal(err)
+		}
+		assertKinds(ns)
+	})
+}
+
+// TestSpendingTx ensures that the transaction spending a credit is returned
+// once it's spent, whether mined or not, and that reorgs un-spending it are
+// reflected.
+func TestSpendingTx(t *testing.T) {
+	t.Parallel()
+
+	store, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	creditTx := spendOutput(&chainhash.Hash{1}, 0, 1e8)
+	creditRec, err := NewTxRecordFromMsgTx(creditTx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := wire.OutPoint{Hash: creditRec.Hash}
+
+	spendTx := spendOutput(&creditRec.Hash, 0, 9e7)
+	spendRec, err := NewTxRecordFromMsgTx(spendTx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// assertSpender ensures the credit is spent by the spending
+	// transaction at the given height, or fails with the given error.
+	assertSpender := func(ns walletdb.ReadBucket, height int32,
+		expErr error) {
+
+		t.Helper()
+
+		details, err := store.SpendingTx(ns, op)
+		if err != expErr {
+			t.Fatalf("expected error %v, got %v", expErr, err)
+		}
+		if expErr != nil {
+			return
+		}
+		if details.Hash != spendRec.Hash {
+			t.Fatalf("expected spender %v, got %v", spendRec.Hash,
+				details.Hash)
+		}
+		if details.Block.Height != height {
+			t.Fatalf("expected spender at height %d, got %d",
+				height, details.Block.Height)
+		}
+	}
+
+	block := &BlockMeta{Block: Block{Height: 100}, Time: time.Now()}
+	spendBlock := &BlockMeta{Block: Block{Height: 101}, Time: time.Now()}
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		assertSpender(ns, 0, ErrUnknownOutput)
+
+		if err := store.InsertTx(ns, creditRec, block); err != nil {
+			t.Fatal(err)
+		}
+		err := store.AddCredit(ns, creditRec, block, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSpender(ns, 0, ErrOutputUnspent)
+
+		// The credit is first spent by an unmined transaction, which
+		// then confirms.
+		if err := store.InsertTx(ns, spendRec, nil); err != nil {
+			t.Fatal(err)
+		}
+		assertSpender(ns, -1, nil)
+
+		if err := store.InsertTx(ns, spendRec, spendBlock); err != nil {
+			t.Fatal(err)
+		}
+		assertSpender(ns, spendBlock.Height, nil)
+	})
+
+	// Reorging out the spending transaction's block leaves the credit
+	// spent by the now unmined transaction, until it's removed.
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		if err := store.Rollback(ns, spendBlock.Height); err != nil {
+			t.Fatal(err)
+		}
+		assertSpender(ns, -1, nil)
+
+		if err := store.RemoveUnminedTx(ns, spendRec); err != nil {
+			t.Fatal(err)
+		}
+		assertSpender(ns, 0, ErrOutputUnspent)
+	})
+}
+
+// TestRollbackCoinbaseReleasesOutputs ensures that rolling back the block of
+// a coinbase transaction clears the locks and freezes over its outputs, which
+// no longer exist.
+func TestRollbackCoinbaseReleasesOutputs(t *testing.T) {
+	t.Parallel()
+
+	store, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	coinbase := newCoinBase(50e8, 25e8)
+	rec, err := NewTxRecordFromMsgTx(coinbase, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := makeBlockMeta(100)
+	lockedOp := wire.OutPoint{Hash: rec.Hash, Index: 0}
+	frozenOp := wire.OutPoint{Hash: rec.Hash, Index: 1}
+
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		if err := store.InsertTx(ns, rec, &block); err != nil {
+			t.Fatal(err)
+		}
+		for i := range coinbase.TxOut {
+			idx := uint32(i)
+			err := store.AddCredit(ns, rec, &block, idx, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err := store.LockOutput(ns, LockID{1}, lockedOp, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.FreezeOutput(ns, frozenOp); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		if err := store.Rollback(ns, block.Height); err != nil {
+			t.Fatal(err)
+		}
+
+		locked, err := store.ListLockedOutputs(ns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(locked) != 0 {
+			t.Fatalf("expected no locked outputs, got %v", locked)
+		}
+		if store.IsFrozenOutput(ns, frozenOp) {
+			t.Fatalf("expected output %v to be unfrozen", frozenOp)
+		}
+
+		balance, err := store.Balance(ns, 0, block.Height+10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance != 0 {
+			t.Fatalf("expected no balance, got %v", balance)
+		}
+	})
+}
+
+// TestOutputLabel ensures that the labels of a transaction's outputs can be
+// set, retrieved and removed independently of each other, and that they
+// persist as their transaction is confirmed.
+func TestOutputLabel(t *testing.T) {
+	t.Parallel()
+
+	store, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	tx := spendOutput(&chainhash.Hash{1}, 0, 1e8, 2e8)
+	txRec, err := NewTxRecordFromMsgTx(tx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := wire.OutPoint{Hash: txRec.Hash, Index: 0}
+	second := wire.OutPoint{Hash: txRec.Hash, Index: 1}
+
+	// assertLabel ensures the output has the expected label.
+	assertLabel := func(ns walletdb.ReadWriteBucket, op wire.OutPoint,
+		expected string) {
+
+		t.Helper()
+
+		if label := store.OutputLabel(ns, op); label != expected {
+			t.Fatalf("expected label %q for %v, got %q", expected,
+				op, label)
+		}
+	}
+
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		// Outputs of unknown transactions can't be labeled.
+		err := store.SetOutputLabel(ns, first, "alice")
+		if !IsNoExists(err) {
+			t.Fatalf("expected ErrNoExists, got %v", err)
+		}
+
+		if err := store.InsertTx(ns, txRec, nil); err != nil {
+			t.Fatal(err)
+		}
+		assertLabel(ns, first, "")
+
+		err = store.SetOutputLabel(
+			ns, first, strings.Repeat("a", TxLabelLimit+1),
+		)
+		if err != ErrLabelTooLong {
+			t.Fatalf("expected ErrLabelTooLong, got %v", err)
+		}
+
+		if err := store.SetOutputLabel(ns, first, "alice"); err != nil {
+			t.Fatalf("unable to set label: %v", err)
+		}
+		if err := store.SetOutputLabel(ns, second, "bob"); err != nil {
+			t.Fatalf("unable to set label: %v", err)
+		}
+		assertLabel(ns, first, "alice")
+		assertLabel(ns, second, "bob")
+	})
+
+	// The labels persist once the transaction confirms.
+	block := &BlockMeta{
+		Block: Block{Height: 1337},
+		Time:  time.Now(),
+	}
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		if err := store.InsertTx(ns, txRec, block); err != nil {
+			t.Fatal(err)
+		}
+		assertLabel(ns, first, "alice")
+		assertLabel(ns, second, "bob")
+	})
+
+	// Setting an empty label only removes that of the given output.
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		if err := store.SetOutputLabel(ns, first, ""); err != nil {
+			t.Fatalf("unable to remove label: %v", err)
+		}
+		assertLabel(ns, first, "")
+		assertLabel(ns, second, "bob")
+	})
+}
+
+// TestRemovedOutputLabels ensures that the output labels of unmined
+// transactions are kept once removed through RemoveUnminedTx, and deleted
+// along with those of their descendants once discarded or replaced by a
+// confirmed double spend.
+func TestRemovedOutputLabels(t *testing.T) {
+	t.Parallel()
+
+	store, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	// newLabeledTx inserts an unmined transaction spending the first
+	// output of the given transaction, and labels its output.
+	newLabeledTx := func(ns walletdb.ReadWriteBucket,
+		prevHash chainhash.Hash, value int64) *TxRecord {
+
+		t.Helper()
+
+		tx := spendOutput(&prevHash, 0, value)
+		rec, err := NewTxRecordFromMsgTx(tx, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.InsertTx(ns, rec, nil); err != nil {
+			t.Fatal(err)
+		}
+		err = store.SetOutputLabel(
+			ns, wire.OutPoint{Hash: rec.Hash}, "label",
+		)
+		if err != nil {
+			t.Fatalf("unable to set label: %v", err)
+		}
+		return rec
+	}
+
+	// assertLabel ensures the first output of the transaction has the
+	// expected label.
+	assertLabel := func(ns walletdb.ReadWriteBucket, rec *TxRecord,
+		expected string) {
+
+		t.Helper()
+
+		op := wire.OutPoint{Hash: rec.Hash}
+		if label := store.OutputLabel(ns, op); label != expected {
+			t.Fatalf("expected label %q for %v, got %q", expected,
+				op, label)
+		}
+	}
+
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		// A removed transaction keeps its label.
+		removed := newLabeledTx(ns, chainhash.Hash{1}, 1e8)
+		if err := store.RemoveUnminedTx(ns, removed); err != nil {
+			t.Fatal(err)
+		}
+		assertLabel(ns, removed, "label")
+
+		// A discarded transaction loses its label, along with the
+		// transaction spending it.
+		parent := newLabeledTx(ns, chainhash.Hash{2}, 1e8)
+		child := newLabeledTx(ns, parent.Hash, 9e7)
+		if err := store.DiscardUnminedTx(ns, parent); err != nil {
+			t.Fatal(err)
+		}
+		assertLabel(ns, parent, "")
+		assertLabel(ns, child, "")
+
+		// So does a transaction replaced by a confirmed double spend.
+		replaced := newLabeledTx(ns, chainhash.Hash{3}, 1e8)
+		doubleSpend, err := NewTxRecordFromMsgTx(
+			spendOutput(&chainhash.Hash{3}, 0, 9e7), time.Now(),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block := &BlockMeta{
+			Block: Block{Height: 1337},
+			Time:  time.Now(),
+		}
+		if err := store.InsertTx(ns, doubleSpend, block); err != nil {
+			t.Fatal(err)
+		}
+		assertLabel(ns, replaced, "")
+	})
+}
+
+// TestTimeLockedScripts ensures that the witness scripts and lock-times
+// recorded for output scripts are returned, and reported along with the
+// credits paying to them.
+func TestTimeLockedScripts(t *testing.T) {
+	t.Parallel()
+
+	store, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	block := &BlockMeta{
+		Block: Block{
+			Hash:   chainhash.Hash{1, 3, 3, 7},
+			Height: 1337,
+		},
+		Time: time.Now(),
+	}
+
+	witnessScript := []byte{txscript.OP_CHECKLOCKTIMEVERIFY}
+	pkScript := append([]byte{txscript.OP_0, 32}, make([]byte, 32)...)
+	coinbase := newCoinBase(btcutil.SatoshiPerBitcoin)
+	coinbaseHash := coinbase.TxHash()
+	confirmedTx := spendOutput(&coinbaseHash, 0, btcutil.SatoshiPerBitcoin)
+	confirmedTx.TxOut[0].PkScript = pkScript
+	insertConfirmedCredit(t, store, db, confirmedTx, 0, block)
+
+	// assertLockTime asserts the lock-time of the output, as reported by
+	// UnspentOutputs.
+	assertLockTime := func(ns walletdb.ReadBucket, lockTime uint32) {
+		t.Helper()
+
+		utxos, err := store.UnspentOutputs(ns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(utxos) != 1 {
+			t.Fatalf("expected 1 credit, got %d", len(utxos))
+		}
+		if utxos[0].LockTime != lockTime {
+			t.Fatalf("expected credit with lock-time %d, got %d",
+				lockTime, utxos[0].LockTime)
+		}
+	}
+
+	// assertScripts asserts the output scripts recorded as paying to a
+	// time-locked script.
+	assertScripts := func(ns walletdb.ReadBucket, expected ...[]byte) {
+		t.Helper()
+
+		pkScripts, err := store.TimeLockedScripts(ns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pkScripts, expected) {
+			t.Fatalf("expected time-locked output scripts %x, "+
+				"got %x", expected, pkScripts)
+		}
+	}
+
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		_, _, ok := store.TimeLockedScript(ns, pkScript)
+		if ok {
+			t.Fatal("unexpected time-locked script")
+		}
+		assertLockTime(ns, 0)
+		assertScripts(ns)
+
+		err := store.PutTimeLockedScript(
+			ns, pkScript, witnessScript, 1500,
+		)
+		if err != nil {
+			t.Fatalf("unable to put time-locked script: %v", err)
+		}
+
+		script, lockTime, ok := store.TimeLockedScript(ns, pkScript)
+		if !ok || lockTime != 1500 ||
+			!bytes.Equal(script, witnessScript) {
+
+			t.Fatalf("expected time-locked script %x with "+
+				"lock-time 1500, got %x with %d (%v)",
+				witnessScript, script, lockTime, ok)
+		}
+		assertLockTime(ns, 1500)
+		assertScripts(ns, pkScript)
+	})
+}
@@ -6,6 +6,8 @@
 package wtxmgr
 
 import (
+	"time"
+
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcwallet/walletdb"
@@ -53,6 +55,18 @@ func (s *Store) insertMemPoolTx(ns walletdb.ReadWriteBucket, rec *TxRecord) erro
 		if err != nil {
 			return err
 		}
+
+		// Spends of credits are indexed under their output script.
+		pkScript, err := fetchCreditPkScript(ns, prevOut)
+		if err != nil {
+			return err
+		}
+		if pkScript == nil {
+			continue
+		}
+		if err := putScriptTx(ns, pkScript, &rec.Hash); err != nil {
+			return err
+		}
 	}
 
 	// TODO: increment credit amount for each credit (but those are unknown
@@ -101,7 +115,8 @@ func (s *Store) removeDoubleSpends(ns walletdb.ReadWriteBucket, rec *TxRecord) e
 			log.Debugf("Removing double spending transaction %v",
 				doubleSpend.Hash)
 
-			if err := s.removeConflict(ns, &doubleSpend); err != nil {
+			err = s.removeConflict(ns, &doubleSpend, true)
+			if err != nil {
 				return err
 			}
 		}
@@ -113,8 +128,12 @@ func (s *Store) removeDoubleSpends(ns walletdb.ReadWriteBucket, rec *TxRecord) e
 // removeConflict removes an unmined transaction record and all spend chains
 // deriving from it from the store.  This is designed to remove transactions
 // that would otherwise result in double spend conflicts if left in the store,
-// and to remove transactions that spend coinbase transactions on reorgs.
-func (s *Store) removeConflict(ns walletdb.ReadWriteBucket, rec *TxRecord) error {
+// and to remove transactions that spend coinbase transactions on reorgs. If
+// deleteLabels is set, the labels of the outputs of the removed transactions
+// are deleted as well.
+func (s *Store) removeConflict(ns walletdb.ReadWriteBucket, rec *TxRecord,
+	deleteLabels bool) error {
+
 	// For each potential credit for this record, each spender (if any) must
 	// be recursively removed as well.  Once the spenders are removed, the
 	// credit is deleted.
@@ -141,7 +160,8 @@ func (s *Store) removeConflict(ns walletdb.ReadWriteBucket, rec *TxRecord) error
 
 			log.Debugf("Transaction %v is part of a removed conflict "+
 				"chain -- removing as well", spender.Hash)
-			if err := s.removeConflict(ns, &spender); err != nil {
+			err = s.removeConflict(ns, &spender, deleteLabels)
+			if err != nil {
 				return err
 			}
 		}
@@ -162,9 +182,42 @@ func (s *Store) removeConflict(ns walletdb.ReadWriteBucket, rec *TxRecord) error
 		}
 	}
 
+	if deleteLabels {
+		if err := deleteTxOutputLabels(ns, &rec.Hash); err != nil {
+			return err
+		}
+	}
+
 	return deleteRawUnmined(ns, rec.Hash[:])
 }
 
+// UnminedConflicts returns the hashes of the unmined transactions, other than
+// rec itself, spending any of the outputs spent by rec, in the order of the
+// inputs of rec.
+func (s *Store) UnminedConflicts(ns walletdb.ReadBucket,
+	rec *TxRecord) []chainhash.Hash {
+
+	var conflicts []chainhash.Hash
+	seen := make(map[chainhash.Hash]struct{})
+	for _, input := range rec.MsgTx.TxIn {
+		prevOut := &input.PreviousOutPoint
+		k := canonicalOutPoint(&prevOut.Hash, prevOut.Index)
+		spenderHashes := fetchUnminedInputSpendTxHashes(ns, k)
+		for _, spenderHash := range spenderHashes {
+			if spenderHash == rec.Hash {
+				continue
+			}
+			if _, ok := seen[spenderHash]; ok {
+				continue
+			}
+			seen[spenderHash] = struct{}{}
+			conflicts = append(conflicts, spenderHash)
+		}
+	}
+
+	return conflicts
+}
+
 // UnminedTxs returns the underlying transactions for all unmined transactions
 // which are not known to have been mined in a block.  Transactions are
 // guaranteed to be sorted by their dependency order.
@@ -220,3 +273,192 @@ func (s *Store) unminedTxHashes(ns walletdb.ReadBucket) ([]*chainhash.Hash, erro
 	})
 	return hashes, err
 }
+
+// PurgeConflicted removes all unmined transactions received before olderThan
+// that can no longer confirm, along with every transaction that spends them
+// and any metadata (labels, comments, unmined credits and unmined inputs) left
+// behind.
+// An unmined transaction is considered conflicted if it spends an output that
+// has already been spent by a mined transaction, or if it spends an output that
+// is also spent by another unmined transaction that was received after it (as
+// is the case for a replaced transaction). Confirmed transactions, and unmined
+// transactions that are not in conflict, are never removed. The number of
+// transaction records removed is returned.
+//
+// Running PurgeConflicted more than once with the same cutoff is safe, any
+// subsequent invocations will not remove any further records.
+func (s *Store) PurgeConflicted(ns walletdb.ReadWriteBucket,
+	olderThan time.Time) (int, error) {
+
+	unmined, err := s.unminedTxRecords(ns)
+	if err != nil {
+		return 0, err
+	}
+
+	// Determine the set of conflicted transactions up front, as removing
+	// them below modifies the buckets we're inspecting.
+	var conflicted []*TxRecord
+	for _, rec := range unmined {
+		if !rec.Received.Before(olderThan) {
+			continue
+		}
+
+		isConflicted, err := s.isConflicted(ns, rec, unmined)
+		if err != nil {
+			return 0, err
+		}
+		if isConflicted {
+			conflicted = append(conflicted, rec)
+		}
+	}
+
+	for _, rec := range conflicted {
+		// The record may have already been removed if it spends from
+		// another conflicted transaction.
+		if existsRawUnmined(ns, rec.Hash[:]) == nil {
+			continue
+		}
+
+		log.Infof("Purging conflicted transaction %v", rec.Hash)
+
+		if err := s.removeConflict(ns, rec, false); err != nil {
+			return 0, err
+		}
+	}
+
+	// Any record we knew about that is no longer found within the unmined
+	// bucket has been removed, either directly or as a descendant of a
+	// conflicted transaction, so its label, comment and output labels must
+	// be removed as well.
+	var numPurged int
+	for txHash := range unmined {
+		if existsRawUnmined(ns, txHash[:]) != nil {
+			continue
+		}
+		if err := deleteTxLabel(ns, &txHash); err != nil {
+			return 0, err
+		}
+		if err := deleteTxComment(ns, &txHash); err != nil {
+			return 0, err
+		}
+		if err := deleteTxOutputLabels(ns, &txHash); err != nil {
+			return 0, err
+		}
+		numPurged++
+	}
+
+	if err := deleteOrphanedUnminedMetadata(ns); err != nil {
+		return 0, err
+	}
+
+	return numPurged, nil
+}
+
+// isConflicted determines whether the unmined transaction rec can no longer be
+// confirmed due to one of its inputs being spent by either a mined
+// transaction, or a more recently received unmined transaction.
+func (s *Store) isConflicted(ns walletdb.ReadBucket, rec *TxRecord,
+	unmined map[chainhash.Hash]*TxRecord) (bool, error) {
+
+	for _, input := range rec.MsgTx.TxIn {
+		prevOut := &input.PreviousOutPoint
+
+		// If the output being spent is a mined credit of ours, we can
+		// check whether it has already been spent by a mined debit.
+		prevKey, _ := latestTxRecord(ns, &prevOut.Hash)
+		if prevKey != nil {
+			var block Block
+			err := readRawTxRecordBlock(prevKey, &block)
+			if err != nil {
+				return false, err
+			}
+			_, credVal := existsCredit(
+				ns, &prevOut.Hash, prevOut.Index, &block,
+			)
+			if credVal != nil {
+				_, spent, err := fetchRawCreditAmountSpent(credVal)
+				if err != nil {
+					return false, err
+				}
+				if spent {
+					return true, nil
+				}
+			}
+		}
+
+		// Otherwise, check whether another unmined transaction that
+		// was received after this one spends the same output.
+		k := canonicalOutPoint(&prevOut.Hash, prevOut.Index)
+		for _, spenderHash := range fetchUnminedInputSpendTxHashes(ns, k) {
+			if spenderHash == rec.Hash {
+				continue
+			}
+			spender, ok := unmined[spenderHash]
+			if !ok {
+				continue
+			}
+			if spender.Received.After(rec.Received) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// deleteOrphanedUnminedMetadata removes all unmined credit and unmined input
+// entries that reference transactions no longer found within the unmined
+// bucket.
+func deleteOrphanedUnminedMetadata(ns walletdb.ReadWriteBucket) error {
+	var orphanedCredits [][]byte
+	orphanedInputs := make(map[string][]chainhash.Hash)
+
+	err := ns.NestedReadBucket(bucketUnminedCredits).ForEach(
+		func(k, _ []byte) error {
+			if existsRawUnmined(ns, k[:32]) == nil {
+				orphanedCredits = append(
+					orphanedCredits, append([]byte(nil), k...),
+				)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		str := "failed iterating unmined credits bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	err = ns.NestedReadBucket(bucketUnminedInputs).ForEach(
+		func(k, _ []byte) error {
+			for _, h := range fetchUnminedInputSpendTxHashes(ns, k) {
+				if existsRawUnmined(ns, h[:]) != nil {
+					continue
+				}
+				orphanedInputs[string(k)] = append(
+					orphanedInputs[string(k)], h,
+				)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		str := "failed iterating unmined inputs bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	for _, k := range orphanedCredits {
+		if err := deleteRawUnminedCredit(ns, k); err != nil {
+			return err
+		}
+	}
+	for k, spendHashes := range orphanedInputs {
+		for _, spendHash := range spendHashes {
+			err := deleteRawUnminedInput(ns, []byte(k), spendHash)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
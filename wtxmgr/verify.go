@@ -0,0 +1,363 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wtxmgr
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// AnomalyKind identifies a category of inconsistency found within the store.
+type AnomalyKind uint8
+
+// These constants describe the inconsistencies that can be reported by
+// VerifyIntegrity.
+const (
+	// AnomalyNegativeBalance describes a stored mined balance which is
+	// negative.
+	AnomalyNegativeBalance AnomalyKind = iota
+
+	// AnomalyBalanceMismatch describes a stored mined balance which does
+	// not match the total value of the mined credits which are not spent
+	// by a mined transaction.
+	AnomalyBalanceMismatch
+
+	// AnomalyMissingDebit describes a credit marked as spent without a
+	// matching debit record for the spending input.
+	AnomalyMissingDebit
+
+	// AnomalyMissingCredit describes a debit record which does not
+	// reference a credit marked as spent by it.
+	AnomalyMissingCredit
+
+	// AnomalyUnspentIndex describes an unspent index entry which is
+	// missing for an unspent credit, or which references a credit that is
+	// spent or does not exist.
+	AnomalyUnspentIndex
+
+	// AnomalyMissingTxRecord describes a block record, credit or debit
+	// referencing a transaction that has no transaction record.
+	AnomalyMissingTxRecord
+
+	// AnomalyMissingBlockRecord describes a mined transaction record whose
+	// block record does not exist or does not include the transaction.
+	AnomalyMissingBlockRecord
+)
+
+var anomalyStrs = [...]string{
+	AnomalyNegativeBalance:    "AnomalyNegativeBalance",
+	AnomalyBalanceMismatch:    "AnomalyBalanceMismatch",
+	AnomalyMissingDebit:       "AnomalyMissingDebit",
+	AnomalyMissingCredit:      "AnomalyMissingCredit",
+	AnomalyUnspentIndex:       "AnomalyUnspentIndex",
+	AnomalyMissingTxRecord:    "AnomalyMissingTxRecord",
+	AnomalyMissingBlockRecord: "AnomalyMissingBlockRecord",
+}
+
+// String returns the AnomalyKind as a human-readable name.
+func (k AnomalyKind) String() string {
+	if k < AnomalyKind(len(anomalyStrs)) {
+		return anomalyStrs[k]
+	}
+	return fmt.Sprintf("AnomalyKind(%d)", k)
+}
+
+// Anomaly describes a single inconsistency found within the store.
+type Anomaly struct {
+	Kind        AnomalyKind // Describes the kind of inconsistency
+	Description string      // Human readable description of the issue
+}
+
+// String returns a human-readable description of the anomaly.
+func (a Anomaly) String() string {
+	return fmt.Sprintf("%v: %s", a.Kind, a.Description)
+}
+
+// anomalies collects the inconsistencies found while verifying the store.
+type anomalies []Anomaly
+
+func (a *anomalies) add(kind AnomalyKind, format string, args ...interface{}) {
+	*a = append(*a, Anomaly{
+		Kind:        kind,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+// VerifyIntegrity cross-checks the records of the store for internal
+// consistency and returns every inconsistency found.  The mined balance, the
+// links between credits and the debits spending them, the unspent index and
+// the block records are all checked.  The store is never modified, so this may
+// be used to diagnose suspected corruption before trusting any balances.
+//
+// An error is only returned if the records can't be read at all.
+func (s *Store) VerifyIntegrity(ns walletdb.ReadBucket) ([]Anomaly, error) {
+	var found anomalies
+
+	if err := verifyBlockRecords(ns, &found); err != nil {
+		return nil, err
+	}
+	if err := verifyTxRecords(ns, &found); err != nil {
+		return nil, err
+	}
+	minedBalance, err := verifyCredits(ns, &found)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyDebits(ns, &found); err != nil {
+		return nil, err
+	}
+	if err := verifyUnspent(ns, &found); err != nil {
+		return nil, err
+	}
+	if err := verifyUnminedCredits(ns, &found); err != nil {
+		return nil, err
+	}
+
+	storedBalance, err := fetchMinedBalance(ns)
+	if err != nil {
+		return nil, err
+	}
+	if storedBalance < 0 {
+		found.add(AnomalyNegativeBalance, "mined balance is %v",
+			storedBalance)
+	}
+	if storedBalance != minedBalance {
+		found.add(AnomalyBalanceMismatch, "mined balance is %v, but "+
+			"unspent mined credits total %v", storedBalance,
+			minedBalance)
+	}
+
+	return found, nil
+}
+
+// verifyIterError converts an error returned while iterating over a bucket to
+// a store error.
+func verifyIterError(err error, bucket []byte) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(Error); ok {
+		return err
+	}
+	str := fmt.Sprintf("failed iterating %s bucket", bucket)
+	return storeError(ErrDatabase, str, err)
+}
+
+// verifyBlockRecords ensures every transaction included in a block record has
+// a transaction record for that block.
+func verifyBlockRecords(ns walletdb.ReadBucket, found *anomalies) error {
+	var block blockRecord
+	err := ns.NestedReadBucket(bucketBlocks).ForEach(func(k, v []byte) error {
+		if err := readRawBlockRecord(k, v, &block); err != nil {
+			return err
+		}
+		for i := range block.transactions {
+			txHash := &block.transactions[i]
+			_, v := existsTxRecord(ns, txHash, &block.Block)
+			if v == nil {
+				found.add(AnomalyMissingTxRecord, "block %v "+
+					"(height %d) includes transaction %v "+
+					"without a record", block.Hash,
+					block.Height, txHash)
+			}
+		}
+		return nil
+	})
+	return verifyIterError(err, bucketBlocks)
+}
+
+// verifyTxRecords ensures every mined transaction record is included in the
+// block record at its height.
+func verifyTxRecords(ns walletdb.ReadBucket, found *anomalies) error {
+	var (
+		txBlock  Block
+		blockRec blockRecord
+	)
+	err := ns.NestedReadBucket(bucketTxRecords).ForEach(func(k, v []byte) error {
+		if err := readRawTxRecordBlock(k, &txBlock); err != nil {
+			return err
+		}
+		var txHash chainhash.Hash
+		copy(txHash[:], k[:32])
+
+		bk, bv := existsBlockRecord(ns, txBlock.Height)
+		if bv == nil {
+			found.add(AnomalyMissingBlockRecord, "transaction %v "+
+				"is mined at height %d without a block record",
+				txHash, txBlock.Height)
+			return nil
+		}
+		if err := readRawBlockRecord(bk, bv, &blockRec); err != nil {
+			return err
+		}
+		if blockRec.Hash != txBlock.Hash {
+			found.add(AnomalyMissingBlockRecord, "transaction %v "+
+				"is mined in block %v, but the block record at "+
+				"height %d is for block %v", txHash,
+				txBlock.Hash, txBlock.Height, blockRec.Hash)
+			return nil
+		}
+		for _, h := range blockRec.transactions {
+			if h == txHash {
+				return nil
+			}
+		}
+		found.add(AnomalyMissingBlockRecord, "transaction %v is not "+
+			"included in the record of block %v (height %d)",
+			txHash, txBlock.Hash, txBlock.Height)
+		return nil
+	})
+	return verifyIterError(err, bucketTxRecords)
+}
+
+// verifyCredits ensures every mined credit has a transaction record, that
+// spent credits have a matching debit, and that unspent credits are recorded
+// in the unspent index.  The total value of the credits not spent by a mined
+// transaction is returned, which should match the mined balance.
+func verifyCredits(ns walletdb.ReadBucket, found *anomalies) (btcutil.Amount,
+	error) {
+
+	var minedBalance btcutil.Amount
+	debits := ns.NestedReadBucket(bucketDebits)
+	err := ns.NestedReadBucket(bucketCredits).ForEach(func(k, v []byte) error {
+		if len(k) < 72 {
+			str := fmt.Sprintf("%s: short key (expected %d "+
+				"bytes, read %d)", bucketCredits, 72, len(k))
+			return storeError(ErrData, str, nil)
+		}
+		amt, spent, err := fetchRawCreditAmountSpent(v)
+		if err != nil {
+			return err
+		}
+
+		var op wire.OutPoint
+		copy(op.Hash[:], k[:32])
+		op.Index = extractRawCreditIndex(k)
+
+		if existsRawTxRecord(ns, extractRawCreditTxRecordKey(k)) == nil {
+			found.add(AnomalyMissingTxRecord, "credit %v has no "+
+				"transaction record", op)
+		}
+
+		unspentKey := canonicalOutPoint(&op.Hash, op.Index)
+		indexed := bytes.Equal(existsRawUnspent(ns, unspentKey), k)
+
+		if !spent {
+			minedBalance += amt
+			if !indexed {
+				found.add(AnomalyUnspentIndex, "unspent credit "+
+					"%v is missing from the unspent index",
+					op)
+			}
+			return nil
+		}
+
+		if indexed {
+			found.add(AnomalyUnspentIndex, "spent credit %v is "+
+				"recorded in the unspent index", op)
+		}
+		if len(v) < 81 {
+			found.add(AnomalyMissingDebit, "spent credit %v does "+
+				"not reference its spender", op)
+			return nil
+		}
+		debitValue := debits.Get(v[9:81])
+		if len(debitValue) < 80 ||
+			!bytes.Equal(extractRawDebitCreditKey(debitValue), k) {
+
+			var spender chainhash.Hash
+			copy(spender[:], v[9:41])
+			found.add(AnomalyMissingDebit, "spent credit %v has "+
+				"no matching debit for spender %v input %d",
+				op, spender, byteOrder.Uint32(v[77:81]))
+		}
+		return nil
+	})
+	return minedBalance, verifyIterError(err, bucketCredits)
+}
+
+// verifyDebits ensures every debit has a transaction record and references a
+// credit that is marked as spent by it.
+func verifyDebits(ns walletdb.ReadBucket, found *anomalies) error {
+	credits := ns.NestedReadBucket(bucketCredits)
+	err := ns.NestedReadBucket(bucketDebits).ForEach(func(k, v []byte) error {
+		if len(k) < 72 {
+			str := fmt.Sprintf("%s: short key (expected %d "+
+				"bytes, read %d)", bucketDebits, 72, len(k))
+			return storeError(ErrData, str, nil)
+		}
+		if len(v) < 80 {
+			str := fmt.Sprintf("%s: short read (expected %d "+
+				"bytes, read %d)", bucketDebits, 80, len(v))
+			return storeError(ErrData, str, nil)
+		}
+
+		var txHash chainhash.Hash
+		copy(txHash[:], k[:32])
+		index := byteOrder.Uint32(k[68:72])
+
+		if existsRawTxRecord(ns, k[:68]) == nil {
+			found.add(AnomalyMissingTxRecord, "debit %v input %d "+
+				"has no transaction record", txHash, index)
+		}
+
+		credKey := extractRawDebitCreditKey(v)
+		credValue := credits.Get(credKey)
+		if len(credValue) < 81 || credValue[8]&(1<<0) == 0 ||
+			!bytes.Equal(credValue[9:81], k) {
+
+			var op wire.OutPoint
+			copy(op.Hash[:], credKey[:32])
+			op.Index = extractRawCreditIndex(credKey)
+			found.add(AnomalyMissingCredit, "debit %v input %d "+
+				"spends credit %v, which is not marked as "+
+				"spent by it", txHash, index, op)
+		}
+		return nil
+	})
+	return verifyIterError(err, bucketDebits)
+}
+
+// verifyUnspent ensures every unspent index entry references an existing
+// credit.  Entries referencing spent credits are reported by verifyCredits.
+func verifyUnspent(ns walletdb.ReadBucket, found *anomalies) error {
+	credits := ns.NestedReadBucket(bucketCredits)
+	err := ns.NestedReadBucket(bucketUnspent).ForEach(func(k, v []byte) error {
+		var op wire.OutPoint
+		if err := readCanonicalOutPoint(k, &op); err != nil {
+			return err
+		}
+		credKey := existsRawUnspent(ns, k)
+		if credKey == nil || credits.Get(credKey) == nil {
+			found.add(AnomalyUnspentIndex, "unspent index entry "+
+				"%v has no credit", op)
+		}
+		return nil
+	})
+	return verifyIterError(err, bucketUnspent)
+}
+
+// verifyUnminedCredits ensures every unmined credit has an unmined transaction
+// record.
+func verifyUnminedCredits(ns walletdb.ReadBucket, found *anomalies) error {
+	bucket := ns.NestedReadBucket(bucketUnminedCredits)
+	err := bucket.ForEach(func(k, v []byte) error {
+		var op wire.OutPoint
+		if err := readCanonicalOutPoint(k, &op); err != nil {
+			return err
+		}
+		if existsRawUnmined(ns, op.Hash[:]) == nil {
+			found.add(AnomalyMissingTxRecord, "unmined credit %v "+
+				"has no transaction record", op)
+		}
+		return nil
+	})
+	return verifyIterError(err, bucketUnminedCredits)
+}
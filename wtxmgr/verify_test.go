@@ -0,0 +1,174 @@
+// Copyright (c) 2021 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wtxmgr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// TestVerifyIntegrity ensures that inconsistencies seeded within the store
+// are reported by VerifyIntegrity, and that it reports none otherwise.
+func TestVerifyIntegrity(t *testing.T) {
+	t.Parallel()
+
+	store, db, teardown, err := testStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	b100 := BlockMeta{
+		Block: Block{Hash: chainhash.Hash{1}, Height: 100},
+		Time:  time.Now(),
+	}
+	b101 := BlockMeta{
+		Block: Block{Hash: chainhash.Hash{2}, Height: 101},
+		Time:  time.Now(),
+	}
+
+	// We'll start with a confirmed transaction with two outputs belonging
+	// to the wallet, the first of which is spent by another confirmed
+	// transaction.
+	cbRec, err := NewTxRecordFromMsgTx(newCoinBase(1e8, 2e8), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	spendRec, err := NewTxRecordFromMsgTx(
+		spendOutput(&cbRec.Hash, 0, 9e7), time.Now(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitDBTx(t, store, db, func(ns walletdb.ReadWriteBucket) {
+		if err := store.InsertTx(ns, cbRec, &b100); err != nil {
+			t.Fatal(err)
+		}
+		for i := uint32(0); i < 2; i++ {
+			err := store.AddCredit(ns, cbRec, &b100, i, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := store.InsertTx(ns, spendRec, &b101); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	spentCredit := wire.OutPoint{Hash: cbRec.Hash, Index: 0}
+	unspentCredit := wire.OutPoint{Hash: cbRec.Hash, Index: 1}
+
+	tests := []struct {
+		name    string
+		corrupt func(walletdb.ReadWriteBucket) error
+		kinds   []AnomalyKind
+	}{
+		{
+			name:    "consistent",
+			corrupt: func(walletdb.ReadWriteBucket) error { return nil },
+		},
+		{
+			name: "negative balance",
+			corrupt: func(ns walletdb.ReadWriteBucket) error {
+				return putMinedBalance(ns, -1)
+			},
+			kinds: []AnomalyKind{
+				AnomalyNegativeBalance, AnomalyBalanceMismatch,
+			},
+		},
+		{
+			name: "balance mismatch",
+			corrupt: func(ns walletdb.ReadWriteBucket) error {
+				return putMinedBalance(ns, 3e8)
+			},
+			kinds: []AnomalyKind{AnomalyBalanceMismatch},
+		},
+		{
+			name: "missing debit",
+			corrupt: func(ns walletdb.ReadWriteBucket) error {
+				k := keyDebit(&spendRec.Hash, 0, &b101.Block)
+				return deleteRawDebit(ns, k)
+			},
+			kinds: []AnomalyKind{AnomalyMissingDebit},
+		},
+		{
+			name: "missing credit",
+			corrupt: func(ns walletdb.ReadWriteBucket) error {
+				k := keyCredit(
+					&spentCredit.Hash, spentCredit.Index,
+					&b100.Block,
+				)
+				return deleteRawCredit(ns, k)
+			},
+			kinds: []AnomalyKind{AnomalyMissingCredit},
+		},
+		{
+			name: "missing unspent index entry",
+			corrupt: func(ns walletdb.ReadWriteBucket) error {
+				k := canonicalOutPoint(
+					&unspentCredit.Hash, unspentCredit.Index,
+				)
+				return deleteRawUnspent(ns, k)
+			},
+			kinds: []AnomalyKind{AnomalyUnspentIndex},
+		},
+		{
+			name: "missing tx record",
+			corrupt: func(ns walletdb.ReadWriteBucket) error {
+				return deleteTxRecord(ns, &cbRec.Hash, &b100.Block)
+			},
+			kinds: []AnomalyKind{AnomalyMissingTxRecord},
+		},
+		{
+			name: "missing block record",
+			corrupt: func(ns walletdb.ReadWriteBucket) error {
+				return deleteBlockRecord(ns, b101.Height)
+			},
+			kinds: []AnomalyKind{AnomalyMissingBlockRecord},
+		},
+	}
+
+	for _, test := range tests {
+		// Each inconsistency is seeded within a database transaction
+		// that's rolled back afterwards, so that they're verified in
+		// isolation.
+		dbTx, err := db.BeginReadWriteTx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ns := dbTx.ReadWriteBucket(namespaceKey)
+
+		if err := test.corrupt(ns); err != nil {
+			dbTx.Rollback()
+			t.Fatalf("%s: unable to seed inconsistency: %v",
+				test.name, err)
+		}
+		anomalies, err := store.VerifyIntegrity(ns)
+		dbTx.Rollback()
+		if err != nil {
+			t.Fatalf("%s: unable to verify integrity: %v",
+				test.name, err)
+		}
+
+		kinds := make(map[AnomalyKind]struct{})
+		for _, anomaly := range anomalies {
+			kinds[anomaly.Kind] = struct{}{}
+		}
+		if len(kinds) != len(test.kinds) {
+			t.Fatalf("%s: expected anomalies of kinds %v, got %v",
+				test.name, test.kinds, anomalies)
+		}
+		for _, kind := range test.kinds {
+			if _, ok := kinds[kind]; !ok {
+				t.Fatalf("%s: expected anomaly of kind %v, "+
+					"got %v", test.name, kind, anomalies)
+			}
+		}
+	}
+}